@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/backtest"
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func setupLogger(level string) {
+	// 报告输出到stdout，日志输出到stderr
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339})
+	switch level {
+	case "debug":
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	case "info":
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	case "warn":
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	case "error":
+		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	default:
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	}
+}
+
+// parseGrid 解析扫描参数，格式: "grid_start_offset=0.8,1.2;ETHUSDC.inventory_skew_coeff=0.001,0.002"
+func parseGrid(spec string) (backtest.ParamGrid, error) {
+	grid := make(backtest.ParamGrid)
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("无效的扫描参数: %s", part)
+		}
+		key := strings.TrimSpace(kv[0])
+		for _, raw := range strings.Split(kv[1], ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+			if err != nil {
+				return nil, fmt.Errorf("参数 %s 取值无效: %w", key, err)
+			}
+			grid[key] = append(grid[key], v)
+		}
+	}
+	return grid, nil
+}
+
+func main() {
+	cfgPath := flag.String("config", "config.yaml", "配置文件路径")
+	dataPath := flag.String("data", "", "录制数据文件或目录（多个用逗号分隔）")
+	sweepSpec := flag.String("sweep", "", "参数扫描，如 grid_start_offset=0.8,1.2;inventory_skew_coeff=0.001,0.002")
+	parallel := flag.Int("parallel", 4, "参数扫描并行度")
+	interval := flag.Duration("interval", 0, "报价间隔（默认使用配置quote_interval_ms）")
+	makerFee := flag.Float64("maker-fee", 0.0002, "Maker费率")
+	balance := flag.Float64("balance", 10000, "初始资金")
+	jsonOut := flag.Bool("json", false, "以JSON输出报告")
+	logLevel := flag.String("log", "warn", "日志级别 (debug, info, warn, error)")
+	flag.Parse()
+
+	setupLogger(*logLevel)
+
+	if *dataPath == "" {
+		log.Fatal().Msg("必须通过 -data 指定录制数据")
+	}
+
+	cfg, err := config.ReadConfig(*cfgPath)
+	if err != nil {
+		log.Fatal().Err(err).Msg("加载配置失败")
+	}
+
+	events, err := backtest.LoadEvents(strings.Split(*dataPath, ",")...)
+	if err != nil {
+		log.Fatal().Err(err).Msg("加载录制数据失败")
+	}
+	log.Warn().Int("events", len(events)).Msg("录制数据加载完成，开始回测")
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	opts := backtest.Options{
+		QuoteInterval:  *interval,
+		MakerFeeRate:   *makerFee,
+		InitialBalance: *balance,
+	}
+
+	if *sweepSpec == "" {
+		report, err := backtest.NewEngine(cfg, events, opts).Run(ctx)
+		if err != nil {
+			log.Fatal().Err(err).Msg("回测失败")
+		}
+		if *jsonOut {
+			writeJSON(report)
+			return
+		}
+		report.WriteText(os.Stdout)
+		return
+	}
+
+	grid, err := parseGrid(*sweepSpec)
+	if err != nil {
+		log.Fatal().Err(err).Msg("解析扫描参数失败")
+	}
+	results := backtest.Sweep(ctx, cfg, events, opts, grid, *parallel)
+	if *jsonOut {
+		writeJSON(results)
+		return
+	}
+	for _, res := range results {
+		fmt.Printf("==== %s ====\n", res.Params)
+		if res.Err != "" {
+			fmt.Printf("失败: %s\n\n", res.Err)
+			continue
+		}
+		res.Report.WriteText(os.Stdout)
+		fmt.Println()
+	}
+}
+
+func writeJSON(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		log.Fatal().Err(err).Msg("输出报告失败")
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+)
+
+// errLimit 达到输出条数上限时提前结束遍历
+var errLimit = errors.New("limit reached")
+
+func parseTime(name, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "无效的 -%s 时间（需要RFC3339格式）: %v\n", name, err)
+		os.Exit(2)
+	}
+	return t
+}
+
+func main() {
+	dir := flag.String("dir", "./data/journal", "事件日志目录")
+	symbol := flag.String("symbol", "", "按交易对过滤")
+	entryType := flag.String("type", "", "按事件类型过滤 (order_placed, order_canceled, order_amended, fill, position, funding, funding_payment)，逗号分隔")
+	orderID := flag.String("order", "", "按客户端订单ID过滤")
+	since := flag.String("since", "", "起始时间（RFC3339）")
+	until := flag.String("until", "", "结束时间（RFC3339）")
+	afterSeq := flag.Uint64("after-seq", 0, "只输出序号大于该值的事件")
+	limit := flag.Int("limit", 0, "最多输出条数，0表示不限制")
+	state := flag.Bool("state", false, "输出由检查点与日志尾部重建的各交易对状态，而不是事件列表")
+	flag.Parse()
+
+	enc := json.NewEncoder(os.Stdout)
+
+	if *state {
+		cp, err := store.ReplayJournal(*dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "重建状态失败: %v\n", err)
+			os.Exit(1)
+		}
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(cp); err != nil {
+			fmt.Fprintf(os.Stderr, "输出状态失败: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	types := make(map[store.EntryType]bool)
+	for _, t := range strings.Split(*entryType, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[store.EntryType(t)] = true
+		}
+	}
+	sinceTime := parseTime("since", *since)
+	untilTime := parseTime("until", *until)
+	sym := strings.ToUpper(*symbol)
+
+	count := 0
+	err := store.ScanJournal(*dir, *afterSeq, func(e *store.JournalEntry) error {
+		if sym != "" && e.Symbol != sym {
+			return nil
+		}
+		if len(types) > 0 && !types[e.Type] {
+			return nil
+		}
+		if !sinceTime.IsZero() && e.Time.Before(sinceTime) {
+			return nil
+		}
+		if !untilTime.IsZero() && e.Time.After(untilTime) {
+			return nil
+		}
+		if *orderID != "" && !matchOrder(e, *orderID) {
+			return nil
+		}
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		count++
+		if *limit > 0 && count >= *limit {
+			return errLimit
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errLimit) {
+		fmt.Fprintf(os.Stderr, "查询事件日志失败: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// matchOrder 判断事件是否属于指定客户端订单
+func matchOrder(e *store.JournalEntry, clientOrderID string) bool {
+	if e.Order != nil && e.Order.ClientOrderID == clientOrderID {
+		return true
+	}
+	return e.Fill != nil && e.Fill.ClientOrderID == clientOrderID
+}
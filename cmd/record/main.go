@@ -0,0 +1,203 @@
+package main
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/newplayman/market-maker-phoenix/internal/orderbook"
+	"github.com/newplayman/market-maker-phoenix/internal/recorder"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+func setupLogger(level string) {
+	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339})
+	switch level {
+	case "debug":
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	case "info":
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	case "warn":
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	case "error":
+		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	default:
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	}
+}
+
+// recordHandler 不交易，仅维护本地订单簿：首次收到增量或断档时拉取REST快照并写入录制文件，
+// 使回放时可以锚定增量深度。原始消息在 OnRawMessage 中写入录制器
+type recordHandler struct {
+	rec   *recorder.Recorder
+	books *orderbook.Manager
+}
+
+func (h *recordHandler) OnDepth(symbol string, bid, ask float64)   {}
+func (h *recordHandler) OnTrade(symbol string, price, qty float64) {}
+
+func (h *recordHandler) OnRawMessage(msg []byte) {
+	h.rec.Record(msg)
+	diff, err := gateway.ParseCombinedDepthDiff(msg)
+	if err != nil {
+		return
+	}
+	h.books.Apply(orderbook.Update{
+		Symbol:            diff.Symbol,
+		EventTime:         diff.EventTime,
+		FirstUpdateID:     diff.FirstUpdateID,
+		FinalUpdateID:     diff.FinalUpdateID,
+		PrevFinalUpdateID: diff.PrevFinalUpdateID,
+		Bids:              toBookLevels(diff.Bids),
+		Asks:              toBookLevels(diff.Asks),
+	})
+}
+
+func toBookLevels(levels []gateway.PriceLevel) []orderbook.Level {
+	out := make([]orderbook.Level, len(levels))
+	for i, lv := range levels {
+		out[i] = orderbook.Level{Price: lv.Price, Quantity: lv.Quantity}
+	}
+	return out
+}
+
+func fromBookLevels(levels []orderbook.Level) []gateway.PriceLevel {
+	out := make([]gateway.PriceLevel, len(levels))
+	for i, lv := range levels {
+		out[i] = gateway.PriceLevel{Price: lv.Price, Quantity: lv.Quantity}
+	}
+	return out
+}
+
+func main() {
+	cfgPath := flag.String("config", "", "配置文件路径（用于读取交易对与API Key，可选）")
+	symbolsFlag := flag.String("symbols", "", "交易对列表，逗号分隔（覆盖配置文件）")
+	dir := flag.String("dir", "./data/record", "录制输出目录")
+	compression := flag.String("compression", recorder.CompressionZstd, "压缩格式 (zstd, gzip, none)")
+	maxFileMB := flag.Int64("max-file-mb", 0, "单文件未压缩大小上限（MB），0表示只按天切分")
+	trades := flag.Bool("trades", true, "是否录制aggTrade逐笔成交")
+	user := flag.Bool("user", false, "是否录制用户数据流（需要API Key）")
+	logLevel := flag.String("log", "info", "日志级别 (debug, info, warn, error)")
+	flag.Parse()
+
+	setupLogger(*logLevel)
+
+	var cfg *config.Config
+	if *cfgPath != "" {
+		c, err := config.ReadConfig(*cfgPath)
+		if err != nil {
+			log.Fatal().Err(err).Msg("加载配置失败")
+		}
+		cfg = c
+	}
+
+	var symbols []string
+	if *symbolsFlag != "" {
+		for _, s := range strings.Split(*symbolsFlag, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				symbols = append(symbols, strings.ToUpper(s))
+			}
+		}
+	} else if cfg != nil {
+		for _, symCfg := range cfg.Symbols {
+			symbols = append(symbols, symCfg.Symbol)
+		}
+	}
+	if len(symbols) == 0 {
+		log.Fatal().Msg("未指定交易对：请使用 -symbols 或 -config")
+	}
+
+	rec, err := recorder.New(recorder.Options{
+		Dir:          *dir,
+		Compression:  *compression,
+		MaxFileBytes: *maxFileMB * 1024 * 1024,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("创建录制器失败")
+	}
+
+	ws := gateway.NewBinanceWSReal()
+	for _, symbol := range symbols {
+		if err := ws.SubscribeDepth(symbol); err != nil {
+			log.Fatal().Err(err).Str("symbol", symbol).Msg("订阅深度失败")
+		}
+		if *trades {
+			if err := ws.SubscribeTrade(symbol); err != nil {
+				log.Fatal().Err(err).Str("symbol", symbol).Msg("订阅逐笔成交失败")
+			}
+		}
+	}
+
+	if *user {
+		if cfg == nil || cfg.Global.APIKey == "" {
+			log.Fatal().Msg("录制用户数据流需要在配置文件中提供API Key")
+		}
+		lk := &gateway.ListenKeyClient{
+			BaseURL:    gateway.BinanceFuturesRestEndpoint,
+			APIKey:     cfg.Global.APIKey,
+			HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		}
+		listenKey, err := lk.NewListenKey()
+		if err != nil {
+			log.Fatal().Err(err).Msg("创建listenKey失败")
+		}
+		if err := ws.SubscribeUserData(listenKey); err != nil {
+			log.Fatal().Err(err).Msg("订阅用户数据流失败")
+		}
+		go func() {
+			ticker := time.NewTicker(30 * time.Minute)
+			defer ticker.Stop()
+			for range ticker.C {
+				if err := lk.KeepAlive(listenKey); err != nil {
+					log.Error().Err(err).Msg("listenKey续期失败")
+				}
+			}
+		}()
+	}
+
+	// 深度快照为公开接口，无需API Key
+	rest := &gateway.BinanceRESTClient{
+		BaseURL:    gateway.BinanceFuturesRestEndpoint,
+		HTTPClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	books := orderbook.NewManager(func(symbol string) (orderbook.Snapshot, error) {
+		lastUpdateID, bids, asks, err := rest.DepthSnapshot(symbol, 1000)
+		if err != nil {
+			return orderbook.Snapshot{}, err
+		}
+		return orderbook.Snapshot{LastUpdateID: lastUpdateID, Bids: toBookLevels(bids), Asks: toBookLevels(asks)}, nil
+	})
+	books.OnSnapshot = func(symbol string, snap orderbook.Snapshot) {
+		rec.Record(gateway.EncodeDepthSnapshotMessage(symbol, snap.LastUpdateID, fromBookLevels(snap.Bids), fromBookLevels(snap.Asks)))
+	}
+
+	go func() {
+		if err := ws.Run(&recordHandler{rec: rec, books: books}); err != nil {
+			log.Error().Err(err).Msg("WebSocket运行错误")
+		}
+	}()
+
+	log.Info().
+		Strs("symbols", symbols).
+		Str("dir", *dir).
+		Bool("trades", *trades).
+		Bool("user", *user).
+		Msg("开始录制行情（不交易）")
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	log.Info().Msg("收到退出信号，正在刷盘...")
+	if err := rec.Close(); err != nil {
+		log.Error().Err(err).Msg("关闭录制器失败")
+	}
+	log.Info().Int64("dropped", rec.Dropped()).Msg("录制已停止")
+}
@@ -11,7 +11,10 @@ import (
 
 	"github.com/newplayman/market-maker-phoenix/internal/config"
 	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/newplayman/market-maker-phoenix/internal/hedge"
 	"github.com/newplayman/market-maker-phoenix/internal/metrics"
+	"github.com/newplayman/market-maker-phoenix/internal/paper"
+	"github.com/newplayman/market-maker-phoenix/internal/recorder"
 	"github.com/newplayman/market-maker-phoenix/internal/risk"
 	"github.com/newplayman/market-maker-phoenix/internal/runner"
 	"github.com/newplayman/market-maker-phoenix/internal/store"
@@ -67,10 +70,17 @@ func main() {
 
 	// 初始化Store
 	log.Info().Msg("初始化Store...")
-	st := store.NewStore(
-		cfg.Global.SnapshotPath,
+	if cfg.Global.JournalDir == "" && !cfg.Global.IsPaper() {
+		log.Warn().Msg("未配置 journal_dir：订单/成交/仓位状态仅保存在内存中，重启后无法恢复")
+	}
+	st, err := store.OpenStore(
+		cfg.Global.JournalDir,
 		time.Duration(cfg.Global.SnapshotInterval)*time.Second,
+		store.JournalOptions{SyncInterval: time.Duration(cfg.Global.JournalSyncMs) * time.Millisecond},
 	)
+	if err != nil {
+		log.Fatal().Err(err).Msg("事件日志恢复失败")
+	}
 	defer st.Close()
 	log.Info().Msg("Store初始化完成")
 
@@ -83,7 +93,7 @@ func main() {
 
 	// 创建策略
 	log.Info().Msg("创建策略...")
-	strat := strategy.NewASMM(cfg, st)
+	strat := strategy.New(cfg, st)
 	log.Info().Msg("策略创建完成")
 
 	// 创建风控管理器
@@ -93,26 +103,50 @@ func main() {
 
 	// 创建Exchange适配器
 	log.Info().
+		Str("exchange", cfg.Global.Venue()).
 		Bool("testnet", cfg.Global.TestNet).
-		Msg("初始化Binance连接")
+		Msg("初始化交易所连接")
 
-	// 创建REST客户端
-	rest := &gateway.BinanceRESTClient{
-		BaseURL:      gateway.BinanceFuturesRestEndpoint,
-		APIKey:       cfg.Global.APIKey,
-		Secret:       cfg.Global.APISecret,
-		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
-		RecvWindowMs: 5000,
-		Limiter:      gateway.NewCompositeLimiter(20, 100, 100, 1200), // rate=20/s, burst=100, max10s=100, max60s=1200
-		MaxRetries:   3,
-		RetryDelay:   time.Second,
+	var feed paper.MarketFeed
+	var exchange gateway.Exchange
+	var binanceAdapter *gateway.BinanceAdapter
+	switch cfg.Global.Venue() {
+	case config.ExchangeBybit:
+		adapter := newBybitAdapter(cfg)
+		feed, exchange = adapter, adapter
+	default:
+		binanceAdapter = newBinanceAdapter(cfg)
+		feed, exchange = binanceAdapter, binanceAdapter
 	}
 
-	// 创建WebSocket客户端
-	ws := gateway.NewBinanceWSReal()
+	// 模拟盘：行情来自适配器，订单在内存中撮合
+	if cfg.Global.IsPaper() {
+		exchange = paper.New(feed, paper.Options{
+			MakerFeeRate:   cfg.Global.PaperMakerFee,
+			InitialBalance: cfg.Global.PaperBalance,
+		})
+		log.Warn().
+			Float64("balance", cfg.Global.PaperBalance).
+			Float64("maker_fee", cfg.Global.PaperMakerFee).
+			Msg("【模拟盘模式】不会向交易所发送任何订单")
+	}
 
-	// 创建适配器
-	exchange := gateway.NewBinanceAdapter(rest, ws)
+	// 可选：旁路录制原始WS消息，便于回测与事故复盘（录制格式为Binance原始消息）
+	if cfg.Global.RecordDir != "" {
+		if binanceAdapter == nil {
+			log.Warn().Str("exchange", cfg.Global.Venue()).Msg("行情录制仅支持Binance，已忽略record_dir")
+		} else {
+			rec, err := recorder.New(recorder.Options{
+				Dir:         cfg.Global.RecordDir,
+				Compression: cfg.Global.RecordCompression,
+			})
+			if err != nil {
+				log.Fatal().Err(err).Msg("创建行情录制器失败")
+			}
+			defer rec.Close()
+			binanceAdapter.SetRecorder(rec)
+		}
+	}
 
 	// 启动Prometheus监控
 	if err := metrics.StartMetricsServer(cfg.Global.MetricsPort); err != nil {
@@ -121,6 +155,25 @@ func main() {
 
 	// 创建Runner
 	r := runner.NewRunner(cfg, st, strat, riskMgr, exchange)
+	if src, ok := feed.(gateway.SymbolFilterSource); ok && cfg.Global.IsPaper() {
+		// 模拟盘同样按真实交易所的交易规则取整报价
+		r.SetFilterSource(src)
+	}
+	config.OnReload(func(*config.Config) {
+		r.RequestRequote("", runner.ReasonConfig)
+	})
+
+	// 创建对冲引擎，注册为风控的对冲敞口来源并接收同账户对冲单推送（需在Runner启动前完成，Runner协程会读取对冲敞口）
+	var hedges *hedge.Manager
+	if len(cfg.Hedges) > 0 {
+		if cfg.Global.IsPaper() {
+			log.Warn().Int("hedges", len(cfg.Hedges)).Msg("【模拟盘模式】不支持对冲，已忽略hedges配置")
+		} else {
+			hedges = newHedges(ctx, cfg, st, exchange)
+			riskMgr.SetHedgeSource(hedges)
+			r.SetExternalOrderHandler(hedges.OnOrderUpdate)
+		}
+	}
 
 	// 启动Runner
 	log.Info().Msg("正在启动Runner...")
@@ -128,6 +181,11 @@ func main() {
 		log.Fatal().Err(err).Msg("启动Runner失败")
 	}
 
+	// 启动对冲引擎（需在Runner连接交易所之后，同一账户的对冲腿共用其用户数据流）
+	if hedges != nil {
+		hedges.Start(ctx)
+	}
+
 	log.Info().Msg("Phoenix系统启动完成，开始做市...")
 
 	// 等待退出信号
@@ -144,6 +202,91 @@ func main() {
 	log.Info().Msg("Phoenix系统已关闭")
 }
 
+// newHedges 创建对冲引擎（不启动）；配置了独立账户的对冲腿使用单独的适配器，在此连接并订阅其用户数据流
+func newHedges(ctx context.Context, cfg *config.Config, st *store.Store, exchange gateway.Exchange) *hedge.Manager {
+	engines := make([]*hedge.Engine, 0, len(cfg.Hedges))
+	for _, h := range cfg.Hedges {
+		exch := exchange
+		if h.SeparateAccount() {
+			exch = newHedgeAccount(cfg, h)
+		}
+		engine, err := hedge.NewEngine(h, st, exch)
+		if err != nil {
+			log.Fatal().Err(err).Msg("创建对冲引擎失败")
+		}
+		if h.SeparateAccount() {
+			if err := exch.Connect(ctx); err != nil {
+				log.Fatal().Err(err).Str("hedge_symbol", h.HedgeSymbol).Msg("连接对冲账户失败")
+			}
+			if err := exch.StartUserStream(ctx, &gateway.UserStreamCallbacks{OnOrderUpdate: engine.OnOrderUpdate}); err != nil {
+				log.Fatal().Err(err).Str("hedge_symbol", h.HedgeSymbol).Msg("订阅对冲账户用户数据流失败")
+			}
+		}
+		engines = append(engines, engine)
+	}
+	return hedge.NewManager(engines...)
+}
+
+// newHedgeAccount 按对冲腿的API密钥与交易所创建独立账户的适配器
+func newHedgeAccount(cfg *config.Config, h config.HedgeConfig) gateway.Exchange {
+	accountCfg := *cfg
+	accountCfg.Global.APIKey = h.APIKey
+	accountCfg.Global.APISecret = h.APISecret
+	if h.Exchange != "" {
+		accountCfg.Global.Exchange = h.Exchange
+	}
+	if accountCfg.Global.Venue() == config.ExchangeBybit {
+		return newBybitAdapter(&accountCfg)
+	}
+	return newBinanceAdapter(&accountCfg)
+}
+
+// newBinanceAdapter 创建Binance USDⓈ-M合约适配器
+func newBinanceAdapter(cfg *config.Config) *gateway.BinanceAdapter {
+	rest := &gateway.BinanceRESTClient{
+		BaseURL:      gateway.BinanceFuturesRestEndpoint,
+		APIKey:       cfg.Global.APIKey,
+		Secret:       cfg.Global.APISecret,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+		RecvWindowMs: 5000,
+		Limiter:      gateway.NewWeightLimiter(gateway.WeightLimiterConfig{}), // 按端点权重与下单数计数，响应头校准
+		MaxRetries:   3,
+		RetryDelay:   time.Second,
+	}
+	// REST与WSS交易签名共用的服务器时间，Connect时同步并后台定时校准
+	rest.TimeSync = gateway.NewTimeSync(rest.BaseURL)
+
+	adapter := gateway.NewBinanceAdapter(rest, gateway.NewBinanceWSReal())
+	if cfg.Global.OrderChannel != "" {
+		adapter.SetOrderChannel(gateway.OrderChannel(cfg.Global.OrderChannel))
+	}
+	return adapter
+}
+
+// newBybitAdapter 创建Bybit v5 linear永续适配器，交易对按venue_symbol映射
+func newBybitAdapter(cfg *config.Config) *gateway.BybitAdapter {
+	rest := &gateway.BybitRESTClient{
+		BaseURL:      gateway.BybitRestEndpoint,
+		APIKey:       cfg.Global.APIKey,
+		Secret:       cfg.Global.APISecret,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+		RecvWindowMs: 5000,
+		Limiter:      gateway.NewCompositeLimiter(10, 20, 100, 600), // 下单接口默认10次/秒
+	}
+	adapterCfg := gateway.BybitAdapterConfig{SymbolMap: make(map[string]string)}
+	if cfg.Global.TestNet {
+		rest.BaseURL = gateway.BybitTestnetRestEndpoint
+		adapterCfg.PublicWSURL = gateway.BybitTestnetPublicWSURL
+		adapterCfg.PrivateWSURL = gateway.BybitTestnetPrivateWSURL
+	}
+	for _, sym := range cfg.Symbols {
+		if sym.VenueSymbol != "" {
+			adapterCfg.SymbolMap[sym.Symbol] = sym.VenueSymbol
+		}
+	}
+	return gateway.NewBybitAdapter(rest, adapterCfg)
+}
+
 // setupLogger 设置日志
 func setupLogger(level string) {
 	// 设置日志格式为人类可读的格式
@@ -0,0 +1,173 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+)
+
+var testStart = time.Unix(1700000000, 0)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Global: config.GlobalConfig{
+			TotalNotionalMax: 1000000,
+			QuoteIntervalMs:  500,
+		},
+		Symbols: []config.SymbolConfig{
+			{
+				Symbol:                "ETHUSDC",
+				NetMax:                1.0,
+				MinSpread:             0.0002,
+				TickSize:              0.01,
+				MinQty:                0.001,
+				MaxCancelPerMin:       1000,
+				StopLossThresh:        0.5,
+				TotalLayers:           3,
+				GridStartOffset:       0.5,
+				GridFirstSpacing:      0.5,
+				GridSpacingMultiplier: 1.2,
+				GridMaxSpacing:        5,
+				UnifiedLayerSize:      0.01,
+				InventorySkewCoeff:    0.002,
+			},
+		},
+	}
+}
+
+func levels(price, step, qty float64, n int) []gateway.PriceLevel {
+	out := make([]gateway.PriceLevel, n)
+	for i := range out {
+		out[i] = gateway.PriceLevel{Price: price + step*float64(i), Quantity: qty}
+	}
+	return out
+}
+
+// syntheticEvents 生成在3000附近来回震荡的深度与成交
+func syntheticEvents(n int) []Event {
+	events := make([]Event, 0, n*2)
+	for i := 0; i < n; i++ {
+		ts := testStart.Add(time.Duration(i) * 200 * time.Millisecond)
+		mid := 3000 + float64((i/10)%8) - 4
+		bid, ask := mid-0.05, mid+0.05
+		events = append(events, Event{
+			Time: ts, Kind: EventDepth, Symbol: "ETHUSDC",
+			Bids: levels(bid, -0.5, 2, 10), Asks: levels(ask, 0.5, 2, 10),
+		})
+		trade := &gateway.Trade{Symbol: "ETHUSDC", Price: bid - 2, Quantity: 5, IsBuyerMaker: true}
+		if i%2 == 1 {
+			trade = &gateway.Trade{Symbol: "ETHUSDC", Price: ask + 2, Quantity: 5}
+		}
+		events = append(events, Event{Time: ts.Add(100 * time.Millisecond), Kind: EventTrade, Symbol: "ETHUSDC", Trade: trade})
+	}
+	return events
+}
+
+func TestReadEvents(t *testing.T) {
+	data := strings.Join([]string{
+		`{"ts":1700000000000,"raw":{"stream":"ethusdc@depth20@100ms","data":{"s":"ETHUSDC","b":[["100","1"]],"a":[["100.1","2"]]}}}`,
+		`{"ts":1700000000050,"raw":{"stream":"ethusdc@aggTrade","data":{"e":"aggTrade","E":1700000000050,"s":"ETHUSDC","a":1,"p":"100","q":"0.5","T":1700000000049,"m":true}}}`,
+		`{"ts":1700000000060,"raw":{"stream":"listenKey","data":{"e":"ORDER_TRADE_UPDATE"}}}`,
+	}, "\n")
+
+	events, err := ReadEvents(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(events) != 2 || events[0].Kind != EventDepth || events[1].Kind != EventTrade {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+	if events[0].Asks[0].Quantity != 2 || events[1].Trade.Quantity != 0.5 {
+		t.Fatalf("unexpected payload: %+v", events)
+	}
+}
+
+func TestReadEventsDiffDepth(t *testing.T) {
+	snap := gateway.EncodeDepthSnapshotMessage("ETHUSDC", 10,
+		[]gateway.PriceLevel{{Price: 100, Quantity: 1}}, []gateway.PriceLevel{{Price: 100.1, Quantity: 1}})
+	data := strings.Join([]string{
+		// 快照前的增量被缓存，快照到达后重放
+		`{"ts":1700000000000,"raw":{"stream":"ethusdc@depth@100ms","data":{"e":"depthUpdate","s":"ETHUSDC","U":9,"u":11,"pu":8,"b":[["100","3"]],"a":[]}}}`,
+		fmt.Sprintf(`{"ts":1700000000100,"raw":%s}`, snap),
+		`{"ts":1700000000200,"raw":{"stream":"ethusdc@depth@100ms","data":{"e":"depthUpdate","s":"ETHUSDC","U":12,"u":12,"pu":11,"b":[],"a":[["100.1","0"],["100.2","4"]]}}}`,
+		// 断档后不再产生事件
+		`{"ts":1700000000300,"raw":{"stream":"ethusdc@depth@100ms","data":{"e":"depthUpdate","s":"ETHUSDC","U":20,"u":21,"pu":19,"b":[],"a":[]}}}`,
+	}, "\n")
+
+	events, err := ReadEvents(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 depth events, got %d: %+v", len(events), events)
+	}
+	if events[0].Bids[0].Quantity != 3 || events[1].Asks[0].Price != 100.2 || events[1].Asks[0].Quantity != 4 {
+		t.Fatalf("unexpected book events: %+v", events)
+	}
+}
+
+func TestEngineDeterministic(t *testing.T) {
+	events := syntheticEvents(600)
+	opts := Options{MakerFeeRate: 0.0002, InitialBalance: 10000}
+
+	first, err := NewEngine(testConfig(), events, opts).Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	second, err := NewEngine(testConfig(), events, opts).Run(context.Background())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("backtest is not deterministic:\n%+v\n%+v", first, second)
+	}
+	if first.QuoteCycles == 0 || len(first.Symbols) != 1 {
+		t.Fatalf("unexpected report: %+v", first)
+	}
+	if s := first.Symbols[0]; s.OrdersPlaced == 0 || s.Fills == 0 {
+		t.Fatalf("expected orders and fills, got %+v", s)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	grid := ParamGrid{
+		"grid_start_offset":            {0.5, 1.0},
+		"ETHUSDC.inventory_skew_coeff": {0.001, 0.002},
+	}
+	sets := grid.Expand()
+	if len(sets) != 4 || sets[0].String() != "ETHUSDC.inventory_skew_coeff=0.001,grid_start_offset=0.5" {
+		t.Fatalf("unexpected expansion: %v", sets)
+	}
+
+	cfg := testConfig()
+	applied, err := ApplyParams(cfg, sets[3])
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if applied.Symbols[0].GridStartOffset != 1.0 || cfg.Symbols[0].GridStartOffset != 0.5 {
+		t.Fatalf("params not applied to copy: %+v / %+v", applied.Symbols[0], cfg.Symbols[0])
+	}
+	if _, err := ApplyParams(cfg, ParamSet{"no_such_field": 1}); err == nil {
+		t.Fatal("expected error for unknown field")
+	}
+
+	results := Sweep(context.Background(), cfg, syntheticEvents(200), Options{}, grid, 2)
+	if len(results) != len(sets) {
+		t.Fatalf("expected %d results, got %d", len(sets), len(results))
+	}
+	for i, res := range results {
+		if res.Err != "" || res.Report == nil {
+			t.Fatalf("result %d failed: %s", i, res.Err)
+		}
+		if fmt.Sprint(res.Params) != fmt.Sprint(sets[i]) {
+			t.Fatalf("result order mismatch at %d", i)
+		}
+	}
+}
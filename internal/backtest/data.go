@@ -0,0 +1,232 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/newplayman/market-maker-phoenix/internal/orderbook"
+	"github.com/newplayman/market-maker-phoenix/internal/recorder"
+)
+
+// EventKind 回放事件类型
+type EventKind int
+
+const (
+	EventDepth EventKind = iota
+	EventTrade
+)
+
+// Event 回放事件
+type Event struct {
+	Time   time.Time
+	Kind   EventKind
+	Symbol string
+	Bids   []gateway.PriceLevel // EventDepth
+	Asks   []gateway.PriceLevel // EventDepth
+	Trade  *gateway.Trade       // EventTrade
+}
+
+// depthLevels 增量深度回放时每个事件携带的档数
+const depthLevels = 20
+
+// recordParser 将录制记录转换为回放事件。
+// 增量深度（@depth@100ms）按交易对维护本地订单簿，以录制的REST快照（@depthSnapshot）锚定；
+// 有限档深度（@depth20等）直接作为快照事件。
+type recordParser struct {
+	books map[string]*orderbook.Book
+}
+
+func newRecordParser() *recordParser {
+	return &recordParser{books: make(map[string]*orderbook.Book)}
+}
+
+func (p *recordParser) book(symbol string) *orderbook.Book {
+	book, ok := p.books[symbol]
+	if !ok {
+		book = orderbook.NewBook(symbol)
+		p.books[symbol] = book
+	}
+	return book
+}
+
+// parse 解析一条记录，非行情消息或订单簿未同步时返回ok=false
+func (p *recordParser) parse(rec recorder.Record) (Event, bool) {
+	var msg gateway.CombinedMessage
+	if err := json.Unmarshal(rec.Raw, &msg); err != nil || msg.Stream == "" {
+		return Event{}, false
+	}
+	ts := time.UnixMilli(rec.RecvTime)
+
+	switch {
+	case strings.HasSuffix(msg.Stream, "@aggTrade"):
+		trade, err := gateway.ParseCombinedAggTrade(rec.Raw)
+		if err != nil || trade.Symbol == "" {
+			return Event{}, false
+		}
+		return Event{Time: ts, Kind: EventTrade, Symbol: trade.Symbol, Trade: trade.ToTrade()}, true
+
+	case strings.HasSuffix(msg.Stream, gateway.DepthSnapshotStreamSuffix):
+		symbol, lastUpdateID, bids, asks, err := gateway.ParseCombinedDepthSnapshot(rec.Raw)
+		if err != nil || symbol == "" {
+			return Event{}, false
+		}
+		book := p.book(symbol)
+		if err := book.ApplySnapshot(orderbook.Snapshot{
+			LastUpdateID: lastUpdateID,
+			Bids:         toBookLevels(bids),
+			Asks:         toBookLevels(asks),
+		}); err != nil {
+			return Event{}, false
+		}
+		return p.bookEvent(ts, book)
+
+	case gateway.IsDiffDepthStream(msg.Stream):
+		diff, err := gateway.ParseCombinedDepthDiff(rec.Raw)
+		if err != nil || diff.Symbol == "" {
+			return Event{}, false
+		}
+		book := p.book(diff.Symbol)
+		if err := book.ApplyDiff(orderbook.Update{
+			Symbol:            diff.Symbol,
+			FirstUpdateID:     diff.FirstUpdateID,
+			FinalUpdateID:     diff.FinalUpdateID,
+			PrevFinalUpdateID: diff.PrevFinalUpdateID,
+			Bids:              toBookLevels(diff.Bids),
+			Asks:              toBookLevels(diff.Asks),
+		}); err != nil {
+			// 未同步或断档：等待下一条录制的快照
+			return Event{}, false
+		}
+		return p.bookEvent(ts, book)
+
+	case strings.Contains(msg.Stream, "@depth"):
+		symbol, bids, asks, err := gateway.ParseCombinedDepthLevels(rec.Raw)
+		if err != nil || symbol == "" || len(bids) == 0 || len(asks) == 0 {
+			return Event{}, false
+		}
+		return Event{Time: ts, Kind: EventDepth, Symbol: symbol, Bids: bids, Asks: asks}, true
+	}
+	return Event{}, false
+}
+
+func (p *recordParser) bookEvent(ts time.Time, book *orderbook.Book) (Event, bool) {
+	bids, asks := book.Levels(depthLevels)
+	if len(bids) == 0 || len(asks) == 0 {
+		return Event{}, false
+	}
+	return Event{
+		Time:   ts,
+		Kind:   EventDepth,
+		Symbol: book.Symbol(),
+		Bids:   fromBookLevels(bids),
+		Asks:   fromBookLevels(asks),
+	}, true
+}
+
+func toBookLevels(levels []gateway.PriceLevel) []orderbook.Level {
+	out := make([]orderbook.Level, len(levels))
+	for i, lv := range levels {
+		out[i] = orderbook.Level{Price: lv.Price, Quantity: lv.Quantity}
+	}
+	return out
+}
+
+func fromBookLevels(levels []orderbook.Level) []gateway.PriceLevel {
+	out := make([]gateway.PriceLevel, len(levels))
+	for i, lv := range levels {
+		out[i] = gateway.PriceLevel{Price: lv.Price, Quantity: lv.Quantity}
+	}
+	return out
+}
+
+// ReadEvents 从NDJSON流读取回放事件
+func ReadEvents(r io.Reader) ([]Event, error) {
+	return readEvents(r, newRecordParser())
+}
+
+func readEvents(r io.Reader, p *recordParser) ([]Event, error) {
+	var events []Event
+	err := recorder.ReadRecords(r, func(rec recorder.Record) error {
+		if ev, ok := p.parse(rec); ok {
+			events = append(events, ev)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// LoadEvents 加载多个录制文件或目录，按接收时间合并排序
+// 支持 .ndjson/.jsonl 明文以及 .zst/.gz 压缩文件（recorder输出格式）
+func LoadEvents(paths ...string) ([]Event, error) {
+	files, err := expandPaths(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一交易对的文件按文件名（日期）顺序读取，共享订单簿状态
+	p := newRecordParser()
+	var all []Event
+	for _, file := range files {
+		events, err := loadFile(file, p)
+		if err != nil {
+			return nil, fmt.Errorf("加载录制文件 %s 失败: %w", file, err)
+		}
+		all = append(all, events...)
+	}
+
+	// 稳定排序：同一时间戳保持文件内顺序，保证回放确定性
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Time.Before(all[j].Time)
+	})
+	return all, nil
+}
+
+func loadFile(path string, p *recordParser) ([]Event, error) {
+	r, err := recorder.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readEvents(r, p)
+}
+
+// expandPaths 展开目录为其中的文件列表（按文件名排序）
+func expandPaths(paths []string) ([]string, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		var dirFiles []string
+		err = filepath.Walk(p, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() {
+				dirFiles = append(dirFiles, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(dirFiles)
+		files = append(files, dirFiles...)
+	}
+	return files, nil
+}
@@ -0,0 +1,159 @@
+package backtest
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	"github.com/newplayman/market-maker-phoenix/internal/risk"
+	"github.com/newplayman/market-maker-phoenix/internal/runner"
+	"github.com/newplayman/market-maker-phoenix/internal/sim"
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+	"github.com/newplayman/market-maker-phoenix/internal/strategy"
+	"github.com/rs/zerolog/log"
+)
+
+// ErrNoEvents 没有可回放的事件
+var ErrNoEvents = errors.New("no events to replay")
+
+// Options 回测参数
+type Options struct {
+	QuoteInterval    time.Duration // 报价间隔，0表示使用配置中的quote_interval_ms
+	MakerFeeRate     float64       // Maker费率（负数表示返佣）
+	InitialBalance   float64       // 初始资金
+	PriceHistorySize int           // 价格历史长度，0表示1800
+}
+
+// Engine 确定性回测引擎：按虚拟时钟回放录制行情并驱动 runner.Runner
+type Engine struct {
+	cfg    *config.Config
+	events []Event
+	opts   Options
+}
+
+// NewEngine 创建回测引擎，events需已按时间排序
+func NewEngine(cfg *config.Config, events []Event, opts Options) *Engine {
+	if opts.QuoteInterval <= 0 {
+		opts.QuoteInterval = cfg.GetQuoteInterval()
+	}
+	if opts.QuoteInterval <= 0 {
+		opts.QuoteInterval = time.Second
+	}
+	if opts.PriceHistorySize <= 0 {
+		opts.PriceHistorySize = 1800
+	}
+	return &Engine{cfg: cfg, events: events, opts: opts}
+}
+
+// Run 回放全部事件并生成报告。相同的配置与数据总是得到相同的结果。
+func (e *Engine) Run(ctx context.Context) (*Report, error) {
+	if len(e.events) == 0 {
+		return nil, ErrNoEvents
+	}
+
+	clock := sim.NewClock(e.events[0].Time)
+
+	// 不落盘快照；必须先注入时钟再初始化交易对，保证撤单计数窗口基于虚拟时间
+	st := store.NewStore("", time.Hour)
+	defer st.Close()
+	st.SetClock(clock.Now)
+
+	symbols := make([]string, 0, len(e.cfg.Symbols))
+	configured := make(map[string]bool, len(e.cfg.Symbols))
+	for _, symCfg := range e.cfg.Symbols {
+		st.InitSymbol(symCfg.Symbol, e.opts.PriceHistorySize)
+		symbols = append(symbols, symCfg.Symbol)
+		configured[symCfg.Symbol] = true
+	}
+
+	exch := sim.NewSimExchange(clock, e.opts.MakerFeeRate, e.opts.InitialBalance)
+	r := runner.NewRunner(e.cfg, st, strategy.New(e.cfg, st), risk.NewRiskManager(e.cfg, st), exch)
+	r.SetClock(clock.Now)
+	if err := r.StartStreams(ctx); err != nil {
+		return nil, err
+	}
+
+	tracker := newInventoryTracker(symbols)
+	interval := e.opts.QuoteInterval
+	nextQuote := e.events[0].Time.Add(interval)
+	cycles := 0
+
+	for _, ev := range e.events {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		// 先执行事件时间之前到期的所有报价周期
+		for !nextQuote.After(ev.Time) {
+			clock.Set(nextQuote)
+			for _, symbol := range symbols {
+				if err := r.ProcessSymbol(ctx, symbol); err != nil {
+					log.Debug().Err(err).Str("symbol", symbol).Msg("回测报价周期失败")
+				}
+			}
+			tracker.sample(exch)
+			cycles++
+			nextQuote = nextQuote.Add(interval)
+		}
+
+		if !configured[ev.Symbol] {
+			continue
+		}
+		clock.Set(ev.Time)
+		switch ev.Kind {
+		case EventDepth:
+			exch.ApplyDepth(ev.Symbol, ev.Bids, ev.Asks)
+		case EventTrade:
+			exch.ApplyTrade(ev.Trade)
+		}
+	}
+	tracker.sample(exch)
+
+	report := buildReport(e.events[0].Time, clock.Now(), len(e.events), cycles, symbols, exch, tracker)
+	return report, nil
+}
+
+// inventoryTracker 在每个报价周期采样仓位与权益
+type inventoryTracker struct {
+	symbols []string
+	samples int
+	maxAbs  map[string]float64
+	sumAbs  map[string]float64
+
+	peakEquity  float64
+	maxDrawdown float64
+	started     bool
+}
+
+func newInventoryTracker(symbols []string) *inventoryTracker {
+	return &inventoryTracker{
+		symbols: symbols,
+		maxAbs:  make(map[string]float64, len(symbols)),
+		sumAbs:  make(map[string]float64, len(symbols)),
+	}
+}
+
+func (t *inventoryTracker) sample(exch *sim.SimExchange) {
+	t.samples++
+	equity := 0.0
+	for _, symbol := range t.symbols {
+		pos, _ := exch.GetPosition(context.Background(), symbol)
+		abs := math.Abs(pos.Size)
+		t.sumAbs[symbol] += abs
+		if abs > t.maxAbs[symbol] {
+			t.maxAbs[symbol] = abs
+		}
+		st := exch.Stats(symbol)
+		equity += st.RealizedPnL - st.Fees + pos.UnrealizedPNL
+	}
+
+	if !t.started || equity > t.peakEquity {
+		t.peakEquity = equity
+		t.started = true
+	}
+	if dd := t.peakEquity - equity; dd > t.maxDrawdown {
+		t.maxDrawdown = dd
+	}
+}
@@ -0,0 +1,105 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/sim"
+)
+
+// SymbolReport 单个交易对的回测结果
+type SymbolReport struct {
+	Symbol           string  `json:"symbol"`
+	Fills            int     `json:"fills"`
+	BuyVolume        float64 `json:"buy_volume"`
+	SellVolume       float64 `json:"sell_volume"`
+	Notional         float64 `json:"notional"`
+	RealizedPnL      float64 `json:"realized_pnl"`
+	UnrealizedPnL    float64 `json:"unrealized_pnl"`
+	Fees             float64 `json:"fees"`
+	NetPnL           float64 `json:"net_pnl"`
+	FinalPosition    float64 `json:"final_position"`
+	MaxAbsPosition   float64 `json:"max_abs_position"`
+	AvgAbsPosition   float64 `json:"avg_abs_position"`
+	OrdersPlaced     int     `json:"orders_placed"`
+	OrdersCanceled   int     `json:"orders_canceled"`
+	OrdersAmended    int     `json:"orders_amended"`
+	PostOnlyRejects  int     `json:"post_only_rejects"`
+	CancelRatePerMin float64 `json:"cancel_rate_per_min"`
+	CancelToFill     float64 `json:"cancel_to_fill"`
+}
+
+// Report 回测报告
+type Report struct {
+	Start       time.Time      `json:"start"`
+	End         time.Time      `json:"end"`
+	Events      int            `json:"events"`
+	QuoteCycles int            `json:"quote_cycles"`
+	Symbols     []SymbolReport `json:"symbols"`
+	NetPnL      float64        `json:"net_pnl"`
+	MaxDrawdown float64        `json:"max_drawdown"`
+}
+
+func buildReport(start, end time.Time, events, cycles int, symbols []string, exch *sim.SimExchange, tracker *inventoryTracker) *Report {
+	report := &Report{
+		Start:       start,
+		End:         end,
+		Events:      events,
+		QuoteCycles: cycles,
+		MaxDrawdown: tracker.maxDrawdown,
+	}
+
+	minutes := end.Sub(start).Minutes()
+	for _, symbol := range symbols {
+		st := exch.Stats(symbol)
+		pos, _ := exch.GetPosition(context.Background(), symbol)
+
+		sr := SymbolReport{
+			Symbol:          symbol,
+			Fills:           st.Fills,
+			BuyVolume:       st.BuyVolume,
+			SellVolume:      st.SellVolume,
+			Notional:        st.Notional,
+			RealizedPnL:     st.RealizedPnL,
+			UnrealizedPnL:   pos.UnrealizedPNL,
+			Fees:            st.Fees,
+			NetPnL:          st.RealizedPnL + pos.UnrealizedPNL - st.Fees,
+			FinalPosition:   pos.Size,
+			MaxAbsPosition:  tracker.maxAbs[symbol],
+			OrdersPlaced:    st.Placed,
+			OrdersCanceled:  st.Canceled,
+			OrdersAmended:   st.Amended,
+			PostOnlyRejects: st.Rejected,
+		}
+		if tracker.samples > 0 {
+			sr.AvgAbsPosition = tracker.sumAbs[symbol] / float64(tracker.samples)
+		}
+		if minutes > 0 {
+			sr.CancelRatePerMin = float64(st.Canceled) / minutes
+		}
+		if st.Fills > 0 {
+			sr.CancelToFill = float64(st.Canceled) / float64(st.Fills)
+		}
+
+		report.Symbols = append(report.Symbols, sr)
+		report.NetPnL += sr.NetPnL
+	}
+	return report
+}
+
+// WriteText 以文本表格输出报告
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "回测区间: %s ~ %s (%s)\n", r.Start.UTC().Format(time.RFC3339), r.End.UTC().Format(time.RFC3339), r.End.Sub(r.Start))
+	fmt.Fprintf(w, "事件数: %d  报价周期: %d\n", r.Events, r.QuoteCycles)
+	for _, s := range r.Symbols {
+		fmt.Fprintf(w, "\n[%s]\n", s.Symbol)
+		fmt.Fprintf(w, "  成交: %d笔  买量: %.4f  卖量: %.4f  成交额: %.2f\n", s.Fills, s.BuyVolume, s.SellVolume, s.Notional)
+		fmt.Fprintf(w, "  已实现: %.4f  未实现: %.4f  手续费: %.4f  净盈亏: %.4f\n", s.RealizedPnL, s.UnrealizedPnL, s.Fees, s.NetPnL)
+		fmt.Fprintf(w, "  期末仓位: %.4f  最大|仓位|: %.4f  平均|仓位|: %.4f\n", s.FinalPosition, s.MaxAbsPosition, s.AvgAbsPosition)
+		fmt.Fprintf(w, "  下单: %d  撤单: %d  改单: %d  post-only拒单: %d  撤单率: %.2f/分钟  撤单/成交: %.2f\n",
+			s.OrdersPlaced, s.OrdersCanceled, s.OrdersAmended, s.PostOnlyRejects, s.CancelRatePerMin, s.CancelToFill)
+	}
+	fmt.Fprintf(w, "\n总净盈亏: %.4f  最大回撤: %.4f\n", r.NetPnL, r.MaxDrawdown)
+}
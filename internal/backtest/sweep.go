@@ -0,0 +1,165 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+)
+
+// ParamGrid 参数网格：键为SymbolConfig的mapstructure字段名（如grid_start_offset），
+// 或以"SYMBOL."为前缀只作用于单个交易对（如ETHUSDC.inventory_skew_coeff）
+type ParamGrid map[string][]float64
+
+// ParamSet 一组具体参数取值
+type ParamSet map[string]float64
+
+// String 按键排序输出，便于报告比对
+func (p ParamSet) String() string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%g", k, p[k]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Expand 展开为笛卡尔积，按键名排序保证顺序确定
+func (g ParamGrid) Expand() []ParamSet {
+	keys := make([]string, 0, len(g))
+	for k, values := range g {
+		if len(values) > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	sets := []ParamSet{{}}
+	for _, k := range keys {
+		next := make([]ParamSet, 0, len(sets)*len(g[k]))
+		for _, base := range sets {
+			for _, v := range g[k] {
+				ps := make(ParamSet, len(base)+1)
+				for bk, bv := range base {
+					ps[bk] = bv
+				}
+				ps[k] = v
+				next = append(next, ps)
+			}
+		}
+		sets = next
+	}
+	return sets
+}
+
+// ApplyParams 复制配置并写入参数，原配置不受影响
+func ApplyParams(cfg *config.Config, params ParamSet) (*config.Config, error) {
+	cp := *cfg
+	cp.Symbols = append([]config.SymbolConfig(nil), cfg.Symbols...)
+
+	for key, value := range params {
+		symbol, field := "", key
+		if idx := strings.LastIndex(key, "."); idx >= 0 {
+			symbol, field = key[:idx], key[idx+1:]
+		}
+
+		applied := false
+		for i := range cp.Symbols {
+			if symbol != "" && cp.Symbols[i].Symbol != symbol {
+				continue
+			}
+			if err := setField(&cp.Symbols[i], field, value); err != nil {
+				return nil, err
+			}
+			applied = true
+		}
+		if !applied {
+			return nil, fmt.Errorf("参数 %s 未匹配任何交易对", key)
+		}
+	}
+	return &cp, nil
+}
+
+// setField 通过mapstructure标签设置数值字段
+func setField(symCfg *config.SymbolConfig, tag string, value float64) error {
+	v := reflect.ValueOf(symCfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("mapstructure") != tag {
+			continue
+		}
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Float64:
+			f.SetFloat(value)
+		case reflect.Int:
+			f.SetInt(int64(math.Round(value)))
+		case reflect.Bool:
+			f.SetBool(value != 0)
+		default:
+			return fmt.Errorf("参数 %s 类型不支持扫描", tag)
+		}
+		return nil
+	}
+	return fmt.Errorf("未知参数 %s", tag)
+}
+
+// SweepResult 单组参数的回测结果
+type SweepResult struct {
+	Params ParamSet `json:"params"`
+	Report *Report  `json:"report,omitempty"`
+	Err    string   `json:"error,omitempty"`
+}
+
+// Sweep 并行回测参数网格中的每组参数，结果顺序与Expand一致
+func Sweep(ctx context.Context, cfg *config.Config, events []Event, opts Options, grid ParamGrid, parallel int) []SweepResult {
+	sets := grid.Expand()
+	results := make([]SweepResult, len(sets))
+	if parallel <= 0 {
+		parallel = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = runOne(ctx, cfg, events, opts, sets[i])
+			}
+		}()
+	}
+	for i := range sets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+func runOne(ctx context.Context, cfg *config.Config, events []Event, opts Options, params ParamSet) SweepResult {
+	result := SweepResult{Params: params}
+	runCfg, err := ApplyParams(cfg, params)
+	if err != nil {
+		result.Err = err.Error()
+		return result
+	}
+	report, err := NewEngine(runCfg, events, opts).Run(ctx)
+	if err != nil {
+		result.Err = err.Error()
+		return result
+	}
+	result.Report = report
+	return result
+}
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -13,24 +15,182 @@ import (
 type Config struct {
 	Global  GlobalConfig   `mapstructure:"global"`
 	Symbols []SymbolConfig `mapstructure:"symbols"`
+	Hedges  []HedgeConfig  `mapstructure:"hedges"` // 对冲腿：在相关品种或其他账户上对冲做市库存
 }
 
 // GlobalConfig 全局配置
 type GlobalConfig struct {
-	TotalNotionalMax float64 `mapstructure:"total_notional_max"` // 总名义价值上限 ($)
-	QuoteIntervalMs  int     `mapstructure:"quote_interval_ms"`  // 报价间隔 (ms)
-	APIKey           string  `mapstructure:"api_key"`            // Binance API Key
-	APISecret        string  `mapstructure:"api_secret"`         // Binance API Secret
-	TestNet          bool    `mapstructure:"testnet"`            // 是否使用测试网
-	LogLevel         string  `mapstructure:"log_level"`          // 日志级别
-	MetricsPort      int     `mapstructure:"metrics_port"`       // Prometheus 端口
-	SnapshotPath     string  `mapstructure:"snapshot_path"`      // 快照文件路径
-	SnapshotInterval int     `mapstructure:"snapshot_interval"`  // 快照保存间隔 (秒)
+	TotalNotionalMax  float64 `mapstructure:"total_notional_max"`  // 总名义价值上限 ($)
+	QuoteIntervalMs   int     `mapstructure:"quote_interval_ms"`   // 报价间隔 (ms)
+	APIKey            string  `mapstructure:"api_key"`             // Binance API Key
+	APISecret         string  `mapstructure:"api_secret"`          // Binance API Secret
+	TestNet           bool    `mapstructure:"testnet"`             // 是否使用测试网
+	Exchange          string  `mapstructure:"exchange"`            // 交易所: binance(默认) | bybit（linear永续）
+	OrderChannel      string  `mapstructure:"order_channel"`       // 下单通道: rest(默认) | ws | ws_with_rest_fallback
+	LogLevel          string  `mapstructure:"log_level"`           // 日志级别
+	MetricsPort       int     `mapstructure:"metrics_port"`        // Prometheus 端口
+	JournalDir        string  `mapstructure:"journal_dir"`         // 事件日志目录（为空则仅内存状态）
+	JournalSyncMs     int     `mapstructure:"journal_sync_ms"`     // 事件日志批量fsync间隔 (ms)
+	SnapshotInterval  int     `mapstructure:"snapshot_interval"`   // 检查点压缩间隔 (秒)
+	FilterRefreshSec  int     `mapstructure:"filter_refresh_sec"`  // 交易规则(exchangeInfo)刷新间隔 (秒，默认3600)
+	StaleReconnectSec int     `mapstructure:"stale_reconnect_sec"` // 行情超过该时长未更新时强制重连 (秒，默认10)
+
+	RequoteMode          string  `mapstructure:"requote_mode"`            // 报价调度: event(默认，事件驱动) | ticker(按quote_interval_ms固定间隔)
+	RequoteMinIntervalMs int     `mapstructure:"requote_min_interval_ms"` // 事件驱动时两次报价的最小间隔 (ms，默认100)
+	RequoteHeartbeatMs   int     `mapstructure:"requote_heartbeat_ms"`    // 无事件时的最长报价间隔 (ms，默认5000)
+	RequoteMidMoveBps    float64 `mapstructure:"requote_mid_move_bps"`    // 中间价相对上次报价移动超过该值时重新报价 (bp，默认1)
+
+	RecordDir         string `mapstructure:"record_dir"`         // 原始WS消息录制目录（为空则不录制）
+	RecordCompression string `mapstructure:"record_compression"` // 录制压缩格式: zstd(默认) | gzip | none
+
+	Mode          string  `mapstructure:"mode"`            // 运行模式: live(默认) | paper(模拟盘，真实行情+内存撮合)
+	PaperBalance  float64 `mapstructure:"paper_balance"`   // 模拟盘初始资金 (USDT)
+	PaperMakerFee float64 `mapstructure:"paper_maker_fee"` // 模拟盘Maker手续费率
+
+	VPIN VPINConfig `mapstructure:"vpin"` // VPIN订单流毒性参数（仅作用于ASMM策略）
+
+	Portfolio PortfolioConfig `mapstructure:"portfolio"` // 组合风险（相关性、VaR/ES）参数
+}
+
+// PortfolioConfig 组合风险参数：按中间价收益的滚动协方差估计组合VaR/ES，
+// 超过限额一定比例后收缩、超过限额时禁止增加组合风险一侧的报价
+type PortfolioConfig struct {
+	Enabled          bool    `mapstructure:"enabled"`            // 是否启用
+	SampleIntervalMs int     `mapstructure:"sample_interval_ms"` // 中间价采样间隔 (ms，默认1000)
+	Window           int     `mapstructure:"window"`             // 滚动窗口样本数 (默认300)
+	MinSamples       int     `mapstructure:"min_samples"`        // 开始限制所需的最少样本数 (默认30)
+	Confidence       float64 `mapstructure:"confidence"`         // VaR/ES置信度 (默认0.99)
+	HorizonSec       float64 `mapstructure:"horizon_sec"`        // VaR/ES持有期 (秒，默认60)
+	VaRLimit         float64 `mapstructure:"var_limit"`          // 组合VaR上限 ($，0为不限制)
+	ESLimit          float64 `mapstructure:"es_limit"`           // 组合ES上限 ($，0为不限制)
+	ThrottleRatio    float64 `mapstructure:"throttle_ratio"`     // 风险达到限额的该比例后开始收缩加风险一侧 (默认0.8)
+	BetaSymbol       string  `mapstructure:"beta_symbol"`        // beta基准交易对 (默认第一个交易对)
+}
+
+// GetSampleInterval 获取中间价采样间隔
+func (p *PortfolioConfig) GetSampleInterval() time.Duration {
+	if p.SampleIntervalMs <= 0 {
+		return time.Second
+	}
+	return time.Duration(p.SampleIntervalMs) * time.Millisecond
+}
+
+// GetWindow 获取滚动窗口样本数
+func (p *PortfolioConfig) GetWindow() int {
+	if p.Window <= 0 {
+		return 300
+	}
+	return p.Window
+}
+
+// GetMinSamples 获取开始限制所需的最少样本数
+func (p *PortfolioConfig) GetMinSamples() int {
+	if p.MinSamples <= 0 {
+		return 30
+	}
+	return p.MinSamples
+}
+
+// GetConfidence 获取VaR/ES置信度
+func (p *PortfolioConfig) GetConfidence() float64 {
+	if p.Confidence <= 0 {
+		return 0.99
+	}
+	return p.Confidence
+}
+
+// GetHorizon 获取VaR/ES持有期
+func (p *PortfolioConfig) GetHorizon() time.Duration {
+	if p.HorizonSec <= 0 {
+		return time.Minute
+	}
+	return time.Duration(p.HorizonSec * float64(time.Second))
+}
+
+// GetThrottleRatio 获取开始收缩报价的限额比例
+func (p *PortfolioConfig) GetThrottleRatio() float64 {
+	if p.ThrottleRatio <= 0 {
+		return 0.8
+	}
+	return p.ThrottleRatio
+}
+
+// VPINConfig VPIN（成交量同步知情交易概率）参数
+type VPINConfig struct {
+	Enabled         bool    `mapstructure:"enabled"`          // 是否启用
+	BucketVolume    float64 `mapstructure:"bucket_volume"`    // 每个成交量桶的大小（手数），可被交易对vpin_bucket_volume覆盖
+	NumBuckets      int     `mapstructure:"num_buckets"`      // 滚动窗口桶数 (默认50)
+	MinBuckets      int     `mapstructure:"min_buckets"`      // 计算VPIN所需的最少完整桶数 (默认5)
+	WidenThreshold  float64 `mapstructure:"widen_threshold"`  // VPIN超过该值时放大价差 (默认0.7)
+	WidenMultiplier float64 `mapstructure:"widen_multiplier"` // 价差放大系数：spread *= 1 + vpin*multiplier (默认0.2)
+	PauseThreshold  float64 `mapstructure:"pause_threshold"`  // VPIN超过该值时暂停报价 (默认0.9)
+	PauseSeconds    float64 `mapstructure:"pause_seconds"`    // 暂停报价时长 (秒，默认5)
+}
+
+// HedgeConfig 对冲腿配置：目标对冲仓位 = -ratio × 做市仓位
+type HedgeConfig struct {
+	Symbol            string  `mapstructure:"symbol"`              // 被对冲的做市交易对 (e.g., ETHUSDC)
+	HedgeSymbol       string  `mapstructure:"hedge_symbol"`        // 对冲品种 (e.g., ETHUSDT)
+	Ratio             float64 `mapstructure:"ratio"`               // 对冲比例 (默认1)
+	Threshold         float64 `mapstructure:"threshold"`           // 对冲偏差超过该数量才下单 (手数，默认0即任意偏差)
+	MaxPosition       float64 `mapstructure:"max_position"`        // 对冲仓位上限 (手数，0为不限制)
+	Execution         string  `mapstructure:"execution"`           // 执行方式: taker(默认，IOC) | passive(挂最优价，超时转taker)
+	MaxSlippageBps    float64 `mapstructure:"max_slippage_bps"`    // taker相对中间价的最大滑点 (bp，默认5)
+	PassiveTimeoutSec float64 `mapstructure:"passive_timeout_sec"` // passive挂单超过该时长未完成时转taker (秒，默认30，负数为不转)
+	IntervalMs        int     `mapstructure:"interval_ms"`         // 对冲检查间隔 (ms，默认1000)
+
+	// 可选：在其他账户对冲（为空则使用global账户）
+	Exchange  string `mapstructure:"exchange"`   // binance | bybit，为空时与global.exchange相同
+	APIKey    string `mapstructure:"api_key"`    // 对冲账户API Key
+	APISecret string `mapstructure:"api_secret"` // 对冲账户API Secret
+}
+
+// 对冲执行方式
+const (
+	HedgeTaker   = "taker"
+	HedgePassive = "passive"
+)
+
+// SeparateAccount 是否在独立账户对冲
+func (h *HedgeConfig) SeparateAccount() bool {
+	return h.APIKey != ""
+}
+
+// 运行模式
+const (
+	ModeLive  = "live"
+	ModePaper = "paper"
+)
+
+// 报价调度模式
+const (
+	RequoteEvent  = "event"
+	RequoteTicker = "ticker"
+)
+
+// 交易所
+const (
+	ExchangeBinance = "binance"
+	ExchangeBybit   = "bybit"
+)
+
+// Venue 返回交易所名称，未配置时为binance
+func (g *GlobalConfig) Venue() string {
+	if g.Exchange == "" {
+		return ExchangeBinance
+	}
+	return g.Exchange
+}
+
+// IsPaper 是否为模拟盘模式
+func (g *GlobalConfig) IsPaper() bool {
+	return g.Mode == ModePaper
 }
 
 // SymbolConfig 单个交易对配置
 type SymbolConfig struct {
 	Symbol           string  `mapstructure:"symbol"`             // 交易对符号 (e.g., ETHUSDC)
+	VenueSymbol      string  `mapstructure:"venue_symbol"`       // 交易所侧交易对（为空则与symbol相同，目前仅bybit使用）
 	NetMax           float64 `mapstructure:"net_max"`            // 最大净仓位 (手数)
 	MinSpread        float64 `mapstructure:"min_spread"`         // 最小价差 (比例)
 	TickSize         float64 `mapstructure:"tick_size"`          // 价格最小变动单位
@@ -66,11 +226,34 @@ type SymbolConfig struct {
 
 	// 库存偏移系数 - 增强成交后逼近盘口的效果
 	InventorySkewCoeff float64 `mapstructure:"inventory_skew_coeff"` // 库存偏移系数 (默认0.002)
+
+	// 报价策略选择，便于在不同交易对上A/B对比
+	Strategy string `mapstructure:"strategy"` // asmm(默认) | avellaneda_stoikov
+
+	// Avellaneda-Stoikov 参数（strategy=avellaneda_stoikov 时生效）
+	ASGamma         float64 `mapstructure:"as_gamma"`          // 风险厌恶系数γ
+	ASKappa         float64 `mapstructure:"as_kappa"`          // 订单到达强度κ初始值（1/USDT），在线估计样本不足时使用
+	ASHorizonSec    float64 `mapstructure:"as_horizon_sec"`    // 时间窗口T（秒）
+	ASFiniteHorizon bool    `mapstructure:"as_finite_horizon"` // true: 使用剩余时间T-t（按窗口滚动）；false: 恒定使用T
+
+	VPINBucketVolume float64 `mapstructure:"vpin_bucket_volume"` // VPIN成交量桶大小（手数），为0时使用global.vpin.bucket_volume
+
+	// 队列位置保留：防闪烁容差按挂单排队靠前程度放大为 容差 × (1 + 系数 × 靠前程度)，队首订单更不容易被撤单重挂
+	QueueRetentionBoost float64 `mapstructure:"queue_retention_boost"` // 0(默认)为不按队列位置调整，最大5
 }
 
+// 报价策略
+const (
+	StrategyASMM              = "asmm"
+	StrategyAvellanedaStoikov = "avellaneda_stoikov"
+)
+
 var (
 	globalConfig *Config
 	configPath   string
+
+	reloadMu        sync.Mutex
+	reloadListeners []func(*Config)
 )
 
 // LoadConfig 加载配置文件
@@ -87,12 +270,15 @@ func LoadConfig(path string) (*Config, error) {
 	viper.BindEnv("global.api_secret", "BINANCE_API_SECRET")
 	viper.BindEnv("global.testnet", "BINANCE_TESTNET")
 	viper.BindEnv("global.metrics_port", "PHOENIX_METRICS_PORT")
-	viper.BindEnv("global.snapshot_path", "PHOENIX_SNAPSHOT_PATH")
+	viper.BindEnv("global.journal_dir", "PHOENIX_JOURNAL_DIR")
 	viper.BindEnv("global.snapshot_interval", "PHOENIX_SNAPSHOT_INTERVAL")
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("读取配置文件失败: %w", err)
 	}
+	if err := checkRemovedKeys(viper.GetViper()); err != nil {
+		return nil, err
+	}
 
 	var cfg Config
 	if err := viper.Unmarshal(&cfg); err != nil {
@@ -113,6 +299,53 @@ func LoadConfig(path string) (*Config, error) {
 	return &cfg, nil
 }
 
+// ReadConfig 读取并校验配置文件，但不启动热重载、不要求API密钥
+// 供回测等离线工具使用，避免污染全局viper实例
+func ReadConfig(path string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	v.SetConfigType("yaml")
+
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %w", err)
+	}
+	if err := checkRemovedKeys(v); err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, fmt.Errorf("解析配置失败: %w", err)
+	}
+
+	if err := validate(&cfg, false); err != nil {
+		return nil, fmt.Errorf("配置验证失败: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// removedKeys 已移除的配置项及其替代项。旧配置仍设置这些项时拒绝启动，
+// 避免如 snapshot_path 被静默忽略、实盘状态只保存在内存中
+var removedKeys = []struct {
+	key, env, replacement string
+}{
+	{"global.snapshot_path", "PHOENIX_SNAPSHOT_PATH", "global.journal_dir (PHOENIX_JOURNAL_DIR)"},
+}
+
+// checkRemovedKeys 检查配置文件与环境变量中是否仍使用已移除的配置项
+func checkRemovedKeys(v *viper.Viper) error {
+	for _, k := range removedKeys {
+		if v.InConfig(k.key) {
+			return fmt.Errorf("配置项 %s 已移除，请改用 %s", k.key, k.replacement)
+		}
+		if _, ok := os.LookupEnv(k.env); ok {
+			return fmt.Errorf("环境变量 %s 已移除，请改用 %s", k.env, k.replacement)
+		}
+	}
+	return nil
+}
+
 // GetConfig 获取全局配置
 func GetConfig() *Config {
 	return globalConfig
@@ -120,6 +353,12 @@ func GetConfig() *Config {
 
 // validateConfig 验证配置有效性
 func validateConfig(cfg *Config) error {
+	// 模拟盘不下真实订单，无需API密钥
+	return validate(cfg, !cfg.Global.IsPaper())
+}
+
+// validate 验证配置有效性，requireCredentials为false时跳过API密钥检查
+func validate(cfg *Config, requireCredentials bool) error {
 	// 全局配置验证
 	if cfg.Global.TotalNotionalMax <= 0 {
 		return fmt.Errorf("total_notional_max 必须 > 0")
@@ -127,9 +366,62 @@ func validateConfig(cfg *Config) error {
 	if cfg.Global.QuoteIntervalMs < 100 || cfg.Global.QuoteIntervalMs > 5000 {
 		return fmt.Errorf("quote_interval_ms 必须在 100-5000 之间")
 	}
-	if cfg.Global.APIKey == "" || cfg.Global.APISecret == "" {
+	if requireCredentials && (cfg.Global.APIKey == "" || cfg.Global.APISecret == "") {
 		return fmt.Errorf("API Key 和 Secret 不能为空")
 	}
+	switch cfg.Global.RecordCompression {
+	case "", "zstd", "gzip", "none":
+	default:
+		return fmt.Errorf("record_compression 必须为 zstd、gzip 或 none")
+	}
+	switch cfg.Global.OrderChannel {
+	case "", "rest", "ws", "ws_with_rest_fallback":
+	default:
+		return fmt.Errorf("order_channel 必须为 rest、ws 或 ws_with_rest_fallback")
+	}
+	switch cfg.Global.Exchange {
+	case "", ExchangeBinance:
+	case ExchangeBybit:
+		if cfg.Global.OrderChannel != "" && cfg.Global.OrderChannel != "rest" {
+			return fmt.Errorf("bybit 仅支持 order_channel=rest")
+		}
+	default:
+		return fmt.Errorf("exchange 必须为 binance 或 bybit")
+	}
+	switch cfg.Global.Mode {
+	case "", ModeLive, ModePaper:
+	default:
+		return fmt.Errorf("mode 必须为 live 或 paper")
+	}
+	if cfg.Global.PaperBalance < 0 || cfg.Global.PaperMakerFee < 0 {
+		return fmt.Errorf("paper_balance 和 paper_maker_fee 不能为负")
+	}
+	if cfg.Global.JournalSyncMs < 0 {
+		return fmt.Errorf("journal_sync_ms 不能为负")
+	}
+	if cfg.Global.FilterRefreshSec < 0 {
+		return fmt.Errorf("filter_refresh_sec 不能为负")
+	}
+	if cfg.Global.StaleReconnectSec < 0 {
+		return fmt.Errorf("stale_reconnect_sec 不能为负")
+	}
+	switch cfg.Global.RequoteMode {
+	case "", RequoteEvent, RequoteTicker:
+	default:
+		return fmt.Errorf("requote_mode 必须为 event 或 ticker")
+	}
+	if cfg.Global.RequoteMinIntervalMs < 0 || cfg.Global.RequoteHeartbeatMs < 0 || cfg.Global.RequoteMidMoveBps < 0 {
+		return fmt.Errorf("requote_min_interval_ms、requote_heartbeat_ms 和 requote_mid_move_bps 不能为负")
+	}
+	if cfg.GetRequoteHeartbeat() < cfg.GetRequoteMinInterval() {
+		return fmt.Errorf("requote_heartbeat_ms 不能小于 requote_min_interval_ms")
+	}
+	if err := validateVPIN(cfg); err != nil {
+		return err
+	}
+	if err := validatePortfolio(cfg); err != nil {
+		return err
+	}
 
 	// 交易对配置验证
 	if len(cfg.Symbols) == 0 {
@@ -199,6 +491,22 @@ func validateConfig(cfg *Config) error {
 			}
 		}
 
+		switch sym.Strategy {
+		case "", StrategyASMM:
+		case StrategyAvellanedaStoikov:
+			if sym.ASGamma <= 0 {
+				return fmt.Errorf("symbols[%d]: as_gamma 必须 > 0", i)
+			}
+			if sym.ASKappa <= 0 {
+				return fmt.Errorf("symbols[%d]: as_kappa 必须 > 0", i)
+			}
+			if sym.ASHorizonSec <= 0 {
+				return fmt.Errorf("symbols[%d]: as_horizon_sec 必须 > 0", i)
+			}
+		default:
+			return fmt.Errorf("symbols[%d]: strategy 必须为 asmm 或 avellaneda_stoikov", i)
+		}
+
 		if sym.MaxCancelPerMin <= 0 || sym.MaxCancelPerMin > 300 {
 			return fmt.Errorf("symbols[%d]: max_cancel_per_min 必须在 (0, 300] 之间", i)
 		}
@@ -223,12 +531,15 @@ func validateConfig(cfg *Config) error {
 		if sym.StopLossThresh > 0 && (sym.StopLossThresh < 0.05 || sym.StopLossThresh > 0.5) {
 			return fmt.Errorf("symbols[%d]: stop_loss_thresh 必须在 [0.05, 0.5] 之间", i)
 		}
+		if sym.QueueRetentionBoost < 0 || sym.QueueRetentionBoost > 5 {
+			return fmt.Errorf("symbols[%d]: queue_retention_boost 必须在 [0, 5] 之间", i)
+		}
 
 		// 回写到配置中（确保兼容性转换生效）
 		cfg.Symbols[i] = *sym
 	}
 
-	return nil
+	return validateHedges(cfg)
 }
 
 // watchConfig 监听配置文件变化并热重载
@@ -250,14 +561,73 @@ func watchConfig() {
 
 		globalConfig = &newCfg
 		log.Info().Msg("配置热重载成功")
+
+		reloadMu.Lock()
+		listeners := append([]func(*Config){}, reloadListeners...)
+		reloadMu.Unlock()
+		for _, fn := range listeners {
+			fn(&newCfg)
+		}
 	})
 }
 
+// OnReload 注册配置热重载成功后的回调
+func OnReload(fn func(*Config)) {
+	reloadMu.Lock()
+	reloadListeners = append(reloadListeners, fn)
+	reloadMu.Unlock()
+}
+
 // GetQuoteInterval 获取报价间隔
 func (c *Config) GetQuoteInterval() time.Duration {
 	return time.Duration(c.Global.QuoteIntervalMs) * time.Millisecond
 }
 
+// IsEventRequote 是否使用事件驱动报价调度
+func (c *Config) IsEventRequote() bool {
+	return c.Global.RequoteMode != RequoteTicker
+}
+
+// GetRequoteMinInterval 获取事件驱动报价的最小间隔
+func (c *Config) GetRequoteMinInterval() time.Duration {
+	if c.Global.RequoteMinIntervalMs <= 0 {
+		return 100 * time.Millisecond
+	}
+	return time.Duration(c.Global.RequoteMinIntervalMs) * time.Millisecond
+}
+
+// GetRequoteHeartbeat 获取无事件时的最长报价间隔
+func (c *Config) GetRequoteHeartbeat() time.Duration {
+	if c.Global.RequoteHeartbeatMs <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.Global.RequoteHeartbeatMs) * time.Millisecond
+}
+
+// GetRequoteMidMoveBps 获取触发重新报价的中间价移动阈值 (bp)
+func (c *Config) GetRequoteMidMoveBps() float64 {
+	if c.Global.RequoteMidMoveBps <= 0 {
+		return 1
+	}
+	return c.Global.RequoteMidMoveBps
+}
+
+// GetFilterRefreshInterval 获取交易规则刷新间隔
+func (c *Config) GetFilterRefreshInterval() time.Duration {
+	if c.Global.FilterRefreshSec <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.Global.FilterRefreshSec) * time.Second
+}
+
+// GetStaleReconnectThreshold 获取行情过期强制重连阈值
+func (c *Config) GetStaleReconnectThreshold() time.Duration {
+	if c.Global.StaleReconnectSec <= 0 {
+		return 10 * time.Second
+	}
+	return time.Duration(c.Global.StaleReconnectSec) * time.Second
+}
+
 // GetSymbolConfig 根据交易对符号获取配置
 func (c *Config) GetSymbolConfig(symbol string) *SymbolConfig {
 	for i := range c.Symbols {
@@ -276,3 +646,137 @@ func (c *Config) GetAllSymbols() []string {
 	}
 	return symbols
 }
+
+// validateVPIN 校验VPIN配置并填充默认值（仅在启用时）
+func validateVPIN(cfg *Config) error {
+	v := &cfg.Global.VPIN
+	if !v.Enabled {
+		return nil
+	}
+	if v.NumBuckets == 0 {
+		v.NumBuckets = 50
+	}
+	if v.MinBuckets == 0 {
+		v.MinBuckets = 5
+	}
+	if v.WidenThreshold == 0 {
+		v.WidenThreshold = 0.7
+	}
+	if v.WidenMultiplier == 0 {
+		v.WidenMultiplier = 0.2
+	}
+	if v.PauseThreshold == 0 {
+		v.PauseThreshold = 0.9
+	}
+	if v.PauseSeconds == 0 {
+		v.PauseSeconds = 5
+	}
+
+	if v.BucketVolume < 0 {
+		return fmt.Errorf("vpin.bucket_volume 不能为负")
+	}
+	if v.NumBuckets < 1 || v.MinBuckets < 1 || v.MinBuckets > v.NumBuckets {
+		return fmt.Errorf("vpin.min_buckets 必须在 1 到 num_buckets 之间")
+	}
+	if v.WidenThreshold <= 0 || v.WidenThreshold > 1 || v.PauseThreshold <= 0 || v.PauseThreshold > 1 {
+		return fmt.Errorf("vpin.widen_threshold 和 vpin.pause_threshold 必须在 (0, 1] 之间")
+	}
+	if v.WidenThreshold > v.PauseThreshold {
+		return fmt.Errorf("vpin.widen_threshold 必须 <= vpin.pause_threshold")
+	}
+	if v.WidenMultiplier < 0 || v.PauseSeconds < 0 {
+		return fmt.Errorf("vpin.widen_multiplier 和 vpin.pause_seconds 不能为负")
+	}
+	for i, sym := range cfg.Symbols {
+		if sym.VPINBucketVolume < 0 {
+			return fmt.Errorf("symbols[%d]: vpin_bucket_volume 不能为负", i)
+		}
+		if sym.VPINBucketVolume == 0 && v.BucketVolume == 0 {
+			return fmt.Errorf("symbols[%d]: 启用VPIN时 vpin_bucket_volume 或 vpin.bucket_volume 必须 > 0", i)
+		}
+	}
+	return nil
+}
+
+// validateHedges 校验对冲腿配置并填充默认值
+func validateHedges(cfg *Config) error {
+	seen := make(map[string]bool)
+	for i := range cfg.Hedges {
+		h := &cfg.Hedges[i]
+		if h.Symbol == "" || h.HedgeSymbol == "" {
+			return fmt.Errorf("hedges[%d]: symbol 和 hedge_symbol 不能为空", i)
+		}
+		if cfg.GetSymbolConfig(h.Symbol) == nil {
+			return fmt.Errorf("hedges[%d]: symbol %s 不是做市交易对", i, h.Symbol)
+		}
+		if seen[h.Symbol] {
+			return fmt.Errorf("hedges[%d]: symbol %s 重复配置对冲", i, h.Symbol)
+		}
+		seen[h.Symbol] = true
+		// 同一账户上对冲腿的仓位与订单推送会与做市交易对混在一起
+		if !h.SeparateAccount() && cfg.GetSymbolConfig(h.HedgeSymbol) != nil {
+			return fmt.Errorf("hedges[%d]: hedge_symbol %s 是做市交易对，需使用独立账户", i, h.HedgeSymbol)
+		}
+		if h.SeparateAccount() && h.APISecret == "" {
+			return fmt.Errorf("hedges[%d]: 配置 api_key 时 api_secret 不能为空", i)
+		}
+		switch h.Exchange {
+		case "", ExchangeBinance, ExchangeBybit:
+		default:
+			return fmt.Errorf("hedges[%d]: exchange 必须为 binance 或 bybit", i)
+		}
+		if h.Exchange != "" && h.Exchange != cfg.Global.Venue() && !h.SeparateAccount() {
+			return fmt.Errorf("hedges[%d]: 在其他交易所对冲需配置 api_key", i)
+		}
+		switch h.Execution {
+		case "":
+			h.Execution = HedgeTaker
+		case HedgeTaker, HedgePassive:
+		default:
+			return fmt.Errorf("hedges[%d]: execution 必须为 taker 或 passive", i)
+		}
+		if h.Ratio == 0 {
+			h.Ratio = 1
+		}
+		if h.MaxSlippageBps == 0 {
+			h.MaxSlippageBps = 5
+		}
+		if h.PassiveTimeoutSec == 0 {
+			h.PassiveTimeoutSec = 30
+		}
+		if h.IntervalMs == 0 {
+			h.IntervalMs = 1000
+		}
+		if h.Ratio < 0 || h.Threshold < 0 || h.MaxPosition < 0 || h.MaxSlippageBps < 0 || h.IntervalMs < 0 {
+			return fmt.Errorf("hedges[%d]: ratio、threshold、max_position、max_slippage_bps 和 interval_ms 不能为负", i)
+		}
+	}
+	return nil
+}
+
+// validatePortfolio 校验组合风险配置（仅在启用时）
+func validatePortfolio(cfg *Config) error {
+	p := &cfg.Global.Portfolio
+	if !p.Enabled {
+		return nil
+	}
+	if p.VaRLimit < 0 || p.ESLimit < 0 || (p.VaRLimit == 0 && p.ESLimit == 0) {
+		return fmt.Errorf("portfolio.var_limit 与 portfolio.es_limit 不能为负，且至少配置一个")
+	}
+	if p.SampleIntervalMs < 0 || p.Window < 0 || p.MinSamples < 0 || p.HorizonSec < 0 {
+		return fmt.Errorf("portfolio.sample_interval_ms、window、min_samples 和 horizon_sec 不能为负")
+	}
+	if p.GetMinSamples() < 2 || p.GetMinSamples() > p.GetWindow() {
+		return fmt.Errorf("portfolio.min_samples 必须在 2 到 window 之间")
+	}
+	if c := p.GetConfidence(); c < 0.5 || c >= 1 {
+		return fmt.Errorf("portfolio.confidence 必须在 [0.5, 1) 之间")
+	}
+	if t := p.GetThrottleRatio(); t >= 1 {
+		return fmt.Errorf("portfolio.throttle_ratio 必须在 (0, 1) 之间")
+	}
+	if p.BetaSymbol != "" && cfg.GetSymbolConfig(p.BetaSymbol) == nil {
+		return fmt.Errorf("portfolio.beta_symbol %s 不是做市交易对", p.BetaSymbol)
+	}
+	return nil
+}
@@ -2,7 +2,10 @@ package config
 
 import (
 	"os"
+	"strings"
 	"testing"
+
+	"github.com/spf13/viper"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -136,3 +139,123 @@ func TestConfigDefaults(t *testing.T) {
 		t.Errorf("Expected default StopLossThresh 0.02, got %.4f", sym.StopLossThresh)
 	}
 }
+
+func TestValidateHedges(t *testing.T) {
+	base := func(h HedgeConfig) *Config {
+		return &Config{
+			Symbols: []SymbolConfig{{Symbol: "ETHUSDC", NetMax: 1.0}},
+			Hedges:  []HedgeConfig{h},
+		}
+	}
+
+	cfg := base(HedgeConfig{Symbol: "ETHUSDC", HedgeSymbol: "ETHUSDT"})
+	if err := validateHedges(cfg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	h := cfg.Hedges[0]
+	if h.Ratio != 1 || h.Execution != HedgeTaker || h.MaxSlippageBps != 5 || h.PassiveTimeoutSec != 30 || h.IntervalMs != 1000 {
+		t.Errorf("unexpected defaults: %+v", h)
+	}
+
+	invalid := []HedgeConfig{
+		{Symbol: "BTCUSDT", HedgeSymbol: "BTCUSD"},                       // 非做市交易对
+		{Symbol: "ETHUSDC", HedgeSymbol: "ETHUSDC"},                      // 同一账户对冲做市交易对
+		{Symbol: "ETHUSDC", HedgeSymbol: "ETHUSDT", Execution: "market"}, // 未知执行方式
+		{Symbol: "ETHUSDC", HedgeSymbol: "ETHUSDT", Threshold: -1},
+		{Symbol: "ETHUSDC", HedgeSymbol: "ETHUSDT", Exchange: ExchangeBybit}, // 其他交易所需独立账户
+		{Symbol: "ETHUSDC", HedgeSymbol: "ETHUSDT", APIKey: "k"},             // 缺少secret
+	}
+	for i, h := range invalid {
+		if err := validateHedges(base(h)); err == nil {
+			t.Errorf("case %d: expected error for %+v", i, h)
+		}
+	}
+
+	// 独立账户可以对冲同名交易对
+	if err := validateHedges(base(HedgeConfig{Symbol: "ETHUSDC", HedgeSymbol: "ETHUSDC", APIKey: "k", APISecret: "s"})); err != nil {
+		t.Errorf("separate account hedge should be allowed: %v", err)
+	}
+}
+
+func TestValidatePortfolio(t *testing.T) {
+	base := func(p PortfolioConfig) *Config {
+		p.Enabled = true
+		return &Config{
+			Global:  GlobalConfig{Portfolio: p},
+			Symbols: []SymbolConfig{{Symbol: "BTCUSDT", NetMax: 1.0}, {Symbol: "ETHUSDT", NetMax: 10}},
+		}
+	}
+
+	if err := validatePortfolio(base(PortfolioConfig{VaRLimit: 500, BetaSymbol: "BTCUSDT"})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	p := PortfolioConfig{}
+	if p.GetWindow() != 300 || p.GetMinSamples() != 30 || p.GetConfidence() != 0.99 || p.GetThrottleRatio() != 0.8 {
+		t.Errorf("unexpected defaults: window=%d min=%d conf=%v throttle=%v",
+			p.GetWindow(), p.GetMinSamples(), p.GetConfidence(), p.GetThrottleRatio())
+	}
+
+	invalid := []PortfolioConfig{
+		{},                           // 未配置限额
+		{VaRLimit: -1, ESLimit: 100}, // 负限额
+		{ESLimit: 100, Window: 20, MinSamples: 50}, // min_samples 超过窗口
+		{ESLimit: 100, Confidence: 1},
+		{ESLimit: 100, ThrottleRatio: 1},
+		{ESLimit: 100, BetaSymbol: "SOLUSDT"}, // 非做市交易对
+	}
+	for i, p := range invalid {
+		if err := validatePortfolio(base(p)); err == nil {
+			t.Errorf("case %d: expected error for %+v", i, p)
+		}
+	}
+
+	// 未启用时不校验
+	if err := validatePortfolio(&Config{}); err != nil {
+		t.Errorf("disabled portfolio should not be validated: %v", err)
+	}
+}
+
+func TestReadConfigRejectsRemovedKeys(t *testing.T) {
+	path := t.TempDir() + "/config.yaml"
+	content := `
+global:
+  total_notional_max: 1000
+  quote_interval_ms: 500
+  snapshot_path: "./data/snapshot.json"
+symbols:
+  - symbol: "BTCUSDT"
+    net_max: 1.0
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := ReadConfig(path); err == nil || !strings.Contains(err.Error(), "journal_dir") {
+		t.Fatalf("expected snapshot_path to be rejected with a journal_dir hint, got %v", err)
+	}
+
+	// 环境变量同样拒绝
+	if err := os.WriteFile(path, []byte(strings.Replace(content, `  snapshot_path: "./data/snapshot.json"`+"\n", "", 1)), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	t.Setenv("PHOENIX_SNAPSHOT_PATH", "./data/snapshot.json")
+	if _, err := ReadConfig(path); err == nil || !strings.Contains(err.Error(), "PHOENIX_SNAPSHOT_PATH") {
+		t.Fatalf("expected PHOENIX_SNAPSHOT_PATH to be rejected, got %v", err)
+	}
+}
+
+// TestShippedConfigsUseJournal 仓库自带的配置文件不得使用已移除的配置项
+func TestShippedConfigsUseJournal(t *testing.T) {
+	for _, path := range []string{"../../config.mainnet.yaml", "../../config.testnet.yaml", "../../configs/phoenix_live.yaml", "../../configs/phoenix_test_190.yaml"} {
+		v := viper.New()
+		v.SetConfigFile(path)
+		if err := v.ReadInConfig(); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if err := checkRemovedKeys(v); err != nil {
+			t.Errorf("%s: %v", path, err)
+		}
+		if v.GetString("global.journal_dir") == "" {
+			t.Errorf("%s: journal_dir not set", path)
+		}
+	}
+}
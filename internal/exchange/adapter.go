@@ -2,11 +2,17 @@ package gateway
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
+	"math"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/newplayman/market-maker-phoenix/internal/filters"
+	"github.com/newplayman/market-maker-phoenix/internal/metrics"
+	"github.com/newplayman/market-maker-phoenix/internal/orderbook"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,10 +25,30 @@ type BinanceAdapter struct {
 	connected  bool
 	mu         sync.RWMutex
 
+	// 下单通道（rest | ws | ws_with_rest_fallback），tradeWSDialing 标记后台重连中
+	orderChannel   OrderChannel
+	tradeWSDialing int32
+	clientSeq      uint64 // ClientOrderID序号
+
 	// Callbacks
 	depthCallback func(*Depth)
+	tradeCallback func(*Trade)
 	userCallbacks *UserStreamCallbacks
 
+	// recorder 旁路录制原始WS消息（可选）
+	recorder RawRecorder
+
+	// 用户数据流（独立连接，管理listenKey生命周期）
+	listenKeys     ListenKeyService
+	userStream     *UserDataStream
+	stopUserStream context.CancelFunc
+
+	// 后台定时同步服务器时间
+	stopTimeSync context.CancelFunc
+
+	// books 由增量深度流维护的本地L2订单簿
+	books *orderbook.Manager
+
 	// State
 	positions  map[string]*Position
 	orders     map[string]*Order // key: clientOrderID -> Order
@@ -36,9 +62,11 @@ func NewBinanceAdapter(rest BinanceREST, ws BinanceWS) *BinanceAdapter {
 	// Get API keys from REST client if it's BinanceRESTClient
 	apiKey := ""
 	secretKey := ""
+	var timeSync *TimeSync
 	if restClient, ok := rest.(*BinanceRESTClient); ok {
 		apiKey = restClient.APIKey
 		secretKey = restClient.Secret
+		timeSync = restClient.TimeSync // REST与WSS交易共用同一时间同步器
 	}
 
 	tradeWS := NewTradeWSClient(TradeWSConfig{
@@ -49,6 +77,10 @@ func NewBinanceAdapter(rest BinanceREST, ws BinanceWS) *BinanceAdapter {
 		KeepAlive:    15 * time.Second,
 		RetryBackoff: time.Second,
 		MaxRetries:   5,
+		TimeSync:     timeSync,
+		OnFallback: func(req WSRequestMeta, reason error) {
+			log.Warn().Err(reason).Str("method", req.Method).Int64("req_id", req.ID).Msg("WSS交易请求未确认")
+		},
 	})
 
 	adapter := &BinanceAdapter{
@@ -63,47 +95,194 @@ func NewBinanceAdapter(rest BinanceREST, ws BinanceWS) *BinanceAdapter {
 	// 保存REST客户端引用以便调用OpenOrders等方法
 	if restClient, ok := rest.(*BinanceRESTClient); ok {
 		adapter.restClient = restClient
+		adapter.listenKeys = &ListenKeyClient{
+			BaseURL:    restClient.BaseURL,
+			APIKey:     restClient.APIKey,
+			HTTPClient: NewListenKeyHTTPClient(),
+		}
 	}
 
+	adapter.books = orderbook.NewManager(adapter.fetchDepthSnapshot)
+	adapter.books.OnSnapshot = adapter.recordDepthSnapshot
+	adapter.books.OnResync = adapter.emitBookDepth
+
 	return adapter
 }
 
-// PlaceOrder places a new order via REST API (fallback from WSS)
+// depthSnapshotLimit 订单簿同步使用的REST快照档数
+const depthSnapshotLimit = 1000
+
+// depthCallbackLevels 推送给Runner的深度档数
+const depthCallbackLevels = 20
+
+// fetchDepthSnapshot 拉取REST深度快照，供本地订单簿锚定
+func (b *BinanceAdapter) fetchDepthSnapshot(symbol string) (orderbook.Snapshot, error) {
+	if b.restClient == nil {
+		return orderbook.Snapshot{}, fmt.Errorf("rest client not available")
+	}
+	lastUpdateID, bids, asks, err := b.restClient.DepthSnapshot(symbol, depthSnapshotLimit)
+	if err != nil {
+		return orderbook.Snapshot{}, err
+	}
+	return orderbook.Snapshot{
+		LastUpdateID: lastUpdateID,
+		Bids:         toBookLevels(bids),
+		Asks:         toBookLevels(asks),
+	}, nil
+}
+
+// recordDepthSnapshot 将快照写入录制器，回放时用于锚定增量
+func (b *BinanceAdapter) recordDepthSnapshot(symbol string, snap orderbook.Snapshot) {
+	b.mu.RLock()
+	rec := b.recorder
+	b.mu.RUnlock()
+	if rec == nil {
+		return
+	}
+	rec.Record(EncodeDepthSnapshotMessage(symbol, snap.LastUpdateID, fromBookLevels(snap.Bids), fromBookLevels(snap.Asks)))
+}
+
+// emitBookDepth 将本地订单簿前N档推送给深度回调
+func (b *BinanceAdapter) emitBookDepth(symbol string) {
+	book := b.books.Book(symbol)
+	if !book.Synced() {
+		return
+	}
+	bids, asks := book.Levels(depthCallbackLevels)
+	if len(bids) == 0 || len(asks) == 0 {
+		return
+	}
+
+	b.mu.RLock()
+	callback := b.depthCallback
+	b.mu.RUnlock()
+
+	if callback != nil {
+		callback(&Depth{
+			Symbol:    symbol,
+			Bids:      fromBookLevels(bids),
+			Asks:      fromBookLevels(asks),
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+func toBookLevels(levels []PriceLevel) []orderbook.Level {
+	out := make([]orderbook.Level, len(levels))
+	for i, lv := range levels {
+		out[i] = orderbook.Level{Price: lv.Price, Quantity: lv.Quantity}
+	}
+	return out
+}
+
+func fromBookLevels(levels []orderbook.Level) []PriceLevel {
+	out := make([]PriceLevel, len(levels))
+	for i, lv := range levels {
+		out[i] = PriceLevel{Price: lv.Price, Quantity: lv.Quantity}
+	}
+	return out
+}
+
+// SetRecorder 设置原始WS消息录制器，需在Connect之前调用。
+// 录制只在 OnRawMessage 中进行（BinanceWSReal 不再单独录制），每条消息只写入一次
+func (b *BinanceAdapter) SetRecorder(rec RawRecorder) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.recorder = rec
+}
+
+// SetListenKeyClient 替换listenKey管理客户端，需在StartUserStream之前调用
+func (b *BinanceAdapter) SetListenKeyClient(lk ListenKeyService) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listenKeys = lk
+}
+
+// SetTradeWSEndpoint 替换WSS交易API地址（测试网或本地模拟器），需在Connect之前调用
+func (b *BinanceAdapter) SetTradeWSEndpoint(endpoint string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tradeWS.cfg.BaseURL = endpoint
+}
+
+// PlaceOrder 按配置的下单通道下Post Only限价单。
+// ws_with_rest_fallback 模式下WSS超时或断线时使用同一ClientOrderID通过REST重试，
+// 交易所按ClientOrderID去重，REST返回-4116说明WSS请求实际已生效
 func (b *BinanceAdapter) PlaceOrder(ctx context.Context, order *Order) (*Order, error) {
 	if order == nil {
 		return nil, ErrInvalidOrder
 	}
 
-	// Generate client order ID if not provided
-	if order.ClientOrderID == "" {
-		// 使用Unix毫秒时间戳确保订单ID长度符合交易所要求(小于36字符)
-		order.ClientOrderID = fmt.Sprintf("phoenix-%s-%d", order.Symbol, time.Now().UnixMilli())
+	b.ensureClientOrderID(order)
+
+	channel := b.getOrderChannel()
+	used := OrderChannelREST
+	var orderID string
+	var err error
+	if b.useWS(channel) {
+		used = OrderChannelWS
+		orderID, err = b.placeViaWS(ctx, order)
+		if channel == OrderChannelWSWithFallback && shouldFallback(ctx, err) {
+			log.Warn().
+				Err(err).
+				Str("symbol", order.Symbol).
+				Str("client_id", order.ClientOrderID).
+				Msg("WSS下单未确认，使用同一ClientOrderID回退REST")
+			metrics.RecordOrderChannelFallback("place")
+			used = OrderChannelREST
+			orderID, err = b.placeViaREST(order)
+			if isDuplicateClientOrderID(err) {
+				log.Info().
+					Str("client_id", order.ClientOrderID).
+					Msg("REST重试返回重复ClientOrderID，订单已由WSS下达")
+				err = nil
+			}
+		}
+	} else {
+		orderID, err = b.placeViaREST(order)
 	}
+	return b.finishPlace(order, orderID, used, err)
+}
 
-	// Use REST API as fallback (WSS requires special API key permissions)
-	orderID, err := b.rest.PlaceLimit(
-		order.Symbol,
-		order.Side,
-		"GTC", // Good Till Cancel
-		order.Price,
-		order.Quantity,
-		false, // reduceOnly
-		true,  // postOnly - Maker-only for free fees
-		order.ClientOrderID,
-	)
+// ensureClientOrderID 未指定时生成ClientOrderID。
+// 批量下单时同一毫秒会生成多个ID，追加序号避免重复
+func (b *BinanceAdapter) ensureClientOrderID(order *Order) {
+	if order.ClientOrderID != "" {
+		return
+	}
+	order.ClientOrderID = NextClientOrderID(&b.clientSeq, order.Symbol)
+}
 
+// clientOrderIDMaxLen Binance clientOrderId / Bybit orderLinkId 长度上限
+const clientOrderIDMaxLen = 36
+
+// NextClientOrderID 生成 phoenix-{symbol}-{毫秒时间戳}{序号} 形式的ClientOrderID。
+// 时间戳与序号共16位，交易对名称超过11个字符（如 1000SHIBUSDT）时截断，保证总长度不超过36字符；
+// 交易所按交易对区分ClientOrderID，截断后不同交易对的前缀相同不影响唯一性
+func NextClientOrderID(seq *uint64, symbol string) string {
+	n := atomic.AddUint64(seq, 1) % 1000
+	suffix := strconv.FormatInt(time.Now().UnixMilli()*1000+int64(n), 10)
+	if limit := clientOrderIDMaxLen - len("phoenix--") - len(suffix); len(symbol) > limit {
+		symbol = symbol[:limit]
+	}
+	return "phoenix-" + symbol + "-" + suffix
+}
+
+// finishPlace 处理单个下单结果：Post Only拒单、错误包装与本地订单缓存
+func (b *BinanceAdapter) finishPlace(order *Order, orderID string, used OrderChannel, err error) (*Order, error) {
 	// If Post Only failed with -5022 error (would execute as taker), skip
-	if err != nil && strings.Contains(err.Error(), "-5022") {
+	if errors.Is(err, ErrPostOnlyReject) {
 		log.Debug().
 			Str("symbol", order.Symbol).
 			Str("side", order.Side).
 			Float64("price", order.Price).
-			Msg("订单价格过近，跳过Post Only失败的订单(REST)")
+			Str("channel", used.label()).
+			Msg("订单价格过近，跳过Post Only失败的订单")
 		return nil, err
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("rest place order failed: %w", err)
+		return nil, fmt.Errorf("%s place order failed: %w", used, err)
 	}
 
 	// Update order state
@@ -121,21 +300,51 @@ func (b *BinanceAdapter) PlaceOrder(ctx context.Context, order *Order) (*Order,
 		Float64("qty", order.Quantity).
 		Str("client_id", order.ClientOrderID).
 		Str("order_id", orderID).
-		Str("channel", "REST").
+		Str("channel", used.label()).
 		Msg("订单已下达")
 
 	return order, nil
 }
 
-// CancelOrder cancels an existing order via REST API (fallback from WSS)
+// CancelOrder 按配置的下单通道撤单。
+// 回退模式下WSS超时或断线时通过REST重试，REST返回-2011说明订单已不在挂单中
 func (b *BinanceAdapter) CancelOrder(ctx context.Context, symbol, clientOrderID string) error {
 	if symbol == "" || clientOrderID == "" {
 		return ErrInvalidOrder
 	}
 
-	// Use REST API as fallback
-	if err := b.rest.CancelOrder(symbol, clientOrderID); err != nil {
-		return fmt.Errorf("rest cancel order failed: %w", err)
+	channel := b.getOrderChannel()
+	used := OrderChannelREST
+	var err error
+	if b.useWS(channel) {
+		used = OrderChannelWS
+		err = b.cancelViaWS(ctx, symbol, clientOrderID)
+		if channel == OrderChannelWSWithFallback && shouldFallback(ctx, err) {
+			log.Warn().
+				Err(err).
+				Str("symbol", symbol).
+				Str("client_id", clientOrderID).
+				Msg("WSS撤单未确认，回退REST")
+			metrics.RecordOrderChannelFallback("cancel")
+			used = OrderChannelREST
+			err = b.cancelViaREST(symbol, clientOrderID)
+			if isUnknownOrder(err) {
+				log.Info().
+					Str("client_id", clientOrderID).
+					Msg("REST重试返回未知订单，订单已不在挂单中")
+				err = nil
+			}
+		}
+	} else {
+		err = b.cancelViaREST(symbol, clientOrderID)
+	}
+	return b.finishCancel(symbol, clientOrderID, used, err)
+}
+
+// finishCancel 处理单个撤单结果：错误包装与本地订单缓存
+func (b *BinanceAdapter) finishCancel(symbol, clientOrderID string, used OrderChannel, err error) error {
+	if err != nil {
+		return fmt.Errorf("%s cancel order failed: %w", used, err)
 	}
 
 	// 撤单成功后，从本地订单map中删除该订单
@@ -150,12 +359,198 @@ func (b *BinanceAdapter) CancelOrder(ctx context.Context, symbol, clientOrderID
 	log.Info().
 		Str("symbol", symbol).
 		Str("client_id", clientOrderID).
-		Str("channel", "REST").
+		Str("channel", used.label()).
 		Msg("订单已撤销")
 
 	return nil
 }
 
+// PlaceOrders 批量下单。REST通道使用 /fapi/v1/batchOrders（每批5个），
+// 单个订单失败（如-5022）只影响该订单；WebSocket API没有批量下单接口，ws通道逐个下单
+func (b *BinanceAdapter) PlaceOrders(ctx context.Context, orders []*Order) []OrderResult {
+	if b.restClient == nil || b.getOrderChannel() != OrderChannelREST {
+		return PlaceOrdersEach(ctx, orders, b.PlaceOrder)
+	}
+
+	results := make([]OrderResult, len(orders))
+	for start := 0; start < len(orders); start += MaxBatchPlaceOrders {
+		end := start + MaxBatchPlaceOrders
+		if end > len(orders) {
+			end = len(orders)
+		}
+		var idx []int
+		var batch []BatchLimitOrder
+		for i := start; i < end; i++ {
+			o := orders[i]
+			if o == nil {
+				results[i].Err = ErrInvalidOrder
+				continue
+			}
+			b.ensureClientOrderID(o)
+			idx = append(idx, i)
+			batch = append(batch, BatchLimitOrder{
+				Symbol:   o.Symbol,
+				Side:     o.Side,
+				Price:    o.Price,
+				Qty:      o.Quantity,
+				PostOnly: true, // Maker-only
+				ClientID: o.ClientOrderID,
+			})
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		t0 := time.Now()
+		items, err := b.restClient.PlaceLimitBatch(batch)
+		observeOrderChannel(OrderChannelREST, "batch_place", t0, err)
+		for j, i := range idx {
+			if err != nil {
+				results[i].Err = fmt.Errorf("rest batch place failed: %w", err)
+				continue
+			}
+			results[i].Order, results[i].Err = b.finishPlace(orders[i], items[j].OrderID, OrderChannelREST, items[j].Err)
+		}
+	}
+	return results
+}
+
+// CancelOrders 批量撤单。REST通道使用 DELETE /fapi/v1/batchOrders（每批10个），
+// 返回的错误与clientOrderIDs一一对应；ws通道逐个撤单
+func (b *BinanceAdapter) CancelOrders(ctx context.Context, symbol string, clientOrderIDs []string) []error {
+	if b.restClient == nil || b.getOrderChannel() != OrderChannelREST {
+		return CancelOrdersEach(ctx, symbol, clientOrderIDs, b.CancelOrder)
+	}
+
+	errs := make([]error, len(clientOrderIDs))
+	for start := 0; start < len(clientOrderIDs); start += MaxBatchCancelOrders {
+		end := start + MaxBatchCancelOrders
+		if end > len(clientOrderIDs) {
+			end = len(clientOrderIDs)
+		}
+		chunk := clientOrderIDs[start:end]
+
+		t0 := time.Now()
+		items, err := b.restClient.CancelBatch(symbol, chunk)
+		observeOrderChannel(OrderChannelREST, "batch_cancel", t0, err)
+		for j, id := range chunk {
+			if err != nil {
+				errs[start+j] = fmt.Errorf("rest batch cancel failed: %w", err)
+				continue
+			}
+			errs[start+j] = b.finishCancel(symbol, id, OrderChannelREST, items[j].Err)
+		}
+	}
+	return errs
+}
+
+// AmendOrder 按配置的下单通道修改挂单价格（PUT /fapi/v1/order 或 WSS order.modify），ClientOrderID保持不变。
+// 回退模式下WSS超时或断线时通过REST重试，REST返回-5027说明WSS改单实际已生效
+func (b *BinanceAdapter) AmendOrder(ctx context.Context, order *Order) (*Order, error) {
+	if order == nil || order.Symbol == "" || order.ClientOrderID == "" {
+		return nil, ErrInvalidOrder
+	}
+
+	channel := b.getOrderChannel()
+	used := OrderChannelREST
+	var orderID string
+	var err error
+	if b.useWS(channel) {
+		used = OrderChannelWS
+		orderID, err = b.amendViaWS(ctx, order)
+		if channel == OrderChannelWSWithFallback && shouldFallback(ctx, err) {
+			log.Warn().
+				Err(err).
+				Str("symbol", order.Symbol).
+				Str("client_id", order.ClientOrderID).
+				Msg("WSS改单未确认，回退REST")
+			metrics.RecordOrderChannelFallback("amend")
+			used = OrderChannelREST
+			orderID, err = b.amendViaREST(order)
+		}
+	} else {
+		orderID, err = b.amendViaREST(order)
+	}
+	return b.finishAmend(order, orderID, used, err)
+}
+
+// finishAmend 处理单个改单结果：-5027视为成功，更新本地订单缓存
+func (b *BinanceAdapter) finishAmend(order *Order, orderID string, used OrderChannel, err error) (*Order, error) {
+	if isNoNeedToModify(err) {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s amend order failed: %w", used, err)
+	}
+
+	b.stateMu.Lock()
+	if cached, exists := b.orders[order.ClientOrderID]; exists {
+		cached.Price = order.Price
+		cached.Quantity = order.Quantity
+	}
+	b.stateMu.Unlock()
+
+	log.Info().
+		Str("symbol", order.Symbol).
+		Str("side", order.Side).
+		Float64("price", order.Price).
+		Float64("qty", order.Quantity).
+		Str("client_id", order.ClientOrderID).
+		Str("order_id", orderID).
+		Str("channel", used.label()).
+		Msg("订单已改价")
+
+	return order, nil
+}
+
+// AmendOrders 批量改单。REST通道使用 PUT /fapi/v1/batchOrders（每批5个），
+// 结果与orders一一对应；ws通道逐个改单
+func (b *BinanceAdapter) AmendOrders(ctx context.Context, orders []*Order) []OrderResult {
+	if b.restClient == nil || b.getOrderChannel() != OrderChannelREST {
+		return PlaceOrdersEach(ctx, orders, b.AmendOrder)
+	}
+
+	results := make([]OrderResult, len(orders))
+	for start := 0; start < len(orders); start += MaxBatchAmendOrders {
+		end := start + MaxBatchAmendOrders
+		if end > len(orders) {
+			end = len(orders)
+		}
+		var idx []int
+		var batch []BatchLimitOrder
+		for i := start; i < end; i++ {
+			o := orders[i]
+			if o == nil || o.Symbol == "" || o.ClientOrderID == "" {
+				results[i].Err = ErrInvalidOrder
+				continue
+			}
+			idx = append(idx, i)
+			batch = append(batch, BatchLimitOrder{
+				Symbol:   o.Symbol,
+				Side:     o.Side,
+				Price:    o.Price,
+				Qty:      o.Quantity,
+				ClientID: o.ClientOrderID,
+			})
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		t0 := time.Now()
+		items, err := b.restClient.AmendLimitBatch(batch)
+		observeOrderChannel(OrderChannelREST, "batch_amend", t0, err)
+		for j, i := range idx {
+			if err != nil {
+				results[i].Err = fmt.Errorf("rest batch amend failed: %w", err)
+				continue
+			}
+			results[i].Order, results[i].Err = b.finishAmend(orders[i], items[j].OrderID, OrderChannelREST, items[j].Err)
+		}
+	}
+	return results
+}
+
 // CancelAllOrders cancels all open orders for a symbol
 func (b *BinanceAdapter) CancelAllOrders(ctx context.Context, symbol string) error {
 	b.stateMu.RLock()
@@ -278,8 +673,21 @@ func (b *BinanceAdapter) GetAllPositions(ctx context.Context) ([]*Position, erro
 }
 
 // GetFundingRate returns the funding rate for a symbol
+// 有REST客户端时读取 premiumIndex，否则返回固定占位值
 func (b *BinanceAdapter) GetFundingRate(ctx context.Context, symbol string) (*FundingRate, error) {
-	// Stub implementation - return zero funding rate
+	if b.restClient != nil {
+		_, rate, nextFunding, err := b.restClient.PremiumIndex(symbol)
+		if err != nil {
+			return nil, err
+		}
+		return &FundingRate{
+			Symbol:          symbol,
+			Rate:            rate,
+			NextFundingTime: time.UnixMilli(nextFunding),
+			Timestamp:       time.Now(),
+		}, nil
+	}
+
 	return &FundingRate{
 		Symbol:          symbol,
 		Rate:            0.0001, // 0.01%
@@ -289,12 +697,33 @@ func (b *BinanceAdapter) GetFundingRate(ctx context.Context, symbol string) (*Fu
 }
 
 // GetDepth returns order book depth
+// 本地订单簿已同步时直接读取，否则回退到REST快照
 func (b *BinanceAdapter) GetDepth(ctx context.Context, symbol string, limit int) (*Depth, error) {
-	// Stub implementation
+	if limit <= 0 {
+		limit = depthCallbackLevels
+	}
+
+	if book := b.books.Book(symbol); book.Synced() {
+		bids, asks := book.Levels(limit)
+		return &Depth{
+			Symbol:    symbol,
+			Bids:      fromBookLevels(bids),
+			Asks:      fromBookLevels(asks),
+			Timestamp: book.UpdatedAt(),
+		}, nil
+	}
+
+	if b.restClient == nil {
+		return nil, fmt.Errorf("rest client not available")
+	}
+	_, bids, asks, err := b.restClient.DepthSnapshot(symbol, limit)
+	if err != nil {
+		return nil, err
+	}
 	return &Depth{
 		Symbol:    symbol,
-		Bids:      []PriceLevel{},
-		Asks:      []PriceLevel{},
+		Bids:      bids,
+		Asks:      asks,
 		Timestamp: time.Now(),
 	}, nil
 }
@@ -316,22 +745,165 @@ func (b *BinanceAdapter) StartDepthStream(ctx context.Context, symbols []string,
 	return nil
 }
 
+// StartTradeStream 订阅归集逐笔成交流，成交通过onTrade回调推送
+func (b *BinanceAdapter) StartTradeStream(ctx context.Context, symbols []string, onTrade func(*Trade)) error {
+	b.mu.Lock()
+	b.tradeCallback = onTrade
+	b.mu.Unlock()
+
+	for _, symbol := range symbols {
+		if err := b.ws.SubscribeTrade(symbol); err != nil {
+			return err
+		}
+	}
+
+	log.Info().Strs("symbols", symbols).Msg("逐笔成交流已订阅")
+	return nil
+}
+
 // StartUserStream starts the user data stream
+// 创建listenKey并在独立连接上接收订单/账户推送：每30分钟续期，listenKey过期或断线时自动重建并重连，
+// 重连后通过REST对账断线期间的订单与仓位
 func (b *BinanceAdapter) StartUserStream(ctx context.Context, callbacks *UserStreamCallbacks) error {
 	b.mu.Lock()
+	defer b.mu.Unlock()
+
 	b.userCallbacks = callbacks
-	b.mu.Unlock()
+	if b.userStream != nil {
+		return nil
+	}
+	if b.listenKeys == nil {
+		return fmt.Errorf("listenKey client not configured")
+	}
 
-	// Subscribe to user data stream
-	listenKey := "dummy-listen-key" // Should be obtained from REST API
-	if err := b.ws.SubscribeUserData(listenKey); err != nil {
+	endpoint := BinanceFuturesWSEndpoint
+	if real, ok := b.ws.(*BinanceWSReal); ok && real.BaseEndpoint != "" {
+		endpoint = real.BaseEndpoint
+	}
+	handler := &adapterWSHandler{adapter: b}
+	stream := &UserDataStream{
+		BaseEndpoint: endpoint,
+		ListenKeys:   b.listenKeys,
+		OnMessage:    handler.OnRawMessage,
+		OnReconnect:  b.reconcileAfterGap,
+	}
+	streamCtx, cancel := context.WithCancel(ctx)
+	if err := stream.Start(streamCtx); err != nil {
+		cancel()
 		return err
 	}
+	b.userStream = stream
+	b.stopUserStream = cancel
 
 	log.Info().Msg("用户数据流已订阅")
 	return nil
 }
 
+// reconcileAfterGap 用户数据流重连后通过REST对账：
+// 本地活跃但已不在交易所挂单列表中的订单查询终态并补发回报，断线期间的部分成交按累计成交量差额补发，
+// 仓位按REST结果推送。补发的成交不含手续费明细，价格使用成交均价（无则用委托价）
+func (b *BinanceAdapter) reconcileAfterGap() {
+	if b.restClient == nil {
+		return
+	}
+	handler := &adapterWSHandler{adapter: b}
+
+	b.stateMu.RLock()
+	local := make(map[string]Order)
+	for id, o := range b.orders {
+		if o.Status == "NEW" || o.Status == "PARTIALLY_FILLED" {
+			local[id] = *o
+		}
+	}
+	b.stateMu.RUnlock()
+
+	open, err := b.restClient.OpenOrders("")
+	if err != nil {
+		log.Error().Err(err).Msg("对账：获取活跃订单失败")
+	} else {
+		stillOpen := make(map[string]FuturesOpenOrder, len(open))
+		for _, o := range open {
+			stillOpen[o.ClientOrderID] = o
+		}
+
+		recovered := 0
+		for id, o := range local {
+			eo, ok := stillOpen[id]
+			if !ok {
+				q, err := b.restClient.QueryOrder(o.Symbol, id)
+				if err != nil {
+					log.Error().Err(err).Str("client_id", id).Msg("对账：查询订单失败")
+					continue
+				}
+				eo = q
+			}
+			if ok && eo.ExecutedQty <= o.FilledQty {
+				continue
+			}
+			update := &Order{
+				Symbol:        o.Symbol,
+				Side:          o.Side,
+				Type:          o.Type,
+				Status:        eo.Status,
+				ClientOrderID: id,
+				Price:         o.Price,
+				Quantity:      o.Quantity,
+				FilledQty:     eo.ExecutedQty,
+				CreatedAt:     o.CreatedAt,
+			}
+			if missed := eo.ExecutedQty - o.FilledQty; missed > 0 {
+				update.LastFilledQty = missed
+				update.LastFilledPrice = o.Price
+				if eo.AvgPrice > 0 {
+					update.LastFilledPrice = eo.AvgPrice
+				}
+			}
+			handler.HandleOrderUpdate(update)
+			recovered++
+		}
+		log.Info().Int("open", len(open)).Int("recovered", recovered).Msg("对账：订单状态已同步")
+	}
+
+	if err := b.syncPositions(handler); err != nil {
+		log.Error().Err(err).Msg("对账：获取仓位失败")
+	}
+}
+
+// SyncPositions 通过REST加载仓位并推送；之后由用户数据流增量更新
+func (b *BinanceAdapter) SyncPositions(ctx context.Context) error {
+	if b.restClient == nil {
+		return fmt.Errorf("rest client not available")
+	}
+	return b.syncPositions(&adapterWSHandler{adapter: b})
+}
+
+// syncPositions 按REST仓位推送更新，本地未知且为0的仓位跳过
+func (b *BinanceAdapter) syncPositions(handler *adapterWSHandler) error {
+	positions, err := b.restClient.PositionRisk("")
+	if err != nil {
+		return err
+	}
+	b.stateMu.RLock()
+	var updates []*Position
+	for _, p := range positions {
+		if _, known := b.positions[p.Symbol]; !known && p.PositionAmt == 0 {
+			continue
+		}
+		updates = append(updates, &Position{
+			Symbol:        p.Symbol,
+			Size:          p.PositionAmt,
+			EntryPrice:    p.EntryPrice,
+			UnrealizedPNL: p.UnrealizedProfit,
+			Notional:      math.Abs(p.PositionAmt) * p.MarkPrice,
+		})
+	}
+	b.stateMu.RUnlock()
+	if len(updates) > 0 {
+		handler.HandlePositionUpdate(updates)
+	}
+	return nil
+}
+
 // Connect establishes connection to the exchange
 func (b *BinanceAdapter) Connect(ctx context.Context) error {
 	b.mu.Lock()
@@ -341,11 +913,31 @@ func (b *BinanceAdapter) Connect(ctx context.Context) error {
 		return nil
 	}
 
+	// 签名请求前先同步服务器时间，之后后台定时同步
+	if b.restClient != nil && b.restClient.TimeSync != nil {
+		ts := b.restClient.TimeSync
+		if err := ts.Sync(); err != nil {
+			log.Warn().Err(err).Msg("服务器时间同步失败，签名暂用本地时间")
+		} else {
+			log.Info().Int64("offset_ms", ts.GetOffset()).Dur("rtt", ts.GetRTT()).Msg("服务器时间已同步")
+		}
+		syncCtx, cancel := context.WithCancel(ctx)
+		b.stopTimeSync = cancel
+		ts.Start(syncCtx)
+	}
+
 	// Start WebSocket trading client
 	b.tradeWS.Start(ctx)
-	log.Info().Msg("WebSocket交易客户端已启动")
+	log.Info().Str("order_channel", string(b.orderChannel)).Msg("WebSocket交易客户端已启动")
+	if b.orderChannel == OrderChannelWSWithFallback {
+		// 后台预先建立WSS交易连接，连接完成前下单走REST
+		b.tradeWSAvailable()
+	}
 
 	// Start WebSocket handler for market data
+	if real, ok := b.ws.(*BinanceWSReal); ok {
+		real.OnResubscribe(b.resyncBooks)
+	}
 	go func() {
 		handler := &adapterWSHandler{adapter: b}
 		if err := b.ws.Run(handler); err != nil {
@@ -364,6 +956,23 @@ func (b *BinanceAdapter) Disconnect() error {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	// 停止用户数据流并关闭listenKey
+	if b.stopUserStream != nil {
+		b.stopUserStream()
+		b.stopUserStream = nil
+		b.userStream = nil
+	}
+
+	if b.stopTimeSync != nil {
+		b.stopTimeSync()
+		b.stopTimeSync = nil
+	}
+
+	// 关闭行情连接
+	if closer, ok := b.ws.(interface{ Close() error }); ok {
+		_ = closer.Close()
+	}
+
 	// Close WebSocket trading client
 	if b.tradeWS != nil {
 		b.tradeWS.Close()
@@ -376,6 +985,26 @@ func (b *BinanceAdapter) Disconnect() error {
 	return nil
 }
 
+// ReconnectMarketData 强制重连交易对所在的行情连接，重连后订单簿重新拉取快照
+func (b *BinanceAdapter) ReconnectMarketData(symbol string) error {
+	real, ok := b.ws.(*BinanceWSReal)
+	if !ok {
+		return fmt.Errorf("market data reconnect not supported")
+	}
+	if !real.ForceReconnect(symbol, ReconnectReasonStale) {
+		return fmt.Errorf("symbol %s not subscribed", symbol)
+	}
+	return nil
+}
+
+// resyncBooks 行情重连后断线期间的增量已丢失：重置订单簿，收到新增量时重新拉取快照
+func (b *BinanceAdapter) resyncBooks(symbols []string) {
+	for _, symbol := range symbols {
+		b.books.Book(symbol).Reset()
+	}
+	log.Info().Strs("symbols", symbols).Msg("行情已重连，订单簿等待重新同步")
+}
+
 // IsConnected returns connection status
 func (b *BinanceAdapter) IsConnected() bool {
 	b.mu.RLock()
@@ -391,10 +1020,28 @@ type adapterWSHandler struct {
 
 // OnRawMessage 处理WebSocket原始消息
 func (h *adapterWSHandler) OnRawMessage(msg []byte) {
-	// 尝试解析深度数据
-	symbol, bid, ask, err := ParseCombinedDepth(msg)
-	if err == nil && symbol != "" && bid > 0 && ask > 0 {
-		h.OnDepth(symbol, bid, ask)
+	h.adapter.mu.RLock()
+	rec := h.adapter.recorder
+	h.adapter.mu.RUnlock()
+	if rec != nil {
+		rec.Record(msg)
+	}
+
+	// 归集逐笔成交
+	if trade, err := ParseCombinedAggTrade(msg); err == nil {
+		h.emitTrade(trade.ToTrade())
+		return
+	}
+
+	// 增量深度：更新本地订单簿
+	if diff, err := ParseCombinedDepthDiff(msg); err == nil {
+		h.OnDepthDiff(diff)
+		return
+	}
+
+	// 有限档深度（@depth20等）：直接推送多档深度
+	if symbol, bids, asks, err := ParseCombinedDepthLevels(msg); err == nil && symbol != "" && len(bids) > 0 && len(asks) > 0 {
+		h.emitDepth(&Depth{Symbol: symbol, Bids: bids, Asks: asks, Timestamp: time.Now()})
 		return
 	}
 
@@ -408,21 +1055,34 @@ func (h *adapterWSHandler) OnRawMessage(msg []byte) {
 	}
 
 	// 处理订单更新
-	if userEvent.Order != nil {
+	if u := userEvent.Order; u != nil {
 		order := &Order{
-			Symbol:        userEvent.Order.Symbol,
-			Side:          userEvent.Order.Side,
-			Type:          userEvent.Order.OrderType,
-			Status:        userEvent.Order.Status,
-			ClientOrderID: userEvent.Order.ClientOrderID,
-			Price:         userEvent.Order.Price,
-			Quantity:      userEvent.Order.OrigQty,
-			FilledQty:     userEvent.Order.AccumulatedQty,
-			CreatedAt:     time.Unix(0, userEvent.Order.EventTime*1e6),
+			Symbol:        u.Symbol,
+			Side:          u.Side,
+			Type:          u.OrderType,
+			Status:        u.Status,
+			ClientOrderID: u.ClientOrderID,
+			Price:         u.Price,
+			Quantity:      u.OrigQty,
+			FilledQty:     u.AccumulatedQty,
+			CreatedAt:     time.Unix(0, u.EventTime*1e6),
+		}
+		if u.ExecutionType == "TRADE" {
+			order.LastFilledQty = u.LastFilledQty
+			order.LastFilledPrice = u.LastFilledPrice
+			order.RealizedPNL = u.RealizedPnL
+			order.Commission = u.CommissionAmount
+			order.CommissionAsset = u.CommissionAsset
+			order.IsMaker = u.IsMaker
 		}
 		h.HandleOrderUpdate(order)
 	}
 
+	// 资金费结算
+	if userEvent.Account != nil && userEvent.Account.Reason == "FUNDING_FEE" {
+		h.onFundingFee(userEvent.Account)
+	}
+
 	// 处理账户更新
 	if userEvent.Account != nil {
 		var positions []*Position
@@ -440,20 +1100,76 @@ func (h *adapterWSHandler) OnRawMessage(msg []byte) {
 	}
 }
 
+// onFundingFee 将资金费结算推送为带结算金额的资金费率事件。
+// 逐仓推送带有对应仓位；全仓只推送余额变化，此时仅在唯一持仓时可归属到交易对
+func (h *adapterWSHandler) onFundingFee(acc *AccountUpdate) {
+	payment := 0.0
+	for _, b := range acc.Balances {
+		payment += b.BalanceChange
+	}
+	if payment == 0 {
+		return
+	}
+
+	symbol := ""
+	if len(acc.Positions) == 1 {
+		symbol = acc.Positions[0].Symbol
+	} else if len(acc.Positions) == 0 {
+		h.adapter.stateMu.RLock()
+		for sym, pos := range h.adapter.positions {
+			if pos == nil || pos.Size == 0 {
+				continue
+			}
+			if symbol != "" {
+				symbol = ""
+				break
+			}
+			symbol = sym
+		}
+		h.adapter.stateMu.RUnlock()
+	}
+	if symbol == "" {
+		log.Warn().Float64("payment", payment).Msg("资金费结算无法归属到交易对，已忽略")
+		return
+	}
+
+	h.HandleFundingUpdate(&FundingRate{Symbol: symbol, Payment: payment, Timestamp: time.Now()})
+}
+
+// OnDepthDiff 应用增量深度，订单簿同步时推送多档深度
+func (h *adapterWSHandler) OnDepthDiff(diff DepthDiff) {
+	synced := h.adapter.books.Apply(orderbook.Update{
+		Symbol:            diff.Symbol,
+		EventTime:         diff.EventTime,
+		FirstUpdateID:     diff.FirstUpdateID,
+		FinalUpdateID:     diff.FinalUpdateID,
+		PrevFinalUpdateID: diff.PrevFinalUpdateID,
+		Bids:              toBookLevels(diff.Bids),
+		Asks:              toBookLevels(diff.Asks),
+	})
+	if synced {
+		h.adapter.emitBookDepth(diff.Symbol)
+	}
+}
+
 // OnDepth handles depth updates from WebSocket
+// 仅有最优价的WS实现（如BinanceWSStub）走此路径；本地订单簿已同步时优先推送完整档位
 func (h *adapterWSHandler) OnDepth(symbol string, bid, ask float64) {
-	// Create Depth object
-	depth := &Depth{
-		Symbol: symbol,
-		Bids: []PriceLevel{
-			{Price: bid, Quantity: 1.0},
-		},
-		Asks: []PriceLevel{
-			{Price: ask, Quantity: 1.0},
-		},
-		Timestamp: time.Now(),
+	if h.adapter.books.Book(symbol).Synced() {
+		h.adapter.emitBookDepth(symbol)
+		return
 	}
 
+	// 数量未知，置0
+	h.emitDepth(&Depth{
+		Symbol:    symbol,
+		Bids:      []PriceLevel{{Price: bid}},
+		Asks:      []PriceLevel{{Price: ask}},
+		Timestamp: time.Now(),
+	})
+}
+
+func (h *adapterWSHandler) emitDepth(depth *Depth) {
 	h.adapter.mu.RLock()
 	callback := h.adapter.depthCallback
 	h.adapter.mu.RUnlock()
@@ -465,17 +1181,28 @@ func (h *adapterWSHandler) OnDepth(symbol string, bid, ask float64) {
 
 // OnTrade handles trade updates from WebSocket
 func (h *adapterWSHandler) OnTrade(symbol string, price, qty float64) {
-	// Trade events can be used for additional processing if needed
-	log.Debug().
-		Str("symbol", symbol).
-		Float64("price", price).
-		Float64("qty", qty).
-		Msg("交易事件")
+	h.emitTrade(&Trade{Symbol: symbol, Price: price, Quantity: qty, Timestamp: time.Now()})
+}
+
+// emitTrade 推送逐笔成交给上层回调
+func (h *adapterWSHandler) emitTrade(trade *Trade) {
+	h.adapter.mu.RLock()
+	callback := h.adapter.tradeCallback
+	h.adapter.mu.RUnlock()
+
+	if callback != nil {
+		callback(trade)
+	}
 }
 
 // HandleOrderUpdate handles order updates (called by user stream)
 func (h *adapterWSHandler) HandleOrderUpdate(order *Order) {
 	h.adapter.stateMu.Lock()
+	// 对账已补发终态的订单，重连后推送的同一回报丢弃，避免重复记账
+	if prev, ok := h.adapter.orders[order.ClientOrderID]; ok && isFinalStatus(prev.Status) && order.FilledQty <= prev.FilledQty {
+		h.adapter.stateMu.Unlock()
+		return
+	}
 	h.adapter.orders[order.ClientOrderID] = order
 	h.adapter.stateMu.Unlock()
 
@@ -529,3 +1256,44 @@ func (b *BinanceAdapter) GetAccountBalance(ctx context.Context) (float64, float6
 
 	return accountInfo.TotalWalletBalance, accountInfo.TotalUnrealizedProfit, nil
 }
+
+// SymbolFilters 通过 /fapi/v1/exchangeInfo 读取交易规则
+func (b *BinanceAdapter) SymbolFilters(ctx context.Context, symbols []string) (map[string]filters.Symbol, error) {
+	if b.restClient == nil {
+		return nil, fmt.Errorf("rest client not available")
+	}
+	infos, err := b.restClient.ExchangeInfo("")
+	if err != nil {
+		return nil, err
+	}
+	wanted := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		wanted[s] = true
+	}
+	out := make(map[string]filters.Symbol, len(symbols))
+	for _, info := range infos {
+		if !wanted[info.Symbol] {
+			continue
+		}
+		out[info.Symbol] = filters.Symbol{
+			Symbol:      info.Symbol,
+			TickSize:    info.TickSize,
+			StepSize:    info.StepSize,
+			MinQty:      info.MinQty,
+			MaxQty:      info.MaxQty,
+			MinPrice:    info.MinPrice,
+			MaxPrice:    info.MaxPrice,
+			MinNotional: info.MinNotional,
+		}
+	}
+	return out, nil
+}
+
+// isFinalStatus 订单是否已终结
+func isFinalStatus(status string) bool {
+	switch status {
+	case "FILLED", "CANCELED", "EXPIRED", "REJECTED":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,21 @@
+package gateway
+
+import "context"
+
+// PlaceOrdersEach 逐个调用place下单（或改单），供没有批量接口的实现复用
+func PlaceOrdersEach(ctx context.Context, orders []*Order, place func(context.Context, *Order) (*Order, error)) []OrderResult {
+	results := make([]OrderResult, len(orders))
+	for i, o := range orders {
+		results[i].Order, results[i].Err = place(ctx, o)
+	}
+	return results
+}
+
+// CancelOrdersEach 逐个调用cancel撤单，供没有批量接口的实现复用
+func CancelOrdersEach(ctx context.Context, symbol string, clientOrderIDs []string, cancel func(context.Context, string, string) error) []error {
+	errs := make([]error, len(clientOrderIDs))
+	for i, id := range clientOrderIDs {
+		errs[i] = cancel(ctx, symbol, id)
+	}
+	return errs
+}
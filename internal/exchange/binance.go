@@ -15,6 +15,7 @@ type BinanceREST interface {
 // BinanceWS is一个极简抽象，供后续对接 binance ws 客户端。
 type BinanceWS interface {
 	SubscribeDepth(symbol string) error
+	SubscribeTrade(symbol string) error
 	SubscribeUserData(listenKey string) error
 	Run(handler WSHandler) error
 }
@@ -0,0 +1,190 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/exchange/fakebinance"
+)
+
+// newFakeBinanceAdapter 真实适配器（REST、combined stream、用户数据流、WSS交易API）连接本地模拟器
+func newFakeBinanceAdapter(t *testing.T, fake *fakebinance.Server) *BinanceAdapter {
+	t.Helper()
+	rest := &BinanceRESTClient{BaseURL: fake.URL(), APIKey: fake.APIKey(), Secret: fake.Secret(), HTTPClient: http.DefaultClient}
+	ws := NewBinanceWSReal()
+	ws.BaseEndpoint = fake.WSURL()
+	ws.RetryBackoff = 50 * time.Millisecond
+	adapter := NewBinanceAdapter(rest, ws)
+	adapter.SetTradeWSEndpoint(fake.TradeWSURL())
+	adapter.tradeWS.cfg.AckTimeout = 300 * time.Millisecond
+	return adapter
+}
+
+func TestBinanceAdapterAgainstFakeExchange(t *testing.T) {
+	fake := fakebinance.New(fakebinance.Options{})
+	defer fake.Close()
+	fake.SetBook("BTCUSDT", []fakebinance.Level{{Price: 100, Qty: 5}}, []fakebinance.Level{{Price: 101, Qty: 5}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	adapter := newFakeBinanceAdapter(t, fake)
+
+	var mu sync.Mutex
+	var bestBid float64
+	updates := make(map[string]*Order)
+	if err := adapter.StartDepthStream(ctx, []string{"BTCUSDT"}, func(d *Depth) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(d.Bids) > 0 {
+			bestBid = d.Bids[0].Price
+		}
+	}); err != nil {
+		t.Fatalf("depth stream: %v", err)
+	}
+	if err := adapter.StartUserStream(ctx, &UserStreamCallbacks{OnOrderUpdate: func(o *Order) {
+		mu.Lock()
+		defer mu.Unlock()
+		cp := *o
+		updates[o.ClientOrderID] = &cp
+	}}); err != nil {
+		t.Fatalf("user stream: %v", err)
+	}
+	if err := adapter.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer adapter.Disconnect()
+	waitFor(t, "streams", func() bool {
+		market, user, _ := fake.StreamCount()
+		return market == 1 && user == 1
+	})
+
+	// 深度增量经本地订单簿同步后推送
+	fake.SetBook("BTCUSDT", []fakebinance.Level{{Price: 100.5, Qty: 2}, {Price: 100, Qty: 5}}, []fakebinance.Level{{Price: 101, Qty: 5}})
+	waitFor(t, "depth update", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return bestBid == 100.5
+	})
+
+	// REST下单，外部成交后经用户数据流收到成交回报
+	placed, err := adapter.PlaceOrder(ctx, &Order{Symbol: "BTCUSDT", Side: "BUY", Type: "LIMIT", Price: 99.9, Quantity: 2})
+	if err != nil {
+		t.Fatalf("place: %v", err)
+	}
+	fake.Trade("BTCUSDT", "SELL", 99.9, 2)
+	waitFor(t, "fill", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		u := updates[placed.ClientOrderID]
+		return u != nil && u.Status == "FILLED"
+	})
+	mu.Lock()
+	fill := updates[placed.ClientOrderID]
+	mu.Unlock()
+	if !fill.IsMaker || fill.LastFilledPrice != 99.9 || fill.LastFilledQty != 2 {
+		t.Fatalf("unexpected fill %+v", fill)
+	}
+	pos, err := adapter.GetPosition(ctx, "BTCUSDT")
+	if err != nil || pos.Size != 2 {
+		t.Fatalf("position: %+v err=%v", pos, err)
+	}
+
+	// 交易所拒绝：Post Only 穿价与撤销未知订单
+	_, err = adapter.PlaceOrder(ctx, &Order{Symbol: "BTCUSDT", Side: "BUY", Type: "LIMIT", Price: 101, Quantity: 2})
+	if err == nil || !strings.Contains(err.Error(), "-5022") {
+		t.Fatalf("crossing post-only should be rejected with -5022, got %v", err)
+	}
+	var exErr *ExchangeError
+	if !errors.Is(err, ErrPostOnlyReject) || !errors.As(err, &exErr) || exErr.Status != 400 || exErr.Endpoint != "POST /fapi/v1/order" {
+		t.Fatalf("post-only reject should be a typed exchange error, got %#v", err)
+	}
+	if err := adapter.CancelOrder(ctx, "BTCUSDT", "missing"); !isUnknownOrder(err) {
+		t.Fatalf("cancel of unknown order should be -2011, got %v", err)
+	}
+}
+
+func TestBinanceAdapterFakeExchangeWSFallback(t *testing.T) {
+	fake := fakebinance.New(fakebinance.Options{})
+	defer fake.Close()
+	fake.SetBook("BTCUSDT", []fakebinance.Level{{Price: 100, Qty: 5}}, []fakebinance.Level{{Price: 101, Qty: 5}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	adapter := newFakeBinanceAdapter(t, fake)
+	adapter.SetOrderChannel(OrderChannelWSWithFallback)
+	if err := adapter.StartDepthStream(ctx, []string{"BTCUSDT"}, func(*Depth) {}); err != nil {
+		t.Fatalf("depth stream: %v", err)
+	}
+	if err := adapter.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	defer adapter.Disconnect()
+	waitFor(t, "trade ws", adapter.tradeWS.Healthy)
+
+	placed, err := adapter.PlaceOrder(ctx, &Order{Symbol: "BTCUSDT", Side: "BUY", Type: "LIMIT", Price: 99, Quantity: 2})
+	if err != nil {
+		t.Fatalf("ws place: %v", err)
+	}
+	if fake.Calls("order.place") != 1 || fake.Calls("POST /fapi/v1/order") != 0 {
+		t.Fatalf("order should go through the WS API")
+	}
+	if err := adapter.CancelOrder(ctx, "BTCUSDT", placed.ClientOrderID); err != nil {
+		t.Fatalf("ws cancel: %v", err)
+	}
+
+	// WSS请求无应答：使用同一ClientOrderID回退REST，交易所只保留一笔订单
+	fake.InjectFault(fakebinance.Fault{Op: "order.place", Drop: true, Times: 1})
+	placed, err = adapter.PlaceOrder(ctx, &Order{Symbol: "BTCUSDT", Side: "SELL", Type: "LIMIT", Price: 102, Quantity: 2})
+	if err != nil {
+		t.Fatalf("fallback place: %v", err)
+	}
+	if fake.Calls("POST /fapi/v1/order") != 1 {
+		t.Fatalf("dropped WS request should fall back to REST")
+	}
+	open := fake.OpenOrders("BTCUSDT")
+	if len(open) != 1 || open[0].ClientOrderID != placed.ClientOrderID {
+		t.Fatalf("expected the fallback order only, got %+v", open)
+	}
+}
+
+func TestBinanceAdapterSymbolFiltersFromFakeExchange(t *testing.T) {
+	fake := fakebinance.New(fakebinance.Options{})
+	defer fake.Close()
+	adapter := newFakeBinanceAdapter(t, fake)
+
+	got, err := adapter.SymbolFilters(context.Background(), []string{"BTCUSDT"})
+	if err != nil {
+		t.Fatalf("symbol filters: %v", err)
+	}
+	f, ok := got["BTCUSDT"]
+	if len(got) != 1 || !ok || f.TickSize != 0.1 || f.StepSize != 0.001 || f.MinNotional != 100 {
+		t.Fatalf("unexpected filters %+v", got)
+	}
+}
+
+func TestBinanceAdapterPlaceIOCAgainstFakeExchange(t *testing.T) {
+	fake := fakebinance.New(fakebinance.Options{})
+	defer fake.Close()
+	fake.SetBook("BTCUSDT", []fakebinance.Level{{Price: 100, Qty: 5}}, []fakebinance.Level{{Price: 101, Qty: 1}, {Price: 102, Qty: 5}})
+	adapter := newFakeBinanceAdapter(t, fake)
+
+	// 限价101只能吃到第一档，剩余部分立即撤销，不留挂单
+	placed, err := adapter.PlaceIOC(context.Background(), &Order{Symbol: "BTCUSDT", Side: "BUY", Type: "LIMIT", Price: 101, Quantity: 3})
+	if err != nil {
+		t.Fatalf("place IOC: %v", err)
+	}
+	if pos := fake.Position("BTCUSDT"); pos.Amount != 1 {
+		t.Fatalf("expected 1 filled at the limit, got position %+v", pos)
+	}
+	if open := fake.OpenOrders("BTCUSDT"); len(open) != 0 {
+		t.Fatalf("IOC remainder should not rest, got %+v", open)
+	}
+	if o, ok := fake.OrderByClientID(placed.ClientOrderID); !ok || o.TimeInForce != "IOC" {
+		t.Fatalf("unexpected order %+v", o)
+	}
+}
@@ -11,6 +11,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/metrics"
+	"github.com/rs/zerolog/log"
 )
 
 // BinanceRESTClient 一个可签名的简化客户端；默认不发起真实网络调用，HTTPClient 可注入 httptest。
@@ -73,18 +76,14 @@ func (c *BinanceRESTClient) PlaceLimit(symbol, side, tif string, price, qty floa
 	if clientID != "" {
 		params["newClientOrderId"] = clientID
 	}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/order?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodPost, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodPost, "/fapi/v1/order", params)
 	if err != nil {
 		return "", err
 	}
 	body, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return "", fmt.Errorf("place limit status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return "", newBinanceError(resp, body)
 	}
 	var pr placeResp
 	if err := json.Unmarshal(body, &pr); err != nil {
@@ -116,18 +115,14 @@ func (c *BinanceRESTClient) PlaceMarket(symbol, side string, qty float64, reduce
 	if clientID != "" {
 		params["newClientOrderId"] = clientID
 	}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/order?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodPost, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodPost, "/fapi/v1/order", params)
 	if err != nil {
 		return "", err
 	}
 	body, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return "", fmt.Errorf("place market status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return "", newBinanceError(resp, body)
 	}
 	var pr placeResp
 	if err := json.Unmarshal(body, &pr); err != nil {
@@ -147,18 +142,14 @@ func (c *BinanceRESTClient) SetDualPosition(enable bool) error {
 	params := map[string]string{
 		"dualSidePosition": strconv.FormatBool(enable),
 	}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/positionSide/dual?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodPost, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodPost, "/fapi/v1/positionSide/dual", params)
 	if err != nil {
 		return err
 	}
 	body, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("set position mode status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return newBinanceError(resp, body)
 	}
 	var pr dualPositionResp
 	if err := json.Unmarshal(body, &pr); err != nil {
@@ -176,18 +167,14 @@ func (c *BinanceRESTClient) GetDualPosition() (bool, error) {
 		return false, fmt.Errorf("http client not set")
 	}
 	params := map[string]string{}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/positionSide/dual?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodGet, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodGet, "/fapi/v1/positionSide/dual", params)
 	if err != nil {
 		return false, err
 	}
 	body, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return false, fmt.Errorf("get position mode status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return false, newBinanceError(resp, body)
 	}
 	var pr dualPositionResp
 	if err := json.Unmarshal(body, &pr); err != nil {
@@ -205,18 +192,14 @@ func (c *BinanceRESTClient) SetMarginType(symbol, marginType string) error {
 		"symbol":     symbol,
 		"marginType": strings.ToUpper(marginType),
 	}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/marginType?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodPost, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodPost, "/fapi/v1/marginType", params)
 	if err != nil {
 		return err
 	}
 	body, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("set margin type status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return newBinanceError(resp, body)
 	}
 	var mr marginTypeResp
 	if err := json.Unmarshal(body, &mr); err != nil {
@@ -237,18 +220,14 @@ func (c *BinanceRESTClient) SetLeverage(symbol string, leverage int) error {
 		"symbol":   symbol,
 		"leverage": strconv.Itoa(leverage),
 	}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/leverage?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodPost, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodPost, "/fapi/v1/leverage", params)
 	if err != nil {
 		return err
 	}
 	body, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("set leverage status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return newBinanceError(resp, body)
 	}
 	return nil
 }
@@ -272,7 +251,7 @@ func (c *BinanceRESTClient) GetBestBidAsk(symbol string, limit int) (float64, fl
 	body, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return 0, 0, fmt.Errorf("get depth status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return 0, 0, newBinanceError(resp, body)
 	}
 	var dr depthResp
 	if err := json.Unmarshal(body, &dr); err != nil {
@@ -285,6 +264,62 @@ func (c *BinanceRESTClient) GetBestBidAsk(symbol string, limit int) (float64, fl
 	return bestBid, bestAsk, nil
 }
 
+// DepthSnapshot 调用 /fapi/v1/depth 获取完整深度快照（含 lastUpdateId，用于本地订单簿同步）。
+func (c *BinanceRESTClient) DepthSnapshot(symbol string, limit int) (int64, []PriceLevel, []PriceLevel, error) {
+	if c == nil || c.HTTPClient == nil {
+		return 0, nil, nil, fmt.Errorf("http client not set")
+	}
+	if limit <= 0 {
+		limit = 1000
+	}
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("limit", strconv.Itoa(limit))
+	endpoint := c.BaseURL + "/fapi/v1/depth?" + params.Encode()
+	resp, err := c.sendWithRetry(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return 0, nil, nil, newBinanceError(resp, body)
+	}
+	var dr depthResp
+	if err := json.Unmarshal(body, &dr); err != nil {
+		return 0, nil, nil, err
+	}
+	return dr.LastUpdateID, parsePriceLevels(dr.Bids), parsePriceLevels(dr.Asks), nil
+}
+
+// PremiumIndex 调用 /fapi/v1/premiumIndex 获取标记价格、当前资金费率与下次结算时间（毫秒）。
+func (c *BinanceRESTClient) PremiumIndex(symbol string) (float64, float64, int64, error) {
+	if c == nil || c.HTTPClient == nil {
+		return 0, 0, 0, fmt.Errorf("http client not set")
+	}
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	endpoint := c.BaseURL + "/fapi/v1/premiumIndex?" + params.Encode()
+	resp, err := c.sendWithRetry(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return 0, 0, 0, newBinanceError(resp, body)
+	}
+	var pr struct {
+		MarkPrice       string `json:"markPrice"`
+		LastFundingRate string `json:"lastFundingRate"`
+		NextFundingTime int64  `json:"nextFundingTime"`
+	}
+	if err := json.Unmarshal(body, &pr); err != nil {
+		return 0, 0, 0, err
+	}
+	return parseFloat(pr.MarkPrice), parseFloat(pr.LastFundingRate), pr.NextFundingTime, nil
+}
+
 func parseDepthLevel(levels [][]string) float64 {
 	if len(levels) == 0 || len(levels[0]) == 0 {
 		return 0
@@ -320,18 +355,14 @@ func (c *BinanceRESTClient) CancelOrder(symbol, orderID string) error {
 		}
 	}
 
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/order?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodDelete, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodDelete, "/fapi/v1/order", params)
 	if err != nil {
 		return err
 	}
 	body, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("cancel status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return newBinanceError(resp, body)
 	}
 	return nil
 }
@@ -345,18 +376,14 @@ func (c *BinanceRESTClient) CancelOrderByClientID(symbol, clientOrderID string)
 		"symbol":            symbol,
 		"origClientOrderId": clientOrderID,
 	}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/order?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodDelete, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodDelete, "/fapi/v1/order", params)
 	if err != nil {
 		return err
 	}
 	body, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("cancel by client id status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return newBinanceError(resp, body)
 	}
 	return nil
 }
@@ -370,18 +397,14 @@ func (c *BinanceRESTClient) CancelOrderByOrderID(symbol string, orderID int64) e
 		"symbol":  symbol,
 		"orderId": strconv.FormatInt(orderID, 10),
 	}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/order?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodDelete, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodDelete, "/fapi/v1/order", params)
 	if err != nil {
 		return err
 	}
 	body, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("cancel by order id status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return newBinanceError(resp, body)
 	}
 	return nil
 }
@@ -397,22 +420,203 @@ func (c *BinanceRESTClient) CancelAll(symbol string) error {
 	params := map[string]string{
 		"symbol": symbol,
 	}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/allOpenOrders?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodDelete, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodDelete, "/fapi/v1/allOpenOrders", params)
 	if err != nil {
 		return err
 	}
 	body, _ := io.ReadAll(resp.Body)
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return fmt.Errorf("cancel all status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return newBinanceError(resp, body)
 	}
 	return nil
 }
 
+// Binance 批量接口单次请求的条目上限
+const (
+	MaxBatchPlaceOrders  = 5
+	MaxBatchCancelOrders = 10
+	MaxBatchAmendOrders  = 5
+)
+
+// BatchLimitOrder 批量下单/改单中的单个限价单（改单时ClientID为原订单的clientOrderId）
+type BatchLimitOrder struct {
+	Symbol   string
+	Side     string
+	Price    float64
+	Qty      float64
+	PostOnly bool
+	ClientID string
+}
+
+// BatchItemResult 批量接口中单个条目的结果，与请求按下标一一对应
+type BatchItemResult struct {
+	OrderID string
+	Err     error
+}
+
+// batchItemResp 批量接口返回数组中的元素：成功为订单对象，失败为 {code,msg}
+type batchItemResp struct {
+	OrderID json.Number `json:"orderId"`
+	Code    int         `json:"code"`
+	Msg     string      `json:"msg"`
+}
+
+// PlaceLimitBatch 调用 /fapi/v1/batchOrders 批量下限价单（单次最多5个）。
+// 请求整体失败时返回error；否则逐个返回结果，单个订单失败不影响其他订单。
+func (c *BinanceRESTClient) PlaceLimitBatch(orders []BatchLimitOrder) ([]BatchItemResult, error) {
+	if c == nil || c.HTTPClient == nil {
+		return nil, fmt.Errorf("http client not set")
+	}
+	if len(orders) == 0 || len(orders) > MaxBatchPlaceOrders {
+		return nil, fmt.Errorf("batch size must be 1-%d, got %d", MaxBatchPlaceOrders, len(orders))
+	}
+	items := make([]map[string]string, 0, len(orders))
+	for _, o := range orders {
+		item := map[string]string{
+			"symbol":      o.Symbol,
+			"side":        o.Side,
+			"type":        "LIMIT",
+			"price":       strconv.FormatFloat(o.Price, 'f', -1, 64),
+			"quantity":    strconv.FormatFloat(o.Qty, 'f', -1, 64),
+			"timeInForce": "GTC",
+		}
+		if o.PostOnly {
+			item["timeInForce"] = "GTX"
+		}
+		if o.ClientID != "" {
+			item["newClientOrderId"] = o.ClientID
+		}
+		items = append(items, item)
+	}
+	payload, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]string{"batchOrders": string(payload)}
+	resp, err := c.sendSigned(http.MethodPost, "/fapi/v1/batchOrders", params)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, newBinanceError(resp, body)
+	}
+	return parseBatchResults(body, len(orders))
+}
+
+// CancelBatch 调用 DELETE /fapi/v1/batchOrders 按clientOrderId批量撤单（单次最多10个）。
+func (c *BinanceRESTClient) CancelBatch(symbol string, clientOrderIDs []string) ([]BatchItemResult, error) {
+	if c == nil || c.HTTPClient == nil {
+		return nil, fmt.Errorf("http client not set")
+	}
+	if len(clientOrderIDs) == 0 || len(clientOrderIDs) > MaxBatchCancelOrders {
+		return nil, fmt.Errorf("batch size must be 1-%d, got %d", MaxBatchCancelOrders, len(clientOrderIDs))
+	}
+	ids, err := json.Marshal(clientOrderIDs)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]string{
+		"symbol":                symbol,
+		"origClientOrderIdList": string(ids),
+	}
+	resp, err := c.sendSigned(http.MethodDelete, "/fapi/v1/batchOrders", params)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, newBinanceError(resp, body)
+	}
+	return parseBatchResults(body, len(clientOrderIDs))
+}
+
+// AmendLimit 调用 PUT /fapi/v1/order 按clientOrderId修改限价单的价格与数量，保留原ClientOrderID。
+func (c *BinanceRESTClient) AmendLimit(symbol, side, clientID string, price, qty float64) (string, error) {
+	if c == nil || c.HTTPClient == nil {
+		return "", fmt.Errorf("http client not set")
+	}
+	params := map[string]string{
+		"symbol":            symbol,
+		"side":              side,
+		"origClientOrderId": clientID,
+		"price":             strconv.FormatFloat(price, 'f', -1, 64),
+		"quantity":          strconv.FormatFloat(qty, 'f', -1, 64),
+	}
+	resp, err := c.sendSigned(http.MethodPut, "/fapi/v1/order", params)
+	if err != nil {
+		return "", err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return "", newBinanceError(resp, body)
+	}
+	var pr placeResp
+	if err := json.Unmarshal(body, &pr); err != nil {
+		return "", err
+	}
+	return pr.OrderID.String(), nil
+}
+
+// AmendLimitBatch 调用 PUT /fapi/v1/batchOrders 批量改单（单次最多5个），结果与请求按下标一一对应。
+func (c *BinanceRESTClient) AmendLimitBatch(orders []BatchLimitOrder) ([]BatchItemResult, error) {
+	if c == nil || c.HTTPClient == nil {
+		return nil, fmt.Errorf("http client not set")
+	}
+	if len(orders) == 0 || len(orders) > MaxBatchAmendOrders {
+		return nil, fmt.Errorf("batch size must be 1-%d, got %d", MaxBatchAmendOrders, len(orders))
+	}
+	items := make([]map[string]string, 0, len(orders))
+	for _, o := range orders {
+		items = append(items, map[string]string{
+			"symbol":            o.Symbol,
+			"side":              o.Side,
+			"origClientOrderId": o.ClientID,
+			"price":             strconv.FormatFloat(o.Price, 'f', -1, 64),
+			"quantity":          strconv.FormatFloat(o.Qty, 'f', -1, 64),
+		})
+	}
+	payload, err := json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	params := map[string]string{"batchOrders": string(payload)}
+	resp, err := c.sendSigned(http.MethodPut, "/fapi/v1/batchOrders", params)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := io.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, newBinanceError(resp, body)
+	}
+	return parseBatchResults(body, len(orders))
+}
+
+// parseBatchResults 解析批量接口的逐条结果；条目错误保留交易所错误码（如-5022、-2011）
+func parseBatchResults(body []byte, n int) ([]BatchItemResult, error) {
+	var raw []batchItemResp
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("decode batch response: %w", err)
+	}
+	if len(raw) != n {
+		return nil, fmt.Errorf("batch response has %d items, expected %d", len(raw), n)
+	}
+	results := make([]BatchItemResult, n)
+	for i, item := range raw {
+		if item.Code != 0 && item.Code != 200 {
+			results[i].Err = &ExchangeError{Code: item.Code, Message: fmt.Sprintf("batch item error %d: %s", item.Code, item.Msg)}
+			continue
+		}
+		results[i].OrderID = item.OrderID.String()
+	}
+	return results, nil
+}
+
 // OpenOrders 查询当前账户的活跃订单列表
 func (c *BinanceRESTClient) OpenOrders(symbol string) ([]FuturesOpenOrder, error) {
 	if c == nil || c.HTTPClient == nil {
@@ -422,18 +626,14 @@ func (c *BinanceRESTClient) OpenOrders(symbol string) ([]FuturesOpenOrder, error
 	if symbol != "" {
 		params["symbol"] = strings.ToUpper(symbol)
 	}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/openOrders?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodGet, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodGet, "/fapi/v1/openOrders", params)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("open orders status %d: %s", resp.StatusCode, bytes.TrimSpace(body))
+		return nil, newBinanceError(resp, body)
 	}
 	var raw []struct {
 		Symbol        string `json:"symbol"`
@@ -480,6 +680,55 @@ func (c *BinanceRESTClient) OpenOrders(symbol string) ([]FuturesOpenOrder, error
 	return out, nil
 }
 
+// QueryOrder 调用 /fapi/v1/order 按客户端订单ID查询订单（含已终结订单）
+func (c *BinanceRESTClient) QueryOrder(symbol, clientOrderID string) (FuturesOpenOrder, error) {
+	if c == nil || c.HTTPClient == nil {
+		return FuturesOpenOrder{}, fmt.Errorf("http client not set")
+	}
+	params := map[string]string{
+		"symbol":            strings.ToUpper(symbol),
+		"origClientOrderId": clientOrderID,
+	}
+	resp, err := c.sendSigned(http.MethodGet, "/fapi/v1/order", params)
+	if err != nil {
+		return FuturesOpenOrder{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return FuturesOpenOrder{}, newBinanceError(resp, body)
+	}
+	var r struct {
+		Symbol        string `json:"symbol"`
+		OrderID       int64  `json:"orderId"`
+		ClientOrderID string `json:"clientOrderId"`
+		Price         string `json:"price"`
+		AvgPrice      string `json:"avgPrice"`
+		OrigQty       string `json:"origQty"`
+		ExecutedQty   string `json:"executedQty"`
+		Status        string `json:"status"`
+		Side          string `json:"side"`
+		Type          string `json:"type"`
+		UpdateTime    int64  `json:"updateTime"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return FuturesOpenOrder{}, err
+	}
+	return FuturesOpenOrder{
+		Symbol:        r.Symbol,
+		Side:          r.Side,
+		OrderType:     r.Type,
+		Status:        r.Status,
+		Price:         parseFloat(r.Price),
+		AvgPrice:      parseFloat(r.AvgPrice),
+		OrigQty:       parseFloat(r.OrigQty),
+		ExecutedQty:   parseFloat(r.ExecutedQty),
+		OrderID:       r.OrderID,
+		ClientOrderID: r.ClientOrderID,
+		UpdateTime:    r.UpdateTime,
+	}, nil
+}
+
 // NewDefaultHTTPClient 提供一个带超时的 http.Client。
 func NewDefaultHTTPClient() *http.Client {
 	return &http.Client{Timeout: 10 * time.Second}
@@ -538,6 +787,7 @@ type FuturesOpenOrder struct {
 	OrderType     string
 	Status        string
 	Price         float64
+	AvgPrice      float64 // 成交均价（仅QueryOrder返回）
 	OrigQty       float64
 	ExecutedQty   float64
 	OrderID       int64
@@ -583,17 +833,14 @@ func (c *BinanceRESTClient) AccountBalances() ([]FuturesBalance, error) {
 		return nil, fmt.Errorf("http client not set")
 	}
 	params := map[string]string{}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v2/balance?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodGet, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodGet, "/fapi/v2/balance", params)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("balance status %d", resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newBinanceError(resp, body)
 	}
 	var raw []struct {
 		Asset            string `json:"asset"`
@@ -629,17 +876,14 @@ func (c *BinanceRESTClient) AccountInfo() (FuturesAccount, error) {
 		return result, fmt.Errorf("http client not set")
 	}
 	params := map[string]string{}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v2/account?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodGet, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodGet, "/fapi/v2/account", params)
 	if err != nil {
 		return result, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return result, fmt.Errorf("account status %d", resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		return result, newBinanceError(resp, body)
 	}
 	var raw struct {
 		TotalWalletBalance    string `json:"totalWalletBalance"`
@@ -736,17 +980,14 @@ func (c *BinanceRESTClient) PositionRisk(symbol string) ([]FuturesPosition, erro
 	if symbol != "" {
 		params["symbol"] = symbol
 	}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v2/positionRisk?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodGet, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodGet, "/fapi/v2/positionRisk", params)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("position status %d", resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newBinanceError(resp, body)
 	}
 	var raw []struct {
 		Symbol           string `json:"symbol"`
@@ -800,17 +1041,14 @@ func (c *BinanceRESTClient) LeverageBrackets(symbol string) ([]LeverageBracket,
 	if symbol != "" {
 		params["symbol"] = symbol
 	}
-	c.applyRecvWindow(params)
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/leverageBracket?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodGet, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodGet, "/fapi/v1/leverageBracket", params)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("leverage bracket status %d", resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newBinanceError(resp, body)
 	}
 	var raw []struct {
 		Symbol   string `json:"symbol"`
@@ -851,16 +1089,14 @@ func (c *BinanceRESTClient) ExchangeInfo(symbol string) ([]ExchangeSymbolInfo, e
 	if symbol != "" {
 		params["symbol"] = symbol
 	}
-	query, sig := SignParams(params, c.Secret)
-	endpoint := c.BaseURL + "/fapi/v1/exchangeInfo?" + query + "&signature=" + url.QueryEscape(sig)
-	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
-	resp, err := c.sendWithRetry(http.MethodGet, endpoint, headers)
+	resp, err := c.sendSigned(http.MethodGet, "/fapi/v1/exchangeInfo", params)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("exchangeInfo status %d", resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		return nil, newBinanceError(resp, body)
 	}
 	var raw struct {
 		Symbols []struct {
@@ -925,9 +1161,68 @@ func (c *BinanceRESTClient) applyRecvWindow(params map[string]string) {
 	}
 }
 
-func (c *BinanceRESTClient) waitLimit() {
-	if c != nil && c.Limiter != nil {
-		c.Limiter.Wait()
+// timestamp 签名时间戳：优先取共享 TimeSync 的服务器时间
+func (c *BinanceRESTClient) timestamp() int64 {
+	if c.TimeSync != nil {
+		return c.TimeSync.GetServerTime()
+	}
+	if globalTimeSync != nil {
+		return globalTimeSync.GetServerTime()
+	}
+	return timeNowMillis()
+}
+
+// sendSigned 签名并发送请求；遇到 -1021（时间戳超出recvWindow）立即重新同步时间，
+// 以新时间戳重新签名后重试一次
+func (c *BinanceRESTClient) sendSigned(method, path string, params map[string]string) (*http.Response, error) {
+	c.applyRecvWindow(params)
+	headers := map[string]string{"X-MBX-APIKEY": c.APIKey}
+	for attempt := 0; ; attempt++ {
+		signedAt := time.Now()
+		params["timestamp"] = strconv.FormatInt(c.timestamp(), 10)
+		query, sig := SignParams(params, c.Secret)
+		endpoint := c.BaseURL + path + "?" + query + "&signature=" + url.QueryEscape(sig)
+		resp, err := c.sendWithRetry(method, endpoint, headers)
+		if err != nil || resp.StatusCode < 400 {
+			return resp, err
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		if !errors.Is(newBinanceError(resp, body), ErrTimestamp) {
+			return resp, nil
+		}
+		metrics.RecordTimestampRejection("rest")
+		if attempt > 0 || c.TimeSync == nil {
+			return resp, nil
+		}
+		if err := c.TimeSync.resync(signedAt); err != nil {
+			log.Warn().Err(err).Str("path", path).Msg("时间戳超出recvWindow，重新同步服务器时间失败")
+			return resp, nil
+		}
+		log.Warn().Str("path", path).Int64("offset_ms", c.TimeSync.GetOffset()).Msg("时间戳超出recvWindow，已重新同步服务器时间并重试")
+	}
+}
+
+// waitLimit 请求前限速：权重限速器按端点开销预占额度，其余限速器按次计数
+func (c *BinanceRESTClient) waitLimit(method, endpoint string) {
+	if c == nil || c.Limiter == nil {
+		return
+	}
+	if wl, ok := c.Limiter.(WeightedRateLimiter); ok {
+		wl.Acquire(BinanceRequestCost(method, endpoint))
+		return
+	}
+	c.Limiter.Wait()
+}
+
+// observeLimit 用响应头与状态码校准权重限速器
+func (c *BinanceRESTClient) observeLimit(resp *http.Response) {
+	if c == nil || c.Limiter == nil {
+		return
+	}
+	if wl, ok := c.Limiter.(WeightedRateLimiter); ok {
+		wl.Observe(resp.StatusCode, resp.Header)
 	}
 }
 
@@ -946,14 +1241,16 @@ func (c *BinanceRESTClient) sendWithRetry(method, endpoint string, headers map[s
 		for k, v := range headers {
 			req.Header.Set(k, v)
 		}
-		c.waitLimit()
+		c.waitLimit(method, endpoint)
 		resp, err := c.HTTPClient.Do(req)
 		if err != nil {
 			lastErr = err
 		} else {
+			c.observeLimit(resp)
 			if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == 418 {
-				lastErr = fmt.Errorf("status %d", resp.StatusCode)
+				body, _ := io.ReadAll(resp.Body)
 				resp.Body.Close()
+				lastErr = newBinanceError(resp, body)
 			} else {
 				return resp, nil
 			}
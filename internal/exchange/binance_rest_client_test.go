@@ -48,6 +48,112 @@ func TestBinanceRESTClientPlaceCancel(t *testing.T) {
 	}
 }
 
+func TestBinanceRESTClientBatchOrders(t *testing.T) {
+	timeNowMillis = func() int64 { return 1234567890000 }
+	defer func() { timeNowMillis = func() int64 { return time.Now().UnixMilli() } }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/fapi/v1/batchOrders" {
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+		switch r.Method {
+		case http.MethodPost:
+			if !strings.Contains(r.URL.Query().Get("batchOrders"), `"timeInForce":"GTX"`) {
+				t.Fatalf("expected post-only items, got %s", r.URL.Query().Get("batchOrders"))
+			}
+			io.WriteString(w, `[{"orderId":1001},{"code":-5022,"msg":"Due to the order could not be executed as maker, the Post Only order will be rejected."}]`)
+		case http.MethodDelete:
+			if r.URL.Query().Get("origClientOrderIdList") != `["a","b"]` {
+				t.Fatalf("unexpected id list %s", r.URL.Query().Get("origClientOrderIdList"))
+			}
+			io.WriteString(w, `[{"code":-2011,"msg":"Unknown order sent."},{"orderId":1002}]`)
+		default:
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+	}))
+	defer ts.Close()
+
+	cli := &BinanceRESTClient{
+		BaseURL:    ts.URL,
+		APIKey:     "key",
+		Secret:     "secret",
+		HTTPClient: ts.Client(),
+		Limiter:    &mockLimiter{},
+	}
+	placed, err := cli.PlaceLimitBatch([]BatchLimitOrder{
+		{Symbol: "BTCUSDT", Side: "BUY", Price: 100, Qty: 1, PostOnly: true, ClientID: "a"},
+		{Symbol: "BTCUSDT", Side: "SELL", Price: 99, Qty: 1, PostOnly: true, ClientID: "b"},
+	})
+	if err != nil {
+		t.Fatalf("batch place err: %v", err)
+	}
+	if placed[0].Err != nil || placed[0].OrderID != "1001" {
+		t.Fatalf("unexpected first item %+v", placed[0])
+	}
+	if placed[1].Err == nil || !strings.Contains(placed[1].Err.Error(), "-5022") {
+		t.Fatalf("expected -5022 on second item, got %v", placed[1].Err)
+	}
+
+	canceled, err := cli.CancelBatch("BTCUSDT", []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("batch cancel err: %v", err)
+	}
+	if canceled[0].Err == nil || !strings.Contains(canceled[0].Err.Error(), "-2011") || canceled[1].Err != nil {
+		t.Fatalf("unexpected cancel results %+v", canceled)
+	}
+
+	if _, err := cli.PlaceLimitBatch(make([]BatchLimitOrder, MaxBatchPlaceOrders+1)); err == nil {
+		t.Fatalf("expected error for oversized batch")
+	}
+}
+
+func TestBinanceRESTClientAmend(t *testing.T) {
+	timeNowMillis = func() int64 { return 1234567890000 }
+	defer func() { timeNowMillis = func() int64 { return time.Now().UnixMilli() } }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Fatalf("unexpected method %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/fapi/v1/order":
+			q := r.URL.Query()
+			if q.Get("origClientOrderId") != "cid" || q.Get("price") != "100.5" || q.Get("side") != "BUY" {
+				t.Fatalf("unexpected amend params %s", r.URL.RawQuery)
+			}
+			io.WriteString(w, `{"orderId":1001}`)
+		case "/fapi/v1/batchOrders":
+			io.WriteString(w, `[{"orderId":1001},{"code":-2013,"msg":"Order does not exist."}]`)
+		default:
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	cli := &BinanceRESTClient{
+		BaseURL:    ts.URL,
+		APIKey:     "key",
+		Secret:     "secret",
+		HTTPClient: ts.Client(),
+		Limiter:    &mockLimiter{},
+	}
+	id, err := cli.AmendLimit("BTCUSDT", "BUY", "cid", 100.5, 1)
+	if err != nil || id != "1001" {
+		t.Fatalf("amend: id=%s err=%v", id, err)
+	}
+
+	items, err := cli.AmendLimitBatch([]BatchLimitOrder{
+		{Symbol: "BTCUSDT", Side: "BUY", Price: 100.5, Qty: 1, ClientID: "a"},
+		{Symbol: "BTCUSDT", Side: "SELL", Price: 101.5, Qty: 1, ClientID: "b"},
+	})
+	if err != nil {
+		t.Fatalf("batch amend err: %v", err)
+	}
+	if items[0].Err != nil || items[1].Err == nil || !strings.Contains(items[1].Err.Error(), "-2013") {
+		t.Fatalf("unexpected amend results %+v", items)
+	}
+}
+
 func TestBinanceRESTClientAccountBalances(t *testing.T) {
 	timeNowMillis = func() int64 { return 1234567890000 }
 	defer func() { timeNowMillis = func() int64 { return time.Now().UnixMilli() } }()
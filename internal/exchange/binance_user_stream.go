@@ -0,0 +1,221 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultListenKeyKeepAlive = 30 * time.Minute
+	defaultUserStreamTimeout  = 10 * time.Minute // 服务端每3分钟发送ping
+	defaultUserStreamBackoff  = time.Second
+	maxUserStreamBackoff      = 30 * time.Second
+)
+
+// errListenKeyExpired 收到listenKeyExpired事件，需要重建listenKey
+var errListenKeyExpired = errors.New("listenKey expired")
+
+// ListenKeyService listenKey管理接口，由ListenKeyClient实现
+type ListenKeyService interface {
+	NewListenKey() (string, error)
+	KeepAlive(listenKey string) error
+	CloseListenKey(listenKey string) error
+}
+
+// UserDataStream 用户数据流：使用独立的 /ws/{listenKey} 连接，负责listenKey的创建、
+// 定时续期与过期重建，断线后自动重连。重连成功后调用OnReconnect供上层通过REST对账
+type UserDataStream struct {
+	BaseEndpoint   string // 默认 wss://fstream.binance.com
+	ListenKeys     ListenKeyService
+	Dialer         *websocket.Dialer
+	KeepAliveEvery time.Duration // listenKey续期间隔（默认30分钟）
+	ReadTimeout    time.Duration // 读超时（默认10分钟）
+	RetryBackoff   time.Duration // 重连初始退避（默认1秒，指数增长至30秒）
+
+	OnMessage   func([]byte)
+	OnReconnect func()
+
+	mu        sync.RWMutex
+	listenKey string
+}
+
+// Start 同步创建listenKey（失败时返回错误），随后在后台维持连接直到ctx取消；
+// ctx取消时关闭listenKey
+func (s *UserDataStream) Start(ctx context.Context) error {
+	if s.ListenKeys == nil {
+		return fmt.Errorf("listenKey service not set")
+	}
+	key, err := s.ListenKeys.NewListenKey()
+	if err != nil {
+		return fmt.Errorf("创建listenKey失败: %w", err)
+	}
+	go s.run(ctx, key)
+	return nil
+}
+
+// ListenKey 当前使用的listenKey
+func (s *UserDataStream) ListenKey() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.listenKey
+}
+
+func (s *UserDataStream) run(ctx context.Context, key string) {
+	delay := s.backoff()
+	reconnect := false
+	for {
+		s.mu.Lock()
+		s.listenKey = key
+		s.mu.Unlock()
+
+		connected, err := s.session(ctx, key, reconnect)
+		if ctx.Err() != nil {
+			s.stop(key)
+			return
+		}
+		// 之后的每次连接都可能错过了回报，均需对账
+		reconnect = true
+		if connected {
+			delay = s.backoff()
+		}
+		log.Warn().Err(err).Dur("retry_in", delay).Msg("用户数据流中断，准备重连")
+
+		select {
+		case <-ctx.Done():
+			s.stop(key)
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxUserStreamBackoff {
+			delay = maxUserStreamBackoff
+		}
+
+		// listenKey仍有效时交易所返回同一个key并延长有效期，过期时返回新key
+		newKey, err := s.ListenKeys.NewListenKey()
+		if err != nil {
+			log.Error().Err(err).Msg("重建listenKey失败")
+			continue
+		}
+		if newKey != key {
+			log.Info().Msg("listenKey已重建")
+		}
+		key = newKey
+	}
+}
+
+func (s *UserDataStream) stop(key string) {
+	if err := s.ListenKeys.CloseListenKey(key); err != nil {
+		log.Warn().Err(err).Msg("关闭listenKey失败")
+	}
+	log.Info().Msg("用户数据流已停止")
+}
+
+// session 建立一次连接并读取直到出错，返回是否曾连接成功
+func (s *UserDataStream) session(ctx context.Context, key string, reconnect bool) (bool, error) {
+	dialer := s.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	endpoint := s.BaseEndpoint
+	if endpoint == "" {
+		endpoint = BinanceFuturesWSEndpoint
+	}
+	conn, _, err := dialer.DialContext(ctx, strings.TrimSuffix(endpoint, "/")+"/ws/"+key, nil)
+	if err != nil {
+		return false, fmt.Errorf("连接用户数据流失败: %w", err)
+	}
+	defer conn.Close()
+	log.Info().Bool("reconnect", reconnect).Msg("用户数据流已连接")
+
+	// 断线期间的回报已丢失：在读取新消息前完成对账，避免与推送并发
+	if reconnect && s.OnReconnect != nil {
+		s.OnReconnect()
+	}
+
+	timeout := s.ReadTimeout
+	if timeout <= 0 {
+		timeout = defaultUserStreamTimeout
+	}
+	resetDeadline := func() {
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+	conn.SetPingHandler(func(data string) error {
+		resetDeadline()
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(10*time.Second))
+	})
+
+	// 续期失败或ctx取消时关闭连接，使读循环退出
+	var keepAliveErr error
+	var errMu sync.Mutex
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		every := s.KeepAliveEvery
+		if every <= 0 {
+			every = defaultListenKeyKeepAlive
+		}
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-ticker.C:
+				if err := s.ListenKeys.KeepAlive(key); err != nil {
+					errMu.Lock()
+					keepAliveErr = fmt.Errorf("listenKey续期失败: %w", err)
+					errMu.Unlock()
+					conn.Close()
+					return
+				}
+				log.Debug().Msg("listenKey已续期")
+			}
+		}
+	}()
+
+	for {
+		resetDeadline()
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			errMu.Lock()
+			defer errMu.Unlock()
+			if keepAliveErr != nil {
+				return true, keepAliveErr
+			}
+			return true, err
+		}
+		if isListenKeyExpired(msg) {
+			return true, errListenKeyExpired
+		}
+		if s.OnMessage != nil {
+			s.OnMessage(msg)
+		}
+	}
+}
+
+func (s *UserDataStream) backoff() time.Duration {
+	if s.RetryBackoff > 0 {
+		return s.RetryBackoff
+	}
+	return defaultUserStreamBackoff
+}
+
+func isListenKeyExpired(msg []byte) bool {
+	if !bytes.Contains(msg, []byte("listenKeyExpired")) {
+		return false
+	}
+	ev, err := ParseUserData(msg)
+	return err == nil && ev.EventType == "listenKeyExpired"
+}
@@ -0,0 +1,200 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type fakeListenKeys struct {
+	mu      sync.Mutex
+	created int
+	renewed int
+	closed  []string
+}
+
+func (f *fakeListenKeys) NewListenKey() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created++
+	return fmt.Sprintf("lk-%d", f.created), nil
+}
+
+func (f *fakeListenKeys) KeepAlive(listenKey string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.renewed++
+	return nil
+}
+
+func (f *fakeListenKeys) CloseListenKey(listenKey string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = append(f.closed, listenKey)
+	return nil
+}
+
+func (f *fakeListenKeys) counts() (created, renewed int, closed []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.created, f.renewed, append([]string(nil), f.closed...)
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestUserDataStream_ExpiryAndReconnect(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		first := len(paths) == 1
+		mu.Unlock()
+
+		if first {
+			// 第一个连接：推送一条订单回报后通知listenKey过期
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"e":"ORDER_TRADE_UPDATE","o":{"s":"ETHUSDC","c":"cid-1","X":"NEW"}}`))
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"e":"listenKeyExpired","E":1}`))
+		} else {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"e":"ORDER_TRADE_UPDATE","o":{"s":"ETHUSDC","c":"cid-2","X":"NEW"}}`))
+		}
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}))
+	defer srv.Close()
+
+	keys := &fakeListenKeys{}
+	var msgMu sync.Mutex
+	var msgs []string
+	reconnects := 0
+	stream := &UserDataStream{
+		BaseEndpoint:   "ws" + strings.TrimPrefix(srv.URL, "http"),
+		ListenKeys:     keys,
+		KeepAliveEvery: 20 * time.Millisecond,
+		RetryBackoff:   10 * time.Millisecond,
+		OnMessage: func(b []byte) {
+			msgMu.Lock()
+			msgs = append(msgs, string(b))
+			msgMu.Unlock()
+		},
+		OnReconnect: func() {
+			msgMu.Lock()
+			reconnects++
+			msgMu.Unlock()
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := stream.Start(ctx); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	waitFor(t, "second connection", func() bool {
+		msgMu.Lock()
+		defer msgMu.Unlock()
+		return len(msgs) >= 2
+	})
+	msgMu.Lock()
+	if reconnects != 1 || strings.Contains(strings.Join(msgs, ""), "listenKeyExpired") {
+		t.Errorf("expected one reconcile and expiry not forwarded, got reconnects=%d msgs=%v", reconnects, msgs)
+	}
+	msgMu.Unlock()
+
+	mu.Lock()
+	if len(paths) < 2 || paths[0] != "/ws/lk-1" || paths[1] != "/ws/lk-2" {
+		t.Errorf("expected reconnect with a fresh listenKey, got %v", paths)
+	}
+	mu.Unlock()
+	if stream.ListenKey() != "lk-2" {
+		t.Errorf("expected current listenKey lk-2, got %s", stream.ListenKey())
+	}
+
+	waitFor(t, "keepalive", func() bool {
+		_, renewed, _ := keys.counts()
+		return renewed > 0
+	})
+
+	cancel()
+	waitFor(t, "listenKey closed", func() bool {
+		_, _, closed := keys.counts()
+		return len(closed) == 1 && closed[0] == "lk-2"
+	})
+}
+
+func TestBinanceAdapter_ReconcileAfterGap(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/fapi/v1/openOrders":
+			// o2仍在挂单，断线期间部分成交0.4
+			w.Write([]byte(`[{"symbol":"ETHUSDC","orderId":2,"clientOrderId":"o2","price":"3010","origQty":"1","executedQty":"0.4","status":"PARTIALLY_FILLED","side":"SELL","type":"LIMIT"}]`))
+		case r.URL.Path == "/fapi/v1/order" && r.URL.Query().Get("origClientOrderId") == "o1":
+			w.Write([]byte(`{"symbol":"ETHUSDC","orderId":1,"clientOrderId":"o1","price":"2990","avgPrice":"2990","origQty":"1","executedQty":"1","status":"FILLED","side":"BUY","type":"LIMIT"}`))
+		case r.URL.Path == "/fapi/v2/positionRisk":
+			w.Write([]byte(`[{"symbol":"ETHUSDC","positionAmt":"0.6","entryPrice":"2990","markPrice":"3000","unRealizedProfit":"6","marginType":"cross","positionSide":"BOTH"},{"symbol":"BTCUSDC","positionAmt":"0","entryPrice":"0","markPrice":"60000","unRealizedProfit":"0","marginType":"cross","positionSide":"BOTH"}]`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.String())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	rest := &BinanceRESTClient{BaseURL: ts.URL, APIKey: "k", Secret: "s", HTTPClient: ts.Client()}
+	adapter := NewBinanceAdapter(rest, &BinanceWSStub{})
+	adapter.orders["o1"] = &Order{Symbol: "ETHUSDC", Side: "BUY", Price: 2990, Quantity: 1, ClientOrderID: "o1", Status: "NEW"}
+	adapter.orders["o2"] = &Order{Symbol: "ETHUSDC", Side: "SELL", Price: 3010, Quantity: 1, ClientOrderID: "o2", Status: "NEW"}
+
+	var updates []*Order
+	var positions []*Position
+	adapter.userCallbacks = &UserStreamCallbacks{
+		OnOrderUpdate:   func(o *Order) { updates = append(updates, o) },
+		OnAccountUpdate: func(p []*Position) { positions = append(positions, p...) },
+	}
+
+	adapter.reconcileAfterGap()
+
+	byID := map[string]*Order{}
+	for _, o := range updates {
+		byID[o.ClientOrderID] = o
+	}
+	if o := byID["o1"]; o == nil || o.Status != "FILLED" || o.LastFilledQty != 1 || o.LastFilledPrice != 2990 {
+		t.Errorf("expected o1 recovered as FILLED 1@2990, got %+v", o)
+	}
+	if o := byID["o2"]; o == nil || o.Status != "PARTIALLY_FILLED" || o.LastFilledQty != 0.4 {
+		t.Errorf("expected o2 missed partial fill 0.4, got %+v", o)
+	}
+	if len(positions) != 1 || positions[0].Symbol != "ETHUSDC" || positions[0].Size != 0.6 || positions[0].Notional != 1800 {
+		t.Errorf("expected only the open ETHUSDC position, got %+v", positions)
+	}
+
+	// 重连后到达的同一终态回报被丢弃
+	handler := &adapterWSHandler{adapter: adapter}
+	handler.HandleOrderUpdate(&Order{Symbol: "ETHUSDC", ClientOrderID: "o1", Status: "FILLED", FilledQty: 1})
+	if len(updates) != 2 {
+		t.Errorf("expected duplicate terminal update dropped, got %d updates", len(updates))
+	}
+}
@@ -4,6 +4,7 @@ package gateway
 // Run 不会连接网络；调用时可注入 handler 以模拟推送。
 type BinanceWSStub struct {
 	depthSym   []string
+	tradeSym   []string
 	userListen string
 }
 
@@ -12,6 +13,11 @@ func (b *BinanceWSStub) SubscribeDepth(symbol string) error {
 	return nil
 }
 
+func (b *BinanceWSStub) SubscribeTrade(symbol string) error {
+	b.tradeSym = append(b.tradeSym, symbol)
+	return nil
+}
+
 func (b *BinanceWSStub) SubscribeUserData(listenKey string) error {
 	b.userListen = listenKey
 	return nil
@@ -21,7 +27,9 @@ func (b *BinanceWSStub) SubscribeUserData(listenKey string) error {
 func (b *BinanceWSStub) Run(handler WSHandler) error {
 	if handler != nil && len(b.depthSym) > 0 {
 		handler.OnDepth(b.depthSym[0], 100, 101)
-		handler.OnTrade(b.depthSym[0], 100, 1)
+	}
+	if handler != nil && len(b.tradeSym) > 0 {
+		handler.OnTrade(b.tradeSym[0], 100, 1)
 	}
 	return nil
 }
@@ -3,8 +3,10 @@ package gateway
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // CombinedMessage 对应 binance combined stream 包装。
@@ -16,6 +18,9 @@ type CombinedMessage struct {
 // ErrNonUserData 表示该 WS 消息不是用户数据流事件，应由调用方静默忽略。
 var ErrNonUserData = errors.New("ws message is not user data")
 
+// ErrNonAggTrade 表示该 WS 消息不是 aggTrade 事件。
+var ErrNonAggTrade = errors.New("ws message is not aggTrade")
+
 // DepthUpdate 提取 depth@100ms 消息的核心字段。
 type DepthUpdate struct {
 	EventType interface{}   `json:"e"`
@@ -48,6 +53,7 @@ type OrderUpdate struct {
 	RealizedPnL      float64
 	CommissionAsset  string
 	CommissionAmount float64
+	IsMaker          bool
 	PositionSide     string
 	EventTime        int64
 	UpdateTime       int64
@@ -64,6 +70,7 @@ type AccountBalance struct {
 	Asset         string
 	WalletBalance float64
 	CrossWallet   float64
+	BalanceChange float64 // 除盈亏与手续费外的余额变化（如资金费）
 }
 
 type AccountPosition struct {
@@ -97,6 +104,213 @@ func ParseCombinedDepth(raw []byte) (symbol string, bestBid, bestAsk float64, er
 	return
 }
 
+// ParseCombinedDepthLevels 解析 combined stream 的 depth 消息，返回完整档位（价格/数量）。
+func ParseCombinedDepthLevels(raw []byte) (symbol string, bids, asks []PriceLevel, err error) {
+	var msg CombinedMessage
+	if err = json.Unmarshal(raw, &msg); err != nil {
+		return
+	}
+	var payload struct {
+		Symbol string     `json:"s"`
+		Bids   [][]string `json:"b"`
+		Asks   [][]string `json:"a"`
+	}
+	if err = json.Unmarshal(msg.Data, &payload); err != nil {
+		return
+	}
+	symbol = payload.Symbol
+	bids = parsePriceLevels(payload.Bids)
+	asks = parsePriceLevels(payload.Asks)
+	return
+}
+
+// ErrNonDepthDiff 表示消息不是增量深度流。
+var ErrNonDepthDiff = errors.New("non depth diff message")
+
+// DepthSnapshotStreamSuffix 录制REST深度快照时使用的伪 stream 后缀，便于回放时锚定本地订单簿。
+const DepthSnapshotStreamSuffix = "@depthSnapshot"
+
+// EncodeDepthSnapshotMessage 将REST深度快照编码为 combined stream 格式的消息。
+func EncodeDepthSnapshotMessage(symbol string, lastUpdateID int64, bids, asks []PriceLevel) []byte {
+	encode := func(levels []PriceLevel) [][]string {
+		out := make([][]string, len(levels))
+		for i, lv := range levels {
+			out[i] = []string{
+				strconv.FormatFloat(lv.Price, 'f', -1, 64),
+				strconv.FormatFloat(lv.Quantity, 'f', -1, 64),
+			}
+		}
+		return out
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"stream": strings.ToLower(symbol) + DepthSnapshotStreamSuffix,
+		"data": map[string]interface{}{
+			"lastUpdateId": lastUpdateID,
+			"s":            symbol,
+			"b":            encode(bids),
+			"a":            encode(asks),
+		},
+	})
+	return data
+}
+
+// ParseCombinedDepthSnapshot 解析 EncodeDepthSnapshotMessage 生成的快照消息。
+func ParseCombinedDepthSnapshot(raw []byte) (symbol string, lastUpdateID int64, bids, asks []PriceLevel, err error) {
+	var msg CombinedMessage
+	if err = json.Unmarshal(raw, &msg); err != nil {
+		return
+	}
+	if !strings.HasSuffix(msg.Stream, DepthSnapshotStreamSuffix) {
+		err = fmt.Errorf("not a depth snapshot: %s", msg.Stream)
+		return
+	}
+	var payload struct {
+		LastUpdateID int64      `json:"lastUpdateId"`
+		Symbol       string     `json:"s"`
+		Bids         [][]string `json:"b"`
+		Asks         [][]string `json:"a"`
+	}
+	if err = json.Unmarshal(msg.Data, &payload); err != nil {
+		return
+	}
+	return payload.Symbol, payload.LastUpdateID, parsePriceLevels(payload.Bids), parsePriceLevels(payload.Asks), nil
+}
+
+// DepthDiff 对应 @depth@100ms 增量深度推送。
+type DepthDiff struct {
+	Symbol            string
+	EventTime         int64
+	FirstUpdateID     int64 // U
+	FinalUpdateID     int64 // u
+	PrevFinalUpdateID int64 // pu
+	Bids              []PriceLevel
+	Asks              []PriceLevel
+}
+
+// IsDiffDepthStream 判断 stream 是否为增量深度流（xxx@depth 或 xxx@depth@100ms），
+// 区别于 @depth5/@depth10/@depth20 等有限档快照流。
+func IsDiffDepthStream(stream string) bool {
+	idx := strings.Index(stream, "@depth")
+	if idx < 0 {
+		return false
+	}
+	rest := stream[idx+len("@depth"):]
+	return rest == "" || strings.HasPrefix(rest, "@")
+}
+
+// ParseCombinedDepthDiff 解析 combined stream 的增量深度消息。
+func ParseCombinedDepthDiff(raw []byte) (DepthDiff, error) {
+	var msg CombinedMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return DepthDiff{}, err
+	}
+	if !IsDiffDepthStream(msg.Stream) {
+		return DepthDiff{}, ErrNonDepthDiff
+	}
+	// 显式声明大小写不同的同名字段，避免 encoding/json 大小写不敏感匹配串位
+	var payload struct {
+		Event         string     `json:"e"`
+		EventTime     int64      `json:"E"`
+		TransactTime  int64      `json:"T"`
+		Symbol        string     `json:"s"`
+		FirstUpdateID int64      `json:"U"`
+		FinalUpdateID int64      `json:"u"`
+		PrevFinalID   int64      `json:"pu"`
+		Bids          [][]string `json:"b"`
+		Asks          [][]string `json:"a"`
+	}
+	if err := json.Unmarshal(msg.Data, &payload); err != nil {
+		return DepthDiff{}, err
+	}
+	if payload.Event != "depthUpdate" || payload.FinalUpdateID == 0 {
+		return DepthDiff{}, fmt.Errorf("not a depth diff: %s", msg.Stream)
+	}
+	return DepthDiff{
+		Symbol:            payload.Symbol,
+		EventTime:         payload.EventTime,
+		FirstUpdateID:     payload.FirstUpdateID,
+		FinalUpdateID:     payload.FinalUpdateID,
+		PrevFinalUpdateID: payload.PrevFinalID,
+		Bids:              parsePriceLevels(payload.Bids),
+		Asks:              parsePriceLevels(payload.Asks),
+	}, nil
+}
+
+func parsePriceLevels(levels [][]string) []PriceLevel {
+	out := make([]PriceLevel, 0, len(levels))
+	for _, lv := range levels {
+		if len(lv) < 2 {
+			continue
+		}
+		out = append(out, PriceLevel{Price: parseFloat(lv[0]), Quantity: parseFloat(lv[1])})
+	}
+	return out
+}
+
+// AggTrade 对应 @aggTrade 推送的归集成交。
+type AggTrade struct {
+	Symbol       string
+	AggTradeID   int64
+	Price        float64
+	Quantity     float64
+	TradeTime    int64
+	BuyerIsMaker bool // true 表示主动方为卖方
+}
+
+// ToTrade 转换为交易所无关的 Trade。
+func (t AggTrade) ToTrade() *Trade {
+	trade := &Trade{
+		Symbol:       t.Symbol,
+		TradeID:      t.AggTradeID,
+		Price:        t.Price,
+		Quantity:     t.Quantity,
+		IsBuyerMaker: t.BuyerIsMaker,
+	}
+	if t.TradeTime > 0 {
+		trade.Timestamp = time.UnixMilli(t.TradeTime)
+	}
+	return trade
+}
+
+// ParseCombinedAggTrade 解析 combined stream 的 aggTrade 消息。
+func ParseCombinedAggTrade(raw []byte) (AggTrade, error) {
+	var msg CombinedMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		return AggTrade{}, err
+	}
+	var header struct {
+		EventType string          `json:"e"`
+		EventTime json.RawMessage `json:"E"` // 显式声明，避免大小写不敏感匹配到 e
+	}
+	if err := json.Unmarshal(msg.Data, &header); err != nil {
+		return AggTrade{}, err
+	}
+	if header.EventType != "aggTrade" {
+		return AggTrade{}, ErrNonAggTrade
+	}
+	var payload struct {
+		EventType    string `json:"e"`
+		EventTime    int64  `json:"E"`
+		Symbol       string `json:"s"`
+		AggTradeID   int64  `json:"a"`
+		Price        string `json:"p"`
+		Quantity     string `json:"q"`
+		TradeTime    int64  `json:"T"`
+		BuyerIsMaker bool   `json:"m"`
+	}
+	if err := json.Unmarshal(msg.Data, &payload); err != nil {
+		return AggTrade{}, err
+	}
+	return AggTrade{
+		Symbol:       payload.Symbol,
+		AggTradeID:   payload.AggTradeID,
+		Price:        parseFloat(payload.Price),
+		Quantity:     parseFloat(payload.Quantity),
+		TradeTime:    payload.TradeTime,
+		BuyerIsMaker: payload.BuyerIsMaker,
+	}, nil
+}
+
 func parseDepthPrice(entry interface{}) (float64, error) {
 	switch v := entry.(type) {
 	case []interface{}:
@@ -176,6 +390,7 @@ func parseUserPayload(data []byte) (UserEvent, error) {
 				RealizedPnL      string `json:"rp"`
 				CommissionAsset  string `json:"N"`
 				CommissionAmount string `json:"n"`
+				IsMaker          bool   `json:"m"`
 				PositionSide     string `json:"ps"`
 			} `json:"o"`
 		}
@@ -204,6 +419,7 @@ func parseUserPayload(data []byte) (UserEvent, error) {
 			RealizedPnL:      parseFloat(o.RealizedPnL),
 			CommissionAsset:  o.CommissionAsset,
 			CommissionAmount: parseFloat(o.CommissionAmount),
+			IsMaker:          o.IsMaker,
 			PositionSide:     o.PositionSide,
 			EventTime:        eventTime,
 			UpdateTime:       tradeTime,
@@ -216,6 +432,7 @@ func parseUserPayload(data []byte) (UserEvent, error) {
 					Asset  string `json:"a"`
 					Wallet string `json:"wb"`
 					Cross  string `json:"cw"`
+					Change string `json:"bc"`
 				} `json:"B"`
 				Positions []struct {
 					Symbol       string `json:"s"`
@@ -237,6 +454,7 @@ func parseUserPayload(data []byte) (UserEvent, error) {
 				Asset:         b.Asset,
 				WalletBalance: parseFloat(b.Wallet),
 				CrossWallet:   parseFloat(b.Cross),
+				BalanceChange: parseFloat(b.Change),
 			})
 		}
 		for _, p := range acc.Positions {
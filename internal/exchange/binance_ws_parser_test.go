@@ -20,6 +20,85 @@ func TestParseCombinedDepth(t *testing.T) {
 	}
 }
 
+func TestParseCombinedDepthLevels(t *testing.T) {
+	raw := []byte(`{
+		"stream":"btcusdt@depth20@100ms",
+		"data":{
+		  "e":"depthUpdate",
+		  "s":"BTCUSDT",
+		  "b":[["100.1","1.2"],["100.0","2"]],
+		  "a":[["100.2","1.1"],["100.3","2.2"]]
+		}
+	}`)
+	sym, bids, asks, err := ParseCombinedDepthLevels(raw)
+	if err != nil {
+		t.Fatalf("parse err: %v", err)
+	}
+	if sym != "BTCUSDT" || len(bids) != 2 || len(asks) != 2 {
+		t.Fatalf("unexpected parse result: %s %v %v", sym, bids, asks)
+	}
+	if bids[1].Price != 100.0 || bids[1].Quantity != 2 || asks[0].Quantity != 1.1 {
+		t.Fatalf("unexpected levels: %v %v", bids, asks)
+	}
+}
+
+func TestParseCombinedDepthDiff(t *testing.T) {
+	raw := []byte(`{
+		"stream":"btcusdt@depth@100ms",
+		"data":{"e":"depthUpdate","E":1700000000123,"T":1700000000120,"s":"BTCUSDT","U":157,"u":160,"pu":149,
+		  "b":[["100.1","0"],["100.0","2"]],"a":[["100.2","1.1"]]}
+	}`)
+	diff, err := ParseCombinedDepthDiff(raw)
+	if err != nil {
+		t.Fatalf("parse err: %v", err)
+	}
+	if diff.Symbol != "BTCUSDT" || diff.FirstUpdateID != 157 || diff.FinalUpdateID != 160 || diff.PrevFinalUpdateID != 149 || diff.EventTime != 1700000000123 {
+		t.Fatalf("unexpected diff: %+v", diff)
+	}
+	if len(diff.Bids) != 2 || diff.Bids[0].Quantity != 0 || len(diff.Asks) != 1 {
+		t.Fatalf("unexpected levels: %+v", diff)
+	}
+
+	partial := []byte(`{"stream":"btcusdt@depth20@100ms","data":{"e":"depthUpdate","s":"BTCUSDT","U":1,"u":2,"pu":0,"b":[],"a":[]}}`)
+	if _, err := ParseCombinedDepthDiff(partial); err != ErrNonDepthDiff {
+		t.Fatalf("expected ErrNonDepthDiff for partial depth, got %v", err)
+	}
+}
+
+func TestDepthSnapshotMessageRoundTrip(t *testing.T) {
+	raw := EncodeDepthSnapshotMessage("ETHUSDC", 42, []PriceLevel{{Price: 3000.5, Quantity: 1.25}}, []PriceLevel{{Price: 3000.6, Quantity: 2}})
+	sym, id, bids, asks, err := ParseCombinedDepthSnapshot(raw)
+	if err != nil {
+		t.Fatalf("parse err: %v", err)
+	}
+	if sym != "ETHUSDC" || id != 42 || bids[0].Price != 3000.5 || bids[0].Quantity != 1.25 || asks[0].Quantity != 2 {
+		t.Fatalf("unexpected snapshot: %s %d %v %v", sym, id, bids, asks)
+	}
+}
+
+func TestParseCombinedAggTrade(t *testing.T) {
+	raw := []byte(`{
+		"stream":"ethusdc@aggTrade",
+		"data":{"e":"aggTrade","E":1700000000100,"s":"ETHUSDC","a":42,"p":"3000.5","q":"0.25","f":1,"l":2,"T":1700000000099,"m":true}
+	}`)
+	tr, err := ParseCombinedAggTrade(raw)
+	if err != nil {
+		t.Fatalf("parse err: %v", err)
+	}
+	if tr.Symbol != "ETHUSDC" || tr.Price != 3000.5 || tr.Quantity != 0.25 || !tr.BuyerIsMaker || tr.TradeTime != 1700000000099 {
+		t.Fatalf("unexpected trade: %+v", tr)
+	}
+	trade := tr.ToTrade()
+	if trade.TradeID != 42 || trade.TakerSide() != "SELL" || trade.Timestamp.UnixMilli() != 1700000000099 {
+		t.Fatalf("unexpected converted trade: %+v", trade)
+	}
+
+	depth := []byte(`{"stream":"ethusdc@depth20@100ms","data":{"e":"depthUpdate","s":"ETHUSDC","b":[],"a":[]}}`)
+	if _, err := ParseCombinedAggTrade(depth); err != ErrNonAggTrade {
+		t.Fatalf("expected ErrNonAggTrade, got %v", err)
+	}
+}
+
 func TestParseUserOrderUpdate(t *testing.T) {
 	raw := []byte(`{
 		"stream":"listenKey",
@@ -28,7 +107,7 @@ func TestParseUserOrderUpdate(t *testing.T) {
 			"o":{
 				"s":"ETHUSDC","S":"BUY","o":"LIMIT","X":"NEW","x":"NEW",
 				"i":1001,"c":"cid","p":"2700.10","q":"1.5","l":"0.2","z":"0.2",
-				"L":"2700.00","rp":"0","N":"USDC","n":"0","ps":"BOTH"
+				"L":"2700.00","rp":"0","N":"USDC","n":"-0.05","m":true,"ps":"BOTH"
 			}
 		}
 	}`)
@@ -42,6 +121,9 @@ func TestParseUserOrderUpdate(t *testing.T) {
 	if ev.Order.Price != 2700.10 || ev.Order.OrigQty != 1.5 || ev.Order.LastFilledQty != 0.2 {
 		t.Fatalf("unexpected order payload: %+v", ev.Order)
 	}
+	if !ev.Order.IsMaker || ev.Order.CommissionAmount != -0.05 {
+		t.Fatalf("unexpected fill details: %+v", ev.Order)
+	}
 }
 
 func TestParseUserAccountUpdate(t *testing.T) {
@@ -51,7 +133,7 @@ func TestParseUserAccountUpdate(t *testing.T) {
 			"e":"ACCOUNT_UPDATE",
 			"a":{
 				"m":"ORDER",
-				"B":[{"a":"USDC","wb":"100.5","cw":"80.2"}],
+				"B":[{"a":"USDC","wb":"100.5","cw":"80.2","bc":"-0.3"}],
 				"P":[{"s":"ETHUSDC","pa":"0.1","ep":"2500","cr":"5","mt":"isolated","ps":"BOTH"}]
 			}
 		}
@@ -63,7 +145,7 @@ func TestParseUserAccountUpdate(t *testing.T) {
 	if ev.EventType != "ACCOUNT_UPDATE" || ev.Account == nil {
 		t.Fatalf("unexpected event: %+v", ev)
 	}
-	if len(ev.Account.Balances) != 1 || ev.Account.Balances[0].WalletBalance != 100.5 {
+	if len(ev.Account.Balances) != 1 || ev.Account.Balances[0].WalletBalance != 100.5 || ev.Account.Balances[0].BalanceChange != -0.3 {
 		t.Fatalf("unexpected balances: %+v", ev.Account.Balances)
 	}
 	if len(ev.Account.Positions) != 1 || ev.Account.Positions[0].PositionAmt != 0.1 {
@@ -1,34 +1,74 @@
 package gateway
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"log"
-	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
 )
 
-// BinanceWSReal 组合订阅深度/用户数据流并连接真实 WS（执行者确保网络可达）。
-// 仅提供最小骨架：连接 + 简单读取；业务层可在 handler 中扩展解析。
+const (
+	// defaultStreamsPerConn 单连接订阅的stream数上限，超出后新开连接（交易所上限为1024）
+	defaultStreamsPerConn = 200
+	// subscribeFlushDelay 运行中新增的订阅合并发送，避免超过每秒10条的上行消息限制
+	subscribeFlushDelay = 100 * time.Millisecond
+)
+
+// BinanceWSReal 行情 combined stream 客户端。
+// 按交易对将 stream 分片到多个连接（同一交易对的深度与成交在同一连接上），每个连接由
+// WSReconnectManager 维持：断线后无限次指数退避重连，连接后通过 SUBSCRIBE 重新订阅，
+// 并在接近交易所24小时强制断开前主动换新连接。Run 之后仍可继续订阅
 type BinanceWSReal struct {
-	BaseEndpoint string // 默认 wss://fstream.binance.com
-	depthStreams []string
-	userStream   string
-	Dialer       *websocket.Dialer
-	MaxRetries   int
-	RetryBackoff time.Duration
-	onConnect    func()
-	onDisconnect func(error)
+	BaseEndpoint      string // 默认 wss://fstream.binance.com
+	Dialer            *websocket.Dialer
+	MaxRetries        int           // 单连接连续拨号失败上限（0=无限）
+	RetryBackoff      time.Duration // 重连初始退避
+	MaxRetryBackoff   time.Duration // 重连最大退避（默认60秒）
+	MaxStreamsPerConn int           // 单连接stream上限（默认200）
+	MaxConnLifetime   time.Duration // 连接最长存活时间（默认23小时）
+
+	mu          sync.Mutex
+	shards      []*wsShard
+	symbolShard map[string]*wsShard
+	handler     WSHandler
+	running     bool
+	stopCh      chan struct{}
+	subSeq      int64
+
+	onConnect     func()
+	onDisconnect  func(error)
+	onResubscribe func(symbols []string)
+}
+
+// wsShard 一条行情连接及其订阅
+type wsShard struct {
+	index   int
+	name    string
+	streams []string
+	symbols []string
+	pending []string // 运行中新增、尚未发送的订阅
+	flush   *time.Timer
+	mgr     *WSReconnectManager
+	dialed  bool // 是否曾连接成功（之后的连接均为重连）
+}
+
+// RawRecorder 旁路接收原始WS消息（实现见 internal/recorder）。
+type RawRecorder interface {
+	Record(raw []byte)
 }
 
 func NewBinanceWSReal() *BinanceWSReal {
 	return &BinanceWSReal{
 		BaseEndpoint: BinanceFuturesWSEndpoint,
 		Dialer:       websocket.DefaultDialer,
-		MaxRetries:   5,
 		RetryBackoff: time.Second,
+		symbolShard:  make(map[string]*wsShard),
+		stopCh:       make(chan struct{}),
 	}
 }
 
@@ -36,17 +76,23 @@ func (b *BinanceWSReal) SubscribeDepth(symbol string) error {
 	if symbol == "" {
 		return fmt.Errorf("symbol required")
 	}
-	stream := strings.ToLower(symbol) + "@depth20@100ms"
-	b.depthStreams = append(b.depthStreams, stream)
-	return nil
+	// 增量深度流，由本地订单簿（internal/orderbook）以REST快照锚定后维护
+	return b.subscribe(symbol, strings.ToLower(symbol)+"@depth@100ms")
+}
+
+// SubscribeTrade 订阅归集逐笔成交流（@aggTrade）。
+func (b *BinanceWSReal) SubscribeTrade(symbol string) error {
+	if symbol == "" {
+		return fmt.Errorf("symbol required")
+	}
+	return b.subscribe(symbol, strings.ToLower(symbol)+"@aggTrade")
 }
 
 func (b *BinanceWSReal) SubscribeUserData(listenKey string) error {
 	if listenKey == "" {
 		return fmt.Errorf("listenKey required")
 	}
-	b.userStream = listenKey
-	return nil
+	return b.subscribe("", listenKey)
 }
 
 func (b *BinanceWSReal) OnConnect(cb func()) {
@@ -57,74 +103,262 @@ func (b *BinanceWSReal) OnDisconnect(cb func(error)) {
 	b.onDisconnect = cb
 }
 
-// Run 构建 combined stream 并读取消息；对消息不做解析，业务可扩展。
+// OnResubscribe 连接断开后重连成功时回调该连接上的交易对（断线期间的深度增量已丢失，需重建订单簿）
+func (b *BinanceWSReal) OnResubscribe(cb func(symbols []string)) {
+	b.mu.Lock()
+	b.onResubscribe = cb
+	b.mu.Unlock()
+}
+
+// subscribe 将stream加入交易对所在分片；运行中则合并后发送 SUBSCRIBE
+func (b *BinanceWSReal) subscribe(symbol, stream string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.symbolShard == nil {
+		b.symbolShard = make(map[string]*wsShard)
+	}
+	shard := b.symbolShard[symbol]
+	if shard == nil {
+		if n := len(b.shards); n > 0 && len(b.shards[n-1].streams) < b.streamsPerConn() {
+			shard = b.shards[n-1]
+		} else {
+			shard = &wsShard{index: len(b.shards), name: fmt.Sprintf("market-%d", len(b.shards))}
+			b.shards = append(b.shards, shard)
+		}
+		b.symbolShard[symbol] = shard
+		if symbol != "" {
+			shard.symbols = append(shard.symbols, symbol)
+		}
+	}
+	for _, s := range shard.streams {
+		if s == stream {
+			return nil
+		}
+	}
+	shard.streams = append(shard.streams, stream)
+
+	if !b.running {
+		return nil
+	}
+	if shard.mgr == nil {
+		b.startShardLocked(shard)
+		return nil
+	}
+	shard.pending = append(shard.pending, stream)
+	if shard.flush == nil {
+		shard.flush = time.AfterFunc(subscribeFlushDelay, func() { b.flushPending(shard) })
+	}
+	return nil
+}
+
+func (b *BinanceWSReal) streamsPerConn() int {
+	if b.MaxStreamsPerConn > 0 {
+		return b.MaxStreamsPerConn
+	}
+	return defaultStreamsPerConn
+}
+
+// flushPending 发送运行中新增的订阅；未连接时无需发送，连接建立后会订阅全部stream
+func (b *BinanceWSReal) flushPending(shard *wsShard) {
+	b.mu.Lock()
+	streams := shard.pending
+	shard.pending = nil
+	shard.flush = nil
+	mgr := shard.mgr
+	req := b.subscribeRequest(streams)
+	b.mu.Unlock()
+	if len(streams) == 0 || mgr == nil || !mgr.IsConnected() {
+		return
+	}
+	if err := mgr.Send(req); err != nil {
+		log.Warn().Err(err).Str("conn", shard.name).Msg("发送SUBSCRIBE失败，等待重连后重新订阅")
+		mgr.ForceReconnect(ReconnectReasonError)
+	}
+}
+
+// subscribeRequest 构造SUBSCRIBE请求（调用方持有b.mu）
+func (b *BinanceWSReal) subscribeRequest(streams []string) map[string]interface{} {
+	b.subSeq++
+	return map[string]interface{}{"method": "SUBSCRIBE", "params": streams, "id": b.subSeq}
+}
+
+// Run 为每个分片建立连接并阻塞直到 Close；之后新增的订阅在运行中生效
 func (b *BinanceWSReal) Run(handler WSHandler) error {
-	streams := make([]string, 0, len(b.depthStreams)+1)
-	streams = append(streams, b.depthStreams...)
-	if b.userStream != "" {
-		streams = append(streams, b.userStream)
-	}
-	if len(streams) == 0 {
-		return fmt.Errorf("no streams subscribed")
-	}
-	u := url.URL{
-		Scheme: "wss",
-		Host:   strings.TrimPrefix(b.BaseEndpoint, "wss://"),
-		Path:   "/stream",
-	}
-	q := u.Query()
-	q.Set("streams", strings.Join(streams, "/"))
-	u.RawQuery = q.Encode()
-
-	retries := 0
-	for {
-		select {
-		default:
-			conn, _, err := b.Dialer.Dial(u.String(), nil)
-			if err != nil {
-				if retries >= b.MaxRetries {
-					return err
-				}
-				retries++
-				sleep := b.RetryBackoff * time.Duration(retries)
-				log.Printf("ws dial failed (%d/%d): %v, retry in %s", retries, b.MaxRetries, err, sleep)
-				time.Sleep(sleep)
-				continue
+	b.mu.Lock()
+	if b.running {
+		b.mu.Unlock()
+		return fmt.Errorf("ws already running")
+	}
+	if b.stopCh == nil {
+		b.stopCh = make(chan struct{})
+	}
+	select {
+	case <-b.stopCh:
+		// Close 之后再次运行（Disconnect 后重新 Connect）
+		b.stopCh = make(chan struct{})
+	default:
+	}
+	b.handler = handler
+	b.running = true
+	for _, shard := range b.shards {
+		b.startShardLocked(shard)
+	}
+	stop := b.stopCh
+	b.mu.Unlock()
+
+	<-stop
+
+	b.mu.Lock()
+	shards := append([]*wsShard(nil), b.shards...)
+	b.running = false
+	b.mu.Unlock()
+	for _, shard := range shards {
+		if shard.mgr != nil {
+			_ = shard.mgr.Stop()
+		}
+	}
+	return nil
+}
+
+// Close 停止全部行情连接，Run 随之返回
+func (b *BinanceWSReal) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.stopCh == nil {
+		b.stopCh = make(chan struct{})
+	}
+	select {
+	case <-b.stopCh:
+	default:
+		close(b.stopCh)
+	}
+	return nil
+}
+
+// ForceReconnect 强制重连交易对所在的行情连接（行情过期看门狗调用），交易对未订阅时返回false
+func (b *BinanceWSReal) ForceReconnect(symbol, reason string) bool {
+	b.mu.Lock()
+	shard := b.symbolShard[symbol]
+	var mgr *WSReconnectManager
+	if shard != nil {
+		mgr = shard.mgr
+	}
+	b.mu.Unlock()
+	if mgr == nil {
+		return false
+	}
+	log.Warn().Str("symbol", symbol).Str("conn", shard.name).Str("reason", reason).Msg("强制重连行情连接")
+	mgr.ForceReconnect(reason)
+	return true
+}
+
+// Stats 各行情连接的状态，key为连接名称
+func (b *BinanceWSReal) Stats() map[string]WSStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make(map[string]WSStats, len(b.shards))
+	for _, shard := range b.shards {
+		if shard.mgr != nil {
+			out[shard.name] = shard.mgr.GetStats()
+		}
+	}
+	return out
+}
+
+// streamURL combined stream 地址；默认wss，ws://用于本地模拟器
+func (b *BinanceWSReal) streamURL() string {
+	base := b.BaseEndpoint
+	if base == "" {
+		base = BinanceFuturesWSEndpoint
+	}
+	if !strings.HasPrefix(base, "ws://") && !strings.HasPrefix(base, "wss://") {
+		base = "wss://" + base
+	}
+	return strings.TrimSuffix(base, "/") + "/stream"
+}
+
+func (b *BinanceWSReal) startShardLocked(shard *wsShard) {
+	cfg := DefaultWSReconnectConfig()
+	cfg.Name = shard.name
+	cfg.Dialer = b.Dialer
+	cfg.MaxRetries = b.MaxRetries
+	if b.RetryBackoff > 0 {
+		cfg.InitialDelay = b.RetryBackoff
+	}
+	if b.MaxRetryBackoff > 0 {
+		cfg.MaxDelay = b.MaxRetryBackoff
+	}
+	if cfg.MaxDelay < cfg.InitialDelay {
+		cfg.MaxDelay = cfg.InitialDelay
+	}
+	if b.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = b.MaxConnLifetime
+	}
+	// 各分片错开换新连接的时间，避免同时断流
+	if stagger := time.Duration(shard.index) * time.Minute; stagger < cfg.MaxConnLifetime/2 {
+		cfg.MaxConnLifetime -= stagger
+	}
+	mgr := NewWSReconnectManager(b.streamURL(), cfg)
+	mgr.SetCallbacks(
+		func(*websocket.Conn) { b.onShardConnect(shard) },
+		func(err error) {
+			if b.onDisconnect != nil {
+				b.onDisconnect(err)
 			}
-			if b.onConnect != nil {
-				b.onConnect()
+		},
+		b.onMessage,
+		nil,
+	)
+	shard.mgr = mgr
+	_ = mgr.Start()
+}
+
+// onShardConnect 连接建立后订阅分片的全部stream；重连时通知上层重建订单簿
+func (b *BinanceWSReal) onShardConnect(shard *wsShard) {
+	b.mu.Lock()
+	streams := append([]string(nil), shard.streams...)
+	symbols := append([]string(nil), shard.symbols...)
+	shard.pending = nil
+	reconnect := shard.dialed
+	shard.dialed = true
+	req := b.subscribeRequest(streams)
+	onResubscribe := b.onResubscribe
+	mgr := shard.mgr
+	b.mu.Unlock()
+
+	if err := mgr.Send(req); err != nil {
+		log.Warn().Err(err).Str("conn", shard.name).Msg("发送SUBSCRIBE失败")
+		mgr.ForceReconnect(ReconnectReasonError)
+		return
+	}
+	log.Info().Str("conn", shard.name).Int("streams", len(streams)).Bool("reconnect", reconnect).Msg("行情连接已订阅")
+	if b.onConnect != nil {
+		b.onConnect()
+	}
+	if reconnect && onResubscribe != nil {
+		onResubscribe(symbols)
+	}
+}
+
+func (b *BinanceWSReal) onMessage(message []byte) {
+	// 订阅请求的应答：{"result":null,"id":1} 或 {"error":{...},"id":1}
+	if !bytes.HasPrefix(message, []byte(`{"stream"`)) {
+		var resp struct {
+			ID    int64           `json:"id"`
+			Error json.RawMessage `json:"error"`
+		}
+		if json.Unmarshal(message, &resp) == nil && resp.ID != 0 {
+			if len(resp.Error) > 0 {
+				log.Error().RawJSON("error", resp.Error).Int64("id", resp.ID).Msg("行情订阅被拒绝")
 			}
-			func() {
-				defer conn.Close()
-				resetDeadline := func() {
-					_ = conn.SetReadDeadline(time.Now().Add(30 * time.Second))
-				}
-				resetDeadline()
-				conn.SetPongHandler(func(string) error {
-					resetDeadline()
-					return nil
-				})
-				retries = 0
-				for {
-					resetDeadline()
-					_, message, err := conn.ReadMessage()
-					if err != nil {
-						if b.onDisconnect != nil {
-							b.onDisconnect(err)
-						}
-						log.Printf("ws read err: %v", err)
-						break
-					}
-					if handler != nil {
-						if h, ok := handler.(interface{ OnRawMessage([]byte) }); ok {
-							h.OnRawMessage(message)
-						}
-					} else {
-						log.Printf("binance ws recv: %s", string(message))
-					}
-				}
-			}()
-		case <-time.After(1 * time.Millisecond):
+			return
 		}
 	}
+	b.mu.Lock()
+	handler := b.handler
+	b.mu.Unlock()
+	if h, ok := handler.(interface{ OnRawMessage([]byte) }); ok {
+		h.OnRawMessage(message)
+	}
 }
@@ -0,0 +1,145 @@
+package gateway
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/exchange/fakebinance"
+)
+
+// rawCollector 记录收到的原始消息
+type rawCollector struct {
+	mu   sync.Mutex
+	msgs []string
+}
+
+func (c *rawCollector) OnDepth(symbol string, bid, ask float64)   {}
+func (c *rawCollector) OnTrade(symbol string, price, qty float64) {}
+func (c *rawCollector) OnRawMessage(msg []byte) {
+	c.mu.Lock()
+	c.msgs = append(c.msgs, string(msg))
+	c.mu.Unlock()
+}
+
+func (c *rawCollector) count(substr string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, m := range c.msgs {
+		if strings.Contains(m, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestBinanceWSRealShardsBySymbol(t *testing.T) {
+	ws := NewBinanceWSReal()
+	ws.MaxStreamsPerConn = 3
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT", "SOLUSDT"} {
+		if err := ws.SubscribeDepth(symbol); err != nil {
+			t.Fatal(err)
+		}
+		if err := ws.SubscribeTrade(symbol); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(ws.shards) != 2 {
+		t.Fatalf("expected 2 shards, got %d", len(ws.shards))
+	}
+	// 同一交易对的深度与成交在同一连接上
+	if got := ws.shards[0].streams; len(got) != 4 || got[0] != "btcusdt@depth@100ms" || got[3] != "ethusdt@aggTrade" {
+		t.Fatalf("shard 0 streams: %v", got)
+	}
+	if ws.symbolShard["SOLUSDT"] != ws.shards[1] {
+		t.Fatal("SOLUSDT should open a new shard")
+	}
+}
+
+func TestBinanceWSRealResubscribesAfterReconnect(t *testing.T) {
+	fake := fakebinance.New(fakebinance.Options{})
+	defer fake.Close()
+
+	ws := NewBinanceWSReal()
+	ws.BaseEndpoint = fake.WSURL()
+	ws.RetryBackoff = 20 * time.Millisecond
+	var mu sync.Mutex
+	var resubscribed [][]string
+	ws.OnResubscribe(func(symbols []string) {
+		mu.Lock()
+		resubscribed = append(resubscribed, symbols)
+		mu.Unlock()
+	})
+
+	h := &rawCollector{}
+	done := make(chan struct{})
+	go func() {
+		_ = ws.Run(h)
+		close(done)
+	}()
+	defer func() {
+		_ = ws.Close()
+		<-done
+	}()
+
+	// Run 之后订阅：连接建立后发送 SUBSCRIBE
+	if err := ws.SubscribeDepth("BTCUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "subscribed", func() bool {
+		st := ws.Stats()["market-0"]
+		return st.Connected
+	})
+	waitFor(t, "first depth", func() bool {
+		fake.SetBook("BTCUSDT", []fakebinance.Level{{Price: 100, Qty: 1}}, []fakebinance.Level{{Price: 101, Qty: 1}})
+		return h.count("btcusdt@depth") > 0
+	})
+
+	// 服务端断开：自动重连并重新订阅
+	fake.DisconnectStreams()
+	waitFor(t, "resubscribe after drop", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(resubscribed) == 1 && resubscribed[0][0] == "BTCUSDT"
+	})
+	before := h.count("btcusdt@depth")
+	waitFor(t, "depth after reconnect", func() bool {
+		fake.SetBook("BTCUSDT", []fakebinance.Level{{Price: 100, Qty: float64(time.Now().UnixNano()%7 + 1)}}, []fakebinance.Level{{Price: 101, Qty: 1}})
+		return h.count("btcusdt@depth") > before
+	})
+
+	// 看门狗强制重连
+	if !ws.ForceReconnect("BTCUSDT", ReconnectReasonStale) {
+		t.Fatal("force reconnect of subscribed symbol should succeed")
+	}
+	waitFor(t, "forced reconnect", func() bool {
+		st := ws.Stats()["market-0"]
+		return st.Connected && st.TotalReconnects == 3 && st.LastDisconnectBy == ReconnectReasonStale
+	})
+	if ws.ForceReconnect("ETHUSDT", ReconnectReasonStale) {
+		t.Fatal("unknown symbol should not reconnect")
+	}
+}
+
+// rawSink 记录写入录制器的消息
+type rawSink struct{ msgs [][]byte }
+
+func (s *rawSink) Record(msg []byte) { s.msgs = append(s.msgs, msg) }
+
+// TestBinanceWSRealRecordsOnce 录制只经由适配器的 OnRawMessage，每条消息只写入一次
+func TestBinanceWSRealRecordsOnce(t *testing.T) {
+	adapter := NewBinanceAdapter(&BinanceRESTStub{}, &BinanceWSStub{})
+	sink := &rawSink{}
+	adapter.SetRecorder(sink)
+
+	ws := NewBinanceWSReal()
+	ws.handler = &adapterWSHandler{adapter: adapter}
+	ws.onMessage([]byte(`{"stream":"ethusdc@bookTicker","data":{}}`))
+	ws.onMessage([]byte(`{"result":null,"id":1}`)) // 订阅应答不录制
+
+	if len(sink.msgs) != 1 {
+		t.Fatalf("expected message recorded once, got %d", len(sink.msgs))
+	}
+}
@@ -0,0 +1,87 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Bybit v5 endpoints (USDT/USDC linear perpetual)
+const (
+	BybitRestEndpoint          = "https://api.bybit.com"
+	BybitPublicWSEndpoint      = "wss://stream.bybit.com/v5/public/linear"
+	BybitPrivateWSEndpoint     = "wss://stream.bybit.com/v5/private"
+	BybitTestnetRestEndpoint   = "https://api-testnet.bybit.com"
+	BybitTestnetPublicWSURL    = "wss://stream-testnet.bybit.com/v5/public/linear"
+	BybitTestnetPrivateWSURL   = "wss://stream-testnet.bybit.com/v5/private"
+	bybitCategoryLinear        = "linear"
+	defaultBybitRecvWindowMs   = 5000
+	defaultBybitOrderbookDepth = 50
+)
+
+// bybitSettleCoins linear合约的结算币种；不指定交易对查询挂单/仓位时须逐个指定
+var bybitSettleCoins = []string{"USDT", "USDC"}
+
+// BybitSign 生成 Bybit v5 签名：HMAC-SHA256(secret, payload) 的十六进制。
+// REST的payload为 timestamp+apiKey+recvWindow+(GET为query串 | POST为JSON body)，
+// 私有WS鉴权的payload为 "GET/realtime"+expires
+func BybitSign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// bybitErrorCodes Bybit错误码到网关统一错误码（沿用Binance错误码）的映射，
+// 上层据此识别订单不存在、ClientOrderID重复、改单无变化等情况
+var bybitErrorCodes = map[int]int{
+	110001: -2011, // 订单不存在或已完成
+	110072: -4116, // orderLinkId重复
+	34040:  -5027, // 改单内容无变化
+	110007: -2019, // 可用余额不足
+	10002:  -1021, // 请求时间超出recv_window
+	10006:  429,   // 请求过于频繁
+}
+
+// newBybitError 将Bybit retCode转换为ExchangeError，错误信息同时保留统一错误码与原始错误码
+func newBybitError(retCode int, retMsg string) *ExchangeError {
+	code, ok := bybitErrorCodes[retCode]
+	if !ok {
+		return &ExchangeError{Code: retCode, Message: fmt.Sprintf("bybit error %d: %s", retCode, retMsg)}
+	}
+	return &ExchangeError{Code: code, Message: fmt.Sprintf("code %d: bybit error %d: %s", code, retCode, retMsg)}
+}
+
+// toBybitSide BUY/SELL -> Buy/Sell
+func toBybitSide(side string) string {
+	switch strings.ToUpper(side) {
+	case "BUY":
+		return "Buy"
+	case "SELL":
+		return "Sell"
+	}
+	return side
+}
+
+// fromBybitSide Buy/Sell -> BUY/SELL
+func fromBybitSide(side string) string {
+	return strings.ToUpper(side)
+}
+
+// fromBybitOrderStatus 将Bybit订单状态转换为网关统一（Binance风格）的订单状态
+func fromBybitOrderStatus(status string) string {
+	switch status {
+	case "New", "Created", "Untriggered":
+		return "NEW"
+	case "PartiallyFilled":
+		return "PARTIALLY_FILLED"
+	case "Filled":
+		return "FILLED"
+	case "Cancelled", "PartiallyFilledCanceled", "Deactivated":
+		return "CANCELED"
+	case "Rejected":
+		return "REJECTED"
+	}
+	return strings.ToUpper(status)
+}
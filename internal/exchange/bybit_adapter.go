@@ -0,0 +1,1121 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/newplayman/market-maker-phoenix/internal/filters"
+	"github.com/newplayman/market-maker-phoenix/internal/orderbook"
+	"github.com/rs/zerolog/log"
+)
+
+// BybitAdapterConfig Bybit适配器配置
+type BybitAdapterConfig struct {
+	PublicWSURL  string            // 默认 BybitPublicWSEndpoint
+	PrivateWSURL string            // 默认 BybitPrivateWSEndpoint
+	Dialer       *websocket.Dialer // 可注入测试连接
+	SymbolMap    map[string]string // 内部交易对 -> Bybit交易对，未配置时同名
+}
+
+// BybitAdapter 基于 Bybit v5 linear永续实现 Exchange 接口。
+// 下单/撤单/改单走REST（支持批量），行情与用户数据走WebSocket，
+// 价格与数量按 instruments-info 的步长取整后提交
+type BybitAdapter struct {
+	rest         *BybitRESTClient
+	publicURL    string
+	privateURL   string
+	dialer       *websocket.Dialer
+	toVenue      map[string]string
+	fromVenue    map[string]string
+	connected    bool
+	userStarted  bool
+	streamCancel []context.CancelFunc
+	clientSeq    uint64
+	mu           sync.RWMutex
+
+	// Callbacks
+	depthCallback func(*Depth)
+	tradeCallback func(*Trade)
+	userCallbacks *UserStreamCallbacks
+
+	// books 由 orderbook.50 快照+增量维护的本地订单簿，key为内部交易对
+	books   map[string]*orderbook.Book
+	booksMu sync.Mutex
+
+	instruments map[string]BybitInstrument // key: Bybit交易对
+	instMu      sync.Mutex
+
+	// State
+	positions map[string]*Position
+	orders    map[string]*Order // key: clientOrderID(orderLinkId) -> Order
+	stateMu   sync.RWMutex
+}
+
+// NewBybitAdapter 创建Bybit适配器
+func NewBybitAdapter(rest *BybitRESTClient, cfg BybitAdapterConfig) *BybitAdapter {
+	a := &BybitAdapter{
+		rest:        rest,
+		publicURL:   cfg.PublicWSURL,
+		privateURL:  cfg.PrivateWSURL,
+		dialer:      cfg.Dialer,
+		toVenue:     make(map[string]string),
+		fromVenue:   make(map[string]string),
+		books:       make(map[string]*orderbook.Book),
+		instruments: make(map[string]BybitInstrument),
+		positions:   make(map[string]*Position),
+		orders:      make(map[string]*Order),
+	}
+	if a.publicURL == "" {
+		a.publicURL = BybitPublicWSEndpoint
+	}
+	if a.privateURL == "" {
+		a.privateURL = BybitPrivateWSEndpoint
+	}
+	for internal, venue := range cfg.SymbolMap {
+		if venue == "" {
+			continue
+		}
+		a.toVenue[internal] = venue
+		a.fromVenue[venue] = internal
+	}
+	return a
+}
+
+// venueSymbol 内部交易对 -> Bybit交易对
+func (a *BybitAdapter) venueSymbol(symbol string) string {
+	if v, ok := a.toVenue[symbol]; ok {
+		return v
+	}
+	return symbol
+}
+
+// internalSymbol Bybit交易对 -> 内部交易对
+func (a *BybitAdapter) internalSymbol(venue string) string {
+	if s, ok := a.fromVenue[venue]; ok {
+		return s
+	}
+	return venue
+}
+
+// instrument 读取交易规则（首次使用时通过REST加载并缓存）
+func (a *BybitAdapter) instrument(venue string) (BybitInstrument, error) {
+	a.instMu.Lock()
+	defer a.instMu.Unlock()
+	if inst, ok := a.instruments[venue]; ok {
+		return inst, nil
+	}
+	inst, err := a.rest.Instrument(venue)
+	if err != nil {
+		return BybitInstrument{}, fmt.Errorf("load instrument %s: %w", venue, err)
+	}
+	if inst.TickSize <= 0 || inst.QtyStep <= 0 {
+		return BybitInstrument{}, fmt.Errorf("invalid instrument %s: tick=%v step=%v", venue, inst.TickSize, inst.QtyStep)
+	}
+	a.instruments[venue] = inst
+	return inst, nil
+}
+
+// orderRequest 将订单转换为Bybit请求：价格按tick四舍五入、数量按步长向下取整，
+// 取整后的值回写到order，使本地缓存与交易所一致
+func (a *BybitAdapter) orderRequest(order *Order, amend bool) (BybitOrderRequest, error) {
+	venue := a.venueSymbol(order.Symbol)
+	inst, err := a.instrument(venue)
+	if err != nil {
+		return BybitOrderRequest{}, err
+	}
+	price := roundToStep(order.Price, inst.TickSize, math.Round)
+	qty := roundToStep(order.Quantity, inst.QtyStep, math.Floor)
+	if price <= 0 || qty <= 0 || qty < inst.MinQty {
+		return BybitOrderRequest{}, fmt.Errorf("%w: price=%v qty=%v min_qty=%v", ErrInvalidOrder, order.Price, order.Quantity, inst.MinQty)
+	}
+	order.Price = price
+	order.Quantity = qty
+
+	req := BybitOrderRequest{
+		Symbol:      venue,
+		Qty:         formatStep(qty, inst.QtyStep),
+		Price:       formatStep(price, inst.TickSize),
+		OrderLinkID: order.ClientOrderID,
+	}
+	if !amend {
+		req.Side = toBybitSide(order.Side)
+		req.OrderType = "Limit"
+		req.TimeInForce = "PostOnly" // Maker-only
+	}
+	return req, nil
+}
+
+// roundToStep 按步长取整；加入微小偏移抵消浮点误差
+func roundToStep(v, step float64, round func(float64) float64) float64 {
+	n := round(v/step + 1e-9)
+	return n * step
+}
+
+// formatStep 按步长的小数位数格式化
+func formatStep(v, step float64) string {
+	decimals := 0
+	if s := strconv.FormatFloat(step, 'f', -1, 64); strings.Contains(s, ".") {
+		decimals = len(s) - strings.Index(s, ".") - 1
+	}
+	return strconv.FormatFloat(v, 'f', decimals, 64)
+}
+
+// PlaceOrder 下Post Only限价单（/v5/order/create）。
+// Bybit的Post Only拒单为异步回报（订单状态Cancelled），不会在下单应答中返回错误
+func (a *BybitAdapter) PlaceOrder(ctx context.Context, order *Order) (*Order, error) {
+	if order == nil {
+		return nil, ErrInvalidOrder
+	}
+	if order.ClientOrderID == "" {
+		order.ClientOrderID = NextClientOrderID(&a.clientSeq, order.Symbol)
+	}
+	req, err := a.orderRequest(order, false)
+	if err != nil {
+		return nil, err
+	}
+	t0 := time.Now()
+	orderID, err := a.rest.CreateOrder(req)
+	observeOrderChannel(OrderChannelREST, "place", t0, err)
+	return a.finishPlace(order, orderID, err)
+}
+
+// PlaceIOC 下IOC限价单，价格即可接受的最差成交价
+func (a *BybitAdapter) PlaceIOC(ctx context.Context, order *Order) (*Order, error) {
+	if order == nil {
+		return nil, ErrInvalidOrder
+	}
+	if order.ClientOrderID == "" {
+		order.ClientOrderID = NextClientOrderID(&a.clientSeq, order.Symbol)
+	}
+	req, err := a.orderRequest(order, false)
+	if err != nil {
+		return nil, err
+	}
+	req.TimeInForce = "IOC"
+	t0 := time.Now()
+	orderID, err := a.rest.CreateOrder(req)
+	observeOrderChannel(OrderChannelREST, "place", t0, err)
+	if err != nil {
+		return nil, fmt.Errorf("bybit place IOC order failed: %w", err)
+	}
+	order.CreatedAt = time.Now()
+
+	log.Info().
+		Str("symbol", order.Symbol).
+		Str("side", order.Side).
+		Float64("price", order.Price).
+		Float64("qty", order.Quantity).
+		Str("client_id", order.ClientOrderID).
+		Str("order_id", orderID).
+		Msg("IOC订单已下达")
+	return order, nil
+}
+
+// finishPlace 处理单个下单结果：错误包装与本地订单缓存
+func (a *BybitAdapter) finishPlace(order *Order, orderID string, err error) (*Order, error) {
+	if err != nil {
+		return nil, fmt.Errorf("bybit place order failed: %w", err)
+	}
+
+	order.Status = "NEW"
+	order.CreatedAt = time.Now()
+
+	a.stateMu.Lock()
+	a.orders[order.ClientOrderID] = order
+	a.stateMu.Unlock()
+
+	log.Info().
+		Str("symbol", order.Symbol).
+		Str("side", order.Side).
+		Float64("price", order.Price).
+		Float64("qty", order.Quantity).
+		Str("client_id", order.ClientOrderID).
+		Str("order_id", orderID).
+		Msg("Bybit订单已下达")
+
+	return order, nil
+}
+
+// CancelOrder 按orderLinkId撤单（/v5/order/cancel）
+func (a *BybitAdapter) CancelOrder(ctx context.Context, symbol, clientOrderID string) error {
+	if symbol == "" || clientOrderID == "" {
+		return ErrInvalidOrder
+	}
+	t0 := time.Now()
+	err := a.rest.CancelOrder(a.venueSymbol(symbol), clientOrderID)
+	observeOrderChannel(OrderChannelREST, "cancel", t0, err)
+	return a.finishCancel(symbol, clientOrderID, err)
+}
+
+// finishCancel 处理单个撤单结果：错误包装与本地订单缓存
+func (a *BybitAdapter) finishCancel(symbol, clientOrderID string, err error) error {
+	if err != nil {
+		return fmt.Errorf("bybit cancel order failed: %w", err)
+	}
+
+	a.stateMu.Lock()
+	if order, exists := a.orders[clientOrderID]; exists {
+		order.Status = "CANCELED"
+		delete(a.orders, clientOrderID)
+	}
+	a.stateMu.Unlock()
+
+	log.Info().
+		Str("symbol", symbol).
+		Str("client_id", clientOrderID).
+		Msg("Bybit订单已撤销")
+
+	return nil
+}
+
+// AmendOrder 按orderLinkId修改挂单价格与数量（/v5/order/amend），
+// 改单内容无变化（34040，映射为-5027）视为成功
+func (a *BybitAdapter) AmendOrder(ctx context.Context, order *Order) (*Order, error) {
+	if order == nil || order.Symbol == "" || order.ClientOrderID == "" {
+		return nil, ErrInvalidOrder
+	}
+	req, err := a.orderRequest(order, true)
+	if err != nil {
+		return nil, err
+	}
+	t0 := time.Now()
+	orderID, err := a.rest.AmendOrder(req)
+	observeOrderChannel(OrderChannelREST, "amend", t0, err)
+	return a.finishAmend(order, orderID, err)
+}
+
+// finishAmend 处理单个改单结果并更新本地订单缓存
+func (a *BybitAdapter) finishAmend(order *Order, orderID string, err error) (*Order, error) {
+	if isNoNeedToModify(err) {
+		err = nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("bybit amend order failed: %w", err)
+	}
+
+	a.stateMu.Lock()
+	if cached, exists := a.orders[order.ClientOrderID]; exists {
+		cached.Price = order.Price
+		cached.Quantity = order.Quantity
+	}
+	a.stateMu.Unlock()
+
+	log.Info().
+		Str("symbol", order.Symbol).
+		Str("side", order.Side).
+		Float64("price", order.Price).
+		Float64("qty", order.Quantity).
+		Str("client_id", order.ClientOrderID).
+		Str("order_id", orderID).
+		Msg("Bybit订单已改价")
+
+	return order, nil
+}
+
+// PlaceOrders 批量下单（/v5/order/create-batch，每批10个），结果与orders一一对应
+func (a *BybitAdapter) PlaceOrders(ctx context.Context, orders []*Order) []OrderResult {
+	return a.batchOrders(orders, false)
+}
+
+// AmendOrders 批量改单（/v5/order/amend-batch，每批10个），结果与orders一一对应
+func (a *BybitAdapter) AmendOrders(ctx context.Context, orders []*Order) []OrderResult {
+	return a.batchOrders(orders, true)
+}
+
+// batchOrders 批量下单/改单：参数无效的订单单独报错，其余按批提交
+func (a *BybitAdapter) batchOrders(orders []*Order, amend bool) []OrderResult {
+	op := "batch_place"
+	if amend {
+		op = "batch_amend"
+	}
+
+	results := make([]OrderResult, len(orders))
+	var idx []int
+	var reqs []BybitOrderRequest
+	for i, o := range orders {
+		if o == nil || (amend && (o.Symbol == "" || o.ClientOrderID == "")) {
+			results[i].Err = ErrInvalidOrder
+			continue
+		}
+		if o.ClientOrderID == "" {
+			o.ClientOrderID = NextClientOrderID(&a.clientSeq, o.Symbol)
+		}
+		req, err := a.orderRequest(o, amend)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		idx = append(idx, i)
+		reqs = append(reqs, req)
+	}
+
+	for start := 0; start < len(reqs); start += MaxBybitBatchOrders {
+		end := start + MaxBybitBatchOrders
+		if end > len(reqs) {
+			end = len(reqs)
+		}
+		t0 := time.Now()
+		var items []BatchItemResult
+		var err error
+		if amend {
+			items, err = a.rest.AmendBatch(reqs[start:end])
+		} else {
+			items, err = a.rest.CreateBatch(reqs[start:end])
+		}
+		observeOrderChannel(OrderChannelREST, op, t0, err)
+		for j, i := range idx[start:end] {
+			if err != nil {
+				results[i].Err = fmt.Errorf("bybit %s failed: %w", op, err)
+				continue
+			}
+			if amend {
+				results[i].Order, results[i].Err = a.finishAmend(orders[i], items[j].OrderID, items[j].Err)
+			} else {
+				results[i].Order, results[i].Err = a.finishPlace(orders[i], items[j].OrderID, items[j].Err)
+			}
+		}
+	}
+	return results
+}
+
+// CancelOrders 批量撤单（/v5/order/cancel-batch，每批10个），返回的错误与clientOrderIDs一一对应
+func (a *BybitAdapter) CancelOrders(ctx context.Context, symbol string, clientOrderIDs []string) []error {
+	errs := make([]error, len(clientOrderIDs))
+	venue := a.venueSymbol(symbol)
+	for start := 0; start < len(clientOrderIDs); start += MaxBybitBatchOrders {
+		end := start + MaxBybitBatchOrders
+		if end > len(clientOrderIDs) {
+			end = len(clientOrderIDs)
+		}
+		chunk := clientOrderIDs[start:end]
+		reqs := make([]BybitOrderRequest, len(chunk))
+		for j, id := range chunk {
+			reqs[j] = BybitOrderRequest{Symbol: venue, OrderLinkID: id}
+		}
+
+		t0 := time.Now()
+		items, err := a.rest.CancelBatch(reqs)
+		observeOrderChannel(OrderChannelREST, "batch_cancel", t0, err)
+		for j, id := range chunk {
+			if err != nil {
+				errs[start+j] = fmt.Errorf("bybit batch cancel failed: %w", err)
+				continue
+			}
+			errs[start+j] = a.finishCancel(symbol, id, items[j].Err)
+		}
+	}
+	return errs
+}
+
+// CancelAllOrders 撤销交易对全部挂单（/v5/order/cancel-all）
+func (a *BybitAdapter) CancelAllOrders(ctx context.Context, symbol string) error {
+	if err := a.rest.CancelAll(a.venueSymbol(symbol)); err != nil {
+		return fmt.Errorf("bybit cancel all failed: %w", err)
+	}
+
+	a.stateMu.Lock()
+	count := 0
+	for id, order := range a.orders {
+		if order.Symbol == symbol {
+			delete(a.orders, id)
+			count++
+		}
+	}
+	a.stateMu.Unlock()
+
+	log.Info().Str("symbol", symbol).Int("count", count).Msg("Bybit批量撤单完成")
+	return nil
+}
+
+// GetOpenOrders 从交易所查询活跃订单并同步到本地缓存
+func (a *BybitAdapter) GetOpenOrders(ctx context.Context, symbol string) ([]*Order, error) {
+	infos, err := a.rest.OpenOrders(a.venueSymbol(symbol))
+	if err != nil {
+		return nil, err
+	}
+
+	orders := make([]*Order, 0, len(infos))
+	a.stateMu.Lock()
+	for id, order := range a.orders {
+		if order.Symbol == symbol {
+			delete(a.orders, id)
+		}
+	}
+	for _, info := range infos {
+		order := a.fromOrderInfo(info)
+		orders = append(orders, order)
+		if order.ClientOrderID != "" {
+			a.orders[order.ClientOrderID] = order
+		}
+	}
+	a.stateMu.Unlock()
+
+	return orders, nil
+}
+
+func (a *BybitAdapter) fromOrderInfo(info BybitOrderInfo) *Order {
+	return &Order{
+		Symbol:        a.internalSymbol(info.Symbol),
+		Side:          fromBybitSide(info.Side),
+		Type:          strings.ToUpper(info.OrderType),
+		Status:        fromBybitOrderStatus(info.OrderStatus),
+		ClientOrderID: info.OrderLinkID,
+		Price:         parseFloat(info.Price),
+		Quantity:      parseFloat(info.Qty),
+		FilledQty:     parseFloat(info.CumExecQty),
+	}
+}
+
+// GetPosition returns the position for a symbol
+func (a *BybitAdapter) GetPosition(ctx context.Context, symbol string) (*Position, error) {
+	a.stateMu.RLock()
+	defer a.stateMu.RUnlock()
+
+	pos, ok := a.positions[symbol]
+	if !ok {
+		return &Position{Symbol: symbol}, nil
+	}
+	return pos, nil
+}
+
+// GetAllPositions returns all positions
+func (a *BybitAdapter) GetAllPositions(ctx context.Context) ([]*Position, error) {
+	a.stateMu.RLock()
+	defer a.stateMu.RUnlock()
+
+	positions := make([]*Position, 0, len(a.positions))
+	for _, pos := range a.positions {
+		positions = append(positions, pos)
+	}
+	return positions, nil
+}
+
+// GetAccountBalance 统一账户钱包余额与永续未实现盈亏
+func (a *BybitAdapter) GetAccountBalance(ctx context.Context) (float64, float64, error) {
+	return a.rest.WalletBalance()
+}
+
+// SymbolFilters 重新拉取 instruments-info 并刷新下单取整使用的缓存
+func (a *BybitAdapter) SymbolFilters(ctx context.Context, symbols []string) (map[string]filters.Symbol, error) {
+	out := make(map[string]filters.Symbol, len(symbols))
+	for _, symbol := range symbols {
+		venue := a.venueSymbol(symbol)
+		inst, err := a.rest.Instrument(venue)
+		if err != nil {
+			return nil, fmt.Errorf("load instrument %s: %w", venue, err)
+		}
+		if inst.TickSize > 0 && inst.QtyStep > 0 {
+			a.instMu.Lock()
+			a.instruments[venue] = inst
+			a.instMu.Unlock()
+		}
+		out[symbol] = filters.Symbol{
+			Symbol:   symbol,
+			TickSize: inst.TickSize,
+			StepSize: inst.QtyStep,
+			MinQty:   inst.MinQty,
+		}
+	}
+	return out, nil
+}
+
+// GetFundingRate 读取 tickers 中的资金费率与下次结算时间
+func (a *BybitAdapter) GetFundingRate(ctx context.Context, symbol string) (*FundingRate, error) {
+	t, err := a.rest.Ticker(a.venueSymbol(symbol))
+	if err != nil {
+		return nil, err
+	}
+	next, _ := strconv.ParseInt(t.NextFundingTime, 10, 64)
+	return &FundingRate{
+		Symbol:          symbol,
+		Rate:            parseFloat(t.FundingRate),
+		NextFundingTime: time.UnixMilli(next),
+		Timestamp:       time.Now(),
+	}, nil
+}
+
+// GetDepth 本地订单簿已同步时直接读取，否则回退到REST快照
+func (a *BybitAdapter) GetDepth(ctx context.Context, symbol string, limit int) (*Depth, error) {
+	if limit <= 0 {
+		limit = depthCallbackLevels
+	}
+	if book := a.book(symbol); book.Synced() {
+		bids, asks := book.Levels(limit)
+		return &Depth{
+			Symbol:    symbol,
+			Bids:      fromBookLevels(bids),
+			Asks:      fromBookLevels(asks),
+			Timestamp: book.UpdatedAt(),
+		}, nil
+	}
+
+	if limit > 500 {
+		limit = 500 // linear最多500档
+	}
+	_, bids, asks, err := a.rest.Orderbook(a.venueSymbol(symbol), limit)
+	if err != nil {
+		return nil, err
+	}
+	return &Depth{Symbol: symbol, Bids: bids, Asks: asks, Timestamp: time.Now()}, nil
+}
+
+func (a *BybitAdapter) book(symbol string) *orderbook.Book {
+	a.booksMu.Lock()
+	defer a.booksMu.Unlock()
+	book, ok := a.books[symbol]
+	if !ok {
+		book = orderbook.NewBook(symbol)
+		a.books[symbol] = book
+	}
+	return book
+}
+
+// StartDepthStream 订阅 orderbook.50 并维护本地订单簿，同步后推送前20档
+func (a *BybitAdapter) StartDepthStream(ctx context.Context, symbols []string, callback func(*Depth)) error {
+	a.mu.Lock()
+	a.depthCallback = callback
+	a.mu.Unlock()
+
+	topics := make([]string, len(symbols))
+	for i, s := range symbols {
+		topics[i] = fmt.Sprintf("orderbook.%d.%s", defaultBybitOrderbookDepth, a.venueSymbol(s))
+	}
+	a.startPublic(ctx, topics, func() {
+		// 重连后交易所重新推送快照，旧订单簿作废
+		for _, s := range symbols {
+			a.book(s).Reset()
+		}
+	})
+
+	log.Info().Strs("symbols", symbols).Msg("Bybit深度流已订阅")
+	return nil
+}
+
+// StartTradeStream 订阅 publicTrade 逐笔成交
+func (a *BybitAdapter) StartTradeStream(ctx context.Context, symbols []string, onTrade func(*Trade)) error {
+	a.mu.Lock()
+	a.tradeCallback = onTrade
+	a.mu.Unlock()
+
+	topics := make([]string, len(symbols))
+	for i, s := range symbols {
+		topics[i] = "publicTrade." + a.venueSymbol(s)
+	}
+	a.startPublic(ctx, topics, nil)
+
+	log.Info().Strs("symbols", symbols).Msg("Bybit逐笔成交流已订阅")
+	return nil
+}
+
+// startPublic 在独立的公共连接上订阅topics
+func (a *BybitAdapter) startPublic(ctx context.Context, topics []string, onReconnect func()) {
+	stream := &BybitStream{
+		URL:    a.publicURL,
+		Dialer: a.dialer,
+		OnOpen: func(conn *websocket.Conn) error {
+			return bybitSubscribe(conn, topics)
+		},
+		OnMessage:   a.onPublicMessage,
+		OnReconnect: onReconnect,
+	}
+	a.runStream(ctx, stream)
+}
+
+func (a *BybitAdapter) runStream(ctx context.Context, stream *BybitStream) {
+	streamCtx, cancel := context.WithCancel(ctx)
+	a.mu.Lock()
+	a.streamCancel = append(a.streamCancel, cancel)
+	a.mu.Unlock()
+	go stream.Run(streamCtx)
+}
+
+// StartUserStream 在私有连接上鉴权并订阅 order/execution/position，
+// 启动时加载仓位；重连后通过REST对账断线期间的订单与仓位
+func (a *BybitAdapter) StartUserStream(ctx context.Context, callbacks *UserStreamCallbacks) error {
+	a.mu.Lock()
+	a.userCallbacks = callbacks
+	started := a.userStarted
+	a.userStarted = true
+	a.mu.Unlock()
+	if started {
+		return nil
+	}
+
+	if err := a.syncPositions(); err != nil {
+		log.Warn().Err(err).Msg("Bybit加载仓位失败")
+	}
+
+	stream := &BybitStream{
+		URL:    a.privateURL,
+		Dialer: a.dialer,
+		OnOpen: func(conn *websocket.Conn) error {
+			if err := bybitAuth(conn, a.rest.APIKey, a.rest.Secret); err != nil {
+				return err
+			}
+			return bybitSubscribe(conn, []string{"order", "execution", "position"})
+		},
+		OnMessage:   a.onPrivateMessage,
+		OnReconnect: a.reconcileAfterGap,
+	}
+	a.runStream(ctx, stream)
+
+	log.Info().Msg("Bybit用户数据流已订阅")
+	return nil
+}
+
+// Connect 标记已连接；WebSocket连接在订阅时建立
+func (a *BybitAdapter) Connect(ctx context.Context) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.rest == nil {
+		return fmt.Errorf("bybit rest client not set")
+	}
+	a.connected = true
+	log.Info().Str("rest", a.rest.BaseURL).Msg("Bybit已连接")
+	return nil
+}
+
+// Disconnect 关闭全部WebSocket连接
+func (a *BybitAdapter) Disconnect() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, cancel := range a.streamCancel {
+		cancel()
+	}
+	a.streamCancel = nil
+	a.userStarted = false
+	a.connected = false
+	log.Info().Msg("Bybit已断开")
+	return nil
+}
+
+// IsConnected returns connection status
+func (a *BybitAdapter) IsConnected() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.connected
+}
+
+// onPublicMessage 处理公共频道推送：订单簿断档时返回错误触发重连
+func (a *BybitAdapter) onPublicMessage(raw []byte) error {
+	var msg bybitWSMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		log.Debug().Err(err).Msg("解析Bybit WS消息失败")
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(msg.Topic, "orderbook."):
+		return a.onOrderbook(msg)
+	case strings.HasPrefix(msg.Topic, "publicTrade."):
+		a.onPublicTrades(msg)
+	case msg.Op == "subscribe" && !msg.Success:
+		log.Error().Str("ret_msg", msg.RetMsg).Msg("Bybit订阅失败")
+	}
+	return nil
+}
+
+// onOrderbook 应用订单簿快照/增量。增量序号u连续递增，
+// 映射为 First=u-1、Final=u、Prev=u-1 以复用本地订单簿的断档检测；u=1表示服务重启后的新快照
+func (a *BybitAdapter) onOrderbook(msg bybitWSMessage) error {
+	var data struct {
+		Symbol   string     `json:"s"`
+		Bids     [][]string `json:"b"`
+		Asks     [][]string `json:"a"`
+		UpdateID int64      `json:"u"`
+	}
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		log.Debug().Err(err).Msg("解析Bybit订单簿失败")
+		return nil
+	}
+	symbol := a.internalSymbol(data.Symbol)
+	book := a.book(symbol)
+	bids := toBookLevels(parsePriceLevels(data.Bids))
+	asks := toBookLevels(parsePriceLevels(data.Asks))
+
+	if msg.Type == "snapshot" || data.UpdateID == 1 {
+		if err := book.ApplySnapshot(orderbook.Snapshot{LastUpdateID: data.UpdateID, Bids: bids, Asks: asks}); err != nil {
+			return err
+		}
+	} else {
+		err := book.ApplyDiff(orderbook.Update{
+			Symbol:            symbol,
+			EventTime:         msg.Ts,
+			FirstUpdateID:     data.UpdateID - 1,
+			FinalUpdateID:     data.UpdateID,
+			PrevFinalUpdateID: data.UpdateID - 1,
+			Bids:              bids,
+			Asks:              asks,
+		})
+		if errors.Is(err, orderbook.ErrNotSynced) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("bybit orderbook %s: %w", symbol, err)
+		}
+	}
+	a.emitBookDepth(symbol)
+	return nil
+}
+
+// emitBookDepth 将本地订单簿前N档推送给深度回调
+func (a *BybitAdapter) emitBookDepth(symbol string) {
+	book := a.book(symbol)
+	if !book.Synced() {
+		return
+	}
+	bids, asks := book.Levels(depthCallbackLevels)
+	if len(bids) == 0 || len(asks) == 0 {
+		return
+	}
+
+	a.mu.RLock()
+	callback := a.depthCallback
+	a.mu.RUnlock()
+
+	if callback != nil {
+		callback(&Depth{
+			Symbol:    symbol,
+			Bids:      fromBookLevels(bids),
+			Asks:      fromBookLevels(asks),
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// onPublicTrades 推送逐笔成交；Bybit成交ID为字符串，非数字时TradeID置0
+func (a *BybitAdapter) onPublicTrades(msg bybitWSMessage) {
+	var data []struct {
+		Time   int64  `json:"T"`
+		Symbol string `json:"s"`
+		Side   string `json:"S"`
+		Size   string `json:"v"`
+		Price  string `json:"p"`
+		ID     string `json:"i"`
+	}
+	if err := json.Unmarshal(msg.Data, &data); err != nil {
+		log.Debug().Err(err).Msg("解析Bybit成交失败")
+		return
+	}
+
+	a.mu.RLock()
+	callback := a.tradeCallback
+	a.mu.RUnlock()
+	if callback == nil {
+		return
+	}
+	for _, t := range data {
+		id, _ := strconv.ParseInt(t.ID, 10, 64)
+		callback(&Trade{
+			Symbol:       a.internalSymbol(t.Symbol),
+			TradeID:      id,
+			Price:        parseFloat(t.Price),
+			Quantity:     parseFloat(t.Size),
+			IsBuyerMaker: t.Side == "Sell", // 主动卖出
+			Timestamp:    time.UnixMilli(t.Time),
+		})
+	}
+}
+
+// bybitOrderEvent order 频道推送
+type bybitOrderEvent struct {
+	Symbol      string `json:"symbol"`
+	OrderLinkID string `json:"orderLinkId"`
+	Side        string `json:"side"`
+	OrderType   string `json:"orderType"`
+	Price       string `json:"price"`
+	Qty         string `json:"qty"`
+	CumExecQty  string `json:"cumExecQty"`
+	OrderStatus string `json:"orderStatus"`
+	UpdatedTime string `json:"updatedTime"`
+}
+
+// bybitExecutionEvent execution 频道推送（成交与资金费）
+type bybitExecutionEvent struct {
+	Symbol      string `json:"symbol"`
+	OrderLinkID string `json:"orderLinkId"`
+	Side        string `json:"side"`
+	OrderType   string `json:"orderType"`
+	OrderPrice  string `json:"orderPrice"`
+	OrderQty    string `json:"orderQty"`
+	LeavesQty   string `json:"leavesQty"`
+	ExecType    string `json:"execType"` // Trade | Funding | ...
+	ExecPrice   string `json:"execPrice"`
+	ExecQty     string `json:"execQty"`
+	ExecFee     string `json:"execFee"` // 正为支出
+	FeeRate     string `json:"feeRate"`
+	IsMaker     bool   `json:"isMaker"`
+	ExecTime    string `json:"execTime"`
+}
+
+// bybitPositionEvent position 频道推送
+type bybitPositionEvent struct {
+	Symbol        string `json:"symbol"`
+	Side          string `json:"side"`
+	Size          string `json:"size"`
+	EntryPrice    string `json:"entryPrice"`
+	MarkPrice     string `json:"markPrice"`
+	UnrealisedPnl string `json:"unrealisedPnl"`
+	Leverage      string `json:"leverage"`
+	LiqPrice      string `json:"liqPrice"`
+}
+
+// onPrivateMessage 处理私有频道推送。
+// 成交明细来自 execution 频道；order 频道只转发非成交状态（NEW/CANCELED/REJECTED），避免重复记账
+func (a *BybitAdapter) onPrivateMessage(raw []byte) error {
+	var msg bybitWSMessage
+	if err := json.Unmarshal(raw, &msg); err != nil {
+		log.Debug().Err(err).Msg("解析Bybit私有消息失败")
+		return nil
+	}
+
+	switch msg.Topic {
+	case "order":
+		var events []bybitOrderEvent
+		if err := json.Unmarshal(msg.Data, &events); err != nil {
+			log.Debug().Err(err).Msg("解析Bybit订单回报失败")
+			return nil
+		}
+		for _, e := range events {
+			status := fromBybitOrderStatus(e.OrderStatus)
+			if status == "PARTIALLY_FILLED" || status == "FILLED" {
+				continue
+			}
+			updated, _ := strconv.ParseInt(e.UpdatedTime, 10, 64)
+			a.handleOrderUpdate(&Order{
+				Symbol:        a.internalSymbol(e.Symbol),
+				Side:          fromBybitSide(e.Side),
+				Type:          strings.ToUpper(e.OrderType),
+				Status:        status,
+				ClientOrderID: e.OrderLinkID,
+				Price:         parseFloat(e.Price),
+				Quantity:      parseFloat(e.Qty),
+				FilledQty:     parseFloat(e.CumExecQty),
+				CreatedAt:     time.UnixMilli(updated),
+			})
+		}
+
+	case "execution":
+		var events []bybitExecutionEvent
+		if err := json.Unmarshal(msg.Data, &events); err != nil {
+			log.Debug().Err(err).Msg("解析Bybit成交回报失败")
+			return nil
+		}
+		for _, e := range events {
+			a.onExecution(e)
+		}
+
+	case "position":
+		var events []bybitPositionEvent
+		if err := json.Unmarshal(msg.Data, &events); err != nil {
+			log.Debug().Err(err).Msg("解析Bybit仓位推送失败")
+			return nil
+		}
+		positions := make([]*Position, 0, len(events))
+		for _, e := range events {
+			positions = append(positions, a.toPosition(e.Symbol, e.Side, e.Size, e.EntryPrice, e.MarkPrice, e.UnrealisedPnl, e.Leverage, e.LiqPrice))
+		}
+		if len(positions) > 0 {
+			a.handlePositionUpdate(positions)
+		}
+
+	default:
+		if msg.Op == "subscribe" && !msg.Success {
+			log.Error().Str("ret_msg", msg.RetMsg).Msg("Bybit私有频道订阅失败")
+		}
+	}
+	return nil
+}
+
+// onExecution 成交转换为带本次成交明细的订单回报；资金费结算转换为资金费事件
+func (a *BybitAdapter) onExecution(e bybitExecutionEvent) {
+	symbol := a.internalSymbol(e.Symbol)
+	execTime, _ := strconv.ParseInt(e.ExecTime, 10, 64)
+
+	switch e.ExecType {
+	case "Trade":
+		qty := parseFloat(e.OrderQty)
+		leaves := parseFloat(e.LeavesQty)
+		status := "PARTIALLY_FILLED"
+		if leaves <= 0 {
+			status = "FILLED"
+		}
+		a.handleOrderUpdate(&Order{
+			Symbol:          symbol,
+			Side:            fromBybitSide(e.Side),
+			Type:            strings.ToUpper(e.OrderType),
+			Status:          status,
+			ClientOrderID:   e.OrderLinkID,
+			Price:           parseFloat(e.OrderPrice),
+			Quantity:        qty,
+			FilledQty:       qty - leaves,
+			CreatedAt:       time.UnixMilli(execTime),
+			LastFilledQty:   parseFloat(e.ExecQty),
+			LastFilledPrice: parseFloat(e.ExecPrice),
+			Commission:      parseFloat(e.ExecFee),
+			IsMaker:         e.IsMaker,
+		})
+
+	case "Funding":
+		a.mu.RLock()
+		callbacks := a.userCallbacks
+		a.mu.RUnlock()
+		if callbacks != nil && callbacks.OnFunding != nil {
+			callbacks.OnFunding(&FundingRate{
+				Symbol:    symbol,
+				Rate:      parseFloat(e.FeeRate),
+				Payment:   -parseFloat(e.ExecFee),
+				Timestamp: time.UnixMilli(execTime),
+			})
+		}
+	}
+}
+
+func (a *BybitAdapter) toPosition(venue, side, size, entry, mark, upnl, leverage, liq string) *Position {
+	p := BybitPosition{Side: side, Size: size}
+	signed := p.SignedSize()
+	return &Position{
+		Symbol:           a.internalSymbol(venue),
+		Size:             signed,
+		EntryPrice:       parseFloat(entry),
+		UnrealizedPNL:    parseFloat(upnl),
+		Notional:         math.Abs(signed) * parseFloat(mark),
+		Leverage:         parseFloat(leverage),
+		LiquidationPrice: parseFloat(liq),
+	}
+}
+
+// handleOrderUpdate 更新本地订单缓存并回调；已终结订单的重复回报丢弃
+func (a *BybitAdapter) handleOrderUpdate(order *Order) {
+	a.stateMu.Lock()
+	if prev, ok := a.orders[order.ClientOrderID]; ok && isFinalStatus(prev.Status) && order.FilledQty <= prev.FilledQty {
+		a.stateMu.Unlock()
+		return
+	}
+	a.orders[order.ClientOrderID] = order
+	a.stateMu.Unlock()
+
+	a.mu.RLock()
+	callbacks := a.userCallbacks
+	a.mu.RUnlock()
+
+	if callbacks != nil && callbacks.OnOrderUpdate != nil {
+		callbacks.OnOrderUpdate(order)
+	}
+}
+
+// handlePositionUpdate 更新本地仓位缓存并回调
+func (a *BybitAdapter) handlePositionUpdate(positions []*Position) {
+	a.stateMu.Lock()
+	for _, pos := range positions {
+		a.positions[pos.Symbol] = pos
+	}
+	a.stateMu.Unlock()
+
+	a.mu.RLock()
+	callbacks := a.userCallbacks
+	a.mu.RUnlock()
+
+	if callbacks != nil && callbacks.OnAccountUpdate != nil {
+		callbacks.OnAccountUpdate(positions)
+	}
+}
+
+// SyncPositions 通过REST加载仓位并推送；之后由私有流增量更新
+func (a *BybitAdapter) SyncPositions(ctx context.Context) error {
+	return a.syncPositions()
+}
+
+// syncPositions 通过REST加载全部USDT与USDC结算仓位并推送
+func (a *BybitAdapter) syncPositions() error {
+	list, err := a.rest.Positions("")
+	if err != nil {
+		return err
+	}
+	a.stateMu.RLock()
+	var updates []*Position
+	for _, p := range list {
+		pos := a.toPosition(p.Symbol, p.Side, p.Size, p.AvgPrice, p.MarkPrice, p.UnrealisedPnl, p.Leverage, p.LiqPrice)
+		if _, known := a.positions[pos.Symbol]; !known && pos.Size == 0 {
+			continue
+		}
+		updates = append(updates, pos)
+	}
+	a.stateMu.RUnlock()
+	if len(updates) > 0 {
+		a.handlePositionUpdate(updates)
+	}
+	return nil
+}
+
+// reconcileAfterGap 私有连接重连后通过REST对账：本地活跃但已不在挂单列表中的订单查询终态并补发回报，
+// 断线期间的成交按累计成交量差额补发（价格使用成交均价，不含手续费明细），随后同步仓位
+func (a *BybitAdapter) reconcileAfterGap() {
+	a.stateMu.RLock()
+	local := make(map[string]Order)
+	for id, o := range a.orders {
+		if o.Status == "NEW" || o.Status == "PARTIALLY_FILLED" {
+			local[id] = *o
+		}
+	}
+	a.stateMu.RUnlock()
+
+	open, err := a.rest.OpenOrders("")
+	if err != nil {
+		log.Error().Err(err).Msg("Bybit对账：获取活跃订单失败")
+	} else {
+		stillOpen := make(map[string]BybitOrderInfo, len(open))
+		for _, o := range open {
+			stillOpen[o.OrderLinkID] = o
+		}
+
+		recovered := 0
+		for id, o := range local {
+			info, ok := stillOpen[id]
+			if !ok {
+				q, err := a.rest.OrderHistory(a.venueSymbol(o.Symbol), id)
+				if err != nil {
+					log.Error().Err(err).Str("client_id", id).Msg("Bybit对账：查询订单失败")
+					continue
+				}
+				info = q
+			}
+			filled := parseFloat(info.CumExecQty)
+			if ok && filled <= o.FilledQty {
+				continue
+			}
+			update := &Order{
+				Symbol:        o.Symbol,
+				Side:          o.Side,
+				Type:          o.Type,
+				Status:        fromBybitOrderStatus(info.OrderStatus),
+				ClientOrderID: id,
+				Price:         o.Price,
+				Quantity:      o.Quantity,
+				FilledQty:     filled,
+				CreatedAt:     o.CreatedAt,
+			}
+			if missed := filled - o.FilledQty; missed > 0 {
+				update.LastFilledQty = missed
+				update.LastFilledPrice = o.Price
+				if avg := parseFloat(info.AvgPrice); avg > 0 {
+					update.LastFilledPrice = avg
+				}
+			}
+			a.handleOrderUpdate(update)
+			recovered++
+		}
+		log.Info().Int("open", len(open)).Int("recovered", recovered).Msg("Bybit对账：订单状态已同步")
+	}
+
+	if err := a.syncPositions(); err != nil {
+		log.Error().Err(err).Msg("Bybit对账：获取仓位失败")
+	}
+}
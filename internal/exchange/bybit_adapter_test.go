@@ -0,0 +1,292 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// bybitStandIn 同时模拟 Bybit REST 与公共/私有 WebSocket
+type bybitStandIn struct {
+	t        *testing.T
+	srv      *httptest.Server
+	upgrader websocket.Upgrader
+
+	mu          sync.Mutex
+	publicConns map[string]int // 按订阅的首个topic计数
+	orderBodies []string
+
+	onPublic  func(conn *websocket.Conn, topic string, n int)
+	onPrivate func(conn *websocket.Conn)
+}
+
+func newBybitStandIn(t *testing.T) *bybitStandIn {
+	s := &bybitStandIn{t: t, publicConns: make(map[string]int)}
+	s.srv = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+func (s *bybitStandIn) wsURL(path string) string {
+	return "ws" + strings.TrimPrefix(s.srv.URL, "http") + path
+}
+
+func (s *bybitStandIn) adapter() *BybitAdapter {
+	rest := &BybitRESTClient{BaseURL: s.srv.URL, APIKey: "key", Secret: "secret", HTTPClient: s.srv.Client()}
+	return NewBybitAdapter(rest, BybitAdapterConfig{
+		PublicWSURL:  s.wsURL("/v5/public/linear"),
+		PrivateWSURL: s.wsURL("/v5/private"),
+		SymbolMap:    map[string]string{"BTC": "BTCUSDT"},
+	})
+}
+
+func (s *bybitStandIn) handle(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v5/public/linear":
+		conn, err := s.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var sub struct {
+			Op   string   `json:"op"`
+			Args []string `json:"args"`
+		}
+		if err := conn.ReadJSON(&sub); err != nil || sub.Op != "subscribe" || len(sub.Args) == 0 {
+			return
+		}
+		s.mu.Lock()
+		s.publicConns[sub.Args[0]]++
+		n := s.publicConns[sub.Args[0]]
+		s.mu.Unlock()
+		s.onPublic(conn, sub.Args[0], n)
+		conn.ReadMessage() // 保持连接直到客户端断开
+	case "/v5/private":
+		conn, err := s.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var auth struct {
+			Op   string   `json:"op"`
+			Args []string `json:"args"`
+		}
+		if err := conn.ReadJSON(&auth); err != nil || auth.Op != "auth" || len(auth.Args) != 3 {
+			s.t.Errorf("unexpected auth request %+v", auth)
+			return
+		}
+		ok := auth.Args[0] == "key" && auth.Args[2] == BybitSign("secret", "GET/realtime"+auth.Args[1])
+		conn.WriteJSON(map[string]interface{}{"op": "auth", "success": ok})
+		var sub map[string]interface{}
+		if err := conn.ReadJSON(&sub); err != nil || sub["op"] != "subscribe" {
+			return
+		}
+		s.onPrivate(conn)
+		conn.ReadMessage()
+	case "/v5/position/list":
+		if r.URL.Query().Get("settleCoin") == "USDC" {
+			io.WriteString(w, `{"retCode":0,"result":{"list":[]}}`)
+			return
+		}
+		io.WriteString(w, `{"retCode":0,"result":{"list":[{"symbol":"BTCUSDT","side":"Sell","size":"0.5","avgPrice":"100","markPrice":"101","unrealisedPnl":"-0.5","leverage":"5","liqPrice":"150"}]}}`)
+	case "/v5/market/instruments-info":
+		io.WriteString(w, `{"retCode":0,"result":{"list":[{"symbol":"BTCUSDT","priceFilter":{"tickSize":"0.5"},"lotSizeFilter":{"qtyStep":"0.001","minOrderQty":"0.001"}}]}}`)
+	case "/v5/order/create", "/v5/order/create-batch":
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		s.orderBodies = append(s.orderBodies, string(body))
+		s.mu.Unlock()
+		if r.URL.Path == "/v5/order/create" {
+			io.WriteString(w, `{"retCode":0,"result":{"orderId":"o-1"}}`)
+			return
+		}
+		io.WriteString(w, `{"retCode":0,"result":{"list":[{"orderId":"o-1"},{"orderId":""}]},"retExtInfo":{"list":[{"code":0},{"code":10001,"msg":"params error"}]}}`)
+	default:
+		s.t.Errorf("unexpected path %s", r.URL.Path)
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestBybitAdapterDepthAndTrades(t *testing.T) {
+	s := newBybitStandIn(t)
+	s.onPublic = func(conn *websocket.Conn, topic string, n int) {
+		if topic == "publicTrade.BTCUSDT" {
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"topic":"publicTrade.BTCUSDT","type":"snapshot","ts":3,"data":[{"T":3,"s":"BTCUSDT","S":"Sell","v":"0.2","p":"99","i":"abc-1"}]}`))
+			return
+		}
+		if topic != "orderbook.50.BTCUSDT" {
+			t.Errorf("unexpected topic %s", topic)
+			return
+		}
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"topic":"orderbook.50.BTCUSDT","type":"snapshot","ts":1,"data":{"s":"BTCUSDT","b":[["100","1"],["99","2"]],"a":[["101","1"]],"u":10}}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"topic":"orderbook.50.BTCUSDT","type":"delta","ts":2,"data":{"s":"BTCUSDT","b":[["100","0"]],"a":[["100.5","3"]],"u":11}}`))
+		if n == 1 {
+			// 序号断档：客户端应断开重连，重新获取快照
+			conn.WriteMessage(websocket.TextMessage, []byte(`{"topic":"orderbook.50.BTCUSDT","type":"delta","ts":4,"data":{"s":"BTCUSDT","b":[["98","1"]],"a":[],"u":13}}`))
+		}
+	}
+
+	a := s.adapter()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer a.Disconnect()
+
+	var mu sync.Mutex
+	var depths []*Depth
+	var trades []*Trade
+	a.StartDepthStream(ctx, []string{"BTC"}, func(d *Depth) {
+		mu.Lock()
+		depths = append(depths, d)
+		mu.Unlock()
+	})
+	a.StartTradeStream(ctx, []string{"BTC"}, func(tr *Trade) {
+		mu.Lock()
+		trades = append(trades, tr)
+		mu.Unlock()
+	})
+
+	waitFor(t, "depth resubscribe after gap", func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.publicConns["orderbook.50.BTCUSDT"] >= 2
+	})
+	waitFor(t, "depth and trades", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(depths) >= 2 && len(trades) >= 1
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	d := depths[1]
+	if d.Symbol != "BTC" || d.Bids[0].Price != 99 || d.Asks[0].Price != 100.5 {
+		t.Fatalf("unexpected depth after delta: %+v", d)
+	}
+	tr := trades[0]
+	if tr.Symbol != "BTC" || tr.Price != 99 || tr.Quantity != 0.2 || tr.TakerSide() != "SELL" {
+		t.Fatalf("unexpected trade %+v", tr)
+	}
+	if book := a.book("BTC"); !book.Synced() {
+		t.Fatalf("book should be synced after resubscribe")
+	}
+}
+
+func TestBybitAdapterUserStream(t *testing.T) {
+	s := newBybitStandIn(t)
+	s.onPrivate = func(conn *websocket.Conn) {
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"topic":"order","data":[{"symbol":"BTCUSDT","orderLinkId":"cid-1","side":"Buy","orderType":"Limit","price":"100","qty":"1","cumExecQty":"0","orderStatus":"New"}]}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"topic":"execution","data":[{"symbol":"BTCUSDT","orderLinkId":"cid-1","side":"Buy","orderType":"Limit","orderPrice":"100","orderQty":"1","leavesQty":"0.6","execType":"Trade","execPrice":"100","execQty":"0.4","execFee":"-0.002","isMaker":true}]}`))
+		// order频道的部分成交状态由execution覆盖，不应重复回调
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"topic":"order","data":[{"symbol":"BTCUSDT","orderLinkId":"cid-1","side":"Buy","orderType":"Limit","price":"100","qty":"1","cumExecQty":"0.4","orderStatus":"PartiallyFilled"}]}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"topic":"execution","data":[{"symbol":"BTCUSDT","side":"Sell","execType":"Funding","execFee":"0.05","feeRate":"0.0001"}]}`))
+		conn.WriteMessage(websocket.TextMessage, []byte(`{"topic":"position","data":[{"symbol":"BTCUSDT","side":"Buy","size":"0.4","entryPrice":"100","markPrice":"100","unrealisedPnl":"0"}]}`))
+	}
+
+	a := s.adapter()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	defer a.Disconnect()
+
+	var mu sync.Mutex
+	var orders []*Order
+	var fundings []*FundingRate
+	var positions []*Position
+	err := a.StartUserStream(ctx, &UserStreamCallbacks{
+		OnOrderUpdate: func(o *Order) {
+			mu.Lock()
+			orders = append(orders, o)
+			mu.Unlock()
+		},
+		OnAccountUpdate: func(ps []*Position) {
+			mu.Lock()
+			positions = append(positions, ps...)
+			mu.Unlock()
+		},
+		OnFunding: func(f *FundingRate) {
+			mu.Lock()
+			fundings = append(fundings, f)
+			mu.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatalf("start user stream: %v", err)
+	}
+
+	waitFor(t, "private events", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(positions) >= 2 && len(fundings) == 1
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(orders) != 2 {
+		t.Fatalf("expected NEW + fill updates, got %d", len(orders))
+	}
+	fill := orders[1]
+	if fill.Symbol != "BTC" || fill.Status != "PARTIALLY_FILLED" || fill.FilledQty != 0.4 ||
+		fill.LastFilledQty != 0.4 || fill.Commission != -0.002 || !fill.IsMaker {
+		t.Fatalf("unexpected fill %+v", fill)
+	}
+	if fundings[0].Symbol != "BTC" || fundings[0].Payment != -0.05 {
+		t.Fatalf("unexpected funding %+v", fundings[0])
+	}
+	// 启动时通过REST加载的空头仓位，随后由推送覆盖
+	if positions[0].Size != -0.5 || positions[0].Symbol != "BTC" {
+		t.Fatalf("unexpected initial position %+v", positions[0])
+	}
+	pos, _ := a.GetPosition(ctx, "BTC")
+	if pos.Size != 0.4 {
+		t.Fatalf("position cache not updated: %+v", pos)
+	}
+}
+
+func TestBybitAdapterOrderFormatting(t *testing.T) {
+	s := newBybitStandIn(t)
+	a := s.adapter()
+	ctx := context.Background()
+
+	order, err := a.PlaceOrder(ctx, &Order{Symbol: "BTC", Side: "BUY", Price: 100.74, Quantity: 0.0129})
+	if err != nil {
+		t.Fatalf("place: %v", err)
+	}
+	if order.Price != 100.5 || order.Quantity != 0.012 || !strings.HasPrefix(order.ClientOrderID, "phoenix-BTC-") {
+		t.Fatalf("unexpected order %+v", order)
+	}
+
+	results := a.PlaceOrders(ctx, []*Order{
+		{Symbol: "BTC", Side: "SELL", Price: 101, Quantity: 0.01},
+		{Symbol: "BTC", Side: "SELL", Price: 102, Quantity: 0.01},
+		{Symbol: "BTC", Side: "SELL", Price: 103, Quantity: 0.0001}, // 低于最小下单量，不提交
+	})
+	if results[0].Err != nil || results[1].Err == nil || results[2].Err == nil {
+		t.Fatalf("unexpected batch results %+v", results)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var req BybitOrderRequest
+	if err := json.Unmarshal([]byte(s.orderBodies[0]), &req); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if req.Symbol != "BTCUSDT" || req.Price != "100.5" || req.Qty != "0.012" || req.Side != "Buy" || req.TimeInForce != "PostOnly" {
+		t.Fatalf("unexpected create request %+v", req)
+	}
+	var batch struct {
+		Category string              `json:"category"`
+		Request  []BybitOrderRequest `json:"request"`
+	}
+	if err := json.Unmarshal([]byte(s.orderBodies[1]), &batch); err != nil {
+		t.Fatalf("decode batch: %v", err)
+	}
+	if batch.Category != "linear" || len(batch.Request) != 2 || batch.Request[1].Price != "102.0" {
+		t.Fatalf("unexpected batch request %+v", batch)
+	}
+}
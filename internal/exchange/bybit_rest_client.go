@@ -0,0 +1,474 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Bybit 批量接口（linear）单次请求的条目上限
+const MaxBybitBatchOrders = 10
+
+// BybitRESTClient Bybit v5 REST客户端（linear永续）；HTTPClient 可注入 httptest。
+type BybitRESTClient struct {
+	BaseURL      string
+	APIKey       string
+	Secret       string
+	HTTPClient   *http.Client
+	RecvWindowMs int64
+	Limiter      RateLimiter
+}
+
+// bybitResponse v5 统一响应包
+type bybitResponse struct {
+	RetCode    int             `json:"retCode"`
+	RetMsg     string          `json:"retMsg"`
+	Result     json.RawMessage `json:"result"`
+	RetExtInfo json.RawMessage `json:"retExtInfo"`
+	Time       int64           `json:"time"`
+}
+
+// BybitOrderRequest 下单/改单/撤单请求；价格与数量由调用方按交易规则格式化
+type BybitOrderRequest struct {
+	Category    string `json:"category,omitempty"`
+	Symbol      string `json:"symbol"`
+	Side        string `json:"side,omitempty"`
+	OrderType   string `json:"orderType,omitempty"`
+	Qty         string `json:"qty,omitempty"`
+	Price       string `json:"price,omitempty"`
+	TimeInForce string `json:"timeInForce,omitempty"`
+	OrderLinkID string `json:"orderLinkId,omitempty"`
+	ReduceOnly  bool   `json:"reduceOnly,omitempty"`
+}
+
+// BybitOrderInfo /v5/order/realtime 返回的订单
+type BybitOrderInfo struct {
+	OrderID     string `json:"orderId"`
+	OrderLinkID string `json:"orderLinkId"`
+	Symbol      string `json:"symbol"`
+	Side        string `json:"side"`
+	OrderType   string `json:"orderType"`
+	Price       string `json:"price"`
+	Qty         string `json:"qty"`
+	CumExecQty  string `json:"cumExecQty"`
+	AvgPrice    string `json:"avgPrice"`
+	OrderStatus string `json:"orderStatus"`
+}
+
+// BybitPosition /v5/position/list 返回的仓位
+type BybitPosition struct {
+	Symbol        string `json:"symbol"`
+	Side          string `json:"side"` // Buy | Sell | 空（无仓位）
+	Size          string `json:"size"`
+	AvgPrice      string `json:"avgPrice"`
+	MarkPrice     string `json:"markPrice"`
+	UnrealisedPnl string `json:"unrealisedPnl"`
+	Leverage      string `json:"leverage"`
+	LiqPrice      string `json:"liqPrice"`
+}
+
+// SignedSize 带方向的仓位数量（空头为负）
+func (p BybitPosition) SignedSize() float64 {
+	size := parseFloat(p.Size)
+	if p.Side == "Sell" {
+		return -size
+	}
+	return size
+}
+
+// BybitTicker /v5/market/tickers 中与资金费相关的字段
+type BybitTicker struct {
+	Symbol          string `json:"symbol"`
+	MarkPrice       string `json:"markPrice"`
+	FundingRate     string `json:"fundingRate"`
+	NextFundingTime string `json:"nextFundingTime"`
+}
+
+// BybitInstrument 交易规则：价格步长、数量步长与最小下单量
+type BybitInstrument struct {
+	Symbol   string
+	TickSize float64
+	QtyStep  float64
+	MinQty   float64
+}
+
+// CreateOrder 调用 /v5/order/create 下单，返回交易所订单ID
+func (c *BybitRESTClient) CreateOrder(req BybitOrderRequest) (string, error) {
+	req.Category = bybitCategoryLinear
+	var res struct {
+		OrderID string `json:"orderId"`
+	}
+	if err := c.post("/v5/order/create", req, &res); err != nil {
+		return "", err
+	}
+	return res.OrderID, nil
+}
+
+// AmendOrder 调用 /v5/order/amend 按orderLinkId修改价格与数量
+func (c *BybitRESTClient) AmendOrder(req BybitOrderRequest) (string, error) {
+	req.Category = bybitCategoryLinear
+	var res struct {
+		OrderID string `json:"orderId"`
+	}
+	if err := c.post("/v5/order/amend", req, &res); err != nil {
+		return "", err
+	}
+	return res.OrderID, nil
+}
+
+// CancelOrder 调用 /v5/order/cancel 按orderLinkId撤单
+func (c *BybitRESTClient) CancelOrder(symbol, orderLinkID string) error {
+	req := BybitOrderRequest{Category: bybitCategoryLinear, Symbol: symbol, OrderLinkID: orderLinkID}
+	return c.post("/v5/order/cancel", req, nil)
+}
+
+// CancelAll 调用 /v5/order/cancel-all 撤销交易对全部挂单
+func (c *BybitRESTClient) CancelAll(symbol string) error {
+	req := BybitOrderRequest{Category: bybitCategoryLinear, Symbol: symbol}
+	return c.post("/v5/order/cancel-all", req, nil)
+}
+
+// CreateBatch 调用 /v5/order/create-batch 批量下单（单次最多10个），结果与请求按下标一一对应
+func (c *BybitRESTClient) CreateBatch(reqs []BybitOrderRequest) ([]BatchItemResult, error) {
+	return c.batch("/v5/order/create-batch", reqs)
+}
+
+// AmendBatch 调用 /v5/order/amend-batch 批量改单（单次最多10个）
+func (c *BybitRESTClient) AmendBatch(reqs []BybitOrderRequest) ([]BatchItemResult, error) {
+	return c.batch("/v5/order/amend-batch", reqs)
+}
+
+// CancelBatch 调用 /v5/order/cancel-batch 批量撤单（单次最多10个）
+func (c *BybitRESTClient) CancelBatch(reqs []BybitOrderRequest) ([]BatchItemResult, error) {
+	return c.batch("/v5/order/cancel-batch", reqs)
+}
+
+// batch 批量接口：result.list 为逐条订单，retExtInfo.list 为逐条 {code,msg}
+func (c *BybitRESTClient) batch(path string, reqs []BybitOrderRequest) ([]BatchItemResult, error) {
+	if len(reqs) == 0 || len(reqs) > MaxBybitBatchOrders {
+		return nil, fmt.Errorf("batch size must be 1-%d, got %d", MaxBybitBatchOrders, len(reqs))
+	}
+	items := make([]BybitOrderRequest, len(reqs))
+	for i, r := range reqs {
+		r.Category = ""
+		items[i] = r
+	}
+	body := struct {
+		Category string              `json:"category"`
+		Request  []BybitOrderRequest `json:"request"`
+	}{Category: bybitCategoryLinear, Request: items}
+
+	resp, err := c.do(http.MethodPost, path, nil, body)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		List []struct {
+			OrderID string `json:"orderId"`
+		} `json:"list"`
+	}
+	var ext struct {
+		List []struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		} `json:"list"`
+	}
+	if err := json.Unmarshal(resp.Result, &res); err != nil {
+		return nil, fmt.Errorf("decode batch response: %w", err)
+	}
+	if len(resp.RetExtInfo) > 0 {
+		if err := json.Unmarshal(resp.RetExtInfo, &ext); err != nil {
+			return nil, fmt.Errorf("decode batch retExtInfo: %w", err)
+		}
+	}
+	if len(res.List) != len(reqs) {
+		return nil, fmt.Errorf("batch response has %d items, expected %d", len(res.List), len(reqs))
+	}
+	results := make([]BatchItemResult, len(reqs))
+	for i, item := range res.List {
+		if i < len(ext.List) && ext.List[i].Code != 0 {
+			results[i].Err = newBybitError(ext.List[i].Code, ext.List[i].Msg)
+			continue
+		}
+		results[i].OrderID = item.OrderID
+	}
+	return results, nil
+}
+
+// OpenOrders 调用 /v5/order/realtime 查询活跃订单；symbol为空时逐个结算币种查询全部合约（自动翻页）
+func (c *BybitRESTClient) OpenOrders(symbol string) ([]BybitOrderInfo, error) {
+	if symbol != "" {
+		return c.openOrders(url.Values{"symbol": {symbol}})
+	}
+	var orders []BybitOrderInfo
+	for _, coin := range bybitSettleCoins {
+		list, err := c.openOrders(url.Values{"settleCoin": {coin}})
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, list...)
+	}
+	return orders, nil
+}
+
+func (c *BybitRESTClient) openOrders(filter url.Values) ([]BybitOrderInfo, error) {
+	var orders []BybitOrderInfo
+	cursor := ""
+	for {
+		q := url.Values{"category": {bybitCategoryLinear}, "limit": {"50"}}
+		for k, v := range filter {
+			q[k] = v
+		}
+		if cursor != "" {
+			q.Set("cursor", cursor)
+		}
+		var res struct {
+			List           []BybitOrderInfo `json:"list"`
+			NextPageCursor string           `json:"nextPageCursor"`
+		}
+		if err := c.get("/v5/order/realtime", q, true, &res); err != nil {
+			return nil, err
+		}
+		orders = append(orders, res.List...)
+		if res.NextPageCursor == "" || len(res.List) == 0 {
+			return orders, nil
+		}
+		cursor = res.NextPageCursor
+	}
+}
+
+// OrderHistory 调用 /v5/order/history 按orderLinkId查询订单（含已终结订单）
+func (c *BybitRESTClient) OrderHistory(symbol, orderLinkID string) (BybitOrderInfo, error) {
+	q := url.Values{"category": {bybitCategoryLinear}, "symbol": {symbol}, "orderLinkId": {orderLinkID}}
+	var res struct {
+		List []BybitOrderInfo `json:"list"`
+	}
+	if err := c.get("/v5/order/history", q, true, &res); err != nil {
+		return BybitOrderInfo{}, err
+	}
+	if len(res.List) == 0 {
+		return BybitOrderInfo{}, newBybitError(110001, "order not exists")
+	}
+	return res.List[0], nil
+}
+
+// Positions 调用 /v5/position/list 查询仓位；symbol为空时逐个结算币种查询全部合约
+func (c *BybitRESTClient) Positions(symbol string) ([]BybitPosition, error) {
+	if symbol != "" {
+		return c.positions(url.Values{"symbol": {symbol}})
+	}
+	var positions []BybitPosition
+	for _, coin := range bybitSettleCoins {
+		list, err := c.positions(url.Values{"settleCoin": {coin}})
+		if err != nil {
+			return nil, err
+		}
+		positions = append(positions, list...)
+	}
+	return positions, nil
+}
+
+func (c *BybitRESTClient) positions(filter url.Values) ([]BybitPosition, error) {
+	q := url.Values{"category": {bybitCategoryLinear}}
+	for k, v := range filter {
+		q[k] = v
+	}
+	var res struct {
+		List []BybitPosition `json:"list"`
+	}
+	if err := c.get("/v5/position/list", q, true, &res); err != nil {
+		return nil, err
+	}
+	return res.List, nil
+}
+
+// WalletBalance 调用 /v5/account/wallet-balance（统一账户），返回钱包余额与永续未实现盈亏
+func (c *BybitRESTClient) WalletBalance() (float64, float64, error) {
+	q := url.Values{"accountType": {"UNIFIED"}}
+	var res struct {
+		List []struct {
+			TotalWalletBalance string `json:"totalWalletBalance"`
+			TotalPerpUPL       string `json:"totalPerpUPL"`
+		} `json:"list"`
+	}
+	if err := c.get("/v5/account/wallet-balance", q, true, &res); err != nil {
+		return 0, 0, err
+	}
+	if len(res.List) == 0 {
+		return 0, 0, fmt.Errorf("empty wallet balance")
+	}
+	return parseFloat(res.List[0].TotalWalletBalance), parseFloat(res.List[0].TotalPerpUPL), nil
+}
+
+// Ticker 调用 /v5/market/tickers 获取标记价格与资金费率
+func (c *BybitRESTClient) Ticker(symbol string) (BybitTicker, error) {
+	q := url.Values{"category": {bybitCategoryLinear}, "symbol": {symbol}}
+	var res struct {
+		List []BybitTicker `json:"list"`
+	}
+	if err := c.get("/v5/market/tickers", q, false, &res); err != nil {
+		return BybitTicker{}, err
+	}
+	if len(res.List) == 0 {
+		return BybitTicker{}, fmt.Errorf("ticker not found: %s", symbol)
+	}
+	return res.List[0], nil
+}
+
+// Orderbook 调用 /v5/market/orderbook 获取深度快照，返回更新序号u与买卖档位
+func (c *BybitRESTClient) Orderbook(symbol string, limit int) (int64, []PriceLevel, []PriceLevel, error) {
+	q := url.Values{"category": {bybitCategoryLinear}, "symbol": {symbol}, "limit": {strconv.Itoa(limit)}}
+	var res struct {
+		Bids     [][]string `json:"b"`
+		Asks     [][]string `json:"a"`
+		UpdateID int64      `json:"u"`
+	}
+	if err := c.get("/v5/market/orderbook", q, false, &res); err != nil {
+		return 0, nil, nil, err
+	}
+	return res.UpdateID, parsePriceLevels(res.Bids), parsePriceLevels(res.Asks), nil
+}
+
+// Instrument 调用 /v5/market/instruments-info 获取交易规则
+func (c *BybitRESTClient) Instrument(symbol string) (BybitInstrument, error) {
+	q := url.Values{"category": {bybitCategoryLinear}, "symbol": {symbol}}
+	var res struct {
+		List []struct {
+			Symbol      string `json:"symbol"`
+			PriceFilter struct {
+				TickSize string `json:"tickSize"`
+			} `json:"priceFilter"`
+			LotSizeFilter struct {
+				QtyStep     string `json:"qtyStep"`
+				MinOrderQty string `json:"minOrderQty"`
+			} `json:"lotSizeFilter"`
+		} `json:"list"`
+	}
+	if err := c.get("/v5/market/instruments-info", q, false, &res); err != nil {
+		return BybitInstrument{}, err
+	}
+	if len(res.List) == 0 {
+		return BybitInstrument{}, fmt.Errorf("instrument not found: %s", symbol)
+	}
+	info := res.List[0]
+	return BybitInstrument{
+		Symbol:   info.Symbol,
+		TickSize: parseFloat(info.PriceFilter.TickSize),
+		QtyStep:  parseFloat(info.LotSizeFilter.QtyStep),
+		MinQty:   parseFloat(info.LotSizeFilter.MinOrderQty),
+	}, nil
+}
+
+func (c *BybitRESTClient) get(path string, query url.Values, signed bool, out interface{}) error {
+	var resp *bybitResponse
+	var err error
+	if signed {
+		resp, err = c.do(http.MethodGet, path, query, nil)
+	} else {
+		resp, err = c.doPublic(path, query)
+	}
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, out)
+}
+
+func (c *BybitRESTClient) post(path string, body interface{}, out interface{}) error {
+	resp, err := c.do(http.MethodPost, path, nil, body)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, out)
+}
+
+// do 发送签名请求：GET签名query串，POST签名JSON body
+func (c *BybitRESTClient) do(method, path string, query url.Values, body interface{}) (*bybitResponse, error) {
+	if c == nil || c.HTTPClient == nil {
+		return nil, fmt.Errorf("http client not set")
+	}
+	payload := ""
+	var reader io.Reader
+	endpoint := c.BaseURL + path
+	if method == http.MethodGet {
+		payload = query.Encode()
+		if payload != "" {
+			endpoint += "?" + payload
+		}
+	} else {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = string(raw)
+		reader = bytes.NewReader(raw)
+	}
+
+	recvWindow := c.RecvWindowMs
+	if recvWindow <= 0 {
+		recvWindow = defaultBybitRecvWindowMs
+	}
+	ts := strconv.FormatInt(timeNowMillis(), 10)
+	rw := strconv.FormatInt(recvWindow, 10)
+
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-BAPI-API-KEY", c.APIKey)
+	req.Header.Set("X-BAPI-TIMESTAMP", ts)
+	req.Header.Set("X-BAPI-RECV-WINDOW", rw)
+	req.Header.Set("X-BAPI-SIGN", BybitSign(c.Secret, ts+c.APIKey+rw+payload))
+	if reader != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.send(req)
+}
+
+// doPublic 发送无需签名的行情请求
+func (c *BybitRESTClient) doPublic(path string, query url.Values) (*bybitResponse, error) {
+	if c == nil || c.HTTPClient == nil {
+		return nil, fmt.Errorf("http client not set")
+	}
+	endpoint := c.BaseURL + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.send(req)
+}
+
+func (c *BybitRESTClient) send(req *http.Request) (*bybitResponse, error) {
+	if c.Limiter != nil {
+		c.Limiter.Wait()
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("bybit %s status %d: %s", req.URL.Path, resp.StatusCode, bytes.TrimSpace(body))
+	}
+	var out bybitResponse
+	if err := json.Unmarshal(body, &out); err != nil {
+		return nil, fmt.Errorf("decode bybit response: %w", err)
+	}
+	if out.RetCode != 0 {
+		return nil, newBybitError(out.RetCode, out.RetMsg)
+	}
+	return &out, nil
+}
@@ -0,0 +1,147 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBybitRESTClientSignature(t *testing.T) {
+	timeNowMillis = func() int64 { return 1234567890000 }
+	defer func() { timeNowMillis = func() int64 { return time.Now().UnixMilli() } }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := r.URL.RawQuery
+		if r.Method == http.MethodPost {
+			body, _ := io.ReadAll(r.Body)
+			payload = string(body)
+		}
+		if got := r.Header.Get("X-BAPI-TIMESTAMP"); got != "1234567890000" {
+			t.Fatalf("timestamp header %q", got)
+		}
+		if got := r.Header.Get("X-BAPI-RECV-WINDOW"); got != "5000" {
+			t.Fatalf("recv window header %q", got)
+		}
+		want := BybitSign("secret", "1234567890000"+"key"+"5000"+payload)
+		if got := r.Header.Get("X-BAPI-SIGN"); got != want {
+			t.Fatalf("%s %s: signature %s, want %s", r.Method, r.URL.Path, got, want)
+		}
+
+		switch r.URL.Path {
+		case "/v5/order/create":
+			var req BybitOrderRequest
+			if err := json.Unmarshal([]byte(payload), &req); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			if req.Category != "linear" || req.Side != "Buy" || req.TimeInForce != "PostOnly" {
+				t.Fatalf("unexpected request %+v", req)
+			}
+			io.WriteString(w, `{"retCode":0,"retMsg":"OK","result":{"orderId":"o-1","orderLinkId":"cid"}}`)
+		case "/v5/order/realtime":
+			if !strings.Contains(payload, "category=linear") || !strings.Contains(payload, "symbol=BTCUSDT") {
+				t.Fatalf("unexpected query %s", payload)
+			}
+			io.WriteString(w, `{"retCode":0,"retMsg":"OK","result":{"list":[{"orderLinkId":"cid","symbol":"BTCUSDT","side":"Buy","price":"100","qty":"1","cumExecQty":"0","orderStatus":"New"}],"nextPageCursor":""}}`)
+		default:
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	cli := &BybitRESTClient{BaseURL: ts.URL, APIKey: "key", Secret: "secret", HTTPClient: ts.Client(), Limiter: &mockLimiter{}}
+	id, err := cli.CreateOrder(BybitOrderRequest{Symbol: "BTCUSDT", Side: "Buy", OrderType: "Limit", Qty: "1", Price: "100", TimeInForce: "PostOnly", OrderLinkID: "cid"})
+	if err != nil || id != "o-1" {
+		t.Fatalf("create: id=%s err=%v", id, err)
+	}
+	orders, err := cli.OpenOrders("BTCUSDT")
+	if err != nil || len(orders) != 1 || orders[0].OrderLinkID != "cid" {
+		t.Fatalf("open orders: %+v err=%v", orders, err)
+	}
+}
+
+func TestBybitRESTClientErrors(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v5/order/create-batch":
+			io.WriteString(w, `{"retCode":0,"retMsg":"OK","result":{"list":[{"orderId":"o-1"},{"orderId":""}]},
+				"retExtInfo":{"list":[{"code":0,"msg":"OK"},{"code":110072,"msg":"OrderLinkedID is duplicate"}]}}`)
+		case "/v5/order/cancel":
+			io.WriteString(w, `{"retCode":110001,"retMsg":"order not exists or too late to cancel","result":{}}`)
+		case "/v5/order/amend":
+			io.WriteString(w, `{"retCode":34040,"retMsg":"not modified","result":{}}`)
+		default:
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	cli := &BybitRESTClient{BaseURL: ts.URL, APIKey: "key", Secret: "secret", HTTPClient: ts.Client()}
+	items, err := cli.CreateBatch([]BybitOrderRequest{{Symbol: "BTCUSDT", OrderLinkID: "a"}, {Symbol: "BTCUSDT", OrderLinkID: "b"}})
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if items[0].Err != nil || items[0].OrderID != "o-1" {
+		t.Fatalf("item 0: %+v", items[0])
+	}
+	if !isDuplicateClientOrderID(items[1].Err) {
+		t.Fatalf("item 1 should map to -4116, got %v", items[1].Err)
+	}
+
+	err = cli.CancelOrder("BTCUSDT", "a")
+	var exErr *ExchangeError
+	if !errors.As(err, &exErr) || exErr.Code != -2011 || !isUnknownOrder(err) {
+		t.Fatalf("cancel should map to -2011, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "110001") {
+		t.Fatalf("original code missing: %v", err)
+	}
+
+	_, err = cli.AmendOrder(BybitOrderRequest{Symbol: "BTCUSDT", OrderLinkID: "a", Price: "100"})
+	if !isNoNeedToModify(err) {
+		t.Fatalf("amend should map to -5027, got %v", err)
+	}
+
+	if _, err := cli.CreateBatch(make([]BybitOrderRequest, MaxBybitBatchOrders+1)); err == nil {
+		t.Fatalf("oversized batch should fail")
+	}
+}
+
+func TestBybitRESTClientQueriesEverySettleCoin(t *testing.T) {
+	var queried []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		coin := r.URL.Query().Get("settleCoin")
+		queried = append(queried, r.URL.Path+" "+coin)
+		symbol := "ETHUSDT"
+		if coin == "USDC" {
+			symbol = "ETHPERP"
+		}
+		switch r.URL.Path {
+		case "/v5/order/realtime":
+			io.WriteString(w, `{"retCode":0,"result":{"list":[{"orderLinkId":"cid-`+coin+`","symbol":"`+symbol+`","orderStatus":"New"}],"nextPageCursor":""}}`)
+		case "/v5/position/list":
+			io.WriteString(w, `{"retCode":0,"result":{"list":[{"symbol":"`+symbol+`","side":"Buy","size":"1"}]}}`)
+		default:
+			t.Fatalf("unexpected path %s", r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	cli := &BybitRESTClient{BaseURL: ts.URL, APIKey: "key", Secret: "secret", HTTPClient: ts.Client()}
+	orders, err := cli.OpenOrders("")
+	if err != nil || len(orders) != 2 || orders[1].Symbol != "ETHPERP" {
+		t.Fatalf("open orders should cover USDT and USDC contracts: %+v err=%v", orders, err)
+	}
+	positions, err := cli.Positions("")
+	if err != nil || len(positions) != 2 || positions[1].Symbol != "ETHPERP" {
+		t.Fatalf("positions should cover USDT and USDC contracts: %+v err=%v", positions, err)
+	}
+	want := []string{"/v5/order/realtime USDT", "/v5/order/realtime USDC", "/v5/position/list USDT", "/v5/position/list USDC"}
+	if strings.Join(queried, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected queries %v", queried)
+	}
+}
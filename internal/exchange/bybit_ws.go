@@ -0,0 +1,190 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultBybitPingInterval = 20 * time.Second // 官方建议每20秒发送一次ping
+	defaultBybitReadTimeout  = time.Minute
+	defaultBybitAuthExpiry   = 10 * time.Second
+	maxBybitSubscribeArgs    = 10 // 单次订阅请求的topic上限
+)
+
+// bybitWSMessage v5 WebSocket消息：op为请求应答（auth/subscribe/pong），topic为数据推送
+type bybitWSMessage struct {
+	Op      string          `json:"op"`
+	Success bool            `json:"success"`
+	RetMsg  string          `json:"ret_msg"`
+	Topic   string          `json:"topic"`
+	Type    string          `json:"type"` // snapshot | delta
+	Ts      int64           `json:"ts"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// BybitStream Bybit v5 WebSocket连接：连接后调用OnOpen完成鉴权与订阅，
+// 每20秒发送 {"op":"ping"}，断线后指数退避重连。重连成功后调用OnReconnect供上层对账；
+// OnMessage返回错误（如订单簿断档）时主动断开重连，重新订阅后交易所会推送新快照
+type BybitStream struct {
+	URL          string
+	Dialer       *websocket.Dialer
+	PingInterval time.Duration // 心跳间隔（默认20秒）
+	ReadTimeout  time.Duration // 读超时（默认1分钟）
+	RetryBackoff time.Duration // 重连初始退避（默认1秒，指数增长至30秒）
+
+	OnOpen      func(conn *websocket.Conn) error
+	OnMessage   func([]byte) error
+	OnReconnect func()
+}
+
+// Run 维持连接直到ctx取消
+func (s *BybitStream) Run(ctx context.Context) {
+	delay := s.backoff()
+	reconnect := false
+	for {
+		connected, err := s.session(ctx, reconnect)
+		if ctx.Err() != nil {
+			return
+		}
+		reconnect = true
+		if connected {
+			delay = s.backoff()
+		}
+		log.Warn().Err(err).Str("url", s.URL).Dur("retry_in", delay).Msg("Bybit WS中断，准备重连")
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > maxUserStreamBackoff {
+			delay = maxUserStreamBackoff
+		}
+	}
+}
+
+// session 建立一次连接并读取直到出错，返回是否曾连接成功
+func (s *BybitStream) session(ctx context.Context, reconnect bool) (bool, error) {
+	dialer := s.Dialer
+	if dialer == nil {
+		dialer = websocket.DefaultDialer
+	}
+	conn, _, err := dialer.DialContext(ctx, s.URL, nil)
+	if err != nil {
+		return false, fmt.Errorf("连接Bybit WS失败: %w", err)
+	}
+	defer conn.Close()
+
+	timeout := s.ReadTimeout
+	if timeout <= 0 {
+		timeout = defaultBybitReadTimeout
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	if s.OnOpen != nil {
+		if err := s.OnOpen(conn); err != nil {
+			return true, err
+		}
+	}
+	log.Info().Str("url", s.URL).Bool("reconnect", reconnect).Msg("Bybit WS已连接")
+
+	// 断线期间的推送已丢失：在读取新消息前完成对账，避免与推送并发
+	if reconnect && s.OnReconnect != nil {
+		s.OnReconnect()
+	}
+
+	// 心跳：OnOpen之后只有该协程写连接；ctx取消时关闭连接使读循环退出
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		every := s.PingInterval
+		if every <= 0 {
+			every = defaultBybitPingInterval
+		}
+		ticker := time.NewTicker(every)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				conn.Close()
+				return
+			case <-ticker.C:
+				_ = conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+				if err := conn.WriteJSON(map[string]string{"op": "ping"}); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return true, err
+		}
+		if s.OnMessage != nil {
+			if err := s.OnMessage(msg); err != nil {
+				return true, err
+			}
+		}
+	}
+}
+
+func (s *BybitStream) backoff() time.Duration {
+	if s.RetryBackoff > 0 {
+		return s.RetryBackoff
+	}
+	return defaultUserStreamBackoff
+}
+
+// bybitSubscribe 订阅topic，按单次上限分批发送
+func bybitSubscribe(conn *websocket.Conn, topics []string) error {
+	for start := 0; start < len(topics); start += maxBybitSubscribeArgs {
+		end := start + maxBybitSubscribeArgs
+		if end > len(topics) {
+			end = len(topics)
+		}
+		req := map[string]interface{}{"op": "subscribe", "args": topics[start:end]}
+		if err := conn.WriteJSON(req); err != nil {
+			return fmt.Errorf("订阅失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// bybitAuth 私有连接鉴权，同步等待auth应答
+func bybitAuth(conn *websocket.Conn, apiKey, secret string) error {
+	expires := strconv.FormatInt(timeNowMillis()+defaultBybitAuthExpiry.Milliseconds(), 10)
+	req := map[string]interface{}{
+		"op":   "auth",
+		"args": []string{apiKey, expires, BybitSign(secret, "GET/realtime"+expires)},
+	}
+	if err := conn.WriteJSON(req); err != nil {
+		return fmt.Errorf("发送鉴权请求失败: %w", err)
+	}
+	for {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("等待鉴权应答失败: %w", err)
+		}
+		var msg bybitWSMessage
+		if err := json.Unmarshal(raw, &msg); err != nil || msg.Op != "auth" {
+			continue
+		}
+		if !msg.Success {
+			return fmt.Errorf("bybit ws auth failed: %s", msg.RetMsg)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,144 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// ErrorCategory 交易所错误分类，同时用作指标标签
+type ErrorCategory string
+
+const (
+	CategoryNone               ErrorCategory = ""
+	CategoryPostOnlyReject     ErrorCategory = "post_only_reject"    // Post Only 会立即成交被拒
+	CategoryUnknownOrder       ErrorCategory = "unknown_order"       // 订单不存在或已完成
+	CategoryInsufficientMargin ErrorCategory = "insufficient_margin" // 余额/保证金不足
+	CategoryRateLimited        ErrorCategory = "rate_limited"        // 请求权重或下单频率超限
+	CategoryTimestamp          ErrorCategory = "timestamp"           // 时间戳超出 recvWindow
+	CategoryFilter             ErrorCategory = "filter_violation"    // 违反价格/数量/名义价值规则
+)
+
+// 分类哨兵错误：errors.Is(err, ErrUnknownOrder) 对任意包装后的 *ExchangeError 按错误码匹配
+var (
+	ErrPostOnlyReject     = errors.New("post-only order would immediately match")
+	ErrUnknownOrder       = errors.New("unknown order")
+	ErrInsufficientMargin = errors.New("insufficient margin")
+	ErrRateLimited        = errors.New("rate limited")
+	ErrTimestamp          = errors.New("timestamp outside recvWindow")
+	ErrFilterViolation    = errors.New("order violates symbol filter")
+)
+
+var categorySentinels = map[ErrorCategory]error{
+	CategoryPostOnlyReject:     ErrPostOnlyReject,
+	CategoryUnknownOrder:       ErrUnknownOrder,
+	CategoryInsufficientMargin: ErrInsufficientMargin,
+	CategoryRateLimited:        ErrRateLimited,
+	CategoryTimestamp:          ErrTimestamp,
+	CategoryFilter:             ErrFilterViolation,
+}
+
+// binanceErrorCategories Binance 错误码分类
+var binanceErrorCategories = map[int]ErrorCategory{
+	-5022: CategoryPostOnlyReject,
+	-2011: CategoryUnknownOrder, // 撤单：Unknown order sent
+	-2013: CategoryUnknownOrder, // 查询/改单：Order does not exist
+	-2018: CategoryInsufficientMargin,
+	-2019: CategoryInsufficientMargin,
+	-1003: CategoryRateLimited,
+	-1015: CategoryRateLimited,
+	429:   CategoryRateLimited, // ErrRateLimit 与 Bybit 映射沿用的 HTTP 状态码
+	-1021: CategoryTimestamp,
+	-1013: CategoryFilter,
+	-1111: CategoryFilter, // 精度超限
+	-4003: CategoryFilter,
+	-4004: CategoryFilter,
+	-4005: CategoryFilter,
+	-4013: CategoryFilter,
+	-4014: CategoryFilter, // 价格不是 tick 的整数倍
+	-4016: CategoryFilter,
+	-4023: CategoryFilter, // 数量不是 step 的整数倍
+	-4024: CategoryFilter,
+	-4164: CategoryFilter, // 名义价值低于最小值
+}
+
+// Category 按错误码（其次 HTTP 状态码）分类
+func (e *ExchangeError) Category() ErrorCategory {
+	if e == nil {
+		return CategoryNone
+	}
+	if c, ok := binanceErrorCategories[e.Code]; ok {
+		return c
+	}
+	if e.Status == http.StatusTooManyRequests || e.Status == 418 {
+		return CategoryRateLimited
+	}
+	return CategoryNone
+}
+
+// Is 支持 errors.Is 匹配分类哨兵错误
+func (e *ExchangeError) Is(target error) bool {
+	sentinel, ok := categorySentinels[e.Category()]
+	return ok && sentinel == target
+}
+
+// CategoryOf 返回错误链中交易所错误的分类；非交易所错误返回 CategoryNone
+func CategoryOf(err error) ErrorCategory {
+	var exErr *ExchangeError
+	if !errors.As(err, &exErr) {
+		return CategoryNone
+	}
+	return exErr.Category()
+}
+
+// ErrorCode 返回错误链中交易所错误的错误码
+func ErrorCode(err error) (int, bool) {
+	var exErr *ExchangeError
+	if !errors.As(err, &exErr) {
+		return 0, false
+	}
+	return exErr.Code, true
+}
+
+// HasErrorCode 错误链中是否含有指定交易所错误码
+func HasErrorCode(err error, code int) bool {
+	c, ok := ErrorCode(err)
+	return ok && c == code
+}
+
+// NewBinanceError 构造 Binance 风格的交易所错误（模拟交易所与回测复用）
+func NewBinanceError(status int, endpoint string, code int, msg string) *ExchangeError {
+	return &ExchangeError{Code: code, Message: msg, Status: status, Endpoint: endpoint}
+}
+
+// newBinanceError 解析 Binance 错误响应体 {"code":-5022,"msg":"..."}；非JSON响应保留原文
+func newBinanceError(resp *http.Response, body []byte) *ExchangeError {
+	e := &ExchangeError{Status: resp.StatusCode}
+	if resp.Request != nil && resp.Request.URL != nil {
+		e.Endpoint = resp.Request.Method + " " + resp.Request.URL.Path
+	}
+	body = bytes.TrimSpace(body)
+	var payload struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil && payload.Code != 0 {
+		e.Code = payload.Code
+		e.Message = payload.Msg
+		return e
+	}
+	e.Message = string(body)
+	return e
+}
+
+// parseErrorCode 从响应体中解析错误码
+func parseErrorCode(body string) int {
+	var payload struct {
+		Code int `json:"code"`
+	}
+	if err := json.Unmarshal([]byte(body), &payload); err != nil {
+		return 0
+	}
+	return payload.Code
+}
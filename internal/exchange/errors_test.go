@@ -0,0 +1,81 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestNewBinanceErrorParsesBody(t *testing.T) {
+	resp := &http.Response{
+		StatusCode: 400,
+		Request:    &http.Request{Method: http.MethodDelete, URL: &url.URL{Path: "/fapi/v1/order"}},
+	}
+	err := newBinanceError(resp, []byte(` {"code":-2011,"msg":"Unknown order sent."} `))
+	if err.Code != -2011 || err.Status != 400 || err.Endpoint != "DELETE /fapi/v1/order" || err.Message != "Unknown order sent." {
+		t.Fatalf("unexpected error %+v", err)
+	}
+	if got := err.Error(); got != "DELETE /fapi/v1/order status 400: code -2011: Unknown order sent." {
+		t.Fatalf("error string %q", got)
+	}
+
+	// 非JSON响应保留原文，按HTTP状态码分类
+	err = newBinanceError(&http.Response{StatusCode: 429}, []byte("<html>too many</html>"))
+	if err.Code != 0 || err.Message != "<html>too many</html>" || !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("unexpected error %+v", err)
+	}
+}
+
+func TestExchangeErrorCategories(t *testing.T) {
+	cases := []struct {
+		code     int
+		sentinel error
+		category ErrorCategory
+	}{
+		{-5022, ErrPostOnlyReject, CategoryPostOnlyReject},
+		{-2011, ErrUnknownOrder, CategoryUnknownOrder},
+		{-2013, ErrUnknownOrder, CategoryUnknownOrder},
+		{-2019, ErrInsufficientMargin, CategoryInsufficientMargin},
+		{-1015, ErrRateLimited, CategoryRateLimited},
+		{-1021, ErrTimestamp, CategoryTimestamp},
+		{-4164, ErrFilterViolation, CategoryFilter},
+	}
+	for _, tc := range cases {
+		err := fmt.Errorf("wrapped: %w", &ExchangeError{Code: tc.code, Status: 400})
+		if !errors.Is(err, tc.sentinel) || CategoryOf(err) != tc.category {
+			t.Errorf("code %d: category %q", tc.code, CategoryOf(err))
+		}
+		if errors.Is(err, ErrTimeout) {
+			t.Errorf("code %d should not match unrelated sentinel", tc.code)
+		}
+	}
+
+	if CategoryOf(&ExchangeError{Code: -1001}) != CategoryNone || CategoryOf(errors.New("-5022")) != CategoryNone {
+		t.Fatal("unclassified and untyped errors should have no category")
+	}
+	if !errors.Is(ErrRateLimit, ErrRateLimited) {
+		t.Fatal("legacy ErrRateLimit should match ErrRateLimited")
+	}
+	if !errors.Is(newBybitError(110001, "order not exists or too late to cancel"), ErrUnknownOrder) {
+		t.Fatal("bybit order-not-found should map to ErrUnknownOrder")
+	}
+	if !HasErrorCode(fmt.Errorf("x: %w", &ExchangeError{Code: -4116}), -4116) || HasErrorCode(errors.New("-4116"), -4116) {
+		t.Fatal("HasErrorCode should match typed codes only")
+	}
+}
+
+func TestClassifyErrorUsesCode(t *testing.T) {
+	cases := map[string]ErrorType{
+		`{"code":-1021,"msg":"Timestamp for this request is outside of the recvWindow."}`: ErrorTypeAuth,
+		`{"code":-5022,"msg":"Post Only order will be rejected."}`:                        ErrorTypePostOnlyReject,
+		`{"code":-2019,"msg":"Margin is insufficient."}`:                                  ErrorTypeInsufficientBalance,
+		`{"code":-1102,"msg":"price -5022 is invalid"}`:                                   ErrorTypeClient,
+	}
+	for body, want := range cases {
+		if got := ClassifyError(400, body); got != want {
+			t.Errorf("%s: got %v, want %v", body, got, want)
+		}
+	}
+}
@@ -0,0 +1,625 @@
+package fakebinance
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Level 公共订单簿档位
+type Level struct {
+	Price float64
+	Qty   float64
+}
+
+// SymbolSpec 交易规则（exchangeInfo 中的 PRICE_FILTER / LOT_SIZE / MIN_NOTIONAL）
+type SymbolSpec struct {
+	Symbol      string
+	TickSize    float64
+	StepSize    float64
+	MinQty      float64
+	MinNotional float64
+}
+
+// Order 模拟器中的订单（含已终结订单，供查询）
+type Order struct {
+	Symbol        string
+	OrderID       int64
+	ClientOrderID string
+	Side          string
+	Type          string
+	TimeInForce   string
+	Price         float64
+	OrigQty       float64
+	ExecutedQty   float64
+	CumQuote      float64
+	Status        string
+	ReduceOnly    bool
+	UpdateTime    int64
+
+	seq int64 // 时间优先序号，改价后重新排队
+}
+
+// AvgPrice 成交均价
+func (o *Order) AvgPrice() float64 {
+	if o.ExecutedQty == 0 {
+		return 0
+	}
+	return o.CumQuote / o.ExecutedQty
+}
+
+func (o *Order) open() bool {
+	return o.Status == "NEW" || o.Status == "PARTIALLY_FILLED"
+}
+
+// Position 单向持仓
+type Position struct {
+	Symbol      string
+	Amount      float64 // 正为多、负为空
+	EntryPrice  float64
+	RealizedPnL float64 // 累计已实现盈亏
+}
+
+// market 单个交易对的公共行情与账户挂单
+type market struct {
+	spec         SymbolSpec
+	bids         map[float64]float64
+	asks         map[float64]float64
+	lastUpdateID int64
+	markPrice    float64
+	fundingRate  float64
+	aggTradeID   int64
+}
+
+func newMarket(spec SymbolSpec) *market {
+	return &market{
+		spec:         spec,
+		bids:         make(map[float64]float64),
+		asks:         make(map[float64]float64),
+		lastUpdateID: 1,
+		fundingRate:  0.0001,
+	}
+}
+
+func (m *market) bestBid() float64 {
+	best := 0.0
+	for p := range m.bids {
+		if p > best {
+			best = p
+		}
+	}
+	return best
+}
+
+func (m *market) bestAsk() float64 {
+	best := 0.0
+	for p := range m.asks {
+		if best == 0 || p < best {
+			best = p
+		}
+	}
+	return best
+}
+
+// mark 标记价格：未设置时取中间价
+func (m *market) mark() float64 {
+	if m.markPrice > 0 {
+		return m.markPrice
+	}
+	bid, ask := m.bestBid(), m.bestAsk()
+	if bid > 0 && ask > 0 {
+		return (bid + ask) / 2
+	}
+	return bid + ask
+}
+
+func sortedLevels(side map[float64]float64, desc bool, limit int) []Level {
+	out := make([]Level, 0, len(side))
+	for p, q := range side {
+		out = append(out, Level{Price: p, Qty: q})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if desc {
+			return out[i].Price > out[j].Price
+		}
+		return out[i].Price < out[j].Price
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+// apiError 交易所错误（HTTP 400 + {code,msg}）
+type apiError struct {
+	Status int
+	Code   int
+	Msg    string
+}
+
+func (e *apiError) Error() string { return strconv.Itoa(e.Code) + ": " + e.Msg }
+
+var (
+	errUnknownOrder     = &apiError{Status: 400, Code: -2011, Msg: "Unknown order sent."}
+	errOrderNotExist    = &apiError{Status: 400, Code: -2013, Msg: "Order does not exist."}
+	errDuplicateClient  = &apiError{Status: 400, Code: -4116, Msg: "ClientOrderId is duplicated."}
+	errPostOnlyReject   = &apiError{Status: 400, Code: -5022, Msg: "Due to the order could not be executed as maker, the Post Only order will be rejected."}
+	errNoNeedToModify   = &apiError{Status: 400, Code: -5027, Msg: "No need to modify the order."}
+	errInvalidSymbol    = &apiError{Status: 400, Code: -1121, Msg: "Invalid symbol."}
+	errTickSize         = &apiError{Status: 400, Code: -4014, Msg: "Price not increased by tick size."}
+	errLotSize          = &apiError{Status: 400, Code: -1013, Msg: "Filter failure: LOT_SIZE"}
+	errMinNotional      = &apiError{Status: 400, Code: -4164, Msg: "Order's notional must be no smaller than minimum notional (unless you choose reduce only)."}
+	errReduceOnlyReject = &apiError{Status: 400, Code: -2022, Msg: "ReduceOnly Order is rejected."}
+)
+
+// orderRequest 下单参数（REST与WS API共用）
+type orderRequest struct {
+	Symbol        string
+	Side          string
+	Type          string
+	TimeInForce   string
+	Price         float64
+	Quantity      float64
+	ClientOrderID string
+	ReduceOnly    bool
+}
+
+// nowMillis 服务器时间（含时钟偏移）
+func (s *Server) nowMillis() int64 {
+	return time.Now().Add(s.opts.ClockOffset).UnixMilli()
+}
+
+func (s *Server) marketLocked(symbol string) (*market, error) {
+	m, ok := s.markets[symbol]
+	if !ok {
+		return nil, errInvalidSymbol
+	}
+	return m, nil
+}
+
+func onStep(v, step float64) bool {
+	if step <= 0 {
+		return true
+	}
+	n := v / step
+	return math.Abs(n-math.Round(n)) < 1e-6
+}
+
+// placeLocked 下单：校验交易规则、Post Only 与 reduceOnly，吃单部分按公共订单簿逐档成交
+func (s *Server) placeLocked(req orderRequest) (*Order, error) {
+	m, err := s.marketLocked(req.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if req.Side != "BUY" && req.Side != "SELL" {
+		return nil, &apiError{Status: 400, Code: -1117, Msg: "Invalid side."}
+	}
+	if req.Quantity <= 0 {
+		return nil, &apiError{Status: 400, Code: -4003, Msg: "Quantity less than or equal to zero."}
+	}
+	if !onStep(req.Quantity, m.spec.StepSize) || req.Quantity < m.spec.MinQty {
+		return nil, errLotSize
+	}
+	if req.ClientOrderID != "" {
+		if prev, ok := s.byClientID[req.ClientOrderID]; ok && prev.open() {
+			return nil, errDuplicateClient
+		}
+	} else {
+		req.ClientOrderID = "fake_" + strconv.FormatInt(s.nextOrderID, 10)
+	}
+
+	switch req.Type {
+	case "LIMIT":
+		if req.Price <= 0 || !onStep(req.Price, m.spec.TickSize) {
+			return nil, errTickSize
+		}
+		if !req.ReduceOnly && req.Price*req.Quantity < m.spec.MinNotional {
+			return nil, errMinNotional
+		}
+		if req.TimeInForce == "GTX" && s.crossesLocked(m, req.Side, req.Price) {
+			return nil, errPostOnlyReject
+		}
+	case "MARKET":
+	default:
+		return nil, &apiError{Status: 400, Code: -1116, Msg: "Invalid orderType."}
+	}
+	if req.ReduceOnly {
+		pos := s.positionLocked(req.Symbol)
+		if (req.Side == "BUY" && pos.Amount >= 0) || (req.Side == "SELL" && pos.Amount <= 0) {
+			return nil, errReduceOnlyReject
+		}
+	}
+
+	o := &Order{
+		Symbol:        req.Symbol,
+		OrderID:       s.nextOrderID,
+		ClientOrderID: req.ClientOrderID,
+		Side:          req.Side,
+		Type:          req.Type,
+		TimeInForce:   req.TimeInForce,
+		Price:         req.Price,
+		OrigQty:       req.Quantity,
+		Status:        "NEW",
+		ReduceOnly:    req.ReduceOnly,
+		UpdateTime:    s.nowMillis(),
+		seq:           s.nextOrderID,
+	}
+	s.nextOrderID++
+	s.orders[o.OrderID] = o
+	s.byClientID[o.ClientOrderID] = o
+	s.emitOrderLocked(o, "NEW", 0, 0, 0, 0, false)
+
+	// 吃单：按公共订单簿逐档成交，剩余部分挂单（IOC/FOK/MARKET剩余撤销）
+	s.takeLocked(m, o)
+	if o.open() && (o.Type == "MARKET" || o.TimeInForce == "IOC" || o.TimeInForce == "FOK") {
+		o.Status = "EXPIRED"
+		o.UpdateTime = s.nowMillis()
+		s.emitOrderLocked(o, "EXPIRED", 0, 0, 0, 0, false)
+	}
+	return o, nil
+}
+
+// crossesLocked 限价是否会与公共订单簿对手价立即成交
+func (s *Server) crossesLocked(m *market, side string, price float64) bool {
+	if side == "BUY" {
+		ask := m.bestAsk()
+		return ask > 0 && price >= ask
+	}
+	bid := m.bestBid()
+	return bid > 0 && price <= bid
+}
+
+// takeLocked 主动成交：消耗公共订单簿流动性（推送对应深度变化），按taker费率计费
+func (s *Server) takeLocked(m *market, o *Order) {
+	var changes []Level
+	book := m.asks
+	if o.Side == "SELL" {
+		book = m.bids
+	}
+	for o.OrigQty-o.ExecutedQty > 1e-12 {
+		levels := sortedLevels(book, o.Side == "SELL", 1)
+		if len(levels) == 0 {
+			break
+		}
+		lv := levels[0]
+		if o.Type == "LIMIT" && ((o.Side == "BUY" && lv.Price > o.Price) || (o.Side == "SELL" && lv.Price < o.Price)) {
+			break
+		}
+		qty := math.Min(lv.Qty, o.OrigQty-o.ExecutedQty)
+		left := lv.Qty - qty
+		if left > 1e-12 {
+			book[lv.Price] = left
+		} else {
+			left = 0
+			delete(book, lv.Price)
+		}
+		changes = append(changes, Level{Price: lv.Price, Qty: left})
+		s.fillLocked(m, o, qty, lv.Price, false)
+	}
+	if o.Side == "BUY" {
+		s.emitDepthLocked(m, nil, changes)
+	} else {
+		s.emitDepthLocked(m, changes, nil)
+	}
+}
+
+// fillLocked 成交记账：订单状态、仓位、已实现盈亏、手续费与余额，并推送用户数据
+func (s *Server) fillLocked(m *market, o *Order, qty, price float64, maker bool) {
+	o.ExecutedQty += qty
+	o.CumQuote += qty * price
+	o.Status = "PARTIALLY_FILLED"
+	if o.OrigQty-o.ExecutedQty <= 1e-12 {
+		o.ExecutedQty = o.OrigQty
+		o.Status = "FILLED"
+	}
+	o.UpdateTime = s.nowMillis()
+
+	rate := s.opts.TakerFee
+	if maker {
+		rate = s.opts.MakerFee
+	}
+	fee := qty * price * rate
+
+	pos := s.positionLocked(o.Symbol)
+	signed := qty
+	if o.Side == "SELL" {
+		signed = -qty
+	}
+	realized := 0.0
+	switch {
+	case pos.Amount == 0 || (pos.Amount > 0) == (signed > 0):
+		total := math.Abs(pos.Amount) + qty
+		pos.EntryPrice = (math.Abs(pos.Amount)*pos.EntryPrice + qty*price) / total
+		pos.Amount += signed
+	default:
+		closing := math.Min(qty, math.Abs(pos.Amount))
+		dir := 1.0
+		if pos.Amount < 0 {
+			dir = -1
+		}
+		realized = closing * (price - pos.EntryPrice) * dir
+		pos.Amount += signed
+		if math.Abs(pos.Amount) < 1e-12 {
+			pos.Amount = 0
+			pos.EntryPrice = 0
+		} else if (pos.Amount > 0) != (dir > 0) {
+			pos.EntryPrice = price // 反手
+		}
+	}
+	pos.RealizedPnL += realized
+	s.wallet += realized - fee
+
+	s.emitOrderLocked(o, "TRADE", qty, price, realized, fee, maker)
+	s.emitAccountLocked("ORDER", o.Symbol, 0)
+}
+
+// restingLocked 某交易对的活跃挂单，按价格优先、时间优先排序（买单价高在前）
+func (s *Server) restingLocked(symbol, side string) []*Order {
+	var out []*Order
+	for _, o := range s.orders {
+		if o.Symbol == symbol && o.Side == side && o.open() {
+			out = append(out, o)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Price != out[j].Price {
+			if side == "BUY" {
+				return out[i].Price > out[j].Price
+			}
+			return out[i].Price < out[j].Price
+		}
+		return out[i].seq < out[j].seq
+	})
+	return out
+}
+
+// crossRestingLocked 公共订单簿穿越挂单价时，挂单按自身价格全部成交（maker）
+func (s *Server) crossRestingLocked(m *market) {
+	bid, ask := m.bestBid(), m.bestAsk()
+	for _, o := range s.restingLocked(m.spec.Symbol, "BUY") {
+		if ask > 0 && ask <= o.Price {
+			s.fillLocked(m, o, o.OrigQty-o.ExecutedQty, o.Price, true)
+		}
+	}
+	for _, o := range s.restingLocked(m.spec.Symbol, "SELL") {
+		if bid > 0 && bid >= o.Price {
+			s.fillLocked(m, o, o.OrigQty-o.ExecutedQty, o.Price, true)
+		}
+	}
+}
+
+// cancelLocked 撤销活跃挂单
+func (s *Server) cancelLocked(o *Order) (*Order, error) {
+	if o == nil || !o.open() {
+		return nil, errUnknownOrder
+	}
+	o.Status = "CANCELED"
+	o.UpdateTime = s.nowMillis()
+	s.emitOrderLocked(o, "CANCELED", 0, 0, 0, 0, false)
+	return o, nil
+}
+
+// amendLocked 改单：价格变化时重新排队；Post Only 改价后会吃单则拒绝
+func (s *Server) amendLocked(o *Order, price, qty float64) (*Order, error) {
+	if o == nil || !o.open() {
+		return nil, errOrderNotExist
+	}
+	m, err := s.marketLocked(o.Symbol)
+	if err != nil {
+		return nil, err
+	}
+	if price == o.Price && qty == o.OrigQty {
+		return nil, errNoNeedToModify
+	}
+	if price <= 0 || !onStep(price, m.spec.TickSize) {
+		return nil, errTickSize
+	}
+	if !onStep(qty, m.spec.StepSize) || qty < m.spec.MinQty || qty <= o.ExecutedQty {
+		return nil, errLotSize
+	}
+	if o.TimeInForce == "GTX" && s.crossesLocked(m, o.Side, price) {
+		return nil, errPostOnlyReject
+	}
+	if price != o.Price {
+		o.seq = s.nextOrderID
+		s.nextOrderID++
+	}
+	o.Price = price
+	o.OrigQty = qty
+	o.UpdateTime = s.nowMillis()
+	s.emitOrderLocked(o, "AMENDMENT", 0, 0, 0, 0, false)
+	return o, nil
+}
+
+// findLocked 按clientOrderId或orderId查找订单
+func (s *Server) findLocked(symbol, clientID string, orderID int64) *Order {
+	var o *Order
+	if clientID != "" {
+		o = s.byClientID[clientID]
+	} else if orderID != 0 {
+		o = s.orders[orderID]
+	}
+	if o == nil || (symbol != "" && o.Symbol != symbol) {
+		return nil
+	}
+	return o
+}
+
+func (s *Server) positionLocked(symbol string) *Position {
+	pos, ok := s.positions[symbol]
+	if !ok {
+		pos = &Position{Symbol: symbol}
+		s.positions[symbol] = pos
+	}
+	return pos
+}
+
+func (s *Server) unrealizedLocked(symbol string) float64 {
+	pos := s.positionLocked(symbol)
+	m := s.markets[symbol]
+	if pos.Amount == 0 || m == nil {
+		return 0
+	}
+	return pos.Amount * (m.mark() - pos.EntryPrice)
+}
+
+func (s *Server) totalUnrealizedLocked() float64 {
+	total := 0.0
+	for symbol := range s.markets {
+		total += s.unrealizedLocked(symbol)
+	}
+	return total
+}
+
+// SetBook 替换公共订单簿并推送增量深度；盘口穿越挂单价时挂单成交
+func (s *Server) SetBook(symbol string, bids, asks []Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.marketLocked(symbol)
+	if err != nil {
+		return
+	}
+	diff := func(old map[float64]float64, levels []Level) (map[float64]float64, []Level) {
+		next := make(map[float64]float64, len(levels))
+		var changes []Level
+		for _, lv := range levels {
+			if lv.Qty <= 0 {
+				continue
+			}
+			next[lv.Price] = lv.Qty
+			if old[lv.Price] != lv.Qty {
+				changes = append(changes, lv)
+			}
+		}
+		for p := range old {
+			if _, ok := next[p]; !ok {
+				changes = append(changes, Level{Price: p})
+			}
+		}
+		return next, changes
+	}
+	var bidChanges, askChanges []Level
+	m.bids, bidChanges = diff(m.bids, bids)
+	m.asks, askChanges = diff(m.asks, asks)
+	s.emitDepthLocked(m, bidChanges, askChanges)
+	s.crossRestingLocked(m)
+}
+
+// Trade 模拟一笔外部主动成交：推送aggTrade，并按价格/时间优先成交价格更优的挂单（maker，按挂单价）
+func (s *Server) Trade(symbol, takerSide string, price, qty float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.marketLocked(symbol)
+	if err != nil {
+		return
+	}
+	m.aggTradeID++
+	s.emitAggTradeLocked(m, takerSide, price, qty)
+
+	restingSide := "BUY"
+	if takerSide == "BUY" {
+		restingSide = "SELL"
+	}
+	left := qty
+	for _, o := range s.restingLocked(symbol, restingSide) {
+		if left <= 1e-12 {
+			break
+		}
+		if (restingSide == "BUY" && o.Price < price) || (restingSide == "SELL" && o.Price > price) {
+			break
+		}
+		fill := math.Min(left, o.OrigQty-o.ExecutedQty)
+		s.fillLocked(m, o, fill, o.Price, true)
+		left -= fill
+	}
+}
+
+// SetMarkPrice 设置标记价格（为0时使用中间价）
+func (s *Server) SetMarkPrice(symbol string, price float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, err := s.marketLocked(symbol); err == nil {
+		m.markPrice = price
+	}
+}
+
+// SetFundingRate 设置当前资金费率
+func (s *Server) SetFundingRate(symbol string, rate float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, err := s.marketLocked(symbol); err == nil {
+		m.fundingRate = rate
+	}
+}
+
+// SettleFunding 按当前资金费率与标记价格结算资金费，推送 ACCOUNT_UPDATE(FUNDING_FEE)；返回结算金额（正为收入）
+func (s *Server) SettleFunding(symbol string) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.marketLocked(symbol)
+	if err != nil {
+		return 0
+	}
+	pos := s.positionLocked(symbol)
+	if pos.Amount == 0 {
+		return 0
+	}
+	payment := -pos.Amount * m.mark() * m.fundingRate
+	s.wallet += payment
+	s.emitAccountLocked("FUNDING_FEE", symbol, payment)
+	return payment
+}
+
+// Orders 返回交易对的全部订单（含已终结）
+func (s *Server) Orders(symbol string) []Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Order
+	for _, o := range s.sortedOrdersLocked() {
+		if symbol == "" || o.Symbol == symbol {
+			out = append(out, *o)
+		}
+	}
+	return out
+}
+
+func sortOrders(orders []*Order) {
+	sort.Slice(orders, func(i, j int) bool { return orders[i].OrderID < orders[j].OrderID })
+}
+
+// OpenOrders 返回交易对的活跃挂单
+func (s *Server) OpenOrders(symbol string) []Order {
+	var out []Order
+	for _, o := range s.Orders(symbol) {
+		if o.open() {
+			out = append(out, o)
+		}
+	}
+	return out
+}
+
+// OrderByClientID 按clientOrderId查询订单
+func (s *Server) OrderByClientID(clientID string) (Order, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, ok := s.byClientID[clientID]
+	if !ok {
+		return Order{}, false
+	}
+	return *o, true
+}
+
+// Position 返回交易对仓位
+func (s *Server) Position(symbol string) Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *s.positionLocked(symbol)
+}
+
+// Wallet 返回USDT钱包余额
+func (s *Server) Wallet() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.wallet
+}
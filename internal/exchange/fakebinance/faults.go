@@ -0,0 +1,101 @@
+package fakebinance
+
+import (
+	"net/http"
+	"time"
+)
+
+var timeAfter = time.After
+
+// Fault 可注入故障。Op 为 REST 端点（如 "POST /fapi/v1/order"）或 ws-fapi 方法（如 "order.place"），空为匹配全部。
+// 先等待 Delay；Drop 为 true 时不返回响应（REST 直接断开连接）；Code/Status 非零时返回该错误，否则照常处理。
+type Fault struct {
+	Op     string
+	Status int // HTTP 状态码（默认400）
+	Code   int // Binance 错误码，如 -1001、-1003
+	Msg    string
+	Delay  time.Duration
+	Drop   bool
+	Times  int // 生效次数，0为持续生效直到 ClearFaults
+}
+
+func (f *Fault) apiError() *apiError {
+	status := f.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	msg := f.Msg
+	if msg == "" {
+		msg = "Injected fault."
+	}
+	return &apiError{Status: status, Code: f.Code, Msg: msg}
+}
+
+// InjectFault 注册故障，按注册顺序匹配
+func (s *Server) InjectFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults 清除全部故障
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// takeFault 取出匹配 op 的第一个故障并扣减次数
+func (s *Server) takeFault(op string) *Fault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.faults {
+		if f.Op != "" && f.Op != op {
+			continue
+		}
+		hit := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+		}
+		return &hit
+	}
+	return nil
+}
+
+// DisconnectStreams 断开全部 WebSocket 连接（行情、用户数据、交易API），模拟网络闪断
+func (s *Server) DisconnectStreams() {
+	s.connMu.Lock()
+	conns := make([]*wsConn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	s.connMu.Unlock()
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+// ExpireListenKey 推送 listenKeyExpired 并使当前 listenKey 失效，之后的用户事件不再推送
+func (s *Server) ExpireListenKey() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listenKey == "" {
+		return
+	}
+	s.broadcast(s.listenKey, map[string]interface{}{
+		"e": "listenKeyExpired", "E": s.nowMillis(), "listenKey": s.listenKey,
+	}, true)
+	s.listenKey = ""
+}
+
+// SkipDepthUpdates 丢弃交易对接下来 n 个深度增量序号，下一条推送的 pu 将与客户端本地序号断档
+func (s *Server) SkipDepthUpdates(symbol string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m, ok := s.markets[symbol]; ok {
+		m.lastUpdateID += int64(n)
+	}
+}
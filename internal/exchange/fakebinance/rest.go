@@ -0,0 +1,517 @@
+package fakebinance
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// restRoute 端点定义：鉴权方式、请求权重与处理函数
+type restRoute struct {
+	signed   bool // 需要 HMAC 签名
+	apiKey   bool // 仅需 API key（listenKey）
+	weight   int
+	weightFn func(r *http.Request) int
+	order    bool                      // 计入下单频率
+	countFn  func(r *http.Request) int // 批量下单按条计数
+	handle   func(s *Server, r *http.Request) (interface{}, error)
+}
+
+func (rt restRoute) orderCount(r *http.Request) int {
+	if rt.countFn != nil {
+		return rt.countFn(r)
+	}
+	return 1
+}
+
+var restRoutes = map[string]restRoute{
+	"GET /fapi/v1/time":               {weight: 1, handle: (*Server).handleTime},
+	"GET /fapi/v1/exchangeInfo":       {weight: 1, handle: (*Server).handleExchangeInfo},
+	"GET /fapi/v1/depth":              {weightFn: depthWeight, handle: (*Server).handleDepth},
+	"GET /fapi/v1/premiumIndex":       {weight: 1, handle: (*Server).handlePremiumIndex},
+	"POST /fapi/v1/order":             {signed: true, weight: 1, order: true, handle: (*Server).handlePlace},
+	"GET /fapi/v1/order":              {signed: true, weight: 1, handle: (*Server).handleQuery},
+	"DELETE /fapi/v1/order":           {signed: true, weight: 1, handle: (*Server).handleCancel},
+	"PUT /fapi/v1/order":              {signed: true, weight: 1, order: true, handle: (*Server).handleAmend},
+	"POST /fapi/v1/batchOrders":       {signed: true, weight: 5, order: true, countFn: batchCount, handle: (*Server).handleBatchPlace},
+	"DELETE /fapi/v1/batchOrders":     {signed: true, weight: 1, handle: (*Server).handleBatchCancel},
+	"PUT /fapi/v1/batchOrders":        {signed: true, weight: 5, order: true, countFn: batchCount, handle: (*Server).handleBatchAmend},
+	"GET /fapi/v1/openOrders":         {signed: true, weightFn: openOrdersWeight, handle: (*Server).handleOpenOrders},
+	"DELETE /fapi/v1/allOpenOrders":   {signed: true, weight: 1, handle: (*Server).handleCancelAll},
+	"GET /fapi/v2/positionRisk":       {signed: true, weight: 5, handle: (*Server).handlePositionRisk},
+	"GET /fapi/v2/account":            {signed: true, weight: 5, handle: (*Server).handleAccount},
+	"GET /fapi/v2/balance":            {signed: true, weight: 5, handle: (*Server).handleBalance},
+	"GET /fapi/v1/leverageBracket":    {signed: true, weight: 1, handle: (*Server).handleLeverageBracket},
+	"POST /fapi/v1/leverage":          {signed: true, weight: 1, handle: (*Server).handleLeverage},
+	"POST /fapi/v1/marginType":        {signed: true, weight: 1, handle: (*Server).handleMarginType},
+	"GET /fapi/v1/positionSide/dual":  {signed: true, weight: 30, handle: (*Server).handleGetDual},
+	"POST /fapi/v1/positionSide/dual": {signed: true, weight: 1, handle: (*Server).handleSetDual},
+	"POST /fapi/v1/listenKey":         {apiKey: true, weight: 1, handle: (*Server).handleNewListenKey},
+	"PUT /fapi/v1/listenKey":          {apiKey: true, weight: 1, handle: (*Server).handleKeepAliveListenKey},
+	"DELETE /fapi/v1/listenKey":       {apiKey: true, weight: 1, handle: (*Server).handleCloseListenKey},
+}
+
+func depthWeight(r *http.Request) int {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	switch {
+	case limit <= 0:
+		return 10
+	case limit <= 50:
+		return 2
+	case limit <= 100:
+		return 5
+	case limit <= 500:
+		return 10
+	default:
+		return 20
+	}
+}
+
+func openOrdersWeight(r *http.Request) int {
+	if r.URL.Query().Get("symbol") == "" {
+		return 40
+	}
+	return 1
+}
+
+func batchCount(r *http.Request) int {
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(r.URL.Query().Get("batchOrders")), &items); err != nil || len(items) == 0 {
+		return 1
+	}
+	return len(items)
+}
+
+func parseFloatParam(v string) float64 {
+	f, _ := strconv.ParseFloat(v, 64)
+	return f
+}
+
+func (s *Server) handleTime(*http.Request) (interface{}, error) {
+	return map[string]int64{"serverTime": s.nowMillis()}, nil
+}
+
+func (s *Server) handleExchangeInfo(r *http.Request) (interface{}, error) {
+	only := r.URL.Query().Get("symbol")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	symbols := make([]map[string]interface{}, 0, len(s.markets))
+	for _, spec := range s.opts.Symbols {
+		if only != "" && spec.Symbol != only {
+			continue
+		}
+		symbols = append(symbols, map[string]interface{}{
+			"symbol":            spec.Symbol,
+			"status":            "TRADING",
+			"baseAsset":         strings.TrimSuffix(spec.Symbol, defaultAsset),
+			"quoteAsset":        defaultAsset,
+			"pricePrecision":    decimals(spec.TickSize),
+			"quantityPrecision": decimals(spec.StepSize),
+			"filters": []map[string]string{
+				{"filterType": "PRICE_FILTER", "minPrice": fmtFloat(spec.TickSize), "maxPrice": "1000000", "tickSize": fmtFloat(spec.TickSize)},
+				{"filterType": "LOT_SIZE", "minQty": fmtFloat(spec.MinQty), "maxQty": "10000", "stepSize": fmtFloat(spec.StepSize)},
+				{"filterType": "MIN_NOTIONAL", "notional": fmtFloat(spec.MinNotional)},
+			},
+		})
+	}
+	return map[string]interface{}{"serverTime": s.nowMillis(), "symbols": symbols}, nil
+}
+
+// decimals 步长对应的小数位数
+func decimals(step float64) int {
+	str := fmtFloat(step)
+	if i := strings.IndexByte(str, '.'); i >= 0 {
+		return len(str) - i - 1
+	}
+	return 0
+}
+
+func (s *Server) handleDepth(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.marketLocked(q.Get("symbol"))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"lastUpdateId": m.lastUpdateID,
+		"E":            s.nowMillis(),
+		"T":            s.nowMillis(),
+		"bids":         levelStrings(sortedLevels(m.bids, true, limit)),
+		"asks":         levelStrings(sortedLevels(m.asks, false, limit)),
+	}, nil
+}
+
+func levelStrings(levels []Level) [][]string {
+	out := make([][]string, 0, len(levels))
+	for _, lv := range levels {
+		out = append(out, []string{fmtFloat(lv.Price), fmtFloat(lv.Qty)})
+	}
+	return out
+}
+
+func (s *Server) handlePremiumIndex(r *http.Request) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, err := s.marketLocked(r.URL.Query().Get("symbol"))
+	if err != nil {
+		return nil, err
+	}
+	next := (s.nowMillis()/(8*3600*1000) + 1) * 8 * 3600 * 1000
+	return map[string]interface{}{
+		"symbol":          m.spec.Symbol,
+		"markPrice":       fmtFloat(m.mark()),
+		"indexPrice":      fmtFloat(m.mark()),
+		"lastFundingRate": fmtFloat(m.fundingRate),
+		"nextFundingTime": next,
+		"time":            s.nowMillis(),
+	}, nil
+}
+
+// orderRequestFrom 从 REST 参数（或批量条目）构造下单请求
+func orderRequestFrom(get func(string) string) orderRequest {
+	return orderRequest{
+		Symbol:        get("symbol"),
+		Side:          get("side"),
+		Type:          get("type"),
+		TimeInForce:   get("timeInForce"),
+		Price:         parseFloatParam(get("price")),
+		Quantity:      parseFloatParam(get("quantity")),
+		ClientOrderID: get("newClientOrderId"),
+		ReduceOnly:    get("reduceOnly") == "true",
+	}
+}
+
+func (s *Server) handlePlace(r *http.Request) (interface{}, error) {
+	req := orderRequestFrom(r.URL.Query().Get)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, err := s.placeLocked(req)
+	if err != nil {
+		return nil, err
+	}
+	return orderJSON(o), nil
+}
+
+func (s *Server) lookupLocked(get func(string) string) *Order {
+	id, _ := strconv.ParseInt(get("orderId"), 10, 64)
+	return s.findLocked(get("symbol"), get("origClientOrderId"), id)
+}
+
+func (s *Server) handleQuery(r *http.Request) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.lookupLocked(r.URL.Query().Get)
+	if o == nil {
+		return nil, errOrderNotExist
+	}
+	return orderJSON(o), nil
+}
+
+func (s *Server) handleCancel(r *http.Request) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, err := s.cancelLocked(s.lookupLocked(r.URL.Query().Get))
+	if err != nil {
+		return nil, err
+	}
+	return orderJSON(o), nil
+}
+
+func (s *Server) handleAmend(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o, err := s.amendLocked(s.lookupLocked(q.Get), parseFloatParam(q.Get("price")), parseFloatParam(q.Get("quantity")))
+	if err != nil {
+		return nil, err
+	}
+	return orderJSON(o), nil
+}
+
+// batchItems 解析 batchOrders 参数，最多5条
+func batchItems(r *http.Request) ([]map[string]string, error) {
+	var items []map[string]string
+	if err := json.Unmarshal([]byte(r.URL.Query().Get("batchOrders")), &items); err != nil || len(items) == 0 {
+		return nil, &apiError{Status: http.StatusBadRequest, Code: -1130, Msg: "Data sent for parameter 'batchOrders' is not valid."}
+	}
+	if len(items) > 5 {
+		return nil, &apiError{Status: http.StatusBadRequest, Code: -4034, Msg: "Exceeded the maximum allowable batch orders size (5)."}
+	}
+	return items, nil
+}
+
+// batchResult 批量接口逐条结果：成功为订单对象，失败为 {code,msg}
+func batchResult(o *Order, err error) interface{} {
+	if err != nil {
+		ae, ok := err.(*apiError)
+		if !ok {
+			ae = &apiError{Code: -1000, Msg: err.Error()}
+		}
+		return map[string]interface{}{"code": ae.Code, "msg": ae.Msg}
+	}
+	return orderJSON(o)
+}
+
+func mapGetter(m map[string]string) func(string) string {
+	return func(k string) string { return m[k] }
+}
+
+func (s *Server) handleBatchPlace(r *http.Request) (interface{}, error) {
+	items, err := batchItems(r)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		out = append(out, batchResult(s.placeLocked(orderRequestFrom(mapGetter(item)))))
+	}
+	return out, nil
+}
+
+func (s *Server) handleBatchAmend(r *http.Request) (interface{}, error) {
+	items, err := batchItems(r)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]interface{}, 0, len(items))
+	for _, item := range items {
+		get := mapGetter(item)
+		out = append(out, batchResult(s.amendLocked(s.lookupLocked(get), parseFloatParam(get("price")), parseFloatParam(get("quantity")))))
+	}
+	return out, nil
+}
+
+func (s *Server) handleBatchCancel(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	symbol := q.Get("symbol")
+	var clientIDs []string
+	var orderIDs []int64
+	if raw := q.Get("origClientOrderIdList"); raw != "" {
+		_ = json.Unmarshal([]byte(raw), &clientIDs)
+	}
+	if raw := q.Get("orderIdList"); raw != "" {
+		_ = json.Unmarshal([]byte(raw), &orderIDs)
+	}
+	if n := len(clientIDs) + len(orderIDs); n == 0 || n > 10 {
+		return nil, &apiError{Status: http.StatusBadRequest, Code: -1130, Msg: "Data sent for parameter 'origClientOrderIdList' is not valid."}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]interface{}, 0, len(clientIDs)+len(orderIDs))
+	for _, id := range clientIDs {
+		out = append(out, batchResult(s.cancelLocked(s.findLocked(symbol, id, 0))))
+	}
+	for _, id := range orderIDs {
+		out = append(out, batchResult(s.cancelLocked(s.findLocked(symbol, "", id))))
+	}
+	return out, nil
+}
+
+func (s *Server) handleOpenOrders(r *http.Request) (interface{}, error) {
+	symbol := r.URL.Query().Get("symbol")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if symbol != "" {
+		if _, err := s.marketLocked(symbol); err != nil {
+			return nil, err
+		}
+	}
+	out := make([]interface{}, 0)
+	for _, o := range s.sortedOrdersLocked() {
+		if o.open() && (symbol == "" || o.Symbol == symbol) {
+			out = append(out, orderJSON(o))
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) handleCancelAll(r *http.Request) (interface{}, error) {
+	symbol := r.URL.Query().Get("symbol")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.marketLocked(symbol); err != nil {
+		return nil, err
+	}
+	s.cancelAllLocked(symbol)
+	return map[string]interface{}{"code": 200, "msg": "The operation of cancel all open order is done."}, nil
+}
+
+func (s *Server) cancelAllLocked(symbol string) {
+	for _, o := range s.sortedOrdersLocked() {
+		if o.open() && o.Symbol == symbol {
+			_, _ = s.cancelLocked(o)
+		}
+	}
+}
+
+func (s *Server) sortedOrdersLocked() []*Order {
+	out := make([]*Order, 0, len(s.orders))
+	for _, o := range s.orders {
+		out = append(out, o)
+	}
+	sortOrders(out)
+	return out
+}
+
+func (s *Server) handlePositionRisk(r *http.Request) (interface{}, error) {
+	only := r.URL.Query().Get("symbol")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]interface{}, 0, len(s.markets))
+	for _, spec := range s.opts.Symbols {
+		if only != "" && spec.Symbol != only {
+			continue
+		}
+		pos := s.positionLocked(spec.Symbol)
+		out = append(out, map[string]interface{}{
+			"symbol":           spec.Symbol,
+			"positionAmt":      fmtFloat(pos.Amount),
+			"entryPrice":       fmtFloat(pos.EntryPrice),
+			"markPrice":        fmtFloat(s.markets[spec.Symbol].mark()),
+			"unRealizedProfit": fmtFloat(s.unrealizedLocked(spec.Symbol)),
+			"marginType":       "cross",
+			"positionSide":     "BOTH",
+			"leverage":         "20",
+		})
+	}
+	return out, nil
+}
+
+func (s *Server) handleAccount(*http.Request) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unrealized := s.totalUnrealizedLocked()
+	wallet := fmtFloat(s.wallet)
+	margin := fmtFloat(s.wallet + unrealized)
+	positions := make([]interface{}, 0, len(s.markets))
+	for _, spec := range s.opts.Symbols {
+		pos := s.positionLocked(spec.Symbol)
+		positions = append(positions, map[string]interface{}{
+			"symbol":           spec.Symbol,
+			"leverage":         "20",
+			"entryPrice":       fmtFloat(pos.EntryPrice),
+			"positionAmt":      fmtFloat(pos.Amount),
+			"unrealizedProfit": fmtFloat(s.unrealizedLocked(spec.Symbol)),
+			"positionSide":     "BOTH",
+		})
+	}
+	return map[string]interface{}{
+		"totalWalletBalance":    wallet,
+		"totalUnrealizedProfit": fmtFloat(unrealized),
+		"availableBalance":      margin,
+		"assets": []map[string]string{{
+			"asset":             defaultAsset,
+			"walletBalance":     wallet,
+			"availableBalance":  margin,
+			"marginBalance":     margin,
+			"maxWithdrawAmount": wallet,
+		}},
+		"positions": positions,
+	}, nil
+}
+
+func (s *Server) handleBalance(*http.Request) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return []map[string]string{{
+		"asset":            defaultAsset,
+		"balance":          fmtFloat(s.wallet),
+		"availableBalance": fmtFloat(s.wallet + s.totalUnrealizedLocked()),
+	}}, nil
+}
+
+func (s *Server) handleLeverageBracket(r *http.Request) (interface{}, error) {
+	only := r.URL.Query().Get("symbol")
+	out := make([]interface{}, 0, len(s.opts.Symbols))
+	for _, spec := range s.opts.Symbols {
+		if only != "" && spec.Symbol != only {
+			continue
+		}
+		out = append(out, map[string]interface{}{
+			"symbol": spec.Symbol,
+			"brackets": []map[string]interface{}{
+				{"bracket": 1, "initialLeverage": 125, "notionalCap": 50000, "notionalFloor": 0, "maintMarginRatio": 0.004},
+			},
+		})
+	}
+	return out, nil
+}
+
+func (s *Server) handleLeverage(r *http.Request) (interface{}, error) {
+	q := r.URL.Query()
+	lev, _ := strconv.Atoi(q.Get("leverage"))
+	return map[string]interface{}{"symbol": q.Get("symbol"), "leverage": lev, "maxNotionalValue": "50000"}, nil
+}
+
+func (s *Server) handleMarginType(*http.Request) (interface{}, error) {
+	return map[string]interface{}{"code": 200, "msg": "success"}, nil
+}
+
+func (s *Server) handleGetDual(*http.Request) (interface{}, error) {
+	return map[string]bool{"dualSidePosition": false}, nil
+}
+
+func (s *Server) handleSetDual(*http.Request) (interface{}, error) {
+	return map[string]interface{}{"code": 200, "msg": "success"}, nil
+}
+
+// handleNewListenKey 已有有效 listenKey 时返回同一个（与交易所行为一致）
+func (s *Server) handleNewListenKey(*http.Request) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.listenKey == "" {
+		buf := make([]byte, 16)
+		_, _ = rand.Read(buf)
+		s.listenKey = hex.EncodeToString(buf)
+	}
+	return map[string]string{"listenKey": s.listenKey}, nil
+}
+
+func (s *Server) handleKeepAliveListenKey(r *http.Request) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key := r.URL.Query().Get("listenKey"); key == "" || key != s.listenKey {
+		return nil, &apiError{Status: http.StatusBadRequest, Code: -1125, Msg: "This listenKey does not exist."}
+	}
+	return map[string]string{"listenKey": s.listenKey}, nil
+}
+
+func (s *Server) handleCloseListenKey(r *http.Request) (interface{}, error) {
+	s.mu.Lock()
+	if key := r.URL.Query().Get("listenKey"); key == s.listenKey {
+		s.listenKey = ""
+	}
+	s.mu.Unlock()
+	return map[string]string{}, nil
+}
+
+// orderJSON 订单响应格式（数值字段均为字符串）
+func orderJSON(o *Order) map[string]interface{} {
+	return map[string]interface{}{
+		"orderId":       o.OrderID,
+		"symbol":        o.Symbol,
+		"status":        o.Status,
+		"clientOrderId": o.ClientOrderID,
+		"price":         fmtFloat(o.Price),
+		"avgPrice":      fmtFloat(o.AvgPrice()),
+		"origQty":       fmtFloat(o.OrigQty),
+		"executedQty":   fmtFloat(o.ExecutedQty),
+		"cumQuote":      fmtFloat(o.CumQuote),
+		"timeInForce":   o.TimeInForce,
+		"type":          o.Type,
+		"reduceOnly":    o.ReduceOnly,
+		"side":          o.Side,
+		"positionSide":  "BOTH",
+		"updateTime":    o.UpdateTime,
+	}
+}
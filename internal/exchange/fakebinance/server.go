@@ -0,0 +1,314 @@
+// Package fakebinance 本地 Binance U本位合约 API 模拟器，供集成测试离线驱动真实适配器。
+// 覆盖 BinanceRESTClient 使用的 REST 端点、combined stream 深度/成交、/ws/{listenKey}
+// 用户数据流与 ws-fapi 交易 API；内置撮合、持仓记账与可注入故障。
+package fakebinance
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultRecvWindow = 5000
+	defaultAsset      = "USDT"
+)
+
+// Options 模拟器配置
+type Options struct {
+	APIKey         string
+	Secret         string
+	Symbols        []SymbolSpec  // 默认 BTCUSDT/ETHUSDT
+	InitialBalance float64       // 初始钱包余额（默认10000）
+	MakerFee       float64       // maker费率（默认0.0002）
+	TakerFee       float64       // taker费率（默认0.0004）
+	ClockOffset    time.Duration // 服务器时间相对本机的偏移，用于时钟漂移测试
+	WeightLimit    int           // 每分钟请求权重上限，0为不限制
+	OrderLimit     int           // 每10秒下单数上限，0为不限制
+}
+
+// Server 模拟器实例
+type Server struct {
+	opts Options
+	http *httptest.Server
+
+	mu          sync.Mutex
+	markets     map[string]*market
+	orders      map[int64]*Order
+	byClientID  map[string]*Order
+	positions   map[string]*Position
+	wallet      float64
+	nextOrderID int64
+
+	listenKey string
+
+	weightWindow int64 // 当前权重统计窗口（分钟）
+	usedWeight   int
+	orderWindow  int64 // 当前下单统计窗口（10秒）
+	orderCount   int
+	calls        map[string]int
+
+	faults []*Fault
+
+	connMu sync.Mutex
+	conns  map[*wsConn]struct{}
+}
+
+// New 启动模拟器
+func New(opts Options) *Server {
+	if opts.APIKey == "" {
+		opts.APIKey = "test-key"
+	}
+	if opts.Secret == "" {
+		opts.Secret = "test-secret"
+	}
+	if len(opts.Symbols) == 0 {
+		opts.Symbols = []SymbolSpec{
+			{Symbol: "BTCUSDT", TickSize: 0.1, StepSize: 0.001, MinQty: 0.001, MinNotional: 100},
+			{Symbol: "ETHUSDT", TickSize: 0.01, StepSize: 0.001, MinQty: 0.001, MinNotional: 20},
+		}
+	}
+	if opts.InitialBalance == 0 {
+		opts.InitialBalance = 10000
+	}
+	if opts.MakerFee == 0 {
+		opts.MakerFee = 0.0002
+	}
+	if opts.TakerFee == 0 {
+		opts.TakerFee = 0.0004
+	}
+	s := &Server{
+		opts:        opts,
+		markets:     make(map[string]*market),
+		orders:      make(map[int64]*Order),
+		byClientID:  make(map[string]*Order),
+		positions:   make(map[string]*Position),
+		wallet:      opts.InitialBalance,
+		nextOrderID: 1000,
+		calls:       make(map[string]int),
+		conns:       make(map[*wsConn]struct{}),
+	}
+	for _, spec := range opts.Symbols {
+		s.markets[spec.Symbol] = newMarket(spec)
+	}
+	s.http = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// URL REST 根地址（BinanceRESTClient.BaseURL）
+func (s *Server) URL() string { return s.http.URL }
+
+// WSURL WebSocket 根地址（BinanceWSReal.BaseEndpoint / UserDataStream）
+func (s *Server) WSURL() string { return "ws://" + strings.TrimPrefix(s.http.URL, "http://") }
+
+// TradeWSURL ws-fapi 交易 API 地址
+func (s *Server) TradeWSURL() string { return s.WSURL() + "/ws-fapi/v1" }
+
+// APIKey 模拟器接受的 API key
+func (s *Server) APIKey() string { return s.opts.APIKey }
+
+// Secret 模拟器使用的签名密钥
+func (s *Server) Secret() string { return s.opts.Secret }
+
+// Close 断开全部 WebSocket 并关闭服务
+func (s *Server) Close() {
+	s.DisconnectStreams()
+	s.http.Close()
+}
+
+// Calls 返回某端点（如 "POST /fapi/v1/order" 或 "order.place"）的请求次数
+func (s *Server) Calls(op string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[op]
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/stream":
+		s.serveMarketStream(w, r)
+		return
+	case strings.HasPrefix(r.URL.Path, "/ws/"):
+		s.serveUserStream(w, r, strings.TrimPrefix(r.URL.Path, "/ws/"))
+		return
+	case r.URL.Path == "/ws-fapi/v1":
+		s.serveTradeAPI(w, r)
+		return
+	}
+
+	op := r.Method + " " + r.URL.Path
+	s.mu.Lock()
+	s.calls[op]++
+	s.mu.Unlock()
+
+	if f := s.takeFault(op); f != nil {
+		if f.Delay > 0 {
+			time.Sleep(f.Delay)
+		}
+		if f.Drop {
+			dropConnection(w)
+			return
+		}
+		if f.Code != 0 || f.Status != 0 {
+			s.writeError(w, f.apiError())
+			return
+		}
+	}
+
+	route, ok := restRoutes[op]
+	if !ok {
+		s.writeError(w, &apiError{Status: http.StatusNotFound, Code: -1000, Msg: "Unknown endpoint " + op})
+		return
+	}
+	if err := s.consumeWeight(w, r, route); err != nil {
+		s.writeError(w, err)
+		return
+	}
+	if route.signed {
+		if err := s.verifySigned(r); err != nil {
+			s.writeError(w, err)
+			return
+		}
+	} else if route.apiKey && r.Header.Get("X-MBX-APIKEY") != s.opts.APIKey {
+		s.writeError(w, &apiError{Status: http.StatusUnauthorized, Code: -2015, Msg: "Invalid API-key, IP, or permissions for action."})
+		return
+	}
+
+	body, err := route.handle(s, r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// verifySigned 校验 API key、HMAC 签名与 timestamp/recvWindow
+func (s *Server) verifySigned(r *http.Request) error {
+	if r.Header.Get("X-MBX-APIKEY") != s.opts.APIKey {
+		return &apiError{Status: http.StatusUnauthorized, Code: -2015, Msg: "Invalid API-key, IP, or permissions for action."}
+	}
+	raw := r.URL.RawQuery
+	idx := strings.LastIndex(raw, "signature=")
+	if idx < 0 {
+		return &apiError{Status: http.StatusBadRequest, Code: -1102, Msg: "Mandatory parameter 'signature' was not sent, was empty/null, or malformed."}
+	}
+	payload := strings.TrimSuffix(raw[:idx], "&")
+	sig := raw[idx+len("signature="):]
+	if end := strings.IndexByte(sig, '&'); end >= 0 {
+		sig = sig[:end]
+	}
+	if !hmac.Equal([]byte(sig), []byte(sign(s.opts.Secret, payload))) {
+		return &apiError{Status: http.StatusBadRequest, Code: -1022, Msg: "Signature for this request is not valid."}
+	}
+	q := r.URL.Query()
+	return s.checkTimestamp(q.Get("timestamp"), q.Get("recvWindow"))
+}
+
+// checkTimestamp 请求时间须在 [服务器时间-recvWindow, 服务器时间+1秒] 内
+func (s *Server) checkTimestamp(tsRaw, windowRaw string) error {
+	ts, err := strconv.ParseInt(tsRaw, 10, 64)
+	if err != nil {
+		return &apiError{Status: http.StatusBadRequest, Code: -1102, Msg: "Mandatory parameter 'timestamp' was not sent, was empty/null, or malformed."}
+	}
+	window := int64(defaultRecvWindow)
+	if windowRaw != "" {
+		if v, err := strconv.ParseInt(windowRaw, 10, 64); err == nil && v > 0 {
+			window = v
+		}
+	}
+	now := s.nowMillis()
+	if ts < now-window {
+		return &apiError{Status: http.StatusBadRequest, Code: -1021, Msg: "Timestamp for this request is outside of the recvWindow."}
+	}
+	if ts > now+1000 {
+		return &apiError{Status: http.StatusBadRequest, Code: -1021, Msg: "Timestamp for this request was 1000ms ahead of the server's time."}
+	}
+	return nil
+}
+
+// consumeWeight 累计请求权重与下单数，写入 X-MBX-USED-WEIGHT-1M / X-MBX-ORDER-COUNT-* 响应头，超限返回429
+func (s *Server) consumeWeight(w http.ResponseWriter, r *http.Request, route restRoute) error {
+	weight := route.weight
+	if route.weightFn != nil {
+		weight = route.weightFn(r)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if minute := now.Unix() / 60; minute != s.weightWindow {
+		s.weightWindow, s.usedWeight = minute, 0
+	}
+	if window := now.Unix() / 10; window != s.orderWindow {
+		s.orderWindow, s.orderCount = window, 0
+	}
+	s.usedWeight += weight
+	w.Header().Set("X-MBX-USED-WEIGHT-1M", strconv.Itoa(s.usedWeight))
+	if route.order {
+		s.orderCount += route.orderCount(r)
+		w.Header().Set("X-MBX-ORDER-COUNT-10S", strconv.Itoa(s.orderCount))
+		w.Header().Set("X-MBX-ORDER-COUNT-1M", strconv.Itoa(s.orderCount))
+	}
+	if s.opts.WeightLimit > 0 && s.usedWeight > s.opts.WeightLimit {
+		w.Header().Set("Retry-After", strconv.FormatInt(60-now.Unix()%60, 10))
+		return &apiError{Status: http.StatusTooManyRequests, Code: -1003, Msg: "Too many requests; current limit is " + strconv.Itoa(s.opts.WeightLimit) + " requests per minute."}
+	}
+	if route.order && s.opts.OrderLimit > 0 && s.orderCount > s.opts.OrderLimit {
+		w.Header().Set("Retry-After", strconv.FormatInt(10-now.Unix()%10, 10))
+		return &apiError{Status: http.StatusTooManyRequests, Code: -1015, Msg: "Too many new orders; current limit is " + strconv.Itoa(s.opts.OrderLimit) + " orders per 10 seconds."}
+	}
+	return nil
+}
+
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	ae, ok := err.(*apiError)
+	if !ok {
+		ae = &apiError{Status: http.StatusBadRequest, Code: -1000, Msg: err.Error()}
+	}
+	status := ae.Status
+	if status == 0 {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, status, map[string]interface{}{"code": ae.Code, "msg": ae.Msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// dropConnection 不返回响应直接断开TCP连接，模拟网络中断
+func dropConnection(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+func sign(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func fmtFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin:  func(*http.Request) bool { return true },
+	Subprotocols: []string{"binary"},
+}
@@ -0,0 +1,153 @@
+package fakebinance
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// call 发送签名请求，返回状态码与响应体
+func call(t *testing.T, s *Server, method, path string, params url.Values) (int, map[string]interface{}, http.Header) {
+	t.Helper()
+	if params.Get("timestamp") == "" {
+		params.Set("timestamp", strconv.FormatInt(time.Now().UnixMilli(), 10))
+	}
+	query := params.Encode()
+	req, _ := http.NewRequest(method, s.URL()+path+"?"+query+"&signature="+sign(s.Secret(), query), nil)
+	req.Header.Set("X-MBX-APIKEY", s.APIKey())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	var out map[string]interface{}
+	_ = json.Unmarshal(body, &out)
+	return resp.StatusCode, out, resp.Header
+}
+
+func limitOrder(side, price, qty, clientID string) url.Values {
+	return url.Values{
+		"symbol": {"BTCUSDT"}, "side": {side}, "type": {"LIMIT"}, "timeInForce": {"GTX"},
+		"price": {price}, "quantity": {qty}, "newClientOrderId": {clientID},
+	}
+}
+
+func errCode(body map[string]interface{}) int {
+	code, _ := body["code"].(float64)
+	return int(code)
+}
+
+func TestServerAuthentication(t *testing.T) {
+	s := New(Options{})
+	defer s.Close()
+
+	status, body, _ := call(t, s, http.MethodGet, "/fapi/v1/openOrders", url.Values{
+		"timestamp": {strconv.FormatInt(time.Now().Add(-time.Minute).UnixMilli(), 10)},
+	})
+	if status != http.StatusBadRequest || errCode(body) != -1021 {
+		t.Fatalf("stale timestamp: status=%d body=%v", status, body)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, s.URL()+"/fapi/v1/openOrders?timestamp=1&signature=bad", nil)
+	req.Header.Set("X-MBX-APIKEY", s.APIKey())
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	_ = json.NewDecoder(resp.Body).Decode(&out)
+	resp.Body.Close()
+	if errCode(out) != -1022 {
+		t.Fatalf("bad signature should be -1022, got %v", out)
+	}
+}
+
+func TestServerMatchingAndAccounting(t *testing.T) {
+	s := New(Options{})
+	defer s.Close()
+	s.SetBook("BTCUSDT", []Level{{Price: 100, Qty: 5}}, []Level{{Price: 101, Qty: 5}})
+
+	if status, body, _ := call(t, s, http.MethodPost, "/fapi/v1/order", limitOrder("BUY", "101", "1", "cross")); status != http.StatusBadRequest || errCode(body) != -5022 {
+		t.Fatalf("crossing post-only should be -5022, got %d %v", status, body)
+	}
+	if status, body, _ := call(t, s, http.MethodPost, "/fapi/v1/order", limitOrder("BUY", "99.5", "2", "bid-1")); status != http.StatusOK || body["status"] != "NEW" {
+		t.Fatalf("place: %d %v", status, body)
+	}
+	if _, body, _ := call(t, s, http.MethodPost, "/fapi/v1/order", limitOrder("BUY", "99.5", "2", "bid-1")); errCode(body) != -4116 {
+		t.Fatalf("duplicate client id should be -4116, got %v", body)
+	}
+	if _, body, _ := call(t, s, http.MethodPut, "/fapi/v1/order", url.Values{
+		"symbol": {"BTCUSDT"}, "side": {"BUY"}, "origClientOrderId": {"bid-1"}, "price": {"99.5"}, "quantity": {"2"},
+	}); errCode(body) != -5027 {
+		t.Fatalf("unchanged amend should be -5027, got %v", body)
+	}
+
+	// 外部卖单打到99.5：挂单按maker成交
+	s.Trade("BTCUSDT", "SELL", 99.5, 3)
+	o, ok := s.OrderByClientID("bid-1")
+	if !ok || o.Status != "FILLED" || o.ExecutedQty != 2 {
+		t.Fatalf("resting bid should fill, got %+v", o)
+	}
+	pos := s.Position("BTCUSDT")
+	if pos.Amount != 2 || pos.EntryPrice != 99.5 {
+		t.Fatalf("position after fill: %+v", pos)
+	}
+
+	// 主动卖出平仓：按买一100成交，已实现1，扣除maker与taker手续费
+	status, body, _ := call(t, s, http.MethodPost, "/fapi/v1/order", url.Values{
+		"symbol": {"BTCUSDT"}, "side": {"SELL"}, "type": {"MARKET"}, "quantity": {"2"}, "reduceOnly": {"true"},
+	})
+	if status != http.StatusOK || body["status"] != "FILLED" {
+		t.Fatalf("market close: %d %v", status, body)
+	}
+	pos = s.Position("BTCUSDT")
+	if pos.Amount != 0 || math.Abs(pos.RealizedPnL-1) > 1e-9 {
+		t.Fatalf("position after close: %+v", pos)
+	}
+	want := 10000 + 1 - 199*0.0002 - 200*0.0004
+	if math.Abs(s.Wallet()-want) > 1e-9 {
+		t.Fatalf("wallet %.6f, want %.6f", s.Wallet(), want)
+	}
+
+	if _, body, _ := call(t, s, http.MethodDelete, "/fapi/v1/order", url.Values{
+		"symbol": {"BTCUSDT"}, "origClientOrderId": {"bid-1"},
+	}); errCode(body) != -2011 {
+		t.Fatalf("cancel of filled order should be -2011, got %v", body)
+	}
+	if _, body, _ := call(t, s, http.MethodGet, "/fapi/v1/order", url.Values{
+		"symbol": {"BTCUSDT"}, "origClientOrderId": {"missing"},
+	}); errCode(body) != -2013 {
+		t.Fatalf("query of missing order should be -2013, got %v", body)
+	}
+}
+
+func TestServerFaultsAndWeight(t *testing.T) {
+	s := New(Options{WeightLimit: 3})
+	defer s.Close()
+
+	s.InjectFault(Fault{Op: "GET /fapi/v1/openOrders", Status: http.StatusServiceUnavailable, Code: -1001, Times: 1})
+	status, body, _ := call(t, s, http.MethodGet, "/fapi/v1/openOrders", url.Values{"symbol": {"BTCUSDT"}})
+	if status != http.StatusServiceUnavailable || errCode(body) != -1001 {
+		t.Fatalf("injected fault: %d %v", status, body)
+	}
+
+	status, _, header := call(t, s, http.MethodGet, "/fapi/v1/openOrders", url.Values{"symbol": {"BTCUSDT"}})
+	if status != http.StatusOK || header.Get("X-MBX-USED-WEIGHT-1M") != "1" {
+		t.Fatalf("fault should be consumed: status=%d weight=%q", status, header.Get("X-MBX-USED-WEIGHT-1M"))
+	}
+
+	// 超出每分钟权重上限
+	status, body, header = call(t, s, http.MethodGet, "/fapi/v2/account", url.Values{})
+	if status != http.StatusTooManyRequests || errCode(body) != -1003 || header.Get("Retry-After") == "" {
+		t.Fatalf("weight limit: %d %v", status, body)
+	}
+	if s.Calls("GET /fapi/v1/openOrders") != 2 {
+		t.Fatalf("calls = %d", s.Calls("GET /fapi/v1/openOrders"))
+	}
+}
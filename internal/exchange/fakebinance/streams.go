@@ -0,0 +1,427 @@
+package fakebinance
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+const wsSendBuffer = 1024
+
+type connKind int
+
+const (
+	connMarket connKind = iota // /stream combined stream（行情，可附带listenKey）
+	connUser                   // /ws/{listenKey}
+	connTrade                  // /ws-fapi/v1 交易API
+)
+
+// wsConn 单个 WebSocket 连接：推送经缓冲通道由独立协程写出，避免在撮合锁内阻塞
+type wsConn struct {
+	kind connKind
+	conn *websocket.Conn
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+
+	mu      sync.Mutex
+	streams map[string]bool
+}
+
+func (s *Server) register(kind connKind, conn *websocket.Conn, streams []string) *wsConn {
+	c := &wsConn{
+		kind:    kind,
+		conn:    conn,
+		send:    make(chan []byte, wsSendBuffer),
+		done:    make(chan struct{}),
+		streams: make(map[string]bool),
+	}
+	for _, st := range streams {
+		c.streams[st] = true
+	}
+	s.connMu.Lock()
+	s.conns[c] = struct{}{}
+	s.connMu.Unlock()
+	go c.writeLoop()
+	return c
+}
+
+func (s *Server) unregister(c *wsConn) {
+	s.connMu.Lock()
+	delete(s.conns, c)
+	s.connMu.Unlock()
+	c.close()
+}
+
+func (c *wsConn) close() {
+	c.once.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+func (c *wsConn) writeLoop() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// push 非阻塞发送；缓冲满视为慢消费者并断开（与交易所行为一致）
+func (c *wsConn) push(msg []byte) {
+	select {
+	case c.send <- msg:
+	case <-c.done:
+	default:
+		c.close()
+	}
+}
+
+func (c *wsConn) subscribed(stream string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.streams[stream]
+}
+
+// broadcast 向订阅了 stream 的行情连接推送 combined 格式消息；user 为 true 时同时推送到 /ws/{listenKey} 连接
+func (s *Server) broadcast(stream string, data interface{}, user bool) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return
+	}
+	combined, _ := json.Marshal(map[string]json.RawMessage{"stream": json.RawMessage(strconv.Quote(stream)), "data": raw})
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	for c := range s.conns {
+		switch {
+		case c.kind == connMarket && c.subscribed(stream):
+			c.push(combined)
+		case c.kind == connUser && user:
+			c.push(raw)
+		}
+	}
+}
+
+func depthStream(symbol string) string    { return strings.ToLower(symbol) + "@depth@100ms" }
+func aggTradeStream(symbol string) string { return strings.ToLower(symbol) + "@aggTrade" }
+
+// emitDepthLocked 推送 depthUpdate（U/u/pu 连续），无变化时不推送
+func (s *Server) emitDepthLocked(m *market, bids, asks []Level) {
+	if len(bids) == 0 && len(asks) == 0 {
+		return
+	}
+	prev := m.lastUpdateID
+	m.lastUpdateID++
+	now := s.nowMillis()
+	s.broadcast(depthStream(m.spec.Symbol), map[string]interface{}{
+		"e": "depthUpdate", "E": now, "T": now, "s": m.spec.Symbol,
+		"U": m.lastUpdateID, "u": m.lastUpdateID, "pu": prev,
+		"b": levelStrings(bids), "a": levelStrings(asks),
+	}, false)
+}
+
+func (s *Server) emitAggTradeLocked(m *market, takerSide string, price, qty float64) {
+	now := s.nowMillis()
+	s.broadcast(aggTradeStream(m.spec.Symbol), map[string]interface{}{
+		"e": "aggTrade", "E": now, "s": m.spec.Symbol, "a": m.aggTradeID,
+		"p": fmtFloat(price), "q": fmtFloat(qty), "f": m.aggTradeID, "l": m.aggTradeID, "T": now,
+		"m": takerSide == "SELL", // 买方为maker
+	}, false)
+}
+
+// emitOrderLocked 推送 ORDER_TRADE_UPDATE
+func (s *Server) emitOrderLocked(o *Order, execType string, lastQty, lastPrice, realized, fee float64, maker bool) {
+	now := s.nowMillis()
+	s.broadcastUserLocked(map[string]interface{}{
+		"e": "ORDER_TRADE_UPDATE", "E": now, "T": now,
+		"o": map[string]interface{}{
+			"s": o.Symbol, "c": o.ClientOrderID, "S": o.Side, "o": o.Type, "f": o.TimeInForce,
+			"q": fmtFloat(o.OrigQty), "p": fmtFloat(o.Price), "ap": fmtFloat(o.AvgPrice()),
+			"x": execType, "X": o.Status, "i": o.OrderID,
+			"l": fmtFloat(lastQty), "z": fmtFloat(o.ExecutedQty), "L": fmtFloat(lastPrice),
+			"N": defaultAsset, "n": fmtFloat(fee), "T": o.UpdateTime, "m": maker,
+			"R": o.ReduceOnly, "ps": "BOTH", "rp": fmtFloat(realized),
+		},
+	})
+}
+
+// emitAccountLocked 推送 ACCOUNT_UPDATE；reason 为 ORDER / FUNDING_FEE 等，change 为余额变动
+func (s *Server) emitAccountLocked(reason, symbol string, change float64) {
+	pos := s.positionLocked(symbol)
+	now := s.nowMillis()
+	s.broadcastUserLocked(map[string]interface{}{
+		"e": "ACCOUNT_UPDATE", "E": now, "T": now,
+		"a": map[string]interface{}{
+			"m": reason,
+			"B": []map[string]string{{
+				"a": defaultAsset, "wb": fmtFloat(s.wallet), "cw": fmtFloat(s.wallet), "bc": fmtFloat(change),
+			}},
+			"P": []map[string]string{{
+				"s": symbol, "pa": fmtFloat(pos.Amount), "ep": fmtFloat(pos.EntryPrice),
+				"cr": fmtFloat(pos.RealizedPnL), "up": fmtFloat(s.unrealizedLocked(symbol)),
+				"mt": "cross", "iw": "0", "ps": "BOTH",
+			}},
+		},
+	})
+}
+
+// broadcastUserLocked 用户数据事件：listenKey 失效后不再推送
+func (s *Server) broadcastUserLocked(event interface{}) {
+	if s.listenKey == "" {
+		return
+	}
+	s.broadcast(s.listenKey, event, true)
+}
+
+// serveMarketStream /stream?streams=a/b/c，支持 SUBSCRIBE / UNSUBSCRIBE / LIST_SUBSCRIPTIONS
+func (s *Server) serveMarketStream(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.calls["WS /stream"]++
+	s.mu.Unlock()
+	var streams []string
+	if raw := r.URL.Query().Get("streams"); raw != "" {
+		streams = strings.Split(raw, "/")
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := s.register(connMarket, conn, streams)
+	defer s.unregister(c)
+	for {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req struct {
+			Method string   `json:"method"`
+			Params []string `json:"params"`
+			ID     int64    `json:"id"`
+		}
+		if json.Unmarshal(raw, &req) != nil {
+			continue
+		}
+		var result interface{}
+		c.mu.Lock()
+		switch req.Method {
+		case "SUBSCRIBE":
+			for _, st := range req.Params {
+				c.streams[st] = true
+			}
+		case "UNSUBSCRIBE":
+			for _, st := range req.Params {
+				delete(c.streams, st)
+			}
+		case "LIST_SUBSCRIPTIONS":
+			list := make([]string, 0, len(c.streams))
+			for st := range c.streams {
+				list = append(list, st)
+			}
+			result = list
+		}
+		c.mu.Unlock()
+		resp, _ := json.Marshal(map[string]interface{}{"result": result, "id": req.ID})
+		c.push(resp)
+	}
+}
+
+// serveUserStream /ws/{listenKey}：listenKey 无效时拒绝握手
+func (s *Server) serveUserStream(w http.ResponseWriter, r *http.Request, key string) {
+	s.mu.Lock()
+	s.calls["WS /ws"]++
+	valid := key != "" && key == s.listenKey
+	s.mu.Unlock()
+	if !valid {
+		s.writeError(w, &apiError{Status: http.StatusBadRequest, Code: -1125, Msg: "This listenKey does not exist."})
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := s.register(connUser, conn, nil)
+	defer s.unregister(c)
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+type tradeRequest struct {
+	ID     json.RawMessage        `json:"id"`
+	Method string                 `json:"method"`
+	Params map[string]interface{} `json:"params"`
+}
+
+// serveTradeAPI ws-fapi/v1：order.place / order.cancel / order.modify / order.status / openOrders.cancelAll
+func (s *Server) serveTradeAPI(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	c := s.register(connTrade, conn, nil)
+	defer s.unregister(c)
+	for {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var req tradeRequest
+		if json.Unmarshal(raw, &req) != nil {
+			continue
+		}
+		s.mu.Lock()
+		s.calls[req.Method]++
+		s.mu.Unlock()
+		// 请求逐个异步处理，故障注入的延迟不阻塞后续请求
+		go s.handleTradeRequest(c, req)
+	}
+}
+
+func (s *Server) handleTradeRequest(c *wsConn, req tradeRequest) {
+	if f := s.takeFault(req.Method); f != nil {
+		if f.Delay > 0 {
+			select {
+			case <-timeAfter(f.Delay):
+			case <-c.done:
+				return
+			}
+		}
+		if f.Drop {
+			return
+		}
+		if f.Code != 0 || f.Status != 0 {
+			c.push(tradeResponse(req.ID, nil, f.apiError()))
+			return
+		}
+	}
+	result, err := s.dispatchTrade(req)
+	c.push(tradeResponse(req.ID, result, err))
+}
+
+func (s *Server) dispatchTrade(req tradeRequest) (interface{}, error) {
+	if req.Method == "session.logon" {
+		// 与交易所一致：HMAC 密钥不支持会话登录，客户端需逐请求签名
+		return nil, &apiError{Status: http.StatusBadRequest, Code: -4056, Msg: "HMAC_SHA256 API key is not supported."}
+	}
+	if req.Method == "ping" {
+		return map[string]interface{}{}, nil
+	}
+	params, err := s.verifyTradeParams(req.Params)
+	if err != nil {
+		return nil, err
+	}
+	get := params.Get
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch req.Method {
+	case "order.place":
+		o, err := s.placeLocked(orderRequestFrom(get))
+		if err != nil {
+			return nil, err
+		}
+		return orderJSON(o), nil
+	case "order.cancel":
+		o, err := s.cancelLocked(s.lookupLocked(get))
+		if err != nil {
+			return nil, err
+		}
+		return orderJSON(o), nil
+	case "order.modify":
+		o, err := s.amendLocked(s.lookupLocked(get), parseFloatParam(get("price")), parseFloatParam(get("quantity")))
+		if err != nil {
+			return nil, err
+		}
+		return orderJSON(o), nil
+	case "order.status":
+		o := s.lookupLocked(get)
+		if o == nil {
+			return nil, errOrderNotExist
+		}
+		return orderJSON(o), nil
+	case "openOrders.cancelAll", "order.cancelAll":
+		if _, err := s.marketLocked(get("symbol")); err != nil {
+			return nil, err
+		}
+		s.cancelAllLocked(get("symbol"))
+		return map[string]interface{}{"code": 200, "msg": "The operation of cancel all open order is done."}, nil
+	}
+	return nil, &apiError{Status: http.StatusBadRequest, Code: -1000, Msg: "Unknown method " + req.Method}
+}
+
+// verifyTradeParams 校验 ws-fapi 请求签名：对除 signature 外的参数按 url.Values 编码后 HMAC
+func (s *Server) verifyTradeParams(raw map[string]interface{}) (url.Values, error) {
+	params := url.Values{}
+	for k, v := range raw {
+		switch val := v.(type) {
+		case string:
+			params.Set(k, val)
+		case float64:
+			params.Set(k, strconv.FormatFloat(val, 'f', -1, 64))
+		case bool:
+			params.Set(k, strconv.FormatBool(val))
+		}
+	}
+	if params.Get("apiKey") != s.opts.APIKey {
+		return nil, &apiError{Status: http.StatusUnauthorized, Code: -2015, Msg: "Invalid API-key, IP, or permissions for action."}
+	}
+	sig := params.Get("signature")
+	params.Del("signature")
+	if sig != sign(s.opts.Secret, params.Encode()) {
+		return nil, &apiError{Status: http.StatusBadRequest, Code: -1022, Msg: "Signature for this request is not valid."}
+	}
+	if err := s.checkTimestamp(params.Get("timestamp"), params.Get("recvWindow")); err != nil {
+		return nil, err
+	}
+	return params, nil
+}
+
+func tradeResponse(id json.RawMessage, result interface{}, err error) []byte {
+	resp := map[string]interface{}{"id": id, "status": http.StatusOK}
+	if err != nil {
+		ae, ok := err.(*apiError)
+		if !ok {
+			ae = &apiError{Status: http.StatusBadRequest, Code: -1000, Msg: err.Error()}
+		}
+		status := ae.Status
+		if status == 0 {
+			status = http.StatusBadRequest
+		}
+		resp["status"] = status
+		resp["error"] = map[string]interface{}{"code": ae.Code, "msg": ae.Msg}
+	} else {
+		resp["result"] = result
+	}
+	out, _ := json.Marshal(resp)
+	return out
+}
+
+// StreamCount 当前 WebSocket 连接数：行情、用户数据、交易API
+func (s *Server) StreamCount() (market, user, trade int) {
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	for c := range s.conns {
+		switch c.kind {
+		case connMarket:
+			market++
+		case connUser:
+			user++
+		case connTrade:
+			trade++
+		}
+	}
+	return market, user, trade
+}
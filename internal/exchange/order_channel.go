@@ -0,0 +1,224 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// OrderChannel 下单/撤单所使用的通道
+type OrderChannel string
+
+const (
+	OrderChannelREST           OrderChannel = "rest"                  // 仅REST（默认）
+	OrderChannelWS             OrderChannel = "ws"                    // 仅WebSocket API
+	OrderChannelWSWithFallback OrderChannel = "ws_with_rest_fallback" // 优先WebSocket API，超时或断线时回退REST
+)
+
+// label 日志中使用的通道名
+func (c OrderChannel) label() string {
+	if c == OrderChannelWS {
+		return "WS"
+	}
+	return "REST"
+}
+
+// SetOrderChannel 设置下单通道，需在Connect之前调用
+func (b *BinanceAdapter) SetOrderChannel(channel OrderChannel) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.orderChannel = channel
+}
+
+func (b *BinanceAdapter) getOrderChannel() OrderChannel {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.orderChannel == "" {
+		return OrderChannelREST
+	}
+	return b.orderChannel
+}
+
+// useWS 判断本次请求是否走WebSocket API。
+// 回退模式下WSS未连接时直接走REST并在后台重连，避免请求阻塞在拨号重试上
+func (b *BinanceAdapter) useWS(channel OrderChannel) bool {
+	switch channel {
+	case OrderChannelWS:
+		return true
+	case OrderChannelWSWithFallback:
+		return b.tradeWSAvailable()
+	default:
+		return false
+	}
+}
+
+func (b *BinanceAdapter) tradeWSAvailable() bool {
+	if atomic.LoadInt32(&b.tradeWSDialing) == 1 {
+		return false
+	}
+	if b.tradeWS.Healthy() {
+		return true
+	}
+	if atomic.CompareAndSwapInt32(&b.tradeWSDialing, 0, 1) {
+		go func() {
+			defer atomic.StoreInt32(&b.tradeWSDialing, 0)
+			if err := b.tradeWS.Connect(); err != nil {
+				log.Warn().Err(err).Msg("WSS交易通道连接失败，暂时使用REST")
+				return
+			}
+			log.Info().Msg("WSS交易通道已连接")
+		}()
+	}
+	return false
+}
+
+// shouldFallback WSS请求没有得到交易所答复（超时、断线、拨号失败）时订单状态未知，
+// 可以使用同一ClientOrderID通过REST安全重试；交易所明确拒绝或调用方取消时不重试
+func shouldFallback(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var exErr *ExchangeError
+	return !errors.As(err, &exErr)
+}
+
+// placeViaWS 通过WebSocket API下Post Only限价单，返回交易所订单ID
+func (b *BinanceAdapter) placeViaWS(ctx context.Context, order *Order) (string, error) {
+	start := time.Now()
+	result, err := b.tradeWS.PlaceOrder(ctx, TradeOrderParams{
+		Symbol:        order.Symbol,
+		Side:          order.Side,
+		Type:          "LIMIT",
+		Quantity:      order.Quantity,
+		Price:         order.Price,
+		ClientOrderID: order.ClientOrderID,
+		PostOnly:      true, // Maker-only
+	})
+	observeOrderChannel(OrderChannelWS, "place", start, err)
+	if err != nil {
+		return "", err
+	}
+	var ack struct {
+		OrderID json.Number `json:"orderId"`
+	}
+	_ = json.Unmarshal(result, &ack)
+	return ack.OrderID.String(), nil
+}
+
+// placeViaREST 通过REST下Post Only限价单，返回交易所订单ID
+func (b *BinanceAdapter) placeViaREST(order *Order) (string, error) {
+	start := time.Now()
+	orderID, err := b.rest.PlaceLimit(
+		order.Symbol,
+		order.Side,
+		"GTC", // Good Till Cancel
+		order.Price,
+		order.Quantity,
+		false, // reduceOnly
+		true,  // postOnly - Maker-only for free fees
+		order.ClientOrderID,
+	)
+	observeOrderChannel(OrderChannelREST, "place", start, err)
+	return orderID, err
+}
+
+// PlaceIOC 通过REST下IOC限价单，价格即可接受的最差成交价
+func (b *BinanceAdapter) PlaceIOC(ctx context.Context, order *Order) (*Order, error) {
+	if order == nil {
+		return nil, ErrInvalidOrder
+	}
+	b.ensureClientOrderID(order)
+
+	start := time.Now()
+	orderID, err := b.rest.PlaceLimit(order.Symbol, order.Side, "IOC", order.Price, order.Quantity, false, false, order.ClientOrderID)
+	observeOrderChannel(OrderChannelREST, "place", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("%s place IOC order failed: %w", OrderChannelREST, err)
+	}
+	order.CreatedAt = time.Now()
+
+	log.Info().
+		Str("symbol", order.Symbol).
+		Str("side", order.Side).
+		Float64("price", order.Price).
+		Float64("qty", order.Quantity).
+		Str("client_id", order.ClientOrderID).
+		Str("order_id", orderID).
+		Msg("IOC订单已下达")
+	return order, nil
+}
+
+func (b *BinanceAdapter) cancelViaWS(ctx context.Context, symbol, clientOrderID string) error {
+	start := time.Now()
+	_, err := b.tradeWS.CancelOrder(ctx, TradeCancelParams{Symbol: symbol, ClientOrderID: clientOrderID})
+	observeOrderChannel(OrderChannelWS, "cancel", start, err)
+	return err
+}
+
+func (b *BinanceAdapter) cancelViaREST(symbol, clientOrderID string) error {
+	start := time.Now()
+	err := b.rest.CancelOrder(symbol, clientOrderID)
+	observeOrderChannel(OrderChannelREST, "cancel", start, err)
+	return err
+}
+
+// amendViaWS 通过WebSocket API修改限价单，返回交易所订单ID
+func (b *BinanceAdapter) amendViaWS(ctx context.Context, order *Order) (string, error) {
+	start := time.Now()
+	result, err := b.tradeWS.ModifyOrder(ctx, TradeModifyParams{
+		Symbol:        order.Symbol,
+		Side:          order.Side,
+		ClientOrderID: order.ClientOrderID,
+		Quantity:      order.Quantity,
+		Price:         order.Price,
+	})
+	observeOrderChannel(OrderChannelWS, "amend", start, err)
+	if err != nil {
+		return "", err
+	}
+	var ack struct {
+		OrderID json.Number `json:"orderId"`
+	}
+	_ = json.Unmarshal(result, &ack)
+	return ack.OrderID.String(), nil
+}
+
+// amendViaREST 通过REST修改限价单，返回交易所订单ID
+func (b *BinanceAdapter) amendViaREST(order *Order) (string, error) {
+	if b.restClient == nil {
+		return "", fmt.Errorf("rest client not available")
+	}
+	start := time.Now()
+	orderID, err := b.restClient.AmendLimit(order.Symbol, order.Side, order.ClientOrderID, order.Price, order.Quantity)
+	observeOrderChannel(OrderChannelREST, "amend", start, err)
+	return orderID, err
+}
+
+func observeOrderChannel(channel OrderChannel, op string, start time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	metrics.ObserveOrderChannel(string(channel), op, result, time.Since(start))
+}
+
+// isDuplicateClientOrderID 下单返回-4116表示该ClientOrderID已存在
+func isDuplicateClientOrderID(err error) bool {
+	return HasErrorCode(err, -4116)
+}
+
+// isNoNeedToModify 改单返回-5027表示订单已是目标价格和数量
+func isNoNeedToModify(err error) bool {
+	return HasErrorCode(err, -5027)
+}
+
+// isUnknownOrder 撤单返回-2011表示订单已不在挂单中
+func isUnknownOrder(err error) bool {
+	return errors.Is(err, ErrUnknownOrder)
+}
@@ -0,0 +1,202 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newFakeTradeWS 模拟WebSocket API：登录直接成功，order.place/order.cancel 按reply返回；
+// reply返回空串时不答复（模拟ACK超时）
+func newFakeTradeWS(t *testing.T, reply func(method string, id int64) string) *httptest.Server {
+	upgrader := websocket.Upgrader{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			var req wsRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			if req.Method == "session.logon" {
+				conn.WriteJSON(map[string]interface{}{"id": req.ID, "status": 200, "result": map[string]interface{}{}})
+				continue
+			}
+			if req.Params["apiKey"] != "k" || req.Params["signature"] == nil {
+				t.Errorf("expected signed request with apiKey, got %v", req.Params)
+			}
+			if msg := reply(req.Method, req.ID); msg != "" {
+				conn.WriteMessage(websocket.TextMessage, []byte(msg))
+			}
+		}
+	}))
+}
+
+// newFakeOrderREST 记录REST下单/撤单使用的ClientOrderID
+func newFakeOrderREST(placeStatus int, placeBody string) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		mu.Lock()
+		switch r.Method {
+		case http.MethodPost:
+			calls = append(calls, "place:"+q.Get("newClientOrderId"))
+		case http.MethodDelete:
+			calls = append(calls, "cancel:"+q.Get("origClientOrderId"))
+		}
+		mu.Unlock()
+		if r.Method == http.MethodPost {
+			w.WriteHeader(placeStatus)
+			w.Write([]byte(placeBody))
+			return
+		}
+		w.Write([]byte(`{"orderId":77,"status":"CANCELED"}`))
+	}))
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), calls...)
+	}
+}
+
+func newChannelTestAdapter(t *testing.T, wsURL, restURL string, channel OrderChannel) *BinanceAdapter {
+	rest := &BinanceRESTClient{BaseURL: restURL, APIKey: "k", Secret: "s", HTTPClient: http.DefaultClient}
+	adapter := NewBinanceAdapter(rest, &BinanceWSStub{})
+	adapter.tradeWS = NewTradeWSClient(TradeWSConfig{
+		BaseURL:      "ws" + strings.TrimPrefix(wsURL, "http"),
+		APIKey:       "k",
+		SecretKey:    "s",
+		AckTimeout:   100 * time.Millisecond,
+		RetryBackoff: 10 * time.Millisecond,
+		MaxRetries:   1,
+	})
+	adapter.SetOrderChannel(channel)
+	if err := adapter.tradeWS.Connect(); err != nil {
+		t.Fatalf("connect trade ws: %v", err)
+	}
+	t.Cleanup(adapter.tradeWS.Close)
+	return adapter
+}
+
+func TestBinanceAdapter_OrderChannelFallbackOnTimeout(t *testing.T) {
+	ws := newFakeTradeWS(t, func(method string, id int64) string { return "" })
+	defer ws.Close()
+	rest, calls := newFakeOrderREST(http.StatusOK, `{"orderId":77}`)
+	defer rest.Close()
+
+	adapter := newChannelTestAdapter(t, ws.URL, rest.URL, OrderChannelWSWithFallback)
+	order, err := adapter.PlaceOrder(context.Background(), &Order{Symbol: "ETHUSDC", Side: "BUY", Price: 3000, Quantity: 0.01, ClientOrderID: "phoenix-a"})
+	if err != nil || order.Status != "NEW" {
+		t.Fatalf("expected REST fallback to place the order, got %+v err=%v", order, err)
+	}
+	if err := adapter.CancelOrder(context.Background(), "ETHUSDC", "phoenix-a"); err != nil {
+		t.Fatalf("expected REST fallback to cancel the order, got %v", err)
+	}
+	got := calls()
+	if len(got) != 2 || got[0] != "place:phoenix-a" || got[1] != "cancel:phoenix-a" {
+		t.Errorf("expected REST retries with the same ClientOrderID, got %v", got)
+	}
+}
+
+func TestBinanceAdapter_OrderChannelDuplicateIsIdempotent(t *testing.T) {
+	ws := newFakeTradeWS(t, func(method string, id int64) string { return "" })
+	defer ws.Close()
+	// WSS请求实际已到达交易所，REST重试被判定为重复ClientOrderID
+	rest, _ := newFakeOrderREST(http.StatusBadRequest, `{"code":-4116,"msg":"ClientOrderId is duplicated."}`)
+	defer rest.Close()
+
+	adapter := newChannelTestAdapter(t, ws.URL, rest.URL, OrderChannelWSWithFallback)
+	order, err := adapter.PlaceOrder(context.Background(), &Order{Symbol: "ETHUSDC", Side: "SELL", Price: 3010, Quantity: 0.01, ClientOrderID: "phoenix-b"})
+	if err != nil || order.Status != "NEW" {
+		t.Fatalf("expected duplicate ClientOrderID treated as placed, got %+v err=%v", order, err)
+	}
+}
+
+func TestBinanceAdapter_OrderChannelRejectDoesNotFallback(t *testing.T) {
+	ws := newFakeTradeWS(t, func(method string, id int64) string {
+		b, _ := json.Marshal(map[string]interface{}{"id": id, "status": 400, "error": map[string]interface{}{"code": -5022, "msg": "Post Only order will be rejected"}})
+		return string(b)
+	})
+	defer ws.Close()
+	rest, calls := newFakeOrderREST(http.StatusOK, `{"orderId":77}`)
+	defer rest.Close()
+
+	adapter := newChannelTestAdapter(t, ws.URL, rest.URL, OrderChannelWSWithFallback)
+	_, err := adapter.PlaceOrder(context.Background(), &Order{Symbol: "ETHUSDC", Side: "BUY", Price: 3000, Quantity: 0.01, ClientOrderID: "phoenix-c"})
+	if err == nil || !strings.Contains(err.Error(), "-5022") {
+		t.Fatalf("expected -5022 rejection, got %v", err)
+	}
+	if got := calls(); len(got) != 0 {
+		t.Errorf("expected no REST retry after an exchange rejection, got %v", got)
+	}
+}
+
+// TestBinanceAdapter_OrderChannelWSStatusWithoutErrorBody 仅有WS状态码时不能当作交易所错误码
+func TestBinanceAdapter_OrderChannelWSStatusWithoutErrorBody(t *testing.T) {
+	ws := newFakeTradeWS(t, func(method string, id int64) string {
+		b, _ := json.Marshal(map[string]interface{}{"id": id, "status": 429})
+		return string(b)
+	})
+	defer ws.Close()
+	rest, _ := newFakeOrderREST(http.StatusOK, `{"orderId":77}`)
+	defer rest.Close()
+
+	adapter := newChannelTestAdapter(t, ws.URL, rest.URL, OrderChannelWS)
+	_, err := adapter.PlaceOrder(context.Background(), &Order{Symbol: "ETHUSDC", Side: "BUY", Price: 3000, Quantity: 0.01, ClientOrderID: "phoenix-e"})
+	var exErr *ExchangeError
+	if !errors.As(err, &exErr) {
+		t.Fatalf("expected ExchangeError, got %v", err)
+	}
+	if exErr.Code != 0 || exErr.Status != 429 || HasErrorCode(err, 429) {
+		t.Errorf("ws status should stay out of the venue error code, got %+v", exErr)
+	}
+	if !errors.Is(err, ErrRateLimited) {
+		t.Errorf("expected 429 status to be classified as rate limited, got %v", err)
+	}
+}
+
+func TestBinanceAdapter_OrderChannelWSAck(t *testing.T) {
+	ws := newFakeTradeWS(t, func(method string, id int64) string {
+		b, _ := json.Marshal(map[string]interface{}{"id": id, "status": 200, "result": map[string]interface{}{"orderId": 123, "clientOrderId": "phoenix-d"}})
+		return string(b)
+	})
+	defer ws.Close()
+	rest, calls := newFakeOrderREST(http.StatusOK, `{"orderId":77}`)
+	defer rest.Close()
+
+	adapter := newChannelTestAdapter(t, ws.URL, rest.URL, OrderChannelWS)
+	if _, err := adapter.PlaceOrder(context.Background(), &Order{Symbol: "ETHUSDC", Side: "BUY", Price: 3000, Quantity: 0.01, ClientOrderID: "phoenix-d"}); err != nil {
+		t.Fatalf("place over ws: %v", err)
+	}
+	if err := adapter.CancelOrder(context.Background(), "ETHUSDC", "phoenix-d"); err != nil {
+		t.Fatalf("cancel over ws: %v", err)
+	}
+	if got := calls(); len(got) != 0 {
+		t.Errorf("expected no REST calls in ws mode, got %v", got)
+	}
+}
+
+func TestNextClientOrderIDLength(t *testing.T) {
+	var seq uint64
+	for _, symbol := range []string{"ETHUSDC", "1000SHIBUSDT", "1000000MOGUSDT"} {
+		id := NextClientOrderID(&seq, symbol)
+		if len(id) > clientOrderIDMaxLen || !strings.HasPrefix(id, "phoenix-") {
+			t.Errorf("%s: client order id %q exceeds %d chars", symbol, id, clientOrderIDMaxLen)
+		}
+	}
+	if id := NextClientOrderID(&seq, "ETHUSDC"); !strings.HasPrefix(id, "phoenix-ETHUSDC-") {
+		t.Errorf("short symbol should be kept in full, got %q", id)
+	}
+}
@@ -3,7 +3,6 @@ package gateway
 import (
 	"fmt"
 	"net/http"
-	"strings"
 	"time"
 )
 
@@ -158,14 +157,17 @@ func ClassifyError(statusCode int, body string) ErrorType {
 	case statusCode >= 500:
 		return ErrorTypeServer
 	case statusCode >= 400 && statusCode < 500:
-		// 检查特定错误码
-		if strings.Contains(body, "-1021") { // Timestamp outside of recvWindow
+		// 按响应体中的错误码分类
+		code := parseErrorCode(body)
+		switch (&ExchangeError{Code: code, Status: statusCode}).Category() {
+		case CategoryTimestamp:
 			return ErrorTypeAuth
-		}
-		if strings.Contains(body, "-5022") { // Post-only reject
+		case CategoryPostOnlyReject:
 			return ErrorTypePostOnlyReject
+		case CategoryInsufficientMargin:
+			return ErrorTypeInsufficientBalance
 		}
-		if strings.Contains(body, "-2010") { // Insufficient balance
+		if code == -2010 { // 下单被拒（余额不足）
 			return ErrorTypeInsufficientBalance
 		}
 		return ErrorTypeClient
@@ -68,6 +68,14 @@ func isRetryableError(err error) bool {
 		return false
 	}
 
+	// 交易所明确答复的错误按分类判断
+	switch CategoryOf(err) {
+	case CategoryRateLimited:
+		return true
+	case CategoryPostOnlyReject, CategoryUnknownOrder, CategoryInsufficientMargin, CategoryTimestamp, CategoryFilter:
+		return false
+	}
+
 	errStr := err.Error()
 
 	// 网络相关错误可重试
@@ -1,22 +1,32 @@
 package gateway
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/metrics"
+	"github.com/rs/zerolog/log"
 )
 
-// TimeSync 管理与币安服务器的时间同步
+// TimeSync 管理与币安服务器的时间同步，签名请求的时间戳统一取自 GetServerTime
 type TimeSync struct {
 	mu           sync.RWMutex
 	offset       int64 // 本地时间与服务器时间的差值（毫秒）
+	rtt          time.Duration
 	lastSync     time.Time
 	syncInterval time.Duration
 	baseURL      string
 	httpClient   *http.Client
+
+	syncMu  sync.Mutex // 串行化同步请求
+	syncing int32      // GetServerTime 触发的后台同步进行中
+	started int32
 }
 
 // NewTimeSync 创建时间同步器
@@ -28,8 +38,25 @@ func NewTimeSync(baseURL string) *TimeSync {
 	}
 }
 
-// Sync 从币安服务器同步时间
+// SetSyncInterval 设置后台同步间隔（需在 Start 之前调用）
+func (ts *TimeSync) SetSyncInterval(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	ts.mu.Lock()
+	ts.syncInterval = d
+	ts.mu.Unlock()
+}
+
+// Sync 从币安服务器同步时间；偏移按往返时间的中点估计
 func (ts *TimeSync) Sync() error {
+	ts.syncMu.Lock()
+	defer ts.syncMu.Unlock()
+	return ts.syncLocked()
+}
+
+func (ts *TimeSync) syncLocked() error {
+	sent := time.Now()
 	resp, err := ts.httpClient.Get(ts.baseURL + "/fapi/v1/time")
 	if err != nil {
 		return fmt.Errorf("获取服务器时间失败: %w", err)
@@ -47,28 +74,75 @@ func (ts *TimeSync) Sync() error {
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return fmt.Errorf("解析服务器时间失败: %w", err)
 	}
+	received := time.Now()
 
-	localTime := time.Now().UnixMilli()
-	offset := result.ServerTime - localTime
+	// 服务器时间对应请求发出与收到响应的中点
+	rtt := received.Sub(sent)
+	localMid := sent.Add(rtt / 2).UnixMilli()
+	offset := result.ServerTime - localMid
 
 	ts.mu.Lock()
 	ts.offset = offset
-	ts.lastSync = time.Now()
+	ts.rtt = rtt
+	ts.lastSync = received
 	ts.mu.Unlock()
 
+	metrics.SetClockSync(float64(offset), float64(rtt.Microseconds())/1000)
 	return nil
 }
 
+// resync 因 -1021 立即重新同步；若 since 之后已有其他请求完成同步则直接复用
+func (ts *TimeSync) resync(since time.Time) error {
+	ts.syncMu.Lock()
+	defer ts.syncMu.Unlock()
+	ts.mu.RLock()
+	fresh := ts.lastSync.After(since)
+	ts.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+	return ts.syncLocked()
+}
+
+// Start 启动后台定时同步，ctx 取消时退出；重复调用无效
+func (ts *TimeSync) Start(ctx context.Context) {
+	if !atomic.CompareAndSwapInt32(&ts.started, 0, 1) {
+		return
+	}
+	ts.mu.RLock()
+	interval := ts.syncInterval
+	ts.mu.RUnlock()
+	go func() {
+		defer atomic.StoreInt32(&ts.started, 0)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := ts.Sync(); err != nil {
+					log.Warn().Err(err).Msg("服务器时间同步失败")
+				}
+			}
+		}
+	}()
+}
+
 // GetServerTime 返回同步后的服务器时间（毫秒）
 func (ts *TimeSync) GetServerTime() int64 {
 	ts.mu.RLock()
 	offset := ts.offset
 	lastSync := ts.lastSync
+	interval := ts.syncInterval
 	ts.mu.RUnlock()
 
 	// 如果从未同步或超过同步间隔，触发后台同步
-	if lastSync.IsZero() || time.Since(lastSync) > ts.syncInterval {
-		go ts.Sync()
+	if (lastSync.IsZero() || time.Since(lastSync) > interval) && atomic.CompareAndSwapInt32(&ts.syncing, 0, 1) {
+		go func() {
+			defer atomic.StoreInt32(&ts.syncing, 0)
+			_ = ts.Sync()
+		}()
 	}
 
 	return time.Now().UnixMilli() + offset
@@ -80,3 +154,10 @@ func (ts *TimeSync) GetOffset() int64 {
 	defer ts.mu.RUnlock()
 	return ts.offset
 }
+
+// GetRTT 返回最近一次同步的往返时间
+func (ts *TimeSync) GetRTT() time.Duration {
+	ts.mu.RLock()
+	defer ts.mu.RUnlock()
+	return ts.rtt
+}
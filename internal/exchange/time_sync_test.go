@@ -0,0 +1,91 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/exchange/fakebinance"
+)
+
+const fakeClockSkew = 10 * time.Second
+
+// staleTimeSync 模拟偏差尚未被发现的时间同步器：偏移为0且刚同步过，不会触发后台同步
+func staleTimeSync(fake *fakebinance.Server) *TimeSync {
+	ts := NewTimeSync(fake.URL())
+	ts.lastSync = time.Now()
+	return ts
+}
+
+func assertSkewOffset(t *testing.T, ts *TimeSync) {
+	t.Helper()
+	if d := ts.GetOffset() - fakeClockSkew.Milliseconds(); d < -500 || d > 500 {
+		t.Fatalf("offset %dms, want about %dms", ts.GetOffset(), fakeClockSkew.Milliseconds())
+	}
+}
+
+func TestTimeSyncSignsWithServerClock(t *testing.T) {
+	fake := fakebinance.New(fakebinance.Options{ClockOffset: fakeClockSkew})
+	defer fake.Close()
+
+	rest := &BinanceRESTClient{BaseURL: fake.URL(), APIKey: fake.APIKey(), Secret: fake.Secret(), HTTPClient: http.DefaultClient}
+	if _, err := rest.OpenOrders("BTCUSDT"); err == nil || !strings.Contains(err.Error(), "-1021") {
+		t.Fatalf("local clock 10s behind should be rejected with -1021, got %v", err)
+	}
+
+	rest.TimeSync = NewTimeSync(fake.URL())
+	if err := rest.TimeSync.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	assertSkewOffset(t, rest.TimeSync)
+	if rest.TimeSync.GetRTT() <= 0 {
+		t.Fatal("rtt should be measured")
+	}
+	if _, err := rest.OpenOrders("BTCUSDT"); err != nil {
+		t.Fatalf("synced request rejected: %v", err)
+	}
+}
+
+func TestTimestampRejectionResyncsAndRetriesOnce(t *testing.T) {
+	fake := fakebinance.New(fakebinance.Options{ClockOffset: fakeClockSkew})
+	defer fake.Close()
+
+	// REST：-1021 后立即同步并重试成功
+	ts := staleTimeSync(fake)
+	rest := &BinanceRESTClient{BaseURL: fake.URL(), APIKey: fake.APIKey(), Secret: fake.Secret(), HTTPClient: http.DefaultClient, TimeSync: ts}
+	if _, err := rest.OpenOrders("BTCUSDT"); err != nil {
+		t.Fatalf("request should succeed after resync: %v", err)
+	}
+	if n := fake.Calls("GET /fapi/v1/openOrders"); n != 2 {
+		t.Fatalf("expected one retry, got %d calls", n)
+	}
+	if n := fake.Calls("GET /fapi/v1/time"); n != 1 {
+		t.Fatalf("expected one resync, got %d", n)
+	}
+	assertSkewOffset(t, ts)
+
+	// WSS交易API：同样重新签名重试
+	ts = staleTimeSync(fake)
+	ws := NewTradeWSClient(TradeWSConfig{BaseURL: fake.TradeWSURL(), APIKey: fake.APIKey(), SecretKey: fake.Secret(), TimeSync: ts})
+	defer ws.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := ws.CancelAll(ctx, TradeCancelAllParams{Symbol: "BTCUSDT"}); err != nil {
+		t.Fatalf("ws request should succeed after resync: %v", err)
+	}
+	assertSkewOffset(t, ts)
+
+	// 服务器时间不可用、重新同步失败时不重试，返回原始 -1021
+	ts = staleTimeSync(fake)
+	ts.baseURL = "http://127.0.0.1:1"
+	rest.TimeSync = ts
+	before := fake.Calls("GET /fapi/v1/openOrders")
+	if _, err := rest.OpenOrders("BTCUSDT"); err == nil || !strings.Contains(err.Error(), "-1021") {
+		t.Fatalf("expected -1021 when resync fails, got %v", err)
+	}
+	if n := fake.Calls("GET /fapi/v1/openOrders") - before; n != 1 {
+		t.Fatalf("failed resync should not retry, got %d calls", n)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -18,6 +19,7 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/newplayman/market-maker-phoenix/internal/metrics"
 )
 
 // WSNotificationSink 用于把 WSS 推送事件透传到上层，例如 ORDER_TRADE_UPDATE。
@@ -39,6 +41,7 @@ type TradeWSConfig struct {
 	KeepAlive    time.Duration
 	RetryBackoff time.Duration
 	MaxRetries   int
+	TimeSync     *TimeSync // 签名时间戳来源，为空时使用本地时间
 }
 
 // TradeWSClient 实现 Binance WebSocket API 的基础下单能力。
@@ -123,6 +126,15 @@ type TradeCancelParams struct {
 	ClientOrderID string
 }
 
+// TradeModifyParams 描述改单参数（按原ClientOrderID修改价格与数量）。
+type TradeModifyParams struct {
+	Symbol        string
+	Side          string
+	ClientOrderID string
+	Quantity      float64
+	Price         float64
+}
+
 // TradeCancelAllParams 描述批量撤单请求。
 type TradeCancelAllParams struct {
 	Symbol string
@@ -200,6 +212,14 @@ func (c *TradeWSClient) Healthy() bool {
 	return c.conn != nil
 }
 
+// Connect 建立连接并登录；已连接时直接返回。
+func (c *TradeWSClient) Connect() error {
+	if atomic.LoadInt32(&c.started) == 0 {
+		c.Start(context.Background())
+	}
+	return c.ensureConnection()
+}
+
 // PlaceOrder 通过 WSS 下 LIMIT/MARKET 单。
 func (c *TradeWSClient) PlaceOrder(ctx context.Context, p TradeOrderParams) (json.RawMessage, error) {
 	params := make(map[string]interface{})
@@ -253,6 +273,18 @@ func (c *TradeWSClient) CancelOrder(ctx context.Context, p TradeCancelParams) (j
 	return c.call(ctx, "order.cancel", params)
 }
 
+// ModifyOrder 通过 WSS 修改限价单。
+func (c *TradeWSClient) ModifyOrder(ctx context.Context, p TradeModifyParams) (json.RawMessage, error) {
+	params := map[string]interface{}{
+		"symbol":            strings.ToUpper(p.Symbol),
+		"side":              strings.ToUpper(p.Side),
+		"origClientOrderId": p.ClientOrderID,
+		"quantity":          formatFloat(p.Quantity),
+		"price":             formatFloat(p.Price),
+	}
+	return c.call(ctx, "order.modify", params)
+}
+
 // CancelAll 通过 WSS 撤掉该合约所有挂单。
 func (c *TradeWSClient) CancelAll(ctx context.Context, p TradeCancelAllParams) (json.RawMessage, error) {
 	params := map[string]interface{}{
@@ -262,7 +294,26 @@ func (c *TradeWSClient) CancelAll(ctx context.Context, p TradeCancelAllParams) (
 }
 
 // call 是对外部请求的统一入口，负责发起请求并等待 ACK/ERR。
+// 遇到 -1021（时间戳超出recvWindow）时立即重新同步时间并重新签名重试一次。
 func (c *TradeWSClient) call(ctx context.Context, method string, params map[string]interface{}) (json.RawMessage, error) {
+	signedAt := time.Now()
+	result, err := c.roundTrip(ctx, method, params)
+	if !errors.Is(err, ErrTimestamp) {
+		return result, err
+	}
+	metrics.RecordTimestampRejection("ws")
+	if c.cfg.TimeSync == nil {
+		return result, err
+	}
+	if syncErr := c.cfg.TimeSync.resync(signedAt); syncErr != nil {
+		log.Printf("trade ws timestamp rejected, resync failed: %v", syncErr)
+		return result, err
+	}
+	return c.roundTrip(ctx, method, params)
+}
+
+// roundTrip 签名并发送一次请求，等待 ACK/ERR。
+func (c *TradeWSClient) roundTrip(ctx context.Context, method string, params map[string]interface{}) (json.RawMessage, error) {
 	if atomic.LoadInt32(&c.started) == 0 {
 		c.Start(context.Background())
 	}
@@ -372,7 +423,7 @@ func (c *TradeWSClient) ensureConnection() error {
 }
 
 func (c *TradeWSClient) login(conn *websocket.Conn) error {
-	timestamp := time.Now().UnixMilli()
+	timestamp := c.timestamp()
 	// 生成签名: 按参数名排序后拼接 apiKey={key}&timestamp={ts}
 	query := url.Values{}
 	query.Set("apiKey", c.cfg.APIKey)
@@ -402,7 +453,9 @@ func (c *TradeWSClient) login(conn *websocket.Conn) error {
 		return fmt.Errorf("ws login ack parse: %w", err)
 	}
 	if resp.Status != 200 && resp.Error != nil {
-		return fmt.Errorf("ws login failed: %d %s", resp.Error.Code, resp.Error.Msg)
+		// session.logon 仅支持 Ed25519 密钥；HMAC 密钥登录会被拒绝，
+		// 此时连接仍可用，每个请求自带 apiKey 与签名即可
+		log.Printf("trade ws logon rejected, falling back to per-request signing: %d %s", resp.Error.Code, resp.Error.Msg)
 	}
 	return nil
 }
@@ -458,12 +511,13 @@ func (c *TradeWSClient) handleResponse(resp wsResponse) {
 		return
 	}
 	req.expireTimer.Stop()
+	// 交易所明确答复的错误使用 ExchangeError，调用方据此区分拒单与超时/断线
 	if resp.Error != nil {
-		req.respCh <- tradeResponse{nil, fmt.Errorf("ws err %d: %s", resp.Error.Code, resp.Error.Msg)}
+		req.respCh <- tradeResponse{nil, &ExchangeError{Code: resp.Error.Code, Message: resp.Error.Msg, Status: resp.Status, Endpoint: req.meta.Method}}
 		return
 	}
 	if resp.Status != 200 && resp.Status != 0 {
-		req.respCh <- tradeResponse{nil, fmt.Errorf("ws status %d", resp.Status)}
+		req.respCh <- tradeResponse{nil, &ExchangeError{Message: fmt.Sprintf("ws status %d", resp.Status), Status: resp.Status, Endpoint: req.meta.Method}}
 		return
 	}
 	req.respCh <- tradeResponse{resp.Result, nil}
@@ -509,8 +563,11 @@ func (c *TradeWSClient) attachSignature(params map[string]interface{}) map[strin
 	if params == nil {
 		params = make(map[string]interface{})
 	}
-	now := time.Now().UnixMilli()
-	params["timestamp"] = now
+	if c.cfg.APIKey != "" {
+		params["apiKey"] = c.cfg.APIKey
+	}
+	delete(params, "signature")
+	params["timestamp"] = c.timestamp()
 	query := url.Values{}
 	for k, v := range params {
 		switch vv := v.(type) {
@@ -530,6 +587,14 @@ func (c *TradeWSClient) attachSignature(params map[string]interface{}) map[strin
 	return params
 }
 
+// timestamp 签名时间戳：配置了 TimeSync 时取服务器时间
+func (c *TradeWSClient) timestamp() int64 {
+	if c.cfg.TimeSync != nil {
+		return c.cfg.TimeSync.GetServerTime()
+	}
+	return time.Now().UnixMilli()
+}
+
 func (c *TradeWSClient) sign(payload string) string {
 	mac := hmac.New(sha256.New, []byte(c.cfg.SecretKey))
 	_, _ = mac.Write([]byte(payload))
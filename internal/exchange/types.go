@@ -2,7 +2,10 @@ package gateway
 
 import (
 	"context"
+	"fmt"
 	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/filters"
 )
 
 // Order represents a trading order
@@ -13,10 +16,18 @@ type Order struct {
 	Type          string    `json:"type"` // "LIMIT", "MARKET"
 	Quantity      float64   `json:"quantity"`
 	Price         float64   `json:"price"`
-	ClientOrderID string    `json:"clientOrderId"` // phoenix-{symbol}-{timestamp}-{seq}
+	ClientOrderID string    `json:"clientOrderId"` // phoenix-{symbol}-{timestamp}{seq}，不超过36字符
 	Status        string    `json:"status"`        // "NEW", "FILLED", "CANCELED"
 	FilledQty     float64   `json:"filledQty"`
 	CreatedAt     time.Time `json:"createdAt"`
+
+	// 本次成交明细（仅成交推送时非零）
+	LastFilledQty   float64 `json:"lastFilledQty,omitempty"`
+	LastFilledPrice float64 `json:"lastFilledPrice,omitempty"`
+	RealizedPNL     float64 `json:"realizedPnl,omitempty"`     // 交易所计算的本次已实现盈亏
+	Commission      float64 `json:"commission,omitempty"`      // 本次手续费（负值为返佣）
+	CommissionAsset string  `json:"commissionAsset,omitempty"` // 手续费资产
+	IsMaker         bool    `json:"isMaker,omitempty"`         // 本次成交是否为maker
 }
 
 // Position represents a trading position
@@ -57,12 +68,31 @@ type PriceLevel struct {
 	Quantity float64 `json:"quantity"`
 }
 
+// Trade represents a public market trade (aggTrade)
+type Trade struct {
+	Symbol       string    `json:"symbol"`
+	TradeID      int64     `json:"tradeId"`
+	Price        float64   `json:"price"`
+	Quantity     float64   `json:"quantity"`
+	IsBuyerMaker bool      `json:"isBuyerMaker"` // true: 主动方为卖方
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// TakerSide 返回主动方方向 "BUY" 或 "SELL"
+func (t *Trade) TakerSide() string {
+	if t.IsBuyerMaker {
+		return "SELL"
+	}
+	return "BUY"
+}
+
 // FundingRate represents funding rate information
 type FundingRate struct {
 	Symbol          string    `json:"symbol"`
 	Rate            float64   `json:"rate"`
 	NextFundingTime time.Time `json:"nextFundingTime"`
 	Timestamp       time.Time `json:"timestamp"`
+	Payment         float64   `json:"payment,omitempty"` // 本次结算的资金费（正为收入），仅结算推送时非零
 }
 
 // Exchange interface defines the contract for exchange operations
@@ -74,6 +104,14 @@ type Exchange interface {
 	CancelAllOrders(ctx context.Context, symbol string) error
 	GetOpenOrders(ctx context.Context, symbol string) ([]*Order, error)
 
+	// Batch order management: 结果与输入按下标一一对应，单个订单失败不影响其他订单
+	PlaceOrders(ctx context.Context, orders []*Order) []OrderResult
+	CancelOrders(ctx context.Context, symbol string, clientOrderIDs []string) []error
+
+	// Order amend: 按ClientOrderID修改挂单价格/数量，不撤单重挂
+	AmendOrder(ctx context.Context, order *Order) (*Order, error)
+	AmendOrders(ctx context.Context, orders []*Order) []OrderResult
+
 	// Position management
 	GetPosition(ctx context.Context, symbol string) (*Position, error)
 	GetAllPositions(ctx context.Context) ([]*Position, error)
@@ -85,6 +123,7 @@ type Exchange interface {
 
 	// WebSocket streams
 	StartDepthStream(ctx context.Context, symbols []string, callback func(*Depth)) error
+	StartTradeStream(ctx context.Context, symbols []string, onTrade func(*Trade)) error
 	StartUserStream(ctx context.Context, callbacks *UserStreamCallbacks) error
 
 	// Connection management
@@ -93,6 +132,33 @@ type Exchange interface {
 	IsConnected() bool
 }
 
+// MarketDataSupervisor 可选能力：强制重连交易对所在的行情连接（行情过期看门狗使用）
+type MarketDataSupervisor interface {
+	ReconnectMarketData(symbol string) error
+}
+
+// SymbolFilterSource 可选能力：从交易所读取交易规则，key为内部交易对
+type SymbolFilterSource interface {
+	SymbolFilters(ctx context.Context, symbols []string) (map[string]filters.Symbol, error)
+}
+
+// TakerOrderPlacer 可选能力：下IOC限价单主动成交（对冲使用），未成交部分由交易所立即撤销。
+// 成交结果以订单推送或仓位为准，返回的订单不进入本地挂单缓存
+type TakerOrderPlacer interface {
+	PlaceIOC(ctx context.Context, order *Order) (*Order, error)
+}
+
+// PositionSyncer 可选能力：通过REST加载仓位到本地缓存（GetPosition 仅读取推送维护的缓存）
+type PositionSyncer interface {
+	SyncPositions(ctx context.Context) error
+}
+
+// OrderResult 批量下单中单个订单的结果
+type OrderResult struct {
+	Order *Order
+	Err   error
+}
+
 // UserStreamCallbacks defines callbacks for user stream events
 // 文档规范: WSS callbacks
 type UserStreamCallbacks struct {
@@ -102,13 +168,23 @@ type UserStreamCallbacks struct {
 }
 
 // ExchangeError represents an exchange-specific error
+// 交易所明确答复的错误：Code 为交易所错误码（Bybit 映射为对应的 Binance 错误码），
+// 按错误码分类后可用 errors.Is 匹配 ErrPostOnlyReject 等哨兵错误
 type ExchangeError struct {
-	Code    int    `json:"code"`
-	Message string `json:"msg"`
+	Code     int    `json:"code"`
+	Message  string `json:"msg"`
+	Status   int    `json:"-"` // HTTP状态码（WS API 为响应中的 status）
+	Endpoint string `json:"-"` // 请求端点，如 "POST /fapi/v1/order" 或 "order.place"
 }
 
 func (e *ExchangeError) Error() string {
-	return e.Message
+	if e.Endpoint == "" {
+		return e.Message
+	}
+	if e.Code == 0 {
+		return fmt.Sprintf("%s status %d: %s", e.Endpoint, e.Status, e.Message)
+	}
+	return fmt.Sprintf("%s status %d: code %d: %s", e.Endpoint, e.Status, e.Code, e.Message)
 }
 
 // Common errors
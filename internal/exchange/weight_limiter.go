@@ -0,0 +1,293 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultWeightPerMinute = 2400 // U本位合约IP权重上限（每分钟）
+	defaultOrdersPer10s    = 300  // 账户下单频率上限（每10秒）
+	defaultOrdersPerMinute = 1200 // 账户下单频率上限（每分钟）
+	defaultCancelReserve   = 0.1  // 为撤单预留的额度比例
+	defaultTeapotBackoff   = 2 * time.Minute
+	cancelPriorityPoll     = 10 * time.Millisecond
+)
+
+// RequestCost 单次REST请求的限流开销
+type RequestCost struct {
+	Weight int  // IP请求权重
+	Orders int  // 计入下单频率的订单数（撤单不计）
+	Cancel bool // 撤单请求：可使用预留额度，并优先于下单放行
+}
+
+// WeightedRateLimiter 按请求开销限速，并根据交易所响应头与限流状态码校准
+type WeightedRateLimiter interface {
+	RateLimiter
+	Acquire(cost RequestCost)
+	Observe(status int, header http.Header)
+}
+
+// WeightLimiterConfig 权重限速器配置，零值使用交易所默认上限
+type WeightLimiterConfig struct {
+	WeightPerMinute int
+	OrdersPer10s    int
+	OrdersPerMinute int
+	CancelReserve   float64 // 下单/查询只能使用 (1-CancelReserve) 的额度
+}
+
+// WeightLimiter 按端点权重与下单数分别计数的限速器。
+// 计数窗口与交易所一致按整分钟/整10秒对齐，响应头 X-MBX-USED-WEIGHT-1M、X-MBX-ORDER-COUNT-10S/1M
+// 高于本地计数时以交易所为准（同IP的其他进程也会消耗额度）；收到429/418时按Retry-After暂停全部请求。
+// 撤单可使用预留额度，且有撤单排队时新的下单与查询让行
+type WeightLimiter struct {
+	cfg WeightLimiterConfig
+
+	mu             sync.Mutex
+	minute         int64 // 当前1分钟窗口编号
+	tenSec         int64 // 当前10秒窗口编号
+	weight         int
+	orders10s      int
+	orders1m       int
+	blockedUntil   time.Time
+	pendingCancels int
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// NewWeightLimiter 创建权重限速器
+func NewWeightLimiter(cfg WeightLimiterConfig) *WeightLimiter {
+	if cfg.WeightPerMinute <= 0 {
+		cfg.WeightPerMinute = defaultWeightPerMinute
+	}
+	if cfg.OrdersPer10s <= 0 {
+		cfg.OrdersPer10s = defaultOrdersPer10s
+	}
+	if cfg.OrdersPerMinute <= 0 {
+		cfg.OrdersPerMinute = defaultOrdersPerMinute
+	}
+	if cfg.CancelReserve <= 0 || cfg.CancelReserve >= 1 {
+		cfg.CancelReserve = defaultCancelReserve
+	}
+	return &WeightLimiter{cfg: cfg, now: time.Now, sleep: time.Sleep}
+}
+
+// Wait 按权重1放行，兼容 RateLimiter
+func (l *WeightLimiter) Wait() {
+	l.Acquire(RequestCost{Weight: 1})
+}
+
+// Acquire 阻塞直到额度足够，并预占本次请求的权重与下单数
+func (l *WeightLimiter) Acquire(cost RequestCost) {
+	if cost.Weight <= 0 {
+		cost.Weight = 1
+	}
+	if cost.Cancel {
+		l.mu.Lock()
+		l.pendingCancels++
+		l.mu.Unlock()
+		defer func() {
+			l.mu.Lock()
+			l.pendingCancels--
+			l.mu.Unlock()
+		}()
+	}
+	for {
+		l.mu.Lock()
+		wait := l.reserveLocked(cost)
+		l.mu.Unlock()
+		if wait <= 0 {
+			return
+		}
+		l.sleep(wait)
+	}
+}
+
+// reserveLocked 额度足够时预占并返回0，否则返回需要等待的时长
+func (l *WeightLimiter) reserveLocked(cost RequestCost) time.Duration {
+	now := l.now()
+	l.rollLocked(now)
+	if now.Before(l.blockedUntil) {
+		return l.blockedUntil.Sub(now)
+	}
+	if !cost.Cancel && l.pendingCancels > 0 {
+		return cancelPriorityPoll
+	}
+
+	share := 1.0
+	if !cost.Cancel {
+		share = 1 - l.cfg.CancelReserve
+	}
+	// 单次开销超过整窗额度时，空窗口也放行，避免永久阻塞
+	if l.weight > 0 && float64(l.weight+cost.Weight) > share*float64(l.cfg.WeightPerMinute) {
+		return untilNextWindow(now, time.Minute)
+	}
+	if cost.Orders > 0 {
+		if l.orders1m > 0 && float64(l.orders1m+cost.Orders) > share*float64(l.cfg.OrdersPerMinute) {
+			return untilNextWindow(now, time.Minute)
+		}
+		if l.orders10s > 0 && float64(l.orders10s+cost.Orders) > share*float64(l.cfg.OrdersPer10s) {
+			return untilNextWindow(now, 10*time.Second)
+		}
+	}
+	l.weight += cost.Weight
+	l.orders10s += cost.Orders
+	l.orders1m += cost.Orders
+	return 0
+}
+
+func (l *WeightLimiter) rollLocked(now time.Time) {
+	if minute := now.Unix() / 60; minute != l.minute {
+		l.minute, l.weight, l.orders1m = minute, 0, 0
+	}
+	if tenSec := now.Unix() / 10; tenSec != l.tenSec {
+		l.tenSec, l.orders10s = tenSec, 0
+	}
+}
+
+func untilNextWindow(now time.Time, window time.Duration) time.Duration {
+	return now.Truncate(window).Add(window).Sub(now)
+}
+
+// Observe 根据响应校准计数：采用交易所回报的已用额度（仅向上修正），429/418时暂停
+func (l *WeightLimiter) Observe(status int, header http.Header) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.rollLocked(now)
+	if v, ok := headerInt(header, "X-MBX-USED-WEIGHT-1M"); ok && v > l.weight {
+		l.weight = v
+	}
+	if v, ok := headerInt(header, "X-MBX-ORDER-COUNT-10S"); ok && v > l.orders10s {
+		l.orders10s = v
+	}
+	if v, ok := headerInt(header, "X-MBX-ORDER-COUNT-1M"); ok && v > l.orders1m {
+		l.orders1m = v
+	}
+
+	if status != http.StatusTooManyRequests && status != 418 {
+		return
+	}
+	backoff := parseRetryAfter(header)
+	if backoff <= 0 {
+		backoff = untilNextWindow(now, time.Minute)
+		if status == 418 {
+			backoff = defaultTeapotBackoff
+		}
+	}
+	if until := now.Add(backoff); until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+	log.Warn().
+		Int("status", status).
+		Dur("backoff", backoff).
+		Int("used_weight", l.weight).
+		Msg("触发交易所限流，暂停REST请求")
+}
+
+// Usage 当前窗口已用权重与下单数
+func (l *WeightLimiter) Usage() (weight, orders10s, orders1m int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rollLocked(l.now())
+	return l.weight, l.orders10s, l.orders1m
+}
+
+func headerInt(header http.Header, key string) (int, bool) {
+	raw := header.Get(key)
+	if raw == "" {
+		return 0, false
+	}
+	v, err := strconv.Atoi(raw)
+	return v, err == nil
+}
+
+// parseRetryAfter 解析 Retry-After（秒）
+func parseRetryAfter(header http.Header) time.Duration {
+	secs, ok := headerInt(header, "Retry-After")
+	if !ok || secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs) * time.Second
+}
+
+// BinanceRequestCost 按U本位合约REST端点文档给出请求权重与下单计数
+func BinanceRequestCost(method, endpoint string) RequestCost {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return RequestCost{Weight: 1}
+	}
+	q := u.Query()
+	hasSymbol := q.Get("symbol") != ""
+	path := u.Path
+	switch {
+	case path == "/fapi/v1/order":
+		switch method {
+		case http.MethodDelete:
+			return RequestCost{Weight: 1, Cancel: true}
+		case http.MethodPost, http.MethodPut:
+			return RequestCost{Weight: 1, Orders: 1}
+		}
+		return RequestCost{Weight: 1}
+	case path == "/fapi/v1/batchOrders":
+		if method == http.MethodDelete {
+			return RequestCost{Weight: 1, Cancel: true}
+		}
+		return RequestCost{Weight: 5, Orders: batchOrderCount(q.Get("batchOrders"))}
+	case path == "/fapi/v1/allOpenOrders":
+		return RequestCost{Weight: 1, Cancel: true}
+	case path == "/fapi/v1/openOrders":
+		if hasSymbol {
+			return RequestCost{Weight: 1}
+		}
+		return RequestCost{Weight: 40}
+	case path == "/fapi/v1/depth":
+		return RequestCost{Weight: depthRequestWeight(q.Get("limit"))}
+	case path == "/fapi/v2/account", path == "/fapi/v2/balance", path == "/fapi/v2/positionRisk":
+		return RequestCost{Weight: 5}
+	case path == "/fapi/v1/premiumIndex":
+		if hasSymbol {
+			return RequestCost{Weight: 1}
+		}
+		return RequestCost{Weight: 10}
+	case path == "/fapi/v1/leverageBracket":
+		if hasSymbol {
+			return RequestCost{Weight: 1}
+		}
+		return RequestCost{Weight: 40}
+	case path == "/fapi/v1/positionSide/dual" && method == http.MethodGet:
+		return RequestCost{Weight: 30}
+	}
+	return RequestCost{Weight: 1}
+}
+
+func depthRequestWeight(limit string) int {
+	n, _ := strconv.Atoi(limit)
+	switch {
+	case n <= 0:
+		return 10 // 未指定时交易所默认500档
+	case n <= 50:
+		return 2
+	case n <= 100:
+		return 5
+	case n <= 500:
+		return 10
+	default:
+		return 20
+	}
+}
+
+func batchOrderCount(raw string) int {
+	var items []json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &items); err != nil || len(items) == 0 {
+		return 1
+	}
+	return len(items)
+}
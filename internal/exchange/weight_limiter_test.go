@@ -0,0 +1,116 @@
+package gateway
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/exchange/fakebinance"
+)
+
+// newTestWeightLimiter 使用可控时钟：sleep直接推进时钟并累计等待时长
+func newTestWeightLimiter(cfg WeightLimiterConfig) (*WeightLimiter, *time.Duration) {
+	l := NewWeightLimiter(cfg)
+	clock := time.Date(2026, 1, 1, 0, 0, 5, 0, time.UTC)
+	var slept time.Duration
+	l.now = func() time.Time { return clock }
+	l.sleep = func(d time.Duration) {
+		slept += d
+		clock = clock.Add(d)
+	}
+	return l, &slept
+}
+
+func TestBinanceRequestCost(t *testing.T) {
+	cases := []struct {
+		method, endpoint string
+		want             RequestCost
+	}{
+		{http.MethodGet, "https://x/fapi/v1/openOrders?timestamp=1", RequestCost{Weight: 40}},
+		{http.MethodGet, "https://x/fapi/v1/openOrders?symbol=BTCUSDT", RequestCost{Weight: 1}},
+		{http.MethodGet, "https://x/fapi/v1/depth?symbol=BTCUSDT&limit=1000", RequestCost{Weight: 20}},
+		{http.MethodPost, "https://x/fapi/v1/order?symbol=BTCUSDT", RequestCost{Weight: 1, Orders: 1}},
+		{http.MethodDelete, "https://x/fapi/v1/order?symbol=BTCUSDT", RequestCost{Weight: 1, Cancel: true}},
+		{http.MethodPost, "https://x/fapi/v1/batchOrders?batchOrders=%5B%7B%7D%2C%7B%7D%2C%7B%7D%5D", RequestCost{Weight: 5, Orders: 3}},
+		{http.MethodDelete, "https://x/fapi/v1/allOpenOrders?symbol=BTCUSDT", RequestCost{Weight: 1, Cancel: true}},
+		{http.MethodGet, "https://x/fapi/v2/account", RequestCost{Weight: 5}},
+	}
+	for _, tc := range cases {
+		if got := BinanceRequestCost(tc.method, tc.endpoint); got != tc.want {
+			t.Errorf("%s %s: got %+v, want %+v", tc.method, tc.endpoint, got, tc.want)
+		}
+	}
+}
+
+func TestWeightLimiterBudgetsAndCancelReserve(t *testing.T) {
+	l, slept := newTestWeightLimiter(WeightLimiterConfig{WeightPerMinute: 100, OrdersPer10s: 10, CancelReserve: 0.1})
+
+	// 交易所回报的已用权重高于本地计数时以回报为准
+	l.Observe(http.StatusOK, http.Header{"X-Mbx-Used-Weight-1m": {"85"}})
+	l.Acquire(RequestCost{Weight: 5})
+	if *slept != 0 {
+		t.Fatalf("request within budget should not wait, slept %s", *slept)
+	}
+
+	// 下单/查询只能用到90%，撤单可以使用预留额度
+	l.Acquire(RequestCost{Weight: 5, Cancel: true})
+	if w, _, _ := l.Usage(); w != 95 || *slept != 0 {
+		t.Fatalf("cancel should use the reserve: weight=%d slept=%s", w, *slept)
+	}
+	l.Acquire(RequestCost{Weight: 1})
+	if *slept != 55*time.Second {
+		t.Fatalf("query over budget should wait for the next minute, slept %s", *slept)
+	}
+
+	// 下单数按10秒窗口单独计数
+	*slept = 0
+	l.Observe(http.StatusOK, http.Header{"X-Mbx-Order-Count-10s": {"9"}})
+	l.Acquire(RequestCost{Weight: 1, Orders: 1})
+	if *slept != 10*time.Second {
+		t.Fatalf("order over 10s budget should wait for the next window, slept %s", *slept)
+	}
+
+	// 有撤单排队时下单让行
+	l.mu.Lock()
+	l.pendingCancels = 1
+	wait := l.reserveLocked(RequestCost{Weight: 1, Orders: 1})
+	l.pendingCancels = 0
+	l.mu.Unlock()
+	if wait != cancelPriorityPoll {
+		t.Fatalf("order should yield to pending cancels, wait=%s", wait)
+	}
+}
+
+func TestWeightLimiterBackoffOnRateLimit(t *testing.T) {
+	l, slept := newTestWeightLimiter(WeightLimiterConfig{})
+	l.Observe(http.StatusTooManyRequests, http.Header{"Retry-After": {"3"}})
+	l.Acquire(RequestCost{Weight: 1, Cancel: true})
+	if *slept != 3*time.Second {
+		t.Fatalf("429 should pause all requests for Retry-After, slept %s", *slept)
+	}
+
+	*slept = 0
+	l.Observe(418, http.Header{})
+	l.Acquire(RequestCost{Weight: 1})
+	if *slept != defaultTeapotBackoff {
+		t.Fatalf("418 without Retry-After should back off %s, slept %s", defaultTeapotBackoff, *slept)
+	}
+}
+
+func TestWeightLimiterTracksExchangeHeaders(t *testing.T) {
+	fake := fakebinance.New(fakebinance.Options{})
+	defer fake.Close()
+
+	limiter := NewWeightLimiter(WeightLimiterConfig{})
+	cli := &BinanceRESTClient{BaseURL: fake.URL(), APIKey: fake.APIKey(), Secret: fake.Secret(), HTTPClient: http.DefaultClient, Limiter: limiter}
+	if _, err := cli.OpenOrders(""); err != nil {
+		t.Fatalf("open orders: %v", err)
+	}
+	if _, err := cli.PlaceLimit("BTCUSDT", "BUY", "GTC", 100, 1, false, true, "cid-1"); err != nil {
+		t.Fatalf("place: %v", err)
+	}
+	weight, orders10s, _ := limiter.Usage()
+	if weight < 41 || orders10s < 1 {
+		t.Fatalf("usage should follow endpoint weights and headers: weight=%d orders=%d", weight, orders10s)
+	}
+}
@@ -1,23 +1,40 @@
 package gateway
 
 import (
-	"log"
+	"errors"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/newplayman/market-maker-phoenix/internal/metrics"
+	"github.com/rs/zerolog/log"
 )
 
+// 断线重连原因（用作指标标签）
+const (
+	ReconnectReasonError     = "error"     // 读错误/服务端断开
+	ReconnectReasonHeartbeat = "heartbeat" // 心跳发送失败
+	ReconnectReasonStale     = "stale"     // 行情过期，由上层看门狗触发
+	ReconnectReasonLifetime  = "lifetime"  // 到达连接最长存活时间（交易所24小时强制断开）
+	ReconnectReasonManual    = "manual"
+)
+
+// errForcedReconnect 主动关闭连接以触发重连
+var errForcedReconnect = errors.New("forced reconnect")
+
 // WSReconnectConfig WebSocket 重连配置
 type WSReconnectConfig struct {
-	MaxRetries      int           // 最大重试次数（0=无限）
-	InitialDelay    time.Duration // 初始重连延迟
-	MaxDelay        time.Duration // 最大重连延迟
-	BackoffFactor   float64       // 退避系数
-	PingInterval    time.Duration // 心跳间隔
-	PongWait        time.Duration // Pong 等待时间
-	WriteWait       time.Duration // 写超时
-	EnableHeartbeat bool          // 启用心跳
+	Name            string            // 连接名称，用作日志与指标标签
+	Dialer          *websocket.Dialer // 为空时使用默认Dialer（10秒握手超时）
+	MaxRetries      int               // 最大重试次数（0=无限）
+	InitialDelay    time.Duration     // 初始重连延迟
+	MaxDelay        time.Duration     // 最大重连延迟
+	BackoffFactor   float64           // 退避系数
+	PingInterval    time.Duration     // 心跳间隔
+	PongWait        time.Duration     // Pong 等待时间
+	WriteWait       time.Duration     // 写超时
+	EnableHeartbeat bool              // 启用心跳
+	MaxConnLifetime time.Duration     // 连接最长存活时间，到期主动重连（0=不限制）
 }
 
 // DefaultWSReconnectConfig 默认配置
@@ -31,21 +48,25 @@ func DefaultWSReconnectConfig() WSReconnectConfig {
 		PongWait:        30 * time.Second,
 		WriteWait:       10 * time.Second,
 		EnableHeartbeat: true,
+		MaxConnLifetime: 23 * time.Hour, // 早于交易所24小时强制断开
 	}
 }
 
-// WSReconnectManager WebSocket 重连管理器
+// WSReconnectManager WebSocket 重连管理器：断线后按指数退避重连，
+// ForceReconnect 关闭当前连接并立即重连，到达 MaxConnLifetime 时主动换新连接
 type WSReconnectManager struct {
 	mu sync.RWMutex
 
 	config        WSReconnectConfig
 	conn          *websocket.Conn
 	url           string
+	started       bool
 	connected     bool
-	reconnecting  bool
+	stopOnce      sync.Once
 	stopChan      chan struct{}
 	doneChan      chan struct{}
 	reconnectChan chan struct{}
+	writeMu       sync.Mutex
 
 	// 回调
 	onConnect    func(*websocket.Conn)
@@ -56,6 +77,8 @@ type WSReconnectManager struct {
 	// 统计
 	totalReconnects int
 	lastConnectTime time.Time
+	pendingReason   string // ForceReconnect 指定的本次断开原因
+	lastReason      string
 }
 
 // NewWSReconnectManager 创建重连管理器
@@ -87,36 +110,67 @@ func (m *WSReconnectManager) SetCallbacks(
 // Start 启动连接
 func (m *WSReconnectManager) Start() error {
 	m.mu.Lock()
-	if m.connected || m.reconnecting {
+	if m.started {
 		m.mu.Unlock()
 		return nil
 	}
+	m.started = true
 	m.mu.Unlock()
 
 	go m.run()
 	return nil
 }
 
-// Stop 停止连接
+// Stop 停止连接并等待后台协程退出
 func (m *WSReconnectManager) Stop() error {
-	m.mu.Lock()
-	if !m.connected && !m.reconnecting {
-		m.mu.Unlock()
+	m.mu.RLock()
+	started := m.started
+	m.mu.RUnlock()
+	if !started {
 		return nil
 	}
-	m.mu.Unlock()
 
-	close(m.stopChan)
+	m.stopOnce.Do(func() {
+		close(m.stopChan)
+		m.closeConn()
+	})
 	<-m.doneChan
 	return nil
 }
 
 // TriggerReconnect 触发手动重连
 func (m *WSReconnectManager) TriggerReconnect() {
+	m.ForceReconnect(ReconnectReasonManual)
+}
+
+// ForceReconnect 关闭当前连接并跳过退避立即重连
+func (m *WSReconnectManager) ForceReconnect(reason string) {
+	m.mu.Lock()
+	if m.pendingReason == "" {
+		m.pendingReason = reason
+	}
+	conn := m.conn
+	m.mu.Unlock()
+
 	select {
 	case m.reconnectChan <- struct{}{}:
 	default:
 	}
+	if conn != nil {
+		_ = conn.Close()
+	}
+}
+
+// Send 以JSON写入一条消息（与心跳并发安全）
+func (m *WSReconnectManager) Send(v interface{}) error {
+	conn := m.getConn()
+	if conn == nil {
+		return ErrNotConnected
+	}
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	_ = conn.SetWriteDeadline(time.Now().Add(m.config.WriteWait))
+	return conn.WriteJSON(v)
 }
 
 // IsConnected 是否已连接
@@ -131,22 +185,25 @@ func (m *WSReconnectManager) GetStats() WSStats {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 	return WSStats{
-		Connected:       m.connected,
-		TotalReconnects: m.totalReconnects,
-		LastConnectTime: m.lastConnectTime,
+		Connected:        m.connected,
+		TotalReconnects:  m.totalReconnects,
+		LastConnectTime:  m.lastConnectTime,
+		LastDisconnectBy: m.lastReason,
 	}
 }
 
 // WSStats WebSocket 统计
 type WSStats struct {
-	Connected       bool
-	TotalReconnects int
-	LastConnectTime time.Time
+	Connected        bool
+	TotalReconnects  int
+	LastConnectTime  time.Time
+	LastDisconnectBy string // 最近一次断开原因
 }
 
 // run 主循环
 func (m *WSReconnectManager) run() {
 	defer close(m.doneChan)
+	defer metrics.SetWSConnected(m.config.Name, false)
 
 	delay := m.config.InitialDelay
 	retries := 0
@@ -154,14 +211,14 @@ func (m *WSReconnectManager) run() {
 	for {
 		// 尝试连接
 		if err := m.connect(); err != nil {
-			log.Printf("WS connect failed: %v", err)
+			log.Warn().Err(err).Str("conn", m.config.Name).Dur("retry_in", delay).Msg("WS连接失败")
 			if m.onError != nil {
 				m.onError(err)
 			}
 
 			// 检查是否达到最大重试次数
 			if m.config.MaxRetries > 0 && retries >= m.config.MaxRetries {
-				log.Printf("WS max retries (%d) reached, giving up", m.config.MaxRetries)
+				log.Error().Str("conn", m.config.Name).Int("max_retries", m.config.MaxRetries).Msg("WS重连次数耗尽，放弃")
 				return
 			}
 
@@ -169,10 +226,11 @@ func (m *WSReconnectManager) run() {
 			select {
 			case <-m.stopChan:
 				return
+			case <-m.reconnectChan:
 			case <-time.After(delay):
 				delay = m.calculateNextDelay(delay)
-				continue
 			}
+			continue
 		}
 
 		// 连接成功，重置重试计数
@@ -183,17 +241,36 @@ func (m *WSReconnectManager) run() {
 		m.connected = true
 		m.lastConnectTime = time.Now()
 		m.mu.Unlock()
+		metrics.SetWSConnected(m.config.Name, true)
 
 		if m.onConnect != nil {
 			m.onConnect(m.conn)
 		}
 
-		// 读取消息
+		// 读取消息直到断开；心跳与存活期限只作用于本次连接
+		connDone := make(chan struct{})
+		if m.config.EnableHeartbeat {
+			go m.heartbeatLoop(m.getConn(), connDone)
+		}
+		var lifetime *time.Timer
+		if m.config.MaxConnLifetime > 0 {
+			lifetime = time.AfterFunc(m.config.MaxConnLifetime, func() {
+				m.ForceReconnect(ReconnectReasonLifetime)
+			})
+		}
 		err := m.readLoop()
+		close(connDone)
+		if lifetime != nil {
+			lifetime.Stop()
+		}
+		m.closeConn()
 
 		m.mu.Lock()
 		m.connected = false
+		reason := m.pendingReason
+		m.pendingReason = ""
 		m.mu.Unlock()
+		metrics.SetWSConnected(m.config.Name, false)
 
 		if m.onDisconnect != nil {
 			m.onDisconnect(err)
@@ -202,31 +279,43 @@ func (m *WSReconnectManager) run() {
 		// 检查是否主动停止
 		select {
 		case <-m.stopChan:
-			m.closeConn()
 			return
 		default:
 		}
 
-		// 等待重连
-		log.Printf("WS disconnected, reconnecting in %v...", delay)
+		if reason == "" {
+			reason = ReconnectReasonError
+		}
+		m.mu.Lock()
+		m.lastReason = reason
+		m.mu.Unlock()
+		metrics.RecordWSReconnect(m.config.Name, reason)
+
+		// 主动触发的重连立即执行，否则按退避等待
+		select {
+		case <-m.reconnectChan:
+			log.Info().Str("conn", m.config.Name).Str("reason", reason).Msg("WS主动重连")
+			continue
+		default:
+		}
+		log.Warn().Err(err).Str("conn", m.config.Name).Dur("retry_in", delay).Msg("WS断开，准备重连")
 		select {
 		case <-m.stopChan:
-			m.closeConn()
 			return
 		case <-m.reconnectChan:
 			// 立即重连
 		case <-time.After(delay):
 		}
-
-		m.closeConn()
 		delay = m.calculateNextDelay(delay)
 	}
 }
 
 // connect 建立连接
 func (m *WSReconnectManager) connect() error {
-	dialer := websocket.DefaultDialer
-	dialer.HandshakeTimeout = 10 * time.Second
+	dialer := m.config.Dialer
+	if dialer == nil {
+		dialer = &websocket.Dialer{Proxy: websocket.DefaultDialer.Proxy, HandshakeTimeout: 10 * time.Second}
+	}
 
 	conn, _, err := dialer.Dial(m.url, nil)
 	if err != nil {
@@ -244,11 +333,12 @@ func (m *WSReconnectManager) connect() error {
 		return nil
 	})
 
-	// 启动心跳
-	if m.config.EnableHeartbeat {
-		go m.heartbeatLoop()
+	// 停止与连接建立并发时，确保新连接也被关闭
+	select {
+	case <-m.stopChan:
+		m.closeConn()
+	default:
 	}
-
 	return nil
 }
 
@@ -256,7 +346,7 @@ func (m *WSReconnectManager) connect() error {
 func (m *WSReconnectManager) readLoop() error {
 	conn := m.getConn()
 	if conn == nil {
-		return nil
+		return errForcedReconnect
 	}
 
 	// 设置初始读超时
@@ -278,8 +368,11 @@ func (m *WSReconnectManager) readLoop() error {
 	}
 }
 
-// heartbeatLoop 心跳循环
-func (m *WSReconnectManager) heartbeatLoop() {
+// heartbeatLoop 心跳循环，连接断开（done关闭）时退出
+func (m *WSReconnectManager) heartbeatLoop(conn *websocket.Conn, done <-chan struct{}) {
+	if conn == nil {
+		return
+	}
 	ticker := time.NewTicker(m.config.PingInterval)
 	defer ticker.Stop()
 
@@ -287,16 +380,12 @@ func (m *WSReconnectManager) heartbeatLoop() {
 		select {
 		case <-m.stopChan:
 			return
+		case <-done:
+			return
 		case <-ticker.C:
-			conn := m.getConn()
-			if conn == nil {
-				return
-			}
-
-			_ = conn.SetWriteDeadline(time.Now().Add(m.config.WriteWait))
-			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-				log.Printf("WS heartbeat failed: %v", err)
-				m.TriggerReconnect()
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(m.config.WriteWait)); err != nil {
+				log.Warn().Err(err).Str("conn", m.config.Name).Msg("WS心跳失败")
+				m.ForceReconnect(ReconnectReasonHeartbeat)
 				return
 			}
 		}
@@ -0,0 +1,137 @@
+// Package filters 交易所交易规则（价格/数量步长、最小下单量与最小名义价值）。
+// Runner在报价转换为订单前统一经过此处取整或拒绝，避免订单被交易所以 -1013/-4164 等错误拒绝
+package filters
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 报价被拒绝的原因（同时用作指标标签）
+const (
+	ReasonInvalid     = "invalid"      // 价格或数量非正
+	ReasonPriceRange  = "price_range"  // 超出 minPrice/maxPrice
+	ReasonMinQty      = "min_qty"      // 取整后低于最小下单量
+	ReasonMaxQty      = "max_qty"      // 超过最大下单量
+	ReasonMinNotional = "min_notional" // 名义价值低于下限
+)
+
+// eps 抵消浮点误差（如 0.3/0.1 = 2.9999999999999996）
+const eps = 1e-9
+
+// Symbol 单个交易对的交易规则，字段为0表示交易所未限制
+type Symbol struct {
+	Symbol      string
+	TickSize    float64
+	StepSize    float64
+	MinQty      float64
+	MaxQty      float64
+	MinPrice    float64
+	MaxPrice    float64
+	MinNotional float64
+}
+
+// RejectError 报价未通过交易规则
+type RejectError struct {
+	Reason string
+	Price  float64
+	Qty    float64
+	Limit  float64
+}
+
+func (e *RejectError) Error() string {
+	return fmt.Sprintf("quote rejected (%s): price=%v qty=%v limit=%v", e.Reason, e.Price, e.Qty, e.Limit)
+}
+
+// Loaded 是否已从交易所加载
+func (f Symbol) Loaded() bool {
+	return f.TickSize > 0 && f.StepSize > 0
+}
+
+// RoundPrice 按tick取整：买单向下、卖单向上，保证取整后不会更靠近盘口（不破坏Post Only）
+func (f Symbol) RoundPrice(side string, price float64) float64 {
+	if f.TickSize <= 0 {
+		return price
+	}
+	if strings.EqualFold(side, "SELL") {
+		return ceilStep(price, f.TickSize)
+	}
+	return floorStep(price, f.TickSize)
+}
+
+// RoundQty 按数量步长向下取整
+func (f Symbol) RoundQty(qty float64) float64 {
+	if f.StepSize <= 0 {
+		return qty
+	}
+	return floorStep(qty, f.StepSize)
+}
+
+// Apply 对报价取整并校验，返回交易所可接受的价格与数量；不满足规则时返回 *RejectError
+func (f Symbol) Apply(side string, price, qty float64) (float64, float64, error) {
+	if price <= 0 || qty <= 0 {
+		return 0, 0, &RejectError{Reason: ReasonInvalid, Price: price, Qty: qty}
+	}
+	p := f.RoundPrice(side, price)
+	q := f.RoundQty(qty)
+	if p <= 0 || (f.MinPrice > 0 && p < f.MinPrice) {
+		return 0, 0, &RejectError{Reason: ReasonPriceRange, Price: p, Qty: q, Limit: f.MinPrice}
+	}
+	if f.MaxPrice > 0 && p > f.MaxPrice {
+		return 0, 0, &RejectError{Reason: ReasonPriceRange, Price: p, Qty: q, Limit: f.MaxPrice}
+	}
+	if q <= 0 || q < f.MinQty-eps {
+		return 0, 0, &RejectError{Reason: ReasonMinQty, Price: p, Qty: q, Limit: f.MinQty}
+	}
+	if f.MaxQty > 0 && q > f.MaxQty+eps {
+		return 0, 0, &RejectError{Reason: ReasonMaxQty, Price: p, Qty: q, Limit: f.MaxQty}
+	}
+	if f.MinNotional > 0 && p*q < f.MinNotional-eps {
+		return 0, 0, &RejectError{Reason: ReasonMinNotional, Price: p, Qty: q, Limit: f.MinNotional}
+	}
+	return p, q, nil
+}
+
+// CheckConfig 校验手工配置的 tick_size / min_qty：
+// tick_size 须为交易所tick的整数倍，min_qty 不得低于交易所最小下单量且须为数量步长的整数倍
+func (f Symbol) CheckConfig(tickSize, minQty float64) error {
+	if f.TickSize > 0 && (tickSize <= 0 || !isMultiple(tickSize, f.TickSize)) {
+		return fmt.Errorf("%s: tick_size %v 不是交易所tick %v 的整数倍", f.Symbol, tickSize, f.TickSize)
+	}
+	if f.MinQty > 0 && minQty < f.MinQty-eps {
+		return fmt.Errorf("%s: min_qty %v 低于交易所最小下单量 %v", f.Symbol, minQty, f.MinQty)
+	}
+	if f.StepSize > 0 && minQty > 0 && !isMultiple(minQty, f.StepSize) {
+		return fmt.Errorf("%s: min_qty %v 不是交易所数量步长 %v 的整数倍", f.Symbol, minQty, f.StepSize)
+	}
+	return nil
+}
+
+// floorStep / ceilStep 按步长取整，恰好落在步长上的值保持不变，并按步长的小数位数消除浮点尾数
+func floorStep(v, step float64) float64 {
+	return roundDecimals(math.Floor(v/step+eps)*step, decimals(step))
+}
+
+func ceilStep(v, step float64) float64 {
+	return roundDecimals(math.Ceil(v/step-eps)*step, decimals(step))
+}
+
+func isMultiple(v, step float64) bool {
+	n := v / step
+	return math.Abs(n-math.Round(n)) < 1e-6
+}
+
+func decimals(step float64) int {
+	s := strconv.FormatFloat(step, 'f', -1, 64)
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+func roundDecimals(v float64, d int) float64 {
+	pow := math.Pow10(d)
+	return math.Round(v*pow) / pow
+}
@@ -0,0 +1,68 @@
+package filters
+
+import (
+	"errors"
+	"testing"
+)
+
+var btc = Symbol{Symbol: "BTCUSDT", TickSize: 0.1, StepSize: 0.001, MinQty: 0.001, MaxQty: 1000, MinPrice: 556.8, MaxPrice: 4529764, MinNotional: 100}
+
+func TestApplyRoundsAwayFromTouch(t *testing.T) {
+	cases := []struct {
+		side       string
+		price, qty float64
+		wantP      float64
+		wantQ      float64
+	}{
+		{"BUY", 60000.37, 0.0129, 60000.3, 0.012},
+		{"SELL", 60000.31, 0.0129, 60000.4, 0.012},
+		{"SELL", 60000.3, 0.3, 60000.3, 0.3}, // 已在tick上的价格不变
+		{"BUY", 60000.3, 0.3, 60000.3, 0.3},
+	}
+	for _, tc := range cases {
+		p, q, err := btc.Apply(tc.side, tc.price, tc.qty)
+		if err != nil || p != tc.wantP || q != tc.wantQ {
+			t.Errorf("%s %v x %v: got %v x %v err=%v, want %v x %v", tc.side, tc.price, tc.qty, p, q, err, tc.wantP, tc.wantQ)
+		}
+	}
+}
+
+func TestApplyRejections(t *testing.T) {
+	cases := []struct {
+		price, qty float64
+		reason     string
+	}{
+		{0, 1, ReasonInvalid},
+		{100, 1, ReasonPriceRange},
+		{60000, 0.0009, ReasonMinQty},
+		{60000, 1001, ReasonMaxQty},
+		{60000, 0.001, ReasonMinNotional},
+	}
+	for _, tc := range cases {
+		_, _, err := btc.Apply("BUY", tc.price, tc.qty)
+		var rej *RejectError
+		if !errors.As(err, &rej) || rej.Reason != tc.reason {
+			t.Errorf("%v x %v: got %v, want %s", tc.price, tc.qty, err, tc.reason)
+		}
+	}
+
+	// 未加载交易规则时原样放行
+	if p, q, err := (Symbol{}).Apply("SELL", 1.23456, 0.00001); err != nil || p != 1.23456 || q != 0.00001 {
+		t.Fatalf("empty filters should pass through: %v %v %v", p, q, err)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	if err := btc.CheckConfig(0.5, 0.002); err != nil {
+		t.Fatalf("valid config rejected: %v", err)
+	}
+	if err := btc.CheckConfig(0.01, 0.001); err == nil {
+		t.Fatal("tick_size finer than exchange tick should be rejected")
+	}
+	if err := btc.CheckConfig(0.1, 0.0005); err == nil {
+		t.Fatal("min_qty below exchange min should be rejected")
+	}
+	if err := btc.CheckConfig(0.1, 0.0015); err == nil {
+		t.Fatal("min_qty off the step should be rejected")
+	}
+}
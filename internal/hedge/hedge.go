@@ -0,0 +1,533 @@
+// Package hedge 对冲引擎：按做市交易对的仓位在相关品种或其他账户上建立反向仓位。
+// 库存积累时除同品种的库存偏移、钉子与磨仓外，可在流动性更好的品种上抵消净Delta
+package hedge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/newplayman/market-maker-phoenix/internal/filters"
+	"github.com/newplayman/market-maker-phoenix/internal/metrics"
+	"github.com/newplayman/market-maker-phoenix/internal/risk"
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// pendingIOCTimeout IOC下单后等待仓位反映的最长时间，超时后以查询到的仓位为准
+const pendingIOCTimeout = 10 * time.Second
+
+// inflightIOC 已下达但仓位尚未反映的IOC
+type inflightIOC struct {
+	clientID string
+	sign     float64 // 买为1，卖为-1
+	qty      float64 // 待反映到仓位的数量；收到终态推送后为实际成交量
+	since    time.Time
+}
+
+// Engine 单条对冲腿：目标对冲仓位 = -ratio × 做市仓位，偏差超过阈值时下单补齐
+type Engine struct {
+	cfg   config.HedgeConfig
+	store *store.Store
+	exch  gateway.Exchange
+	taker gateway.TakerOrderPlacer // 交易所不支持IOC时为nil
+	now   func() time.Time
+
+	mu      sync.RWMutex
+	synced  bool    // 对冲仓位是否已从交易所加载
+	size    float64 // 最近一次查询的对冲仓位
+	mark    float64 // 对冲品种中间价
+	filters filters.Symbol
+
+	// passive模式下的对冲挂单
+	working      *gateway.Order
+	workingSince time.Time
+
+	// 已下达但仓位尚未反映的IOC：仓位缓存滞后于成交，未扣除会重复对冲
+	pending     []inflightIOC
+	pendingBase float64 // 首笔在途IOC下单时的对冲仓位
+}
+
+// NewEngine 创建对冲引擎；taker执行或passive超时转taker需要交易所支持IOC下单
+func NewEngine(cfg config.HedgeConfig, st *store.Store, exch gateway.Exchange) (*Engine, error) {
+	e := &Engine{cfg: cfg, store: st, exch: exch, now: time.Now}
+	e.taker, _ = exch.(gateway.TakerOrderPlacer)
+	needTaker := cfg.Execution != config.HedgePassive || cfg.PassiveTimeoutSec > 0
+	if needTaker && e.taker == nil {
+		return nil, fmt.Errorf("对冲 %s→%s: 交易所不支持IOC下单", cfg.Symbol, cfg.HedgeSymbol)
+	}
+	return e, nil
+}
+
+// SetClock 设置时钟（测试使用）
+func (e *Engine) SetClock(now func() time.Time) {
+	e.now = now
+}
+
+// Config 返回对冲腿配置
+func (e *Engine) Config() config.HedgeConfig {
+	return e.cfg
+}
+
+// LoadFilters 从交易所加载对冲品种的交易规则；交易所不支持时按原值下单
+func (e *Engine) LoadFilters(ctx context.Context) error {
+	src, ok := e.exch.(gateway.SymbolFilterSource)
+	if !ok {
+		return nil
+	}
+	all, err := src.SymbolFilters(ctx, []string{e.cfg.HedgeSymbol})
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.filters = all[e.cfg.HedgeSymbol]
+	e.mu.Unlock()
+	return nil
+}
+
+// Start 加载交易规则并在后台按 interval_ms 周期对冲，ctx取消时撤销对冲挂单并退出
+func (e *Engine) Start(ctx context.Context) {
+	if err := e.LoadFilters(ctx); err != nil {
+		log.Warn().Err(err).Str("hedge_symbol", e.cfg.HedgeSymbol).Msg("加载对冲品种交易规则失败，按原值下单")
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(e.cfg.IntervalMs) * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				cancelCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				if err := e.cancelWorking(cancelCtx); err != nil {
+					log.Warn().Err(err).Str("hedge_symbol", e.cfg.HedgeSymbol).Msg("退出时撤销对冲挂单失败")
+				}
+				cancel()
+				return
+			case <-ticker.C:
+				if err := e.Step(ctx); err != nil {
+					log.Warn().
+						Err(err).
+						Str("symbol", e.cfg.Symbol).
+						Str("hedge_symbol", e.cfg.HedgeSymbol).
+						Msg("对冲失败")
+				}
+			}
+		}
+	}()
+	log.Info().
+		Str("symbol", e.cfg.Symbol).
+		Str("hedge_symbol", e.cfg.HedgeSymbol).
+		Float64("ratio", e.cfg.Ratio).
+		Str("execution", e.cfg.Execution).
+		Bool("separate_account", e.cfg.SeparateAccount()).
+		Msg("对冲引擎已启动")
+}
+
+// Step 执行一次对冲：刷新对冲仓位与盘口，偏差超过阈值时按配置的执行方式下单
+func (e *Engine) Step(ctx context.Context) error {
+	state := e.store.GetSymbolState(e.cfg.Symbol)
+	if state == nil {
+		return fmt.Errorf("交易对 %s 未初始化", e.cfg.Symbol)
+	}
+	state.Mu.RLock()
+	makerPos := state.Position.Size
+	state.Mu.RUnlock()
+
+	if err := e.syncPositions(ctx); err != nil {
+		return err
+	}
+	pos, err := e.exch.GetPosition(ctx, e.cfg.HedgeSymbol)
+	if err != nil {
+		return fmt.Errorf("查询对冲仓位失败: %w", err)
+	}
+	var size float64
+	if pos != nil {
+		size = pos.Size
+	}
+	depth, err := e.exch.GetDepth(ctx, e.cfg.HedgeSymbol, 5)
+	if err != nil {
+		return fmt.Errorf("查询对冲品种盘口失败: %w", err)
+	}
+	if depth == nil || len(depth.Bids) == 0 || len(depth.Asks) == 0 {
+		return fmt.Errorf("对冲品种 %s 盘口为空", e.cfg.HedgeSymbol)
+	}
+	bid, ask := depth.Bids[0].Price, depth.Asks[0].Price
+	mid := (bid + ask) / 2
+
+	e.mu.Lock()
+	e.size = size
+	e.mark = mid
+	e.mu.Unlock()
+	metrics.UpdateHedgePosition(e.cfg.Symbol, e.cfg.HedgeSymbol, size)
+
+	delta := e.target(makerPos) - size - e.inflight(size)
+	if math.Abs(delta) < 1e-12 || math.Abs(delta) < e.cfg.Threshold {
+		return e.cancelWorking(ctx)
+	}
+	side := "BUY"
+	if delta < 0 {
+		side = "SELL"
+	}
+
+	if e.cfg.Execution == config.HedgePassive {
+		return e.workPassive(ctx, side, math.Abs(delta), bid, ask, mid)
+	}
+	return e.take(ctx, side, math.Abs(delta), bid, ask, mid)
+}
+
+// syncPositions 首次对冲前通过REST加载仓位，避免把已有的对冲仓位当作0而重复对冲
+func (e *Engine) syncPositions(ctx context.Context) error {
+	e.mu.RLock()
+	synced := e.synced
+	e.mu.RUnlock()
+	if synced {
+		return nil
+	}
+	if syncer, ok := e.exch.(gateway.PositionSyncer); ok {
+		if err := syncer.SyncPositions(ctx); err != nil {
+			return fmt.Errorf("加载对冲仓位失败: %w", err)
+		}
+	}
+	e.mu.Lock()
+	e.synced = true
+	e.mu.Unlock()
+	return nil
+}
+
+// target 目标对冲仓位，按 max_position 截断
+func (e *Engine) target(makerPos float64) float64 {
+	t := -e.cfg.Ratio * makerPos
+	if m := e.cfg.MaxPosition; m > 0 {
+		t = math.Max(math.Min(t, m), -m)
+	}
+	return t
+}
+
+// take 以IOC限价单主动成交，限价为中间价加减最大滑点；最优价已超出滑点限制时不下单
+func (e *Engine) take(ctx context.Context, side string, qty, bid, ask, mid float64) error {
+	slip := e.cfg.MaxSlippageBps / 1e4
+	limit := mid * (1 + slip)
+	touch := ask
+	if side == "SELL" {
+		limit = mid * (1 - slip)
+		touch = bid
+	}
+	if (side == "BUY" && touch > limit) || (side == "SELL" && touch < limit) {
+		log.Warn().
+			Str("hedge_symbol", e.cfg.HedgeSymbol).
+			Str("side", side).
+			Float64("touch", touch).
+			Float64("limit", limit).
+			Float64("max_slippage_bps", e.cfg.MaxSlippageBps).
+			Msg("对冲品种最优价超出滑点限制，暂不对冲")
+		metrics.RecordHedgeOrder(e.cfg.HedgeSymbol, config.HedgeTaker, "slippage")
+		return nil
+	}
+
+	order, ok := e.buildOrder(side, limit, qty)
+	if !ok {
+		return nil
+	}
+	placed, err := e.taker.PlaceIOC(ctx, order)
+	if err != nil {
+		metrics.RecordHedgeOrder(e.cfg.HedgeSymbol, config.HedgeTaker, "error")
+		return fmt.Errorf("对冲IOC下单失败: %w", err)
+	}
+	if placed == nil {
+		placed = order
+	}
+	sign := 1.0
+	if side == "SELL" {
+		sign = -1
+	}
+	e.mu.Lock()
+	if len(e.pending) == 0 {
+		e.pendingBase = e.size
+	}
+	e.pending = append(e.pending, inflightIOC{clientID: placed.ClientOrderID, sign: sign, qty: placed.Quantity, since: e.now()})
+	e.mu.Unlock()
+	metrics.RecordHedgeOrder(e.cfg.HedgeSymbol, config.HedgeTaker, "placed")
+	log.Info().
+		Str("symbol", e.cfg.Symbol).
+		Str("hedge_symbol", e.cfg.HedgeSymbol).
+		Str("side", side).
+		Float64("price", order.Price).
+		Float64("qty", order.Quantity).
+		Str("client_id", placed.ClientOrderID).
+		Msg("对冲IOC已下单")
+	return nil
+}
+
+// inflight 返回已下达但仓位尚未反映的IOC数量（带方向）：在途IOC合计减去首笔下单以来的仓位变化。
+// 仓位已全部反映或最早一笔等待超时后清除
+func (e *Engine) inflight(size float64) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.pending) == 0 {
+		return 0
+	}
+	expected := 0.0
+	for _, p := range e.pending {
+		expected += p.sign * p.qty
+	}
+	rest := expected - (size - e.pendingBase)
+	if rest*expected <= 0 || math.Abs(rest) < 1e-12 {
+		e.pending = nil
+		return 0
+	}
+	if e.now().Sub(e.pending[0].since) >= pendingIOCTimeout {
+		log.Warn().
+			Str("hedge_symbol", e.cfg.HedgeSymbol).
+			Str("client_id", e.pending[0].clientID).
+			Int("inflight", len(e.pending)).
+			Float64("unconfirmed", rest).
+			Msg("对冲IOC等待仓位更新超时，按查询到的仓位继续对冲")
+		e.pending = nil
+		return 0
+	}
+	return rest
+}
+
+// workPassive 在己方最优价挂Post Only单并随盘口改价；挂单超过 passive_timeout_sec 仍未完成时撤单转taker
+func (e *Engine) workPassive(ctx context.Context, side string, qty, bid, ask, mid float64) error {
+	if err := e.refreshWorking(ctx); err != nil {
+		return err
+	}
+
+	e.mu.RLock()
+	w := e.working
+	since := e.workingSince
+	e.mu.RUnlock()
+
+	if w != nil {
+		timedOut := e.cfg.PassiveTimeoutSec > 0 &&
+			e.now().Sub(since) >= time.Duration(e.cfg.PassiveTimeoutSec*float64(time.Second))
+		if w.Side != side || timedOut {
+			if err := e.cancelWorking(ctx); err != nil {
+				return err
+			}
+			if timedOut {
+				log.Info().
+					Str("hedge_symbol", e.cfg.HedgeSymbol).
+					Float64("timeout_sec", e.cfg.PassiveTimeoutSec).
+					Msg("对冲挂单超时未完成，转为taker")
+				return e.take(ctx, side, qty, bid, ask, mid)
+			}
+		}
+	}
+
+	price := bid
+	if side == "SELL" {
+		price = ask
+	}
+
+	if w != nil && w.Side == side {
+		order, ok := e.buildOrder(side, price, w.FilledQty+qty)
+		if !ok {
+			return e.cancelWorking(ctx)
+		}
+		if math.Abs(order.Price-w.Price) < 1e-12 && math.Abs(order.Quantity-w.Quantity) < 1e-12 {
+			return nil
+		}
+		order.ClientOrderID = w.ClientOrderID
+		amended, err := e.exch.AmendOrder(ctx, order)
+		if err != nil {
+			// 改价失败（已成交或已撤销）：撤单后下一轮按最新仓位重新挂单
+			metrics.RecordHedgeOrder(e.cfg.HedgeSymbol, config.HedgePassive, "amend_error")
+			if cerr := e.cancelWorking(ctx); cerr != nil {
+				return cerr
+			}
+			return fmt.Errorf("对冲挂单改价失败: %w", err)
+		}
+		if amended == nil {
+			amended = order
+		}
+		amended.FilledQty = w.FilledQty
+		e.mu.Lock()
+		e.working = amended
+		e.mu.Unlock()
+		metrics.RecordHedgeOrder(e.cfg.HedgeSymbol, config.HedgePassive, "amended")
+		return nil
+	}
+
+	order, ok := e.buildOrder(side, price, qty)
+	if !ok {
+		return nil
+	}
+	placed, err := e.exch.PlaceOrder(ctx, order)
+	if errors.Is(err, gateway.ErrPostOnlyReject) {
+		// 盘口在查询后移动，下一轮按新盘口挂单
+		metrics.RecordHedgeOrder(e.cfg.HedgeSymbol, config.HedgePassive, "post_only_reject")
+		return nil
+	}
+	if err != nil {
+		metrics.RecordHedgeOrder(e.cfg.HedgeSymbol, config.HedgePassive, "error")
+		return fmt.Errorf("对冲挂单失败: %w", err)
+	}
+	if placed == nil {
+		placed = order
+	}
+	e.mu.Lock()
+	e.working = placed
+	e.workingSince = e.now()
+	e.mu.Unlock()
+	metrics.RecordHedgeOrder(e.cfg.HedgeSymbol, config.HedgePassive, "placed")
+	log.Info().
+		Str("symbol", e.cfg.Symbol).
+		Str("hedge_symbol", e.cfg.HedgeSymbol).
+		Str("side", side).
+		Float64("price", placed.Price).
+		Float64("qty", placed.Quantity).
+		Msg("对冲挂单已下达")
+	return nil
+}
+
+// refreshWorking 按交易所挂单刷新对冲挂单的成交量；挂单已不在时清除
+func (e *Engine) refreshWorking(ctx context.Context) error {
+	e.mu.RLock()
+	w := e.working
+	e.mu.RUnlock()
+	if w == nil {
+		return nil
+	}
+	open, err := e.exch.GetOpenOrders(ctx, e.cfg.HedgeSymbol)
+	if err != nil {
+		return fmt.Errorf("查询对冲挂单失败: %w", err)
+	}
+	var found *gateway.Order
+	for _, o := range open {
+		if o.ClientOrderID == w.ClientOrderID {
+			found = o
+			break
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if found == nil {
+		e.working = nil
+		return nil
+	}
+	cp := *w
+	cp.FilledQty = found.FilledQty
+	e.working = &cp
+	return nil
+}
+
+// cancelWorking 撤销passive对冲挂单
+func (e *Engine) cancelWorking(ctx context.Context) error {
+	e.mu.Lock()
+	w := e.working
+	e.working = nil
+	e.mu.Unlock()
+	if w == nil {
+		return nil
+	}
+	err := e.exch.CancelOrder(ctx, e.cfg.HedgeSymbol, w.ClientOrderID)
+	if err != nil && !errors.Is(err, gateway.ErrUnknownOrder) {
+		return fmt.Errorf("撤销对冲挂单失败: %w", err)
+	}
+	return nil
+}
+
+// buildOrder 按交易规则取整；不满足最小下单量或名义价值时不下单
+func (e *Engine) buildOrder(side string, price, qty float64) (*gateway.Order, bool) {
+	e.mu.RLock()
+	f := e.filters
+	e.mu.RUnlock()
+	p, q, err := f.Apply(side, price, qty)
+	if err != nil {
+		log.Debug().
+			Err(err).
+			Str("hedge_symbol", e.cfg.HedgeSymbol).
+			Str("side", side).
+			Float64("qty", qty).
+			Msg("对冲量不满足交易规则，跳过")
+		return nil, false
+	}
+	return &gateway.Order{
+		Symbol:   e.cfg.HedgeSymbol,
+		Side:     side,
+		Type:     "LIMIT",
+		Price:    p,
+		Quantity: q,
+	}, true
+}
+
+// Exposure 对冲腿敞口（按最近一次查询的仓位与中间价）
+func (e *Engine) Exposure() risk.HedgeExposure {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return risk.HedgeExposure{
+		Symbol:      e.cfg.Symbol,
+		HedgeSymbol: e.cfg.HedgeSymbol,
+		Size:        e.size,
+		Notional:    e.size * e.mark,
+	}
+}
+
+// OnOrderUpdate 对冲品种的订单推送（独立账户的用户数据流或同账户经Runner转发）：记录对冲成交；IOC终态后只等待实际成交量反映到仓位
+func (e *Engine) OnOrderUpdate(order *gateway.Order) {
+	if order == nil || order.Symbol != e.cfg.HedgeSymbol {
+		return
+	}
+	switch order.Status {
+	case "FILLED", "CANCELED", "EXPIRED", "REJECTED":
+		e.mu.Lock()
+		for i := range e.pending {
+			if e.pending[i].clientID == order.ClientOrderID {
+				e.pending[i].qty = order.FilledQty
+			}
+		}
+		e.mu.Unlock()
+	}
+	if order.LastFilledQty <= 0 {
+		return
+	}
+	log.Info().
+		Str("symbol", e.cfg.Symbol).
+		Str("hedge_symbol", order.Symbol).
+		Str("side", order.Side).
+		Float64("price", order.LastFilledPrice).
+		Float64("qty", order.LastFilledQty).
+		Str("client_id", order.ClientOrderID).
+		Msg("对冲成交")
+}
+
+// Manager 管理全部对冲腿，向风控提供对冲敞口
+type Manager struct {
+	engines []*Engine
+}
+
+// NewManager 创建对冲管理器
+func NewManager(engines ...*Engine) *Manager {
+	return &Manager{engines: engines}
+}
+
+// Start 启动全部对冲引擎
+func (m *Manager) Start(ctx context.Context) {
+	for _, e := range m.engines {
+		e.Start(ctx)
+	}
+}
+
+// OnOrderUpdate 转发同账户用户数据流中非做市交易对的订单推送给共用该账户的对冲引擎
+func (m *Manager) OnOrderUpdate(order *gateway.Order) {
+	for _, e := range m.engines {
+		if !e.cfg.SeparateAccount() {
+			e.OnOrderUpdate(order)
+		}
+	}
+}
+
+// HedgeExposures 实现 risk.HedgeSource
+func (m *Manager) HedgeExposures() []risk.HedgeExposure {
+	out := make([]risk.HedgeExposure, 0, len(m.engines))
+	for _, e := range m.engines {
+		out = append(out, e.Exposure())
+	}
+	return out
+}
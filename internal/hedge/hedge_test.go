@@ -0,0 +1,284 @@
+package hedge
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/newplayman/market-maker-phoenix/internal/filters"
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+)
+
+// hedgeExchange 对冲品种的模拟交易所：未实现的方法由嵌入的接口兜底（调用即panic）
+type hedgeExchange struct {
+	gateway.Exchange
+	position float64
+	bid, ask float64
+	open     map[string]*gateway.Order
+	iocs     []*gateway.Order
+	placed   []*gateway.Order
+	amended  []*gateway.Order
+	canceled []string
+}
+
+func newHedgeExchange() *hedgeExchange {
+	return &hedgeExchange{bid: 2999.9, ask: 3000.1, open: make(map[string]*gateway.Order)}
+}
+
+func (m *hedgeExchange) GetPosition(ctx context.Context, symbol string) (*gateway.Position, error) {
+	return &gateway.Position{Symbol: symbol, Size: m.position}, nil
+}
+
+func (m *hedgeExchange) GetDepth(ctx context.Context, symbol string, limit int) (*gateway.Depth, error) {
+	return &gateway.Depth{
+		Symbol: symbol,
+		Bids:   []gateway.PriceLevel{{Price: m.bid, Quantity: 10}},
+		Asks:   []gateway.PriceLevel{{Price: m.ask, Quantity: 10}},
+	}, nil
+}
+
+func (m *hedgeExchange) PlaceIOC(ctx context.Context, order *gateway.Order) (*gateway.Order, error) {
+	order.ClientOrderID = "ioc" + string(rune('0'+len(m.iocs)))
+	m.iocs = append(m.iocs, order)
+	return order, nil
+}
+
+func (m *hedgeExchange) PlaceOrder(ctx context.Context, order *gateway.Order) (*gateway.Order, error) {
+	order.ClientOrderID = "h" + string(rune('0'+len(m.placed)))
+	m.placed = append(m.placed, order)
+	cp := *order
+	m.open[order.ClientOrderID] = &cp
+	return order, nil
+}
+
+func (m *hedgeExchange) AmendOrder(ctx context.Context, order *gateway.Order) (*gateway.Order, error) {
+	m.amended = append(m.amended, order)
+	o := m.open[order.ClientOrderID]
+	o.Price, o.Quantity = order.Price, order.Quantity
+	return order, nil
+}
+
+func (m *hedgeExchange) CancelOrder(ctx context.Context, symbol, clientOrderID string) error {
+	m.canceled = append(m.canceled, clientOrderID)
+	delete(m.open, clientOrderID)
+	return nil
+}
+
+func (m *hedgeExchange) GetOpenOrders(ctx context.Context, symbol string) ([]*gateway.Order, error) {
+	var out []*gateway.Order
+	for _, o := range m.open {
+		cp := *o
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func newTestEngine(t *testing.T, cfg config.HedgeConfig, makerPos float64) (*Engine, *hedgeExchange) {
+	t.Helper()
+	st := store.NewStore("", time.Minute)
+	st.InitSymbol("ETHUSDC", 100)
+	st.UpdatePosition("ETHUSDC", store.Position{Symbol: "ETHUSDC", Size: makerPos})
+	ex := newHedgeExchange()
+	cfg.Symbol, cfg.HedgeSymbol = "ETHUSDC", "ETHUSDT"
+	e, err := NewEngine(cfg, st, ex)
+	if err != nil {
+		t.Fatalf("NewEngine: %v", err)
+	}
+	e.filters = filters.Symbol{TickSize: 0.01, StepSize: 0.001, MinQty: 0.001}
+	return e, ex
+}
+
+func TestEngine_TakerSizingAndSlippage(t *testing.T) {
+	e, ex := newTestEngine(t, config.HedgeConfig{Ratio: 0.5, Threshold: 0.05, MaxSlippageBps: 5, Execution: config.HedgeTaker}, 1.2)
+
+	// 目标 -0.6，已有 -0.2：卖出 0.4，限价不低于中间价 - 5bp
+	ex.position = -0.2
+	if err := e.Step(context.Background()); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.iocs) != 1 {
+		t.Fatalf("期望1笔IOC，实际%d笔", len(ex.iocs))
+	}
+	o := ex.iocs[0]
+	if o.Side != "SELL" || math.Abs(o.Quantity-0.4) > 1e-9 || o.Price != 2998.5 {
+		t.Fatalf("IOC下单不符合预期: %+v", o)
+	}
+	if exp := e.Exposure(); exp.Size != -0.2 || exp.Notional != -600 {
+		t.Fatalf("对冲敞口不符合预期: %+v", exp)
+	}
+
+	// 偏差低于阈值不下单
+	ex.position = -0.58
+	_ = e.Step(context.Background())
+	if len(ex.iocs) != 1 {
+		t.Fatal("偏差低于阈值时不应下单")
+	}
+
+	// 最优价超出滑点限制时不下单
+	ex.position = 0
+	ex.bid, ex.ask = 2990, 3010
+	_ = e.Step(context.Background())
+	if len(ex.iocs) != 1 {
+		t.Fatal("最优价超出滑点限制时不应下单")
+	}
+}
+
+func TestEngine_TakerWaitsForLatePositionUpdate(t *testing.T) {
+	e, ex := newTestEngine(t, config.HedgeConfig{Ratio: 1, MaxSlippageBps: 5, Execution: config.HedgeTaker}, 1)
+	now := time.Unix(1700000000, 0)
+	e.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.iocs) != 1 || ex.iocs[0].Quantity != 1 {
+		t.Fatalf("期望卖出1的IOC: %+v", ex.iocs)
+	}
+
+	// 仓位推送滞后：仓位未变与部分反映时都不应重复下单
+	now = now.Add(time.Second)
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	ex.position = -0.4
+	now = now.Add(time.Second)
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.iocs) != 1 {
+		t.Fatalf("仓位未反映IOC成交时不应重复下单，实际%d笔", len(ex.iocs))
+	}
+
+	// IOC只成交0.4后过期：仓位已反映实际成交，补齐剩余0.6
+	e.OnOrderUpdate(&gateway.Order{Symbol: "ETHUSDT", ClientOrderID: ex.iocs[0].ClientOrderID, Status: "EXPIRED", FilledQty: 0.4})
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.iocs) != 2 || math.Abs(ex.iocs[1].Quantity-0.6) > 1e-9 {
+		t.Fatalf("期望补齐0.6: %+v", ex.iocs)
+	}
+
+	// 仓位始终未更新：超时后按查询到的仓位继续对冲
+	now = now.Add(pendingIOCTimeout)
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.iocs) != 3 {
+		t.Fatalf("等待超时后应按仓位重新对冲，实际%d笔", len(ex.iocs))
+	}
+}
+
+func TestEngine_BackToBackIOCsWithLatePositionUpdate(t *testing.T) {
+	e, ex := newTestEngine(t, config.HedgeConfig{Ratio: 1, MaxSlippageBps: 5, Execution: config.HedgeTaker}, 1)
+	ctx := context.Background()
+
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	// 做市仓位继续增加：在首笔IOC反映到仓位前再补一笔
+	e.store.UpdatePosition("ETHUSDC", store.Position{Symbol: "ETHUSDC", Size: 2})
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.iocs) != 2 || ex.iocs[1].Quantity != 1 {
+		t.Fatalf("期望第二笔卖出1的IOC: %+v", ex.iocs)
+	}
+
+	// 首笔成交先反映到仓位：第二笔仍在途，不应重复对冲
+	ex.position = -1
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	ex.position = -2
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.iocs) != 2 {
+		t.Fatalf("在途IOC反映前不应重复对冲，实际%d笔: %+v", len(ex.iocs), ex.iocs)
+	}
+}
+
+func TestManager_RoutesSharedAccountOrderUpdates(t *testing.T) {
+	e, ex := newTestEngine(t, config.HedgeConfig{Ratio: 1, MaxSlippageBps: 5, Execution: config.HedgeTaker}, 1)
+	m := NewManager(e)
+	ctx := context.Background()
+
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	// 同账户的IOC过期推送经Runner转发到对冲管理器，无需等待超时即可补单
+	m.OnOrderUpdate(&gateway.Order{Symbol: "ETHUSDT", ClientOrderID: ex.iocs[0].ClientOrderID, Status: "EXPIRED"})
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.iocs) != 2 {
+		t.Fatalf("IOC过期后应重新对冲，实际%d笔", len(ex.iocs))
+	}
+}
+
+func TestEngine_MaxPosition(t *testing.T) {
+	e, ex := newTestEngine(t, config.HedgeConfig{Ratio: 1, MaxPosition: 0.5, MaxSlippageBps: 5, Execution: config.HedgeTaker}, -2)
+	if err := e.Step(context.Background()); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.iocs) != 1 || ex.iocs[0].Side != "BUY" || ex.iocs[0].Quantity != 0.5 {
+		t.Fatalf("对冲仓位应截断到 max_position: %+v", ex.iocs)
+	}
+}
+
+func TestEngine_PassiveRepricesAndEscalates(t *testing.T) {
+	e, ex := newTestEngine(t, config.HedgeConfig{Ratio: 1, MaxSlippageBps: 5, Execution: config.HedgePassive, PassiveTimeoutSec: 10}, 1)
+	now := time.Unix(1700000000, 0)
+	e.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+
+	// 挂在卖一
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.placed) != 1 || ex.placed[0].Side != "SELL" || ex.placed[0].Price != 3000.1 || ex.placed[0].Quantity != 1 {
+		t.Fatalf("期望在卖一挂单: %+v", ex.placed)
+	}
+
+	// 盘口下移后改价，数量含已成交部分
+	ex.open["h0"].FilledQty = 0.3
+	ex.position = -0.3
+	ex.bid, ex.ask = 2999.4, 2999.6
+	now = now.Add(2 * time.Second)
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.amended) != 1 || ex.amended[0].Price != 2999.6 || ex.amended[0].Quantity != 1 {
+		t.Fatalf("期望改价到新卖一: %+v", ex.amended)
+	}
+
+	// 超时未完成：撤单并以IOC补齐剩余
+	now = now.Add(10 * time.Second)
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.canceled) != 1 || len(ex.iocs) != 1 || math.Abs(ex.iocs[0].Quantity-0.7) > 1e-9 {
+		t.Fatalf("期望撤单后IOC补齐0.7: canceled=%v iocs=%+v", ex.canceled, ex.iocs)
+	}
+
+	// IOC未成交：终态推送后剩余部分重新挂单；对冲到位后撤销挂单
+	e.OnOrderUpdate(&gateway.Order{Symbol: "ETHUSDT", ClientOrderID: ex.iocs[0].ClientOrderID, Status: "EXPIRED"})
+	now = now.Add(time.Second)
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.placed) != 2 {
+		t.Fatalf("期望重新挂单: %+v", ex.placed)
+	}
+	ex.position = -1
+	if err := e.Step(ctx); err != nil {
+		t.Fatalf("Step: %v", err)
+	}
+	if len(ex.canceled) != 2 || len(ex.open) != 0 {
+		t.Fatalf("对冲到位后应撤销挂单: canceled=%v open=%v", ex.canceled, ex.open)
+	}
+}
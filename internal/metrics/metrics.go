@@ -3,6 +3,7 @@ package metrics
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -102,6 +103,55 @@ var (
 		[]string{"symbol"},
 	)
 
+	// 盈亏账本指标
+	RealizedPNL = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "phoenix_realized_pnl",
+			Help: "已实现盈亏（不含手续费与资金费）",
+		},
+		[]string{"symbol"},
+	)
+
+	LedgerUnrealizedPNL = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "phoenix_ledger_unrealized_pnl",
+			Help: "账本按标记价格计算的未实现盈亏",
+		},
+		[]string{"symbol"},
+	)
+
+	Fees = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "phoenix_fees",
+			Help: "累计手续费（负值为返佣）",
+		},
+		[]string{"symbol", "liquidity"},
+	)
+
+	FundingPNL = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "phoenix_funding",
+			Help: "累计资金费（paid为支出，received为收入）",
+		},
+		[]string{"symbol", "direction"},
+	)
+
+	EquityHighWater = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "phoenix_equity_high_water",
+			Help: "账本净值高水位",
+		},
+		[]string{"symbol"},
+	)
+
+	Drawdown = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "phoenix_drawdown",
+			Help: "当前净值距高水位的回撤",
+		},
+		[]string{"symbol"},
+	)
+
 	// 风控指标
 	WorstCaseLong = prometheus.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -134,6 +184,14 @@ var (
 		[]string{"symbol"},
 	)
 
+	AmendRate = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "phoenix_amend_rate",
+			Help: "每分钟改单数",
+		},
+		[]string{"symbol"},
+	)
+
 	// 系统指标
 	QuoteGeneration = prometheus.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -162,6 +220,137 @@ var (
 		[]string{"endpoint", "status"},
 	)
 
+	// 下单通道指标，便于对比WSS与REST的延迟
+	OrderChannelLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "phoenix_order_channel_latency_seconds",
+			Help:    "下单/撤单请求延迟（按通道）",
+			Buckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 3.0},
+		},
+		[]string{"channel", "op", "result"},
+	)
+
+	OrderChannelFallbacks = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "phoenix_order_channel_fallbacks_total",
+			Help: "WSS请求超时或断线后回退REST的次数",
+		},
+		[]string{"op"},
+	)
+
+	// WebSocket连接状态
+	WSConnected = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "phoenix_ws_connected",
+			Help: "WebSocket连接状态 (1=已连接)",
+		},
+		[]string{"conn"},
+	)
+
+	WSReconnects = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "phoenix_ws_reconnects_total",
+			Help: "WebSocket断线重连次数（按原因）",
+		},
+		[]string{"conn", "reason"},
+	)
+
+	// 本地时钟与交易所服务器时间的偏差
+	ClockOffset = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "phoenix_clock_offset_ms",
+			Help: "交易所服务器时间减本地时间（毫秒）",
+		},
+	)
+
+	TimeSyncRTT = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "phoenix_time_sync_rtt_ms",
+			Help: "最近一次服务器时间同步的往返时间（毫秒）",
+		},
+	)
+
+	// 因时间戳超出recvWindow(-1021)被拒绝的签名请求
+	TimestampRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "phoenix_timestamp_rejections_total",
+			Help: "因时间戳超出recvWindow(-1021)被拒绝的签名请求数",
+		},
+		[]string{"channel"},
+	)
+
+	// 未通过交易所交易规则而被丢弃的报价
+	QuoteRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "phoenix_quote_rejections_total",
+			Help: "未通过交易规则(tick/step/最小下单量/最小名义价值)被丢弃的报价数",
+		},
+		[]string{"symbol", "side", "reason"},
+	)
+
+	// 事件驱动重新报价的触发次数
+	Requotes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "phoenix_requotes_total",
+			Help: "重新报价次数（按触发原因）",
+		},
+		[]string{"symbol", "reason"},
+	)
+
+	// 对冲腿仓位与对冲下单
+	HedgePosition = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "phoenix_hedge_position",
+			Help: "对冲腿仓位（正为多，负为空）",
+		},
+		[]string{"symbol", "hedge_symbol"},
+	)
+
+	HedgeOrders = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "phoenix_hedge_orders_total",
+			Help: "对冲下单次数（按执行方式与结果）",
+		},
+		[]string{"hedge_symbol", "execution", "result"},
+	)
+
+	// 组合风险
+	PortfolioVaR = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "phoenix_portfolio_var",
+			Help: "组合参数法VaR ($)",
+		},
+	)
+
+	PortfolioES = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "phoenix_portfolio_es",
+			Help: "组合参数法ES ($)",
+		},
+	)
+
+	PortfolioNetDelta = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "phoenix_portfolio_net_delta",
+			Help: "组合净美元Delta（含对冲腿）",
+		},
+	)
+
+	PortfolioBetaDelta = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "phoenix_portfolio_beta_delta",
+			Help: "组合beta加权美元Delta",
+		},
+	)
+
+	PortfolioSideScale = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "phoenix_portfolio_side_scale",
+			Help: "组合风控对报价量的缩放系数（1为不限制，0为禁止）",
+		},
+		[]string{"symbol", "side"},
+	)
+
 	ErrorCount = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "phoenix_error_count_total",
@@ -194,6 +383,23 @@ var (
 		},
 		[]string{"symbol"},
 	)
+
+	// 订单流毒性指标
+	VPIN = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "phoenix_vpin",
+			Help: "VPIN订单流毒性 (0-1)",
+		},
+		[]string{"symbol"},
+	)
+
+	VPINPauses = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "phoenix_vpin_pauses_total",
+			Help: "因VPIN过高暂停报价次数",
+		},
+		[]string{"symbol"},
+	)
 )
 
 func init() {
@@ -210,17 +416,42 @@ func init() {
 		FillCount,
 		FillVolume,
 		TotalPNL,
+		RealizedPNL,
+		LedgerUnrealizedPNL,
+		Fees,
+		FundingPNL,
+		EquityHighWater,
+		Drawdown,
 		WorstCaseLong,
 		TotalNotional,
 		MaxDrawdown,
 		CancelRate,
+		AmendRate,
 		QuoteGeneration,
 		OrderPlacement,
 		APILatency,
+		OrderChannelLatency,
+		OrderChannelFallbacks,
+		WSConnected,
+		WSReconnects,
+		ClockOffset,
+		TimeSyncRTT,
+		TimestampRejections,
+		QuoteRejections,
+		Requotes,
+		HedgePosition,
+		HedgeOrders,
+		PortfolioVaR,
+		PortfolioES,
+		PortfolioNetDelta,
+		PortfolioBetaDelta,
+		PortfolioSideScale,
 		ErrorCount,
 		StrategyMode,
 		InventorySkew,
 		VolatilityScaling,
+		VPIN,
+		VPINPauses,
 	)
 }
 
@@ -258,6 +489,30 @@ func UpdatePositionMetrics(symbol string, size, notional, unrealizedPNL float64)
 	UnrealizedPNL.WithLabelValues(symbol).Set(unrealizedPNL)
 }
 
+// PnLStats 盈亏账本指标快照
+type PnLStats struct {
+	Realized        float64
+	Unrealized      float64
+	MakerFees       float64
+	TakerFees       float64
+	FundingPaid     float64
+	FundingReceived float64
+	HighWater       float64
+	Drawdown        float64
+}
+
+// UpdatePnLMetrics 更新盈亏账本指标
+func UpdatePnLMetrics(symbol string, s PnLStats) {
+	RealizedPNL.WithLabelValues(symbol).Set(s.Realized)
+	LedgerUnrealizedPNL.WithLabelValues(symbol).Set(s.Unrealized)
+	Fees.WithLabelValues(symbol, "maker").Set(s.MakerFees)
+	Fees.WithLabelValues(symbol, "taker").Set(s.TakerFees)
+	FundingPNL.WithLabelValues(symbol, "paid").Set(s.FundingPaid)
+	FundingPNL.WithLabelValues(symbol, "received").Set(s.FundingReceived)
+	EquityHighWater.WithLabelValues(symbol).Set(s.HighWater)
+	Drawdown.WithLabelValues(symbol).Set(s.Drawdown)
+}
+
 // UpdatePendingMetrics 更新挂单指标
 func UpdatePendingMetrics(symbol string, buySize, sellSize float64) {
 	PendingBuySize.WithLabelValues(symbol).Set(buySize)
@@ -270,3 +525,81 @@ func UpdateMarketMetrics(symbol string, mid, spread, funding float64) {
 	PriceSpread.WithLabelValues(symbol).Set(spread)
 	FundingRate.WithLabelValues(symbol).Set(funding)
 }
+
+// UpdateVPIN 更新VPIN指标
+func UpdateVPIN(symbol string, vpin float64) {
+	VPIN.WithLabelValues(symbol).Set(vpin)
+}
+
+// RecordVPINPause 记录一次VPIN暂停报价
+func RecordVPINPause(symbol string) {
+	VPINPauses.WithLabelValues(symbol).Inc()
+}
+
+// ObserveOrderChannel 记录一次下单/撤单请求在指定通道上的耗时
+func ObserveOrderChannel(channel, op, result string, d time.Duration) {
+	OrderChannelLatency.WithLabelValues(channel, op, result).Observe(d.Seconds())
+}
+
+// RecordOrderChannelFallback 记录一次WSS回退REST
+func RecordOrderChannelFallback(op string) {
+	OrderChannelFallbacks.WithLabelValues(op).Inc()
+}
+
+// SetWSConnected 更新WebSocket连接状态
+func SetWSConnected(conn string, connected bool) {
+	v := 0.0
+	if connected {
+		v = 1
+	}
+	WSConnected.WithLabelValues(conn).Set(v)
+}
+
+// RecordWSReconnect 记录一次WebSocket断线重连
+func RecordWSReconnect(conn, reason string) {
+	WSReconnects.WithLabelValues(conn, reason).Inc()
+}
+
+// SetClockSync 更新时钟偏差与同步往返时间（毫秒）
+func SetClockSync(offsetMs, rttMs float64) {
+	ClockOffset.Set(offsetMs)
+	TimeSyncRTT.Set(rttMs)
+}
+
+// RecordTimestampRejection 记录一次 -1021 时间戳拒绝
+func RecordTimestampRejection(channel string) {
+	TimestampRejections.WithLabelValues(channel).Inc()
+}
+
+// RecordQuoteRejection 记录一次未通过交易规则的报价
+func RecordQuoteRejection(symbol, side, reason string) {
+	QuoteRejections.WithLabelValues(symbol, side, reason).Inc()
+}
+
+// RecordRequote 记录一次重新报价及其触发原因
+func RecordRequote(symbol, reason string) {
+	Requotes.WithLabelValues(symbol, reason).Inc()
+}
+
+// UpdateHedgePosition 更新对冲腿仓位
+func UpdateHedgePosition(symbol, hedgeSymbol string, size float64) {
+	HedgePosition.WithLabelValues(symbol, hedgeSymbol).Set(size)
+}
+
+// UpdatePortfolioRisk 更新组合风险指标
+func UpdatePortfolioRisk(varValue, es, netDelta, betaDelta float64) {
+	PortfolioVaR.Set(varValue)
+	PortfolioES.Set(es)
+	PortfolioNetDelta.Set(netDelta)
+	PortfolioBetaDelta.Set(betaDelta)
+}
+
+// UpdatePortfolioSideScale 更新组合风控对某一侧报价量的缩放系数
+func UpdatePortfolioSideScale(symbol, side string, scale float64) {
+	PortfolioSideScale.WithLabelValues(symbol, side).Set(scale)
+}
+
+// RecordHedgeOrder 记录一次对冲下单结果
+func RecordHedgeOrder(hedgeSymbol, execution, result string) {
+	HedgeOrders.WithLabelValues(hedgeSymbol, execution, result).Inc()
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 func TestMetricsInitialization(t *testing.T) {
@@ -211,6 +212,23 @@ func TestTotalPNLMetric(t *testing.T) {
 	// 验证不panic
 }
 
+func TestUpdatePnLMetrics(t *testing.T) {
+	symbol := "BTCUSDT"
+
+	// 更新盈亏账本指标
+	UpdatePnLMetrics(symbol, PnLStats{Realized: 12, MakerFees: -0.5, TakerFees: 1, FundingPaid: 0.2, HighWater: 15, Drawdown: 3})
+
+	// 验证不panic
+}
+
+func TestOrderChannelMetrics(t *testing.T) {
+	ObserveOrderChannel("ws", "place", "ok", 12*time.Millisecond)
+	ObserveOrderChannel("rest", "cancel", "error", 80*time.Millisecond)
+	RecordOrderChannelFallback("place")
+
+	// 验证不panic
+}
+
 func TestMetricsServerStart(t *testing.T) {
 	// 测试服务器启动（不实际启动以避免端口冲突）
 	// StartMetricsServer会在后台goroutine中启动，这里只验证函数调用不panic
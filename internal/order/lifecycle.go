@@ -0,0 +1,510 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/rs/zerolog/log"
+)
+
+// OrderState 订单生命周期状态
+type OrderState string
+
+const (
+	StatePendingNew      OrderState = "PENDING_NEW"      // 下单请求已发出，尚未确认
+	StateNew             OrderState = "NEW"              // 交易所已确认挂单
+	StatePartiallyFilled OrderState = "PARTIALLY_FILLED" // 部分成交，剩余仍在挂单
+	StatePendingCancel   OrderState = "PENDING_CANCEL"   // 撤单请求已发出，尚未确认
+	StateFilled          OrderState = "FILLED"
+	StateCanceled        OrderState = "CANCELED"
+	StateRejected        OrderState = "REJECTED"
+	StateExpired         OrderState = "EXPIRED"
+)
+
+const (
+	defaultPendingTimeout    = 5 * time.Second  // 请求未确认超过该时长即与REST对账
+	defaultReconcileInterval = 30 * time.Second // 无超时订单时的兜底对账间隔
+	closedRetention          = time.Minute      // 已结束订单ID保留时长，用于忽略迟到推送
+)
+
+// Terminal 终态订单不再变化，从本地跟踪中移除
+func (s OrderState) Terminal() bool {
+	switch s {
+	case StateFilled, StateCanceled, StateRejected, StateExpired:
+		return true
+	}
+	return false
+}
+
+// Pending 请求已发出但尚未得到确认
+func (s OrderState) Pending() bool {
+	return s == StatePendingNew || s == StatePendingCancel
+}
+
+// stateFromStatus 交易所订单状态映射为生命周期状态
+func stateFromStatus(status string) (OrderState, bool) {
+	switch strings.ToUpper(status) {
+	case "NEW", "":
+		return StateNew, true
+	case "PARTIALLY_FILLED":
+		return StatePartiallyFilled, true
+	case "FILLED":
+		return StateFilled, true
+	case "CANCELED", "CANCELLED":
+		return StateCanceled, true
+	case "REJECTED":
+		return StateRejected, true
+	case "EXPIRED", "EXPIRED_IN_MATCH":
+		return StateExpired, true
+	}
+	return "", false
+}
+
+// nextState 状态迁移规则，返回迁移后的状态与事件是否生效：
+// 终态不再变化；撤单中的订单收到成交/改单推送只更新数量，状态保持撤单中；
+// 部分成交的订单收到改单推送（NEW）仍为部分成交
+func nextState(from, to OrderState) (OrderState, bool) {
+	switch {
+	case from.Terminal():
+		return from, false
+	case to.Terminal():
+		return to, true
+	case to == StatePendingNew:
+		return from, false
+	case from == StatePendingCancel && to != StatePendingCancel:
+		return from, true
+	case from == StatePartiallyFilled && to == StateNew:
+		return from, true
+	}
+	return to, true
+}
+
+// TrackedOrder 本地跟踪的订单及其生命周期状态
+type TrackedOrder struct {
+	gateway.Order
+	State     OrderState
+	PrevState OrderState // 进入撤单中之前的状态，撤单被拒时恢复
+	Since     time.Time  // 进入当前状态的时间
+	UpdatedAt time.Time  // 最近一次被事件、确认或对账更新的时间
+
+	queue queueEstimate
+}
+
+// SetClock 替换时间源（回测注入虚拟时钟）
+func (om *OrderManager) SetClock(clock func() time.Time) {
+	if clock == nil {
+		clock = time.Now
+	}
+	om.mu.Lock()
+	om.now = clock
+	om.mu.Unlock()
+}
+
+// SetReconcilePolicy 设置请求确认超时与兜底对账间隔
+func (om *OrderManager) SetReconcilePolicy(pendingTimeout, interval time.Duration) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	if pendingTimeout > 0 {
+		om.pendingTimeout = pendingTimeout
+	}
+	if interval > 0 {
+		om.reconcileInterval = interval
+	}
+}
+
+// GetOrderState 返回本地跟踪订单的状态；未跟踪（含已结束）的订单返回false
+func (om *OrderManager) GetOrderState(symbol, clientOrderID string) (OrderState, bool) {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	t, ok := om.orders[symbol][clientOrderID]
+	if !ok {
+		return "", false
+	}
+	return t.State, true
+}
+
+// ActiveOrders 返回参与报价差分的订单：已发出或已挂单、且不在撤单中的订单，按价格排序
+func (om *OrderManager) ActiveOrders(symbol string) []*gateway.Order {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	return om.activeOrdersLocked(symbol)
+}
+
+func (om *OrderManager) activeOrdersLocked(symbol string) []*gateway.Order {
+	out := make([]*gateway.Order, 0, len(om.orders[symbol]))
+	for _, t := range om.orders[symbol] {
+		if t.State == StatePendingCancel {
+			continue
+		}
+		o := t.Order
+		out = append(out, &o)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Price != out[j].Price {
+			return out[i].Price < out[j].Price
+		}
+		return out[i].ClientOrderID < out[j].ClientOrderID
+	})
+	return out
+}
+
+// OnOrderUpdate 用户数据流订单推送驱动状态迁移；未跟踪的挂单（如重启前遗留）直接纳入跟踪
+func (om *OrderManager) OnOrderUpdate(update *gateway.Order) {
+	if update == nil || update.Symbol == "" || update.ClientOrderID == "" {
+		return
+	}
+	to, ok := stateFromStatus(update.Status)
+	if !ok {
+		return
+	}
+
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	now := om.now()
+	t := om.orders[update.Symbol][update.ClientOrderID]
+	if t == nil {
+		if to.Terminal() {
+			return
+		}
+		if _, closed := om.closed[update.ClientOrderID]; closed {
+			// 已结束订单的迟到推送
+			return
+		}
+		om.trackLocked(update, to, now)
+		om.refreshStoreLocked(update.Symbol)
+		return
+	}
+
+	state, applied := nextState(t.State, to)
+	if !applied {
+		return
+	}
+	if update.Price > 0 {
+		t.Price = update.Price
+	}
+	if update.Quantity > 0 {
+		t.Quantity = update.Quantity
+	}
+	if update.FilledQty > t.FilledQty {
+		// 本订单开始成交，说明已排到队首
+		t.FilledQty = update.FilledQty
+		t.queue.ahead = 0
+	}
+	om.setStateLocked(t, state, now)
+	om.refreshStoreLocked(update.Symbol)
+}
+
+// ReconcileIfDue 首次、存在确认超时的请求或到达兜底间隔时与REST对账，否则直接使用本地状态
+func (om *OrderManager) ReconcileIfDue(ctx context.Context, symbol string) error {
+	om.mu.RLock()
+	now := om.now()
+	last, synced := om.lastReconcile[symbol]
+	due := !synced || now.Sub(last) >= om.reconcileInterval
+	for _, t := range om.orders[symbol] {
+		if due {
+			break
+		}
+		due = t.State.Pending() && now.Sub(t.Since) >= om.pendingTimeout
+	}
+	om.mu.RUnlock()
+
+	if !due {
+		return nil
+	}
+	return om.SyncActiveOrders(ctx, symbol)
+}
+
+// RequestReconcile 下一次 ReconcileIfDue 强制与REST对账（如全部撤单之后）
+func (om *OrderManager) RequestReconcile(symbol string) {
+	om.mu.Lock()
+	delete(om.lastReconcile, symbol)
+	om.mu.Unlock()
+}
+
+// SyncActiveOrders 与交易所REST挂单对账：确认超时的请求以交易所为准，未跟踪的挂单纳入跟踪，
+// 交易所已不存在的订单移除；对账期间有更新的订单以本地状态为准
+func (om *OrderManager) SyncActiveOrders(ctx context.Context, symbol string) error {
+	om.mu.RLock()
+	start := om.now()
+	om.mu.RUnlock()
+
+	orders, err := om.exchange.GetOpenOrders(ctx, symbol)
+	if err != nil {
+		return fmt.Errorf("获取当前持有订单失败: %w", err)
+	}
+
+	om.mu.Lock()
+	defer om.mu.Unlock()
+
+	now := om.now()
+	tracked := om.orders[symbol]
+	seen := make(map[string]bool, len(orders))
+	adopted, resolved, dropped := 0, 0, 0
+	for _, o := range orders {
+		if o == nil || o.ClientOrderID == "" {
+			continue
+		}
+		seen[o.ClientOrderID] = true
+		live := StateNew
+		if o.FilledQty > 0 {
+			live = StatePartiallyFilled
+		}
+		t := tracked[o.ClientOrderID]
+		if t == nil {
+			if at, closed := om.closed[o.ClientOrderID]; closed && !at.Before(start) {
+				continue
+			}
+			delete(om.closed, o.ClientOrderID)
+			adopt := *o
+			adopt.Symbol = symbol
+			om.trackLocked(&adopt, live, now)
+			tracked = om.orders[symbol]
+			adopted++
+			continue
+		}
+		if t.UpdatedAt.After(start) {
+			continue
+		}
+		t.Price, t.Quantity = o.Price, o.Quantity
+		if o.FilledQty > t.FilledQty {
+			t.FilledQty = o.FilledQty
+		}
+		switch {
+		case t.State == StatePendingNew:
+			om.setStateLocked(t, live, now)
+			resolved++
+		case t.State == StatePendingCancel:
+			// 撤单迟迟未确认且订单仍在挂单：恢复为挂单，由下一轮差分重新撤单
+			if now.Sub(t.Since) >= om.pendingTimeout {
+				om.setStateLocked(t, live, now)
+				resolved++
+			}
+		case t.State != live:
+			om.setStateLocked(t, live, now)
+		}
+	}
+
+	for id, t := range tracked {
+		if seen[id] || t.UpdatedAt.After(start) {
+			continue
+		}
+		if t.State == StatePendingNew && now.Sub(t.Since) < om.pendingTimeout {
+			// 下单请求可能尚未到达交易所
+			continue
+		}
+		log.Warn().
+			Str("symbol", symbol).
+			Str("order_id", id).
+			Str("state", string(t.State)).
+			Msg("对账：订单已不在交易所挂单中，移除本地跟踪")
+		om.setStateLocked(t, StateCanceled, now)
+		dropped++
+	}
+
+	for id, at := range om.closed {
+		if now.Sub(at) > closedRetention {
+			delete(om.closed, id)
+		}
+	}
+	om.lastReconcile[symbol] = now
+	om.refreshStoreLocked(symbol)
+
+	log.Info().
+		Str("symbol", symbol).
+		Int("active_orders", len(om.orders[symbol])).
+		Int("adopted", adopted).
+		Int("resolved", resolved).
+		Int("dropped", dropped).
+		Msg("同步活跃订单")
+
+	return nil
+}
+
+// trackLocked 纳入跟踪一笔订单
+func (om *OrderManager) trackLocked(o *gateway.Order, state OrderState, now time.Time) *TrackedOrder {
+	if om.orders[o.Symbol] == nil {
+		om.orders[o.Symbol] = make(map[string]*TrackedOrder)
+	}
+	t := &TrackedOrder{Order: *o, State: state, Since: now, UpdatedAt: now}
+	t.Status = string(state)
+	om.orders[o.Symbol][o.ClientOrderID] = t
+	return t
+}
+
+// setStateLocked 迁移状态；进入终态时移除跟踪并记录结束时间
+func (om *OrderManager) setStateLocked(t *TrackedOrder, state OrderState, now time.Time) {
+	if state != t.State {
+		if state == StatePendingCancel {
+			t.PrevState = t.State
+		}
+		t.State = state
+		t.Since = now
+		t.Status = string(state)
+	}
+	t.UpdatedAt = now
+	if state.Terminal() {
+		delete(om.orders[t.Symbol], t.ClientOrderID)
+		om.closed[t.ClientOrderID] = now
+	}
+}
+
+// refreshStoreLocked 按本地跟踪的订单更新挂单量与活跃订单数
+func (om *OrderManager) refreshStoreLocked(symbol string) {
+	var buyAmt, sellAmt float64
+	for _, t := range om.orders[symbol] {
+		remaining := t.Quantity - t.FilledQty
+		if t.Side == "BUY" {
+			buyAmt += remaining
+		} else if t.Side == "SELL" {
+			sellAmt += remaining
+		}
+	}
+	om.store.UpdatePendingOrders(symbol, buyAmt, sellAmt)
+	om.store.SetActiveOrderCount(symbol, len(om.orders[symbol]))
+}
+
+// beginPlace 下单前分配ClientOrderID并登记为待确认，返回登记的ID（交易所实现可能改写请求中的ID）
+func (om *OrderManager) beginPlace(symbol string, orders []*gateway.Order) []string {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	now := om.now()
+	ids := make([]string, len(orders))
+	for i, o := range orders {
+		if o.Symbol == "" {
+			o.Symbol = symbol
+		}
+		if o.ClientOrderID == "" {
+			o.ClientOrderID = gateway.NextClientOrderID(&om.clientSeq, o.Symbol)
+		}
+		om.trackLocked(o, StatePendingNew, now)
+		ids[i] = o.ClientOrderID
+	}
+	om.refreshStoreLocked(symbol)
+	return ids
+}
+
+// ackPlace 处理下单结果：成功转为挂单（交易所返回的ClientOrderID不同则改用其作为键），
+// 交易所明确拒绝转为已拒绝，未得到答复（超时/断线）保持待确认，等待对账
+func (om *OrderManager) ackPlace(symbol, id string, placed *gateway.Order, err error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	now := om.now()
+	t := om.orders[symbol][id]
+
+	if err != nil {
+		if t != nil && isExchangeReply(err) {
+			om.setStateLocked(t, StateRejected, now)
+		}
+		om.refreshStoreLocked(symbol)
+		return
+	}
+
+	if placed != nil && placed.ClientOrderID != "" && placed.ClientOrderID != id {
+		if t != nil {
+			delete(om.orders[symbol], id)
+		}
+		if existing := om.orders[symbol][placed.ClientOrderID]; existing != nil {
+			// 用户数据流推送先于下单确认到达，已纳入跟踪
+			om.refreshStoreLocked(symbol)
+			return
+		}
+		if _, closed := om.closed[placed.ClientOrderID]; closed {
+			om.refreshStoreLocked(symbol)
+			return
+		}
+		ack := *placed
+		ack.Symbol = symbol
+		t = om.trackLocked(&ack, StatePendingNew, now)
+	}
+	if t == nil {
+		// 确认到达前订单已结束（如推送显示已成交）
+		om.refreshStoreLocked(symbol)
+		return
+	}
+	to := StateNew
+	if placed != nil {
+		if s, ok := stateFromStatus(placed.Status); ok {
+			to = s
+		}
+	}
+	if state, applied := nextState(t.State, to); applied {
+		om.setStateLocked(t, state, now)
+	}
+	om.refreshStoreLocked(symbol)
+}
+
+// beginCancel 撤单前将订单标记为撤单中，返回标记前的订单（用于统计方向）
+func (om *OrderManager) beginCancel(symbol string, ids []string) map[string]gateway.Order {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	now := om.now()
+	before := make(map[string]gateway.Order, len(ids))
+	for _, id := range ids {
+		t := om.orders[symbol][id]
+		if t == nil {
+			continue
+		}
+		before[id] = t.Order
+		if state, applied := nextState(t.State, StatePendingCancel); applied {
+			om.setStateLocked(t, state, now)
+		}
+	}
+	om.refreshStoreLocked(symbol)
+	return before
+}
+
+// ackCancel 处理撤单结果：成功或订单已不存在则结束；交易所拒绝撤单恢复原状态；
+// 未得到答复保持撤单中，等待对账
+func (om *OrderManager) ackCancel(symbol, id string, err error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	now := om.now()
+	t := om.orders[symbol][id]
+	if t == nil {
+		return
+	}
+	switch {
+	case err == nil || isOrderGone(err):
+		om.setStateLocked(t, StateCanceled, now)
+	case isExchangeReply(err) && t.State == StatePendingCancel:
+		prev := t.PrevState
+		if prev == "" || prev.Terminal() {
+			prev = StateNew
+		}
+		om.setStateLocked(t, prev, now)
+	}
+	om.refreshStoreLocked(symbol)
+}
+
+// ackAmend 改价成功后更新本地订单价格与数量；订单已不存在时结束跟踪
+func (om *OrderManager) ackAmend(symbol string, amended *gateway.Order, err error) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	now := om.now()
+	t := om.orders[symbol][amended.ClientOrderID]
+	if t == nil {
+		return
+	}
+	switch {
+	case err == nil:
+		// 改价后在新价位重新排队
+		t.Price = amended.Price
+		t.Quantity = amended.Quantity
+		t.UpdatedAt = now
+		t.queue = queueEstimate{}
+	case isOrderGone(err):
+		om.setStateLocked(t, StateCanceled, now)
+	}
+	om.refreshStoreLocked(symbol)
+}
+
+// isExchangeReply 交易所明确答复的错误（区别于超时、断线等结果未知的错误）
+func isExchangeReply(err error) bool {
+	_, ok := gateway.ErrorCode(err)
+	return ok
+}
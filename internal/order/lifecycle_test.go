@@ -0,0 +1,191 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+)
+
+// fakeClock 可手动推进的时间源
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.t }
+func (c *fakeClock) Advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newLifecycleManager(ex *mockExchange) (*OrderManager, *store.Store, *fakeClock) {
+	st := store.NewStore("", time.Minute)
+	st.InitSymbol("BTCUSDT", 10)
+	om := NewOrderManager(st, ex)
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	om.SetClock(clock.Now)
+	return om, st, clock
+}
+
+func assertState(t *testing.T, om *OrderManager, id string, want OrderState) {
+	t.Helper()
+	got, ok := om.GetOrderState("BTCUSDT", id)
+	if want == "" {
+		if ok {
+			t.Fatalf("%s: 期望已移除跟踪，实际%s", id, got)
+		}
+		return
+	}
+	if !ok || got != want {
+		t.Fatalf("%s: 期望状态%s，实际%s(tracked=%v)", id, want, got, ok)
+	}
+}
+
+func TestNextState(t *testing.T) {
+	cases := []struct {
+		from, to, want OrderState
+		applied        bool
+	}{
+		{StatePendingNew, StateNew, StateNew, true},
+		{StateNew, StatePartiallyFilled, StatePartiallyFilled, true},
+		{StatePartiallyFilled, StateNew, StatePartiallyFilled, true},         // 部分成交后改价推送
+		{StatePendingCancel, StatePartiallyFilled, StatePendingCancel, true}, // 撤单途中成交
+		{StatePendingCancel, StateCanceled, StateCanceled, true},
+		{StateNew, StatePendingNew, StateNew, false},
+		{StateFilled, StateNew, StateFilled, false}, // 终态不再变化
+	}
+	for _, tc := range cases {
+		got, applied := nextState(tc.from, tc.to)
+		if got != tc.want || applied != tc.applied {
+			t.Errorf("%s -> %s: 期望%s/%v，实际%s/%v", tc.from, tc.to, tc.want, tc.applied, got, applied)
+		}
+	}
+}
+
+// TestLifecycle_EventsDriveState 下单确认与订单推送驱动状态迁移，终态后迟到的推送被忽略
+func TestLifecycle_EventsDriveState(t *testing.T) {
+	om, st, _ := newLifecycleManager(&mockExchange{})
+
+	om.beginPlace("BTCUSDT", []*gateway.Order{{ClientOrderID: "c1", Side: "BUY", Price: 100, Quantity: 2}})
+	assertState(t, om, "c1", StatePendingNew)
+	if n := len(om.ActiveOrders("BTCUSDT")); n != 1 {
+		t.Fatalf("待确认订单应参与差分，实际%d", n)
+	}
+
+	// 推送先于下单确认到达
+	om.OnOrderUpdate(&gateway.Order{ClientOrderID: "c1", Symbol: "BTCUSDT", Side: "BUY", Status: "NEW", Price: 100, Quantity: 2})
+	assertState(t, om, "c1", StateNew)
+	om.ackPlace("BTCUSDT", "c1", &gateway.Order{ClientOrderID: "c1", Status: "NEW"}, nil)
+	assertState(t, om, "c1", StateNew)
+
+	om.OnOrderUpdate(&gateway.Order{ClientOrderID: "c1", Symbol: "BTCUSDT", Side: "BUY", Status: "PARTIALLY_FILLED", Price: 100, Quantity: 2, FilledQty: 0.5})
+	assertState(t, om, "c1", StatePartiallyFilled)
+	if got := st.GetSymbolState("BTCUSDT").PendingBuy; got != 1.5 {
+		t.Fatalf("PendingBuy 期望1.5，实际%v", got)
+	}
+
+	om.OnOrderUpdate(&gateway.Order{ClientOrderID: "c1", Symbol: "BTCUSDT", Side: "BUY", Status: "FILLED", Price: 100, Quantity: 2, FilledQty: 2})
+	assertState(t, om, "c1", "")
+	om.OnOrderUpdate(&gateway.Order{ClientOrderID: "c1", Symbol: "BTCUSDT", Side: "BUY", Status: "PARTIALLY_FILLED", Price: 100, Quantity: 2, FilledQty: 0.5})
+	assertState(t, om, "c1", "")
+	if got := st.GetActiveOrderCount("BTCUSDT"); got != 0 {
+		t.Fatalf("活跃订单数期望0，实际%d", got)
+	}
+
+	// 未跟踪的挂单推送（如重启前遗留）纳入跟踪
+	om.OnOrderUpdate(&gateway.Order{ClientOrderID: "x1", Symbol: "BTCUSDT", Side: "SELL", Status: "NEW", Price: 101, Quantity: 1})
+	assertState(t, om, "x1", StateNew)
+}
+
+// TestLifecycle_PlaceAndCancelAcks 下单/撤单确认：交易所拒绝与结果未知分别处理
+func TestLifecycle_PlaceAndCancelAcks(t *testing.T) {
+	mockEx := &mockExchange{
+		cancelErrs: map[string]error{
+			"c2": &gateway.ExchangeError{Code: -1001, Message: "Internal error"},
+			"c3": errors.New("websocket closed"),
+		},
+		rejectPrices: map[float64]error{
+			99:  &gateway.ExchangeError{Code: -4164, Message: "Order's notional must be no smaller than 5"},
+			98:  errors.New("context deadline exceeded"),
+			103: &gateway.ExchangeError{Code: -5022, Message: "Post Only order will be rejected"},
+		},
+	}
+	om, _, _ := newLifecycleManager(mockEx)
+	setTrackedOrders(om, "BTCUSDT", []*gateway.Order{
+		{ClientOrderID: "c1", Side: "SELL", Price: 101, Quantity: 1},
+		{ClientOrderID: "c2", Side: "SELL", Price: 102, Quantity: 1},
+		{ClientOrderID: "c3", Side: "SELL", Price: 104, Quantity: 1},
+	})
+
+	toPlace := []*gateway.Order{
+		{ClientOrderID: "p1", Symbol: "BTCUSDT", Side: "BUY", Price: 99, Quantity: 1},
+		{ClientOrderID: "p2", Symbol: "BTCUSDT", Side: "BUY", Price: 98, Quantity: 1},
+		{ClientOrderID: "p3", Symbol: "BTCUSDT", Side: "SELL", Price: 103, Quantity: 1},
+	}
+	if err := om.ApplyDiff(context.Background(), "BTCUSDT", []string{"c1", "c2", "c3"}, nil, toPlace); err != nil {
+		t.Fatalf("ApplyDiff错误:%v", err)
+	}
+
+	assertState(t, om, "c1", "")                 // 撤单成功
+	assertState(t, om, "c2", StateNew)           // 交易所拒绝撤单，恢复原状态
+	assertState(t, om, "c3", StatePendingCancel) // 结果未知，等待对账
+	assertState(t, om, "p1", "")                 // 交易所拒绝下单
+	assertState(t, om, "p2", StatePendingNew)    // 结果未知，等待对账
+	assertState(t, om, "p3", "")                 // Post Only 被拒
+
+	for _, o := range om.ActiveOrders("BTCUSDT") {
+		if o.ClientOrderID == "c3" {
+			t.Fatal("撤单中的订单不应参与差分")
+		}
+	}
+
+	// 交易所返回不同的ClientOrderID时改用其作为键
+	mockEx.rejectPrices = nil
+	if err := om.ApplyDiff(context.Background(), "BTCUSDT", nil, nil, []*gateway.Order{{ClientOrderID: "p4", Symbol: "BTCUSDT", Side: "BUY", Price: 97, Quantity: 1}}); err != nil {
+		t.Fatalf("ApplyDiff错误:%v", err)
+	}
+	assertState(t, om, "p4", "")
+	assertState(t, om, "order123", StateNew)
+}
+
+// TestLifecycle_ReconcileOnTimeout 只有请求确认超时或到达兜底间隔时才与REST对账
+func TestLifecycle_ReconcileOnTimeout(t *testing.T) {
+	mockEx := &mockExchange{openOrders: map[string][]*gateway.Order{"BTCUSDT": {}}}
+	om, _, clock := newLifecycleManager(mockEx)
+	ctx := context.Background()
+
+	if err := om.ReconcileIfDue(ctx, "BTCUSDT"); err != nil || mockEx.openOrdersCalls != 1 {
+		t.Fatalf("首次应与REST对账，实际%d次 err=%v", mockEx.openOrdersCalls, err)
+	}
+
+	om.beginPlace("BTCUSDT", []*gateway.Order{
+		{ClientOrderID: "p1", Side: "BUY", Price: 99, Quantity: 1},
+		{ClientOrderID: "p2", Side: "BUY", Price: 98, Quantity: 1},
+	})
+	clock.Advance(time.Second)
+	_ = om.ReconcileIfDue(ctx, "BTCUSDT")
+	if mockEx.openOrdersCalls != 1 {
+		t.Fatalf("未超时不应对账，实际%d次", mockEx.openOrdersCalls)
+	}
+
+	// 超时后对账：p1 已挂单，p2 未到达交易所
+	mockEx.openOrders["BTCUSDT"] = []*gateway.Order{{ClientOrderID: "p1", Side: "BUY", Price: 99, Quantity: 1}}
+	clock.Advance(defaultPendingTimeout)
+	if err := om.ReconcileIfDue(ctx, "BTCUSDT"); err != nil || mockEx.openOrdersCalls != 2 {
+		t.Fatalf("超时应对账，实际%d次 err=%v", mockEx.openOrdersCalls, err)
+	}
+	assertState(t, om, "p1", StateNew)
+	assertState(t, om, "p2", "")
+
+	// 撤单迟迟未确认而订单仍在挂单：恢复为挂单
+	om.beginCancel("BTCUSDT", []string{"p1"})
+	clock.Advance(defaultPendingTimeout)
+	_ = om.ReconcileIfDue(ctx, "BTCUSDT")
+	assertState(t, om, "p1", StateNew)
+
+	_ = om.ReconcileIfDue(ctx, "BTCUSDT")
+	calls := mockEx.openOrdersCalls
+	clock.Advance(defaultReconcileInterval)
+	_ = om.ReconcileIfDue(ctx, "BTCUSDT")
+	if mockEx.openOrdersCalls != calls+1 {
+		t.Fatalf("到达兜底间隔应对账，实际%d次", mockEx.openOrdersCalls-calls)
+	}
+}
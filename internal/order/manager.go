@@ -2,11 +2,12 @@ package order
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
 	"sort"
-	"strings"
 	"sync"
+	"time"
 
 	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
 	"github.com/newplayman/market-maker-phoenix/internal/store"
@@ -19,17 +20,31 @@ type OrderManager struct {
 	store    *store.Store
 	exchange gateway.Exchange
 
-	activeOrders     map[string][]*gateway.Order // symbol -> orders
-	maxOrdersPerSide map[string]int              // symbol -> max orders per side
+	orders           map[string]map[string]*TrackedOrder // symbol -> ClientOrderID -> 订单
+	closed           map[string]time.Time                // 已结束订单ID -> 结束时间
+	lastReconcile    map[string]time.Time                // symbol -> 上次REST对账时间
+	maxOrdersPerSide map[string]int                      // symbol -> max orders per side
+	queueBoost       map[string]float64                  // symbol -> 队列位置保留系数
+
+	pendingTimeout    time.Duration
+	reconcileInterval time.Duration
+	now               func() time.Time
+	clientSeq         uint64
 }
 
 // NewOrderManager 创建OrderManager实例
 func NewOrderManager(store *store.Store, exch gateway.Exchange) *OrderManager {
 	return &OrderManager{
-		store:            store,
-		exchange:         exch,
-		activeOrders:     make(map[string][]*gateway.Order),
-		maxOrdersPerSide: make(map[string]int),
+		store:             store,
+		exchange:          exch,
+		orders:            make(map[string]map[string]*TrackedOrder),
+		closed:            make(map[string]time.Time),
+		lastReconcile:     make(map[string]time.Time),
+		maxOrdersPerSide:  make(map[string]int),
+		queueBoost:        make(map[string]float64),
+		pendingTimeout:    defaultPendingTimeout,
+		reconcileInterval: defaultReconcileInterval,
+		now:               time.Now,
 	}
 }
 
@@ -51,61 +66,38 @@ func (om *OrderManager) GetMaxOrdersPerSide(symbol string) int {
 	return 18 // 默认值
 }
 
-// SyncActiveOrders 同步当前symbol的活跃订单，从exchange拉取并本地更新
-func (om *OrderManager) SyncActiveOrders(ctx context.Context, symbol string) error {
-	orders, err := om.exchange.GetOpenOrders(ctx, symbol)
-	if err != nil {
-		return fmt.Errorf("获取当前持有订单失败: %w", err)
-	}
-
-	om.mu.Lock()
-	defer om.mu.Unlock()
-
-	om.activeOrders[symbol] = orders
-
-	// 更新挂单量统计，方便store使用
-	var buyAmt, sellAmt float64
-	for _, order := range orders {
-		if order.Side == "BUY" {
-			buyAmt += order.Quantity - order.FilledQty
-		} else if order.Side == "SELL" {
-			sellAmt += order.Quantity - order.FilledQty
-		}
-	}
-
-	om.store.UpdatePendingOrders(symbol, buyAmt, sellAmt)
-
-	// 更新实际活跃订单数量
-	om.store.SetActiveOrderCount(symbol, len(orders))
-
-	log.Info().
-		Str("symbol", symbol).
-		Int("active_orders", len(orders)).
-		Float64("pending_buy", buyAmt).
-		Float64("pending_sell", sellAmt).
-		Msg("同步活跃订单")
-
-	return nil
-}
-
-// CalculateOrderDiff 根据期望待挂单和当前活跃订单计算差异，返回待撤销订单ID列表和待下新订单切片
+// CalculateOrderDiff 根据期望待挂单和本地跟踪的活跃订单计算差异，返回待撤销订单ID列表、待改价订单和待下新订单切片。
+// 同一方向、同一层（按靠近盘口排序后的下标）的订单若只有价格变化，则输出改价而不是撤单重挂；
+// 改价订单沿用当前订单的ClientOrderID。设置了队列位置保留系数时，排队越靠前的订单保留容差越大，
+// 避免为微小的价格改善放弃队列优先级
 func (om *OrderManager) CalculateOrderDiff(
 	symbol string,
 	desiredBuyQuotes []*gateway.Order,
 	desiredSellQuotes []*gateway.Order,
 	tolerance float64,
-) (toCancel []string, toPlace []*gateway.Order) {
+) (toCancel []string, toAmend []*gateway.Order, toPlace []*gateway.Order) {
 	om.mu.RLock()
 	defer om.mu.RUnlock()
 
-	currentOrders := om.activeOrders[symbol]
+	currentOrders := om.activeOrdersLocked(symbol)
+
+	// 各订单的保留容差
+	boost := om.queueBoost[symbol]
+	orderTolerance := func(id string) float64 {
+		t := om.orders[symbol][id]
+		if boost <= 0 || t == nil {
+			return tolerance
+		}
+		return tolerance * (1 + boost*t.queuePriority())
+	}
 
 	// 辅助函数：处理单边订单
 	processSide := func(current []*gateway.Order, desired []*gateway.Order) {
 		usedDesired := make([]bool, len(desired))
+		usedCurrent := make([]bool, len(current))
 
-		for _, curr := range current {
-			matched := false
+		for ci, curr := range current {
+			currTolerance := orderTolerance(curr.ClientOrderID)
 			// 寻找最匹配的期望订单
 			// 由于网格策略通常价格有序，这里简单遍历即可。
 			// 如果有性能问题，可以先排序。但考虑到订单数较少（几十个），遍历很快。
@@ -116,8 +108,8 @@ func (om *OrderManager) CalculateOrderDiff(
 				// 检查价格差异是否在容差范围内 (Hysteresis)
 				// 如果 |curr - des| <= tolerance，则认为匹配，不需要移动订单
 				diff := math.Abs(curr.Price - des.Price)
-				if diff <= tolerance+1e-9 {
-					matched = true
+				if diff <= currTolerance+1e-9 {
+					usedCurrent[ci] = true
 					usedDesired[i] = true
 
 					// 价格匹配，检查数量
@@ -137,18 +129,41 @@ func (om *OrderManager) CalculateOrderDiff(
 							Float64("curr_price", curr.Price).
 							Float64("des_price", des.Price).
 							Float64("diff", diff).
-							Float64("tolerance", tolerance).
+							Float64("tolerance", currTolerance).
 							Msg("订单匹配，保持不变")
 					}
 					// 否则：完全匹配（价格在容差内，数量一致），保留订单，不做任何操作
 					break
-				} else {
-					// Log mismatch for debugging (optional, might be too noisy)
-					// log.Trace().Float64("diff", diff).Float64("tol", tolerance).Msg("价格差异过大")
 				}
 			}
-			if !matched {
-				// 当前订单在期望列表中找不到匹配项（价格偏离过大或不再需要） -> 撤销
+		}
+
+		// 同一层未匹配的订单：数量一致时只是价格移动，改价保留ClientOrderID
+		for i := 0; i < len(current) && i < len(desired); i++ {
+			if usedCurrent[i] || usedDesired[i] {
+				continue
+			}
+			curr, des := current[i], desired[i]
+			if math.Abs(curr.Quantity-des.Quantity) > 1e-8 {
+				continue
+			}
+			usedCurrent[i] = true
+			usedDesired[i] = true
+			amend := *des
+			amend.Symbol = curr.Symbol
+			amend.ClientOrderID = curr.ClientOrderID
+			log.Debug().
+				Str("id", curr.ClientOrderID).
+				Int("layer", i).
+				Float64("curr_price", curr.Price).
+				Float64("des_price", des.Price).
+				Msg("仅价格变化，改价")
+			toAmend = append(toAmend, &amend)
+		}
+
+		// 当前订单在期望列表中找不到匹配项（价格偏离过大或不再需要） -> 撤销
+		for ci, curr := range current {
+			if !usedCurrent[ci] {
 				toCancel = append(toCancel, curr.ClientOrderID)
 			}
 		}
@@ -194,7 +209,7 @@ func (om *OrderManager) CalculateOrderDiff(
 	processSide(currBuy, desiredBuyQuotes)
 	processSide(currSell, desiredSellQuotes)
 
-	return toCancel, toPlace
+	return toCancel, toAmend, toPlace
 }
 
 // ordersMatch 判断两个订单是否匹配（价格和数量都相同）
@@ -221,79 +236,130 @@ func ordersMatch(existing, desired *gateway.Order, tolerance float64) bool {
 	return true
 }
 
-// removeActiveOrder 从本地活跃订单列表中移除指定订单
-func (om *OrderManager) removeActiveOrder(symbol, orderID string) {
-	om.mu.Lock()
-	defer om.mu.Unlock()
+// isOrderGone 撤单返回-2011、改单返回-2013，均表示订单已不在挂单中
+func isOrderGone(err error) bool {
+	return errors.Is(err, gateway.ErrUnknownOrder)
+}
 
-	orders := om.activeOrders[symbol]
-	newOrders := make([]*gateway.Order, 0, len(orders))
-	for _, o := range orders {
-		if o.ClientOrderID != orderID {
-			newOrders = append(newOrders, o)
-		}
-	}
-	om.activeOrders[symbol] = newOrders
+// isSystemicOrderError 限频与保证金不足影响整组请求，上报给Runner处理
+func isSystemicOrderError(err error) bool {
+	return errors.Is(err, gateway.ErrRateLimited) || errors.Is(err, gateway.ErrInsufficientMargin)
 }
 
-// ApplyDiff 应用订单差分，执行撤单和新单下单
-func (om *OrderManager) ApplyDiff(ctx context.Context, symbol string, toCancel []string, toPlace []*gateway.Order) error {
+// ApplyDiff 应用订单差分，依次执行撤单、改价和新单下单
+func (om *OrderManager) ApplyDiff(ctx context.Context, symbol string, toCancel []string, toAmend []*gateway.Order, toPlace []*gateway.Order) error {
 	// 【关键修复】限制下单数量，防止订单爆炸
 	maxPerSide := om.GetMaxOrdersPerSide(symbol)
 
-	// 统计当前活跃订单数
-	om.mu.RLock()
-	currentOrders := om.activeOrders[symbol]
+	// 统计当前活跃订单数（不含撤单中的订单）
 	var currentBuyCount, currentSellCount int
-	for _, o := range currentOrders {
+	for _, o := range om.ActiveOrders(symbol) {
 		if o.Side == "BUY" {
 			currentBuyCount++
 		} else {
 			currentSellCount++
 		}
 	}
-	om.mu.RUnlock()
 
-	// 计算撤单后的订单数
+	// 撤单前标记为撤单中，并计算撤单后的订单数
 	canceledBuy, canceledSell := 0, 0
-	for _, orderID := range toCancel {
-		om.mu.RLock()
-		for _, o := range currentOrders {
-			if o.ClientOrderID == orderID {
-				if o.Side == "BUY" {
-					canceledBuy++
-				} else {
-					canceledSell++
-				}
-				break
-			}
+	for _, o := range om.beginCancel(symbol, toCancel) {
+		if o.Side == "BUY" {
+			canceledBuy++
+		} else {
+			canceledSell++
 		}
-		om.mu.RUnlock()
 	}
 
 	afterCancelBuy := currentBuyCount - canceledBuy
 	afterCancelSell := currentSellCount - canceledSell
 
-	// 撤单
+	// systemErr 记录首个限频/保证金不足错误，逐单处理完后返回给调用方
+	var systemErr error
+
+	// 撤单：整组交给交易所批量撤单，逐个处理结果
 	cancelSuccess := 0
-	for _, orderID := range toCancel {
-		if err := om.exchange.CancelOrder(ctx, symbol, orderID); err != nil {
+	var cancelErrs []error
+	if len(toCancel) > 0 {
+		cancelErrs = om.exchange.CancelOrders(ctx, symbol, toCancel)
+	}
+	for i, orderID := range toCancel {
+		om.ackCancel(symbol, orderID, cancelErrs[i])
+		if err := cancelErrs[i]; err != nil {
 			// 如果是订单不存在错误，视为撤单成功（实际上是清理僵尸订单）
-			if strings.Contains(err.Error(), "Unknown order") || strings.Contains(err.Error(), "-2011") {
+			if isOrderGone(err) {
 				log.Warn().Str("order_id", orderID).Msg("订单不存在，从本地状态移除")
-				om.removeActiveOrder(symbol, orderID)
+				om.store.RecordOrderCanceled(symbol, orderID)
 				// 不增加 cancelCount，因为这不是一次有效的撤单消耗
 			} else {
-				log.Error().Err(err).Str("order_id", orderID).Msg("撤单失败")
+				if systemErr == nil && isSystemicOrderError(err) {
+					systemErr = err
+				}
+				log.Error().Err(err).Str("order_id", orderID).Str("category", string(gateway.CategoryOf(err))).Msg("撤单失败")
 			}
 		} else {
 			// 【关键修复】撤单成功后调用计数器
 			om.store.IncrementCancelCount(symbol)
+			om.store.RecordOrderCanceled(symbol, orderID)
 			cancelSuccess++
 			log.Info().Str("order_id", orderID).Msg("撤单成功")
 		}
 	}
 
+	// 改价：整组交给交易所批量改单，改价不计入撤单数
+	amendSuccess := 0
+	var amendResults []gateway.OrderResult
+	if len(toAmend) > 0 {
+		amendResults = om.exchange.AmendOrders(ctx, toAmend)
+	}
+	for i, order := range toAmend {
+		err := amendResults[i].Err
+		om.ackAmend(symbol, order, err)
+		switch {
+		case err == nil:
+			om.store.IncrementAmendCount(symbol)
+			om.store.RecordOrderAmended(symbol, store.OrderEvent{
+				ClientOrderID: order.ClientOrderID,
+				Side:          order.Side,
+				Price:         order.Price,
+				Quantity:      order.Quantity,
+			})
+			amendSuccess++
+			log.Info().
+				Str("order_id", order.ClientOrderID).
+				Float64("price", order.Price).
+				Msg("改价成功")
+		case isOrderGone(err):
+			// 订单已成交或被撤，从本地移除并按期望价格重新下单补齐该层
+			log.Warn().Str("order_id", order.ClientOrderID).Msg("改价订单不存在，从本地状态移除并重新下单")
+			om.store.RecordOrderCanceled(symbol, order.ClientOrderID)
+			if order.Side == "BUY" {
+				afterCancelBuy--
+			} else {
+				afterCancelSell--
+			}
+			replacement := *order
+			replacement.ClientOrderID = ""
+			toPlace = append(toPlace, &replacement)
+		case errors.Is(err, gateway.ErrPostOnlyReject):
+			// 改价后会成为taker，保留原订单，下个周期重新计算
+			log.Debug().
+				Str("order_id", order.ClientOrderID).
+				Float64("price", order.Price).
+				Msg("改价会立即成交，保留原订单")
+		default:
+			if systemErr == nil && isSystemicOrderError(err) {
+				systemErr = err
+			}
+			log.Error().
+				Err(err).
+				Str("order_id", order.ClientOrderID).
+				Float64("price", order.Price).
+				Str("category", string(gateway.CategoryOf(err))).
+				Msg("改价失败")
+		}
+	}
+
 	// 分离买单和卖单
 	var buyOrders, sellOrders []*gateway.Order
 	for _, order := range toPlace {
@@ -338,19 +404,47 @@ func (om *OrderManager) ApplyDiff(ctx context.Context, symbol string, toCancel [
 	// 合并订单列表
 	limitedOrders := append(buyOrders, sellOrders...)
 
-	// 下单
+	// 下单：先登记为待确认，再整组交给交易所批量下单，部分失败逐个处理
 	placeSuccess := 0
-	for _, order := range limitedOrders {
-		if _, err := om.exchange.PlaceOrder(ctx, order); err != nil {
+	var placeResults []gateway.OrderResult
+	var placeIDs []string
+	if len(limitedOrders) > 0 {
+		placeIDs = om.beginPlace(symbol, limitedOrders)
+		placeResults = om.exchange.PlaceOrders(ctx, limitedOrders)
+	}
+	for i, order := range limitedOrders {
+		placed, err := placeResults[i].Order, placeResults[i].Err
+		om.ackPlace(symbol, placeIDs[i], placed, err)
+		if errors.Is(err, gateway.ErrPostOnlyReject) {
+			// 价格已穿过盘口，Post Only被拒属正常情况，下个周期按新盘口重新报价
+			log.Debug().
+				Str("symbol", symbol).
+				Str("side", order.Side).
+				Float64("price", order.Price).
+				Msg("Post Only下单被拒")
+		} else if err != nil {
+			if systemErr == nil && isSystemicOrderError(err) {
+				systemErr = err
+			}
 			log.Error().
 				Err(err).
 				Str("symbol", symbol).
 				Str("side", order.Side).
 				Float64("price", order.Price).
 				Float64("qty", order.Quantity).
+				Str("category", string(gateway.CategoryOf(err))).
 				Msg("下单失败")
 		} else {
 			placeSuccess++
+			if placed == nil {
+				placed = order
+			}
+			om.store.RecordOrderPlaced(symbol, store.OrderEvent{
+				ClientOrderID: placed.ClientOrderID,
+				Side:          placed.Side,
+				Price:         placed.Price,
+				Quantity:      placed.Quantity,
+			})
 			log.Info().
 				Str("symbol", symbol).
 				Str("side", order.Side).
@@ -364,10 +458,15 @@ func (om *OrderManager) ApplyDiff(ctx context.Context, symbol string, toCancel [
 		Str("symbol", symbol).
 		Int("cancel_requested", len(toCancel)).
 		Int("cancel_success", cancelSuccess).
+		Int("amend_requested", len(toAmend)).
+		Int("amend_success", amendSuccess).
 		Int("place_requested", len(toPlace)).
 		Int("place_limited", len(limitedOrders)).
 		Int("place_success", placeSuccess).
 		Msg("订单差分应用完成")
 
+	if systemErr != nil {
+		return fmt.Errorf("订单请求受限: %w", systemErr)
+	}
 	return nil
 }
@@ -17,9 +17,20 @@ type mockExchange struct {
 	placeCalls  []*gateway.Order
 	failCancel  bool
 	failPlace   bool
+
+	batchCancelCalls int
+	batchPlaceCalls  int
+	cancelErrs       map[string]error  // clientOrderID -> 撤单错误
+	rejectPrices     map[float64]error // 价格 -> 下单错误
+
+	amendCalls []*gateway.Order
+	amendErrs  map[string]error // clientOrderID -> 改单错误
+
+	openOrdersCalls int
 }
 
 func (m *mockExchange) GetOpenOrders(ctx context.Context, symbol string) ([]*gateway.Order, error) {
+	m.openOrdersCalls++
 	if orders, ok := m.openOrders[symbol]; ok {
 		return orders, nil
 	}
@@ -43,6 +54,40 @@ func (m *mockExchange) PlaceOrder(ctx context.Context, order *gateway.Order) (*g
 	return order, nil
 }
 
+func (m *mockExchange) PlaceOrders(ctx context.Context, orders []*gateway.Order) []gateway.OrderResult {
+	m.batchPlaceCalls++
+	return gateway.PlaceOrdersEach(ctx, orders, func(ctx context.Context, o *gateway.Order) (*gateway.Order, error) {
+		if err := m.rejectPrices[o.Price]; err != nil {
+			m.placeCalls = append(m.placeCalls, o)
+			return nil, err
+		}
+		return m.PlaceOrder(ctx, o)
+	})
+}
+
+func (m *mockExchange) CancelOrders(ctx context.Context, symbol string, clientOrderIDs []string) []error {
+	m.batchCancelCalls++
+	return gateway.CancelOrdersEach(ctx, symbol, clientOrderIDs, func(ctx context.Context, symbol, id string) error {
+		if err := m.cancelErrs[id]; err != nil {
+			m.cancelCalls = append(m.cancelCalls, id)
+			return err
+		}
+		return m.CancelOrder(ctx, symbol, id)
+	})
+}
+
+func (m *mockExchange) AmendOrder(ctx context.Context, order *gateway.Order) (*gateway.Order, error) {
+	m.amendCalls = append(m.amendCalls, order)
+	if err := m.amendErrs[order.ClientOrderID]; err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+func (m *mockExchange) AmendOrders(ctx context.Context, orders []*gateway.Order) []gateway.OrderResult {
+	return gateway.PlaceOrdersEach(ctx, orders, m.AmendOrder)
+}
+
 func (m *mockExchange) CancelAllOrders(ctx context.Context, symbol string) error {
 	// 模拟成功，不做实际操作
 	return nil
@@ -56,6 +101,10 @@ func (m *mockExchange) GetAllPositions(ctx context.Context) ([]*gateway.Position
 	return nil, nil
 }
 
+func (m *mockExchange) GetAccountBalance(ctx context.Context) (float64, float64, error) {
+	return 0, 0, nil
+}
+
 func (m *mockExchange) GetFundingRate(ctx context.Context, symbol string) (*gateway.FundingRate, error) {
 	return nil, nil
 }
@@ -72,6 +121,10 @@ func (m *mockExchange) StartDepthStream(ctx context.Context, symbols []string, c
 	return nil
 }
 
+func (m *mockExchange) StartTradeStream(ctx context.Context, symbols []string, onTrade func(*gateway.Trade)) error {
+	return nil
+}
+
 func (m *mockExchange) StartUserStream(ctx context.Context, callbacks *gateway.UserStreamCallbacks) error {
 	return nil
 }
@@ -101,6 +154,17 @@ func assertLen(t *testing.T, a interface{}, length int, msg string) {
 	}
 }
 
+// setTrackedOrders 以已挂单状态替换本地跟踪的订单
+func setTrackedOrders(om *OrderManager, symbol string, orders []*gateway.Order) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	om.orders[symbol] = nil
+	for _, o := range orders {
+		o.Symbol = symbol
+		om.trackLocked(o, StateNew, om.now())
+	}
+}
+
 // TestSyncActiveOrders 测试同步活跃订单
 func TestSyncActiveOrders(t *testing.T) {
 	mockEx := &mockExchange{
@@ -120,9 +184,7 @@ func TestSyncActiveOrders(t *testing.T) {
 		t.Fatalf("SyncActiveOrders错误: %v", err)
 	}
 
-	om.mu.RLock()
-	defer om.mu.RUnlock()
-	assertLen(t, om.activeOrders["BTCUSDT"], 2, "activeOrders 长度")
+	assertLen(t, om.ActiveOrders("BTCUSDT"), 2, "activeOrders 长度")
 	assertEqual(t, store.GetSymbolState("BTCUSDT").PendingBuy, 0.8, "PendingBuy")
 	assertEqual(t, store.GetSymbolState("BTCUSDT").PendingSell, 1.0, "PendingSell")
 }
@@ -134,13 +196,11 @@ func TestCalculateOrderDiff(t *testing.T) {
 	store.InitSymbol("BTCUSDT", 100)
 	om := NewOrderManager(store, mockEx)
 
-	// 场景1: 当前订单与期望订单不同价格，应该全部撤销并重新下单
-	om.mu.Lock()
-	om.activeOrders["BTCUSDT"] = []*gateway.Order{
+	// 场景1: 当前订单与期望订单同层仅价格不同，应该改价而不是撤单重挂
+	setTrackedOrders(om, "BTCUSDT", []*gateway.Order{
 		{ClientOrderID: "c1", Side: "BUY", Price: 100.0, Quantity: 1.0},
 		{ClientOrderID: "c2", Side: "SELL", Price: 101.0, Quantity: 1.0},
-	}
-	om.mu.Unlock()
+	})
 
 	desiredBuy := []*gateway.Order{
 		{ClientOrderID: "new1", Side: "BUY", Price: 99.0, Quantity: 1.0},
@@ -149,27 +209,31 @@ func TestCalculateOrderDiff(t *testing.T) {
 		{ClientOrderID: "new2", Side: "SELL", Price: 102.0, Quantity: 1.0},
 	}
 
-	toCancel, toPlace := om.CalculateOrderDiff("BTCUSDT", desiredBuy, desiredSell, 0.01)
+	toCancel, toAmend, toPlace := om.CalculateOrderDiff("BTCUSDT", desiredBuy, desiredSell, 0.01)
 
-	if len(toCancel) != 2 {
-		t.Fatalf("场景1: 期望撤销2个订单，实际%d个", len(toCancel))
+	if len(toCancel) != 0 || len(toPlace) != 0 {
+		t.Fatalf("场景1: 仅价格变化不应撤单重挂，实际撤销%d个、新下%d个", len(toCancel), len(toPlace))
+	}
+	if len(toAmend) != 2 {
+		t.Fatalf("场景1: 期望改价2个订单，实际%d个", len(toAmend))
 	}
-	if len(toPlace) != 2 {
-		t.Fatalf("场景1: 期望新下2个订单，实际%d个", len(toPlace))
+	for _, o := range toAmend {
+		if (o.Side == "BUY" && (o.ClientOrderID != "c1" || o.Price != 99.0)) ||
+			(o.Side == "SELL" && (o.ClientOrderID != "c2" || o.Price != 102.0)) {
+			t.Fatalf("场景1: 改价订单应沿用原ClientOrderID并使用新价格，实际%+v", o)
+		}
 	}
 
 	// 场景2: 当前订单与期望订单价格相同但数量不同，应该撤销并重新下单
-	om.mu.Lock()
-	om.activeOrders["BTCUSDT"] = []*gateway.Order{
+	setTrackedOrders(om, "BTCUSDT", []*gateway.Order{
 		{ClientOrderID: "c3", Side: "BUY", Price: 100.0, Quantity: 1.0},
-	}
-	om.mu.Unlock()
+	})
 
 	desiredBuy2 := []*gateway.Order{
 		{Side: "BUY", Price: 100.0, Quantity: 2.0}, // 数量不同
 	}
 
-	toCancel2, toPlace2 := om.CalculateOrderDiff("BTCUSDT", desiredBuy2, nil, 0.01)
+	toCancel2, toAmend2, toPlace2 := om.CalculateOrderDiff("BTCUSDT", desiredBuy2, nil, 0.01)
 
 	if len(toCancel2) != 1 {
 		t.Fatalf("场景2: 期望撤销1个订单（数量变化），实际%d个", len(toCancel2))
@@ -177,14 +241,15 @@ func TestCalculateOrderDiff(t *testing.T) {
 	if len(toPlace2) != 1 {
 		t.Fatalf("场景2: 期望新下1个订单，实际%d个", len(toPlace2))
 	}
+	if len(toAmend2) != 0 {
+		t.Fatalf("场景2: 数量变化不应改价，实际%d个", len(toAmend2))
+	}
 
 	// 场景3: 当前订单与期望订单完全匹配，不应该有任何操作
-	om.mu.Lock()
-	om.activeOrders["BTCUSDT"] = []*gateway.Order{
+	setTrackedOrders(om, "BTCUSDT", []*gateway.Order{
 		{ClientOrderID: "c4", Side: "BUY", Price: 100.0, Quantity: 1.0},
 		{ClientOrderID: "c5", Side: "SELL", Price: 101.0, Quantity: 1.0},
-	}
-	om.mu.Unlock()
+	})
 
 	desiredBuy3 := []*gateway.Order{
 		{Side: "BUY", Price: 100.0, Quantity: 1.0},
@@ -193,7 +258,7 @@ func TestCalculateOrderDiff(t *testing.T) {
 		{Side: "SELL", Price: 101.0, Quantity: 1.0},
 	}
 
-	toCancel3, toPlace3 := om.CalculateOrderDiff("BTCUSDT", desiredBuy3, desiredSell3, 0.01)
+	toCancel3, toAmend3, toPlace3 := om.CalculateOrderDiff("BTCUSDT", desiredBuy3, desiredSell3, 0.01)
 
 	if len(toCancel3) != 0 {
 		t.Fatalf("场景3: 订单完全匹配，不应撤销订单，实际撤销%d个", len(toCancel3))
@@ -201,6 +266,9 @@ func TestCalculateOrderDiff(t *testing.T) {
 	if len(toPlace3) != 0 {
 		t.Fatalf("场景3: 订单完全匹配，不应新下订单，实际新下%d个", len(toPlace3))
 	}
+	if len(toAmend3) != 0 {
+		t.Fatalf("场景3: 订单完全匹配，不应改价，实际改价%d个", len(toAmend3))
+	}
 }
 
 // TestApplyDiff 测试下单和撤单操作
@@ -215,7 +283,7 @@ func TestApplyDiff(t *testing.T) {
 	}
 
 	// 测试成功调用
-	err := om.ApplyDiff(context.Background(), "BTCUSDT", toCancel, toPlace)
+	err := om.ApplyDiff(context.Background(), "BTCUSDT", toCancel, nil, toPlace)
 	if err != nil {
 		t.Fatalf("ApplyDiff错误:%v", err)
 	}
@@ -228,7 +296,7 @@ func TestApplyDiff(t *testing.T) {
 
 	// 测试撤单失败仍继续
 	mockEx.failCancel = true
-	err = om.ApplyDiff(context.Background(), "BTCUSDT", toCancel, toPlace)
+	err = om.ApplyDiff(context.Background(), "BTCUSDT", toCancel, nil, toPlace)
 	if err != nil {
 		t.Fatalf("ApplyDiff错误:%v", err)
 	}
@@ -236,8 +304,136 @@ func TestApplyDiff(t *testing.T) {
 	// 测试下单失败仍继续
 	mockEx.failCancel = false
 	mockEx.failPlace = true
-	err = om.ApplyDiff(context.Background(), "BTCUSDT", toCancel, toPlace)
+	err = om.ApplyDiff(context.Background(), "BTCUSDT", toCancel, nil, toPlace)
 	if err != nil {
 		t.Fatalf("ApplyDiff错误:%v", err)
 	}
 }
+
+// TestApplyDiff_BatchPartialFailure 整组批量下单/撤单，部分失败逐个处理
+func TestApplyDiff_BatchPartialFailure(t *testing.T) {
+	mockEx := &mockExchange{
+		openOrders: map[string][]*gateway.Order{
+			"BTCUSDT": {
+				{ClientOrderID: "c1", Symbol: "BTCUSDT", Side: "BUY", Price: 100, Quantity: 1},
+				{ClientOrderID: "c2", Symbol: "BTCUSDT", Side: "BUY", Price: 99, Quantity: 1},
+				{ClientOrderID: "c3", Symbol: "BTCUSDT", Side: "SELL", Price: 101, Quantity: 1},
+			},
+		},
+		cancelErrs: map[string]error{
+			"c2": &gateway.ExchangeError{Code: -2011, Message: "batch item error -2011: Unknown order sent."},
+			"c3": &gateway.ExchangeError{Code: -1001, Message: "batch item error -1001: Internal error"},
+		},
+		rejectPrices: map[float64]error{
+			100.5: &gateway.ExchangeError{Code: -5022, Message: "batch item error -5022: Post Only order will be rejected"},
+		},
+	}
+	st := store.NewStore("", time.Minute)
+	st.InitSymbol("BTCUSDT", 10)
+	om := NewOrderManager(st, mockEx)
+	if err := om.SyncActiveOrders(context.Background(), "BTCUSDT"); err != nil {
+		t.Fatalf("SyncActiveOrders错误:%v", err)
+	}
+
+	toPlace := []*gateway.Order{
+		{Symbol: "BTCUSDT", Side: "BUY", Price: 100.2, Quantity: 1},
+		{Symbol: "BTCUSDT", Side: "SELL", Price: 100.5, Quantity: 1},
+		{Symbol: "BTCUSDT", Side: "SELL", Price: 101.2, Quantity: 1},
+	}
+	if err := om.ApplyDiff(context.Background(), "BTCUSDT", []string{"c1", "c2", "c3"}, nil, toPlace); err != nil {
+		t.Fatalf("ApplyDiff错误:%v", err)
+	}
+
+	if mockEx.batchCancelCalls != 1 || mockEx.batchPlaceCalls != 1 {
+		t.Errorf("期望各一次批量调用，实际撤单%d次、下单%d次", mockEx.batchCancelCalls, mockEx.batchPlaceCalls)
+	}
+	if len(mockEx.cancelCalls) != 3 || len(mockEx.placeCalls) != 3 {
+		t.Errorf("期望逐个处理3笔撤单和3笔下单，实际%d/%d", len(mockEx.cancelCalls), len(mockEx.placeCalls))
+	}
+
+	// 撤单成功只计一次；不存在的订单(c2)从本地移除，其他失败(c3)保留
+	if got := st.GetSymbolState("BTCUSDT").CancelCountLast; got != 1 {
+		t.Errorf("期望撤单计数1，实际%d", got)
+	}
+	remaining := map[string]bool{}
+	for _, o := range om.ActiveOrders("BTCUSDT") {
+		remaining[o.ClientOrderID] = true
+	}
+	if remaining["c2"] || !remaining["c3"] {
+		t.Errorf("期望移除c2并保留c3，实际%v", remaining)
+	}
+}
+
+// TestApplyDiff_SystemicErrorReturned 限频等影响整组请求的错误返回给调用方，Post Only拒单不返回
+func TestApplyDiff_SystemicErrorReturned(t *testing.T) {
+	mockEx := &mockExchange{
+		rejectPrices: map[float64]error{
+			100.5: &gateway.ExchangeError{Code: -5022, Message: "batch item error -5022: Post Only order will be rejected"},
+			101.2: &gateway.ExchangeError{Code: -1015, Message: "batch item error -1015: Too many new orders"},
+		},
+	}
+	st := store.NewStore("", time.Minute)
+	st.InitSymbol("BTCUSDT", 10)
+	om := NewOrderManager(st, mockEx)
+
+	toPlace := []*gateway.Order{{Symbol: "BTCUSDT", Side: "SELL", Price: 100.5, Quantity: 1}}
+	if err := om.ApplyDiff(context.Background(), "BTCUSDT", nil, nil, toPlace); err != nil {
+		t.Fatalf("Post Only拒单不应返回错误:%v", err)
+	}
+	toPlace = append(toPlace, &gateway.Order{Symbol: "BTCUSDT", Side: "SELL", Price: 101.2, Quantity: 1})
+	err := om.ApplyDiff(context.Background(), "BTCUSDT", nil, nil, toPlace)
+	if !errors.Is(err, gateway.ErrRateLimited) {
+		t.Fatalf("期望返回限频错误，实际%v", err)
+	}
+}
+
+// TestApplyDiff_Amend 改价单独计数，订单已不存在时按期望价格重新下单
+func TestApplyDiff_Amend(t *testing.T) {
+	mockEx := &mockExchange{
+		openOrders: map[string][]*gateway.Order{
+			"BTCUSDT": {
+				{ClientOrderID: "c1", Symbol: "BTCUSDT", Side: "BUY", Price: 100, Quantity: 1},
+				{ClientOrderID: "c2", Symbol: "BTCUSDT", Side: "SELL", Price: 101, Quantity: 1},
+			},
+		},
+		amendErrs: map[string]error{
+			"c2": &gateway.ExchangeError{Code: -2013, Message: "batch item error -2013: Order does not exist."},
+		},
+	}
+	st := store.NewStore("", time.Minute)
+	st.InitSymbol("BTCUSDT", 10)
+	om := NewOrderManager(st, mockEx)
+	if err := om.SyncActiveOrders(context.Background(), "BTCUSDT"); err != nil {
+		t.Fatalf("SyncActiveOrders错误:%v", err)
+	}
+
+	toAmend := []*gateway.Order{
+		{ClientOrderID: "c1", Symbol: "BTCUSDT", Side: "BUY", Price: 99.5, Quantity: 1},
+		{ClientOrderID: "c2", Symbol: "BTCUSDT", Side: "SELL", Price: 101.5, Quantity: 1},
+	}
+	if err := om.ApplyDiff(context.Background(), "BTCUSDT", nil, toAmend, nil); err != nil {
+		t.Fatalf("ApplyDiff错误:%v", err)
+	}
+
+	state := st.GetSymbolState("BTCUSDT")
+	if state.AmendCountLast != 1 || state.CancelCountLast != 0 {
+		t.Errorf("期望改单计数1、撤单计数0，实际%d/%d", state.AmendCountLast, state.CancelCountLast)
+	}
+	if len(mockEx.cancelCalls) != 0 {
+		t.Errorf("改价不应撤单，实际撤单%v", mockEx.cancelCalls)
+	}
+	if len(mockEx.placeCalls) != 1 || mockEx.placeCalls[0].Price != 101.5 {
+		t.Fatalf("期望按101.5重新下单1笔，实际%d笔", len(mockEx.placeCalls))
+	}
+
+	remaining := map[string]float64{}
+	for _, o := range om.ActiveOrders("BTCUSDT") {
+		remaining[o.ClientOrderID] = o.Price
+	}
+	if remaining["c1"] != 99.5 {
+		t.Errorf("期望本地c1价格更新为99.5，实际%v", remaining["c1"])
+	}
+	if _, ok := remaining["c2"]; ok {
+		t.Errorf("期望c2从本地移除")
+	}
+}
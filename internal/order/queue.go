@@ -0,0 +1,110 @@
+package order
+
+import (
+	"math"
+
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+)
+
+// queueEstimate 挂单在所在价位的排队估计
+type queueEstimate struct {
+	known    bool
+	ahead    float64 // 排在本订单之前的数量
+	levelQty float64 // 最近一次观察到的价位总量（含本订单）
+}
+
+// SetQueueRetention 设置队列位置保留系数：订单的保留容差放大为 tolerance × (1 + boost × 队列靠前程度)，
+// 队首订单最多放大 1+boost 倍，队尾订单保持原容差；0 表示不按队列位置调整
+func (om *OrderManager) SetQueueRetention(symbol string, boost float64) {
+	if boost < 0 {
+		boost = 0
+	}
+	om.mu.Lock()
+	om.queueBoost[symbol] = boost
+	om.mu.Unlock()
+}
+
+// QueuePosition 返回订单前方排队量与价位总量；尚无深度观察时返回false
+func (om *OrderManager) QueuePosition(symbol, clientOrderID string) (ahead, levelQty float64, ok bool) {
+	om.mu.RLock()
+	defer om.mu.RUnlock()
+	t := om.orders[symbol][clientOrderID]
+	if t == nil || !t.queue.known {
+		return 0, 0, false
+	}
+	return t.queue.ahead, t.queue.levelQty, true
+}
+
+// OnBook 深度更新时刷新排队估计：首次观察时假定价位上的其他数量都排在前面；
+// 之后价位上其他数量减少（撤单）时前方排队量不超过该数量
+func (om *OrderManager) OnBook(depth *gateway.Depth) {
+	if depth == nil {
+		return
+	}
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	for _, t := range om.orders[depth.Symbol] {
+		if t.State != StateNew && t.State != StatePartiallyFilled {
+			continue
+		}
+		levels := depth.Asks
+		if t.Side == "BUY" {
+			levels = depth.Bids
+		}
+		qty, ok := levelQty(levels, t.Price)
+		if !ok {
+			// 价位不在推送档位内，保持原估计
+			continue
+		}
+		others := math.Max(qty-(t.Quantity-t.FilledQty), 0)
+		if !t.queue.known || others < t.queue.ahead {
+			t.queue.ahead = others
+		}
+		t.queue.known = true
+		t.queue.levelQty = qty
+	}
+}
+
+// OnMarketTrade 逐笔成交消耗同价位的排队量；成交价穿过订单价位说明该价位已被吃完
+func (om *OrderManager) OnMarketTrade(symbol string, price, qty float64, takerBuy bool) {
+	om.mu.Lock()
+	defer om.mu.Unlock()
+	for _, t := range om.orders[symbol] {
+		if !t.queue.known {
+			continue
+		}
+		// 主动卖出成交买单，主动买入成交卖单
+		if (t.Side == "BUY") == takerBuy {
+			continue
+		}
+		switch {
+		case samePrice(price, t.Price):
+			t.queue.ahead = math.Max(t.queue.ahead-qty, 0)
+		case (t.Side == "BUY" && price < t.Price) || (t.Side == "SELL" && price > t.Price):
+			t.queue.ahead = 0
+		}
+	}
+}
+
+// queuePriority 订单在队列中的靠前程度：1 为队首，0 为队尾或未知
+func (t *TrackedOrder) queuePriority() float64 {
+	if !t.queue.known || t.queue.levelQty <= 0 {
+		return 0
+	}
+	p := 1 - t.queue.ahead/t.queue.levelQty
+	return math.Min(math.Max(p, 0), 1)
+}
+
+// levelQty 查找指定价位的挂单量
+func levelQty(levels []gateway.PriceLevel, price float64) (float64, bool) {
+	for _, lv := range levels {
+		if samePrice(lv.Price, price) {
+			return lv.Quantity, true
+		}
+	}
+	return 0, false
+}
+
+func samePrice(a, b float64) bool {
+	return math.Abs(a-b) <= 1e-9*math.Max(math.Abs(a), 1)
+}
@@ -0,0 +1,90 @@
+package order
+
+import (
+	"math"
+	"testing"
+
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+)
+
+func bookWithBid(price, qty float64) *gateway.Depth {
+	return &gateway.Depth{
+		Symbol: "BTCUSDT",
+		Bids:   []gateway.PriceLevel{{Price: price, Quantity: qty}},
+		Asks:   []gateway.PriceLevel{{Price: price + 1, Quantity: 5}},
+	}
+}
+
+func assertQueue(t *testing.T, om *OrderManager, id string, wantAhead float64) {
+	t.Helper()
+	ahead, _, ok := om.QueuePosition("BTCUSDT", id)
+	if !ok || math.Abs(ahead-wantAhead) > 1e-9 {
+		t.Fatalf("%s: 期望前方排队%v，实际%v(ok=%v)", id, wantAhead, ahead, ok)
+	}
+}
+
+// TestQueueEstimate 深度与逐笔成交推进排队位置
+func TestQueueEstimate(t *testing.T) {
+	om, _, _ := newLifecycleManager(&mockExchange{})
+	setTrackedOrders(om, "BTCUSDT", []*gateway.Order{{ClientOrderID: "b1", Side: "BUY", Price: 100, Quantity: 1}})
+
+	if _, _, ok := om.QueuePosition("BTCUSDT", "b1"); ok {
+		t.Fatal("尚无深度观察时排队位置未知")
+	}
+
+	// 首次观察：价位上其他数量都排在前面
+	om.OnBook(bookWithBid(100, 11))
+	assertQueue(t, om, "b1", 10)
+
+	// 价位增加的数量排在后面
+	om.OnBook(bookWithBid(100, 15))
+	assertQueue(t, om, "b1", 10)
+
+	// 同价位主动卖出消耗前方排队量，主动买入不影响买单
+	om.OnMarketTrade("BTCUSDT", 100, 3, false)
+	om.OnMarketTrade("BTCUSDT", 101, 2, true)
+	assertQueue(t, om, "b1", 7)
+
+	// 价位上其他数量少于前方排队量：前方有人撤单
+	om.OnBook(bookWithBid(100, 5))
+	assertQueue(t, om, "b1", 4)
+
+	// 成交价穿过订单价位
+	om.OnMarketTrade("BTCUSDT", 99.5, 0.1, false)
+	assertQueue(t, om, "b1", 0)
+
+	// 改价后重新排队
+	om.ackAmend("BTCUSDT", &gateway.Order{ClientOrderID: "b1", Price: 99, Quantity: 1}, nil)
+	if _, _, ok := om.QueuePosition("BTCUSDT", "b1"); ok {
+		t.Fatal("改价后排队位置应重新估计")
+	}
+}
+
+// TestCalculateOrderDiff_QueueRetention 队首订单在更大的价格偏离下仍保留，队尾订单按原容差撤单重挂
+func TestCalculateOrderDiff_QueueRetention(t *testing.T) {
+	om, _, _ := newLifecycleManager(&mockExchange{})
+	setTrackedOrders(om, "BTCUSDT", []*gateway.Order{
+		{ClientOrderID: "front", Side: "BUY", Price: 100, Quantity: 1},
+		{ClientOrderID: "back", Side: "SELL", Price: 102, Quantity: 1},
+	})
+	om.OnBook(&gateway.Depth{
+		Symbol: "BTCUSDT",
+		Bids:   []gateway.PriceLevel{{Price: 100, Quantity: 1}},  // 价位上只有本订单
+		Asks:   []gateway.PriceLevel{{Price: 102, Quantity: 20}}, // 前方19
+	})
+
+	desiredBuy := []*gateway.Order{{Side: "BUY", Price: 100.15, Quantity: 1}}
+	desiredSell := []*gateway.Order{{Side: "SELL", Price: 101.85, Quantity: 1}}
+
+	// 未启用队列保留：两侧都超出0.1容差
+	_, toAmend, _ := om.CalculateOrderDiff("BTCUSDT", desiredBuy, desiredSell, 0.1)
+	if len(toAmend) != 2 {
+		t.Fatalf("未启用队列保留时期望改价2笔，实际%d笔", len(toAmend))
+	}
+
+	om.SetQueueRetention("BTCUSDT", 1)
+	toCancel, toAmend, toPlace := om.CalculateOrderDiff("BTCUSDT", desiredBuy, desiredSell, 0.1)
+	if len(toCancel) != 0 || len(toPlace) != 0 || len(toAmend) != 1 || toAmend[0].ClientOrderID != "back" {
+		t.Fatalf("期望仅改价队尾订单，实际撤%v 改%d 下%d", toCancel, len(toAmend), len(toPlace))
+	}
+}
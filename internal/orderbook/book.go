@@ -0,0 +1,236 @@
+package orderbook
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotSynced 尚未加载快照，增量已缓存等待重放
+	ErrNotSynced = errors.New("orderbook: not synced")
+	// ErrGap 增量序号不连续，需要重新拉取快照
+	ErrGap = errors.New("orderbook: update sequence gap")
+)
+
+// maxBuffered 未同步期间最多缓存的增量条数
+const maxBuffered = 1000
+
+// Level 价格档位
+type Level struct {
+	Price    float64
+	Quantity float64
+}
+
+// Update 增量深度事件（对应 @depth@100ms 推送）
+type Update struct {
+	Symbol            string
+	EventTime         int64 // 毫秒
+	FirstUpdateID     int64 // U
+	FinalUpdateID     int64 // u
+	PrevFinalUpdateID int64 // pu
+	Bids              []Level
+	Asks              []Level
+}
+
+// Snapshot REST /fapi/v1/depth 快照
+type Snapshot struct {
+	LastUpdateID int64
+	Bids         []Level
+	Asks         []Level
+}
+
+// Book 单个交易对的本地L2订单簿。
+// 同步流程遵循Binance期货文档：以快照lastUpdateId为锚点，丢弃u<lastUpdateId的增量，
+// 第一条增量需满足 U<=lastUpdateId<=u，此后每条增量的pu必须等于上一条的u。
+type Book struct {
+	mu           sync.RWMutex
+	symbol       string
+	bids         map[float64]float64
+	asks         map[float64]float64
+	lastUpdateID int64
+	synced       bool
+	awaitFirst   bool // 快照后尚未应用第一条增量
+	buffer       []Update
+	updatedAt    time.Time
+}
+
+// NewBook 创建空订单簿
+func NewBook(symbol string) *Book {
+	return &Book{
+		symbol: symbol,
+		bids:   make(map[float64]float64),
+		asks:   make(map[float64]float64),
+	}
+}
+
+// Symbol 交易对
+func (b *Book) Symbol() string {
+	return b.symbol
+}
+
+// Synced 是否已与交易所同步
+func (b *Book) Synced() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.synced
+}
+
+// LastUpdateID 最近应用的更新序号
+func (b *Book) LastUpdateID() int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.lastUpdateID
+}
+
+// ApplySnapshot 用快照重建订单簿，并重放缓存的增量
+func (b *Book) ApplySnapshot(s Snapshot) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bids = make(map[float64]float64, len(s.Bids))
+	b.asks = make(map[float64]float64, len(s.Asks))
+	setLevels(b.bids, s.Bids)
+	setLevels(b.asks, s.Asks)
+	b.lastUpdateID = s.LastUpdateID
+	b.synced = true
+	b.awaitFirst = true
+	b.updatedAt = time.Now()
+
+	buffered := b.buffer
+	b.buffer = nil
+	for i, u := range buffered {
+		if err := b.applyLocked(u); err != nil {
+			// 快照过旧或缓存中有断档：保留剩余增量等待下一次快照
+			b.buffer = append(b.buffer, buffered[i+1:]...)
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyDiff 应用一条增量。未同步时缓存并返回ErrNotSynced；断档时返回ErrGap并置为未同步。
+func (b *Book) ApplyDiff(u Update) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.synced {
+		b.bufferLocked(u)
+		return ErrNotSynced
+	}
+	return b.applyLocked(u)
+}
+
+// Reset 清空订单簿并置为未同步
+func (b *Book) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bids = make(map[float64]float64)
+	b.asks = make(map[float64]float64)
+	b.lastUpdateID = 0
+	b.synced = false
+	b.awaitFirst = false
+	b.buffer = nil
+}
+
+func (b *Book) applyLocked(u Update) error {
+	// 早于快照的增量直接丢弃
+	if u.FinalUpdateID < b.lastUpdateID {
+		return nil
+	}
+
+	if b.awaitFirst {
+		if u.FirstUpdateID > b.lastUpdateID {
+			b.desyncLocked(u)
+			return ErrGap
+		}
+	} else if u.PrevFinalUpdateID != b.lastUpdateID {
+		b.desyncLocked(u)
+		return ErrGap
+	}
+
+	setLevels(b.bids, u.Bids)
+	setLevels(b.asks, u.Asks)
+	b.lastUpdateID = u.FinalUpdateID
+	b.awaitFirst = false
+	b.updatedAt = time.Now()
+	return nil
+}
+
+// desyncLocked 标记断档，当前增量留待下一次快照后重放
+func (b *Book) desyncLocked(u Update) {
+	b.synced = false
+	b.awaitFirst = false
+	b.buffer = b.buffer[:0]
+	b.bufferLocked(u)
+}
+
+func (b *Book) bufferLocked(u Update) {
+	if len(b.buffer) >= maxBuffered {
+		b.buffer = b.buffer[1:]
+	}
+	b.buffer = append(b.buffer, u)
+}
+
+// setLevels 数量为0表示删除该档位
+func setLevels(side map[float64]float64, levels []Level) {
+	for _, lv := range levels {
+		if lv.Quantity <= 0 {
+			delete(side, lv.Price)
+			continue
+		}
+		side[lv.Price] = lv.Quantity
+	}
+}
+
+// Levels 返回前limit档（买盘价格降序、卖盘价格升序），limit<=0返回全部
+func (b *Book) Levels(limit int) (bids, asks []Level) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return sortedLevels(b.bids, limit, true), sortedLevels(b.asks, limit, false)
+}
+
+// BestBidAsk 返回最优买卖价，任一侧为空时返回0
+func (b *Book) BestBidAsk() (bid, ask float64) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for p := range b.bids {
+		if p > bid {
+			bid = p
+		}
+	}
+	for p := range b.asks {
+		if ask == 0 || p < ask {
+			ask = p
+		}
+	}
+	return bid, ask
+}
+
+// UpdatedAt 最近一次更新时间
+func (b *Book) UpdatedAt() time.Time {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.updatedAt
+}
+
+func sortedLevels(side map[float64]float64, limit int, desc bool) []Level {
+	prices := make([]float64, 0, len(side))
+	for p := range side {
+		prices = append(prices, p)
+	}
+	if desc {
+		sort.Sort(sort.Reverse(sort.Float64Slice(prices)))
+	} else {
+		sort.Float64s(prices)
+	}
+	if limit > 0 && len(prices) > limit {
+		prices = prices[:limit]
+	}
+	out := make([]Level, len(prices))
+	for i, p := range prices {
+		out[i] = Level{Price: p, Quantity: side[p]}
+	}
+	return out
+}
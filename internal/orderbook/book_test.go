@@ -0,0 +1,102 @@
+package orderbook
+
+import (
+	"testing"
+	"time"
+)
+
+func snapshot() Snapshot {
+	return Snapshot{
+		LastUpdateID: 100,
+		Bids:         []Level{{Price: 99.9, Quantity: 1}, {Price: 100, Quantity: 2}},
+		Asks:         []Level{{Price: 100.2, Quantity: 2}, {Price: 100.1, Quantity: 1}},
+	}
+}
+
+func TestBookSnapshotAndDiff(t *testing.T) {
+	b := NewBook("ETHUSDC")
+
+	// 快照前的增量被缓存，快照后重放（u<lastUpdateId的丢弃）
+	if err := b.ApplyDiff(Update{FirstUpdateID: 90, FinalUpdateID: 95, Bids: []Level{{Price: 1, Quantity: 1}}}); err != ErrNotSynced {
+		t.Fatalf("expected ErrNotSynced, got %v", err)
+	}
+	if err := b.ApplyDiff(Update{FirstUpdateID: 96, FinalUpdateID: 105, PrevFinalUpdateID: 95,
+		Bids: []Level{{Price: 100, Quantity: 0}, {Price: 100.05, Quantity: 3}}}); err != ErrNotSynced {
+		t.Fatalf("expected ErrNotSynced, got %v", err)
+	}
+	if err := b.ApplySnapshot(snapshot()); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if !b.Synced() || b.LastUpdateID() != 105 {
+		t.Fatalf("expected synced at 105, got %v %d", b.Synced(), b.LastUpdateID())
+	}
+
+	if err := b.ApplyDiff(Update{FirstUpdateID: 106, FinalUpdateID: 110, PrevFinalUpdateID: 105,
+		Asks: []Level{{Price: 100.1, Quantity: 0}}}); err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+
+	bids, asks := b.Levels(0)
+	if len(bids) != 2 || bids[0].Price != 100.05 || bids[0].Quantity != 3 || bids[1].Price != 99.9 {
+		t.Fatalf("unexpected bids: %+v", bids)
+	}
+	if len(asks) != 1 || asks[0].Price != 100.2 {
+		t.Fatalf("unexpected asks: %+v", asks)
+	}
+	if bid, ask := b.BestBidAsk(); bid != 100.05 || ask != 100.2 {
+		t.Fatalf("unexpected best: %f %f", bid, ask)
+	}
+}
+
+func TestBookGap(t *testing.T) {
+	b := NewBook("ETHUSDC")
+	b.ApplySnapshot(snapshot())
+
+	// 第一条增量必须覆盖lastUpdateId
+	if err := b.ApplyDiff(Update{FirstUpdateID: 101, FinalUpdateID: 102, PrevFinalUpdateID: 100}); err != ErrGap {
+		t.Fatalf("expected ErrGap for first update, got %v", err)
+	}
+	if b.Synced() {
+		t.Fatal("book should be desynced after gap")
+	}
+
+	b.ApplySnapshot(Snapshot{LastUpdateID: 101})
+	if !b.Synced() || b.LastUpdateID() != 102 {
+		t.Fatalf("buffered update should be replayed, got %v %d", b.Synced(), b.LastUpdateID())
+	}
+	if err := b.ApplyDiff(Update{FirstUpdateID: 104, FinalUpdateID: 105, PrevFinalUpdateID: 103}); err != ErrGap {
+		t.Fatalf("expected ErrGap for pu mismatch, got %v", err)
+	}
+}
+
+func TestManagerResync(t *testing.T) {
+	fetches := 0
+	m := NewManager(func(symbol string) (Snapshot, error) {
+		fetches++
+		return snapshot(), nil
+	})
+	var recorded []int64
+	m.OnSnapshot = func(symbol string, s Snapshot) { recorded = append(recorded, s.LastUpdateID) }
+	done := make(chan string, 1)
+	m.OnResync = func(symbol string) { done <- symbol }
+
+	// 首条增量触发异步快照
+	if m.Apply(Update{Symbol: "ETHUSDC", FirstUpdateID: 99, FinalUpdateID: 101, PrevFinalUpdateID: 98}) {
+		t.Fatal("book should not be synced before snapshot")
+	}
+	select {
+	case sym := <-done:
+		if sym != "ETHUSDC" {
+			t.Fatalf("unexpected symbol %s", sym)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("resync not triggered")
+	}
+
+	if fetches != 1 || len(recorded) != 1 {
+		t.Fatalf("expected one snapshot fetch, got %d/%d", fetches, len(recorded))
+	}
+	if !m.Apply(Update{Symbol: "ETHUSDC", FirstUpdateID: 102, FinalUpdateID: 103, PrevFinalUpdateID: 101}) {
+		t.Fatal("book should be synced after resync")
+	}
+}
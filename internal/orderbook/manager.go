@@ -0,0 +1,122 @@
+package orderbook
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// SnapshotFunc 拉取REST深度快照
+type SnapshotFunc func(symbol string) (Snapshot, error)
+
+// minResyncInterval 同一交易对两次快照请求的最小间隔，防止断档时打爆REST权重
+const minResyncInterval = time.Second
+
+// Manager 管理多个交易对的订单簿，断档或首次收到增量时自动异步拉取快照重同步
+type Manager struct {
+	mu         sync.Mutex
+	books      map[string]*Book
+	resyncing  map[string]bool
+	lastResync map[string]time.Time
+	fetch      SnapshotFunc
+
+	// OnSnapshot 快照加载后回调（可选，用于录制快照以便回放）
+	OnSnapshot func(symbol string, s Snapshot)
+	// OnResync 异步重同步完成后回调（可选）
+	OnResync func(symbol string)
+}
+
+// NewManager 创建订单簿管理器
+func NewManager(fetch SnapshotFunc) *Manager {
+	return &Manager{
+		books:      make(map[string]*Book),
+		resyncing:  make(map[string]bool),
+		lastResync: make(map[string]time.Time),
+		fetch:      fetch,
+	}
+}
+
+// Book 返回交易对订单簿（不存在则创建）
+func (m *Manager) Book(symbol string) *Book {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	book, ok := m.books[symbol]
+	if !ok {
+		book = NewBook(symbol)
+		m.books[symbol] = book
+	}
+	return book
+}
+
+// Apply 应用增量，返回订单簿当前是否同步可用
+func (m *Manager) Apply(u Update) bool {
+	book := m.Book(u.Symbol)
+	switch err := book.ApplyDiff(u); err {
+	case nil:
+		return true
+	case ErrGap:
+		log.Warn().
+			Str("symbol", u.Symbol).
+			Int64("U", u.FirstUpdateID).
+			Int64("u", u.FinalUpdateID).
+			Int64("pu", u.PrevFinalUpdateID).
+			Msg("订单簿增量断档，重新拉取快照")
+		metrics.RecordError("orderbook_gap", u.Symbol)
+		m.triggerResync(u.Symbol)
+	default:
+		m.triggerResync(u.Symbol)
+	}
+	return false
+}
+
+// Resync 同步拉取快照并重建订单簿
+func (m *Manager) Resync(symbol string) error {
+	if m.fetch == nil {
+		return fmt.Errorf("orderbook: snapshot func not set")
+	}
+	snap, err := m.fetch(symbol)
+	if err != nil {
+		return fmt.Errorf("拉取深度快照失败: %w", err)
+	}
+	if m.OnSnapshot != nil {
+		m.OnSnapshot(symbol, snap)
+	}
+	if err := m.Book(symbol).ApplySnapshot(snap); err != nil {
+		return err
+	}
+	log.Info().
+		Str("symbol", symbol).
+		Int64("last_update_id", snap.LastUpdateID).
+		Msg("订单簿快照同步完成")
+	return nil
+}
+
+// triggerResync 异步重同步，同一交易对同时只有一个请求且受最小间隔限制
+func (m *Manager) triggerResync(symbol string) {
+	m.mu.Lock()
+	if m.resyncing[symbol] || time.Since(m.lastResync[symbol]) < minResyncInterval {
+		m.mu.Unlock()
+		return
+	}
+	m.resyncing[symbol] = true
+	m.lastResync[symbol] = time.Now()
+	m.mu.Unlock()
+
+	go func() {
+		defer func() {
+			m.mu.Lock()
+			m.resyncing[symbol] = false
+			m.mu.Unlock()
+		}()
+		if err := m.Resync(symbol); err != nil {
+			log.Error().Err(err).Str("symbol", symbol).Msg("订单簿重同步失败")
+			return
+		}
+		if m.OnResync != nil {
+			m.OnResync(symbol)
+		}
+	}()
+}
@@ -0,0 +1,201 @@
+package paper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/newplayman/market-maker-phoenix/internal/sim"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultFundingPoll 资金费率轮询间隔
+const defaultFundingPoll = time.Minute
+
+// MarketFeed 模拟盘的实时行情来源（由 gateway.BinanceAdapter 实现）
+type MarketFeed interface {
+	Connect(ctx context.Context) error
+	Disconnect() error
+	StartDepthStream(ctx context.Context, symbols []string, callback func(*gateway.Depth)) error
+	StartTradeStream(ctx context.Context, symbols []string, onTrade func(*gateway.Trade)) error
+	GetFundingRate(ctx context.Context, symbol string) (*gateway.FundingRate, error)
+}
+
+// Options 模拟盘参数
+type Options struct {
+	MakerFeeRate   float64
+	InitialBalance float64
+	FundingPoll    time.Duration // 资金费率轮询间隔，默认1分钟
+}
+
+var (
+	_ gateway.Exchange = (*PaperExchange)(nil)
+	_ MarketFeed       = (*gateway.BinanceAdapter)(nil)
+)
+
+// wallClock 系统时间
+type wallClock struct{}
+
+func (wallClock) Now() time.Time { return time.Now() }
+
+// fundingState 单个交易对的资金费结算状态
+type fundingState struct {
+	rate    float64
+	next    time.Time
+	settled time.Time // 最近一次已结算的时间点，防止重复结算
+}
+
+// PaperExchange 模拟盘交易所，实现 gateway.Exchange。
+// 行情（深度、逐笔成交、资金费率）来自真实交易所；订单、成交、仓位、手续费与资金费
+// 在内存中按回测同一套队列位置撮合模拟，并像用户数据流一样推送 OnOrderUpdate/OnAccountUpdate。
+type PaperExchange struct {
+	*sim.SimExchange
+
+	feed MarketFeed
+	opts Options
+
+	mu      sync.Mutex
+	funding map[string]*fundingState
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// New 创建模拟盘交易所
+func New(feed MarketFeed, opts Options) *PaperExchange {
+	if opts.FundingPoll <= 0 {
+		opts.FundingPoll = defaultFundingPoll
+	}
+	engine := sim.NewSimExchange(wallClock{}, opts.MakerFeeRate, opts.InitialBalance)
+	engine.SetOrderIDPrefix("paper")
+	return &PaperExchange{
+		SimExchange: engine,
+		feed:        feed,
+		opts:        opts,
+		funding:     make(map[string]*fundingState),
+	}
+}
+
+// StartDepthStream 订阅真实深度与逐笔成交并驱动内存撮合，随后连接行情WS
+func (p *PaperExchange) StartDepthStream(ctx context.Context, symbols []string, callback func(*gateway.Depth)) error {
+	if err := p.SimExchange.StartDepthStream(ctx, symbols, callback); err != nil {
+		return err
+	}
+	if err := p.feed.StartTradeStream(ctx, symbols, p.SimExchange.ApplyTrade); err != nil {
+		return err
+	}
+	err := p.feed.StartDepthStream(ctx, symbols, func(depth *gateway.Depth) {
+		p.SimExchange.ApplyDepth(depth.Symbol, depth.Bids, depth.Asks)
+	})
+	if err != nil {
+		return err
+	}
+	// 订阅完成后再连接，保证组合流包含全部stream
+	if err := p.feed.Connect(ctx); err != nil {
+		return err
+	}
+
+	loopCtx, cancel := context.WithCancel(ctx)
+	p.mu.Lock()
+	p.cancel = cancel
+	p.mu.Unlock()
+
+	p.wg.Add(1)
+	go p.runFunding(loopCtx, symbols)
+
+	log.Info().Strs("symbols", symbols).Msg("[模拟盘] 行情已接入，订单在内存中撮合")
+	return nil
+}
+
+// GetFundingRate 返回真实资金费率
+func (p *PaperExchange) GetFundingRate(ctx context.Context, symbol string) (*gateway.FundingRate, error) {
+	return p.feed.GetFundingRate(ctx, symbol)
+}
+
+// ReconnectMarketData 转发给真实行情来源（行情过期看门狗使用）
+func (p *PaperExchange) ReconnectMarketData(symbol string) error {
+	sup, ok := p.feed.(gateway.MarketDataSupervisor)
+	if !ok {
+		return fmt.Errorf("market data reconnect not supported")
+	}
+	return sup.ReconnectMarketData(symbol)
+}
+
+// Disconnect 停止资金费轮询并断开行情
+func (p *PaperExchange) Disconnect() error {
+	p.mu.Lock()
+	cancel := p.cancel
+	p.cancel = nil
+	p.mu.Unlock()
+	if cancel != nil {
+		cancel()
+		p.wg.Wait()
+	}
+
+	if err := p.feed.Disconnect(); err != nil {
+		log.Error().Err(err).Msg("[模拟盘] 断开行情失败")
+	}
+	return p.SimExchange.Disconnect()
+}
+
+// runFunding 定期拉取资金费率，跨过结算时间点时按当时中间价结算资金费
+func (p *PaperExchange) runFunding(ctx context.Context, symbols []string) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.opts.FundingPoll)
+	defer ticker.Stop()
+
+	p.pollFunding(ctx, symbols)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.pollFunding(ctx, symbols)
+		}
+	}
+}
+
+func (p *PaperExchange) pollFunding(ctx context.Context, symbols []string) {
+	now := time.Now()
+	for _, symbol := range symbols {
+		fr, err := p.feed.GetFundingRate(ctx, symbol)
+		if err != nil {
+			log.Warn().Err(err).Str("symbol", symbol).Msg("[模拟盘] 获取资金费率失败")
+			continue
+		}
+		p.updateFunding(symbol, fr, now)
+	}
+}
+
+// updateFunding 记录最新预测费率；now越过上次记录的结算时间时，按结算前的费率结算
+func (p *PaperExchange) updateFunding(symbol string, fr *gateway.FundingRate, now time.Time) {
+	p.mu.Lock()
+	st := p.funding[symbol]
+	if st == nil {
+		st = &fundingState{}
+		p.funding[symbol] = st
+	}
+	settle := !st.next.IsZero() && !now.Before(st.next) && st.next.After(st.settled)
+	if settle {
+		st.settled = st.next
+	}
+	rate := st.rate
+	st.rate = fr.Rate
+	if fr.NextFundingTime.After(st.next) {
+		st.next = fr.NextFundingTime
+	}
+	p.mu.Unlock()
+
+	if !settle {
+		return
+	}
+	mark := p.SimExchange.MidPrice(symbol)
+	p.SimExchange.ApplyFunding(symbol, rate, mark)
+	log.Info().
+		Str("symbol", symbol).
+		Float64("rate", rate).
+		Float64("mark", mark).
+		Msg("[模拟盘] 资金费已结算")
+}
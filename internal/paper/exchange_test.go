@@ -0,0 +1,149 @@
+package paper
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+)
+
+// fakeFeed 手动推送行情的MarketFeed
+type fakeFeed struct {
+	mu        sync.Mutex
+	depthCb   func(*gateway.Depth)
+	tradeCb   func(*gateway.Trade)
+	connected bool
+	rate      float64
+	next      time.Time
+}
+
+func (f *fakeFeed) Connect(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.connected = true
+	return nil
+}
+
+func (f *fakeFeed) Disconnect() error { return nil }
+
+func (f *fakeFeed) StartDepthStream(ctx context.Context, symbols []string, cb func(*gateway.Depth)) error {
+	f.depthCb = cb
+	return nil
+}
+
+func (f *fakeFeed) StartTradeStream(ctx context.Context, symbols []string, cb func(*gateway.Trade)) error {
+	f.tradeCb = cb
+	return nil
+}
+
+func (f *fakeFeed) GetFundingRate(ctx context.Context, symbol string) (*gateway.FundingRate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &gateway.FundingRate{Symbol: symbol, Rate: f.rate, NextFundingTime: f.next}, nil
+}
+
+func TestPaperExchangeFillsAgainstFeed(t *testing.T) {
+	feed := &fakeFeed{next: time.Now().Add(time.Hour)}
+	ex := New(feed, Options{MakerFeeRate: 0.0002, InitialBalance: 1000, FundingPoll: time.Hour})
+	ctx := context.Background()
+
+	var (
+		mu        sync.Mutex
+		orders    []gateway.Order
+		positions []gateway.Position
+		depths    int
+	)
+	if err := ex.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	if err := ex.StartDepthStream(ctx, []string{"ETHUSDC"}, func(*gateway.Depth) { depths++ }); err != nil {
+		t.Fatalf("start depth: %v", err)
+	}
+	defer ex.Disconnect()
+	ex.StartUserStream(ctx, &gateway.UserStreamCallbacks{
+		OnOrderUpdate: func(o *gateway.Order) {
+			mu.Lock()
+			orders = append(orders, *o)
+			mu.Unlock()
+		},
+		OnAccountUpdate: func(ps []*gateway.Position) {
+			mu.Lock()
+			positions = append(positions, *ps[0])
+			mu.Unlock()
+		},
+	})
+	if !feed.connected {
+		t.Fatal("feed should be connected after subscribing")
+	}
+
+	feed.depthCb(&gateway.Depth{
+		Symbol: "ETHUSDC",
+		Bids:   []gateway.PriceLevel{{Price: 100, Quantity: 1}},
+		Asks:   []gateway.PriceLevel{{Price: 100.1, Quantity: 1}},
+	})
+	if depths != 1 {
+		t.Fatalf("depth should be forwarded to runner, got %d", depths)
+	}
+
+	placed, err := ex.PlaceOrder(ctx, &gateway.Order{Symbol: "ETHUSDC", Side: "BUY", Price: 100, Quantity: 0.5})
+	if err != nil {
+		t.Fatalf("place: %v", err)
+	}
+	if !strings.HasPrefix(placed.ClientOrderID, "paper-ETHUSDC-") {
+		t.Fatalf("unexpected client id %s", placed.ClientOrderID)
+	}
+
+	// 卖方主动成交1.5手：1手消耗队列，0.5手成交本单
+	feed.tradeCb(&gateway.Trade{Symbol: "ETHUSDC", Price: 100, Quantity: 1.5, IsBuyerMaker: true})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(orders) != 1 || orders[0].Status != "FILLED" || orders[0].ClientOrderID != placed.ClientOrderID {
+		t.Fatalf("expected fill update, got %+v", orders)
+	}
+	if len(positions) != 1 || positions[0].Size != 0.5 || positions[0].EntryPrice != 100 {
+		t.Fatalf("expected account update, got %+v", positions)
+	}
+	balance, _, _ := ex.GetAccountBalance(ctx)
+	if math.Abs(balance-(1000-0.5*100*0.0002)) > 1e-9 {
+		t.Fatalf("fee not charged, balance=%f", balance)
+	}
+}
+
+func TestPaperExchangeFundingSettlement(t *testing.T) {
+	ex := New(&fakeFeed{}, Options{InitialBalance: 1000})
+	ctx := context.Background()
+	ex.Connect(ctx)
+	ex.ApplyDepth("ETHUSDC",
+		[]gateway.PriceLevel{{Price: 100, Quantity: 5}},
+		[]gateway.PriceLevel{{Price: 100.2, Quantity: 5}})
+
+	// 卖单被主动买单穿越后成交，形成1手空仓
+	if _, err := ex.PlaceOrder(ctx, &gateway.Order{Symbol: "ETHUSDC", Side: "SELL", Price: 100.2, Quantity: 1}); err != nil {
+		t.Fatalf("place: %v", err)
+	}
+	ex.ApplyTrade(&gateway.Trade{Symbol: "ETHUSDC", Price: 100.3, Quantity: 1})
+
+	var fundings []float64
+	ex.StartUserStream(ctx, &gateway.UserStreamCallbacks{
+		OnFunding: func(f *gateway.FundingRate) { fundings = append(fundings, f.Rate) },
+	})
+
+	settleAt := time.Unix(1700000000, 0)
+	ex.updateFunding("ETHUSDC", &gateway.FundingRate{Rate: 0.001, NextFundingTime: settleAt}, settleAt.Add(-time.Minute))
+	// 越过结算点：按结算前费率结算；交易所尚未刷新下次结算时间时不重复结算
+	ex.updateFunding("ETHUSDC", &gateway.FundingRate{Rate: 0.0005, NextFundingTime: settleAt}, settleAt.Add(time.Second))
+	ex.updateFunding("ETHUSDC", &gateway.FundingRate{Rate: 0.0005, NextFundingTime: settleAt}, settleAt.Add(time.Minute))
+
+	if len(fundings) != 1 || fundings[0] != 0.001 {
+		t.Fatalf("expected one settlement at 0.001, got %v", fundings)
+	}
+	// 空头在正费率下收取资金费：1 * 100.1 * 0.001
+	if st := ex.Stats("ETHUSDC"); math.Abs(st.Funding-0.1001) > 1e-9 {
+		t.Fatalf("unexpected funding %f", st.Funding)
+	}
+}
@@ -0,0 +1,329 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/newplayman/market-maker-phoenix/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// 压缩格式
+const (
+	CompressionZstd = "zstd"
+	CompressionGzip = "gzip"
+	CompressionNone = "none"
+)
+
+// UserStreamKey 用户数据流（listenKey）消息的归档目录名
+const UserStreamKey = "user"
+
+// Record 录制文件中的一行（NDJSON）：接收时间戳 + 交易所原始消息
+type Record struct {
+	RecvTime int64           `json:"ts"`  // 本地接收时间（Unix毫秒）
+	Raw      json.RawMessage `json:"raw"` // combined stream 原始消息
+}
+
+// Options 录制参数
+type Options struct {
+	Dir          string        // 输出根目录，文件按 {Dir}/{SYMBOL}/{YYYY-MM-DD}.ndjson.zst 组织
+	Compression  string        // zstd(默认) | gzip | none
+	MaxFileBytes int64         // 单个文件写入的未压缩字节上限，0表示只按天切分
+	BufferSize   int           // 异步队列长度，默认8192
+	FlushEvery   time.Duration // 定时刷盘间隔，默认1秒
+}
+
+type entry struct {
+	key  string
+	ts   time.Time
+	data []byte
+}
+
+// Recorder 将原始WS消息异步写入按交易对、按天切分的压缩NDJSON文件。
+// Record 不会阻塞调用方：队列满时丢弃消息并计入 Dropped。
+type Recorder struct {
+	opts Options
+	now  func() time.Time
+
+	ch      chan entry
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+	dropped atomic.Int64
+
+	files map[string]*segment // 仅由写协程访问
+}
+
+// New 创建录制器并启动写协程
+func New(opts Options) (*Recorder, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("record dir required")
+	}
+	switch opts.Compression {
+	case "":
+		opts.Compression = CompressionZstd
+	case CompressionZstd, CompressionGzip, CompressionNone:
+	default:
+		return nil, fmt.Errorf("unsupported compression %q", opts.Compression)
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 8192
+	}
+	if opts.FlushEvery <= 0 {
+		opts.FlushEvery = time.Second
+	}
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建录制目录失败: %w", err)
+	}
+
+	r := &Recorder{
+		opts:  opts,
+		now:   time.Now,
+		ch:    make(chan entry, opts.BufferSize),
+		done:  make(chan struct{}),
+		files: make(map[string]*segment),
+	}
+	r.wg.Add(1)
+	go r.loop()
+
+	log.Info().
+		Str("dir", opts.Dir).
+		Str("compression", opts.Compression).
+		Msg("行情录制器已启动")
+	return r, nil
+}
+
+// Record 记录一条原始消息（以当前时间作为接收时间）
+func (r *Recorder) Record(raw []byte) {
+	if len(raw) == 0 {
+		return
+	}
+	select {
+	case <-r.done:
+		return
+	default:
+	}
+
+	// 复制一份，调用方可能复用读缓冲
+	e := entry{key: StreamKey(raw), ts: r.now(), data: append([]byte(nil), raw...)}
+	select {
+	case r.ch <- e:
+	default:
+		if r.dropped.Add(1)%1000 == 1 {
+			log.Warn().Int64("dropped", r.dropped.Load()).Msg("录制队列已满，丢弃消息")
+		}
+		metrics.RecordError("recorder_dropped", e.key)
+	}
+}
+
+// Dropped 返回因队列满被丢弃的消息数
+func (r *Recorder) Dropped() int64 {
+	return r.dropped.Load()
+}
+
+// Close 写完队列中剩余消息并关闭所有文件
+func (r *Recorder) Close() error {
+	r.once.Do(func() {
+		close(r.done)
+	})
+	r.wg.Wait()
+	return nil
+}
+
+func (r *Recorder) loop() {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.opts.FlushEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e := <-r.ch:
+			r.write(e)
+		case <-ticker.C:
+			r.flushAll()
+		case <-r.done:
+			for {
+				select {
+				case e := <-r.ch:
+					r.write(e)
+				default:
+					r.closeAll()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) write(e entry) {
+	if !json.Valid(e.data) {
+		log.Debug().Str("key", e.key).Msg("非JSON消息，跳过录制")
+		return
+	}
+
+	seg, err := r.segmentFor(e.key, e.ts)
+	if err != nil {
+		log.Error().Err(err).Str("key", e.key).Msg("打开录制文件失败")
+		return
+	}
+	n, err := fmt.Fprintf(seg.w, "{\"ts\":%d,\"raw\":%s}\n", e.ts.UnixMilli(), e.data)
+	if err != nil {
+		log.Error().Err(err).Str("path", seg.path).Msg("写入录制文件失败")
+		return
+	}
+	seg.written += int64(n)
+}
+
+// segmentFor 返回当前应写入的文件，跨天或超过大小上限时切换新文件
+func (r *Recorder) segmentFor(key string, ts time.Time) (*segment, error) {
+	day := ts.UTC().Format("2006-01-02")
+	seg := r.files[key]
+	if seg != nil && seg.day == day && (r.opts.MaxFileBytes <= 0 || seg.written < r.opts.MaxFileBytes) {
+		return seg, nil
+	}
+
+	part := 0
+	if seg != nil {
+		if seg.day == day {
+			part = seg.part + 1
+		}
+		if err := seg.close(); err != nil {
+			log.Error().Err(err).Str("path", seg.path).Msg("关闭录制文件失败")
+		}
+		delete(r.files, key)
+	}
+
+	dir := filepath.Join(r.opts.Dir, key)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	seg, err := openSegment(filepath.Join(dir, segmentName(day, part, r.opts.Compression)), r.opts.Compression)
+	if err != nil {
+		return nil, err
+	}
+	seg.day = day
+	seg.part = part
+	r.files[key] = seg
+
+	log.Info().Str("path", seg.path).Msg("切换录制文件")
+	return seg, nil
+}
+
+func (r *Recorder) flushAll() {
+	for _, seg := range r.files {
+		if err := seg.flush(); err != nil {
+			log.Error().Err(err).Str("path", seg.path).Msg("刷新录制文件失败")
+		}
+	}
+}
+
+func (r *Recorder) closeAll() {
+	for key, seg := range r.files {
+		if err := seg.close(); err != nil {
+			log.Error().Err(err).Str("path", seg.path).Msg("关闭录制文件失败")
+		}
+		delete(r.files, key)
+	}
+}
+
+func segmentName(day string, part int, compression string) string {
+	name := day
+	if part > 0 {
+		name = fmt.Sprintf("%s.%d", day, part)
+	}
+	name += ".ndjson"
+	switch compression {
+	case CompressionZstd:
+		name += ".zst"
+	case CompressionGzip:
+		name += ".gz"
+	}
+	return name
+}
+
+// StreamKey 根据combined stream名称提取归档目录：行情流取交易对大写，用户数据流归入"user"
+func StreamKey(raw []byte) string {
+	var msg struct {
+		Stream string `json:"stream"`
+	}
+	if err := json.Unmarshal(raw, &msg); err != nil || msg.Stream == "" {
+		return UserStreamKey
+	}
+	if idx := strings.Index(msg.Stream, "@"); idx > 0 {
+		return strings.ToUpper(msg.Stream[:idx])
+	}
+	return UserStreamKey
+}
+
+// segment 单个录制文件（追加写入；gzip/zstd多帧拼接可被正常解压）
+type segment struct {
+	path    string
+	day     string
+	part    int
+	written int64
+
+	file *os.File
+	buf  *bufio.Writer
+	enc  io.WriteCloser // 无压缩时为nil
+	w    io.Writer
+}
+
+func openSegment(path, compression string) (*segment, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	seg := &segment{path: path, file: f, buf: bufio.NewWriterSize(f, 64*1024)}
+
+	switch compression {
+	case CompressionZstd:
+		enc, err := zstd.NewWriter(seg.buf)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		seg.enc = enc
+	case CompressionGzip:
+		seg.enc = gzip.NewWriter(seg.buf)
+	}
+
+	seg.w = seg.buf
+	if seg.enc != nil {
+		seg.w = seg.enc
+	}
+	return seg, nil
+}
+
+func (s *segment) flush() error {
+	if f, ok := s.enc.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return s.buf.Flush()
+}
+
+func (s *segment) close() error {
+	if s.enc != nil {
+		if err := s.enc.Close(); err != nil {
+			s.file.Close()
+			return err
+		}
+	}
+	if err := s.buf.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
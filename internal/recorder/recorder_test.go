@@ -0,0 +1,110 @@
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStreamKey(t *testing.T) {
+	cases := map[string]string{
+		`{"stream":"ethusdc@depth20@100ms","data":{}}`: "ETHUSDC",
+		`{"stream":"btcusdt@aggTrade","data":{}}`:      "BTCUSDT",
+		`{"stream":"abcListenKey","data":{}}`:          UserStreamKey,
+		`not json`:                                     UserStreamKey,
+	}
+	for raw, want := range cases {
+		if got := StreamKey([]byte(raw)); got != want {
+			t.Errorf("StreamKey(%s) = %s, want %s", raw, got, want)
+		}
+	}
+}
+
+func readAll(t *testing.T, path string) []Record {
+	t.Helper()
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("open %s: %v", path, err)
+	}
+	defer r.Close()
+
+	var recs []Record
+	if err := ReadRecords(r, func(rec Record) error {
+		recs = append(recs, rec)
+		return nil
+	}); err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return recs
+}
+
+func TestRecorderRoundTrip(t *testing.T) {
+	for _, compression := range []string{CompressionZstd, CompressionGzip, CompressionNone} {
+		t.Run(compression, func(t *testing.T) {
+			dir := t.TempDir()
+			rec, err := New(Options{Dir: dir, Compression: compression})
+			if err != nil {
+				t.Fatalf("new: %v", err)
+			}
+
+			now := time.Date(2025, 1, 1, 23, 59, 59, 0, time.UTC)
+			rec.now = func() time.Time { return now }
+			rec.Record([]byte(`{"stream":"ethusdc@depth20@100ms","data":{"s":"ETHUSDC"}}`))
+			rec.Record([]byte(`{"stream":"listenKey","data":{"e":"ORDER_TRADE_UPDATE"}}`))
+			rec.Record([]byte(`garbage`))
+			// 接收时间跨天，写入新文件
+			now = now.Add(2 * time.Second)
+			rec.Record([]byte(`{"stream":"ethusdc@aggTrade","data":{"s":"ETHUSDC"}}`))
+			if err := rec.Close(); err != nil {
+				t.Fatalf("close: %v", err)
+			}
+
+			day1 := filepath.Join(dir, "ETHUSDC", segmentName("2025-01-01", 0, compression))
+			day2 := filepath.Join(dir, "ETHUSDC", segmentName("2025-01-02", 0, compression))
+			user := filepath.Join(dir, UserStreamKey, segmentName("2025-01-01", 0, compression))
+
+			if recs := readAll(t, day1); len(recs) != 1 || recs[0].RecvTime != time.Date(2025, 1, 1, 23, 59, 59, 0, time.UTC).UnixMilli() {
+				t.Fatalf("unexpected day1 records: %+v", recs)
+			}
+			if recs := readAll(t, day2); len(recs) != 1 {
+				t.Fatalf("unexpected day2 records: %+v", recs)
+			}
+			if recs := readAll(t, user); len(recs) != 1 {
+				t.Fatalf("unexpected user records: %+v", recs)
+			}
+		})
+	}
+}
+
+func TestRecorderSizeRotationAndAppend(t *testing.T) {
+	dir := t.TempDir()
+	rec, err := New(Options{Dir: dir, MaxFileBytes: 1})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	rec.now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+	rec.Record([]byte(`{"stream":"ethusdc@aggTrade","data":{}}`))
+	rec.Record([]byte(`{"stream":"ethusdc@aggTrade","data":{}}`))
+	rec.Close()
+
+	for part := 0; part < 2; part++ {
+		path := filepath.Join(dir, "ETHUSDC", segmentName("2025-01-01", part, CompressionZstd))
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected part %d: %v", part, err)
+		}
+	}
+
+	// 重启后追加写入同一文件，多帧拼接仍可完整读出
+	rec, err = New(Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	rec.now = func() time.Time { return time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC) }
+	rec.Record([]byte(`{"stream":"ethusdc@aggTrade","data":{}}`))
+	rec.Close()
+
+	if recs := readAll(t, filepath.Join(dir, "ETHUSDC", segmentName("2025-01-01", 0, CompressionZstd))); len(recs) != 2 {
+		t.Fatalf("expected 2 records after append, got %d", len(recs))
+	}
+}
@@ -0,0 +1,264 @@
+package risk
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+)
+
+// Portfolio 组合收益统计：按固定间隔对全部交易对的中间价同时采样，
+// 在滚动窗口内用对数收益估计协方差与相关系数矩阵
+type Portfolio struct {
+	mu       sync.Mutex
+	interval time.Duration
+	window   int
+
+	symbols  []string
+	index    map[string]int
+	lastMid  []float64
+	lastTime time.Time
+	returns  [][]float64 // 每个样本为各交易对同一时段的收益
+	cov      [][]float64 // 新样本到达时重算
+}
+
+// NewPortfolio 创建组合收益统计，symbols 决定矩阵的行列顺序
+func NewPortfolio(cfg config.PortfolioConfig, symbols []string) *Portfolio {
+	index := make(map[string]int, len(symbols))
+	for i, s := range symbols {
+		index[s] = i
+	}
+	return &Portfolio{
+		interval: cfg.GetSampleInterval(),
+		window:   cfg.GetWindow(),
+		symbols:  append([]string(nil), symbols...),
+		index:    index,
+		lastMid:  make([]float64, len(symbols)),
+	}
+}
+
+// Observe 距上次采样超过采样间隔时记录一次中间价；任一交易对缺少价格时只更新基准价，不产生收益样本。
+// 返回是否产生了新样本
+func (p *Portfolio) Observe(now time.Time, mids map[string]float64) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.lastTime.IsZero() && now.Sub(p.lastTime) < p.interval {
+		return false
+	}
+	p.lastTime = now
+
+	sample := make([]float64, len(p.symbols))
+	complete := true
+	for i, s := range p.symbols {
+		mid := mids[s]
+		if mid <= 0 || p.lastMid[i] <= 0 {
+			complete = false
+		} else {
+			sample[i] = math.Log(mid / p.lastMid[i])
+		}
+		if mid > 0 {
+			p.lastMid[i] = mid
+		}
+	}
+	if !complete {
+		return false
+	}
+	p.returns = append(p.returns, sample)
+	if len(p.returns) > p.window {
+		p.returns = p.returns[len(p.returns)-p.window:]
+	}
+	p.cov = covariance(p.returns)
+	return true
+}
+
+// Covariance 返回单个采样间隔收益的协方差矩阵与样本数；样本不足2个时矩阵为nil
+func (p *Portfolio) Covariance() ([][]float64, int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cov, len(p.returns)
+}
+
+// Symbols 矩阵的行列顺序
+func (p *Portfolio) Symbols() []string {
+	return p.symbols
+}
+
+// Correlation 相关系数矩阵，方差为0的交易对与其他交易对的相关系数记为0
+func (p *Portfolio) Correlation() [][]float64 {
+	cov, _ := p.Covariance()
+	if cov == nil {
+		return nil
+	}
+	n := len(cov)
+	corr := make([][]float64, n)
+	for i := range corr {
+		corr[i] = make([]float64, n)
+		for j := range corr[i] {
+			if d := math.Sqrt(cov[i][i] * cov[j][j]); d > 0 {
+				corr[i][j] = cov[i][j] / d
+			}
+		}
+	}
+	return corr
+}
+
+// covariance 样本协方差（n-1）
+func covariance(samples [][]float64) [][]float64 {
+	n := len(samples)
+	if n < 2 {
+		return nil
+	}
+	k := len(samples[0])
+	mean := make([]float64, k)
+	for _, s := range samples {
+		for i, v := range s {
+			mean[i] += v / float64(n)
+		}
+	}
+	cov := make([][]float64, k)
+	for i := range cov {
+		cov[i] = make([]float64, k)
+	}
+	for _, s := range samples {
+		for i := 0; i < k; i++ {
+			di := s[i] - mean[i]
+			for j := i; j < k; j++ {
+				cov[i][j] += di * (s[j] - mean[j])
+			}
+		}
+	}
+	for i := 0; i < k; i++ {
+		for j := i; j < k; j++ {
+			cov[i][j] /= float64(n - 1)
+			cov[j][i] = cov[i][j]
+		}
+	}
+	return cov
+}
+
+// PortfolioRisk 组合风险快照
+type PortfolioRisk struct {
+	Ready        bool               // 样本数是否达到 min_samples
+	Samples      int                // 收益样本数
+	Delta        map[string]float64 // 各交易对美元Delta（含对冲腿）
+	NetDelta     float64            // 净美元Delta
+	Beta         map[string]float64 // 相对 beta_symbol 的beta
+	BetaWeighted float64            // beta加权美元Delta
+	VaR          float64            // 持有期参数法VaR ($)
+	ES           float64            // 持有期参数法ES ($)
+	Utilization  float64            // max(VaR/var_limit, ES/es_limit)
+	marginal     map[string]float64 // (Σd)_i：正表示该交易对多头方向增加组合方差
+}
+
+// AddsRisk 该交易对指定方向的成交是否增加组合方差
+func (p PortfolioRisk) AddsRisk(symbol, side string) bool {
+	m := p.marginal[symbol]
+	if side == "BUY" {
+		return m > 0
+	}
+	return m < 0
+}
+
+// PortfolioRisk 计算组合风险：美元Delta为仓位×中间价，对冲腿计入被对冲的交易对（假定两者收益一致）；
+// VaR = zσ，ES = σφ(z)/(1-α)，σ² = dᵀΣd × 持有期内的采样间隔数
+func (r *RiskManager) PortfolioRisk() PortfolioRisk {
+	pc := &r.cfg.Global.Portfolio
+	res := PortfolioRisk{Delta: r.signedExposures(), Beta: make(map[string]float64)}
+	for _, d := range res.Delta {
+		res.NetDelta += d
+	}
+	if r.portfolio == nil {
+		return res
+	}
+
+	cov, n := r.portfolio.Covariance()
+	res.Samples = n
+	if cov == nil {
+		return res
+	}
+	symbols := r.portfolio.Symbols()
+	d := make([]float64, len(symbols))
+	for i, s := range symbols {
+		d[i] = res.Delta[s]
+	}
+
+	// beta：cov(i, b) / var(b)
+	bench := pc.BetaSymbol
+	if bench == "" {
+		bench = symbols[0]
+	}
+	if b, ok := r.portfolio.index[bench]; ok && cov[b][b] > 0 {
+		for i, s := range symbols {
+			res.Beta[s] = cov[i][b] / cov[b][b]
+			res.BetaWeighted += d[i] * res.Beta[s]
+		}
+	}
+
+	res.marginal = make(map[string]float64, len(symbols))
+	var variance float64
+	for i, s := range symbols {
+		var m float64
+		for j := range symbols {
+			m += cov[i][j] * d[j]
+		}
+		res.marginal[s] = m
+		variance += d[i] * m
+	}
+	periods := float64(pc.GetHorizon()) / float64(pc.GetSampleInterval())
+	sigma := math.Sqrt(math.Max(variance, 0) * periods)
+	conf := pc.GetConfidence()
+	z := math.Sqrt2 * math.Erfinv(2*conf-1)
+	res.VaR = z * sigma
+	res.ES = sigma * math.Exp(-z*z/2) / math.Sqrt(2*math.Pi) / (1 - conf)
+
+	if pc.VaRLimit > 0 {
+		res.Utilization = res.VaR / pc.VaRLimit
+	}
+	if pc.ESLimit > 0 {
+		res.Utilization = math.Max(res.Utilization, res.ES/pc.ESLimit)
+	}
+	res.Ready = n >= pc.GetMinSamples()
+	return res
+}
+
+// ObservePortfolio 采样各交易对中间价（按 sample_interval_ms 限频，可在每次报价前调用）
+func (r *RiskManager) ObservePortfolio(now time.Time) {
+	if r.portfolio == nil {
+		return
+	}
+	mids := make(map[string]float64)
+	for _, symbol := range r.store.GetAllSymbols() {
+		state := r.store.GetSymbolState(symbol)
+		if state == nil {
+			continue
+		}
+		state.Mu.RLock()
+		mids[symbol] = state.MidPrice
+		state.Mu.RUnlock()
+	}
+	r.portfolio.Observe(now, mids)
+}
+
+// PortfolioSideScales 按组合风险返回该交易对买卖两侧报价量的缩放系数：
+// 风险利用率低于 throttle_ratio 时不限制；之后增加组合风险的一侧线性收缩，达到限额时为0（禁止），
+// 降低组合风险的一侧不受限制。未启用或样本不足时返回 1, 1
+func (r *RiskManager) PortfolioSideScales(symbol string) (buy, sell float64) {
+	if r.portfolio == nil {
+		return 1, 1
+	}
+	res := r.PortfolioRisk()
+	throttle := r.cfg.Global.Portfolio.GetThrottleRatio()
+	if !res.Ready || res.Utilization < throttle {
+		return 1, 1
+	}
+	scale := math.Min(math.Max((1-res.Utilization)/(1-throttle), 0), 1)
+	buy, sell = 1, 1
+	if res.AddsRisk(symbol, "BUY") {
+		buy = scale
+	}
+	if res.AddsRisk(symbol, "SELL") {
+		sell = scale
+	}
+	return buy, sell
+}
@@ -0,0 +1,93 @@
+package risk
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+)
+
+// newPortfolioManager 两个交易对，ETH收益为BTC的2倍（完全正相关），采样 n 个收益样本
+func newPortfolioManager(t *testing.T, pc config.PortfolioConfig, n int) (*RiskManager, *store.Store) {
+	t.Helper()
+	pc.Enabled = true
+	cfg := &config.Config{
+		Global:  config.GlobalConfig{TotalNotionalMax: 1e9, Portfolio: pc},
+		Symbols: []config.SymbolConfig{{Symbol: "BTCUSDT", NetMax: 1}, {Symbol: "ETHUSDT", NetMax: 10}},
+	}
+	st := store.NewStore("", time.Minute)
+	st.InitSymbol("BTCUSDT", 100)
+	st.InitSymbol("ETHUSDT", 100)
+	rm := NewRiskManager(cfg, st)
+
+	start := time.Unix(1700000000, 0)
+	for i := 0; i <= n; i++ {
+		r := 0.0
+		if i%2 == 1 {
+			r = 0.001
+		}
+		btc, eth := 100*math.Exp(r), 100*math.Exp(2*r)
+		st.UpdateMidPrice("BTCUSDT", btc, btc-0.01, btc+0.01)
+		st.UpdateMidPrice("ETHUSDT", eth, eth-0.01, eth+0.01)
+		rm.ObservePortfolio(start.Add(time.Duration(i) * time.Second))
+	}
+	return rm, st
+}
+
+func TestPortfolio_CorrelationAndBeta(t *testing.T) {
+	rm, st := newPortfolioManager(t, config.PortfolioConfig{MinSamples: 10}, 20)
+
+	corr := rm.portfolio.Correlation()
+	if math.Abs(corr[0][1]-1) > 1e-6 {
+		t.Fatalf("期望完全正相关，实际%v", corr)
+	}
+
+	// 多 1 BTC、空 1 ETH：净美元Delta约为0，但ETH波动是BTC的2倍，beta加权后仍为净空
+	st.UpdatePosition("BTCUSDT", store.Position{Symbol: "BTCUSDT", Size: 1})
+	st.UpdatePosition("ETHUSDT", store.Position{Symbol: "ETHUSDT", Size: -1})
+	res := rm.PortfolioRisk()
+	if !res.Ready || res.Samples != 20 {
+		t.Fatalf("期望20个样本，实际%+v", res)
+	}
+	if math.Abs(res.Beta["ETHUSDT"]-2) > 1e-3 || math.Abs(res.Beta["BTCUSDT"]-1) > 1e-9 {
+		t.Fatalf("beta不符合预期: %v", res.Beta)
+	}
+	if math.Abs(res.NetDelta) > 0.2 || res.BetaWeighted > -99 {
+		t.Fatalf("Delta不符合预期: net=%v beta=%v", res.NetDelta, res.BetaWeighted)
+	}
+	if res.VaR <= 0 || res.ES <= res.VaR {
+		t.Fatalf("期望VaR为正且ES大于VaR，实际var=%v es=%v", res.VaR, res.ES)
+	}
+}
+
+func TestPortfolioSideScales(t *testing.T) {
+	// 多 1 BTC：σ≈0.1$/s，60秒持有期 99% VaR ≈ 1.8$
+	rm, st := newPortfolioManager(t, config.PortfolioConfig{MinSamples: 10, VaRLimit: 1}, 20)
+	st.UpdatePosition("BTCUSDT", store.Position{Symbol: "BTCUSDT", Size: 1})
+
+	// 超过限额：增加组合风险的一侧（买BTC、与之正相关的买ETH）禁止，减仓一侧不受限制
+	buy, sell := rm.PortfolioSideScales("BTCUSDT")
+	if buy != 0 || sell != 1 {
+		t.Fatalf("BTCUSDT: 期望禁止买入，实际buy=%v sell=%v", buy, sell)
+	}
+	buy, sell = rm.PortfolioSideScales("ETHUSDT")
+	if buy != 0 || sell != 1 {
+		t.Fatalf("ETHUSDT: 正相关品种也应禁止买入，实际buy=%v sell=%v", buy, sell)
+	}
+
+	// 利用率介于 throttle_ratio 与 1 之间：线性收缩
+	rm.cfg.Global.Portfolio.VaRLimit = 2
+	buy, sell = rm.PortfolioSideScales("BTCUSDT")
+	if buy <= 0 || buy >= 1 || sell != 1 {
+		t.Fatalf("期望买入侧被收缩，实际buy=%v sell=%v", buy, sell)
+	}
+
+	// 样本不足时不限制
+	rm, st = newPortfolioManager(t, config.PortfolioConfig{MinSamples: 30, VaRLimit: 1}, 20)
+	st.UpdatePosition("BTCUSDT", store.Position{Symbol: "BTCUSDT", Size: 1})
+	if buy, sell := rm.PortfolioSideScales("BTCUSDT"); buy != 1 || sell != 1 {
+		t.Fatalf("样本不足时不应限制，实际buy=%v sell=%v", buy, sell)
+	}
+}
@@ -16,18 +16,37 @@ type Quote struct {
 	Layer int
 }
 
+// HedgeExposure 对冲腿敞口
+type HedgeExposure struct {
+	Symbol      string  // 被对冲的做市交易对
+	HedgeSymbol string  // 对冲品种
+	Size        float64 // 对冲仓位（正为多，负为空）
+	Notional    float64 // 带符号的对冲仓位名义价值
+}
+
+// HedgeSource 对冲腿敞口来源（hedge引擎实现）
+type HedgeSource interface {
+	HedgeExposures() []HedgeExposure
+}
+
 // RiskManager 风控管理器
 type RiskManager struct {
-	cfg   *config.Config
-	store *store.Store
+	cfg       *config.Config
+	store     *store.Store
+	hedges    HedgeSource
+	portfolio *Portfolio // 未启用组合风险时为nil
 }
 
 // NewRiskManager 创建风控管理器
 func NewRiskManager(cfg *config.Config, st *store.Store) *RiskManager {
-	return &RiskManager{
+	r := &RiskManager{
 		cfg:   cfg,
 		store: st,
 	}
+	if cfg.Global.Portfolio.Enabled && len(cfg.Symbols) > 0 {
+		r.portfolio = NewPortfolio(cfg.Global.Portfolio, cfg.GetAllSymbols())
+	}
+	return r
 }
 
 func (r *RiskManager) CheckPreTrade(symbol string, side string, size float64) error {
@@ -246,7 +265,7 @@ func (r *RiskManager) CheckStopLoss(symbol string) (shouldStop bool, reason stri
 	state.Mu.RLock()
 	unrealizedPNL := state.Position.UnrealizedPNL
 	notional := state.Position.Notional
-	maxDrawdown := state.MaxDrawdown
+	drawdown := state.Mark.Drawdown(&state.Ledger)
 	state.Mu.RUnlock()
 
 	// 检查未实现亏损
@@ -258,11 +277,11 @@ func (r *RiskManager) CheckStopLoss(symbol string) (shouldStop bool, reason stri
 		}
 	}
 
-	// 检查最大回撤
+	// 检查账本净值距高水位的回撤（含已实现盈亏、手续费与资金费）
 	if notional > 0 {
-		drawdownRatio := maxDrawdown / notional
+		drawdownRatio := drawdown / notional
 		if drawdownRatio > symCfg.StopLossThresh*1.5 {
-			return true, fmt.Sprintf("最大回撤 %.2f%% 超过阈值 %.2f%%",
+			return true, fmt.Sprintf("净值回撤 %.2f%% 超过阈值 %.2f%%",
 				drawdownRatio*100, symCfg.StopLossThresh*1.5*100)
 		}
 	}
@@ -367,13 +386,65 @@ func (r *RiskManager) OnFill(symbol string, side string, size, pnl float64) {
 	}
 }
 
-// CheckGlobal 全局风控检查
+// SetHedgeSource 设置对冲腿敞口来源，需在Runner启动前调用
+func (r *RiskManager) SetHedgeSource(src HedgeSource) {
+	r.hedges = src
+}
+
+// PortfolioExposure 组合敞口：gross 为做市仓位与对冲仓位名义价值绝对值之和；
+// net 中被对冲的交易对与其对冲腿的带符号名义价值先相抵再取绝对值
+func (r *RiskManager) PortfolioExposure() (gross, net float64) {
+	signed, gross := r.exposures()
+	for _, v := range signed {
+		net += math.Abs(v)
+	}
+	return gross, net
+}
+
+// signedExposures 各交易对带符号的美元Delta，对冲腿计入被对冲的交易对
+func (r *RiskManager) signedExposures() map[string]float64 {
+	signed, _ := r.exposures()
+	return signed
+}
+
+// exposures 各交易对带符号的名义价值（仓位×中间价，无中间价时用开仓均价）与全部腿的名义价值绝对值之和
+func (r *RiskManager) exposures() (map[string]float64, float64) {
+	signed := make(map[string]float64)
+	var gross float64
+	for _, symbol := range r.store.GetAllSymbols() {
+		state := r.store.GetSymbolState(symbol)
+		if state == nil {
+			continue
+		}
+		state.Mu.RLock()
+		pos := state.Position.Size
+		price := state.MidPrice
+		if price <= 0 {
+			price = state.Position.EntryPrice
+		}
+		state.Mu.RUnlock()
+		signed[symbol] = pos * price
+		gross += math.Abs(pos * price)
+	}
+	if r.hedges != nil {
+		for _, h := range r.hedges.HedgeExposures() {
+			signed[h.Symbol] += h.Notional
+			gross += math.Abs(h.Notional)
+		}
+	}
+	return signed, gross
+}
+
+// CheckGlobal 全局风控检查；配置了对冲时按对冲相抵后的净敞口检查
 func (r *RiskManager) CheckGlobal() error {
 	totalNotional := r.store.GetTotalNotional()
+	if r.hedges != nil {
+		_, totalNotional = r.PortfolioExposure()
+	}
 
 	// 检查是否超过全局上限
 	if totalNotional > r.cfg.Global.TotalNotionalMax {
-		return fmt.Errorf("总名义价值 %.2f 超过上限 %.2f，暂停所有交易",
+		return fmt.Errorf("总名义价值 %.2f 超过上限 %.2f，仅允许减仓",
 			totalNotional, r.cfg.Global.TotalNotionalMax)
 	}
 
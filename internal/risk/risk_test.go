@@ -2,6 +2,7 @@ package risk
 
 import (
 	"testing"
+	"time"
 
 	"github.com/newplayman/market-maker-phoenix/internal/config"
 	"github.com/newplayman/market-maker-phoenix/internal/store"
@@ -22,7 +23,7 @@ func TestCheckBatchPreTrade_LightPositionPrinciple(t *testing.T) {
 	}
 
 	// 创建store
-	st := store.NewStore("./test_snapshot.json", 60)
+	st := store.NewStore("", time.Minute)
 	st.InitSymbol("ETHUSDC", 3600)
 
 	// 更新仓位为0
@@ -126,7 +127,7 @@ func TestCheckBatchPreTrade_AsymmetricPositions(t *testing.T) {
 		},
 	}
 
-	st := store.NewStore("./test_snapshot.json", 60)
+	st := store.NewStore("", time.Minute)
 	st.InitSymbol("ETHUSDC", 3600)
 
 	// 多头仓位0.06 ETH（40% NetMax）
@@ -184,7 +185,7 @@ func TestCheckBatchPreTrade_EdgeCases(t *testing.T) {
 		},
 	}
 
-	st := store.NewStore("./test_snapshot.json", 60)
+	st := store.NewStore("", time.Minute)
 	st.InitSymbol("ETHUSDC", 3600)
 
 	pos := store.Position{
@@ -222,3 +223,33 @@ func TestCheckBatchPreTrade_EdgeCases(t *testing.T) {
 		t.Errorf("超过50%% NetMax应该失败，但通过了")
 	}
 }
+
+type staticHedges []HedgeExposure
+
+func (h staticHedges) HedgeExposures() []HedgeExposure { return h }
+
+// TestCheckGlobal_NetsHedgeLegs 对冲腿与被对冲交易对的敞口相抵后再检查全局上限
+func TestCheckGlobal_NetsHedgeLegs(t *testing.T) {
+	cfg := &config.Config{
+		Global:  config.GlobalConfig{TotalNotionalMax: 2000},
+		Symbols: []config.SymbolConfig{{Symbol: "ETHUSDC", NetMax: 2}},
+	}
+	st := store.NewStore("", time.Minute)
+	st.InitSymbol("ETHUSDC", 100)
+	st.UpdatePosition("ETHUSDC", store.Position{Symbol: "ETHUSDC", Size: 1, EntryPrice: 2990, Notional: 2990})
+	st.UpdateMidPrice("ETHUSDC", 3000, 2999, 3001)
+
+	rm := NewRiskManager(cfg, st)
+	if err := rm.CheckGlobal(); err == nil {
+		t.Fatal("未对冲时总名义价值超限应拒绝")
+	}
+
+	rm.SetHedgeSource(staticHedges{{Symbol: "ETHUSDC", HedgeSymbol: "ETHUSDT", Size: -0.8, Notional: -2404}})
+	gross, net := rm.PortfolioExposure()
+	if gross != 5404 || net != 596 {
+		t.Fatalf("期望 gross=5404 net=596，实际 gross=%v net=%v", gross, net)
+	}
+	if err := rm.CheckGlobal(); err != nil {
+		t.Fatalf("对冲后净敞口未超限，不应拒绝: %v", err)
+	}
+}
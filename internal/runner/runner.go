@@ -3,6 +3,7 @@ package runner
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"sync"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/newplayman/market-maker-phoenix/internal/config"
 	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/newplayman/market-maker-phoenix/internal/filters"
 	"github.com/newplayman/market-maker-phoenix/internal/metrics"
 	"github.com/newplayman/market-maker-phoenix/internal/order"
 	"github.com/newplayman/market-maker-phoenix/internal/risk"
@@ -18,6 +20,9 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+// fillQtyEpsilon 成交量比较容差（累计成交量与已记账成交量相减的浮点误差）
+const fillQtyEpsilon = 1e-12
+
 // Runner 核心运行器
 type Runner struct {
 	cfg      *config.Config
@@ -28,6 +33,21 @@ type Runner struct {
 	om       *order.OrderManager
 	dryRun   bool
 
+	// filterSource 交易规则来源（交易所未实现时为nil，报价不做交易规则校验）
+	filterSource gateway.SymbolFilterSource
+
+	// externalOrders 接收同一账户上非做市交易对（如对冲腿）的订单推送，未设置时忽略
+	externalOrders func(*gateway.Order)
+
+	// staleReconnects 行情过期看门狗最近一次强制重连时间，防止重连风暴
+	staleReconnects map[string]time.Time
+
+	// schedulers 各交易对的事件驱动报价调度
+	schedulers map[string]*requoteScheduler
+
+	// now 时间源，默认time.Now；回测时注入虚拟时钟
+	now func() time.Time
+
 	wg       sync.WaitGroup
 	stopChan chan struct{}
 	stopped  bool
@@ -43,15 +63,45 @@ func NewRunner(
 	exch gateway.Exchange,
 ) *Runner {
 	om := order.NewOrderManager(st, exch)
+	filterSource, _ := exch.(gateway.SymbolFilterSource)
+	schedulers := make(map[string]*requoteScheduler, len(cfg.Symbols))
+	for _, symCfg := range cfg.Symbols {
+		schedulers[symCfg.Symbol] = newRequoteScheduler()
+		om.SetQueueRetention(symCfg.Symbol, symCfg.QueueRetentionBoost)
+	}
 	return &Runner{
-		cfg:      cfg,
-		store:    st,
-		strategy: strat,
-		risk:     riskMgr,
-		exchange: exch,
-		om:       om,
-		stopChan: make(chan struct{}),
+		cfg:          cfg,
+		store:        st,
+		strategy:     strat,
+		risk:         riskMgr,
+		exchange:     exch,
+		om:           om,
+		filterSource: filterSource,
+		now:          time.Now,
+		stopChan:     make(chan struct{}),
+
+		staleReconnects: make(map[string]time.Time),
+		schedulers:      schedulers,
+	}
+}
+
+// SetFilterSource 指定交易规则来源（模拟盘使用真实交易所的交易规则）
+func (r *Runner) SetFilterSource(src gateway.SymbolFilterSource) {
+	r.filterSource = src
+}
+
+// SetExternalOrderHandler 设置非做市交易对订单推送的接收方（同账户对冲腿），需在Start前调用
+func (r *Runner) SetExternalOrderHandler(handler func(*gateway.Order)) {
+	r.externalOrders = handler
+}
+
+// SetClock 替换Runner使用的时间源（回测引擎注入虚拟时钟）
+func (r *Runner) SetClock(clock func() time.Time) {
+	if clock == nil {
+		clock = time.Now
 	}
+	r.now = clock
+	r.om.SetClock(clock)
 }
 
 // Start 启动Runner
@@ -63,6 +113,38 @@ func (r *Runner) Start(ctx context.Context) error {
 	}
 	r.mu.Unlock()
 
+	// 同步交易规则并校验手工配置的 tick_size / min_qty
+	if err := r.SyncFilters(ctx); err != nil {
+		return err
+	}
+
+	if err := r.StartStreams(ctx); err != nil {
+		return err
+	}
+
+	// 为每个交易对启动独立的协程
+	for _, symCfg := range r.cfg.Symbols {
+		r.wg.Add(1)
+		go r.runSymbol(ctx, symCfg.Symbol)
+	}
+
+	// 启动全局监控协程
+	r.wg.Add(1)
+	go r.runGlobalMonitor(ctx)
+
+	// 定期刷新交易规则
+	if r.filterSource != nil {
+		r.wg.Add(1)
+		go r.runFilterRefresh(ctx)
+	}
+
+	log.Info().Msg("Runner启动完成")
+	return nil
+}
+
+// StartStreams 连接交易所并注册深度流与用户数据流回调，不启动做市循环
+// 回测引擎调用此方法后，通过ProcessSymbol按虚拟时钟逐步驱动报价
+func (r *Runner) StartStreams(ctx context.Context) error {
 	// 连接交易所
 	log.Info().Msg("正在连接交易所...")
 	if err := r.exchange.Connect(ctx); err != nil {
@@ -81,6 +163,14 @@ func (r *Runner) Start(ctx context.Context) error {
 	}
 	log.Info().Msg("深度流启动成功")
 
+	// 启动逐笔成交流
+	if err := r.exchange.StartTradeStream(ctx, symbols, r.onTrade); err != nil {
+		// 逐笔成交仅用于信号估计，失败时降级运行
+		log.Warn().Err(err).Msg("启动逐笔成交流失败，相关信号将不可用")
+	} else {
+		log.Info().Msg("逐笔成交流启动成功")
+	}
+
 	// 启动用户数据流
 	log.Info().Msg("正在启动用户数据流...")
 	callbacks := &gateway.UserStreamCallbacks{
@@ -93,17 +183,6 @@ func (r *Runner) Start(ctx context.Context) error {
 	}
 	log.Info().Msg("用户数据流启动成功")
 
-	// 为每个交易对启动独立的协程
-	for _, symCfg := range r.cfg.Symbols {
-		r.wg.Add(1)
-		go r.runSymbol(ctx, symCfg.Symbol)
-	}
-
-	// 启动全局监控协程
-	r.wg.Add(1)
-	go r.runGlobalMonitor(ctx)
-
-	log.Info().Msg("Runner启动完成")
 	return nil
 }
 
@@ -159,6 +238,16 @@ func (r *Runner) runSymbol(ctx context.Context, symbol string) {
 
 	log.Info().Str("symbol", symbol).Msg("启动交易对做市循环")
 
+	sched := r.schedulers[symbol]
+	if !r.cfg.IsEventRequote() || sched == nil {
+		r.runTicker(ctx, symbol)
+		return
+	}
+	r.runEventLoop(ctx, symbol, sched)
+}
+
+// runTicker 按quote_interval_ms固定间隔报价
+func (r *Runner) runTicker(ctx context.Context, symbol string) {
 	ticker := time.NewTicker(r.cfg.GetQuoteInterval())
 	defer ticker.Stop()
 
@@ -171,17 +260,95 @@ func (r *Runner) runSymbol(ctx context.Context, symbol string) {
 			log.Info().Str("symbol", symbol).Msg("收到停止信号")
 			return
 		case <-ticker.C:
-			if err := r.processSymbol(ctx, symbol); err != nil {
-				log.Error().
-					Err(err).
-					Str("symbol", symbol).
-					Msg("处理交易对失败")
-				metrics.RecordError("process_symbol", symbol)
+			r.requote(ctx, symbol)
+		}
+	}
+}
+
+// runEventLoop 事件驱动报价：中间价移动、成交、仓位或配置变化时重新报价，
+// 两次报价间隔不小于最小间隔（期间的事件合并为一次），无事件时按心跳间隔报价
+func (r *Runner) runEventLoop(ctx context.Context, symbol string, sched *requoteScheduler) {
+	minInterval := r.cfg.GetRequoteMinInterval()
+	heartbeat := time.NewTimer(r.cfg.GetRequoteHeartbeat())
+	defer heartbeat.Stop()
+
+	sched.trigger(ReasonStart)
+	var last time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info().Str("symbol", symbol).Msg("收到退出信号")
+			return
+		case <-r.stopChan:
+			log.Info().Str("symbol", symbol).Msg("收到停止信号")
+			return
+		case <-sched.wake:
+		case <-heartbeat.C:
+			sched.trigger(ReasonHeartbeat)
+		}
+
+		// 距上次报价不足最小间隔时等待，期间到达的事件一并处理
+		if wait := minInterval - r.now().Sub(last); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.stopChan:
+				return
+			case <-time.After(wait):
 			}
 		}
+
+		reasons := sched.take()
+		if len(reasons) == 0 {
+			continue
+		}
+		for _, reason := range reasons {
+			metrics.RecordRequote(symbol, string(reason))
+		}
+		log.Debug().Str("symbol", symbol).Interface("reasons", reasons).Msg("重新报价")
+
+		r.requote(ctx, symbol)
+		last = r.now()
+
+		if !heartbeat.Stop() {
+			select {
+			case <-heartbeat.C:
+			default:
+			}
+		}
+		heartbeat.Reset(r.cfg.GetRequoteHeartbeat())
+	}
+}
+
+// requote 执行一轮报价，失败时记录错误
+func (r *Runner) requote(ctx context.Context, symbol string) {
+	if err := r.processSymbol(ctx, symbol); err != nil {
+		log.Error().
+			Err(err).
+			Str("symbol", symbol).
+			Msg("处理交易对失败")
+		metrics.RecordError("process_symbol", symbol)
+	}
+}
+
+// RequestRequote 请求尽快重新报价；symbol为空时作用于所有交易对
+func (r *Runner) RequestRequote(symbol string, reason RequoteReason) {
+	if symbol == "" {
+		for _, sched := range r.schedulers {
+			sched.trigger(reason)
+		}
+		return
+	}
+	if sched := r.schedulers[symbol]; sched != nil {
+		sched.trigger(reason)
 	}
 }
 
+// ProcessSymbol 执行一轮报价处理（回测引擎按虚拟时钟调用）
+func (r *Runner) ProcessSymbol(ctx context.Context, symbol string) error {
+	return r.processSymbol(ctx, symbol)
+}
+
 // processSymbol 处理单个交易对
 func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 	startTime := time.Now()
@@ -196,11 +363,12 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 	if symCfg != nil {
 		state := r.store.GetSymbolState(symbol)
 		if state != nil {
+			now := r.now()
 			state.Mu.Lock()
-			if time.Since(state.LastCancelReset) > time.Minute {
+			if now.Sub(state.LastCancelReset) > time.Minute {
 				oldCount := state.CancelCountLast
 				state.CancelCountLast = 0
-				state.LastCancelReset = time.Now()
+				state.LastCancelReset = now
 				if oldCount > 0 {
 					log.Info().
 						Str("symbol", symbol).
@@ -208,13 +376,17 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 						Msg("撤单计数器已重置（每分钟自动）")
 				}
 			}
+			if now.Sub(state.LastAmendReset) > time.Minute {
+				state.AmendCountLast = 0
+				state.LastAmendReset = now
+			}
 			state.Mu.Unlock()
 		}
 	}
 
-	// 【新增】同步当前本地订单状态（从exchange拉取）
-	// 必须在检查溢出前同步，否则一旦溢出就会因状态无法更新而陷入死循环
-	if err := r.om.SyncActiveOrders(ctx, symbol); err != nil {
+	// 【新增】本地订单状态由订单推送与下单/撤单确认驱动；首次、请求确认超时或到达兜底间隔时与REST对账
+	// 必须在检查溢出前对账，否则一旦溢出就会因状态无法更新而陷入死循环
+	if err := r.om.ReconcileIfDue(ctx, symbol); err != nil {
 		log.Error().Err(err).Str("symbol", symbol).Msg("同步活跃订单失败")
 		return err
 	}
@@ -235,6 +407,7 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 		if err := r.exchange.CancelAllOrders(ctx, symbol); err != nil {
 			log.Error().Err(err).Str("symbol", symbol).Msg("紧急撤单失败")
 		}
+		r.om.RequestReconcile(symbol)
 
 		// 停止该策略的做市循环 (返回错误停止本轮交易)
 		return fmt.Errorf("订单数量溢出(%d)，触发紧急撤单", activeOrdersCount)
@@ -242,6 +415,8 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 
 	// 【关键修复】检查价格数据新鲜度 - 防止WebSocket静默断流导致假死
 	// 将阈值从10秒降低到3秒，更快检测异常
+	// quotedMid 本轮报价使用的中间价，报价实际下达后作为调度器判断中间价移动的基准
+	var quotedMid float64
 	state := r.store.GetSymbolState(symbol)
 	if state != nil {
 		state.Mu.RLock()
@@ -250,18 +425,20 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 		state.Mu.RUnlock()
 
 		// 如果价格从未更新（刚启动且WSS未推）或超过3秒未更新
-		if lastUpdate.IsZero() || time.Since(lastUpdate) > 3*time.Second {
+		staleDuration := r.now().Sub(lastUpdate)
+		if lastUpdate.IsZero() || staleDuration > 3*time.Second {
 			log.Error().
 				Str("symbol", symbol).
 				Time("last_update", lastUpdate).
-				Dur("stale_duration", time.Since(lastUpdate)).
+				Dur("stale_duration", staleDuration).
 				Float64("mid", midPrice).
 				Msg("【告警】价格数据过期，停止报价！WebSocket可能断流")
-			
+
 			// 记录错误到metrics
 			metrics.RecordError("stale_price_data", symbol)
 			return nil
 		}
+		quotedMid = midPrice
 	}
 
 	// 1. 检查止损
@@ -309,6 +486,12 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 	r.store.UpdatePendingOrders(symbol, 0, 0)
 
 	buyQuotes, sellQuotes, err := r.strategy.GenerateQuotes(ctx, symbol)
+	if errors.Is(err, strategy.ErrHighToxicity) {
+		// 订单流毒性过高：以空报价继续执行差分，撤销全部挂单
+		log.Warn().Str("symbol", symbol).Msg("订单流毒性过高，撤销挂单并暂停报价")
+		buyQuotes, sellQuotes, err = nil, nil, nil
+		quotedMid = 0
+	}
 	if err != nil {
 		return fmt.Errorf("生成报价失败: %w", err)
 	}
@@ -384,6 +567,9 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 			Msg("报价已生成（统一几何网格）")
 	}
 
+	// 组合风控：总名义价值超限时只保留减仓方向，组合VaR/ES接近或超过限额时收缩或禁止增加组合风险的一侧
+	buyQuotes, sellQuotes = r.applyPortfolioRisk(symbol, buyQuotes, sellQuotes)
+
 	// 5. 批量风控检查（新增）- 确保轻仓做市原则
 	// 检查所有挂单累计风险，防止满仓
 	buyRiskQuotes := make([]risk.Quote, len(buyQuotes))
@@ -420,7 +606,7 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 		}
 	}
 
-	// 7. 转换为exchange.Order并进行Pre-Trade风控校验
+	// 7. 转换为exchange.Order并进行Pre-Trade风控校验，价格与数量按交易规则取整
 	desiredBuyOrders := make([]*gateway.Order, 0, len(buyQuotes))
 	for _, quote := range buyQuotes {
 		// Pre-Trade风控检查：每个买单都需要通过风控校验
@@ -433,12 +619,16 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 				Msg("买单未通过风控校验，跳过此单")
 			continue
 		}
+		price, qty, ok := r.applyFilters(symbol, "BUY", quote.Price, quote.Size)
+		if !ok {
+			continue
+		}
 		desiredBuyOrders = append(desiredBuyOrders, &gateway.Order{
 			Symbol:   symbol,
 			Side:     "BUY",
 			Type:     "LIMIT",
-			Quantity: quote.Size,
-			Price:    quote.Price,
+			Quantity: qty,
+			Price:    price,
 		})
 	}
 
@@ -454,17 +644,21 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 				Msg("卖单未通过风控校验，跳过此单")
 			continue
 		}
+		price, qty, ok := r.applyFilters(symbol, "SELL", quote.Price, quote.Size)
+		if !ok {
+			continue
+		}
 		desiredSellOrders = append(desiredSellOrders, &gateway.Order{
 			Symbol:   symbol,
 			Side:     "SELL",
 			Type:     "LIMIT",
-			Quantity: quote.Size,
-			Price:    quote.Price,
+			Quantity: qty,
+			Price:    price,
 		})
 	}
 
 	// 8. 同步当前本地订单状态（已移至函数开头）
-	// if err := r.om.SyncActiveOrders(ctx, symbol); err != nil { ... }
+	// if err := r.om.ReconcileIfDue(ctx, symbol); err != nil { ... }
 
 	// 9. 计算订单差分，获取待撤销和待新增订单
 	symCfg = r.cfg.GetSymbolConfig(symbol)
@@ -516,17 +710,30 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 			Msg("防闪烁容差计算完成")
 	}
 
-	toCancel, toPlace := r.om.CalculateOrderDiff(symbol, desiredBuyOrders, desiredSellOrders, tolerance)
+	toCancel, toAmend, toPlace := r.om.CalculateOrderDiff(symbol, desiredBuyOrders, desiredSellOrders, tolerance)
 
 	// 10. 应用差分，执行撤单和新单下单
 	if r.dryRun {
 		log.Info().
 			Str("symbol", symbol).
 			Int("to_cancel", len(toCancel)).
+			Int("to_amend", len(toAmend)).
 			Int("to_place", len(toPlace)).
 			Msg("[Dry-Run模式] 模拟执行订单差分操作，未实际下单")
 	} else {
-		if err := r.om.ApplyDiff(ctx, symbol, toCancel, toPlace); err != nil {
+		if err := r.om.ApplyDiff(ctx, symbol, toCancel, toAmend, toPlace); err != nil {
+			if category := gateway.CategoryOf(err); category != gateway.CategoryNone {
+				metrics.RecordError("order_"+string(category), symbol)
+			}
+			switch {
+			case errors.Is(err, gateway.ErrRateLimited):
+				// 限速器已按响应头校准，本轮放弃，下个周期按剩余额度继续
+				log.Warn().Err(err).Str("symbol", symbol).Msg("交易所限频，本轮订单差分未完成")
+				return nil
+			case errors.Is(err, gateway.ErrInsufficientMargin):
+				log.Warn().Err(err).Str("symbol", symbol).Msg("保证金不足，部分新单被拒")
+				return nil
+			}
 			log.Error().Err(err).Str("symbol", symbol).Msg("应用订单差分失败")
 			return err
 		}
@@ -535,6 +742,7 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 	log.Debug().
 		Str("symbol", symbol).
 		Int("to_cancel", len(toCancel)).
+		Int("to_amend", len(toAmend)).
 		Int("to_place", len(toPlace)).
 		Msg("订单差分处理完成")
 
@@ -544,12 +752,95 @@ func (r *Runner) processSymbol(ctx context.Context, symbol string) error {
 		Int("sell_quotes", len(sellQuotes)).
 		Msg("报价已下达")
 
+	// 报价已下达后才更新中间价基准；止损、撤单频率、VPIN暂停或差分失败时保留旧基准，
+	// 之后的中间价移动仍会触发重新报价
+	if sched := r.schedulers[symbol]; sched != nil && quotedMid > 0 {
+		sched.quoted(quotedMid)
+	}
+
 	// 10. 更新指标
 	r.updateSymbolMetrics(symbol)
 
 	return nil
 }
 
+// applyFilters 按交易规则对报价取整，未通过时记录指标并丢弃
+func (r *Runner) applyFilters(symbol, side string, price, qty float64) (float64, float64, bool) {
+	f := r.store.GetSymbolFilters(symbol)
+	p, q, err := f.Apply(side, price, qty)
+	if err != nil {
+		reason := filters.ReasonInvalid
+		var rej *filters.RejectError
+		if errors.As(err, &rej) {
+			reason = rej.Reason
+		}
+		metrics.RecordQuoteRejection(symbol, side, reason)
+		log.Debug().
+			Err(err).
+			Str("symbol", symbol).
+			Str("side", side).
+			Msg("报价未通过交易规则，跳过此单")
+		return 0, 0, false
+	}
+	return p, q, true
+}
+
+// applyPortfolioRisk 报价前的组合风控：全局检查未通过时只保留减仓方向的报价；
+// 组合风险利用率超过 throttle_ratio 后按比例收缩增加组合风险一侧的报价量，达到限额时撤销该侧报价
+func (r *Runner) applyPortfolioRisk(symbol string, buyQuotes, sellQuotes []strategy.Quote) ([]strategy.Quote, []strategy.Quote) {
+	r.risk.ObservePortfolio(r.now())
+	buyScale, sellScale := r.risk.PortfolioSideScales(symbol)
+
+	if err := r.risk.CheckGlobal(); err != nil {
+		var pos float64
+		if state := r.store.GetSymbolState(symbol); state != nil {
+			state.Mu.RLock()
+			pos = state.Position.Size
+			state.Mu.RUnlock()
+		}
+		if pos >= 0 {
+			buyScale = 0
+		}
+		if pos <= 0 {
+			sellScale = 0
+		}
+		log.Warn().Err(err).Str("symbol", symbol).Float64("pos", pos).Msg("全局风控未通过，仅保留减仓方向报价")
+	}
+
+	metrics.UpdatePortfolioSideScale(symbol, "BUY", buyScale)
+	metrics.UpdatePortfolioSideScale(symbol, "SELL", sellScale)
+	if buyScale >= 1 && sellScale >= 1 {
+		return buyQuotes, sellQuotes
+	}
+	log.Warn().
+		Str("symbol", symbol).
+		Float64("buy_scale", buyScale).
+		Float64("sell_scale", sellScale).
+		Msg("组合风控：收缩增加组合风险一侧的报价")
+
+	var minQty float64
+	if symCfg := r.cfg.GetSymbolConfig(symbol); symCfg != nil {
+		minQty = symCfg.MinQty
+	}
+	return scaleQuotes(buyQuotes, buyScale, minQty), scaleQuotes(sellQuotes, sellScale, minQty)
+}
+
+// scaleQuotes 按比例缩放报价量，缩放后低于最小下单量的报价丢弃
+func scaleQuotes(quotes []strategy.Quote, scale, minQty float64) []strategy.Quote {
+	if scale >= 1 {
+		return quotes
+	}
+	scaled := make([]strategy.Quote, 0, len(quotes))
+	for _, q := range quotes {
+		q.Size *= scale
+		if q.Size <= 0 || q.Size < minQty {
+			continue
+		}
+		scaled = append(scaled, q)
+	}
+	return scaled
+}
+
 // adjustQuotesForRisk 根据风控要求调整报价数量和大小
 // 当批量风控检查失败时，削减挂单层数以满足轻仓做市原则
 func (r *Runner) adjustQuotesForRisk(symbol string, buyQuotes, sellQuotes []strategy.Quote) ([]strategy.Quote, []strategy.Quote) {
@@ -668,6 +959,10 @@ func (r *Runner) onDepthUpdate(depth *gateway.Depth) {
 	midPrice := (bestBid + bestAsk) / 2.0
 
 	r.store.UpdateMidPrice(depth.Symbol, midPrice, bestBid, bestAsk)
+	r.om.OnBook(depth)
+	if sched := r.schedulers[depth.Symbol]; sched != nil {
+		sched.observeMid(midPrice, r.cfg.GetRequoteMidMoveBps())
+	}
 
 	log.Debug().
 		Str("symbol", depth.Symbol).
@@ -677,11 +972,33 @@ func (r *Runner) onDepthUpdate(depth *gateway.Depth) {
 		Msg("深度更新")
 }
 
+// onTrade 处理逐笔成交：写入Store成交带并转发给策略
+func (r *Runner) onTrade(trade *gateway.Trade) {
+	if trade == nil {
+		return
+	}
+	r.store.RecordTrade(trade.Symbol, trade.Price, trade.Quantity, !trade.IsBuyerMaker, trade.Timestamp)
+	r.om.OnMarketTrade(trade.Symbol, trade.Price, trade.Quantity, !trade.IsBuyerMaker)
+
+	if obs, ok := r.strategy.(strategy.MarketObserver); ok {
+		obs.OnTrade(trade.Symbol, trade.Price, trade.Quantity)
+	}
+}
+
 // onOrderUpdate 处理订单更新
 func (r *Runner) onOrderUpdate(order *gateway.Order) {
 	if order == nil {
 		return
 	}
+	if r.cfg.GetSymbolConfig(order.Symbol) == nil {
+		// 同一账户上对冲腿等非做市交易对的订单，转交对冲引擎处理
+		if r.externalOrders != nil {
+			r.externalOrders(order)
+			return
+		}
+		log.Debug().Str("symbol", order.Symbol).Str("status", order.Status).Msg("忽略非做市交易对的订单更新")
+		return
+	}
 
 	log.Info().
 		Str("symbol", order.Symbol).
@@ -690,22 +1007,60 @@ func (r *Runner) onOrderUpdate(order *gateway.Order) {
 		Float64("filled", order.FilledQty).
 		Msg("订单更新")
 
-	// 如果订单完全成交，记录成交
-	if order.Status == "FILLED" {
-		// 计算PNL（简化版，实际需要根据仓位计算）
-		pnl := 0.0
-		r.store.RecordFill(order.Symbol, order.FilledQty, pnl)
-		metrics.RecordFill(order.Symbol, order.Side, order.FilledQty)
+	// 推送驱动本地订单状态机
+	r.om.OnOrderUpdate(order)
+
+	// 交易所侧撤单/过期：写入事件日志
+	if order.Status == "CANCELED" || order.Status == "EXPIRED" {
+		r.store.RecordOrderCanceled(order.Symbol, order.ClientOrderID)
+	}
+
+	switch order.Status {
+	case "FILLED", "PARTIALLY_FILLED":
+		r.RequestRequote(order.Symbol, ReasonFill)
+	case "CANCELED", "EXPIRED", "REJECTED":
+		r.RequestRequote(order.Symbol, ReasonOrder)
+	}
+
+	// 逐笔记入成交：成交推送带有本次成交明细时按明细记账（含部分成交），
+	// 否则（不提供明细的交易所实现）在完全成交时按累计成交量扣除该订单已记账的部分记账
+	fillQty, fillPrice := order.LastFilledQty, order.LastFilledPrice
+	if fillQty <= 0 && order.Status == "FILLED" {
+		fillQty, fillPrice = order.FilledQty-r.store.BookedFillQty(order.Symbol, order.ClientOrderID), order.Price
+		if fillQty <= fillQtyEpsilon {
+			// 各次成交已按明细记账，只结束该挂单
+			r.store.RecordOrderFill(order.Symbol, store.FillEvent{ClientOrderID: order.ClientOrderID, Side: order.Side, Final: true})
+		}
+	}
+	if fillQty > fillQtyEpsilon {
+		r.store.RecordOrderFill(order.Symbol, store.FillEvent{
+			ClientOrderID: order.ClientOrderID,
+			Side:          order.Side,
+			Price:         fillPrice,
+			Quantity:      fillQty,
+			PNL:           order.RealizedPNL,
+			Fee:           order.Commission,
+			Maker:         order.IsMaker,
+			Final:         order.Status == "FILLED",
+		})
+		metrics.RecordFill(order.Symbol, order.Side, fillQty)
+
+		// 本次成交明细反馈给需要在线估计订单到达强度的策略（累计成交量会在每次部分成交时重复计入）
+		if obs, ok := r.strategy.(strategy.MarketObserver); ok {
+			obs.OnFill(order.Symbol, order.Side, fillPrice, fillQty)
+		}
 
 		// Log structured TRADE_EVENT for dashboard
 		tradeEvent := map[string]interface{}{
 			"type":      "TRADE",
 			"symbol":    order.Symbol,
 			"side":      order.Side,
-			"price":     order.Price,
-			"quantity":  order.FilledQty,
-			"pnl":       pnl,
-			"timestamp": time.Now().Unix(),
+			"price":     fillPrice,
+			"quantity":  fillQty,
+			"pnl":       order.RealizedPNL - order.Commission,
+			"fee":       order.Commission,
+			"maker":     order.IsMaker,
+			"timestamp": r.now().Unix(),
 		}
 		jsonBytes, _ := json.Marshal(tradeEvent)
 		log.Info().RawJSON("trade_data", jsonBytes).Msg("TRADE_EVENT")
@@ -715,10 +1070,18 @@ func (r *Runner) onOrderUpdate(order *gateway.Order) {
 // onAccountUpdate 处理账户更新
 func (r *Runner) onAccountUpdate(positions []*gateway.Position) {
 	for _, pos := range positions {
-		if pos == nil {
+		if pos == nil || r.cfg.GetSymbolConfig(pos.Symbol) == nil {
+			// 非做市交易对（如同一账户上的对冲腿）不写入Store，避免与对冲敞口重复计入
 			continue
 		}
 
+		var prevSize float64
+		if state := r.store.GetSymbolState(pos.Symbol); state != nil {
+			state.Mu.RLock()
+			prevSize = state.Position.Size
+			state.Mu.RUnlock()
+		}
+
 		// 更新Store中的仓位
 		storePos := store.Position{
 			Symbol:        pos.Symbol,
@@ -729,6 +1092,9 @@ func (r *Runner) onAccountUpdate(positions []*gateway.Position) {
 			Leverage:      pos.Leverage,
 		}
 		r.store.UpdatePosition(pos.Symbol, storePos)
+		if math.Abs(pos.Size-prevSize) > 1e-12 {
+			r.RequestRequote(pos.Symbol, ReasonPosition)
+		}
 
 		log.Info().
 			Str("symbol", pos.Symbol).
@@ -745,6 +1111,18 @@ func (r *Runner) onFundingUpdate(funding *gateway.FundingRate) {
 		return
 	}
 
+	// 资金费结算记入账本；实盘的结算推送只带金额，不覆盖费率
+	if funding.Payment != 0 {
+		r.store.RecordFundingPayment(funding.Symbol, funding.Payment)
+		log.Info().
+			Str("symbol", funding.Symbol).
+			Float64("payment", funding.Payment).
+			Msg("资金费结算")
+		if funding.Rate == 0 {
+			return
+		}
+	}
+
 	// 更新Store中的资金费率
 	r.store.UpdateFundingRate(funding.Symbol, funding.Rate)
 
@@ -754,6 +1132,83 @@ func (r *Runner) onFundingUpdate(funding *gateway.FundingRate) {
 		Msg("资金费率更新")
 }
 
+// SyncFilters 从交易所加载交易规则写入store，并校验配置的 tick_size / min_qty
+func (r *Runner) SyncFilters(ctx context.Context) error {
+	return r.syncFilters(ctx, true)
+}
+
+// syncFilters strict为true（启动时）遇到任何问题返回错误；定期刷新时仅记录告警并保留已有规则
+func (r *Runner) syncFilters(ctx context.Context, strict bool) error {
+	if r.filterSource == nil {
+		if strict {
+			log.Warn().Msg("交易所未提供交易规则，报价不做tick/step/最小名义价值校验")
+		}
+		return nil
+	}
+
+	symbols := r.cfg.GetAllSymbols()
+	got, err := r.filterSource.SymbolFilters(ctx, symbols)
+	if err != nil {
+		metrics.RecordError("filter_sync", "global")
+		if strict {
+			return fmt.Errorf("同步交易规则失败: %w", err)
+		}
+		log.Error().Err(err).Msg("刷新交易规则失败，沿用已有规则")
+		return nil
+	}
+
+	for _, symCfg := range r.cfg.Symbols {
+		f, ok := got[symCfg.Symbol]
+		if !ok {
+			metrics.RecordError("filter_sync", symCfg.Symbol)
+			if strict {
+				return fmt.Errorf("交易所未返回 %s 的交易规则", symCfg.Symbol)
+			}
+			log.Error().Str("symbol", symCfg.Symbol).Msg("交易所未返回交易规则，沿用已有规则")
+			continue
+		}
+		if err := f.CheckConfig(symCfg.TickSize, symCfg.MinQty); err != nil {
+			metrics.RecordError("filter_config_mismatch", symCfg.Symbol)
+			if strict {
+				return fmt.Errorf("配置与交易规则不一致: %w", err)
+			}
+			log.Error().Err(err).Str("symbol", symCfg.Symbol).Msg("交易规则已变更，配置不再匹配，请更新配置")
+		}
+
+		if old := r.store.GetSymbolFilters(symCfg.Symbol); old != f {
+			log.Info().
+				Str("symbol", symCfg.Symbol).
+				Float64("tick_size", f.TickSize).
+				Float64("step_size", f.StepSize).
+				Float64("min_qty", f.MinQty).
+				Float64("max_qty", f.MaxQty).
+				Float64("min_notional", f.MinNotional).
+				Msg("交易规则已更新")
+			r.store.SetSymbolFilters(symCfg.Symbol, f)
+		}
+	}
+	return nil
+}
+
+// runFilterRefresh 定期刷新交易规则，应对交易所调整tick或最小下单量
+func (r *Runner) runFilterRefresh(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.cfg.GetFilterRefreshInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.stopChan:
+			return
+		case <-ticker.C:
+			_ = r.syncFilters(ctx, false)
+		}
+	}
+}
+
 // runGlobalMonitor 运行全局监控
 func (r *Runner) runGlobalMonitor(ctx context.Context) {
 	defer r.wg.Done()
@@ -808,14 +1263,20 @@ func (r *Runner) monitorGlobalState() {
 	totalNotional := r.store.GetTotalNotional()
 	metrics.TotalNotional.Set(totalNotional)
 
-	// 检查总名义价值上限
-	if totalNotional > r.cfg.Global.TotalNotionalMax {
+	// 检查总名义价值上限（配置对冲时按相抵后的净敞口）
+	if err := r.risk.CheckGlobal(); err != nil {
 		log.Warn().
+			Err(err).
 			Float64("total_notional", totalNotional).
 			Float64("max", r.cfg.Global.TotalNotionalMax).
 			Msg("总名义价值超过上限")
 	}
 
+	// 组合风险指标
+	r.risk.ObservePortfolio(r.now())
+	pr := r.risk.PortfolioRisk()
+	metrics.UpdatePortfolioRisk(pr.VaR, pr.ES, pr.NetDelta, pr.BetaWeighted)
+
 	// 【关键修复】检查WebSocket健康度 - 检测静默断流
 	for _, symbol := range r.store.GetAllSymbols() {
 		state := r.store.GetSymbolState(symbol)
@@ -837,8 +1298,9 @@ func (r *Runner) monitorGlobalState() {
 				// 记录错误
 				metrics.RecordError("websocket_stale", symbol)
 
-				// TODO: 可以在这里触发WebSocket重连
-				// r.exchange.ReconnectDepthStream(ctx, symbol)
+				if time.Since(lastUpdate) > r.cfg.GetStaleReconnectThreshold() {
+					r.reconnectStaleMarketData(symbol)
+				}
 			}
 		}
 
@@ -847,6 +1309,26 @@ func (r *Runner) monitorGlobalState() {
 	}
 }
 
+// reconnectStaleMarketData 行情过期超过阈值时强制重连该交易对所在的行情连接，
+// 两次重连至少间隔一个阈值，给新连接留出订阅与订单簿同步的时间
+func (r *Runner) reconnectStaleMarketData(symbol string) {
+	sup, ok := r.exchange.(gateway.MarketDataSupervisor)
+	if !ok {
+		return
+	}
+	threshold := r.cfg.GetStaleReconnectThreshold()
+	if last, ok := r.staleReconnects[symbol]; ok && time.Since(last) < threshold {
+		return
+	}
+	r.staleReconnects[symbol] = time.Now()
+
+	if err := sup.ReconnectMarketData(symbol); err != nil {
+		log.Error().Err(err).Str("symbol", symbol).Msg("强制重连行情失败")
+		return
+	}
+	log.Warn().Str("symbol", symbol).Dur("threshold", threshold).Msg("行情过期，已强制重连行情连接")
+}
+
 // updateSymbolMetrics 更新交易对指标
 func (r *Runner) updateSymbolMetrics(symbol string) {
 	state := r.store.GetSymbolState(symbol)
@@ -888,9 +1370,23 @@ func (r *Runner) updateSymbolMetrics(symbol string) {
 	metrics.WorstCaseLong.WithLabelValues(symbol).Set(
 		r.store.GetWorstCaseLong(symbol),
 	)
-	metrics.MaxDrawdown.WithLabelValues(symbol).Set(state.MaxDrawdown)
+	metrics.MaxDrawdown.WithLabelValues(symbol).Set(state.Mark.MaxDrawdown)
 	metrics.CancelRate.WithLabelValues(symbol).Set(float64(state.CancelCountLast))
+	metrics.AmendRate.WithLabelValues(symbol).Set(float64(state.AmendCountLast))
 	metrics.TotalPNL.WithLabelValues(symbol).Set(state.TotalPNL)
+
+	// 更新盈亏账本指标
+	ledger, mark := state.Ledger, state.Mark
+	metrics.UpdatePnLMetrics(symbol, metrics.PnLStats{
+		Realized:        ledger.RealizedPnL,
+		Unrealized:      mark.UnrealizedPnL(&ledger),
+		MakerFees:       ledger.MakerFees,
+		TakerFees:       ledger.TakerFees,
+		FundingPaid:     ledger.FundingPaid,
+		FundingReceived: ledger.FundingReceived,
+		HighWater:       math.Max(mark.HighWater, ledger.HighWater),
+		Drawdown:        mark.Drawdown(&ledger),
+	})
 }
 
 // logDashboardStats 记录Dashboard所需的结构化统计信息
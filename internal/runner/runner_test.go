@@ -2,12 +2,14 @@ package runner
 
 import (
 	"context"
+	"math"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/newplayman/market-maker-phoenix/internal/config"
 	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/newplayman/market-maker-phoenix/internal/filters"
 	"github.com/newplayman/market-maker-phoenix/internal/risk"
 	"github.com/newplayman/market-maker-phoenix/internal/store"
 	"github.com/newplayman/market-maker-phoenix/internal/strategy"
@@ -53,6 +55,31 @@ func (m *MockExchange) CancelOrder(ctx context.Context, symbol, clientOrderID st
 	return nil
 }
 
+func (m *MockExchange) AmendOrder(ctx context.Context, order *gateway.Order) (*gateway.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.orders[order.ClientOrderID]
+	if !ok {
+		return nil, gateway.NewBinanceError(400, "PUT /fapi/v1/order", -2013, "Order does not exist.")
+	}
+	existing.Price = order.Price
+	existing.Quantity = order.Quantity
+	return existing, nil
+}
+
+func (m *MockExchange) AmendOrders(ctx context.Context, orders []*gateway.Order) []gateway.OrderResult {
+	return gateway.PlaceOrdersEach(ctx, orders, m.AmendOrder)
+}
+
+func (m *MockExchange) PlaceOrders(ctx context.Context, orders []*gateway.Order) []gateway.OrderResult {
+	return gateway.PlaceOrdersEach(ctx, orders, m.PlaceOrder)
+}
+
+func (m *MockExchange) CancelOrders(ctx context.Context, symbol string, clientOrderIDs []string) []error {
+	return gateway.CancelOrdersEach(ctx, symbol, clientOrderIDs, m.CancelOrder)
+}
+
 func (m *MockExchange) CancelAllOrders(ctx context.Context, symbol string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -80,6 +107,10 @@ func (m *MockExchange) GetAllPositions(ctx context.Context) ([]*gateway.Position
 	return nil, nil
 }
 
+func (m *MockExchange) GetAccountBalance(ctx context.Context) (float64, float64, error) {
+	return 0, 0, nil
+}
+
 func (m *MockExchange) GetFundingRate(ctx context.Context, symbol string) (*gateway.FundingRate, error) {
 	return &gateway.FundingRate{Symbol: symbol, Rate: 0.0001}, nil
 }
@@ -92,6 +123,10 @@ func (m *MockExchange) StartDepthStream(ctx context.Context, symbols []string, c
 	return nil
 }
 
+func (m *MockExchange) StartTradeStream(ctx context.Context, symbols []string, onTrade func(*gateway.Trade)) error {
+	return nil
+}
+
 func (m *MockExchange) StartUserStream(ctx context.Context, callbacks *gateway.UserStreamCallbacks) error {
 	return nil
 }
@@ -360,3 +395,207 @@ func TestRunner_MultiSymbol(t *testing.T) {
 		t.Errorf("Expected at least 4 PlaceOrder calls for 2 symbols, got %d", mockExch.placeOrderCalled)
 	}
 }
+
+// stubFilterSource 返回固定交易规则
+type stubFilterSource struct {
+	rules map[string]filters.Symbol
+}
+
+func (s *stubFilterSource) SymbolFilters(ctx context.Context, symbols []string) (map[string]filters.Symbol, error) {
+	return s.rules, nil
+}
+
+func TestRunner_SyncFiltersAndApply(t *testing.T) {
+	cfg := &config.Config{
+		Global: config.GlobalConfig{TotalNotionalMax: 1000000, QuoteIntervalMs: 200},
+		Symbols: []config.SymbolConfig{
+			{Symbol: "BTCUSDT", NetMax: 1.0, MinSpread: 0.0002, TickSize: 0.1, MinQty: 0.001, TotalLayers: 2, UnifiedLayerSize: 0.01},
+		},
+	}
+	st := store.NewStore("", 5*time.Minute)
+	st.InitSymbol("BTCUSDT", 100)
+
+	r := NewRunner(cfg, st, strategy.NewASMM(cfg, st), risk.NewRiskManager(cfg, st), NewMockExchange())
+	src := &stubFilterSource{rules: map[string]filters.Symbol{
+		"BTCUSDT": {Symbol: "BTCUSDT", TickSize: 0.1, StepSize: 0.001, MinQty: 0.001, MinNotional: 100},
+	}}
+	r.SetFilterSource(src)
+	if err := r.SyncFilters(context.Background()); err != nil {
+		t.Fatalf("sync filters: %v", err)
+	}
+	if f := st.GetSymbolFilters("BTCUSDT"); f.MinNotional != 100 {
+		t.Fatalf("filters not stored: %+v", f)
+	}
+
+	// 卖单价格向上取整、数量向下取整；名义价值不足的报价被丢弃
+	if price, qty, ok := r.applyFilters("BTCUSDT", "SELL", 50000.01, 0.0109); !ok || price != 50000.1 || qty != 0.01 {
+		t.Fatalf("apply: %v %v %v", price, qty, ok)
+	}
+	if _, _, ok := r.applyFilters("BTCUSDT", "BUY", 50000, 0.0019); ok {
+		t.Fatal("quote below min notional should be rejected")
+	}
+
+	// 配置的tick_size比交易所更细时启动失败
+	cfg.Symbols[0].TickSize = 0.01
+	if err := r.SyncFilters(context.Background()); err == nil {
+		t.Fatal("config finer than exchange tick should fail startup sync")
+	}
+}
+
+// reconnectingExchange 记录行情强制重连请求
+type reconnectingExchange struct {
+	*MockExchange
+	reconnects []string
+}
+
+func (e *reconnectingExchange) ReconnectMarketData(symbol string) error {
+	e.reconnects = append(e.reconnects, symbol)
+	return nil
+}
+
+func TestRunner_StaleMarketDataForcesReconnect(t *testing.T) {
+	cfg := &config.Config{
+		Global: config.GlobalConfig{TotalNotionalMax: 1000000, QuoteIntervalMs: 200, StaleReconnectSec: 10},
+		Symbols: []config.SymbolConfig{
+			{Symbol: "BTCUSDT", NetMax: 1.0, MinSpread: 0.0002, TotalLayers: 2, UnifiedLayerSize: 0.01},
+		},
+	}
+	st := store.NewStore("", 5*time.Minute)
+	st.InitSymbol("BTCUSDT", 100)
+	st.SetClock(func() time.Time { return time.Now().Add(-6 * time.Second) })
+	st.UpdateMidPrice("BTCUSDT", 50000, 49995, 50005)
+
+	exch := &reconnectingExchange{MockExchange: NewMockExchange()}
+	r := NewRunner(cfg, st, strategy.NewASMM(cfg, st), risk.NewRiskManager(cfg, st), exch)
+
+	// 过期但未超过重连阈值：只告警
+	r.monitorGlobalState()
+	if len(exch.reconnects) != 0 {
+		t.Fatalf("should not reconnect below threshold: %v", exch.reconnects)
+	}
+
+	st.SetClock(func() time.Time { return time.Now().Add(-11 * time.Second) })
+	st.UpdateMidPrice("BTCUSDT", 50000, 49995, 50005)
+	r.monitorGlobalState()
+	r.monitorGlobalState() // 冷却期内不重复重连
+	if len(exch.reconnects) != 1 || exch.reconnects[0] != "BTCUSDT" {
+		t.Fatalf("expected one forced reconnect, got %v", exch.reconnects)
+	}
+}
+
+func TestRunner_PortfolioRiskReduceOnly(t *testing.T) {
+	cfg := &config.Config{
+		Global: config.GlobalConfig{TotalNotionalMax: 1000, QuoteIntervalMs: 200},
+		Symbols: []config.SymbolConfig{
+			{Symbol: "BTCUSDT", NetMax: 1.0, MinSpread: 0.0002, MinQty: 0.001, TotalLayers: 2, UnifiedLayerSize: 0.01},
+		},
+	}
+	st := store.NewStore("", 5*time.Minute)
+	st.InitSymbol("BTCUSDT", 100)
+	st.UpdateMidPrice("BTCUSDT", 50000, 49995, 50005)
+	r := NewRunner(cfg, st, strategy.NewASMM(cfg, st), risk.NewRiskManager(cfg, st), NewMockExchange())
+
+	buys := []strategy.Quote{{Price: 49990, Size: 0.01}}
+	sells := []strategy.Quote{{Price: 50010, Size: 0.01}}
+
+	// 未超限：报价不变
+	if b, s := r.applyPortfolioRisk("BTCUSDT", buys, sells); len(b) != 1 || len(s) != 1 {
+		t.Fatalf("quotes should pass through: buy=%v sell=%v", b, s)
+	}
+
+	// 多头超过总名义价值上限：只保留卖出（减仓）报价
+	st.UpdatePosition("BTCUSDT", store.Position{Symbol: "BTCUSDT", Size: 0.1, Notional: 5000})
+	if b, s := r.applyPortfolioRisk("BTCUSDT", buys, sells); len(b) != 0 || len(s) != 1 || s[0].Size != 0.01 {
+		t.Fatalf("expected reduce-only sell side: buy=%v sell=%v", b, s)
+	}
+
+	// 按比例缩放，低于最小下单量的报价丢弃
+	if q := scaleQuotes([]strategy.Quote{{Size: 0.01}, {Size: 0.0015}}, 0.5, 0.001); len(q) != 1 || q[0].Size != 0.005 {
+		t.Fatalf("unexpected scaled quotes: %v", q)
+	}
+}
+
+// fillObserver 记录策略收到的自身成交
+type fillObserver struct {
+	*strategy.ASMM
+	fills [][2]float64
+}
+
+func (f *fillObserver) OnTrade(symbol string, price, qty float64) {}
+func (f *fillObserver) OnFill(symbol, side string, price, qty float64) {
+	f.fills = append(f.fills, [2]float64{price, qty})
+}
+
+func TestRunner_OnFillUsesLastExecution(t *testing.T) {
+	cfg := &config.Config{
+		Global:  config.GlobalConfig{TotalNotionalMax: 1000000, QuoteIntervalMs: 200},
+		Symbols: []config.SymbolConfig{{Symbol: "BTCUSDT", NetMax: 1.0, MinSpread: 0.0002, TotalLayers: 2, UnifiedLayerSize: 0.01}},
+	}
+	st := store.NewStore("", 5*time.Minute)
+	st.InitSymbol("BTCUSDT", 100)
+	obs := &fillObserver{ASMM: strategy.NewASMM(cfg, st)}
+	r := NewRunner(cfg, st, obs, risk.NewRiskManager(cfg, st), NewMockExchange())
+
+	// 两次部分成交：策略应分别收到 0.3@100 与 0.2@99.9，而不是累计成交量
+	r.onOrderUpdate(&gateway.Order{Symbol: "BTCUSDT", ClientOrderID: "o1", Side: "BUY", Price: 100, Quantity: 1,
+		Status: "PARTIALLY_FILLED", FilledQty: 0.3, LastFilledQty: 0.3, LastFilledPrice: 100})
+	r.onOrderUpdate(&gateway.Order{Symbol: "BTCUSDT", ClientOrderID: "o1", Side: "BUY", Price: 100, Quantity: 1,
+		Status: "PARTIALLY_FILLED", FilledQty: 0.5, LastFilledQty: 0.2, LastFilledPrice: 99.9})
+
+	if len(obs.fills) != 2 || obs.fills[0] != [2]float64{100, 0.3} || obs.fills[1] != [2]float64{99.9, 0.2} {
+		t.Fatalf("expected per-execution fills, got %v", obs.fills)
+	}
+}
+
+func TestRunner_ForwardsExternalOrderUpdates(t *testing.T) {
+	cfg := &config.Config{
+		Global:  config.GlobalConfig{TotalNotionalMax: 1000000, QuoteIntervalMs: 500},
+		Symbols: []config.SymbolConfig{{Symbol: "BTCUSDT", NetMax: 1.0, MinSpread: 0.0002, BaseLayerSize: 0.1}},
+	}
+	st := store.NewStore("", 5*time.Minute)
+	st.InitSymbol("BTCUSDT", 100)
+	r := NewRunner(cfg, st, strategy.NewASMM(cfg, st), risk.NewRiskManager(cfg, st), NewMockExchange())
+
+	var forwarded []string
+	r.SetExternalOrderHandler(func(o *gateway.Order) { forwarded = append(forwarded, o.ClientOrderID) })
+	r.onOrderUpdate(&gateway.Order{Symbol: "ETHUSDT", ClientOrderID: "hedge-1", Status: "EXPIRED"})
+	r.onOrderUpdate(&gateway.Order{Symbol: "BTCUSDT", ClientOrderID: "maker-1", Status: "NEW"})
+
+	if len(forwarded) != 1 || forwarded[0] != "hedge-1" {
+		t.Fatalf("only non-maker order updates should be forwarded, got %v", forwarded)
+	}
+}
+
+func TestRunner_FinalFillWithoutDetailBooksRemainder(t *testing.T) {
+	cfg := &config.Config{
+		Global:  config.GlobalConfig{TotalNotionalMax: 1000000, QuoteIntervalMs: 200},
+		Symbols: []config.SymbolConfig{{Symbol: "BTCUSDT", NetMax: 1.0, MinSpread: 0.0002, TotalLayers: 2, UnifiedLayerSize: 0.01}},
+	}
+	st := store.NewStore("", 5*time.Minute)
+	st.InitSymbol("BTCUSDT", 100)
+	r := NewRunner(cfg, st, strategy.NewASMM(cfg, st), risk.NewRiskManager(cfg, st), NewMockExchange())
+
+	// 两次部分成交按明细记账，随后的完全成交推送（如断线对账补发）不带明细，只应补记剩余的0.5
+	r.onOrderUpdate(&gateway.Order{Symbol: "BTCUSDT", ClientOrderID: "o1", Side: "BUY", Price: 100, Quantity: 1,
+		Status: "PARTIALLY_FILLED", FilledQty: 0.3, LastFilledQty: 0.3, LastFilledPrice: 100})
+	r.onOrderUpdate(&gateway.Order{Symbol: "BTCUSDT", ClientOrderID: "o1", Side: "BUY", Price: 100, Quantity: 1,
+		Status: "PARTIALLY_FILLED", FilledQty: 0.5, LastFilledQty: 0.2, LastFilledPrice: 100})
+	r.onOrderUpdate(&gateway.Order{Symbol: "BTCUSDT", ClientOrderID: "o1", Side: "BUY", Price: 100, Quantity: 1,
+		Status: "FILLED", FilledQty: 1})
+
+	l, _ := st.GetLedger("BTCUSDT")
+	if math.Abs(l.Position-1) > 1e-9 {
+		t.Fatalf("expected ledger position 1 after the final fill, got %f", l.Position)
+	}
+
+	// 全部成交都已按明细记账时，不带明细的完全成交推送只结束挂单
+	r.onOrderUpdate(&gateway.Order{Symbol: "BTCUSDT", ClientOrderID: "o2", Side: "SELL", Price: 101, Quantity: 1,
+		Status: "PARTIALLY_FILLED", FilledQty: 1, LastFilledQty: 1, LastFilledPrice: 101})
+	r.onOrderUpdate(&gateway.Order{Symbol: "BTCUSDT", ClientOrderID: "o2", Side: "SELL", Price: 101, Quantity: 1,
+		Status: "FILLED", FilledQty: 1})
+	state := st.GetSymbolState("BTCUSDT")
+	l = state.Ledger
+	if math.Abs(l.Position) > 1e-9 || state.FillCount != 4 || len(state.OpenOrders) != 0 {
+		t.Fatalf("expected flat ledger with 4 fills and no open orders, got pos=%f fills=%d open=%v", l.Position, state.FillCount, state.OpenOrders)
+	}
+}
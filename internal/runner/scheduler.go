@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"math"
+	"sort"
+	"sync"
+)
+
+// RequoteReason 重新报价的触发原因
+type RequoteReason string
+
+const (
+	ReasonStart     RequoteReason = "start"     // 做市循环启动
+	ReasonMidMove   RequoteReason = "mid_move"  // 中间价相对上次报价移动超过阈值
+	ReasonFill      RequoteReason = "fill"      // 成交
+	ReasonOrder     RequoteReason = "order"     // 订单被交易所撤销/过期/拒绝
+	ReasonPosition  RequoteReason = "position"  // 仓位变化
+	ReasonConfig    RequoteReason = "config"    // 配置热重载
+	ReasonHeartbeat RequoteReason = "heartbeat" // 超过最长间隔无事件
+)
+
+// requoteScheduler 单个交易对的重新报价调度：事件只置位待处理原因并唤醒做市循环，
+// 多个事件合并为一次报价
+type requoteScheduler struct {
+	mu        sync.Mutex
+	pending   map[RequoteReason]bool
+	quotedMid float64 // 上次报价时的中间价
+	wake      chan struct{}
+}
+
+func newRequoteScheduler() *requoteScheduler {
+	return &requoteScheduler{
+		pending: make(map[RequoteReason]bool),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// trigger 记录触发原因并唤醒做市循环；已有待处理唤醒时合并
+func (s *requoteScheduler) trigger(reason RequoteReason) {
+	s.mu.Lock()
+	s.pending[reason] = true
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// observeMid 中间价相对上次报价移动超过 thresholdBps 时触发重新报价
+func (s *requoteScheduler) observeMid(mid, thresholdBps float64) {
+	if mid <= 0 {
+		return
+	}
+	s.mu.Lock()
+	quoted := s.quotedMid
+	s.mu.Unlock()
+	if quoted > 0 && math.Abs(mid-quoted)/quoted*1e4 < thresholdBps {
+		return
+	}
+	s.trigger(ReasonMidMove)
+}
+
+// take 取出并清空待处理的触发原因
+func (s *requoteScheduler) take() []RequoteReason {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reasons := make([]RequoteReason, 0, len(s.pending))
+	for r := range s.pending {
+		reasons = append(reasons, r)
+	}
+	s.pending = make(map[RequoteReason]bool)
+	sort.Slice(reasons, func(i, j int) bool { return reasons[i] < reasons[j] })
+	return reasons
+}
+
+// quoted 记录本次报价使用的中间价，作为下次判断移动幅度的基准
+func (s *requoteScheduler) quoted(mid float64) {
+	s.mu.Lock()
+	s.quotedMid = mid
+	s.mu.Unlock()
+}
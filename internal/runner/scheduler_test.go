@@ -0,0 +1,187 @@
+package runner
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/newplayman/market-maker-phoenix/internal/risk"
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+	"github.com/newplayman/market-maker-phoenix/internal/strategy"
+)
+
+func TestRequoteScheduler_Coalesces(t *testing.T) {
+	s := newRequoteScheduler()
+	s.trigger(ReasonFill)
+	s.trigger(ReasonFill)
+	s.trigger(ReasonPosition)
+
+	select {
+	case <-s.wake:
+	default:
+		t.Fatal("trigger should wake the loop")
+	}
+	select {
+	case <-s.wake:
+		t.Fatal("multiple triggers should coalesce into one wake-up")
+	default:
+	}
+	if got := s.take(); !reflect.DeepEqual(got, []RequoteReason{ReasonFill, ReasonPosition}) {
+		t.Fatalf("unexpected reasons %v", got)
+	}
+	if got := s.take(); len(got) != 0 {
+		t.Fatalf("take should clear pending reasons, got %v", got)
+	}
+}
+
+func TestRequoteScheduler_MidMoveThreshold(t *testing.T) {
+	s := newRequoteScheduler()
+	s.observeMid(3000, 1)
+	if got := s.take(); len(got) != 1 || got[0] != ReasonMidMove {
+		t.Fatalf("first mid before any quote should trigger, got %v", got)
+	}
+
+	s.quoted(3000)
+	s.observeMid(3000.2, 1) // 0.67bp
+	if got := s.take(); len(got) != 0 {
+		t.Fatalf("move below threshold should not trigger, got %v", got)
+	}
+	s.observeMid(2999.6, 1) // 1.33bp
+	if got := s.take(); len(got) != 1 || got[0] != ReasonMidMove {
+		t.Fatalf("move beyond threshold should trigger, got %v", got)
+	}
+}
+
+func TestRunner_EventsTriggerRequote(t *testing.T) {
+	cfg := &config.Config{
+		Global: config.GlobalConfig{
+			TotalNotionalMax:  1000000,
+			QuoteIntervalMs:   500,
+			RequoteMidMoveBps: 2,
+		},
+		Symbols: []config.SymbolConfig{
+			{Symbol: "BTCUSDT", NetMax: 1.0, MinSpread: 0.0002, NearLayers: 3, FarLayers: 5, BaseLayerSize: 0.1},
+		},
+	}
+	st := store.NewStore("", 5*time.Minute)
+	st.InitSymbol("BTCUSDT", 100)
+	r := NewRunner(cfg, st, strategy.NewASMM(cfg, st), risk.NewRiskManager(cfg, st), NewMockExchange())
+	sched := r.schedulers["BTCUSDT"]
+	sched.quoted(50000)
+
+	depth := func(bid, ask float64) *gateway.Depth {
+		return &gateway.Depth{
+			Symbol: "BTCUSDT",
+			Bids:   []gateway.PriceLevel{{Price: bid, Quantity: 1}},
+			Asks:   []gateway.PriceLevel{{Price: ask, Quantity: 1}},
+		}
+	}
+	r.onDepthUpdate(depth(49999, 50001)) // 未移动
+	r.onDepthUpdate(depth(50004, 50006)) // 1bp
+	if got := sched.take(); len(got) != 0 {
+		t.Fatalf("small mid moves should not requote, got %v", got)
+	}
+	r.onDepthUpdate(depth(50011, 50013)) // 2.4bp
+	r.onOrderUpdate(&gateway.Order{Symbol: "BTCUSDT", ClientOrderID: "c1", Side: "BUY", Status: "PARTIALLY_FILLED", Price: 49990, Quantity: 0.1, FilledQty: 0.05})
+	r.onAccountUpdate([]*gateway.Position{{Symbol: "BTCUSDT", Size: 0.05}})
+	r.onAccountUpdate([]*gateway.Position{{Symbol: "BTCUSDT", Size: 0.05}}) // 仓位未变化
+	want := []RequoteReason{ReasonFill, ReasonMidMove, ReasonPosition}
+	if got := sched.take(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+}
+
+// TestRunner_QuotedMidOnlyAfterQuotesSent 本轮未下达报价时不更新中间价基准，避免之后的中间价移动被忽略
+func TestRunner_QuotedMidOnlyAfterQuotesSent(t *testing.T) {
+	cfg := &config.Config{
+		Global: config.GlobalConfig{TotalNotionalMax: 1000000, QuoteIntervalMs: 200, RequoteMidMoveBps: 1},
+		Symbols: []config.SymbolConfig{
+			{Symbol: "BTCUSDT", NetMax: 1.0, MinSpread: 0.0002, NearLayers: 2, FarLayers: 3, BaseLayerSize: 0.1, MaxCancelPerMin: 100},
+		},
+	}
+	st := store.NewStore("", 5*time.Minute)
+	st.InitSymbol("BTCUSDT", 100)
+	st.UpdateMidPrice("BTCUSDT", 50000, 49995, 50005)
+	exch := NewMockExchange()
+	r := NewRunner(cfg, st, strategy.NewASMM(cfg, st), risk.NewRiskManager(cfg, st), exch)
+	sched := r.schedulers["BTCUSDT"]
+	state := st.GetSymbolState("BTCUSDT")
+
+	// 撤单频率接近上限：本轮不报价
+	state.Mu.Lock()
+	state.CancelCountLast = 100
+	state.Mu.Unlock()
+	if err := r.processSymbol(context.Background(), "BTCUSDT"); err != nil {
+		t.Fatalf("processSymbol: %v", err)
+	}
+	sched.observeMid(50000, 1)
+	if got := sched.take(); len(got) != 1 || got[0] != ReasonMidMove {
+		t.Fatalf("skipped round should not record the mid as quoted, got %v", got)
+	}
+
+	state.Mu.Lock()
+	state.CancelCountLast = 0
+	state.Mu.Unlock()
+	if err := r.processSymbol(context.Background(), "BTCUSDT"); err != nil {
+		t.Fatalf("processSymbol: %v", err)
+	}
+	if exch.placeOrderCalled == 0 {
+		t.Fatal("expected quotes to be placed")
+	}
+	sched.observeMid(50000, 1)
+	if got := sched.take(); len(got) != 0 {
+		t.Fatalf("mid of a sent quote should be the new reference, got %v", got)
+	}
+}
+
+// TestRunner_EventLoopUsesInjectedClock 最小报价间隔按注入的时钟计算：时钟已越过最小间隔时立即处理新事件
+func TestRunner_EventLoopUsesInjectedClock(t *testing.T) {
+	cfg := &config.Config{
+		Global: config.GlobalConfig{
+			TotalNotionalMax:     1000000,
+			QuoteIntervalMs:      500,
+			RequoteMinIntervalMs: int(time.Hour / time.Millisecond),
+			RequoteHeartbeatMs:   int(2 * time.Hour / time.Millisecond),
+		},
+		Symbols: []config.SymbolConfig{
+			{Symbol: "BTCUSDT", NetMax: 1.0, MinSpread: 0.0002, NearLayers: 3, FarLayers: 5, BaseLayerSize: 0.1},
+		},
+	}
+	st := store.NewStore("", 5*time.Minute)
+	st.InitSymbol("BTCUSDT", 100)
+	r := NewRunner(cfg, st, strategy.NewASMM(cfg, st), risk.NewRiskManager(cfg, st), NewMockExchange())
+
+	// 每次读取时钟前进2小时，远超最小间隔
+	var ticks atomic.Int64
+	start := time.Unix(1700000000, 0)
+	r.SetClock(func() time.Time { return start.Add(time.Duration(ticks.Add(1)) * 2 * time.Hour) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sched := r.schedulers["BTCUSDT"]
+	go r.runEventLoop(ctx, "BTCUSDT", sched)
+
+	taken := func() bool {
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			sched.mu.Lock()
+			n := len(sched.pending)
+			sched.mu.Unlock()
+			if n == 0 {
+				return true
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		return false
+	}
+	for i := 0; i < 2; i++ {
+		r.RequestRequote("BTCUSDT", ReasonConfig)
+		if !taken() {
+			t.Fatalf("requote %d was held back by the wall clock instead of the injected clock", i+1)
+		}
+	}
+}
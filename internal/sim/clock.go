@@ -0,0 +1,42 @@
+package sim
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 虚拟时钟，回放期间由引擎推进，替代time.Now
+type Clock struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+// NewClock 创建从指定时间开始的虚拟时钟
+func NewClock(start time.Time) *Clock {
+	return &Clock{now: start}
+}
+
+// Now 返回当前虚拟时间
+func (c *Clock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.now
+}
+
+// Set 将时钟设置到指定时间（不允许回拨）
+func (c *Clock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// Advance 将时钟前进指定时长
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d > 0 {
+		c.now = c.now.Add(d)
+	}
+}
@@ -0,0 +1,701 @@
+// Package sim 按队列位置撮合的模拟交易所与虚拟时钟，供回测与模拟盘共用
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+)
+
+const qtyEpsilon = 1e-12
+
+// simOrder 模拟撮合中的挂单
+type simOrder struct {
+	order      gateway.Order
+	seq        int64
+	queueAhead float64 // 排在本单之前的市场挂单量
+}
+
+func (o *simOrder) remaining() float64 {
+	return o.order.Quantity - o.order.FilledQty
+}
+
+// simBook 最近一次深度快照
+type simBook struct {
+	bids []gateway.PriceLevel
+	asks []gateway.PriceLevel
+}
+
+func (b *simBook) bestBid() float64 {
+	if b == nil || len(b.bids) == 0 {
+		return 0
+	}
+	return b.bids[0].Price
+}
+
+func (b *simBook) bestAsk() float64 {
+	if b == nil || len(b.asks) == 0 {
+		return 0
+	}
+	return b.asks[0].Price
+}
+
+func (b *simBook) mid() float64 {
+	bid, ask := b.bestBid(), b.bestAsk()
+	if bid <= 0 || ask <= 0 {
+		return 0
+	}
+	return (bid + ask) / 2
+}
+
+// simPosition 模拟仓位
+type simPosition struct {
+	size  float64
+	entry float64
+}
+
+// SymbolStats 单个交易对的撮合统计
+type SymbolStats struct {
+	Placed      int
+	Canceled    int
+	Amended     int
+	Rejected    int // post-only 拒单
+	Fills       int
+	BuyVolume   float64
+	SellVolume  float64
+	Notional    float64
+	Fees        float64
+	RealizedPnL float64
+	Funding     float64 // 资金费收支（正为收入）
+}
+
+// TimeSource 撮合使用的时间源：回测为虚拟时钟，模拟盘为系统时间
+type TimeSource interface {
+	Now() time.Time
+}
+
+// SimExchange 模拟交易所（回测与模拟盘共用），实现 gateway.Exchange
+// 限价单按队列位置撮合：挂单时排在同价位已有挂单之后，
+// 同价位成交量先消耗前方队列，再成交本单；价格穿越则全部成交。
+type SimExchange struct {
+	mu        sync.Mutex
+	clock     TimeSource
+	makerFee  float64
+	balance   float64
+	seq       int64
+	idPrefix  string
+	connected bool
+
+	books     map[string]*simBook
+	open      map[string][]*simOrder // symbol -> 按seq排序的挂单
+	positions map[string]*simPosition
+	stats     map[string]*SymbolStats
+
+	depthCallback func(*gateway.Depth)
+	tradeCallback func(*gateway.Trade)
+	userCallbacks *gateway.UserStreamCallbacks
+}
+
+// NewSimExchange 创建模拟交易所
+func NewSimExchange(clock TimeSource, makerFeeRate, initialBalance float64) *SimExchange {
+	return &SimExchange{
+		clock:     clock,
+		makerFee:  makerFeeRate,
+		balance:   initialBalance,
+		idPrefix:  "bt",
+		books:     make(map[string]*simBook),
+		open:      make(map[string][]*simOrder),
+		positions: make(map[string]*simPosition),
+		stats:     make(map[string]*SymbolStats),
+	}
+}
+
+// SetOrderIDPrefix 设置未指定ClientOrderID时生成ID的前缀（默认bt）
+func (e *SimExchange) SetOrderIDPrefix(prefix string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.idPrefix = prefix
+}
+
+// ApplyDepth 回放一条深度快照：先撮合被穿越的挂单，再推送给深度回调
+func (e *SimExchange) ApplyDepth(symbol string, bids, asks []gateway.PriceLevel) {
+	e.mu.Lock()
+	book := &simBook{bids: bids, asks: asks}
+	e.books[symbol] = book
+
+	var notify []func()
+	for _, o := range e.open[symbol] {
+		price := o.order.Price
+		if o.order.Side == "BUY" {
+			if ask := book.bestAsk(); ask > 0 && price >= ask {
+				notify = append(notify, e.fillLocked(o, o.remaining(), price)...)
+				continue
+			}
+			o.queueAhead = refreshQueue(o.queueAhead, price, bids, true)
+		} else {
+			if bid := book.bestBid(); bid > 0 && price <= bid {
+				notify = append(notify, e.fillLocked(o, o.remaining(), price)...)
+				continue
+			}
+			o.queueAhead = refreshQueue(o.queueAhead, price, asks, false)
+		}
+	}
+	e.pruneLocked(symbol)
+	cb := e.depthCallback
+	e.mu.Unlock()
+
+	dispatch(notify)
+	if cb != nil {
+		cb(&gateway.Depth{Symbol: symbol, Bids: bids, Asks: asks, Timestamp: e.clock.Now()})
+	}
+}
+
+// ApplyTrade 回放一笔逐笔成交，按队列位置撮合挂单
+func (e *SimExchange) ApplyTrade(trade *gateway.Trade) {
+	e.mu.Lock()
+	var notify []func()
+
+	// IsBuyerMaker=true 表示卖方主动成交，打到买单；否则打到卖单
+	hitSide := "SELL"
+	if trade.IsBuyerMaker {
+		hitSide = "BUY"
+	}
+
+	volume := trade.Quantity
+	excessUsed := 0.0
+	for _, o := range e.open[trade.Symbol] {
+		if o.order.Side != hitSide {
+			continue
+		}
+		price := o.order.Price
+		crossed := (hitSide == "BUY" && price > trade.Price) || (hitSide == "SELL" && price < trade.Price)
+		if crossed {
+			notify = append(notify, e.fillLocked(o, o.remaining(), price)...)
+			continue
+		}
+		if math.Abs(price-trade.Price) > qtyEpsilon {
+			continue
+		}
+
+		// 同价位：成交量先消耗前方队列，剩余部分按下单顺序成交本方挂单
+		consumed := math.Min(o.queueAhead, volume)
+		o.queueAhead -= consumed
+		avail := volume - consumed - excessUsed
+		if avail <= qtyEpsilon {
+			continue
+		}
+		qty := math.Min(avail, o.remaining())
+		excessUsed += qty
+		notify = append(notify, e.fillLocked(o, qty, price)...)
+	}
+	e.pruneLocked(trade.Symbol)
+	cb := e.tradeCallback
+	e.mu.Unlock()
+
+	dispatch(notify)
+	if cb != nil {
+		cb(trade)
+	}
+}
+
+// ApplyFunding 按标记价格结算资金费：费率为正时多头支付、空头收取
+func (e *SimExchange) ApplyFunding(symbol string, rate, markPrice float64) {
+	e.mu.Lock()
+	pos := e.positions[symbol]
+	if pos == nil || pos.size == 0 || markPrice <= 0 {
+		e.mu.Unlock()
+		return
+	}
+	payment := -pos.size * markPrice * rate
+	e.balance += payment
+	e.statsLocked(symbol).Funding += payment
+
+	funding := &gateway.FundingRate{Symbol: symbol, Rate: rate, Payment: payment, Timestamp: e.clock.Now()}
+	positions := []*gateway.Position{e.positionLocked(symbol)}
+	callbacks := e.userCallbacks
+	e.mu.Unlock()
+
+	if callbacks == nil {
+		return
+	}
+	if callbacks.OnFunding != nil {
+		callbacks.OnFunding(funding)
+	}
+	if callbacks.OnAccountUpdate != nil {
+		callbacks.OnAccountUpdate(positions)
+	}
+}
+
+// refreshQueue 根据最新深度收缩队列位置：同价位挂单量减少说明前方有撤单或成交
+func refreshQueue(current, price float64, levels []gateway.PriceLevel, isBid bool) float64 {
+	if len(levels) == 0 {
+		return current
+	}
+	for _, lvl := range levels {
+		if math.Abs(lvl.Price-price) <= qtyEpsilon {
+			return math.Min(current, lvl.Quantity)
+		}
+	}
+	// 价位不在深度中：若处于盘口与最差档之间，说明该价位已被清空
+	worst := levels[len(levels)-1].Price
+	if (isBid && price >= worst) || (!isBid && price <= worst) {
+		return 0
+	}
+	return current
+}
+
+// fillLocked 成交挂单并更新仓位，返回需在锁外执行的回调
+func (e *SimExchange) fillLocked(o *simOrder, qty, price float64) []func() {
+	if qty <= qtyEpsilon {
+		return nil
+	}
+	symbol := o.order.Symbol
+	o.order.FilledQty += qty
+	if o.remaining() <= qtyEpsilon {
+		o.order.FilledQty = o.order.Quantity
+		o.order.Status = "FILLED"
+	} else {
+		o.order.Status = "PARTIALLY_FILLED"
+	}
+
+	st := e.statsLocked(symbol)
+	st.Fills++
+	st.Notional += qty * price
+	if o.order.Side == "BUY" {
+		st.BuyVolume += qty
+	} else {
+		st.SellVolume += qty
+	}
+
+	fee := qty * price * e.makerFee
+	st.Fees += fee
+	e.balance -= fee
+
+	pos := e.positions[symbol]
+	if pos == nil {
+		pos = &simPosition{}
+		e.positions[symbol] = pos
+	}
+	signed := qty
+	if o.order.Side == "SELL" {
+		signed = -qty
+	}
+	realized := applyFill(pos, signed, price)
+	st.RealizedPnL += realized
+	e.balance += realized
+
+	o.order.LastFilledQty = qty
+	o.order.LastFilledPrice = price
+	o.order.RealizedPNL = realized
+	o.order.Commission = fee
+	o.order.IsMaker = true
+	orderCopy := o.order
+	positions := []*gateway.Position{e.positionLocked(symbol)}
+	callbacks := e.userCallbacks
+	return []func(){func() {
+		if callbacks == nil {
+			return
+		}
+		if callbacks.OnOrderUpdate != nil {
+			callbacks.OnOrderUpdate(&orderCopy)
+		}
+		if callbacks.OnAccountUpdate != nil {
+			callbacks.OnAccountUpdate(positions)
+		}
+	}}
+}
+
+// applyFill 按平均成本法更新仓位，返回已实现盈亏
+func applyFill(pos *simPosition, signed, price float64) float64 {
+	if pos.size == 0 || (pos.size > 0) == (signed > 0) {
+		total := math.Abs(pos.size) + math.Abs(signed)
+		pos.entry = (pos.entry*math.Abs(pos.size) + price*math.Abs(signed)) / total
+		pos.size += signed
+		return 0
+	}
+
+	closeQty := math.Min(math.Abs(pos.size), math.Abs(signed))
+	direction := 1.0
+	if pos.size < 0 {
+		direction = -1.0
+	}
+	realized := closeQty * (price - pos.entry) * direction
+
+	pos.size += signed
+	switch {
+	case math.Abs(pos.size) <= qtyEpsilon:
+		pos.size = 0
+		pos.entry = 0
+	case (pos.size > 0) != (direction > 0):
+		// 反手：剩余部分以成交价开仓
+		pos.entry = price
+	}
+	return realized
+}
+
+// pruneLocked 移除已完成的挂单
+func (e *SimExchange) pruneLocked(symbol string) {
+	orders := e.open[symbol]
+	kept := orders[:0]
+	for _, o := range orders {
+		if o.order.Status == "NEW" || o.order.Status == "PARTIALLY_FILLED" {
+			kept = append(kept, o)
+		}
+	}
+	e.open[symbol] = kept
+}
+
+func (e *SimExchange) statsLocked(symbol string) *SymbolStats {
+	st := e.stats[symbol]
+	if st == nil {
+		st = &SymbolStats{}
+		e.stats[symbol] = st
+	}
+	return st
+}
+
+func (e *SimExchange) positionLocked(symbol string) *gateway.Position {
+	pos := e.positions[symbol]
+	if pos == nil {
+		return &gateway.Position{Symbol: symbol, Leverage: 1}
+	}
+	unrealized := 0.0
+	if mid := e.books[symbol].mid(); mid > 0 {
+		unrealized = pos.size * (mid - pos.entry)
+	}
+	return &gateway.Position{
+		Symbol:        symbol,
+		Size:          pos.size,
+		EntryPrice:    pos.entry,
+		UnrealizedPNL: unrealized,
+		Notional:      math.Abs(pos.size) * pos.entry,
+		Leverage:      1,
+	}
+}
+
+func dispatch(notify []func()) {
+	for _, fn := range notify {
+		fn()
+	}
+}
+
+// Stats 返回交易对撮合统计的副本
+func (e *SimExchange) Stats(symbol string) SymbolStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if st := e.stats[symbol]; st != nil {
+		return *st
+	}
+	return SymbolStats{}
+}
+
+// MidPrice 返回最近一次深度的中间价
+func (e *SimExchange) MidPrice(symbol string) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.books[symbol].mid()
+}
+
+// PlaceOrder 下post-only限价单，会立即成交的订单以-5022拒绝。
+// 沿用调用方的ClientOrderID（为空时生成），与挂单重复时以-4116拒绝
+func (e *SimExchange) PlaceOrder(ctx context.Context, order *gateway.Order) (*gateway.Order, error) {
+	if order == nil || order.Quantity <= 0 || order.Price <= 0 {
+		return nil, gateway.ErrInvalidOrder
+	}
+
+	e.mu.Lock()
+	if !e.connected {
+		e.mu.Unlock()
+		return nil, gateway.ErrNotConnected
+	}
+
+	book := e.books[order.Symbol]
+	wouldTake := (order.Side == "BUY" && book.bestAsk() > 0 && order.Price >= book.bestAsk()) ||
+		(order.Side == "SELL" && book.bestBid() > 0 && order.Price <= book.bestBid())
+	if wouldTake {
+		e.statsLocked(order.Symbol).Rejected++
+		e.mu.Unlock()
+		return nil, gateway.NewBinanceError(400, "POST /fapi/v1/order", -5022, "Due to the order could not be executed as maker, the Post Only order will be rejected.")
+	}
+
+	for _, o := range e.open[order.Symbol] {
+		if order.ClientOrderID != "" && o.order.ClientOrderID == order.ClientOrderID {
+			e.mu.Unlock()
+			return nil, gateway.NewBinanceError(400, "POST /fapi/v1/order", -4116, "ClientOrderId is duplicated.")
+		}
+	}
+
+	e.seq++
+	o := &simOrder{order: *order, seq: e.seq}
+	if o.order.ClientOrderID == "" {
+		o.order.ClientOrderID = fmt.Sprintf("%s-%s-%d", e.idPrefix, order.Symbol, e.seq)
+	}
+	o.order.Type = "LIMIT"
+	o.order.Status = "NEW"
+	o.order.FilledQty = 0
+	o.order.CreatedAt = e.clock.Now()
+	if book != nil {
+		levels := book.asks
+		if order.Side == "BUY" {
+			levels = book.bids
+		}
+		for _, lvl := range levels {
+			if math.Abs(lvl.Price-order.Price) <= qtyEpsilon {
+				o.queueAhead = lvl.Quantity
+				break
+			}
+		}
+	}
+	e.open[order.Symbol] = append(e.open[order.Symbol], o)
+	e.statsLocked(order.Symbol).Placed++
+	result := o.order
+	e.mu.Unlock()
+
+	return &result, nil
+}
+
+// CancelOrder 撤销指定订单，订单不存在时返回-2011
+func (e *SimExchange) CancelOrder(ctx context.Context, symbol, clientOrderID string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, o := range e.open[symbol] {
+		if o.order.ClientOrderID == clientOrderID {
+			o.order.Status = "CANCELED"
+			e.statsLocked(symbol).Canceled++
+			e.pruneLocked(symbol)
+			return nil
+		}
+	}
+	return gateway.NewBinanceError(400, "DELETE /fapi/v1/order", -2011, "Unknown order sent.")
+}
+
+// AmendOrder 修改挂单价格/数量，ClientOrderID不变。
+// 改价后重新排到新价位队尾；改价后会立即成交时以-5022拒绝并保留原订单，订单不存在时返回-2013
+func (e *SimExchange) AmendOrder(ctx context.Context, order *gateway.Order) (*gateway.Order, error) {
+	if order == nil || order.Quantity <= 0 || order.Price <= 0 {
+		return nil, gateway.ErrInvalidOrder
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var target *simOrder
+	for _, o := range e.open[order.Symbol] {
+		if o.order.ClientOrderID == order.ClientOrderID {
+			target = o
+			break
+		}
+	}
+	if target == nil {
+		return nil, gateway.NewBinanceError(400, "PUT /fapi/v1/order", -2013, "Order does not exist.")
+	}
+	if math.Abs(target.order.Price-order.Price) <= qtyEpsilon && math.Abs(target.order.Quantity-order.Quantity) <= qtyEpsilon {
+		return nil, gateway.NewBinanceError(400, "PUT /fapi/v1/order", -5027, "No need to modify the order.")
+	}
+	if order.Quantity < target.order.FilledQty-qtyEpsilon {
+		return nil, gateway.ErrInvalidOrder
+	}
+
+	book := e.books[order.Symbol]
+	side := target.order.Side
+	wouldTake := (side == "BUY" && book.bestAsk() > 0 && order.Price >= book.bestAsk()) ||
+		(side == "SELL" && book.bestBid() > 0 && order.Price <= book.bestBid())
+	if wouldTake {
+		e.statsLocked(order.Symbol).Rejected++
+		return nil, gateway.NewBinanceError(400, "PUT /fapi/v1/order", -5022, "Due to the order could not be executed as maker, the Post Only order will be rejected.")
+	}
+
+	if math.Abs(target.order.Price-order.Price) > qtyEpsilon {
+		target.order.Price = order.Price
+		target.queueAhead = 0
+		if book != nil {
+			levels := book.asks
+			if side == "BUY" {
+				levels = book.bids
+			}
+			for _, lvl := range levels {
+				if math.Abs(lvl.Price-order.Price) <= qtyEpsilon {
+					target.queueAhead = lvl.Quantity
+					break
+				}
+			}
+		}
+		// 重新排队：移到挂单列表末尾，保持按seq排序
+		e.seq++
+		target.seq = e.seq
+		orders := e.open[order.Symbol]
+		kept := make([]*simOrder, 0, len(orders))
+		for _, o := range orders {
+			if o != target {
+				kept = append(kept, o)
+			}
+		}
+		e.open[order.Symbol] = append(kept, target)
+	}
+	target.order.Quantity = order.Quantity
+	e.statsLocked(order.Symbol).Amended++
+	result := target.order
+	return &result, nil
+}
+
+// AmendOrders 逐个改单，与实盘批量接口一样逐个返回结果
+func (e *SimExchange) AmendOrders(ctx context.Context, orders []*gateway.Order) []gateway.OrderResult {
+	return gateway.PlaceOrdersEach(ctx, orders, e.AmendOrder)
+}
+
+// PlaceOrders 逐个撮合下单，与实盘批量接口一样逐个返回结果
+func (e *SimExchange) PlaceOrders(ctx context.Context, orders []*gateway.Order) []gateway.OrderResult {
+	return gateway.PlaceOrdersEach(ctx, orders, e.PlaceOrder)
+}
+
+// CancelOrders 逐个撤单，返回的错误与clientOrderIDs一一对应
+func (e *SimExchange) CancelOrders(ctx context.Context, symbol string, clientOrderIDs []string) []error {
+	return gateway.CancelOrdersEach(ctx, symbol, clientOrderIDs, e.CancelOrder)
+}
+
+// CancelAllOrders 撤销交易对全部挂单
+func (e *SimExchange) CancelAllOrders(ctx context.Context, symbol string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, o := range e.open[symbol] {
+		o.order.Status = "CANCELED"
+		e.statsLocked(symbol).Canceled++
+	}
+	e.open[symbol] = nil
+	return nil
+}
+
+// GetOpenOrders 返回当前挂单（按下单顺序）
+func (e *SimExchange) GetOpenOrders(ctx context.Context, symbol string) ([]*gateway.Order, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	orders := make([]*gateway.Order, 0, len(e.open[symbol]))
+	for _, o := range e.open[symbol] {
+		cp := o.order
+		orders = append(orders, &cp)
+	}
+	return orders, nil
+}
+
+// GetPosition 返回交易对仓位
+func (e *SimExchange) GetPosition(ctx context.Context, symbol string) (*gateway.Position, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.positionLocked(symbol), nil
+}
+
+// GetAllPositions 返回所有非零仓位（按交易对排序）
+func (e *SimExchange) GetAllPositions(ctx context.Context) ([]*gateway.Position, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	symbols := make([]string, 0, len(e.positions))
+	for symbol, pos := range e.positions {
+		if pos.size != 0 {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+
+	positions := make([]*gateway.Position, 0, len(symbols))
+	for _, symbol := range symbols {
+		positions = append(positions, e.positionLocked(symbol))
+	}
+	return positions, nil
+}
+
+// GetAccountBalance 返回钱包余额（含已实现盈亏与手续费）和未实现盈亏
+func (e *SimExchange) GetAccountBalance(ctx context.Context) (float64, float64, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	unrealized := 0.0
+	for symbol := range e.positions {
+		unrealized += e.positionLocked(symbol).UnrealizedPNL
+	}
+	return e.balance, unrealized, nil
+}
+
+// GetFundingRate 回测不模拟资金费率
+func (e *SimExchange) GetFundingRate(ctx context.Context, symbol string) (*gateway.FundingRate, error) {
+	return &gateway.FundingRate{Symbol: symbol, Timestamp: e.clock.Now()}, nil
+}
+
+// GetDepth 返回最近一次深度快照
+func (e *SimExchange) GetDepth(ctx context.Context, symbol string, limit int) (*gateway.Depth, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	book := e.books[symbol]
+	if book == nil {
+		return nil, fmt.Errorf("no depth for %s", symbol)
+	}
+	bids, asks := book.bids, book.asks
+	if limit > 0 && len(bids) > limit {
+		bids = bids[:limit]
+	}
+	if limit > 0 && len(asks) > limit {
+		asks = asks[:limit]
+	}
+	return &gateway.Depth{
+		Symbol:    symbol,
+		Bids:      append([]gateway.PriceLevel(nil), bids...),
+		Asks:      append([]gateway.PriceLevel(nil), asks...),
+		Timestamp: e.clock.Now(),
+	}, nil
+}
+
+// StartDepthStream 注册深度回调，由ApplyDepth驱动
+func (e *SimExchange) StartDepthStream(ctx context.Context, symbols []string, callback func(*gateway.Depth)) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.depthCallback = callback
+	return nil
+}
+
+// StartTradeStream 注册逐笔成交回调，由ApplyTrade在撮合后驱动
+func (e *SimExchange) StartTradeStream(ctx context.Context, symbols []string, onTrade func(*gateway.Trade)) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tradeCallback = onTrade
+	return nil
+}
+
+// StartUserStream 注册用户数据回调，成交时触发
+func (e *SimExchange) StartUserStream(ctx context.Context, callbacks *gateway.UserStreamCallbacks) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.userCallbacks = callbacks
+	return nil
+}
+
+// Connect 连接（模拟）
+func (e *SimExchange) Connect(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.connected = true
+	return nil
+}
+
+// Disconnect 断开（模拟）
+func (e *SimExchange) Disconnect() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.connected = false
+	return nil
+}
+
+// IsConnected 是否已连接
+func (e *SimExchange) IsConnected() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.connected
+}
@@ -0,0 +1,153 @@
+package sim
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	gateway "github.com/newplayman/market-maker-phoenix/internal/exchange"
+	"github.com/newplayman/market-maker-phoenix/internal/order"
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+)
+
+var testStart = time.Unix(1700000000, 0)
+
+func levels(price, step, qty float64, n int) []gateway.PriceLevel {
+	out := make([]gateway.PriceLevel, n)
+	for i := range out {
+		out[i] = gateway.PriceLevel{Price: price + step*float64(i), Quantity: qty}
+	}
+	return out
+}
+
+func newConnectedExchange(t *testing.T) *SimExchange {
+	t.Helper()
+	exch := NewSimExchange(NewClock(testStart), 0.0002, 1000)
+	if err := exch.Connect(context.Background()); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	exch.ApplyDepth("ETHUSDC", levels(100, -0.1, 3, 5), levels(100.1, 0.1, 3, 5))
+	return exch
+}
+
+func TestSimExchangePostOnlyReject(t *testing.T) {
+	exch := newConnectedExchange(t)
+	ctx := context.Background()
+
+	_, err := exch.PlaceOrder(ctx, &gateway.Order{Symbol: "ETHUSDC", Side: "BUY", Price: 100.1, Quantity: 1})
+	if err == nil || !strings.Contains(err.Error(), "-5022") {
+		t.Fatalf("expected -5022 reject, got %v", err)
+	}
+	if _, err := exch.PlaceOrder(ctx, &gateway.Order{Symbol: "ETHUSDC", Side: "SELL", Price: 100.1, Quantity: 1}); err != nil {
+		t.Fatalf("maker sell should be accepted: %v", err)
+	}
+	if st := exch.Stats("ETHUSDC"); st.Rejected != 1 || st.Placed != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+
+	if err := exch.CancelOrder(ctx, "ETHUSDC", "missing"); err == nil || !strings.Contains(err.Error(), "-2011") {
+		t.Fatalf("expected -2011 for unknown order, got %v", err)
+	}
+}
+
+func TestSimExchangeQueuePosition(t *testing.T) {
+	exch := newConnectedExchange(t)
+	ctx := context.Background()
+
+	var updates []gateway.Order
+	exch.StartUserStream(ctx, &gateway.UserStreamCallbacks{
+		OnOrderUpdate: func(o *gateway.Order) { updates = append(updates, *o) },
+	})
+
+	// 买一价100已有3手排队
+	placed, err := exch.PlaceOrder(ctx, &gateway.Order{Symbol: "ETHUSDC", Side: "BUY", Price: 100, Quantity: 1})
+	if err != nil {
+		t.Fatalf("place: %v", err)
+	}
+
+	// 2手成交只消耗前方队列
+	exch.ApplyTrade(&gateway.Trade{Symbol: "ETHUSDC", Price: 100, Quantity: 2, IsBuyerMaker: true})
+	if len(updates) != 0 {
+		t.Fatalf("order should still be queued, got %+v", updates)
+	}
+
+	// 再1.5手：1手消耗剩余队列，0.5手成交本单
+	exch.ApplyTrade(&gateway.Trade{Symbol: "ETHUSDC", Price: 100, Quantity: 1.5, IsBuyerMaker: true})
+	if len(updates) != 1 || updates[0].Status != "PARTIALLY_FILLED" || updates[0].FilledQty != 0.5 {
+		t.Fatalf("expected partial fill of 0.5, got %+v", updates)
+	}
+
+	// 价格穿越后全部成交
+	exch.ApplyTrade(&gateway.Trade{Symbol: "ETHUSDC", Price: 99.9, Quantity: 0.1, IsBuyerMaker: true})
+	if len(updates) != 2 || updates[1].Status != "FILLED" || updates[1].ClientOrderID != placed.ClientOrderID {
+		t.Fatalf("expected full fill, got %+v", updates)
+	}
+
+	pos, _ := exch.GetPosition(ctx, "ETHUSDC")
+	if pos.Size != 1 || pos.EntryPrice != 100 {
+		t.Fatalf("unexpected position: %+v", pos)
+	}
+	if orders, _ := exch.GetOpenOrders(ctx, "ETHUSDC"); len(orders) != 0 {
+		t.Fatalf("expected no open orders, got %d", len(orders))
+	}
+}
+
+// fillBeforeAckExchange 下单返回前即撮合并推送成交，模拟成交推送先于下单确认到达
+type fillBeforeAckExchange struct {
+	*SimExchange
+}
+
+func (f *fillBeforeAckExchange) PlaceOrders(ctx context.Context, orders []*gateway.Order) []gateway.OrderResult {
+	results := f.SimExchange.PlaceOrders(ctx, orders)
+	for _, o := range orders {
+		f.ApplyTrade(&gateway.Trade{Symbol: o.Symbol, Price: o.Price, Quantity: o.Quantity, IsBuyerMaker: o.Side == "BUY"})
+	}
+	return results
+}
+
+func TestSimExchangeKeepsClientOrderID(t *testing.T) {
+	exch := NewSimExchange(NewClock(testStart), 0.0002, 1000)
+	ctx := context.Background()
+	if err := exch.Connect(ctx); err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	exch.ApplyDepth("ETHUSDC", levels(99.9, -0.1, 3, 5), levels(100.1, 0.1, 3, 5))
+
+	st := store.NewStore("", time.Minute)
+	defer st.Close()
+	st.InitSymbol("ETHUSDC", 10)
+	om := order.NewOrderManager(st, &fillBeforeAckExchange{exch})
+	exch.StartUserStream(ctx, &gateway.UserStreamCallbacks{OnOrderUpdate: om.OnOrderUpdate})
+
+	if err := om.ApplyDiff(ctx, "ETHUSDC", nil, nil, []*gateway.Order{{Symbol: "ETHUSDC", Side: "BUY", Price: 100, Quantity: 1}}); err != nil {
+		t.Fatalf("apply diff: %v", err)
+	}
+	if active := om.ActiveOrders("ETHUSDC"); len(active) != 0 {
+		t.Fatalf("filled order should not stay tracked after a late ack, got %+v", active[0])
+	}
+	if pos, _ := exch.GetPosition(ctx, "ETHUSDC"); pos.Size != 1 {
+		t.Fatalf("expected the order to fill, position %+v", pos)
+	}
+
+	if _, err := exch.PlaceOrder(ctx, &gateway.Order{ClientOrderID: "c1", Symbol: "ETHUSDC", Side: "SELL", Price: 101, Quantity: 1}); err != nil {
+		t.Fatalf("place: %v", err)
+	}
+	if _, err := exch.PlaceOrder(ctx, &gateway.Order{ClientOrderID: "c1", Symbol: "ETHUSDC", Side: "SELL", Price: 101.1, Quantity: 1}); !gateway.HasErrorCode(err, -4116) {
+		t.Fatalf("expected -4116 for a duplicate client id, got %v", err)
+	}
+	if orders, _ := exch.GetOpenOrders(ctx, "ETHUSDC"); len(orders) != 1 || orders[0].ClientOrderID != "c1" {
+		t.Fatalf("expected caller client id to be kept, got %+v", orders)
+	}
+}
+
+func TestApplyFillRealizedPnL(t *testing.T) {
+	pos := &simPosition{}
+	applyFill(pos, 2, 100)
+	if pnl := applyFill(pos, -3, 110); pnl != 20 {
+		t.Fatalf("expected realized 20, got %f", pnl)
+	}
+	if pos.size != -1 || pos.entry != 110 {
+		t.Fatalf("expected flip to short 1@110, got %+v", pos)
+	}
+}
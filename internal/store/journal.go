@@ -0,0 +1,641 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// EntryType 事件日志条目类型
+type EntryType string
+
+const (
+	EntryOrderPlaced    EntryType = "order_placed"
+	EntryOrderCanceled  EntryType = "order_canceled"
+	EntryOrderAmended   EntryType = "order_amended"
+	EntryFill           EntryType = "fill"
+	EntryPosition       EntryType = "position"
+	EntryFunding        EntryType = "funding"
+	EntryFundingPayment EntryType = "funding_payment"
+)
+
+const (
+	checkpointFile = "checkpoint.json"
+	segmentPrefix  = "journal-"
+	segmentSuffix  = ".ndjson"
+
+	defaultSyncInterval = 50 * time.Millisecond
+	defaultSyncBatch    = 256
+)
+
+// OrderEvent 下单/撤单/改单事件
+type OrderEvent struct {
+	ClientOrderID string  `json:"client_order_id"`
+	Side          string  `json:"side,omitempty"`
+	Price         float64 `json:"price,omitempty"`
+	Quantity      float64 `json:"quantity,omitempty"`
+	Filled        float64 `json:"filled,omitempty"` // 已记账的成交量
+}
+
+// FillEvent 成交事件
+type FillEvent struct {
+	ClientOrderID string  `json:"client_order_id,omitempty"`
+	Side          string  `json:"side,omitempty"`
+	Price         float64 `json:"price,omitempty"`
+	Quantity      float64 `json:"quantity"`
+	PNL           float64 `json:"pnl"`             // 交易所回报的已实现盈亏，为0时由账本按平均成本计算
+	Fee           float64 `json:"fee,omitempty"`   // 手续费（负值为返佣）
+	Maker         bool    `json:"maker,omitempty"` // 是否为maker成交
+	Final         bool    `json:"final,omitempty"` // 订单已完全成交，从挂单中移除
+}
+
+// FundingEvent 资金费率/资金费结算事件
+type FundingEvent struct {
+	Rate    float64 `json:"rate,omitempty"`
+	Payment float64 `json:"payment,omitempty"` // 结算金额（正为收入）
+}
+
+// JournalEntry 事件日志条目（每行一个JSON）
+type JournalEntry struct {
+	Seq      uint64        `json:"seq"`
+	Time     time.Time     `json:"time"`
+	Type     EntryType     `json:"type"`
+	Symbol   string        `json:"symbol"`
+	Order    *OrderEvent   `json:"order,omitempty"`
+	Fill     *FillEvent    `json:"fill,omitempty"`
+	Position *Position     `json:"position,omitempty"`
+	Funding  *FundingEvent `json:"funding,omitempty"`
+}
+
+// SymbolCheckpoint 单个交易对的可持久化状态
+type SymbolCheckpoint struct {
+	Position       Position              `json:"position"`
+	FundingRate    float64               `json:"funding_rate"`
+	FundingHistory []float64             `json:"funding_history,omitempty"`
+	FillCount      int64                 `json:"fill_count"`
+	TotalVolume    float64               `json:"total_volume"`
+	TotalPNL       float64               `json:"total_pnl"`
+	MaxDrawdown    float64               `json:"max_drawdown"`
+	LastFill       time.Time             `json:"last_fill"`
+	OpenOrders     map[string]OrderEvent `json:"open_orders,omitempty"`
+	Ledger         Ledger                `json:"ledger"`
+}
+
+// Checkpoint 压缩检查点：序号Seq及之前的全部事件已折叠进状态
+type Checkpoint struct {
+	Seq     uint64                       `json:"seq"`
+	Time    time.Time                    `json:"time"`
+	Symbols map[string]*SymbolCheckpoint `json:"symbols"`
+}
+
+// JournalOptions 事件日志参数
+type JournalOptions struct {
+	SyncInterval time.Duration // 批量fsync间隔（默认50ms）
+	SyncBatch    int           // 未落盘条目达到该数量时立即fsync（默认256）
+}
+
+// Journal 追加写事件日志（WAL）。
+// 日志按检查点切分为 journal-{首条序号}.ndjson 段文件，旧段保留供审计查询
+type Journal struct {
+	dir  string
+	opts JournalOptions
+
+	mu         sync.Mutex
+	file       *os.File
+	w          *bufio.Writer
+	seq        uint64 // 最后写入的序号
+	segEntries int    // 当前段已写入条目数
+	pending    int    // 已写入缓冲但未fsync的条目数
+	err        error  // 首个写入错误，出现后拒绝继续写入
+
+	kick chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// openJournalWriter 打开日志写入端：续写最后一个段文件，或从lastSeq+1新建段
+func openJournalWriter(dir string, lastSeq uint64, opts JournalOptions) (*Journal, error) {
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = defaultSyncInterval
+	}
+	if opts.SyncBatch <= 0 {
+		opts.SyncBatch = defaultSyncBatch
+	}
+
+	j := &Journal{
+		dir:  dir,
+		opts: opts,
+		seq:  lastSeq,
+		kick: make(chan struct{}, 1),
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	segments, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, segmentName(lastSeq+1))
+	if n := len(segments); n > 0 && segments[n-1].first <= lastSeq+1 {
+		path = segments[n-1].path
+		j.segEntries = int(lastSeq + 1 - segments[n-1].first)
+	}
+	if err := j.openSegment(path); err != nil {
+		return nil, err
+	}
+
+	go j.syncLoop()
+	return j, nil
+}
+
+func (j *Journal) openSegment(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("打开日志段失败: %w", err)
+	}
+	j.file = f
+	j.w = bufio.NewWriter(f)
+	return syncDir(j.dir)
+}
+
+// Append 追加一条事件并分配序号，落盘由后台批量fsync完成
+func (j *Journal) Append(e *JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err != nil {
+		return j.err
+	}
+
+	e.Seq = j.seq + 1
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("序列化日志条目失败: %w", err)
+	}
+	line = append(line, '\n')
+	if _, err := j.w.Write(line); err != nil {
+		j.err = fmt.Errorf("写入日志失败: %w", err)
+		return j.err
+	}
+	j.seq = e.Seq
+	j.segEntries++
+	j.pending++
+
+	if j.pending >= j.opts.SyncBatch {
+		select {
+		case j.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Sync 将缓冲中的条目写入文件并fsync
+func (j *Journal) Sync() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.syncLocked()
+}
+
+func (j *Journal) syncLocked() error {
+	if j.err != nil {
+		return j.err
+	}
+	if j.pending == 0 {
+		return nil
+	}
+	if err := j.w.Flush(); err != nil {
+		j.err = fmt.Errorf("刷新日志失败: %w", err)
+		return j.err
+	}
+	if err := j.file.Sync(); err != nil {
+		j.err = fmt.Errorf("fsync日志失败: %w", err)
+		return j.err
+	}
+	j.pending = 0
+	return nil
+}
+
+func (j *Journal) syncLoop() {
+	defer close(j.done)
+	ticker := time.NewTicker(j.opts.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-j.kick:
+		case <-j.stop:
+			return
+		}
+		if err := j.Sync(); err != nil {
+			log.Error().Err(err).Str("dir", j.dir).Msg("事件日志落盘失败")
+		}
+	}
+}
+
+// rotate 落盘并切换到新段文件，返回切换前的最后序号。
+// 当前段为空时不切换
+func (j *Journal) rotate() (uint64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.syncLocked(); err != nil {
+		return 0, err
+	}
+	if j.segEntries == 0 {
+		return j.seq, nil
+	}
+	if err := j.file.Close(); err != nil {
+		return 0, fmt.Errorf("关闭日志段失败: %w", err)
+	}
+	if err := j.openSegment(filepath.Join(j.dir, segmentName(j.seq+1))); err != nil {
+		j.err = err
+		return 0, err
+	}
+	j.segEntries = 0
+	return j.seq, nil
+}
+
+// LastSeq 最后写入的序号
+func (j *Journal) LastSeq() uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.seq
+}
+
+// Close 停止后台落盘并关闭文件
+func (j *Journal) Close() error {
+	close(j.stop)
+	<-j.done
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	err := j.syncLocked()
+	if cerr := j.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// segment 日志段文件
+type segment struct {
+	first uint64
+	path  string
+}
+
+func segmentName(first uint64) string {
+	return fmt.Sprintf("%s%020d%s", segmentPrefix, first, segmentSuffix)
+}
+
+func listSegments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取日志目录失败: %w", err)
+	}
+	var segments []segment
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		first, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{first: first, path: filepath.Join(dir, name)})
+	}
+	sort.Slice(segments, func(i, k int) bool { return segments[i].first < segments[k].first })
+	return segments, nil
+}
+
+// ScanJournal 按序号顺序遍历日志中序号大于afterSeq的条目。
+// 最后一个段末尾未写完整的条目（进程崩溃时的残留）会被忽略
+func ScanJournal(dir string, afterSeq uint64, fn func(*JournalEntry) error) error {
+	_, err := scanJournal(dir, afterSeq, false, fn)
+	return err
+}
+
+// scanJournal 遍历日志，repair为true时截断最后一个段的残缺尾部，返回最后一条有效序号
+func scanJournal(dir string, afterSeq uint64, repair bool, fn func(*JournalEntry) error) (uint64, error) {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	lastSeq := afterSeq
+	for i, seg := range segments {
+		// 下一段的首条序号不超过afterSeq+1时，本段已全部折叠进检查点
+		if i+1 < len(segments) && segments[i+1].first <= afterSeq+1 {
+			continue
+		}
+		last := i == len(segments)-1
+		validLen, err := scanSegment(seg.path, last, func(e *JournalEntry) error {
+			if e.Seq <= afterSeq {
+				return nil
+			}
+			if e.Seq <= lastSeq {
+				return fmt.Errorf("%s: 日志序号%d未递增", filepath.Base(seg.path), e.Seq)
+			}
+			lastSeq = e.Seq
+			return fn(e)
+		})
+		if err != nil {
+			return 0, err
+		}
+		if last && repair && validLen >= 0 {
+			if err := os.Truncate(seg.path, validLen); err != nil {
+				return 0, fmt.Errorf("截断残缺日志失败: %w", err)
+			}
+			log.Warn().Str("segment", seg.path).Int64("offset", validLen).Msg("事件日志尾部残缺，已截断")
+		}
+	}
+	return lastSeq, nil
+}
+
+// scanSegment 逐行解析段文件。tolerateTail为true时末尾残缺行不视为错误，
+// 此时返回残缺行起始偏移（无残缺时为-1）
+func scanSegment(path string, tolerateTail bool, fn func(*JournalEntry) error) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return -1, fmt.Errorf("打开日志段失败: %w", err)
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	var offset int64
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) == 0 && err == io.EOF {
+			return -1, nil
+		}
+		if err != nil && err != io.EOF {
+			return -1, fmt.Errorf("读取日志段失败: %w", err)
+		}
+
+		var e JournalEntry
+		complete := err == nil
+		if complete {
+			if perr := json.Unmarshal(bytes.TrimSpace(line), &e); perr != nil {
+				complete = false
+			}
+		}
+		if !complete {
+			if _, peekErr := r.Peek(1); tolerateTail && peekErr == io.EOF {
+				return offset, nil
+			}
+			return -1, fmt.Errorf("%s: 偏移%d处日志损坏", filepath.Base(path), offset)
+		}
+
+		if err := fn(&e); err != nil {
+			return -1, err
+		}
+		offset += int64(len(line))
+	}
+}
+
+// loadCheckpoint 读取检查点，不存在时返回nil
+func loadCheckpoint(dir string) (*Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(dir, checkpointFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取检查点失败: %w", err)
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("解析检查点失败: %w", err)
+	}
+	return &cp, nil
+}
+
+// writeCheckpoint 原子写入检查点：临时文件fsync后rename
+func writeCheckpoint(dir string, cp *Checkpoint) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化检查点失败: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, checkpointFile+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("创建检查点临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("写入检查点失败: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("fsync检查点失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("关闭检查点失败: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, checkpointFile)); err != nil {
+		return fmt.Errorf("替换检查点失败: %w", err)
+	}
+	return syncDir(dir)
+}
+
+// syncDir fsync目录，确保新建/重命名的文件项落盘
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil && !errors.Is(err, os.ErrInvalid) {
+		return err
+	}
+	return nil
+}
+
+// applyEntry 将一条事件折叠进交易对状态（实时写入与启动重放共用，保证重建结果确定）
+func applyEntry(state *SymbolState, e *JournalEntry) {
+	switch e.Type {
+	case EntryOrderPlaced:
+		if e.Order == nil {
+			return
+		}
+		if state.OpenOrders == nil {
+			state.OpenOrders = make(map[string]OrderEvent)
+		}
+		placed := *e.Order
+		// 成交推送先于下单确认记账时保留已记账的成交量
+		placed.Filled = state.OpenOrders[placed.ClientOrderID].Filled
+		state.OpenOrders[placed.ClientOrderID] = placed
+
+	case EntryOrderAmended:
+		if e.Order == nil {
+			return
+		}
+		if prev, ok := state.OpenOrders[e.Order.ClientOrderID]; ok {
+			prev.Price = e.Order.Price
+			prev.Quantity = e.Order.Quantity
+			state.OpenOrders[e.Order.ClientOrderID] = prev
+		}
+
+	case EntryOrderCanceled:
+		if e.Order != nil {
+			delete(state.OpenOrders, e.Order.ClientOrderID)
+		}
+
+	case EntryFill:
+		f := e.Fill
+		if f == nil {
+			return
+		}
+		// 数量为0的完全成交事件只结束挂单（各次成交已记账）
+		if f.Quantity != 0 {
+			state.FillCount++
+			state.TotalVolume += abs(f.Quantity)
+			state.LastFill = e.Time
+			state.Ledger.applyFill(f.Side, f.Price, abs(f.Quantity), f.Fee, f.Maker, f.PNL)
+			state.TotalPNL = state.Ledger.NetRealized()
+			state.MaxDrawdown = state.Ledger.MaxDrawdown
+		}
+		if f.ClientOrderID != "" {
+			if f.Final {
+				delete(state.OpenOrders, f.ClientOrderID)
+			} else {
+				if state.OpenOrders == nil {
+					state.OpenOrders = make(map[string]OrderEvent)
+				}
+				o := state.OpenOrders[f.ClientOrderID]
+				o.ClientOrderID = f.ClientOrderID
+				if o.Side == "" {
+					o.Side = f.Side
+				}
+				o.Filled += abs(f.Quantity)
+				state.OpenOrders[f.ClientOrderID] = o
+			}
+		}
+
+	case EntryPosition:
+		if e.Position != nil {
+			state.Position = *e.Position
+		}
+
+	case EntryFunding:
+		if e.Funding == nil {
+			return
+		}
+		state.FundingRate = e.Funding.Rate
+		state.FundingHistory = append(state.FundingHistory, e.Funding.Rate)
+		// 保持最近24个资金费率
+		if len(state.FundingHistory) > 24 {
+			state.FundingHistory = state.FundingHistory[1:]
+		}
+
+	case EntryFundingPayment:
+		if e.Funding == nil {
+			return
+		}
+		state.Ledger.applyFunding(e.Funding.Payment)
+		state.TotalPNL = state.Ledger.NetRealized()
+		state.MaxDrawdown = state.Ledger.MaxDrawdown
+	}
+}
+
+func abs(v float64) float64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// checkpointOf 提取交易对的可持久化状态（调用方持有state读锁）
+func checkpointOf(state *SymbolState) *SymbolCheckpoint {
+	cp := &SymbolCheckpoint{
+		Position:       state.Position,
+		FundingRate:    state.FundingRate,
+		FundingHistory: append([]float64(nil), state.FundingHistory...),
+		FillCount:      state.FillCount,
+		TotalVolume:    state.TotalVolume,
+		TotalPNL:       state.TotalPNL,
+		MaxDrawdown:    state.MaxDrawdown,
+		LastFill:       state.LastFill,
+		Ledger:         state.Ledger,
+	}
+	if len(state.OpenOrders) > 0 {
+		cp.OpenOrders = make(map[string]OrderEvent, len(state.OpenOrders))
+		for id, o := range state.OpenOrders {
+			cp.OpenOrders[id] = o
+		}
+	}
+	return cp
+}
+
+// restoreCheckpoint 用检查点覆盖交易对的可持久化状态
+func restoreCheckpoint(state *SymbolState, cp *SymbolCheckpoint) {
+	state.Position = cp.Position
+	state.FundingRate = cp.FundingRate
+	state.FundingHistory = append(state.FundingHistory[:0], cp.FundingHistory...)
+	state.FillCount = cp.FillCount
+	state.TotalVolume = cp.TotalVolume
+	state.TotalPNL = cp.TotalPNL
+	state.MaxDrawdown = cp.MaxDrawdown
+	state.LastFill = cp.LastFill
+	state.Ledger = cp.Ledger
+	state.OpenOrders = make(map[string]OrderEvent, len(cp.OpenOrders))
+	for id, o := range cp.OpenOrders {
+		state.OpenOrders[id] = o
+	}
+}
+
+// ReplayJournal 由检查点与日志尾部重建各交易对状态（只读，供审计CLI使用）
+func ReplayJournal(dir string) (*Checkpoint, error) {
+	states := make(map[string]*SymbolState)
+	get := func(symbol string) *SymbolState {
+		st := states[symbol]
+		if st == nil {
+			st = &SymbolState{Symbol: symbol}
+			states[symbol] = st
+		}
+		return st
+	}
+
+	cp, err := loadCheckpoint(dir)
+	if err != nil {
+		return nil, err
+	}
+	var seq uint64
+	if cp != nil {
+		seq = cp.Seq
+		for symbol, symCp := range cp.Symbols {
+			restoreCheckpoint(get(symbol), symCp)
+		}
+	}
+
+	last, err := scanJournal(dir, seq, false, func(e *JournalEntry) error {
+		applyEntry(get(e.Symbol), e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &Checkpoint{Seq: last, Symbols: make(map[string]*SymbolCheckpoint, len(states))}
+	for symbol, st := range states {
+		out.Symbols[symbol] = checkpointOf(st)
+	}
+	return out, nil
+}
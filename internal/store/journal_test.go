@@ -0,0 +1,164 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestStore(t *testing.T, dir string) *Store {
+	t.Helper()
+	st, err := OpenStore(dir, time.Hour, JournalOptions{})
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	st.InitSymbol("ETHUSDC", 100)
+	return st
+}
+
+func writeTestEvents(st *Store) {
+	st.UpdatePosition("ETHUSDC", Position{Symbol: "ETHUSDC", Size: 0.3, EntryPrice: 3000, Notional: 900})
+	st.RecordOrderPlaced("ETHUSDC", OrderEvent{ClientOrderID: "b1", Side: "BUY", Price: 2990, Quantity: 0.1})
+	st.RecordOrderPlaced("ETHUSDC", OrderEvent{ClientOrderID: "s1", Side: "SELL", Price: 3010, Quantity: 0.1})
+	st.RecordOrderFill("ETHUSDC", FillEvent{ClientOrderID: "b1", Side: "BUY", Price: 2990, Quantity: 0.1, PNL: -2, Final: true})
+	st.RecordOrderCanceled("ETHUSDC", "s1")
+	st.RecordOrderPlaced("ETHUSDC", OrderEvent{ClientOrderID: "s2", Side: "SELL", Price: 3012, Quantity: 0.2})
+	st.UpdateFundingRate("ETHUSDC", 0.0001)
+}
+
+func checkTestState(t *testing.T, state *SymbolState) {
+	t.Helper()
+	if state.Position.Size != 0.3 || state.Position.EntryPrice != 3000 {
+		t.Errorf("unexpected position %+v", state.Position)
+	}
+	if state.FillCount != 1 || state.TotalPNL != -2 || state.MaxDrawdown != 2 {
+		t.Errorf("unexpected fill stats count=%d pnl=%f dd=%f", state.FillCount, state.TotalPNL, state.MaxDrawdown)
+	}
+	if len(state.OpenOrders) != 1 || state.OpenOrders["s2"].Price != 3012 {
+		t.Errorf("expected only s2 open, got %+v", state.OpenOrders)
+	}
+	if state.FundingRate != 0.0001 || len(state.FundingHistory) != 1 {
+		t.Errorf("unexpected funding rate=%f history=%v", state.FundingRate, state.FundingHistory)
+	}
+}
+
+func TestJournal_RecoverFromJournal(t *testing.T) {
+	dir := t.TempDir()
+
+	st := openTestStore(t, dir)
+	writeTestEvents(st)
+	// 不经过Close直接落盘，模拟未写检查点的崩溃
+	if err := st.journal.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	st.journal.Close()
+
+	recovered := openTestStore(t, dir)
+	defer recovered.Close()
+	checkTestState(t, recovered.GetSymbolState("ETHUSDC"))
+	if recovered.GetTotalNotional() != 900 {
+		t.Errorf("expected total notional 900, got %f", recovered.GetTotalNotional())
+	}
+	if got := recovered.journal.LastSeq(); got != 7 {
+		t.Errorf("expected last seq 7, got %d", got)
+	}
+}
+
+func TestJournal_CheckpointAndTail(t *testing.T) {
+	dir := t.TempDir()
+
+	st := openTestStore(t, dir)
+	writeTestEvents(st)
+	if err := st.Checkpoint(); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	// 检查点之后的尾部事件
+	st.RecordOrderFill("ETHUSDC", FillEvent{ClientOrderID: "s2", Side: "SELL", Price: 3012, Quantity: 0.2, PNL: 5, Final: true})
+	st.Close()
+
+	cp, err := loadCheckpoint(dir)
+	if err != nil || cp == nil {
+		t.Fatalf("expected checkpoint, got %v err=%v", cp, err)
+	}
+	if cp.Seq != 8 {
+		t.Errorf("expected checkpoint seq 8 after close, got %d", cp.Seq)
+	}
+	segments, _ := listSegments(dir)
+	if len(segments) < 2 || segments[1].first != 8 {
+		t.Errorf("expected a new segment starting at seq 8 after checkpoint, got %+v", segments)
+	}
+
+	recovered := openTestStore(t, dir)
+	defer recovered.Close()
+	state := recovered.GetSymbolState("ETHUSDC")
+	if state.FillCount != 2 || state.TotalPNL != 3 || len(state.OpenOrders) != 0 {
+		t.Errorf("unexpected state after tail replay: fills=%d pnl=%f open=%v", state.FillCount, state.TotalPNL, state.OpenOrders)
+	}
+
+	// 审计查询可遍历全部历史段
+	var types []EntryType
+	if err := ScanJournal(dir, 0, func(e *JournalEntry) error {
+		types = append(types, e.Type)
+		return nil
+	}); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(types) != 8 || types[0] != EntryPosition || types[7] != EntryFill {
+		t.Errorf("unexpected scanned entries %v", types)
+	}
+}
+
+func TestJournal_TruncatesTornTail(t *testing.T) {
+	dir := t.TempDir()
+
+	st := openTestStore(t, dir)
+	writeTestEvents(st)
+	if err := st.journal.Sync(); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	st.journal.Close()
+
+	segments, _ := listSegments(dir)
+	path := segments[len(segments)-1].path
+	info, _ := os.Stat(path)
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open segment: %v", err)
+	}
+	f.WriteString(`{"seq":8,"type":"fill","symbol":"ETH`)
+	f.Close()
+
+	recovered := openTestStore(t, dir)
+	checkTestState(t, recovered.GetSymbolState("ETHUSDC"))
+	if after, _ := os.Stat(path); after.Size() != info.Size() {
+		t.Errorf("expected torn tail truncated to %d bytes, got %d", info.Size(), after.Size())
+	}
+
+	// 截断后继续写入的序号与残缺条目无冲突
+	recovered.UpdateFundingRate("ETHUSDC", 0.0002)
+	recovered.Close()
+
+	cp, err := ReplayJournal(dir)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if cp.Seq != 8 || cp.Symbols["ETHUSDC"].FundingRate != 0.0002 {
+		t.Errorf("unexpected replay seq=%d funding=%f", cp.Seq, cp.Symbols["ETHUSDC"].FundingRate)
+	}
+}
+
+func TestJournal_CorruptMiddleFails(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, segmentName(1))
+	data := `{"seq":1,"type":"funding","symbol":"ETHUSDC","funding":{"rate":0.1}}` + "\n" +
+		`not json` + "\n" +
+		`{"seq":3,"type":"funding","symbol":"ETHUSDC","funding":{"rate":0.2}}` + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenStore(dir, time.Hour, JournalOptions{}); err == nil {
+		t.Error("expected corruption in the middle of a segment to fail recovery")
+	}
+}
@@ -0,0 +1,196 @@
+package store
+
+import "math"
+
+// ledgerEpsilon 仓位归零判定阈值
+const ledgerEpsilon = 1e-9
+
+// Ledger 单个交易对的盈亏账本（平均成本法）。
+// 账本由事件日志中的成交与资金费事件折叠而成，从日志起点开始累计，
+// 只随事件变化，因此检查点中的账本总能由日志重放得到；
+// 净值 = 已实现盈亏 - 手续费 + 资金费净收入 + 按最近成交价计算的未实现盈亏。
+// 按中间价标记的高水位与回撤见 MarkToMarket
+type Ledger struct {
+	Position        float64 `json:"position"`         // 账本仓位（正为多头）
+	AvgEntry        float64 `json:"avg_entry"`        // 平均开仓价
+	MarkPrice       float64 `json:"mark_price"`       // 最近成交价
+	RealizedPnL     float64 `json:"realized_pnl"`     // 已实现盈亏（不含手续费）
+	MakerFees       float64 `json:"maker_fees"`       // maker手续费（负值为返佣）
+	TakerFees       float64 `json:"taker_fees"`       // taker手续费
+	FundingPaid     float64 `json:"funding_paid"`     // 累计支付的资金费（正数）
+	FundingReceived float64 `json:"funding_received"` // 累计收取的资金费
+	HighWater       float64 `json:"high_water"`       // 成交/资金费事件时点的净值高水位
+	MaxDrawdown     float64 `json:"max_drawdown"`     // 成交/资金费事件时点的净值最大回撤
+}
+
+// UnrealizedPnL 按标记价格计算的未实现盈亏
+func (l *Ledger) UnrealizedPnL() float64 {
+	if l.Position == 0 || l.MarkPrice <= 0 {
+		return 0
+	}
+	return l.Position * (l.MarkPrice - l.AvgEntry)
+}
+
+// Fees 累计手续费
+func (l *Ledger) Fees() float64 {
+	return l.MakerFees + l.TakerFees
+}
+
+// NetFunding 资金费净收入
+func (l *Ledger) NetFunding() float64 {
+	return l.FundingReceived - l.FundingPaid
+}
+
+// NetRealized 已实现净盈亏：已实现盈亏 - 手续费 + 资金费净收入
+func (l *Ledger) NetRealized() float64 {
+	return l.RealizedPnL - l.Fees() + l.NetFunding()
+}
+
+// Equity 账本净值（已实现净盈亏 + 未实现盈亏）
+func (l *Ledger) Equity() float64 {
+	return l.NetRealized() + l.UnrealizedPnL()
+}
+
+// Drawdown 当前净值距高水位的回撤
+func (l *Ledger) Drawdown() float64 {
+	return math.Max(0, l.HighWater-l.Equity())
+}
+
+// applyFill 记入一笔成交并返回本笔已实现盈亏（不含手续费）。
+// 缺少方向或价格时（旧接口）只记入reported；reported非零时以交易所回报的已实现盈亏为准
+func (l *Ledger) applyFill(side string, price, qty, fee float64, maker bool, reported float64) float64 {
+	realized := 0.0
+	if (side == "BUY" || side == "SELL") && price > 0 && qty > 0 {
+		signed := qty
+		if side == "SELL" {
+			signed = -qty
+		}
+		if l.Position == 0 || (l.Position > 0) == (signed > 0) {
+			// 开仓或加仓：更新平均开仓价
+			size := math.Abs(l.Position)
+			l.AvgEntry = (l.AvgEntry*size + price*qty) / (size + qty)
+			l.Position += signed
+		} else {
+			// 减仓/平仓/反手：平掉部分按平均成本实现盈亏
+			closed := math.Min(math.Abs(l.Position), qty)
+			if l.Position > 0 {
+				realized = closed * (price - l.AvgEntry)
+			} else {
+				realized = closed * (l.AvgEntry - price)
+			}
+			flipped := qty-closed > ledgerEpsilon
+			l.Position += signed
+			switch {
+			case math.Abs(l.Position) <= ledgerEpsilon:
+				l.Position, l.AvgEntry = 0, 0
+			case flipped:
+				l.AvgEntry = price
+			}
+		}
+		l.MarkPrice = price
+	}
+	if reported != 0 {
+		realized = reported
+	}
+
+	l.RealizedPnL += realized
+	if maker {
+		l.MakerFees += fee
+	} else {
+		l.TakerFees += fee
+	}
+	l.updateHighWater()
+	return realized
+}
+
+// applyFunding 记入一次资金费结算（正为收入）
+func (l *Ledger) applyFunding(payment float64) {
+	if payment >= 0 {
+		l.FundingReceived += payment
+	} else {
+		l.FundingPaid -= payment
+	}
+	l.updateHighWater()
+}
+
+// updateHighWater 更新净值高水位与峰谷最大回撤
+func (l *Ledger) updateHighWater() {
+	equity := l.Equity()
+	if equity > l.HighWater {
+		l.HighWater = equity
+	}
+	if dd := l.HighWater - equity; dd > l.MaxDrawdown {
+		l.MaxDrawdown = dd
+	}
+}
+
+// GetLedger 返回交易对盈亏账本的副本
+func (s *Store) GetLedger(symbol string) (Ledger, bool) {
+	state := s.GetSymbolState(symbol)
+	if state == nil {
+		return Ledger{}, false
+	}
+	state.Mu.RLock()
+	defer state.Mu.RUnlock()
+	return state.Ledger, true
+}
+
+// BookedFillQty 返回挂单已记账的成交量（未跟踪或已完全成交的订单为0）
+func (s *Store) BookedFillQty(symbol, clientOrderID string) float64 {
+	state := s.GetSymbolState(symbol)
+	if state == nil {
+		return 0
+	}
+	state.Mu.RLock()
+	defer state.Mu.RUnlock()
+	return state.OpenOrders[clientOrderID].Filled
+}
+
+// RecordFundingPayment 记录一次资金费结算（正为收入，负为支出）
+func (s *Store) RecordFundingPayment(symbol string, payment float64) {
+	state := s.GetSymbolState(symbol)
+	if state == nil || payment == 0 {
+		return
+	}
+	s.commit(state, &JournalEntry{Type: EntryFundingPayment, Symbol: symbol, Funding: &FundingEvent{Payment: payment}})
+}
+
+// MarkToMarket 按中间价标记的净值高水位与回撤。中间价更新不写入事件日志，
+// 这些值不进入账本与检查点：重启后从账本（由事件确定性重建）的高水位重新开始跟踪
+type MarkToMarket struct {
+	Price       float64 // 最近中间价
+	HighWater   float64 // 按中间价标记的净值高水位
+	MaxDrawdown float64 // 按中间价标记的净值最大回撤
+}
+
+// update 按最新中间价标记账本净值
+func (m *MarkToMarket) update(l *Ledger, price float64) {
+	if price <= 0 {
+		return
+	}
+	m.Price = price
+	equity := m.Equity(l)
+	m.HighWater = math.Max(math.Max(m.HighWater, l.HighWater), equity)
+	m.MaxDrawdown = math.Max(math.Max(m.MaxDrawdown, l.MaxDrawdown), m.HighWater-equity)
+}
+
+// UnrealizedPnL 按中间价计算的未实现盈亏（尚无中间价时按最近成交价）
+func (m *MarkToMarket) UnrealizedPnL(l *Ledger) float64 {
+	if m.Price <= 0 {
+		return l.UnrealizedPnL()
+	}
+	if l.Position == 0 {
+		return 0
+	}
+	return l.Position * (m.Price - l.AvgEntry)
+}
+
+// Equity 按中间价标记的账本净值
+func (m *MarkToMarket) Equity(l *Ledger) float64 {
+	return l.NetRealized() + m.UnrealizedPnL(l)
+}
+
+// Drawdown 按中间价标记的净值距高水位的回撤
+func (m *MarkToMarket) Drawdown(l *Ledger) float64 {
+	return math.Max(0, math.Max(m.HighWater, l.HighWater)-m.Equity(l))
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func approx(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestLedger_AverageCostAndFees(t *testing.T) {
+	st := NewStore("", time.Hour)
+	defer st.Close()
+	st.InitSymbol("ETHUSDC", 100)
+
+	// 两次开多：均价 (3000*1 + 3030*2)/3 = 3020
+	st.RecordOrderFill("ETHUSDC", FillEvent{Side: "BUY", Price: 3000, Quantity: 1, Fee: -0.3, Maker: true})
+	st.RecordOrderFill("ETHUSDC", FillEvent{Side: "BUY", Price: 3030, Quantity: 2, Fee: 1.2})
+	l, _ := st.GetLedger("ETHUSDC")
+	if !approx(l.Position, 3) || !approx(l.AvgEntry, 3020) {
+		t.Fatalf("expected 3 @ 3020, got %f @ %f", l.Position, l.AvgEntry)
+	}
+	if !approx(l.MakerFees, -0.3) || !approx(l.TakerFees, 1.2) {
+		t.Errorf("unexpected fees maker=%f taker=%f", l.MakerFees, l.TakerFees)
+	}
+
+	// 卖出4：平多3实现 3*(3050-3020)=90，反手空1 @ 3050
+	st.RecordOrderFill("ETHUSDC", FillEvent{Side: "SELL", Price: 3050, Quantity: 4, Maker: true})
+	l, _ = st.GetLedger("ETHUSDC")
+	if !approx(l.RealizedPnL, 90) || !approx(l.Position, -1) || !approx(l.AvgEntry, 3050) {
+		t.Fatalf("unexpected after flip: realized=%f pos=%f entry=%f", l.RealizedPnL, l.Position, l.AvgEntry)
+	}
+
+	// 交易所回报的已实现盈亏优先
+	st.RecordOrderFill("ETHUSDC", FillEvent{Side: "BUY", Price: 3040, Quantity: 1, PNL: 9.5, Maker: true})
+	l, _ = st.GetLedger("ETHUSDC")
+	if !approx(l.RealizedPnL, 99.5) || l.Position != 0 || l.AvgEntry != 0 {
+		t.Errorf("expected flat with realized 99.5, got realized=%f pos=%f entry=%f", l.RealizedPnL, l.Position, l.AvgEntry)
+	}
+
+	st.RecordFundingPayment("ETHUSDC", -0.4)
+	st.RecordFundingPayment("ETHUSDC", 0.1)
+	l, _ = st.GetLedger("ETHUSDC")
+	if !approx(l.FundingPaid, 0.4) || !approx(l.FundingReceived, 0.1) {
+		t.Errorf("unexpected funding paid=%f received=%f", l.FundingPaid, l.FundingReceived)
+	}
+
+	state := st.GetSymbolState("ETHUSDC")
+	want := 99.5 - 0.9 - 0.3
+	if !approx(state.TotalPNL, want) || !approx(l.NetRealized(), want) {
+		t.Errorf("expected net realized %f, got state=%f ledger=%f", want, state.TotalPNL, l.NetRealized())
+	}
+}
+
+func TestLedger_HighWaterDrawdown(t *testing.T) {
+	st := NewStore("", time.Hour)
+	defer st.Close()
+	st.InitSymbol("ETHUSDC", 100)
+
+	st.RecordOrderFill("ETHUSDC", FillEvent{Side: "BUY", Price: 3000, Quantity: 1})
+	st.UpdateMidPrice("ETHUSDC", 3100, 3099, 3101) // 净值 +100
+	st.UpdateMidPrice("ETHUSDC", 2950, 2949, 2951) // 净值 -50，峰谷回撤150
+	st.UpdateMidPrice("ETHUSDC", 3050, 3049, 3051) // 净值 +50
+
+	state := st.GetSymbolState("ETHUSDC")
+	l, m := state.Ledger, state.Mark
+	if !approx(m.HighWater, 100) || !approx(m.MaxDrawdown, 150) {
+		t.Errorf("expected mark hwm 100 / max dd 150, got %f / %f", m.HighWater, m.MaxDrawdown)
+	}
+	if !approx(m.UnrealizedPnL(&l), 50) || !approx(m.Drawdown(&l), 50) {
+		t.Errorf("expected unrealized 50 / drawdown 50, got %f / %f", m.UnrealizedPnL(&l), m.Drawdown(&l))
+	}
+
+	// 中间价不改变账本：账本只由事件决定
+	if l.MarkPrice != 3000 || l.HighWater != 0 || l.MaxDrawdown != 0 || state.MaxDrawdown != 0 {
+		t.Errorf("mid updates should not touch the ledger, got %+v (state dd %f)", l, state.MaxDrawdown)
+	}
+}
+
+// TestLedger_CheckpointMatchesReplay 检查点中的账本与仅重放事件得到的账本一致，不受检查点前中间价的影响
+func TestLedger_CheckpointMatchesReplay(t *testing.T) {
+	dir := t.TempDir()
+	st := openTestStore(t, dir)
+	st.RecordOrderFill("ETHUSDC", FillEvent{Side: "BUY", Price: 3000, Quantity: 1, Fee: 0.3})
+	st.UpdateMidPrice("ETHUSDC", 3100, 3099, 3101)
+	st.UpdateMidPrice("ETHUSDC", 2950, 2949, 2951)
+	if err := st.Checkpoint(); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	st.RecordFundingPayment("ETHUSDC", -0.2)
+	st.Close()
+
+	replayed := NewStore("", time.Hour)
+	defer replayed.Close()
+	replayed.InitSymbol("ETHUSDC", 100)
+	replayed.RecordOrderFill("ETHUSDC", FillEvent{Side: "BUY", Price: 3000, Quantity: 1, Fee: 0.3})
+	replayed.RecordFundingPayment("ETHUSDC", -0.2)
+	want, _ := replayed.GetLedger("ETHUSDC")
+
+	recovered := openTestStore(t, dir)
+	defer recovered.Close()
+	if got, _ := recovered.GetLedger("ETHUSDC"); got != want {
+		t.Fatalf("checkpointed ledger %+v differs from replay %+v", got, want)
+	}
+
+	// 重启后按中间价从账本高水位(0)重新跟踪回撤：净值 = -50 - 0.3 - 0.2
+	recovered.UpdateMidPrice("ETHUSDC", 2950, 2949, 2951)
+	state := recovered.GetSymbolState("ETHUSDC")
+	if dd := state.Mark.Drawdown(&state.Ledger); !approx(dd, 50.5) {
+		t.Errorf("expected drawdown 50.5 from ledger high water, got %f", dd)
+	}
+}
+
+func TestLedger_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	st := openTestStore(t, dir)
+	st.RecordOrderFill("ETHUSDC", FillEvent{Side: "SELL", Price: 3000, Quantity: 2, Fee: 0.6})
+	if err := st.Checkpoint(); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	st.RecordOrderFill("ETHUSDC", FillEvent{Side: "BUY", Price: 2990, Quantity: 1, Fee: 0.3})
+	st.RecordFundingPayment("ETHUSDC", 0.25)
+	st.Close()
+
+	recovered := openTestStore(t, dir)
+	defer recovered.Close()
+	l, _ := recovered.GetLedger("ETHUSDC")
+	if !approx(l.Position, -1) || !approx(l.AvgEntry, 3000) || !approx(l.RealizedPnL, 10) {
+		t.Errorf("unexpected ledger after restart: %+v", l)
+	}
+	if !approx(l.TakerFees, 0.9) || !approx(l.FundingReceived, 0.25) {
+		t.Errorf("unexpected fees/funding after restart: %+v", l)
+	}
+}
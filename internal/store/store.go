@@ -1,13 +1,14 @@
 package store
 
 import (
-	"encoding/json"
+	"fmt"
 	"math"
 	"os"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/newplayman/market-maker-phoenix/internal/filters"
 	"github.com/rs/zerolog/log"
 )
 
@@ -48,26 +49,54 @@ type SymbolState struct {
 	// 资金费率历史（用于EMA计算）
 	FundingHistory []float64
 
+	// 本地记录的挂单（由事件日志的下单/撤单/成交事件维护）
+	OpenOrders map[string]OrderEvent
+
+	// 市场逐笔成交（按时间窗口保留，不写入检查点）
+	Trades TradeTape
+
+	// 盈亏账本（成交、手续费、资金费）
+	Ledger Ledger
+
+	// 按中间价标记的净值高水位与回撤（不写入日志与检查点）
+	Mark MarkToMarket
+
 	// 统计信息
 	FillCount       int64   // 成交次数
 	TotalVolume     float64 // 总成交量
-	TotalPNL        float64 // 累计盈亏
-	MaxDrawdown     float64 // 最大回撤
+	TotalPNL        float64 // 已实现净盈亏（同Ledger.NetRealized）
+	MaxDrawdown     float64 // 事件时点的净值峰谷最大回撤（同Ledger.MaxDrawdown）
 	CancelCountLast int     // 最近一分钟撤单数
 	LastCancelReset time.Time
+	AmendCountLast  int // 最近一分钟改单数（与撤单数分开统计）
+	LastAmendReset  time.Time
 
 	// 策略状态
 	LastMode string // 最后使用的策略模式 (normal/pinning/grinding)
+
+	// 交易所交易规则（启动时同步并定期刷新，不写入检查点）
+	Filters filters.Symbol
 }
 
 type Store struct {
-	mu              sync.RWMutex
-	symbols         map[string]*SymbolState
-	totalNotional   atomic.Value // float64
-	snapshotPath    string
-	snapshotTicker  *time.Ticker
-	stopSnapshot    chan struct{}
-	lastSnapshotErr error
+	mu            sync.RWMutex
+	symbols       map[string]*SymbolState
+	totalNotional atomic.Value  // float64
+	tradeWindow   time.Duration // 逐笔成交保留时长
+
+	// 事件日志（为nil时仅保留内存状态）
+	// commitMu：写入事件时持读锁，生成检查点时持写锁，保证检查点与日志序号一致
+	journal           *Journal
+	commitMu          sync.RWMutex
+	lastCheckpointSeq uint64
+	checkpointTicker  *time.Ticker
+	stopCheckpoint    chan struct{}
+	checkpointDone    chan struct{}
+	lastCheckpointErr error
+
+	// clock 时间源，默认time.Now；回测时替换为虚拟时钟
+	clockMu sync.RWMutex
+	clock   func() time.Time
 }
 
 // GetActiveOrderCount 获取指定符号当前活跃订单数量
@@ -102,26 +131,133 @@ func (s *Store) SetActiveOrderCount(symbol string, count int) {
 	state.Mu.Unlock()
 }
 
-// NewStore 创建新的存储实例
-func NewStore(snapshotPath string, snapshotInterval time.Duration) *Store {
+// NewStore 创建新的存储实例。journalDir为空时仅保留内存状态；
+// 打开事件日志失败时记录错误并退化为内存状态
+func NewStore(journalDir string, checkpointInterval time.Duration) *Store {
+	s, err := OpenStore(journalDir, checkpointInterval, JournalOptions{})
+	if err != nil {
+		log.Error().Err(err).Str("dir", journalDir).Msg("打开事件日志失败，使用空的内存状态")
+		s, _ = OpenStore("", checkpointInterval, JournalOptions{})
+	}
+	return s
+}
+
+// OpenStore 创建存储实例，并由journalDir中的最近检查点与日志尾部确定性地重建状态。
+// 此后下单、撤单、成交、仓位与资金费事件均追加写入事件日志，并每隔checkpointInterval压缩一次检查点
+func OpenStore(journalDir string, checkpointInterval time.Duration, opts JournalOptions) (*Store, error) {
 	s := &Store{
 		symbols:        make(map[string]*SymbolState),
-		snapshotPath:   snapshotPath,
-		snapshotTicker: time.NewTicker(snapshotInterval),
-		stopSnapshot:   make(chan struct{}),
+		tradeWindow:    DefaultTradeWindow,
+		stopCheckpoint: make(chan struct{}),
+		checkpointDone: make(chan struct{}),
+		clock:          time.Now,
 	}
-
 	s.totalNotional.Store(float64(0))
 
-	// 启动快照协程
-	go s.runSnapshotLoop()
+	if journalDir != "" {
+		if err := s.recover(journalDir, opts); err != nil {
+			return nil, err
+		}
+	}
 
-	// 尝试从快照恢复
-	if err := s.LoadSnapshot(); err != nil {
-		log.Warn().Err(err).Msg("无法从快照恢复，使用空状态")
+	if checkpointInterval <= 0 {
+		checkpointInterval = time.Minute
 	}
+	s.checkpointTicker = time.NewTicker(checkpointInterval)
+	go s.runCheckpointLoop()
 
-	return s
+	return s, nil
+}
+
+// recover 加载检查点并重放日志尾部，然后打开日志写入端
+func (s *Store) recover(dir string, opts JournalOptions) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("创建日志目录失败: %w", err)
+	}
+
+	cp, err := loadCheckpoint(dir)
+	if err != nil {
+		return err
+	}
+	var seq uint64
+	if cp != nil {
+		seq = cp.Seq
+		for symbol, symCp := range cp.Symbols {
+			restoreCheckpoint(s.ensureSymbolLocked(symbol), symCp)
+		}
+	}
+
+	replayed := 0
+	lastSeq, err := scanJournal(dir, seq, true, func(e *JournalEntry) error {
+		applyEntry(s.ensureSymbolLocked(e.Symbol), e)
+		replayed++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	j, err := openJournalWriter(dir, lastSeq, opts)
+	if err != nil {
+		return err
+	}
+	s.journal = j
+	s.lastCheckpointSeq = seq
+	s.updateTotalNotionalLocked()
+
+	log.Info().
+		Str("dir", dir).
+		Uint64("checkpoint_seq", seq).
+		Int("replayed", replayed).
+		Uint64("last_seq", lastSeq).
+		Int("symbols", len(s.symbols)).
+		Msg("事件日志恢复完成")
+	return nil
+}
+
+// ensureSymbolLocked 获取或创建交易对状态（恢复阶段使用，价格历史在InitSymbol时分配）
+func (s *Store) ensureSymbolLocked(symbol string) *SymbolState {
+	state := s.symbols[symbol]
+	if state == nil {
+		state = &SymbolState{Symbol: symbol}
+		s.symbols[symbol] = state
+	}
+	return state
+}
+
+// commit 将事件应用到交易对状态并追加写入事件日志
+func (s *Store) commit(state *SymbolState, e *JournalEntry) {
+	s.commitMu.RLock()
+	defer s.commitMu.RUnlock()
+
+	e.Time = s.Now()
+
+	state.Mu.Lock()
+	applyEntry(state, e)
+	if s.journal != nil {
+		if err := s.journal.Append(e); err != nil {
+			log.Error().Err(err).Str("symbol", e.Symbol).Str("type", string(e.Type)).Msg("写入事件日志失败")
+		}
+	}
+	state.Mu.Unlock()
+}
+
+// SetClock 替换时间源（回测引擎注入虚拟时钟）
+func (s *Store) SetClock(clock func() time.Time) {
+	if clock == nil {
+		clock = time.Now
+	}
+	s.clockMu.Lock()
+	s.clock = clock
+	s.clockMu.Unlock()
+}
+
+// Now 返回当前时间源的时间
+func (s *Store) Now() time.Time {
+	s.clockMu.RLock()
+	clock := s.clock
+	s.clockMu.RUnlock()
+	return clock()
 }
 
 // InitSymbol 初始化交易对状态
@@ -129,7 +265,15 @@ func (s *Store) InitSymbol(symbol string, priceHistorySize int) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.symbols[symbol]; exists {
+	if state, exists := s.symbols[symbol]; exists {
+		// 从事件日志恢复的交易对尚未分配价格历史
+		if state.PriceHistorySize == 0 && priceHistorySize > 0 {
+			state.Mu.Lock()
+			state.PriceHistory = make([]float64, priceHistorySize)
+			state.PriceHistorySize = priceHistorySize
+			state.LastCancelReset = s.clock()
+			state.Mu.Unlock()
+		}
 		return
 	}
 
@@ -138,7 +282,7 @@ func (s *Store) InitSymbol(symbol string, priceHistorySize int) {
 		PriceHistory:     make([]float64, priceHistorySize),
 		PriceHistorySize: priceHistorySize,
 		FundingHistory:   make([]float64, 0, 24), // 24小时
-		LastCancelReset:  time.Now(),
+		LastCancelReset:  s.clock(),
 	}
 
 	log.Info().Str("symbol", symbol).Msg("交易对状态初始化完成")
@@ -162,9 +306,7 @@ func (s *Store) UpdatePosition(symbol string, pos Position) {
 		return
 	}
 
-	state.Mu.Lock()
-	state.Position = pos
-	state.Mu.Unlock()
+	s.commit(state, &JournalEntry{Type: EntryPosition, Symbol: symbol, Position: &pos})
 
 	// 更新全局名义价值
 	s.updateTotalNotional()
@@ -186,11 +328,14 @@ func (s *Store) UpdateMidPrice(symbol string, mid, bestBid, bestAsk float64) {
 	state.MidPrice = mid
 	state.BestBid = bestBid
 	state.BestAsk = bestAsk
-	state.LastPriceUpdate = time.Now()
+	state.LastPriceUpdate = s.Now()
+	state.Mark.update(&state.Ledger, mid)
 
 	// 添加到价格历史
-	state.PriceHistory[state.PriceHistoryIndex] = mid
-	state.PriceHistoryIndex = (state.PriceHistoryIndex + 1) % state.PriceHistorySize
+	if state.PriceHistorySize > 0 {
+		state.PriceHistory[state.PriceHistoryIndex] = mid
+		state.PriceHistoryIndex = (state.PriceHistoryIndex + 1) % state.PriceHistorySize
+	}
 }
 
 // UpdateFundingRate 更新资金费率
@@ -203,16 +348,37 @@ func (s *Store) UpdateFundingRate(symbol string, rate float64) {
 		return
 	}
 
+	s.commit(state, &JournalEntry{Type: EntryFunding, Symbol: symbol, Funding: &FundingEvent{Rate: rate}})
+}
+
+// SetSymbolFilters 更新交易对的交易所交易规则
+func (s *Store) SetSymbolFilters(symbol string, f filters.Symbol) {
+	s.mu.RLock()
+	state := s.symbols[symbol]
+	s.mu.RUnlock()
+
+	if state == nil {
+		return
+	}
+
 	state.Mu.Lock()
-	defer state.Mu.Unlock()
+	state.Filters = f
+	state.Mu.Unlock()
+}
 
-	state.FundingRate = rate
-	state.FundingHistory = append(state.FundingHistory, rate)
+// GetSymbolFilters 读取交易对的交易规则，未同步时返回零值（不做限制）
+func (s *Store) GetSymbolFilters(symbol string) filters.Symbol {
+	s.mu.RLock()
+	state := s.symbols[symbol]
+	s.mu.RUnlock()
 
-	// 保持最近24个资金费率
-	if len(state.FundingHistory) > 24 {
-		state.FundingHistory = state.FundingHistory[1:]
+	if state == nil {
+		return filters.Symbol{}
 	}
+
+	state.Mu.RLock()
+	defer state.Mu.RUnlock()
+	return state.Filters
 }
 
 // UpdatePendingOrders 更新挂单量
@@ -233,6 +399,11 @@ func (s *Store) UpdatePendingOrders(symbol string, buy, sell float64) {
 
 // RecordFill 记录成交
 func (s *Store) RecordFill(symbol string, size, pnl float64) {
+	s.RecordOrderFill(symbol, FillEvent{Quantity: size, PNL: pnl})
+}
+
+// RecordOrderFill 记录订单成交（含订单号、方向与价格，写入事件日志供审计）
+func (s *Store) RecordOrderFill(symbol string, fill FillEvent) {
 	s.mu.RLock()
 	state := s.symbols[symbol]
 	s.mu.RUnlock()
@@ -241,18 +412,46 @@ func (s *Store) RecordFill(symbol string, size, pnl float64) {
 		return
 	}
 
-	state.Mu.Lock()
-	defer state.Mu.Unlock()
+	s.commit(state, &JournalEntry{Type: EntryFill, Symbol: symbol, Fill: &fill})
+}
 
-	atomic.AddInt64(&state.FillCount, 1)
-	state.TotalVolume += math.Abs(size)
-	state.TotalPNL += pnl
-	state.LastFill = time.Now()
+// RecordOrderPlaced 记录下单成功
+func (s *Store) RecordOrderPlaced(symbol string, order OrderEvent) {
+	s.mu.RLock()
+	state := s.symbols[symbol]
+	s.mu.RUnlock()
 
-	// 更新最大回撤
-	if pnl < 0 && math.Abs(pnl) > state.MaxDrawdown {
-		state.MaxDrawdown = math.Abs(pnl)
+	if state == nil {
+		return
 	}
+
+	s.commit(state, &JournalEntry{Type: EntryOrderPlaced, Symbol: symbol, Order: &order})
+}
+
+// RecordOrderCanceled 记录撤单成功
+func (s *Store) RecordOrderCanceled(symbol, clientOrderID string) {
+	s.mu.RLock()
+	state := s.symbols[symbol]
+	s.mu.RUnlock()
+
+	if state == nil {
+		return
+	}
+
+	s.commit(state, &JournalEntry{Type: EntryOrderCanceled, Symbol: symbol, Order: &OrderEvent{ClientOrderID: clientOrderID}})
+}
+
+// RecordOrderAmended 记录改单成功（ClientOrderID不变，更新价格与数量）
+func (s *Store) RecordOrderAmended(symbol string, order OrderEvent) {
+	s.mu.RLock()
+	state := s.symbols[symbol]
+	s.mu.RUnlock()
+
+	if state == nil {
+		return
+	}
+
+	s.commit(state, &JournalEntry{Type: EntryOrderAmended, Symbol: symbol, Order: &order})
 }
 
 // IncrementCancelCount 增加撤单计数
@@ -269,15 +468,39 @@ func (s *Store) IncrementCancelCount(symbol string) int {
 	defer state.Mu.Unlock()
 
 	// 检查是否需要重置计数（每分钟）
-	if time.Since(state.LastCancelReset) > time.Minute {
+	now := s.Now()
+	if now.Sub(state.LastCancelReset) > time.Minute {
 		state.CancelCountLast = 0
-		state.LastCancelReset = time.Now()
+		state.LastCancelReset = now
 	}
 
 	state.CancelCountLast++
 	return state.CancelCountLast
 }
 
+// IncrementAmendCount 增加改单计数（每分钟重置，不计入撤单数）
+func (s *Store) IncrementAmendCount(symbol string) int {
+	s.mu.RLock()
+	state := s.symbols[symbol]
+	s.mu.RUnlock()
+
+	if state == nil {
+		return 0
+	}
+
+	state.Mu.Lock()
+	defer state.Mu.Unlock()
+
+	now := s.Now()
+	if now.Sub(state.LastAmendReset) > time.Minute {
+		state.AmendCountLast = 0
+		state.LastAmendReset = now
+	}
+
+	state.AmendCountLast++
+	return state.AmendCountLast
+}
+
 // GetWorstCaseLong 获取最坏情况多头敞口（仓位 + 挂买单 - 挂卖单）
 func (s *Store) GetWorstCaseLong(symbol string) float64 {
 	s.mu.RLock()
@@ -391,81 +614,71 @@ func (s *Store) updateTotalNotionalLocked() {
 	s.totalNotional.Store(total)
 }
 
-// SaveSnapshot 保存快照
-func (s *Store) SaveSnapshot() error {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	snapshot := make(map[string]*SymbolState)
-	for symbol, state := range s.symbols {
-		state.Mu.RLock()
-		// 复制状态
-		stateCopy := *state
-		state.Mu.RUnlock()
-		snapshot[symbol] = &stateCopy
+// Checkpoint 压缩检查点：将当前可持久化状态原子写入checkpoint.json并切换日志段。
+// 重启时只需重放检查点之后的日志
+func (s *Store) Checkpoint() error {
+	if s.journal == nil {
+		return nil
 	}
 
-	data, err := json.MarshalIndent(snapshot, "", "  ")
-	if err != nil {
+	s.commitMu.Lock()
+	seq, err := s.journal.rotate()
+	if err != nil || seq == s.lastCheckpointSeq {
+		s.commitMu.Unlock()
 		return err
 	}
-
-	if err := os.WriteFile(s.snapshotPath, data, 0644); err != nil {
-		return err
-	}
-
-	log.Debug().Str("path", s.snapshotPath).Msg("快照保存成功")
-	return nil
-}
-
-// LoadSnapshot 加载快照
-func (s *Store) LoadSnapshot() error {
-	data, err := os.ReadFile(s.snapshotPath)
-	if err != nil {
-		return err
+	cp := &Checkpoint{Seq: seq, Time: s.Now(), Symbols: make(map[string]*SymbolCheckpoint)}
+	s.mu.RLock()
+	for symbol, state := range s.symbols {
+		state.Mu.RLock()
+		cp.Symbols[symbol] = checkpointOf(state)
+		state.Mu.RUnlock()
 	}
+	s.mu.RUnlock()
+	s.commitMu.Unlock()
 
-	snapshot := make(map[string]*SymbolState)
-	if err := json.Unmarshal(data, &snapshot); err != nil {
+	if err := writeCheckpoint(s.journal.dir, cp); err != nil {
 		return err
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
+	s.commitMu.Lock()
+	s.lastCheckpointSeq = seq
+	s.commitMu.Unlock()
 
-	for symbol, state := range snapshot {
-		s.symbols[symbol] = state
-	}
-
-	s.updateTotalNotionalLocked()
-
-	log.Info().Str("path", s.snapshotPath).Int("symbols", len(snapshot)).Msg("快照加载成功")
+	log.Debug().Str("dir", s.journal.dir).Uint64("seq", seq).Msg("检查点保存成功")
 	return nil
 }
 
-// runSnapshotLoop 运行快照循环
-func (s *Store) runSnapshotLoop() {
+// runCheckpointLoop 运行检查点压缩循环
+func (s *Store) runCheckpointLoop() {
+	defer close(s.checkpointDone)
 	for {
 		select {
-		case <-s.snapshotTicker.C:
-			if err := s.SaveSnapshot(); err != nil {
-				s.lastSnapshotErr = err
-				log.Error().Err(err).Msg("保存快照失败")
+		case <-s.checkpointTicker.C:
+			if err := s.Checkpoint(); err != nil {
+				s.lastCheckpointErr = err
+				log.Error().Err(err).Msg("保存检查点失败")
 			}
-		case <-s.stopSnapshot:
+		case <-s.stopCheckpoint:
 			return
 		}
 	}
 }
 
-// Close 关闭存储
+// Close 关闭存储：保存最后一次检查点并关闭事件日志
 func (s *Store) Close() {
-	close(s.stopSnapshot)
-	s.snapshotTicker.Stop()
+	close(s.stopCheckpoint)
+	s.checkpointTicker.Stop()
+	<-s.checkpointDone
 
-	// 最后保存一次
-	if err := s.SaveSnapshot(); err != nil {
-		log.Error().Err(err).Msg("关闭时保存快照失败")
+	if s.journal == nil {
+		return
+	}
+	if err := s.Checkpoint(); err != nil {
+		log.Error().Err(err).Msg("关闭时保存检查点失败")
+	}
+	if err := s.journal.Close(); err != nil {
+		log.Error().Err(err).Msg("关闭事件日志失败")
 	}
 }
 
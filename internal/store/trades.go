@@ -0,0 +1,163 @@
+package store
+
+import "time"
+
+const (
+	// DefaultTradeWindow 逐笔成交默认保留时长
+	DefaultTradeWindow = 5 * time.Minute
+	// defaultTradeCapacity 逐笔成交环形缓冲初始容量
+	defaultTradeCapacity = 1024
+	// maxTradeCapacity 逐笔成交环形缓冲容量上限，超过后覆盖最旧记录
+	maxTradeCapacity = 1 << 16
+)
+
+// TapeTrade 逐笔成交记录
+type TapeTrade struct {
+	Price    float64
+	Quantity float64
+	IsBuy    bool // true: 主动买入
+	Time     time.Time
+}
+
+// TradeTape 按时间窗口保留的逐笔成交环形缓冲
+type TradeTape struct {
+	buf  []TapeTrade
+	head int // 最旧记录下标
+	size int
+}
+
+// push 追加一笔成交并淘汰早于cutoff的记录，缓冲满时扩容（达到上限后覆盖最旧记录）
+func (t *TradeTape) push(tr TapeTrade, cutoff time.Time) {
+	t.evict(cutoff)
+	if t.size == len(t.buf) {
+		if len(t.buf) < maxTradeCapacity {
+			t.grow()
+		} else {
+			t.head = (t.head + 1) % len(t.buf)
+			t.size--
+		}
+	}
+	t.buf[(t.head+t.size)%len(t.buf)] = tr
+	t.size++
+}
+
+func (t *TradeTape) grow() {
+	capacity := len(t.buf) * 2
+	if capacity == 0 {
+		capacity = defaultTradeCapacity
+	}
+	buf := make([]TapeTrade, capacity)
+	for i := 0; i < t.size; i++ {
+		buf[i] = t.buf[(t.head+i)%len(t.buf)]
+	}
+	t.buf, t.head = buf, 0
+}
+
+func (t *TradeTape) evict(cutoff time.Time) {
+	for t.size > 0 && t.buf[t.head].Time.Before(cutoff) {
+		t.head = (t.head + 1) % len(t.buf)
+		t.size--
+	}
+}
+
+// each 从最新到最旧遍历不早于since的成交
+func (t *TradeTape) each(since time.Time, fn func(TapeTrade)) {
+	for i := t.size - 1; i >= 0; i-- {
+		tr := t.buf[(t.head+i)%len(t.buf)]
+		if tr.Time.Before(since) {
+			return
+		}
+		fn(tr)
+	}
+}
+
+// Len 缓冲中的成交笔数
+func (t *TradeTape) Len() int {
+	return t.size
+}
+
+// SetTradeWindow 设置逐笔成交保留时长（默认5分钟）
+func (s *Store) SetTradeWindow(window time.Duration) {
+	if window <= 0 {
+		window = DefaultTradeWindow
+	}
+	s.mu.Lock()
+	s.tradeWindow = window
+	s.mu.Unlock()
+}
+
+// RecordTrade 记录一笔市场逐笔成交，ts为零值时使用当前时间源
+func (s *Store) RecordTrade(symbol string, price, qty float64, isBuy bool, ts time.Time) {
+	s.mu.RLock()
+	state := s.symbols[symbol]
+	window := s.tradeWindow
+	s.mu.RUnlock()
+
+	if state == nil || price <= 0 || qty <= 0 {
+		return
+	}
+	if ts.IsZero() {
+		ts = s.Now()
+	}
+
+	state.Mu.Lock()
+	state.Trades.push(TapeTrade{Price: price, Quantity: qty, IsBuy: isBuy, Time: ts}, ts.Add(-window))
+	state.Mu.Unlock()
+}
+
+// TradeVolume 返回最近window内的主动买入量与主动卖出量
+func (s *Store) TradeVolume(symbol string, window time.Duration) (buyVol, sellVol float64) {
+	s.eachTrade(symbol, window, func(tr TapeTrade) {
+		if tr.IsBuy {
+			buyVol += tr.Quantity
+		} else {
+			sellVol += tr.Quantity
+		}
+	})
+	return buyVol, sellVol
+}
+
+// TradeVWAP 返回最近window内的成交量加权均价，无成交时返回0
+func (s *Store) TradeVWAP(symbol string, window time.Duration) float64 {
+	var notional, volume float64
+	s.eachTrade(symbol, window, func(tr TapeTrade) {
+		notional += tr.Price * tr.Quantity
+		volume += tr.Quantity
+	})
+	if volume <= 0 {
+		return 0
+	}
+	return notional / volume
+}
+
+// TradeIntensity 返回最近window内的成交强度（笔/秒）
+func (s *Store) TradeIntensity(symbol string, window time.Duration) float64 {
+	if window <= 0 {
+		return 0
+	}
+	count := 0
+	s.eachTrade(symbol, window, func(TapeTrade) { count++ })
+	return float64(count) / window.Seconds()
+}
+
+// RecentTrades 返回最近window内的成交（从旧到新）
+func (s *Store) RecentTrades(symbol string, window time.Duration) []TapeTrade {
+	var trades []TapeTrade
+	s.eachTrade(symbol, window, func(tr TapeTrade) { trades = append(trades, tr) })
+	for i, j := 0, len(trades)-1; i < j; i, j = i+1, j-1 {
+		trades[i], trades[j] = trades[j], trades[i]
+	}
+	return trades
+}
+
+func (s *Store) eachTrade(symbol string, window time.Duration, fn func(TapeTrade)) {
+	state := s.GetSymbolState(symbol)
+	if state == nil {
+		return
+	}
+	since := s.Now().Add(-window)
+
+	state.Mu.RLock()
+	defer state.Mu.RUnlock()
+	state.Trades.each(since, fn)
+}
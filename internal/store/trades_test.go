@@ -0,0 +1,72 @@
+package store
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestStore_TradeTape(t *testing.T) {
+	st := NewStore("", time.Hour)
+	defer st.Close()
+	st.InitSymbol("ETHUSDC", 100)
+	st.SetTradeWindow(time.Minute)
+
+	now := time.Unix(1700000000, 0)
+	st.SetClock(func() time.Time { return now })
+
+	// 90秒前的成交在写入新成交时被淘汰
+	st.RecordTrade("ETHUSDC", 2990, 10, true, now.Add(-90*time.Second))
+	st.RecordTrade("ETHUSDC", 3000, 1, true, now.Add(-20*time.Second))
+	st.RecordTrade("ETHUSDC", 3010, 3, false, now.Add(-5*time.Second))
+	st.RecordTrade("ETHUSDC", 3020, 1, true, time.Time{}) // 零值时间使用时钟
+
+	if n := st.GetSymbolState("ETHUSDC").Trades.Len(); n != 3 {
+		t.Fatalf("expected stale trade evicted, got %d trades", n)
+	}
+
+	buy, sell := st.TradeVolume("ETHUSDC", time.Minute)
+	if buy != 2 || sell != 3 {
+		t.Errorf("expected buy=2 sell=3, got buy=%f sell=%f", buy, sell)
+	}
+	buy, sell = st.TradeVolume("ETHUSDC", 10*time.Second)
+	if buy != 1 || sell != 3 {
+		t.Errorf("expected buy=1 sell=3 in 10s window, got buy=%f sell=%f", buy, sell)
+	}
+
+	wantVWAP := (3000*1 + 3010*3 + 3020*1) / 5.0
+	if vwap := st.TradeVWAP("ETHUSDC", time.Minute); math.Abs(vwap-wantVWAP) > 1e-9 {
+		t.Errorf("expected vwap %f, got %f", wantVWAP, vwap)
+	}
+	if got := st.TradeIntensity("ETHUSDC", 10*time.Second); got != 0.2 {
+		t.Errorf("expected intensity 0.2/s, got %f", got)
+	}
+
+	recent := st.RecentTrades("ETHUSDC", time.Minute)
+	if len(recent) != 3 || recent[0].Price != 3000 || recent[2].Price != 3020 {
+		t.Errorf("expected oldest-first trades, got %+v", recent)
+	}
+
+	if st.TradeVWAP("BTCUSDC", time.Minute) != 0 || st.TradeIntensity("BTCUSDC", time.Minute) != 0 {
+		t.Error("unknown symbol should report no trades")
+	}
+}
+
+func TestStore_TradeTapeGrows(t *testing.T) {
+	st := NewStore("", time.Hour)
+	defer st.Close()
+	st.InitSymbol("ETHUSDC", 100)
+
+	now := time.Unix(1700000000, 0)
+	st.SetClock(func() time.Time { return now })
+
+	// 超过初始容量时扩容并保持时间顺序
+	n := defaultTradeCapacity + 10
+	for i := 0; i < n; i++ {
+		st.RecordTrade("ETHUSDC", 3000+float64(i), 1, i%2 == 0, now.Add(time.Duration(i-n)*time.Millisecond))
+	}
+	recent := st.RecentTrades("ETHUSDC", time.Minute)
+	if len(recent) != n || recent[0].Price != 3000 || recent[n-1].Price != 3000+float64(n-1) {
+		t.Fatalf("expected %d ordered trades, got %d", n, len(recent))
+	}
+}
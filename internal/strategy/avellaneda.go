@@ -0,0 +1,302 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// asVarAlpha 中间价方差EWMA平滑系数
+	asVarAlpha = 0.05
+	// asDistAlpha 成交距离EWMA平滑系数
+	asDistAlpha = 0.02
+	// asMinDistSamples 在线估计κ所需的最少成交样本数
+	asMinDistSamples = 20
+	// asFuseRatio 持仓超过该比例NetMax时停止报价（与ASMM一致的最后防线）
+	asFuseRatio = 0.80
+)
+
+// asState 单个交易对的在线估计状态
+type asState struct {
+	lastMid     float64
+	lastTime    time.Time
+	variance    float64 // 中间价每秒变动方差σ²（EWMA）
+	varSamples  int
+	distance    float64 // 成交价距中间价的平均距离（EWMA），κ≈1/distance
+	distSamples int
+	windowStart time.Time // 有限时间窗口的起点
+}
+
+// AvellanedaStoikov 最优做市报价策略（Avellaneda & Stoikov, 2008）
+//
+//	reservation = s - q·γ·σ²·τ
+//	spread      = γ·σ²·τ + (2/γ)·ln(1 + γ/κ)
+//
+// 其中σ²由中间价变动在线估计，κ由市场逐笔成交与自身成交距中间价的距离在线估计
+// （成交距离服从指数分布时κ的极大似然估计为平均距离的倒数），τ为时间窗口。
+type AvellanedaStoikov struct {
+	cfg   *config.Config
+	store *store.Store
+
+	mu     sync.Mutex
+	states map[string]*asState
+}
+
+// NewAvellanedaStoikov 创建Avellaneda-Stoikov策略实例
+func NewAvellanedaStoikov(cfg *config.Config, st *store.Store) *AvellanedaStoikov {
+	return &AvellanedaStoikov{
+		cfg:    cfg,
+		store:  st,
+		states: make(map[string]*asState),
+	}
+}
+
+// GenerateQuotes 生成报价
+func (a *AvellanedaStoikov) GenerateQuotes(ctx context.Context, symbol string) ([]Quote, []Quote, error) {
+	symCfg := a.cfg.GetSymbolConfig(symbol)
+	if symCfg == nil {
+		return nil, nil, ErrSymbolNotConfigured
+	}
+
+	state := a.store.GetSymbolState(symbol)
+	if state == nil {
+		return nil, nil, ErrSymbolNotInitialized
+	}
+
+	state.Mu.RLock()
+	mid := state.MidPrice
+	pos := state.Position.Size
+	bestBid := state.BestBid
+	bestAsk := state.BestAsk
+	state.Mu.RUnlock()
+
+	if mid <= 0 {
+		return nil, nil, ErrInvalidMidPrice
+	}
+
+	posRatio := math.Abs(pos) / symCfg.NetMax
+	if posRatio > asFuseRatio {
+		log.Error().
+			Str("symbol", symbol).
+			Float64("pos", pos).
+			Float64("net_max", symCfg.NetMax).
+			Float64("pos_ratio", posRatio*100).
+			Msg("【紧急熔断】持仓超过80% netMax，停止报价以防止持仓继续扩大")
+		return nil, nil, fmt.Errorf("紧急熔断: 持仓使用率%.1f%%超过80%%阈值，停止报价", posRatio*100)
+	}
+
+	sigma2, kappa, tau := a.observe(symbol, mid, a.store.Now(), symCfg)
+	gamma := symCfg.ASGamma
+
+	reservation := mid - pos*gamma*sigma2*tau
+	spread := gamma*sigma2*tau + 2/gamma*math.Log(1+gamma/kappa)
+	if minSpread := symCfg.MinSpread * mid; spread < minSpread {
+		spread = minSpread
+	}
+	half := spread / 2
+
+	bid := floorToTick(reservation-half, symCfg.TickSize)
+	ask := ceilToTick(reservation+half, symCfg.TickSize)
+
+	// Post-Only：不穿越对手盘口
+	if bestAsk > 0 && bid >= bestAsk {
+		bid = floorToTick(bestAsk-symCfg.TickSize, symCfg.TickSize)
+	}
+	if bestBid > 0 && ask <= bestBid {
+		ask = ceilToTick(bestBid+symCfg.TickSize, symCfg.TickSize)
+	}
+
+	buyQuotes, sellQuotes := a.buildLayers(bid, ask, half, symCfg)
+
+	log.Debug().
+		Str("symbol", symbol).
+		Float64("mid", mid).
+		Float64("pos", pos).
+		Float64("sigma2", sigma2).
+		Float64("kappa", kappa).
+		Float64("tau", tau).
+		Float64("reservation", reservation).
+		Float64("spread", spread).
+		Float64("bid", bid).
+		Float64("ask", ask).
+		Msg("Avellaneda-Stoikov报价")
+
+	return buyQuotes, sellQuotes, nil
+}
+
+// observe 用最新中间价更新σ²估计，返回当前σ²、κ和τ
+func (a *AvellanedaStoikov) observe(symbol string, mid float64, now time.Time, cfg *config.SymbolConfig) (sigma2, kappa, tau float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	st := a.stateLocked(symbol)
+	if st.lastMid > 0 {
+		if dt := now.Sub(st.lastTime).Seconds(); dt > 0 {
+			d := mid - st.lastMid
+			sample := d * d / dt
+			if st.varSamples == 0 {
+				st.variance = sample
+			} else {
+				st.variance = asVarAlpha*sample + (1-asVarAlpha)*st.variance
+			}
+			st.varSamples++
+			st.lastMid, st.lastTime = mid, now
+		}
+	} else {
+		st.lastMid, st.lastTime = mid, now
+	}
+
+	kappa = cfg.ASKappa
+	if st.distSamples >= asMinDistSamples && st.distance > 0 {
+		kappa = 1 / st.distance
+	}
+
+	tau = cfg.ASHorizonSec
+	if cfg.ASFiniteHorizon {
+		horizon := time.Duration(cfg.ASHorizonSec * float64(time.Second))
+		if st.windowStart.IsZero() || now.Before(st.windowStart) {
+			st.windowStart = now
+		}
+		for horizon > 0 && now.Sub(st.windowStart) >= horizon {
+			st.windowStart = st.windowStart.Add(horizon)
+		}
+		tau = cfg.ASHorizonSec - now.Sub(st.windowStart).Seconds()
+	}
+	return st.variance, kappa, tau
+}
+
+// buildLayers 从最优买卖价向外铺设多层报价。
+// 配置了几何网格参数时层间距沿用网格间距，否则使用半价差
+func (a *AvellanedaStoikov) buildLayers(bid, ask, half float64, cfg *config.SymbolConfig) ([]Quote, []Quote) {
+	orderSize := cfg.UnifiedLayerSize
+	if orderSize <= 0 {
+		orderSize = cfg.BaseLayerSize
+	}
+	if orderSize < cfg.MinQty {
+		orderSize = cfg.MinQty
+	}
+
+	layers := cfg.TotalLayers
+	if layers <= 0 {
+		layers = 1
+	}
+
+	buyQuotes := make([]Quote, 0, layers)
+	sellQuotes := make([]Quote, 0, layers)
+	offset := 0.0
+	for i := 0; i < layers; i++ {
+		if i > 0 {
+			offset += layerSpacing(cfg, i-1, half)
+		}
+		buyQuotes = append(buyQuotes, Quote{
+			Price: floorToTick(bid-offset, cfg.TickSize),
+			Size:  orderSize,
+			Layer: i + 1,
+		})
+		sellQuotes = append(sellQuotes, Quote{
+			Price: ceilToTick(ask+offset, cfg.TickSize),
+			Size:  orderSize,
+			Layer: i + 1,
+		})
+	}
+	return buyQuotes, sellQuotes
+}
+
+// layerSpacing 第j个层间距
+func layerSpacing(cfg *config.SymbolConfig, j int, half float64) float64 {
+	if cfg.GridFirstSpacing <= 0 || cfg.GridSpacingMultiplier <= 1.0 {
+		return half
+	}
+	spacing := cfg.GridFirstSpacing * math.Pow(cfg.GridSpacingMultiplier, float64(j))
+	if cfg.GridMaxSpacing > 0 && spacing > cfg.GridMaxSpacing {
+		spacing = cfg.GridMaxSpacing
+	}
+	return spacing
+}
+
+// OnTrade 市场逐笔成交：以成交价距中间价的距离更新κ估计
+func (a *AvellanedaStoikov) OnTrade(symbol string, price, qty float64) {
+	a.observeDistance(symbol, price)
+}
+
+// OnFill 自身成交：同样计入κ估计
+func (a *AvellanedaStoikov) OnFill(symbol, side string, price, qty float64) {
+	a.observeDistance(symbol, price)
+}
+
+func (a *AvellanedaStoikov) observeDistance(symbol string, price float64) {
+	state := a.store.GetSymbolState(symbol)
+	if state == nil || price <= 0 {
+		return
+	}
+	state.Mu.RLock()
+	mid := state.MidPrice
+	state.Mu.RUnlock()
+	if mid <= 0 {
+		return
+	}
+	dist := math.Abs(price - mid)
+	if dist <= 0 {
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st := a.stateLocked(symbol)
+	if st.distSamples == 0 {
+		st.distance = dist
+	} else {
+		st.distance = asDistAlpha*dist + (1-asDistAlpha)*st.distance
+	}
+	st.distSamples++
+}
+
+// Kappa 返回当前使用的κ（在线估计样本不足时为配置值）
+func (a *AvellanedaStoikov) Kappa(symbol string) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if st := a.states[symbol]; st != nil && st.distSamples >= asMinDistSamples && st.distance > 0 {
+		return 1 / st.distance
+	}
+	if symCfg := a.cfg.GetSymbolConfig(symbol); symCfg != nil {
+		return symCfg.ASKappa
+	}
+	return 0
+}
+
+func (a *AvellanedaStoikov) stateLocked(symbol string) *asState {
+	st := a.states[symbol]
+	if st == nil {
+		st = &asState{}
+		a.states[symbol] = st
+	}
+	return st
+}
+
+// UpdateMetrics 更新指标
+func (a *AvellanedaStoikov) UpdateMetrics() {
+	// 由metrics模块处理
+}
+
+// floorToTick 买价向下对齐tickSize
+func floorToTick(price, tickSize float64) float64 {
+	if tickSize <= 0 {
+		return price
+	}
+	return math.Floor(price/tickSize+1e-9) * tickSize
+}
+
+// ceilToTick 卖价向上对齐tickSize
+func ceilToTick(price, tickSize float64) float64 {
+	if tickSize <= 0 {
+		return price
+	}
+	return math.Ceil(price/tickSize-1e-9) * tickSize
+}
@@ -0,0 +1,120 @@
+package strategy
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+)
+
+func asConfig() *config.Config {
+	return &config.Config{
+		Symbols: []config.SymbolConfig{
+			{
+				Symbol:           "ETHUSDC",
+				NetMax:           1.0,
+				MinSpread:        0.0001,
+				TickSize:         0.01,
+				MinQty:           0.001,
+				TotalLayers:      3,
+				UnifiedLayerSize: 0.01,
+				Strategy:         config.StrategyAvellanedaStoikov,
+				ASGamma:          0.1,
+				ASKappa:          0.5,
+				ASHorizonSec:     60,
+			},
+			{
+				Symbol:           "BTCUSDC",
+				NetMax:           1.0,
+				MinSpread:        0.0001,
+				TickSize:         0.1,
+				MinQty:           0.001,
+				TotalLayers:      2,
+				UnifiedLayerSize: 0.01,
+			},
+		},
+	}
+}
+
+func setMarket(st *store.Store, symbol string, mid, pos float64) {
+	state := st.GetSymbolState(symbol)
+	state.Mu.Lock()
+	state.MidPrice = mid
+	state.BestBid = mid - 0.05
+	state.BestAsk = mid + 0.05
+	state.Position.Size = pos
+	state.Mu.Unlock()
+}
+
+func TestAvellanedaStoikov_SpreadAndSkew(t *testing.T) {
+	cfg := asConfig()
+	st := store.NewStore("", time.Hour)
+	st.InitSymbol("ETHUSDC", 100)
+	now := time.Unix(1700000000, 0)
+	st.SetClock(func() time.Time { return now })
+
+	as := NewAvellanedaStoikov(cfg, st)
+	ctx := context.Background()
+
+	// 无波动、无库存：对称报价，半价差=(1/γ)ln(1+γ/κ)
+	setMarket(st, "ETHUSDC", 3000, 0)
+	buys, sells, err := as.GenerateQuotes(ctx, "ETHUSDC")
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	half := math.Log(1+0.1/0.5) / 0.1
+	if math.Abs((3000-buys[0].Price)-half) > 0.011 || math.Abs((sells[0].Price-3000)-half) > 0.011 {
+		t.Fatalf("expected half spread %.4f, got bid %.2f ask %.2f", half, buys[0].Price, sells[0].Price)
+	}
+	if len(buys) != 3 || buys[1].Price >= buys[0].Price || sells[2].Price <= sells[1].Price {
+		t.Fatalf("unexpected layers: %+v / %+v", buys, sells)
+	}
+
+	// 价格波动后σ²>0：多头库存使保留价下移
+	now = now.Add(time.Second)
+	setMarket(st, "ETHUSDC", 3001, 0.5)
+	buysLong, sellsLong, _ := as.GenerateQuotes(ctx, "ETHUSDC")
+	now = now.Add(time.Second)
+	setMarket(st, "ETHUSDC", 3001, -0.5)
+	buysShort, sellsShort, _ := as.GenerateQuotes(ctx, "ETHUSDC")
+	midLong := (buysLong[0].Price + sellsLong[0].Price) / 2
+	midShort := (buysShort[0].Price + sellsShort[0].Price) / 2
+	if midLong >= midShort {
+		t.Fatalf("long inventory should lower reservation: long %.2f short %.2f", midLong, midShort)
+	}
+}
+
+func TestAvellanedaStoikov_KappaEstimate(t *testing.T) {
+	cfg := asConfig()
+	st := store.NewStore("", time.Hour)
+	st.InitSymbol("ETHUSDC", 100)
+	setMarket(st, "ETHUSDC", 3000, 0)
+
+	as := NewAvellanedaStoikov(cfg, st)
+	for i := 0; i < asMinDistSamples-1; i++ {
+		as.OnTrade("ETHUSDC", 3000.5, 1)
+	}
+	if k := as.Kappa("ETHUSDC"); k != 0.5 {
+		t.Fatalf("expected configured kappa before enough samples, got %f", k)
+	}
+	as.OnFill("ETHUSDC", "BUY", 2999.5, 1)
+	if k := as.Kappa("ETHUSDC"); math.Abs(k-2) > 1e-9 {
+		t.Fatalf("expected kappa 1/0.5=2, got %f", k)
+	}
+}
+
+func TestRouterSelectsPerSymbol(t *testing.T) {
+	cfg := asConfig()
+	st := store.NewStore("", time.Hour)
+	r := New(cfg, st)
+
+	if _, ok := r.For("ETHUSDC").(*AvellanedaStoikov); !ok {
+		t.Fatal("ETHUSDC should use Avellaneda-Stoikov")
+	}
+	if _, ok := r.For("BTCUSDC").(*ASMM); !ok {
+		t.Fatal("BTCUSDC should default to ASMM")
+	}
+}
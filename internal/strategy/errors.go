@@ -14,4 +14,7 @@ var (
 
 	// ErrQuoteFlicker 报价闪烁（撤单频率过高）
 	ErrQuoteFlicker = errors.New("quote flicker detected: cancel rate too high")
+
+	// ErrHighToxicity 订单流毒性过高（VPIN），暂停报价
+	ErrHighToxicity = errors.New("order flow toxicity too high: quoting paused")
 )
@@ -0,0 +1,70 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+)
+
+// MarketObserver 需要观察市场成交与自身成交的策略实现此接口（可选）
+type MarketObserver interface {
+	// OnTrade 市场逐笔成交
+	OnTrade(symbol string, price, qty float64)
+	// OnFill 自身订单成交
+	OnFill(symbol, side string, price, qty float64)
+}
+
+// Router 按交易对配置的 strategy 字段分派到对应策略实现，
+// 便于在不同交易对上对比 ASMM 与 Avellaneda-Stoikov
+type Router struct {
+	cfg  *config.Config
+	asmm *ASMM
+	as   *AvellanedaStoikov
+}
+
+// New 根据配置创建策略（按交易对分派）
+func New(cfg *config.Config, st *store.Store) *Router {
+	asmm := NewASMM(cfg, st)
+	if cfg.Global.VPIN.Enabled {
+		asmm.SetVPINCalculator(NewVPINCalculator(cfg))
+	}
+	return &Router{
+		cfg:  cfg,
+		asmm: asmm,
+		as:   NewAvellanedaStoikov(cfg, st),
+	}
+}
+
+// For 返回交易对当前使用的策略
+func (r *Router) For(symbol string) Strategy {
+	if symCfg := r.cfg.GetSymbolConfig(symbol); symCfg != nil && symCfg.Strategy == config.StrategyAvellanedaStoikov {
+		return r.as
+	}
+	return r.asmm
+}
+
+// GenerateQuotes 生成报价
+func (r *Router) GenerateQuotes(ctx context.Context, symbol string) ([]Quote, []Quote, error) {
+	return r.For(symbol).GenerateQuotes(ctx, symbol)
+}
+
+// UpdateMetrics 更新指标
+func (r *Router) UpdateMetrics() {
+	r.asmm.UpdateMetrics()
+	r.as.UpdateMetrics()
+}
+
+// OnTrade 转发市场成交给需要观察成交的策略
+func (r *Router) OnTrade(symbol string, price, qty float64) {
+	if obs, ok := r.For(symbol).(MarketObserver); ok {
+		obs.OnTrade(symbol, price, qty)
+	}
+}
+
+// OnFill 转发自身成交给需要观察成交的策略
+func (r *Router) OnFill(symbol, side string, price, qty float64) {
+	if obs, ok := r.For(symbol).(MarketObserver); ok {
+		obs.OnFill(symbol, side, price, qty)
+	}
+}
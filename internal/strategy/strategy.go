@@ -34,6 +34,9 @@ type ASMM struct {
 	// 状态记忆，用于减少抖动
 	mu                  sync.RWMutex
 	lastInventoryRatios map[string]float64
+
+	// VPIN订单流毒性（可选，为nil时不启用）
+	vpin *VPINCalculator
 }
 
 // NewASMM 创建ASMM策略实例
@@ -45,6 +48,21 @@ func NewASMM(cfg *config.Config, st *store.Store) *ASMM {
 	}
 }
 
+// SetVPINCalculator 启用VPIN订单流毒性调整
+func (a *ASMM) SetVPINCalculator(v *VPINCalculator) {
+	a.vpin = v
+}
+
+// OnTrade 市场逐笔成交：填充VPIN成交量桶
+func (a *ASMM) OnTrade(symbol string, price, qty float64) {
+	if a.vpin != nil {
+		a.vpin.UpdateTrade(symbol, price, qty)
+	}
+}
+
+// OnFill 自身成交（ASMM不使用）
+func (a *ASMM) OnFill(symbol, side string, price, qty float64) {}
+
 // GenerateQuotes 生成报价
 func (a *ASMM) GenerateQuotes(ctx context.Context, symbol string) ([]Quote, []Quote, error) {
 	symCfg := a.cfg.GetSymbolConfig(symbol)
@@ -91,6 +109,16 @@ func (a *ASMM) GenerateQuotes(ctx context.Context, symbol string) ([]Quote, []Qu
 			Msg("【风控警告】持仓已超过50% netMax，需要注意风险")
 	}
 
+	// VPIN订单流毒性：过高时暂停报价，偏高时放大价差
+	spreadFactor := 1.0
+	if a.vpin != nil {
+		factor, err := a.vpin.Assess(symbol, a.store.Now())
+		if err != nil {
+			return nil, nil, err
+		}
+		spreadFactor = factor
+	}
+
 	// 计算库存偏移
 	inventorySkew := a.calculateInventorySkew(symbol, pos, symCfg.NetMax, mid, symCfg)
 
@@ -135,6 +163,13 @@ func (a *ASMM) GenerateQuotes(ctx context.Context, symbol string) ([]Quote, []Qu
 		// 正常模式：生成多层报价
 		mode = "normal"
 		buyQuotes, sellQuotes = a.generateNormalQuotes(reservation, spread, symCfg)
+		if spreadFactor > 1 {
+			buyQuotes, sellQuotes = widenQuotes(buyQuotes, sellQuotes, reservation, spreadFactor, symCfg.TickSize)
+			log.Info().
+				Str("symbol", symbol).
+				Float64("spread_factor", spreadFactor).
+				Msg("【VPIN】订单流毒性偏高，放大报价价差")
+		}
 	}
 
 	// 记录模式切换（仅在模式变化时记录）
@@ -143,6 +178,17 @@ func (a *ASMM) GenerateQuotes(ctx context.Context, symbol string) ([]Quote, []Qu
 	return buyQuotes, sellQuotes, nil
 }
 
+// widenQuotes 按系数放大各层报价与reservation的距离（网格与旧分层算法通用）
+func widenQuotes(buyQuotes, sellQuotes []Quote, reservation, factor, tickSize float64) ([]Quote, []Quote) {
+	for i := range buyQuotes {
+		buyQuotes[i].Price = floorToTick(reservation-(reservation-buyQuotes[i].Price)*factor, tickSize)
+	}
+	for i := range sellQuotes {
+		sellQuotes[i].Price = ceilToTick(reservation+(sellQuotes[i].Price-reservation)*factor, tickSize)
+	}
+	return buyQuotes, sellQuotes
+}
+
 // calculateInventorySkew 计算库存偏移
 func (a *ASMM) calculateInventorySkew(symbol string, pos, netMax, mid float64, cfg *config.SymbolConfig) float64 {
 	// 库存比例 [-1, 1]
@@ -0,0 +1,210 @@
+package strategy
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	"github.com/newplayman/market-maker-phoenix/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// vpinDeltaAlpha 桶间价格变动方差EWMA平滑系数
+	vpinDeltaAlpha = 0.05
+	// vpinVolumeEpsilon 成交量比较容差
+	vpinVolumeEpsilon = 1e-12
+)
+
+// vpinState 单个交易对的成交量桶状态
+type vpinState struct {
+	lastClose float64 // 上一个完整桶的收盘价
+	deltaVar  float64 // 桶间价格变动方差σ²(ΔP)（EWMA）
+	deltaN    int     // 已估计方差的桶数
+
+	curOpen   float64 // 当前未满桶的第一笔成交价
+	curVolume float64 // 当前未满桶的成交量
+
+	imbalances []float64 // 已完成桶的|Vb-Vs|，环形缓冲
+	next       int
+	count      int
+
+	pausedUntil time.Time
+}
+
+// VPINCalculator 成交量同步知情交易概率（Easley, López de Prado & O'Hara, 2012）
+//
+//	VPIN = Σ|Vb - Vs| / (n·V)
+//
+// 逐笔成交按固定成交量V切分为桶，桶满时用批量成交量分类（BVC）拆分整桶的买卖量：
+// Vb = V·Φ(ΔP/σΔP)，Vs = V - Vb，其中ΔP为相邻两桶收盘价之差（首个桶取桶内首笔成交价），
+// σΔP由EWMA在线估计（预热期按等权样本方差）。逐笔应用BVC时相邻成交大多同价（ΔP=0，各按50%拆分），会低估单边行情的毒性。
+// 取最近n个完整桶计算VPIN，数值越高表示知情交易（逆向选择）风险越大。
+type VPINCalculator struct {
+	cfg *config.Config
+
+	mu     sync.Mutex
+	states map[string]*vpinState
+}
+
+// NewVPINCalculator 创建VPIN计算器
+func NewVPINCalculator(cfg *config.Config) *VPINCalculator {
+	return &VPINCalculator{
+		cfg:    cfg,
+		states: make(map[string]*vpinState),
+	}
+}
+
+// UpdateTrade 接收一笔市场成交，填充成交量桶
+func (v *VPINCalculator) UpdateTrade(symbol string, price, qty float64) {
+	bucket := v.bucketVolume(symbol)
+	if bucket <= 0 || price <= 0 || qty <= 0 {
+		return
+	}
+
+	v.mu.Lock()
+	st := v.stateLocked(symbol)
+
+	// 单笔成交量超过桶剩余容量时拆分到后续桶
+	closed := false
+	remaining := qty
+	for remaining > vpinVolumeEpsilon {
+		if st.curVolume == 0 {
+			st.curOpen = price
+		}
+		take := math.Min(remaining, bucket-st.curVolume)
+		st.curVolume += take
+		remaining -= take
+		if st.curVolume >= bucket-vpinVolumeEpsilon {
+			v.closeBucketLocked(st, price)
+			closed = true
+		}
+	}
+
+	vpin, ready := v.vpinLocked(st, bucket)
+	v.mu.Unlock()
+
+	if closed && ready {
+		metrics.UpdateVPIN(symbol, vpin)
+	}
+}
+
+// closeBucketLocked 当前桶已满，按桶间价格变动拆分买卖量，记录不平衡量并开启新桶
+func (v *VPINCalculator) closeBucketLocked(st *vpinState, closePrice float64) {
+	ref := st.lastClose
+	if ref <= 0 {
+		ref = st.curOpen
+	}
+	d := closePrice - ref
+	// 前1/α个桶按等权样本方差估计，之后转为EWMA；从0起步的EWMA在预热期低估σ，使每个桶都被判为单边
+	st.deltaN++
+	alpha := math.Max(vpinDeltaAlpha, 1/float64(st.deltaN))
+	st.deltaVar = alpha*d*d + (1-alpha)*st.deltaVar
+	// 首个桶的ΔP就是方差的唯一样本，无从判断方向，按均衡计
+	buyFrac := 0.5
+	if st.deltaN > 1 && st.deltaVar > 0 {
+		buyFrac = normCDF(d / math.Sqrt(st.deltaVar))
+	}
+	st.lastClose = closePrice
+
+	n := v.cfg.Global.VPIN.NumBuckets
+	if n <= 0 {
+		n = 1
+	}
+	if len(st.imbalances) != n {
+		st.imbalances = make([]float64, n)
+		st.next, st.count = 0, 0
+	}
+	// |Vb - Vs| = V·|2Φ - 1|
+	st.imbalances[st.next] = st.curVolume * math.Abs(2*buyFrac-1)
+	st.next = (st.next + 1) % n
+	if st.count < n {
+		st.count++
+	}
+	st.curVolume = 0
+}
+
+// VPIN 返回交易对当前VPIN，完整桶数不足min_buckets时ok为false
+func (v *VPINCalculator) VPIN(symbol string) (float64, bool) {
+	bucket := v.bucketVolume(symbol)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	st := v.states[symbol]
+	if st == nil {
+		return 0, false
+	}
+	return v.vpinLocked(st, bucket)
+}
+
+func (v *VPINCalculator) vpinLocked(st *vpinState, bucket float64) (float64, bool) {
+	if st.count == 0 || st.count < v.cfg.Global.VPIN.MinBuckets || bucket <= 0 {
+		return 0, false
+	}
+	sum := 0.0
+	for i := 0; i < st.count; i++ {
+		sum += st.imbalances[i]
+	}
+	return math.Min(sum/(float64(st.count)*bucket), 1), true
+}
+
+// Assess 评估当前订单流毒性，返回价差放大系数。
+// VPIN达到pause_threshold时进入暂停期，暂停期内返回ErrHighToxicity
+func (v *VPINCalculator) Assess(symbol string, now time.Time) (float64, error) {
+	vcfg := v.cfg.Global.VPIN
+	bucket := v.bucketVolume(symbol)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	st := v.states[symbol]
+	if st == nil {
+		return 1, nil
+	}
+	if now.Before(st.pausedUntil) {
+		return 0, ErrHighToxicity
+	}
+
+	vpin, ready := v.vpinLocked(st, bucket)
+	if !ready {
+		return 1, nil
+	}
+
+	if vpin >= vcfg.PauseThreshold {
+		st.pausedUntil = now.Add(time.Duration(vcfg.PauseSeconds * float64(time.Second)))
+		metrics.RecordVPINPause(symbol)
+		log.Warn().
+			Str("symbol", symbol).
+			Float64("vpin", vpin).
+			Float64("pause_threshold", vcfg.PauseThreshold).
+			Float64("pause_sec", vcfg.PauseSeconds).
+			Msg("【VPIN】订单流毒性过高，暂停报价")
+		return 0, ErrHighToxicity
+	}
+	if vpin >= vcfg.WidenThreshold {
+		return 1 + vpin*vcfg.WidenMultiplier, nil
+	}
+	return 1, nil
+}
+
+// bucketVolume 交易对成交量桶大小：优先使用交易对配置
+func (v *VPINCalculator) bucketVolume(symbol string) float64 {
+	if symCfg := v.cfg.GetSymbolConfig(symbol); symCfg != nil && symCfg.VPINBucketVolume > 0 {
+		return symCfg.VPINBucketVolume
+	}
+	return v.cfg.Global.VPIN.BucketVolume
+}
+
+func (v *VPINCalculator) stateLocked(symbol string) *vpinState {
+	st := v.states[symbol]
+	if st == nil {
+		st = &vpinState{}
+		v.states[symbol] = st
+	}
+	return st
+}
+
+// normCDF 标准正态分布函数Φ(x)
+func normCDF(x float64) float64 {
+	return 0.5 * (1 + math.Erf(x/math.Sqrt2))
+}
@@ -0,0 +1,176 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/newplayman/market-maker-phoenix/internal/config"
+	"github.com/newplayman/market-maker-phoenix/internal/store"
+)
+
+func vpinConfig(widen, pause float64) *config.Config {
+	return &config.Config{
+		Global: config.GlobalConfig{
+			VPIN: config.VPINConfig{
+				Enabled:         true,
+				BucketVolume:    10,
+				NumBuckets:      4,
+				MinBuckets:      2,
+				WidenThreshold:  widen,
+				WidenMultiplier: 0.2,
+				PauseThreshold:  pause,
+				PauseSeconds:    5,
+			},
+		},
+		Symbols: []config.SymbolConfig{
+			{
+				Symbol:                "ETHUSDC",
+				NetMax:                1.0,
+				MinSpread:             0.0001,
+				TickSize:              0.01,
+				MinQty:                0.001,
+				TotalLayers:           3,
+				UnifiedLayerSize:      0.01,
+				GridStartOffset:       2,
+				GridFirstSpacing:      1,
+				GridSpacingMultiplier: 1.2,
+				MaxCancelPerMin:       50,
+			},
+		},
+	}
+}
+
+// feedTrend 以加速单边上涨的成交填满n个桶：价格变动持续大于历史波动，BVC几乎全部归为买方
+func feedTrend(v *VPINCalculator, n int) {
+	price, step := 3000.0, 0.01
+	for i := 0; i < n*10; i++ {
+		price += step
+		step *= 1.2
+		v.UpdateTrade("ETHUSDC", price, 1)
+	}
+}
+
+func TestVPINCalculator_Buckets(t *testing.T) {
+	v := NewVPINCalculator(vpinConfig(0.7, 0.9))
+
+	// 单笔25手跨越两个桶，未满的5手留在当前桶
+	v.UpdateTrade("ETHUSDC", 3000, 25)
+	if vpin, ok := v.VPIN("ETHUSDC"); !ok || vpin != 0 {
+		t.Fatalf("expected balanced VPIN 0 after two buckets, got %f ok=%v", vpin, ok)
+	}
+
+	// 单边上涨成交挤出窗口中的均衡桶后VPIN接近1
+	feedTrend(v, 5)
+	vpin, ok := v.VPIN("ETHUSDC")
+	if !ok || vpin < 0.95 || vpin > 1 {
+		t.Fatalf("expected toxic VPIN close to 1, got %f ok=%v", vpin, ok)
+	}
+
+	// 价格来回震荡时买卖量接近均衡，VPIN显著下降
+	v2 := NewVPINCalculator(vpinConfig(0.7, 0.9))
+	for i := 0; i < 40; i++ {
+		v2.UpdateTrade("ETHUSDC", 3000+float64(i%2), 1)
+	}
+	if calm, ok := v2.VPIN("ETHUSDC"); !ok || calm >= vpin/2 {
+		t.Fatalf("expected low VPIN for two-sided flow, got %f ok=%v", calm, ok)
+	}
+
+	if _, ok := v.VPIN("BTCUSDC"); ok {
+		t.Fatal("unknown symbol should not be ready")
+	}
+}
+
+// TestVPINCalculator_RepeatedPrices 归集成交中相邻成交大多同价：单边上涨的毒性按桶间价格变动识别，不被同价成交稀释
+func TestVPINCalculator_RepeatedPrices(t *testing.T) {
+	v := NewVPINCalculator(vpinConfig(0.7, 0.9))
+	price := 3000.0
+	for i := 0; i < 100; i++ {
+		if i%5 == 4 {
+			price += 0.5
+		}
+		v.UpdateTrade("ETHUSDC", price, 1)
+	}
+	if vpin, ok := v.VPIN("ETHUSDC"); !ok || vpin < 0.6 {
+		t.Fatalf("expected one-sided flow with repeated prices to stay toxic, got %f ok=%v", vpin, ok)
+	}
+}
+
+// TestVPINCalculator_RandomWalkWarmup 随机游走的均衡成交：刚满min_buckets时σΔP尚未稳定，VPIN也不应触发放大或暂停
+func TestVPINCalculator_RandomWalkWarmup(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	sum, runs := 0.0, 200
+	for run := 0; run < runs; run++ {
+		cfg := vpinConfig(0.7, 0.9)
+		cfg.Global.VPIN.NumBuckets, cfg.Global.VPIN.MinBuckets = 50, 5
+		v := NewVPINCalculator(cfg)
+		price := 3000.0
+		for {
+			price += rng.NormFloat64() * 0.5
+			v.UpdateTrade("ETHUSDC", price, 1)
+			if vpin, ok := v.VPIN("ETHUSDC"); ok {
+				if vpin >= cfg.Global.VPIN.PauseThreshold {
+					t.Fatalf("run %d: random flow paused quoting right after warm-up, vpin=%f", run, vpin)
+				}
+				sum += vpin
+				break
+			}
+		}
+	}
+	if mean := sum / float64(runs); mean >= 0.6 {
+		t.Fatalf("expected random flow VPIN around 0.5 after warm-up, got mean %f", mean)
+	}
+}
+
+func TestASMM_VPINWidenAndPause(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewStore("", time.Hour)
+	st.InitSymbol("ETHUSDC", 100)
+	now := time.Unix(1700000000, 0)
+	st.SetClock(func() time.Time { return now })
+	setMarket(st, "ETHUSDC", 3000, 0)
+
+	// 放大：VPIN≈1落在[widen, pause)之间
+	cfg := vpinConfig(0.5, 1)
+	asmm := NewASMM(cfg, st)
+	base, _, err := asmm.GenerateQuotes(ctx, "ETHUSDC")
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	asmm.SetVPINCalculator(NewVPINCalculator(cfg))
+	feedTrend(asmm.vpin, 4)
+	vpin, _ := asmm.vpin.VPIN("ETHUSDC")
+	wide, wideSells, err := asmm.GenerateQuotes(ctx, "ETHUSDC")
+	if err != nil {
+		t.Fatalf("generate widened: %v", err)
+	}
+	want := (3000 - base[0].Price) * (1 + vpin*0.2)
+	if math.Abs((3000-wide[0].Price)-want) > 0.011 || wideSells[0].Price-3000 < want-0.011 {
+		t.Fatalf("expected buy1 distance %.4f, got %.4f", want, 3000-wide[0].Price)
+	}
+
+	// 暂停：VPIN超过pause_threshold后pause_seconds内拒绝报价（首个桶尚无σΔP按均衡计，需滚出4个桶的窗口）
+	cfg = vpinConfig(0.5, 0.9)
+	asmm = NewASMM(cfg, st)
+	asmm.SetVPINCalculator(NewVPINCalculator(cfg))
+	feedTrend(asmm.vpin, 5)
+	if _, _, err := asmm.GenerateQuotes(ctx, "ETHUSDC"); !errors.Is(err, ErrHighToxicity) {
+		t.Fatalf("expected ErrHighToxicity, got %v", err)
+	}
+
+	// 暂停期内即使毒性已回落也不恢复报价
+	for i := 0; i < 40; i++ {
+		asmm.OnTrade("ETHUSDC", 3000+float64(i%2), 1)
+	}
+	now = now.Add(4 * time.Second)
+	if _, _, err := asmm.GenerateQuotes(ctx, "ETHUSDC"); !errors.Is(err, ErrHighToxicity) {
+		t.Fatalf("expected pause to hold, got %v", err)
+	}
+	now = now.Add(2 * time.Second)
+	if buys, _, err := asmm.GenerateQuotes(ctx, "ETHUSDC"); err != nil || len(buys) == 0 {
+		t.Fatalf("expected quoting to resume, got %v", err)
+	}
+}
@@ -2,6 +2,7 @@ package test
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -59,6 +60,31 @@ func (m *MockExchange) CancelOrder(ctx context.Context, symbol string, clientOrd
 	return nil
 }
 
+func (m *MockExchange) AmendOrder(ctx context.Context, order *gateway.Order) (*gateway.Order, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.openOrders[order.Symbol] {
+		if o.ClientOrderID == order.ClientOrderID {
+			o.Price = order.Price
+			o.Quantity = order.Quantity
+			return o, nil
+		}
+	}
+	return nil, fmt.Errorf("amend status 400: {\"code\":-2013,\"msg\":\"Order does not exist.\"}")
+}
+
+func (m *MockExchange) AmendOrders(ctx context.Context, orders []*gateway.Order) []gateway.OrderResult {
+	return gateway.PlaceOrdersEach(ctx, orders, m.AmendOrder)
+}
+
+func (m *MockExchange) PlaceOrders(ctx context.Context, orders []*gateway.Order) []gateway.OrderResult {
+	return gateway.PlaceOrdersEach(ctx, orders, m.PlaceOrder)
+}
+
+func (m *MockExchange) CancelOrders(ctx context.Context, symbol string, clientOrderIDs []string) []error {
+	return gateway.CancelOrdersEach(ctx, symbol, clientOrderIDs, m.CancelOrder)
+}
+
 func (m *MockExchange) CancelAllOrders(ctx context.Context, symbol string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -81,6 +107,10 @@ func (m *MockExchange) GetAllPositions(ctx context.Context) ([]*gateway.Position
 	return nil, nil
 }
 
+func (m *MockExchange) GetAccountBalance(ctx context.Context) (float64, float64, error) {
+	return 0, 0, nil
+}
+
 func (m *MockExchange) GetFundingRate(ctx context.Context, symbol string) (*gateway.FundingRate, error) {
 	return &gateway.FundingRate{Symbol: symbol, Rate: 0}, nil
 }
@@ -94,6 +124,10 @@ func (m *MockExchange) StartDepthStream(ctx context.Context, symbols []string, c
 	return nil
 }
 
+func (m *MockExchange) StartTradeStream(ctx context.Context, symbols []string, onTrade func(*gateway.Trade)) error {
+	return nil
+}
+
 func (m *MockExchange) StartUserStream(ctx context.Context, callbacks *gateway.UserStreamCallbacks) error {
 	m.userStreamStarted = true
 	return nil